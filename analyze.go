@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/influx6/sitecrawler/crawler"
+	"github.com/klauspost/compress/zstd"
+)
+
+// errors ...
+var (
+	ErrUnknownAnalysis = errors.New("unknown analysis, expected top-broken, slowest, deepest, orphans or graph-stats")
+	ErrEmptyCrawl      = errors.New("stored crawl holds no reports")
+)
+
+// storedCrawl embodies a crawl restored from the results of it's store or it's
+// ndjson reports, with the link graph of it's pages rebuilt from their
+// outlinks, so it can be analysed without network access.
+type storedCrawl struct {
+	Reports []crawler.LinkReport
+	Graph   *crawler.Graph
+}
+
+// zstdMagic holds the magic number opening zstd frames.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// sqliteMagic holds the header opening sqlite database files.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// boltMagic holds the magic number of the meta page opening bolt database
+// files, following it's 16 bytes page header.
+var boltMagic = []byte{0xed, 0xda, 0x0c, 0xed}
+
+// boltHeader holds the length of the header detectStore needs to recognise
+// bolt database files.
+const boltHeader = 20
+
+// detectStore returns the kind of the store whose database file opens with
+// giving header, being a bolt or sqlite store. It returns false for other
+// files, such as ndjson reports.
+func detectStore(header []byte) (crawler.StoreKind, bool) {
+	switch {
+	case bytes.HasPrefix(header, sqliteMagic):
+		return crawler.StoreSQLite, true
+	case len(header) >= boltHeader && bytes.Equal(header[16:boltHeader], boltMagic):
+		return crawler.StoreBolt, true
+	}
+	return "", false
+}
+
+// openCrawl restores the crawl stored at giving path, being the database file
+// of a store of provided kind, else ndjson reports. Bolt and sqlite stores are
+// detected when no kind is provided.
+func openCrawl(path string, kind string) (*storedCrawl, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+	if kind == "" {
+		header, _ := buffered.Peek(boltHeader)
+		if detected, ok := detectStore(header); ok {
+			kind = string(detected)
+		}
+	}
+
+	if kind == "" {
+		return loadCrawl(buffered)
+	}
+
+	storeKind, err := crawler.ParseStoreKind(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	file.Close()
+
+	store, err := crawler.OpenStore(storeKind, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer store.Close()
+	return loadStoredCrawl(store)
+}
+
+// loadStoredCrawl restores the crawl whose results are held by giving store,
+// as recorded by `crawl -crawl.store`, taking the seed recorded along with
+// them as the seed of the crawl.
+func loadStoredCrawl(store crawler.Store) (*storedCrawl, error) {
+	seed, err := crawler.CrawlSeed(store)
+	if err != nil {
+		return nil, err
+	}
+
+	crawl := &storedCrawl{Graph: crawler.NewGraph()}
+	err = store.Each(crawler.StoreResults, func(_ string, value []byte) error {
+		var report crawler.LinkReport
+		if err := json.Unmarshal(value, &report); err != nil {
+			return err
+		}
+
+		if report.Path == nil {
+			return nil
+		}
+
+		crawl.Graph.Record(report, seed != nil && report.Path.String() == seed.String())
+		crawl.Reports = append(crawl.Reports, report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(crawl.Reports) == 0 {
+		return nil, ErrEmptyCrawl
+	}
+	return crawl, nil
+}
+
+// loadCrawl restores a crawl from giving ndjson reports, gzipped, zstd
+// compressed or not, as written by `crawl -crawl.format=ndjson`. The first
+// report is taken as the seed of the crawl.
+func loadCrawl(r io.Reader) (*storedCrawl, error) {
+	buffered := bufio.NewReader(r)
+	magic, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+
+		defer gz.Close()
+		r = gz
+	case bytes.Equal(magic, zstdMagic):
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+
+		defer zr.Close()
+		r = zr
+	default:
+		r = buffered
+	}
+
+	crawl := &storedCrawl{Graph: crawler.NewGraph()}
+
+	decoder := json.NewDecoder(r)
+	for {
+		var row reportRow
+		if err := decoder.Decode(&row); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		report, err := row.linkReport()
+		if err != nil {
+			return nil, err
+		}
+
+		crawl.Graph.Record(report, len(crawl.Reports) == 0)
+		crawl.Reports = append(crawl.Reports, report)
+	}
+
+	if len(crawl.Reports) == 0 {
+		return nil, ErrEmptyCrawl
+	}
+	return crawl, nil
+}
+
+// linkReport returns the LinkReport of the row, restoring the fields needed
+// for analyses.
+func (row reportRow) linkReport() (crawler.LinkReport, error) {
+	path, err := url.Parse(row.URL)
+	if err != nil {
+		return crawler.LinkReport{}, fmt.Errorf("url error: %+s for %+q", err, row.URL)
+	}
+
+	report := crawler.LinkReport{
+		Path:          path,
+		Status:        row.Status,
+		Title:         row.Title,
+		ContentType:   row.ContentType,
+		ContentLength: row.ContentLength,
+		Latency:       time.Duration(row.LatencyMS * float64(time.Millisecond)),
+		Owner:         row.Owner,
+		ParseWarnings: row.ParseWarnings,
+	}
+
+	for _, outlink := range row.Outlinks {
+		kidPath, err := url.Parse(outlink.URL)
+		if err != nil {
+			return crawler.LinkReport{}, fmt.Errorf("url error: %+s for %+q", err, outlink.URL)
+		}
+
+		kid := crawler.LinkReport{
+			Path:       kidPath,
+			Status:     outlink.Status,
+			Owner:      outlink.Owner,
+			Position:   outlink.Position,
+			Rel:        outlink.Rel,
+			AnchorText: outlink.AnchorText,
+			Heading:    outlink.Heading,
+			Origin:     outlink.Origin,
+		}
+
+		if outlink.RedirectedTo != "" {
+			if kid.RedirectedTo, err = url.Parse(outlink.RedirectedTo); err != nil {
+				return crawler.LinkReport{}, fmt.Errorf("url error: %+s for %+q", err, outlink.RedirectedTo)
+			}
+		}
+
+		report.PointsTo = append(report.PointsTo, kid)
+	}
+
+	return report, nil
+}
+
+// brokenLink embodies a broken url of a stored crawl with the pages linking to
+// it.
+type brokenLink struct {
+	URL    string
+	Status int
+	Reason string
+	Pages  []string
+}
+
+// TopBroken returns at most top broken urls of the crawl, ordered by the pages
+// linking to them, most linked first. Links skipped as crawler traps are not
+// counted as broken. A top of 0 or less returns all broken urls.
+func (c *storedCrawl) TopBroken(top int) []brokenLink {
+	found := map[string]*brokenLink{}
+	add := func(link string, status crawler.Status, page string) {
+		broken, ok := found[link]
+		if !ok {
+			broken = &brokenLink{URL: link, Status: status.LastStatus}
+			if status.Reason != nil {
+				broken.Reason = string(status.Reason.Code)
+			}
+			found[link] = broken
+		}
+
+		if page != "" && !hasPage(broken.Pages, page) {
+			broken.Pages = append(broken.Pages, page)
+		}
+	}
+
+	for _, report := range c.Reports {
+		if !report.Status.IsLive {
+			add(report.Path.String(), report.Status, "")
+		}
+
+		for _, kid := range report.PointsTo {
+			if reason := kid.Status.Reason; reason != nil && reason.Code == crawler.ReasonTrap {
+				continue
+			}
+
+			if !kid.Status.IsLive {
+				add(kid.Path.String(), kid.Status, report.Path.String())
+			}
+		}
+	}
+
+	links := make([]brokenLink, 0, len(found))
+	for _, broken := range found {
+		sort.Strings(broken.Pages)
+		links = append(links, *broken)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if len(links[i].Pages) != len(links[j].Pages) {
+			return len(links[i].Pages) > len(links[j].Pages)
+		}
+		return links[i].URL < links[j].URL
+	})
+	return links[:topCount(len(links), top)]
+}
+
+// Slowest returns at most top crawled pages ordered by their latency, slowest
+// first. A top of 0 or less returns all pages.
+func (c *storedCrawl) Slowest(top int) []crawler.LinkReport {
+	pages := append([]crawler.LinkReport(nil), c.Reports...)
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Latency > pages[j].Latency
+	})
+	return pages[:topCount(len(pages), top)]
+}
+
+// pageDepth embodies a crawled page with the fewest links followed from the
+// seed to reach it.
+type pageDepth struct {
+	URL   string
+	Depth int
+	Path  []string
+}
+
+// Deepest returns at most top crawled pages reachable from the seed ordered by
+// their depth, deepest first, along with the shortest path reaching them. A top
+// of 0 or less returns all pages.
+func (c *storedCrawl) Deepest(top int) []pageDepth {
+	var pages []pageDepth
+	for _, report := range c.Reports {
+		link := report.Path.String()
+		if path := c.Graph.ShortestPath(link); path != nil {
+			pages = append(pages, pageDepth{URL: link, Depth: len(path) - 1, Path: path})
+		}
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		if pages[i].Depth != pages[j].Depth {
+			return pages[i].Depth > pages[j].Depth
+		}
+		return pages[i].URL < pages[j].URL
+	})
+	return pages[:topCount(len(pages), top)]
+}
+
+// Orphans returns the urls of crawled pages no other page of the crawl links or
+// redirects to, such as those only found through sitemaps, ordered by url.
+// The seed is never an orphan.
+func (c *storedCrawl) Orphans() []string {
+	seed := c.Graph.Seed()
+
+	var orphans []string
+	for _, node := range c.Graph.Nodes() {
+		if !node.Crawled || node.URL == seed {
+			continue
+		}
+
+		linked := false
+		for _, edge := range c.Graph.Inlinks(node.URL) {
+			if edge.From != node.URL {
+				linked = true
+				break
+			}
+		}
+
+		if !linked {
+			orphans = append(orphans, node.URL)
+		}
+	}
+	return orphans
+}
+
+// graphStats embodies the shape of the link graph of a stored crawl.
+type graphStats struct {
+	Pages       int
+	URLs        int
+	Links       int
+	Broken      int
+	DeadEnds    int
+	Orphans     int
+	MaxDepth    int
+	AvgOutlinks float64
+}
+
+// Stats returns the shape of the crawl's link graph. Dead ends are crawled
+// pages without outgoing links.
+func (c *storedCrawl) Stats() graphStats {
+	stats := graphStats{
+		Broken:  len(c.TopBroken(0)),
+		Orphans: len(c.Orphans()),
+	}
+
+	for _, node := range c.Graph.Nodes() {
+		stats.URLs++
+		if !node.Crawled {
+			continue
+		}
+
+		stats.Pages++
+		outlinks := len(c.Graph.Outlinks(node.URL))
+		stats.Links += outlinks
+
+		if outlinks == 0 {
+			stats.DeadEnds++
+		}
+	}
+
+	if stats.Pages != 0 {
+		stats.AvgOutlinks = float64(stats.Links) / float64(stats.Pages)
+	}
+
+	c.Graph.Walk(func(node crawler.GraphNode, depth int) bool {
+		if node.Crawled && depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+		return true
+	})
+
+	return stats
+}
+
+// runAnalysis writes the giving analysis of the crawl into w, listing at most
+// top urls.
+func runAnalysis(w io.Writer, crawl *storedCrawl, analysis string, top int) error {
+	switch analysis {
+	case "top-broken":
+		for _, broken := range crawl.TopBroken(top) {
+			reason := broken.Reason
+			if reason == "" {
+				reason = "-"
+			}
+			fmt.Fprintf(w, "%d pages\t%d\t%s\t%s\n", len(broken.Pages), broken.Status, reason, broken.URL)
+		}
+	case "slowest":
+		for _, page := range crawl.Slowest(top) {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", formatDuration(page.Latency), page.Status.LastStatus, page.Path.String())
+		}
+	case "deepest":
+		for _, page := range crawl.Deepest(top) {
+			fmt.Fprintf(w, "%d\t%s\n", page.Depth, page.URL)
+		}
+	case "orphans":
+		for _, orphan := range crawl.Orphans() {
+			fmt.Fprintln(w, orphan)
+		}
+	case "graph-stats":
+		stats := crawl.Stats()
+		fmt.Fprintf(w, "Pages:\t\t%d\n", stats.Pages)
+		fmt.Fprintf(w, "URLs:\t\t%d\n", stats.URLs)
+		fmt.Fprintf(w, "Links:\t\t%d\n", stats.Links)
+		fmt.Fprintf(w, "Broken:\t\t%d\n", stats.Broken)
+		fmt.Fprintf(w, "Dead ends:\t%d\n", stats.DeadEnds)
+		fmt.Fprintf(w, "Orphans:\t%d\n", stats.Orphans)
+		fmt.Fprintf(w, "Max depth:\t%d\n", stats.MaxDepth)
+		fmt.Fprintf(w, "Avg outlinks:\t%.1f\n", stats.AvgOutlinks)
+	default:
+		return fmt.Errorf("%+s: %+q", ErrUnknownAnalysis, analysis)
+	}
+	return nil
+}
+
+// topCount returns the count of items listed out of total, being at most top,
+// or total if top is 0 or less.
+func topCount(total int, top int) int {
+	if top > 0 && total > top {
+		return top
+	}
+	return total
+}
+
+// hasPage returns true if giving pages hold page.
+func hasPage(pages []string, page string) bool {
+	for _, item := range pages {
+		if item == page {
+			return true
+		}
+	}
+	return false
+}
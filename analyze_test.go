@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestAnalyzeStoredCrawl(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+		return link
+	}
+
+	live := crawler.Status{IsLive: true, LastStatus: 200}
+	gone := crawler.Status{LastStatus: 404, Reason: &crawler.Reason{Code: crawler.ReasonHTTPStatus}}
+	trap := crawler.Status{Reason: &crawler.Reason{Code: crawler.ReasonTrap}}
+
+	reports := []crawler.LinkReport{
+		{
+			Path:    parse("http://example.com/"),
+			Status:  live,
+			Latency: 20 * time.Millisecond,
+			PointsTo: []crawler.LinkReport{
+				{Path: parse("http://example.com/about"), Status: live},
+				{Path: parse("http://example.com/old"), Status: live, RedirectedTo: parse("http://example.com/blog")},
+				{Path: parse("http://example.com/gone"), Status: gone},
+				{Path: parse("http://example.com/calendar?page=999"), Status: trap},
+			},
+		},
+		{
+			Path:     parse("http://example.com/about"),
+			Status:   live,
+			Latency:  300 * time.Millisecond,
+			PointsTo: []crawler.LinkReport{{Path: parse("http://example.com/gone"), Status: gone}},
+		},
+		{
+			Path:     parse("http://example.com/blog"),
+			Status:   live,
+			Latency:  80 * time.Millisecond,
+			PointsTo: []crawler.LinkReport{{Path: parse("http://example.com/blog/post"), Status: live}},
+		},
+		{Path: parse("http://example.com/blog/post"), Status: live, Latency: 10 * time.Millisecond},
+		{Path: parse("http://example.com/hidden"), Status: live, Latency: 40 * time.Millisecond},
+	}
+
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	writer, _ := newReportWriter("ndjson", gz)
+	for _, report := range reports {
+		if err := writer.Write(report); err != nil {
+			tests.FailedWithError(err, "Should have successfully written report")
+		}
+	}
+	writer.Flush()
+	gz.Close()
+
+	crawl, err := loadCrawl(&out)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded gzipped crawl")
+	}
+	tests.Passed("Should have successfully loaded gzipped crawl")
+
+	if len(crawl.Reports) != 5 || crawl.Graph.Seed() != "http://example.com/" || crawl.Reports[1].Latency != 300*time.Millisecond {
+		tests.Info("Received Reports: %#v", crawl.Reports)
+		tests.Failed("Should have restored reports of crawl")
+	}
+	tests.Passed("Should have restored reports of crawl")
+
+	broken := crawl.TopBroken(10)
+	if len(broken) != 1 || broken[0].URL != "http://example.com/gone" || len(broken[0].Pages) != 2 || broken[0].Status != 404 || broken[0].Reason != "http-status" {
+		tests.Info("Received Broken: %#v", broken)
+		tests.Failed("Should have listed broken urls by linking pages, skipping traps")
+	}
+	tests.Passed("Should have listed broken urls by linking pages, skipping traps")
+
+	slowest := crawl.Slowest(2)
+	if len(slowest) != 2 || slowest[0].Path.Path != "/about" || slowest[1].Path.Path != "/blog" {
+		tests.Info("Received Slowest: %#v", slowest)
+		tests.Failed("Should have listed slowest pages")
+	}
+	tests.Passed("Should have listed slowest pages")
+
+	deepest := crawl.Deepest(1)
+	if len(deepest) != 1 || deepest[0].URL != "http://example.com/blog/post" || deepest[0].Depth != 3 {
+		tests.Info("Received Deepest: %#v", deepest)
+		tests.Failed("Should have listed deepest pages through redirects")
+	}
+	tests.Passed("Should have listed deepest pages through redirects")
+
+	if orphans := crawl.Orphans(); len(orphans) != 1 || orphans[0] != "http://example.com/hidden" {
+		tests.Info("Received Orphans: %#v", orphans)
+		tests.Failed("Should have listed pages no other page links to")
+	}
+	tests.Passed("Should have listed pages no other page links to")
+
+	stats := crawl.Stats()
+	if stats.Pages != 5 || stats.Links != 6 || stats.Broken != 1 || stats.DeadEnds != 2 || stats.Orphans != 1 || stats.MaxDepth != 3 {
+		tests.Info("Received Stats: %#v", stats)
+		tests.Failed("Should have measured shape of link graph")
+	}
+	tests.Passed("Should have measured shape of link graph")
+
+	var listing bytes.Buffer
+	if err := runAnalysis(&listing, crawl, "top-broken", 0); err != nil || listing.String() != "2 pages\t404\thttp-status\thttp://example.com/gone\n" {
+		tests.Info("Received Listing: %q", listing.String())
+		tests.Failed("Should have written top broken urls")
+	}
+	tests.Passed("Should have written top broken urls")
+
+	if err := runAnalysis(&listing, crawl, "popular", 0); err == nil || !strings.Contains(err.Error(), ErrUnknownAnalysis.Error()) {
+		tests.Info("Received Error: %+s", err)
+		tests.Failed("Should have failed unknown analysis")
+	}
+	tests.Passed("Should have failed unknown analysis")
+
+	store := crawler.NewMemoryStore()
+	store.Put(crawler.StoreCrawl, "seed", []byte(`"http://example.com/"`))
+	for _, report := range reports {
+		data, err := json.Marshal(report)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully marshalled report")
+		}
+		store.Put(crawler.StoreResults, report.Path.String(), data)
+	}
+
+	stored, err := loadStoredCrawl(store)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded crawl from store")
+	}
+
+	if len(stored.Reports) != 5 || stored.Graph.Seed() != "http://example.com/" || stored.Stats() != stats {
+		tests.Info("Received Stats: %#v", stored.Stats())
+		tests.Failed("Should have restored crawl from results of store")
+	}
+	tests.Passed("Should have restored crawl from results of store")
+
+	if _, err := loadStoredCrawl(crawler.NewMemoryStore()); err != ErrEmptyCrawl {
+		tests.Info("Received Error: %+s", err)
+		tests.Failed("Should have failed to load crawl from empty store")
+	}
+	tests.Passed("Should have failed to load crawl from empty store")
+
+	if _, err := loadCrawl(strings.NewReader("")); err != ErrEmptyCrawl {
+		tests.Info("Received Error: %+s", err)
+		tests.Failed("Should have failed to load empty crawl")
+	}
+	tests.Passed("Should have failed to load empty crawl")
+}
+
+func TestAnalyzeCrawlStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/about"></a><a href="/gone"></a>`)
+		case "/about":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<a href="/gone"></a>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "crawl-analyze")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	for _, kind := range []crawler.StoreKind{crawler.StoreBolt, crawler.StoreSQLite} {
+		path := filepath.Join(dir, string(kind)+".db")
+		if status, stderr, err := runMain("-crawl.store="+string(kind), "-crawl.store-path="+path, "crawl", server.URL); err != nil || status != 0 {
+			tests.Info("Status: %d, Stderr: %q", status, stderr)
+			tests.FailedWithError(err, "Should have successfully run crawl with %s store", kind)
+		}
+
+		stdout, status, stderr, err := runMainStdout("analyze", "top-broken", path)
+		if err != nil || status != 0 {
+			tests.Info("Status: %d, Stderr: %q", status, stderr)
+			tests.FailedWithError(err, "Should have successfully analysed %s store", kind)
+		}
+
+		if expected := "2 pages\t404\thttp-status\t" + server.URL + "/gone\n"; stdout != expected {
+			tests.Info("Expected: %q", expected)
+			tests.Info("Received: %q", stdout)
+			tests.Failed("Should have listed top broken urls of crawl in %s store", kind)
+		}
+		tests.Passed("Should have listed top broken urls of crawl in %s store", kind)
+	}
+
+	path := filepath.Join(dir, "crawl.json")
+	if status, stderr, err := runMain("-crawl.store=json", "-crawl.store-path="+path, "crawl", server.URL); err != nil || status != 0 {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.FailedWithError(err, "Should have successfully run crawl with json store")
+	}
+
+	stdout, status, stderr, err := runMainStdout("-analyze.store=json", "analyze", "deepest", path)
+	if err != nil || status != 0 || !strings.Contains(stdout, "1\t"+server.URL+"/about\n") {
+		tests.Info("Status: %d, Stdout: %q, Stderr: %q", status, stdout, stderr)
+		tests.Failed("Should have analysed crawl in json store")
+	}
+	tests.Passed("Should have analysed crawl in json store")
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/influx6/sitecrawler/crawler"
+	"gopkg.in/yaml.v2"
+)
+
+// defaults of a batch of crawls.
+const (
+	defaultBatchConcurrency = 4
+	defaultBatchWorkers     = 50
+	defaultBatchTimeout     = 3 * time.Second
+	batchSummaryName        = "summary.json"
+)
+
+// ErrNoSites is returned for batch configs listing no sites to crawl.
+var ErrNoSites = errors.New("batch config lists no sites")
+
+// siteNames matches the names usable for a site, as they name it's output files.
+var siteNames = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// batchConfig embodies a batch of independent crawls of many sites, run at
+// most Concurrency at a time, writing each site's reports into OutputDir.
+type batchConfig struct {
+	Concurrency int         `yaml:"concurrency"`
+	OutputDir   string      `yaml:"output_dir"`
+	Sites       []batchSite `yaml:"sites"`
+}
+
+// batchSite embodies a site crawled by a batch with it's own crawl options,
+// mirroring the flags of the crawl command.
+type batchSite struct {
+	Name             string        `yaml:"name"`
+	URL              string        `yaml:"url"`
+	Depth            int           `yaml:"depth"`
+	Format           string        `yaml:"format"`
+	Compress         string        `yaml:"compress"`
+	Rate             string        `yaml:"rate"`
+	Timeout          time.Duration `yaml:"timeout"`
+	MaxDuration      time.Duration `yaml:"max_duration"`
+	MaxPages         int           `yaml:"max_pages"`
+	MaxRequests      int           `yaml:"max_requests"`
+	Workers          int           `yaml:"workers"`
+	UserAgent        string        `yaml:"user_agent"`
+	Headers          []string      `yaml:"headers"`
+	Cookies          []string      `yaml:"cookies"`
+	IgnoreCrawlDelay bool          `yaml:"ignore_crawl_delay"`
+	OwnHosts         []string      `yaml:"own_hosts"`
+	ScanStylesheets  bool          `yaml:"stylesheets"`
+	FollowFeeds      bool          `yaml:"feeds"`
+	ScanJSON         bool          `yaml:"json"`
+	PrimeCache       bool          `yaml:"prime_cache"`
+	ScanSocial       bool          `yaml:"social"`
+	CanonicalHost    string        `yaml:"canonical_host"`
+	OutlinkOrigins   []string      `yaml:"outlink_origins"`
+	Store            string        `yaml:"store"`
+	StorePath        string        `yaml:"store_path"`
+
+	target    *url.URL
+	canonical *canonicalHost
+	origins   outlinkOrigins
+	store     crawler.StoreKind
+}
+
+// batchResult embodies the outcome of crawling a site of a batch.
+type batchResult struct {
+	Site     string        `json:"site"`
+	URL      string        `json:"url"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Summary  *crawlSummary `json:"summary,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// loadBatchConfig returns the batchConfig stored as yaml in giving file.
+func loadBatchConfig(path string) (batchConfig, error) {
+	var config batchConfig
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return config, fmt.Errorf("batch config %+q: %+s", path, err)
+	}
+
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("batch config %+q: %+s", path, err)
+	}
+
+	return config, nil
+}
+
+// validate checks all sites of the config have a unique name and a valid url,
+// setting defaults of unset options.
+func (c *batchConfig) validate() error {
+	if len(c.Sites) == 0 {
+		return ErrNoSites
+	}
+
+	if c.Concurrency <= 0 {
+		c.Concurrency = defaultBatchConcurrency
+	}
+
+	if c.OutputDir == "" {
+		c.OutputDir = "."
+	}
+
+	names := map[string]bool{}
+	for index := range c.Sites {
+		site := &c.Sites[index]
+
+		target, err := url.Parse(site.URL)
+		if err != nil {
+			return fmt.Errorf("site %+q: %+s", site.URL, err)
+		}
+
+		if target.Host == "" {
+			return fmt.Errorf("site %+q: url has no host", site.URL)
+		}
+		site.target = target
+
+		if site.canonical, err = parseCanonicalHost(site.CanonicalHost); err != nil {
+			return fmt.Errorf("site %+q: %+s", site.URL, err)
+		}
+		site.origins = parseOutlinkOrigins(site.OutlinkOrigins)
+
+		if site.Name == "" {
+			site.Name = target.Host
+		}
+
+		if !siteNames.MatchString(site.Name) {
+			return fmt.Errorf("site name %+q must only hold letters, digits, '.', '_' or '-'", site.Name)
+		}
+
+		if names[site.Name] {
+			return fmt.Errorf("site name %+q is not unique", site.Name)
+		}
+		names[site.Name] = true
+
+		if _, err := newReportWriter(site.Format, ioutil.Discard); err != nil {
+			return fmt.Errorf("site %+q: %+s", site.Name, err)
+		}
+
+		if _, err := newCompressor(site.Compress, ioutil.Discard); err != nil {
+			return fmt.Errorf("site %+q: %+s", site.Name, err)
+		}
+
+		if err := checkParquetCompression(site.Format, site.Compress); err != nil {
+			return fmt.Errorf("site %+q: %+s", site.Name, err)
+		}
+
+		if site.Store != "" {
+			if site.store, err = crawler.ParseStoreKind(site.Store); err != nil {
+				return fmt.Errorf("site %+q: %+s", site.Name, err)
+			}
+		}
+
+		if site.Rate != "" {
+			if _, err := crawler.ParseRate(site.Rate); err != nil {
+				return fmt.Errorf("site %+q: %+s", site.Name, err)
+			}
+		}
+
+		if site.Timeout <= 0 {
+			site.Timeout = defaultBatchTimeout
+		}
+
+		if site.Workers <= 0 {
+			site.Workers = defaultBatchWorkers
+		}
+
+		if site.UserAgent == "" {
+			site.UserAgent = crawler.DefaultUserAgent
+		}
+	}
+
+	return nil
+}
+
+// runBatch crawls all sites of config, at most config.Concurrency at a time,
+// returning the result of each in the order sites are listed. Progress is
+// logged into logs.
+func runBatch(ctx context.Context, config batchConfig, logs io.Writer) []batchResult {
+	results := make([]batchResult, len(config.Sites))
+	slots := make(chan struct{}, config.Concurrency)
+
+	var ml sync.Mutex
+	var wg sync.WaitGroup
+
+	for index, site := range config.Sites {
+		wg.Add(1)
+		go func(index int, site batchSite) {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				results[index] = batchResult{Site: site.Name, URL: site.URL, Error: ctx.Err().Error()}
+				return
+			}
+			defer func() { <-slots }()
+
+			ml.Lock()
+			fmt.Fprintf(logs, "Crawling %s: %s\n", site.Name, site.URL)
+			ml.Unlock()
+
+			result := crawlSite(ctx, site, config.OutputDir)
+
+			ml.Lock()
+			if result.Error != "" {
+				fmt.Fprintf(logs, "Failed %s after %s: %s\n", site.Name, result.Duration, result.Error)
+			} else {
+				fmt.Fprintf(logs, "Finished %s after %s: %d pages\n", site.Name, result.Duration, result.Summary.Pages)
+			}
+			ml.Unlock()
+
+			results[index] = result
+		}(index, site)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// crawlSite crawls giving site, writing it's reports into a file named after
+// the site within dir.
+func crawlSite(ctx context.Context, site batchSite, dir string) batchResult {
+	start := time.Now()
+	result := batchResult{Site: site.Name, URL: site.URL}
+
+	summary, output, err := crawlSiteInto(ctx, site, dir)
+	result.Duration = time.Since(start)
+	result.Output = output
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Summary = summary
+	return result
+}
+
+// crawlSiteInto crawls giving site, returning the summary of the crawl and the
+// path of the file it's reports were written into.
+func crawlSiteInto(ctx context.Context, site batchSite, dir string) (*crawlSummary, string, error) {
+	var pages crawler.PageCrawler
+	pages.Target = site.target
+	pages.MaxDepth = site.Depth
+	pages.UserAgent = site.UserAgent
+	pages.IgnoreCrawlDelay = site.IgnoreCrawlDelay
+	pages.OwnHosts = site.OwnHosts
+	pages.ScanStylesheets = site.ScanStylesheets
+	pages.FollowFeeds = site.FollowFeeds
+	pages.ScanJSON = site.ScanJSON
+	pages.PrimeCache = site.PrimeCache
+	pages.ScanSocial = site.ScanSocial
+	pages.RequestTimeout = site.Timeout
+
+	var err error
+	if pages.Headers, err = parseHeaders(site.Headers); err != nil {
+		return nil, "", err
+	}
+
+	if pages.Cookies, err = parseCookies(site.Cookies); err != nil {
+		return nil, "", err
+	}
+
+	if site.Rate != "" {
+		if pages.Rate, err = crawler.ParseRate(site.Rate); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if site.MaxPages > 0 || site.MaxRequests > 0 {
+		pages.Budget = crawler.NewBudget(site.MaxPages, site.MaxRequests)
+	}
+
+	if site.MaxPages > 0 {
+		pages.Coverage = crawler.NewSectionCoverage(crawler.DefaultSectionCoverage)
+	}
+
+	// The bolt and sqlite stores of sites default to a database file named
+	// after the site within dir, as json stores do to a json file.
+	var store crawler.Store
+	if site.store != "" {
+		storePath := site.StorePath
+		switch {
+		case storePath != "":
+		case site.store == crawler.StoreJSON:
+			storePath = filepath.Join(dir, site.Name+".store.json")
+		case site.store != crawler.StoreMemory:
+			storePath = filepath.Join(dir, site.Name+".db")
+		}
+
+		if store, err = crawler.OpenStore(site.store, storePath); err != nil {
+			return nil, "", err
+		}
+		defer store.Close()
+
+		if pages.Cache, err = crawler.LoadValidatorCacheStore(store); err != nil {
+			return nil, "", err
+		}
+		pages.State = crawler.NewCrawlState(store)
+		if err := pages.State.Reset(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	path := filepath.Join(dir, site.Name+formatExtension(site.Format, site.Compress))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	output, err := newCompressor(site.Compress, file)
+	if err != nil {
+		return nil, path, err
+	}
+
+	writer, err := newReportWriter(site.Format, output)
+	if err != nil {
+		return nil, path, err
+	}
+
+	crawlCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if site.MaxDuration > 0 {
+		crawlCtx, cancel = context.WithTimeout(crawlCtx, site.MaxDuration)
+		defer cancel()
+	}
+
+	client := &http.Client{
+		Transport: crawler.NewRoundTripper(crawler.TransportOptions{}),
+	}
+
+	if pages, err = crawler.NewPageCrawler(pages); err != nil {
+		return nil, path, err
+	}
+
+	pool := crawler.NewWorkerPool(site.Workers, crawlCtx)
+	defer pool.Stop()
+
+	summary := newCrawlSummary()
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() { pages.Run(crawlCtx, client, pool, reports) })
+
+	var writeErr error
+	for report := range reports {
+		report = site.canonical.Report(report)
+		summary.Observe(report)
+
+		if writeErr == nil {
+			writeErr = writer.Write(site.origins.Report(report))
+		}
+	}
+
+	if writeErr != nil {
+		return nil, path, writeErr
+	}
+
+	if err := writer.Flush(); err != nil {
+		return nil, path, err
+	}
+
+	if err := output.Close(); err != nil {
+		return nil, path, err
+	}
+
+	if err := pages.State.Err(); err != nil {
+		return nil, path, err
+	}
+
+	if store != nil {
+		if err := pages.Cache.SaveStore(store); err != nil {
+			return nil, path, err
+		}
+
+		// Json stores only write their file once closed.
+		if err := store.Close(); err != nil {
+			return nil, path, err
+		}
+	}
+
+	summary.Truncated = crawlCtx.Err() != nil || pages.Budget.Exhausted()
+	summary.PartialSections(pages.Coverage)
+	return summary, path, file.Close()
+}
+
+// writeBatchSummary writes giving results as indented json into path.
+func writeBatchSummary(path string, results []batchResult) error {
+	content, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
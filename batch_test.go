@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestLoadBatchConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitecrawler-batch")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	sites := filepath.Join(dir, "sites.yaml")
+	if err := ioutil.WriteFile(sites, []byte(`
+concurrency: 2
+sites:
+  - url: https://example.com
+    timeout: 10s
+    max_pages: 100
+    headers:
+      - "X-Api-Key: abc"
+  - name: shop
+    url: https://shop.example.com
+    format: ndjson
+    compress: gzip
+    store: sqlite
+`), 0644); err != nil {
+		tests.FailedWithError(err, "Should have successfully written batch config")
+	}
+
+	config, err := loadBatchConfig(sites)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded batch config")
+	}
+	tests.Passed("Should have successfully loaded batch config")
+
+	if config.Concurrency != 2 || len(config.Sites) != 2 || config.OutputDir != "." {
+		tests.Info("Received Config: %#v", config)
+		tests.Failed("Should have loaded all sites of batch config")
+	}
+	tests.Passed("Should have loaded all sites of batch config")
+
+	site := config.Sites[0]
+	if site.Name != "example.com" || site.Timeout != 10*time.Second || site.MaxPages != 100 || len(site.Headers) != 1 || site.Workers != defaultBatchWorkers {
+		tests.Info("Received Site: %#v", site)
+		tests.Failed("Should have named site after it's host with it's own options")
+	}
+	tests.Passed("Should have named site after it's host with it's own options")
+
+	if shop := config.Sites[1]; shop.store != crawler.StoreSQLite || config.Sites[0].store != "" {
+		tests.Info("Received Sites: %#v", config.Sites)
+		tests.Failed("Should have parsed store of site")
+	}
+	tests.Passed("Should have parsed store of site")
+
+	for _, invalid := range []string{
+		"sites: []",
+		"sites:\n  - url: https://example.com\n  - url: https://example.com/blog",
+		"sites:\n  - url: /no-host",
+		"sites:\n  - name: ../escape\n    url: https://example.com",
+		"sites:\n  - url: https://example.com\n    format: csv",
+		"sites:\n  - url: https://example.com\n    format: parquet\n    compress: zstd",
+		"sites:\n  - url: https://example.com\n    store: redis",
+		"sites:\n  - url: https://example.com\n    depht: 2",
+	} {
+		if err := ioutil.WriteFile(sites, []byte(invalid), 0644); err != nil {
+			tests.FailedWithError(err, "Should have successfully written batch config")
+		}
+
+		if _, err := loadBatchConfig(sites); err == nil {
+			tests.Info("Config: %q", invalid)
+			tests.Failed("Should have rejected invalid batch config")
+		}
+	}
+	tests.Passed("Should have rejected invalid batch config")
+}
+
+func TestRunBatch(t *testing.T) {
+	var active, overlapped int32
+
+	site := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			if atomic.AddInt32(&active, 1) > 1 {
+				atomic.StoreInt32(&overlapped, 1)
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+
+			w.Write([]byte(`<html><body>page</body></html>`))
+		}))
+	}
+
+	first := site()
+	defer first.Close()
+
+	second := site()
+	defer second.Close()
+
+	dir, err := ioutil.TempDir("", "sitecrawler-batch")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	config := batchConfig{
+		Concurrency: 1,
+		OutputDir:   dir,
+		Sites: []batchSite{
+			{Name: "first", URL: first.URL, Workers: 1},
+			{Name: "second", URL: second.URL, Format: "ndjson", Workers: 1, Store: "bolt"},
+			{Name: "missing", URL: "http://127.0.0.1:1/", Timeout: 500 * time.Millisecond},
+		},
+	}
+
+	if err := config.validate(); err != nil {
+		tests.FailedWithError(err, "Should have successfully validated batch config")
+	}
+
+	results := runBatch(context.Background(), config, ioutil.Discard)
+	if len(results) != 3 || results[0].Site != "first" || results[1].Site != "second" || results[2].Site != "missing" {
+		tests.Info("Received Results: %#v", results)
+		tests.Failed("Should have returned result of every site in listed order")
+	}
+	tests.Passed("Should have returned result of every site in listed order")
+
+	if atomic.LoadInt32(&overlapped) != 0 {
+		tests.Failed("Should have crawled a single site at a time")
+	}
+	tests.Passed("Should have crawled a single site at a time")
+
+	for _, result := range results[:2] {
+		if result.Error != "" || result.Summary == nil || result.Summary.Pages != 1 || result.Summary.Live != 1 {
+			tests.Info("Received Result: %#v", result)
+			tests.Failed("Should have successfully crawled site")
+		}
+
+		if _, err := os.Stat(result.Output); err != nil {
+			tests.FailedWithError(err, "Should have written reports of site into it's own file")
+		}
+	}
+	tests.Passed("Should have successfully crawled sites into their own files")
+
+	if results[1].Output != filepath.Join(dir, "second.ndjson") {
+		tests.Info("Received Output: %q", results[1].Output)
+		tests.Failed("Should have named output after site and it's format")
+	}
+	tests.Passed("Should have named output after site and it's format")
+
+	store, err := crawler.OpenStore(crawler.StoreBolt, filepath.Join(dir, "second.db"))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully opened store of site")
+	}
+
+	var stored []string
+	err = store.Each(crawler.StoreResults, func(target string, _ []byte) error {
+		stored = append(stored, target)
+		return nil
+	})
+	store.Close()
+
+	if err != nil || len(stored) != 1 || stored[0] != second.URL+"/" {
+		tests.Info("Stored: %q, Error: %+s", stored, err)
+		tests.Failed("Should have recorded crawl of site into store named after it")
+	}
+	tests.Passed("Should have recorded crawl of site into store named after it")
+
+	if results[2].Summary == nil || results[2].Summary.Live != 0 {
+		tests.Info("Received Result: %#v", results[2])
+		tests.Failed("Should have reported unreachable site as not live")
+	}
+	tests.Passed("Should have reported unreachable site as not live")
+
+	summaryFile := filepath.Join(dir, batchSummaryName)
+	if err := writeBatchSummary(summaryFile, results); err != nil {
+		tests.FailedWithError(err, "Should have successfully written batch summary")
+	}
+
+	content, err := ioutil.ReadFile(summaryFile)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read batch summary")
+	}
+
+	var summary []batchResult
+	if err := json.Unmarshal(content, &summary); err != nil || len(summary) != 3 {
+		tests.Info("Received Summary: %s", content)
+		tests.Failed("Should have written results of all sites into batch summary")
+	}
+	tests.Passed("Should have written results of all sites into batch summary")
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/influx6/sitecrawler/crawler"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
+)
+
+// benchSampleInterval sets how often memory and goroutines are sampled while a
+// benchmark crawl runs.
+const benchSampleInterval = 50 * time.Millisecond
+
+// benchConfig embodies the synthetic site crawled by a benchmark and the crawl
+// settings measured against it.
+type benchConfig struct {
+	Site            sitetest.Options
+	Depth           int
+	Workers         int
+	Rate            crawler.Rate
+	Timeout         time.Duration
+	MaxConnsPerHost int
+	CPUProfile      string
+	MemProfile      string
+}
+
+// benchResult embodies the measurements of a benchmark crawl.
+type benchResult struct {
+	Pages          int
+	Broken         int
+	Duration       time.Duration
+	PeakHeap       uint64
+	TotalAlloc     uint64
+	Mallocs        uint64
+	GCs            uint32
+	PeakGoroutines int
+}
+
+// PagesPerSecond returns the throughput of the crawl in pages crawled per
+// second.
+func (r benchResult) PagesPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Pages) / r.Duration.Seconds()
+}
+
+// AllocsPerPage returns the average heap allocations made per page crawled.
+func (r benchResult) AllocsPerPage() uint64 {
+	if r.Pages == 0 {
+		return 0
+	}
+	return r.Mallocs / uint64(r.Pages)
+}
+
+// runBench serves a synthetic site of giving config locally and crawls it,
+// measuring the crawl's throughput, memory and allocations. CPU and memory
+// profiles of the crawl are written if their files are set.
+func runBench(ctx context.Context, config benchConfig) (benchResult, error) {
+	var result benchResult
+
+	server := sitetest.NewServer(config.Site)
+	defer server.Close()
+
+	var pages crawler.PageCrawler
+	pages.Target = server.Target()
+	pages.MaxDepth = config.Depth
+	pages.Rate = config.Rate
+	pages.RequestTimeout = config.Timeout
+
+	pages, err := crawler.NewPageCrawler(pages)
+	if err != nil {
+		return result, err
+	}
+
+	client := &http.Client{
+		Transport: crawler.NewRoundTripper(crawler.TransportOptions{MaxConnsPerHost: config.MaxConnsPerHost}),
+	}
+
+	if config.CPUProfile != "" {
+		file, err := os.Create(config.CPUProfile)
+		if err != nil {
+			return result, err
+		}
+		defer file.Close()
+
+		if err := pprof.StartCPUProfile(file); err != nil {
+			return result, err
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	pool := crawler.NewWorkerPool(config.Workers, ctx)
+	defer pool.Stop()
+
+	runtime.GC()
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	done := make(chan struct{})
+	var sampler sync.WaitGroup
+	sampler.Add(1)
+	go func() {
+		defer sampler.Done()
+
+		ticker := time.NewTicker(benchSampleInterval)
+		defer ticker.Stop()
+
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > result.PeakHeap {
+				result.PeakHeap = stats.HeapAlloc
+			}
+			if goroutines := runtime.NumGoroutine(); goroutines > result.PeakGoroutines {
+				result.PeakGoroutines = goroutines
+			}
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() { pages.Run(ctx, client, pool, reports) })
+
+	for report := range reports {
+		result.Pages++
+		for _, kid := range report.PointsTo {
+			if !kid.Status.IsLive {
+				result.Broken++
+			}
+		}
+	}
+
+	result.Duration = time.Since(start)
+	close(done)
+	sampler.Wait()
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	result.TotalAlloc = after.TotalAlloc - before.TotalAlloc
+	result.Mallocs = after.Mallocs - before.Mallocs
+	result.GCs = after.NumGC - before.NumGC
+
+	if config.MemProfile != "" {
+		file, err := os.Create(config.MemProfile)
+		if err != nil {
+			return result, err
+		}
+		defer file.Close()
+
+		if err := pprof.Lookup("allocs").WriteTo(file, 0); err != nil {
+			return result, err
+		}
+	}
+
+	return result, ctx.Err()
+}
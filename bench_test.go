@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
+)
+
+func TestRunBench(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitecrawler-bench")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	config := benchConfig{
+		Site:       sitetest.Options{Pages: 300, Branching: 5, Errors: 7},
+		Workers:    50,
+		Timeout:    5 * time.Second,
+		MemProfile: filepath.Join(dir, "mem.prof"),
+	}
+
+	result, err := runBench(context.Background(), config)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run benchmark")
+	}
+	tests.Passed("Should have successfully run benchmark")
+
+	if result.Pages != 300 || result.Broken != 7 {
+		tests.Info("Received Pages: %d, Broken: %d", result.Pages, result.Broken)
+		tests.Failed("Should have crawled all pages of synthetic site")
+	}
+	tests.Passed("Should have crawled all pages of synthetic site")
+
+	if result.PagesPerSecond() <= 0 || result.Mallocs == 0 || result.PeakHeap == 0 || result.PeakGoroutines == 0 {
+		tests.Info("Received Result: %#v", result)
+		tests.Failed("Should have measured throughput, memory and allocations of crawl")
+	}
+	tests.Passed("Should have measured throughput, memory and allocations of crawl")
+
+	if info, err := os.Stat(config.MemProfile); err != nil || info.Size() == 0 {
+		tests.Failed("Should have written allocation profile of crawl")
+	}
+	tests.Passed("Should have written allocation profile of crawl")
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// ErrUnknownBufferPolicy is returned when parsing an unknown report buffer
+// policy.
+var ErrUnknownBufferPolicy = errors.New("unknown report buffer policy, expected block, drop-oldest or spill")
+
+// bufferPolicy defines how a bufferedWriter handles reports produced while
+// it's buffer is full.
+type bufferPolicy string
+
+// buffer policies ...
+const (
+	bufferBlock      bufferPolicy = "block"
+	bufferDropOldest bufferPolicy = "drop-oldest"
+	bufferSpill      bufferPolicy = "spill"
+)
+
+// parseBufferPolicy parses giving buffer policy, an empty policy defaults to
+// bufferBlock.
+func parseBufferPolicy(policy string) (bufferPolicy, error) {
+	switch bufferPolicy(strings.ToLower(policy)) {
+	case "", bufferBlock:
+		return bufferBlock, nil
+	case bufferDropOldest:
+		return bufferDropOldest, nil
+	case bufferSpill:
+		return bufferSpill, nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrUnknownBufferPolicy, policy)
+}
+
+// bufferedWriter implements the ReportWriter which delivers reports to another
+// ReportWriter from it's own goroutine through a bounded buffer, so a slow sink
+// can't stall the crawl nor grow it's memory without bounds. Reports written
+// while the buffer is full wait for room with bufferBlock, evict the oldest
+// buffered report with bufferDropOldest, or are spilled into a temporary file
+// with bufferSpill, to be delivered in order once the buffer drains.
+type bufferedWriter struct {
+	ReportWriter
+	size   int
+	policy bufferPolicy
+
+	ml      sync.Mutex
+	cond    *sync.Cond
+	queue   []crawler.LinkReport
+	closed  bool
+	err     error
+	done    chan struct{}
+	dropped int
+
+	spill       *os.File
+	spillReader *os.File
+	spillEnc    *json.Encoder
+	spillDec    *json.Decoder
+	spilled     int
+	unspill     int
+}
+
+// newBufferedWriter returns a new bufferedWriter delivering reports to giving
+// writer through a buffer of size reports handled by policy.
+func newBufferedWriter(writer ReportWriter, size int, policy bufferPolicy) *bufferedWriter {
+	if size < 1 {
+		size = 1
+	}
+
+	buffered := &bufferedWriter{
+		ReportWriter: writer,
+		size:         size,
+		policy:       policy,
+		done:         make(chan struct{}),
+	}
+	buffered.cond = sync.NewCond(&buffered.ml)
+
+	go buffered.drain()
+	return buffered
+}
+
+// Write buffers giving report for delivery, returning the error of the last
+// failed delivery if any.
+func (b *bufferedWriter) Write(report crawler.LinkReport) error {
+	b.ml.Lock()
+	defer b.ml.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+
+	switch b.policy {
+	case bufferDropOldest:
+		if len(b.queue) >= b.size {
+			b.queue = b.queue[1:]
+			b.dropped++
+		}
+	case bufferSpill:
+		// Reports are spilled while earlier ones remain spilled, so they're
+		// delivered in the order written.
+		if len(b.queue) >= b.size || b.spilled > b.unspill {
+			if err := b.spillReport(report); err != nil {
+				return err
+			}
+
+			b.cond.Broadcast()
+			return nil
+		}
+	default:
+		for len(b.queue) >= b.size && b.err == nil {
+			b.cond.Wait()
+		}
+
+		if b.err != nil {
+			return b.err
+		}
+	}
+
+	b.queue = append(b.queue, report)
+	b.cond.Broadcast()
+	return nil
+}
+
+// Flush waits for all buffered and spilled reports to be delivered before
+// flushing the underlying writer, removing the spill file if any.
+func (b *bufferedWriter) Flush() error {
+	b.ml.Lock()
+	b.closed = true
+	b.cond.Broadcast()
+	b.ml.Unlock()
+
+	<-b.done
+
+	if b.spill != nil {
+		b.spillReader.Close()
+		b.spill.Close()
+		os.Remove(b.spill.Name())
+	}
+
+	if b.err != nil {
+		return b.err
+	}
+	return b.ReportWriter.Flush()
+}
+
+// Dropped returns the total reports evicted from a full buffer.
+func (b *bufferedWriter) Dropped() int {
+	b.ml.Lock()
+	defer b.ml.Unlock()
+	return b.dropped
+}
+
+// Spilled returns the total reports spilled into the spill file.
+func (b *bufferedWriter) Spilled() int {
+	b.ml.Lock()
+	defer b.ml.Unlock()
+	return b.spilled
+}
+
+// drain delivers buffered reports, then spilled ones, to the underlying writer
+// till the writer is flushed or a delivery fails.
+func (b *bufferedWriter) drain() {
+	defer close(b.done)
+
+	for {
+		b.ml.Lock()
+		for len(b.queue) == 0 && b.spilled == b.unspill && !b.closed {
+			b.cond.Wait()
+		}
+
+		var report crawler.LinkReport
+		switch {
+		case len(b.queue) != 0:
+			report = b.queue[0]
+			b.queue = b.queue[1:]
+		case b.spilled != b.unspill:
+			if err := b.spillDec.Decode(&report); err != nil {
+				b.err = err
+				b.cond.Broadcast()
+				b.ml.Unlock()
+				return
+			}
+			b.unspill++
+		default:
+			b.ml.Unlock()
+			return
+		}
+
+		b.cond.Broadcast()
+		b.ml.Unlock()
+
+		if err := b.ReportWriter.Write(report); err != nil {
+			b.ml.Lock()
+			b.err = err
+			b.cond.Broadcast()
+			b.ml.Unlock()
+			return
+		}
+	}
+}
+
+// spillReport appends giving report to the spill file, creating it if needed.
+// It must be called with the lock held.
+func (b *bufferedWriter) spillReport(report crawler.LinkReport) error {
+	if b.spill == nil {
+		spill, err := ioutil.TempFile("", "sitecrawler-spill")
+		if err != nil {
+			return err
+		}
+
+		reader, err := os.Open(spill.Name())
+		if err != nil {
+			spill.Close()
+			os.Remove(spill.Name())
+			return err
+		}
+
+		b.spill = spill
+		b.spillReader = reader
+		b.spillEnc = json.NewEncoder(spill)
+		b.spillDec = json.NewDecoder(reader)
+	}
+
+	if err := b.spillEnc.Encode(report); err != nil {
+		return err
+	}
+
+	b.spilled++
+	return nil
+}
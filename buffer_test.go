@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// gatedWriter implements a ReportWriter recording the paths of reports written
+// once it's gate is opened, signalling started on it's first write.
+type gatedWriter struct {
+	ml      sync.Mutex
+	paths   []string
+	gate    chan struct{}
+	started chan struct{}
+	once    sync.Once
+	err     error
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{gate: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (g *gatedWriter) Write(report crawler.LinkReport) error {
+	g.once.Do(func() { close(g.started) })
+	<-g.gate
+
+	if g.err != nil {
+		return g.err
+	}
+
+	g.ml.Lock()
+	defer g.ml.Unlock()
+	g.paths = append(g.paths, report.Path.Path)
+	return nil
+}
+
+func (g *gatedWriter) Flush() error {
+	return nil
+}
+
+func TestBufferedWriter(t *testing.T) {
+	report := func(path string) crawler.LinkReport {
+		return crawler.LinkReport{Path: &url.URL{Scheme: "http", Host: "example.com", Path: path}, Status: crawler.Status{IsLive: true}}
+	}
+
+	// fill writes /0 and waits for the sink to block on it, before writing the
+	// remaining reports into the buffer.
+	fill := func(policy bufferPolicy) (*gatedWriter, *bufferedWriter) {
+		sink := newGatedWriter()
+		buffered := newBufferedWriter(sink, 2, policy)
+
+		buffered.Write(report("/0"))
+		<-sink.started
+
+		for _, path := range []string{"/1", "/2", "/3", "/4"} {
+			if err := buffered.Write(report(path)); err != nil {
+				tests.FailedWithError(err, "Should have successfully buffered report")
+			}
+		}
+		return sink, buffered
+	}
+
+	sink, buffered := fill(bufferDropOldest)
+	close(sink.gate)
+	if err := buffered.Flush(); err != nil {
+		tests.FailedWithError(err, "Should have successfully flushed buffered writer")
+	}
+
+	if strings.Join(sink.paths, ",") != "/0,/3,/4" || buffered.Dropped() != 2 {
+		tests.Info("Received Paths: %#v", sink.paths)
+		tests.Info("Received Dropped: %d", buffered.Dropped())
+		tests.Failed("Should have evicted oldest reports of full buffer")
+	}
+	tests.Passed("Should have evicted oldest reports of full buffer")
+
+	sink, buffered = fill(bufferSpill)
+	close(sink.gate)
+	if err := buffered.Flush(); err != nil {
+		tests.FailedWithError(err, "Should have successfully flushed buffered writer")
+	}
+
+	if strings.Join(sink.paths, ",") != "/0,/1,/2,/3,/4" || buffered.Spilled() != 2 || buffered.Dropped() != 0 {
+		tests.Info("Received Paths: %#v", sink.paths)
+		tests.Info("Received Spilled: %d", buffered.Spilled())
+		tests.Failed("Should have delivered spilled reports in order")
+	}
+	tests.Passed("Should have delivered spilled reports in order")
+
+	sink = newGatedWriter()
+	buffered = newBufferedWriter(sink, 2, bufferBlock)
+	buffered.Write(report("/0"))
+	<-sink.started
+	buffered.Write(report("/1"))
+	buffered.Write(report("/2"))
+
+	written := make(chan struct{})
+	go func() {
+		buffered.Write(report("/3"))
+		close(written)
+	}()
+
+	select {
+	case <-written:
+		tests.Failed("Should have blocked write into full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tests.Passed("Should have blocked write into full buffer")
+
+	close(sink.gate)
+	<-written
+	if err := buffered.Flush(); err != nil {
+		tests.FailedWithError(err, "Should have successfully flushed buffered writer")
+	}
+
+	if strings.Join(sink.paths, ",") != "/0,/1,/2,/3" {
+		tests.Info("Received Paths: %#v", sink.paths)
+		tests.Failed("Should have delivered all blocked reports in order")
+	}
+	tests.Passed("Should have delivered all blocked reports in order")
+
+	sink = newGatedWriter()
+	sink.err = errors.New("sink unavailable")
+	close(sink.gate)
+
+	buffered = newBufferedWriter(sink, 2, bufferBlock)
+	buffered.Write(report("/0"))
+	if err := buffered.Flush(); err != sink.err {
+		tests.Info("Received Error: %+s", err)
+		tests.Failed("Should have returned error of failed delivery")
+	}
+	tests.Passed("Should have returned error of failed delivery")
+
+	if _, err := parseBufferPolicy("drop-newest"); err == nil || !strings.Contains(err.Error(), ErrUnknownBufferPolicy.Error()) {
+		tests.Info("Received Error: %+s", err)
+		tests.Failed("Should have failed to parse unknown buffer policy")
+	}
+	tests.Passed("Should have failed to parse unknown buffer policy")
+}
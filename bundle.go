@@ -0,0 +1,189 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrUnknownBundle is returned for bundle paths whose extension is not a
+// supported archive.
+var ErrUnknownBundle = errors.New("unknown bundle archive, expected .tar, .tar.gz or .tar.zst")
+
+// bundleIndexName sets the name of the content index written first into a bundle.
+const bundleIndexName = "index.json"
+
+// crawlManifest embodies the metadata of a crawl packaged into it's bundle.
+type crawlManifest struct {
+	Target      string           `json:"target"`
+	StartedAt   time.Time        `json:"started_at"`
+	FinishedAt  time.Time        `json:"finished_at"`
+	Pages       int              `json:"pages"`
+	Format      string           `json:"format"`
+	Compression string           `json:"compression,omitempty"`
+	Truncated   bool             `json:"truncated"`
+	IndexNowKey *keyVerification `json:"indexnow_key,omitempty"`
+}
+
+// keyVerification embodies the result of verifying a hosted IndexNow key.
+type keyVerification struct {
+	Location string `json:"location"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// bundleEntry embodies a file packaged into a crawl bundle, read from Path on
+// disk else held in Content.
+type bundleEntry struct {
+	Name    string
+	Path    string
+	Content []byte
+}
+
+// bundleIndexEntry embodies the listing of a bundled file in the content index
+// of a bundle.
+type bundleIndexEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// bundleCompression returns the compression of the bundle archive at giving
+// path based on it's extension.
+func bundleCompression(path string) (string, error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".tar"):
+		return "none", nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "gzip", nil
+	case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"):
+		return "zstd", nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrUnknownBundle, path)
+}
+
+// jsonEntry returns a bundleEntry holding giving value as indented json.
+func jsonEntry(name string, value interface{}) (bundleEntry, error) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return bundleEntry{}, err
+	}
+	return bundleEntry{Name: name, Content: append(content, '\n')}, nil
+}
+
+// open returns a reader of the entry's content with it's size.
+func (b bundleEntry) open() (io.ReadCloser, int64, error) {
+	if b.Path == "" {
+		return nopReadCloser{Reader: bytes.NewReader(b.Content)}, int64(len(b.Content)), nil
+	}
+
+	file, err := os.Open(b.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	return file, stat.Size(), nil
+}
+
+// writeBundle writes giving entries into a tar archive at path, compressed
+// based on it's extension, preceded by a content index listing the size and
+// sha256 of every entry.
+func writeBundle(path string, entries []bundleEntry) error {
+	compression, err := bundleCompression(path)
+	if err != nil {
+		return err
+	}
+
+	index := make([]bundleIndexEntry, 0, len(entries))
+	for _, entry := range entries {
+		reader, _, err := entry.open()
+		if err != nil {
+			return err
+		}
+
+		hash := sha256.New()
+		size, err := io.Copy(hash, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+
+		index = append(index, bundleIndexEntry{Name: entry.Name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))})
+	}
+
+	indexEntry, err := jsonEntry(bundleIndexName, index)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	compressor, err := newCompressor(compression, file)
+	if err != nil {
+		return err
+	}
+
+	archive := tar.NewWriter(compressor)
+	now := time.Now()
+
+	for _, entry := range append([]bundleEntry{indexEntry}, entries...) {
+		reader, size, err := entry.open()
+		if err != nil {
+			return err
+		}
+
+		err = archive.WriteHeader(&tar.Header{
+			Name:    entry.Name,
+			Mode:    0644,
+			Size:    size,
+			ModTime: now,
+		})
+		if err == nil {
+			_, err = io.CopyN(archive, reader, size)
+		}
+
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// nopReadCloser implements a io.ReadCloser whose Close does nothing.
+type nopReadCloser struct {
+	io.Reader
+}
+
+// Close implements the io.Closer interface.
+func (nopReadCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestWriteBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitecrawler-bundle")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	reports := filepath.Join(dir, "reports.ndjson")
+	if err := ioutil.WriteFile(reports, []byte(`{"url":"http://example.com/"}`+"\n"), 0644); err != nil {
+		tests.FailedWithError(err, "Should have successfully written reports")
+	}
+
+	manifest, err := jsonEntry("manifest.json", crawlManifest{Target: "http://example.com/", Pages: 1})
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully encoded manifest")
+	}
+
+	bundle := filepath.Join(dir, "crawl.tar.gz")
+	err = writeBundle(bundle, []bundleEntry{
+		manifest,
+		{Name: "reports.ndjson", Path: reports},
+		{Name: "crawl.log", Content: []byte("Finished: 1s.\n")},
+	})
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully written bundle")
+	}
+	tests.Passed("Should have successfully written bundle")
+
+	file, err := os.Open(bundle)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully opened bundle")
+	}
+	defer file.Close()
+
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		tests.FailedWithError(err, "Should have gzip compressed bundle")
+	}
+
+	archive := tar.NewReader(decompressed)
+	contents := map[string][]byte{}
+
+	var names []string
+	for {
+		header, err := archive.Next()
+		if err != nil {
+			break
+		}
+
+		content, _ := ioutil.ReadAll(archive)
+		contents[header.Name] = content
+		names = append(names, header.Name)
+	}
+
+	if len(names) != 4 || names[0] != bundleIndexName {
+		tests.Info("Received Entries: %+q", names)
+		tests.Failed("Should have packaged all entries after the content index")
+	}
+	tests.Passed("Should have packaged all entries after the content index")
+
+	var index []bundleIndexEntry
+	if err := json.Unmarshal(contents[bundleIndexName], &index); err != nil {
+		tests.FailedWithError(err, "Should have written content index as json")
+	}
+
+	for _, entry := range index {
+		sum := sha256.Sum256(contents[entry.Name])
+		if entry.Size != int64(len(contents[entry.Name])) || entry.SHA256 != hex.EncodeToString(sum[:]) {
+			tests.Info("Received Entry: %#v", entry)
+			tests.Failed("Should have indexed size and sha256 of %q", entry.Name)
+		}
+	}
+	tests.Passed("Should have indexed size and sha256 of all entries")
+
+	if err := writeBundle(filepath.Join(dir, "crawl.zip"), nil); err == nil {
+		tests.Failed("Should have failed to write unsupported bundle")
+	}
+	tests.Passed("Should have failed to write unsupported bundle")
+}
+
+func TestWriteBundleZstd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitecrawler-bundle")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	bundle := filepath.Join(dir, "crawl.tar.zst")
+	err = writeBundle(bundle, []bundleEntry{
+		{Name: "crawl.log", Content: []byte("Finished: 1s.\n")},
+	})
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully written bundle")
+	}
+	tests.Passed("Should have successfully written bundle")
+
+	file, err := os.Open(bundle)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully opened bundle")
+	}
+	defer file.Close()
+
+	decompressed, err := zstd.NewReader(file)
+	if err != nil {
+		tests.FailedWithError(err, "Should have zstd compressed bundle")
+	}
+	defer decompressed.Close()
+
+	archive := tar.NewReader(decompressed)
+
+	var names []string
+	for {
+		header, err := archive.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+
+	if len(names) != 2 || names[0] != bundleIndexName || names[1] != "crawl.log" {
+		tests.Info("Received Entries: %+q", names)
+		tests.Failed("Should have packaged all entries into zstd compressed archive")
+	}
+	tests.Passed("Should have packaged all entries into zstd compressed archive")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// ErrInvalidCanonicalHost is returned when parsing a canonical host which is
+// neither a host nor a url of one.
+var ErrInvalidCanonicalHost = errors.New("invalid canonical host, expected a host such as www.monzo.com or a url such as https://www.monzo.com")
+
+// canonicalHost implements the rewriting of urls of the variants of a site's
+// host to it's canonical host, so outputs such as sitemaps match the site's
+// canonical configuration whichever variant was fetched. Variants are the
+// canonical host with or without a www. prefix. The scheme of urls is only
+// rewritten if the canonical host is given as a url. A nil canonicalHost
+// leaves everything as is.
+type canonicalHost struct {
+	scheme string
+	host   string
+	bare   string
+}
+
+// parseCanonicalHost returns the canonicalHost of giving host, such as
+// www.monzo.com, or url, such as https://www.monzo.com. It returns nil if no
+// host is provided.
+func parseCanonicalHost(value string) (*canonicalHost, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var canonical canonicalHost
+	if strings.Contains(value, "://") {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") || strings.Trim(parsed.Path, "/") != "" {
+			return nil, fmt.Errorf("%+s: %+q", ErrInvalidCanonicalHost, value)
+		}
+
+		canonical.scheme = parsed.Scheme
+		value = parsed.Host
+	}
+
+	if strings.ContainsAny(value, "/?#@ ") {
+		return nil, fmt.Errorf("%+s: %+q", ErrInvalidCanonicalHost, value)
+	}
+
+	canonical.host = strings.ToLower(value)
+	canonical.bare = bareHost(canonical.host)
+	return &canonical, nil
+}
+
+// URL returns a copy of giving url on the canonical host and scheme if it's
+// host is a variant of the canonical host, else the url as is.
+func (c *canonicalHost) URL(target *url.URL) *url.URL {
+	if c == nil || target == nil || bareHost(strings.ToLower(target.Host)) != c.bare {
+		return target
+	}
+
+	canonical := *target
+	canonical.Host = c.host
+	if c.scheme != "" {
+		canonical.Scheme = c.scheme
+	}
+	return &canonical
+}
+
+// Report returns a copy of giving report with it's urls and those of the links
+// it points to on the canonical host. Redirect hops are kept as fetched.
+func (c *canonicalHost) Report(report crawler.LinkReport) crawler.LinkReport {
+	if c == nil {
+		return report
+	}
+
+	report.Path = c.URL(report.Path)
+	report.RedirectedTo = c.URL(report.RedirectedTo)
+	report.Canonical = c.URL(report.Canonical)
+
+	if report.Alternates != nil {
+		alternates := make([]crawler.Alternate, len(report.Alternates))
+		for index, alternate := range report.Alternates {
+			alternate.URL = c.URL(alternate.URL)
+			alternates[index] = alternate
+		}
+		report.Alternates = alternates
+	}
+
+	if report.PointsTo != nil {
+		kids := make([]crawler.LinkReport, len(report.PointsTo))
+		for index, kid := range report.PointsTo {
+			kids[index] = c.Report(kid)
+		}
+		report.PointsTo = kids
+	}
+
+	return report
+}
+
+// bareHost returns giving host without it's www. prefix.
+func bareHost(host string) string {
+	return strings.TrimPrefix(host, "www.")
+}
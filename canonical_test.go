@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestCanonicalHost(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+		return link
+	}
+
+	canonical, err := parseCanonicalHost("https://www.example.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed canonical host")
+	}
+	tests.Passed("Should have successfully parsed canonical host")
+
+	report := canonical.Report(crawler.LinkReport{
+		Path: parse("http://example.com/"),
+		PointsTo: []crawler.LinkReport{
+			{Path: parse("http://WWW.example.com/about?lang=en")},
+			{Path: parse("http://example.com/old"), RedirectedTo: parse("https://www.example.com/new")},
+			{Path: parse("https://blog.example.com/")},
+			{Path: parse("https://elsewhere.example/")},
+		},
+	})
+
+	received := []string{report.Path.String()}
+	for _, kid := range report.PointsTo {
+		received = append(received, kid.Path.String())
+	}
+	received = append(received, report.PointsTo[1].RedirectedTo.String())
+
+	expected := []string{
+		"https://www.example.com/",
+		"https://www.example.com/about?lang=en",
+		"https://www.example.com/old",
+		"https://blog.example.com/",
+		"https://elsewhere.example/",
+		"https://www.example.com/new",
+	}
+
+	if strings.Join(received, " ") != strings.Join(expected, " ") {
+		tests.Info("Expected: %+q", expected)
+		tests.Info("Received: %+q", received)
+		tests.Failed("Should have rewritten urls of host variants to canonical host")
+	}
+	tests.Passed("Should have rewritten urls of host variants to canonical host")
+
+	bare, err := parseCanonicalHost("example.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed canonical host")
+	}
+
+	if link := bare.URL(parse("http://www.example.com/about")); link.String() != "http://example.com/about" {
+		tests.Info("Received: %q", link.String())
+		tests.Failed("Should have kept scheme of url for canonical host without scheme")
+	}
+	tests.Passed("Should have kept scheme of url for canonical host without scheme")
+
+	if none, err := parseCanonicalHost(""); none != nil || err != nil || none.URL(parse("http://example.com/")).Host != "example.com" {
+		tests.Failed("Should have left urls as is without canonical host")
+	}
+	tests.Passed("Should have left urls as is without canonical host")
+
+	for _, invalid := range []string{"ftp://example.com", "https://example.com/blog", "example.com/blog", "https://"} {
+		if _, err := parseCanonicalHost(invalid); err == nil || !strings.Contains(err.Error(), ErrInvalidCanonicalHost.Error()) {
+			tests.Info("Canonical Host: %q", invalid)
+			tests.Failed("Should have failed to parse invalid canonical host")
+		}
+	}
+	tests.Passed("Should have failed to parse invalid canonical host")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// contactAudit embodies the mailto: and tel: addresses linked to by the pages
+// of a crawl, with the total pages linking to each, for contact audits.
+type contactAudit struct {
+	pages map[string]int
+}
+
+// contactCount embodies an address of a contactAudit and the total pages
+// linking to it.
+type contactCount struct {
+	Address string
+	Pages   int
+}
+
+// newContactAudit returns a new empty contactAudit.
+func newContactAudit() *contactAudit {
+	return &contactAudit{pages: map[string]int{}}
+}
+
+// Observe records the addresses giving page report links to.
+func (c *contactAudit) Observe(report crawler.LinkReport) {
+	for _, address := range report.Contacts {
+		c.pages[address]++
+	}
+}
+
+// Addresses returns all addresses recorded sorted by address.
+func (c *contactAudit) Addresses() []contactCount {
+	counts := make([]contactCount, 0, len(c.pages))
+	for address, pages := range c.pages {
+		counts = append(counts, contactCount{Address: address, Pages: pages})
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Address < counts[j].Address
+	})
+	return counts
+}
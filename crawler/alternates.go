@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Alternate embodies a localized version of a page declared by it through a
+// <link rel="alternate" hreflang> element, with the language code it's
+// declared for, such as en-GB or x-default.
+type Alternate struct {
+	Hreflang string   `json:"hreflang"`
+	URL      *url.URL `json:"url"`
+}
+
+// CachedAlternate embodies an Alternate of a cached page.
+type CachedAlternate struct {
+	Hreflang string `json:"hreflang"`
+	URL      string `json:"url"`
+}
+
+// alternateLink returns the Alternate declared by giving attributes of a
+// <link rel="alternate"> element, resolved against the baseURL. It returns
+// false if the element declares no hreflang or no valid href.
+func alternateLink(attrs []html.Attribute, baseURL *url.URL) (Alternate, bool) {
+	hreflang, ok := getAttr(attrs, "hreflang")
+	if !ok || strings.TrimSpace(hreflang.Val) == "" {
+		return Alternate{}, false
+	}
+
+	href, ok := getAttr(attrs, "href")
+	if !ok {
+		return Alternate{}, false
+	}
+
+	link, err := parsePath(strings.TrimSpace(href.Val), baseURL)
+	if err != nil {
+		return Alternate{}, false
+	}
+	return Alternate{Hreflang: strings.TrimSpace(hreflang.Val), URL: link}, true
+}
+
+// reportAlternates returns giving alternates with their urls as reported for
+// the links of pages, normalized and stripped of the parameters the crawl
+// strips, so they can be matched against the page's outlinks.
+func (pc PageCrawler) reportAlternates(alternates []Alternate) []Alternate {
+	if len(alternates) == 0 {
+		return nil
+	}
+
+	reported := make([]Alternate, 0, len(alternates))
+	for _, alternate := range alternates {
+		alternate.URL, _ = pc.stripQuery(Normalize(alternate.URL))
+		reported = append(reported, alternate)
+	}
+	return reported
+}
+
+// cacheAlternates returns the CachedAlternates of giving alternates.
+func cacheAlternates(alternates []Alternate) []CachedAlternate {
+	if len(alternates) == 0 {
+		return nil
+	}
+
+	cached := make([]CachedAlternate, 0, len(alternates))
+	for _, alternate := range alternates {
+		cached = append(cached, CachedAlternate{Hreflang: alternate.Hreflang, URL: alternate.URL.String()})
+	}
+	return cached
+}
+
+// restoreAlternates returns the alternates of giving CachedAlternates,
+// skipping those whose url fails to parse.
+func restoreAlternates(cached []CachedAlternate) []Alternate {
+	var alternates []Alternate
+	for _, alternate := range cached {
+		if parsed, err := url.Parse(alternate.URL); err == nil {
+			alternates = append(alternates, Alternate{Hreflang: alternate.Hreflang, URL: parsed})
+		}
+	}
+	return alternates
+}
@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidASNDatabase is returned when parsing a malformed line of an asn
+// database.
+var ErrInvalidASNDatabase = errors.New("invalid asn database line, expected range start, range end, as number, country and description separated by tabs")
+
+// asnRange embodies a range of ip addresses announced by an autonomous system.
+type asnRange struct {
+	start net.IP
+	end   net.IP
+	asn   int
+	org   string
+}
+
+// ASNs implements an offline lookup of the autonomous systems announcing ip
+// addresses, such as the CDN or cloud provider serving a url, loaded from the
+// tab separated ip2asn database of iptoasn.com.
+type ASNs struct {
+	ranges []asnRange
+}
+
+// ParseASNs parses giving ip2asn database, where each line holds the first and
+// last ip address of a range followed by the number, country and description
+// of the autonomous system announcing it e.g
+// `1.1.1.0	1.1.1.255	13335	US	CLOUDFLARENET`. Ranges of as number 0 are
+// not routed and skipped.
+func ParseASNs(content io.Reader) (*ASNs, error) {
+	var asns ASNs
+
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 5)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%+s: %+q", ErrInvalidASNDatabase, line)
+		}
+
+		start, end := net.ParseIP(fields[0]), net.ParseIP(fields[1])
+		asn, err := strconv.Atoi(fields[2])
+		if start == nil || end == nil || err != nil {
+			return nil, fmt.Errorf("%+s: %+q", ErrInvalidASNDatabase, line)
+		}
+
+		if asn == 0 {
+			continue
+		}
+
+		var org string
+		if len(fields) == 5 {
+			org = strings.TrimSpace(fields[4])
+		}
+
+		asns.ranges = append(asns.ranges, asnRange{start: start.To16(), end: end.To16(), asn: asn, org: org})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(asns.ranges, func(i, j int) bool {
+		return bytes.Compare(asns.ranges[i].start, asns.ranges[j].start) < 0
+	})
+
+	return &asns, nil
+}
+
+// Lookup returns the number and description of the autonomous system
+// announcing giving ip address, else 0 if none is known.
+func (a *ASNs) Lookup(ip string) (int, string) {
+	if a == nil {
+		return 0, ""
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return 0, ""
+	}
+	addr = addr.To16()
+
+	// Find the last range starting at or before the address.
+	index := sort.Search(len(a.ranges), func(i int) bool {
+		return bytes.Compare(a.ranges[i].start, addr) > 0
+	}) - 1
+
+	if index < 0 || bytes.Compare(addr, a.ranges[index].end) > 0 {
+		return 0, ""
+	}
+	return a.ranges[index].asn, a.ranges[index].org
+}
+
+// Annotate sets the autonomous system of the remote ip address of giving
+// report and all it's outgoing links.
+func (a *ASNs) Annotate(report *LinkReport) {
+	if a == nil {
+		return
+	}
+
+	report.ASN, report.ASOrg = a.Lookup(report.RemoteIP)
+	for index := range report.PointsTo {
+		kid := &report.PointsTo[index]
+		kid.ASN, kid.ASOrg = a.Lookup(kid.RemoteIP)
+	}
+}
@@ -0,0 +1,52 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestASNs(t *testing.T) {
+	asns, err := ParseASNs(strings.NewReader(strings.Join([]string{
+		"# range_start\trange_end\tAS_number\tcountry_code\tAS_description",
+		"1.1.1.0\t1.1.1.255\t13335\tUS\tCLOUDFLARENET",
+		"10.0.0.0\t10.255.255.255\t0\tNone\tNot routed",
+		"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE",
+		"2606:4700::\t2606:4700:ffff:ffff:ffff:ffff:ffff:ffff\t13335\tUS\tCLOUDFLARENET",
+	}, "\n")))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed asn database")
+	}
+	tests.Passed("Should have successfully parsed asn database")
+
+	for ip, expected := range map[string]int{
+		"1.1.1.1":         13335,
+		"8.8.8.255":       15169,
+		"2606:4700::6810": 13335,
+		"10.0.0.1":        0,
+		"9.9.9.9":         0,
+		"":                0,
+	} {
+		if asn, _ := asns.Lookup(ip); asn != expected {
+			tests.Info("IP: %q, Expected: %d, Received: %d", ip, expected, asn)
+			tests.Failed("Should have found autonomous system announcing ip")
+		}
+	}
+	tests.Passed("Should have found autonomous system announcing ip")
+
+	link, _ := url.Parse("https://mombo.com")
+	report := LinkReport{Path: link, RemoteIP: "8.8.8.8", PointsTo: []LinkReport{{Path: link, RemoteIP: "1.1.1.1"}}}
+	asns.Annotate(&report)
+	if report.ASN != 15169 || report.ASOrg != "GOOGLE" || report.PointsTo[0].ASN != 13335 {
+		tests.Info("Received: %d %q, %d", report.ASN, report.ASOrg, report.PointsTo[0].ASN)
+		tests.Failed("Should have annotated report and it's links with their autonomous systems")
+	}
+	tests.Passed("Should have annotated report and it's links with their autonomous systems")
+
+	if _, err := ParseASNs(strings.NewReader("1.1.1.0\tnot-an-ip\t13335\tUS\tCLOUDFLARENET")); err == nil {
+		tests.Failed("Should have failed to parse malformed asn database")
+	}
+	tests.Passed("Should have failed to parse malformed asn database")
+}
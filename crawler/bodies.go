@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+)
+
+// maxDrainSize sets the most bytes of a response body read before closing it,
+// so it's connection is reused. Bodies with more left unread are closed as
+// is, as reading them costs more than a new connection.
+const maxDrainSize = 256 * 1024
+
+// closeBody drains what's left of giving response body, up to maxDrainSize,
+// and closes it, letting the transport reuse it's connection.
+func closeBody(body io.ReadCloser) {
+	io.CopyN(ioutil.Discard, body, maxDrainSize)
+	body.Close()
+}
+
+// drainBody reads giving body up to limit bytes without closing it, for bodies
+// which must be transferred whole, such as those of responses to be cached.
+func drainBody(body io.Reader, limit int64) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, limit))
+}
+
+// BodyStats implements a concurrent-safe tracker of the response bodies
+// received by a transport, counting those closed and those closed before they
+// were fully read, for spotting leaked bodies which hold connections and file
+// descriptors open.
+type BodyStats struct {
+	opened    int64
+	closed    int64
+	abandoned int64
+}
+
+// NewBodyStats returns a new instance of a BodyStats.
+func NewBodyStats() *BodyStats {
+	return &BodyStats{}
+}
+
+// Opened returns total response bodies received.
+func (b *BodyStats) Opened() int64 {
+	return atomic.LoadInt64(&b.opened)
+}
+
+// Closed returns total response bodies closed.
+func (b *BodyStats) Closed() int64 {
+	return atomic.LoadInt64(&b.closed)
+}
+
+// Abandoned returns total response bodies closed before they were fully read,
+// whose connections can't be reused.
+func (b *BodyStats) Abandoned() int64 {
+	return atomic.LoadInt64(&b.abandoned)
+}
+
+// Leaked returns total response bodies received but not closed yet, which
+// once requests are done are leaks.
+func (b *BodyStats) Leaked() int64 {
+	return b.Opened() - b.Closed()
+}
+
+// bodyStatsTransport implements a http.RoundTripper tracking the response
+// bodies it returns into a BodyStats.
+type bodyStatsTransport struct {
+	transport http.RoundTripper
+	stats     *BodyStats
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (b *bodyStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := b.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt64(&b.stats.opened, 1)
+	res.Body = &trackedBody{ReadCloser: res.Body, stats: b.stats}
+	return res, nil
+}
+
+// CloseIdleConnections closes the idle connections of the underlying transport,
+// if it supports it.
+func (b *bodyStatsTransport) CloseIdleConnections() {
+	closeIdleConnections(b.transport)
+}
+
+// trackedBody implements a response body recording it's close into a
+// BodyStats, noting whether it was fully read.
+type trackedBody struct {
+	io.ReadCloser
+	stats  *BodyStats
+	eof    int32
+	closed int32
+}
+
+// Read implements the io.Reader interface.
+func (t *trackedBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if err == io.EOF {
+		atomic.StoreInt32(&t.eof, 1)
+	}
+	return n, err
+}
+
+// Close implements the io.Closer interface, recording only the first close.
+func (t *trackedBody) Close() error {
+	if atomic.CompareAndSwapInt32(&t.closed, 0, 1) {
+		atomic.AddInt64(&t.stats.closed, 1)
+		if atomic.LoadInt32(&t.eof) == 0 {
+			atomic.AddInt64(&t.stats.abandoned, 1)
+		}
+	}
+	return t.ReadCloser.Close()
+}
+
+// closeIdleConnections closes the idle connections of giving transport, if it
+// supports it.
+func closeIdleConnections(transport http.RoundTripper) {
+	type idleCloser interface {
+		CloseIdleConnections()
+	}
+
+	if closer, ok := transport.(idleCloser); ok {
+		closer.CloseIdleConnections()
+	}
+}
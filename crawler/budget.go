@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrBudgetExhausted is returned for requests beyond the request budget of a crawl.
+var ErrBudgetExhausted = errors.New("crawl budget exhausted, request not sent")
+
+// Budget implements a concurrent-safe budget of pages crawled and requests
+// sent, shared by all pages of a crawl so it can be bounded by count rather
+// than only depth. A zero maximum leaves it's count unlimited.
+type Budget struct {
+	ml          sync.Mutex
+	maxPages    int
+	maxRequests int
+	pages       int
+	requests    int
+	exhausted   bool
+}
+
+// NewBudget returns a new instance of a Budget allowing the provided maximum
+// pages and requests.
+func NewBudget(maxPages int, maxRequests int) *Budget {
+	return &Budget{
+		maxPages:    maxPages,
+		maxRequests: maxRequests,
+	}
+}
+
+// Page reserves a page to be crawled, returning false if the budget is
+// exhausted.
+func (b *Budget) Page() bool {
+	if b == nil {
+		return true
+	}
+
+	b.ml.Lock()
+	defer b.ml.Unlock()
+
+	if b.exhausted || (b.maxPages > 0 && b.pages >= b.maxPages) {
+		b.exhausted = true
+		return false
+	}
+
+	b.pages++
+	return true
+}
+
+// Request reserves a request to be sent, returning false if the budget is
+// exhausted. Redirects followed by a request are not counted separately.
+func (b *Budget) Request() bool {
+	if b == nil {
+		return true
+	}
+
+	b.ml.Lock()
+	defer b.ml.Unlock()
+
+	if b.maxRequests > 0 && b.requests >= b.maxRequests {
+		b.exhausted = true
+		return false
+	}
+
+	b.requests++
+	return true
+}
+
+// Exhausted returns true if the crawl was stopped short by the budget.
+func (b *Budget) Exhausted() bool {
+	if b == nil {
+		return false
+	}
+
+	b.ml.Lock()
+	defer b.ml.Unlock()
+	return b.exhausted
+}
+
+// Pages returns total pages crawled within the budget.
+func (b *Budget) Pages() int {
+	b.ml.Lock()
+	defer b.ml.Unlock()
+	return b.pages
+}
+
+// Requests returns total requests sent within the budget.
+func (b *Budget) Requests() int {
+	b.ml.Lock()
+	defer b.ml.Unlock()
+	return b.requests
+}
@@ -0,0 +1,278 @@
+package crawler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// CachedLink embodies a link farmed from a cached page.
+type CachedLink struct {
+	URL      string       `json:"url"`
+	Position LinkPosition `json:"position,omitempty"`
+	Rel      []string     `json:"rel,omitempty"`
+	Text     string       `json:"text,omitempty"`
+	Heading  string       `json:"heading,omitempty"`
+	Origin   string       `json:"origin,omitempty"`
+}
+
+// CacheEntry embodies the validators and farmed content of a page from a
+// previous crawl, used to issue conditional requests and to restore the
+// page when the host responds with a 304.
+type CacheEntry struct {
+	ETag          string            `json:"etag,omitempty"`
+	LastModified  string            `json:"last_modified,omitempty"`
+	ContentType   string            `json:"content_type,omitempty"`
+	ContentLength int64             `json:"content_length"`
+	Title         string            `json:"title,omitempty"`
+	Language      string            `json:"language,omitempty"`
+	Direction     string            `json:"direction,omitempty"`
+	Canonical     string            `json:"canonical,omitempty"`
+	Alternates    []CachedAlternate `json:"alternates,omitempty"`
+	Social        *SocialTags       `json:"social,omitempty"`
+	Structured    *StructuredData   `json:"structured_data,omitempty"`
+	Robots        []string          `json:"robots,omitempty"`
+	Contacts      []string          `json:"contacts,omitempty"`
+	ParseWarnings []string          `json:"parse_warnings,omitempty"`
+	Links         []CachedLink      `json:"links"`
+	JSONLinks     []CachedLink      `json:"json_links,omitempty"`
+}
+
+// ValidatorCache implements a concurrent-safe cache of page validators which
+// persists across crawls, so repeat crawls issue conditional requests which
+// are mostly answered with a 304 instead of the page's content.
+type ValidatorCache struct {
+	ml      sync.Mutex
+	entries map[string]CacheEntry
+	hits    int64
+}
+
+// NewValidatorCache returns a new instance of an empty ValidatorCache.
+func NewValidatorCache() *ValidatorCache {
+	return &ValidatorCache{
+		entries: map[string]CacheEntry{},
+	}
+}
+
+// LoadValidatorCache returns a new instance of a ValidatorCache holding the
+// entries of giving content, as written by ValidatorCache.Save.
+func LoadValidatorCache(content io.Reader) (*ValidatorCache, error) {
+	cache := NewValidatorCache()
+	if err := json.NewDecoder(content).Decode(&cache.entries); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Save writes all entries of the cache into giving writer.
+func (c *ValidatorCache) Save(w io.Writer) error {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	return json.NewEncoder(w).Encode(c.entries)
+}
+
+// LoadValidatorCacheStore returns a new instance of a ValidatorCache holding
+// the entries of the cache bucket of giving store, as written by
+// ValidatorCache.SaveStore.
+func LoadValidatorCacheStore(store Store) (*ValidatorCache, error) {
+	cache := NewValidatorCache()
+	err := store.Each(StoreCache, func(target string, value []byte) error {
+		var entry CacheEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return err
+		}
+
+		cache.entries[target] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// SaveStore writes all entries of the cache into the cache bucket of giving
+// store, deleting those stored which are no longer cached.
+func (c *ValidatorCache) SaveStore(store Store) error {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	err := store.Each(StoreCache, func(target string, _ []byte) error {
+		if _, ok := c.entries[target]; ok {
+			return nil
+		}
+		return store.Delete(StoreCache, target)
+	})
+	if err != nil {
+		return err
+	}
+
+	for target, entry := range c.entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		if err := store.Put(StoreCache, target, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the entry of giving url if cached.
+func (c *ValidatorCache) Get(target string) (CacheEntry, bool) {
+	if c == nil {
+		return CacheEntry{}, false
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	entry, ok := c.entries[target]
+	return entry, ok
+}
+
+// Set caches giving entry for provided url, entries without any validator
+// are dropped as they can't be revalidated.
+func (c *ValidatorCache) Set(target string, entry CacheEntry) {
+	if c == nil {
+		return
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	if entry.ETag == "" && entry.LastModified == "" {
+		delete(c.entries, target)
+		return
+	}
+
+	c.entries[target] = entry
+}
+
+// Condition sets the conditional headers of giving request from the
+// validators cached for it's url.
+func (c *ValidatorCache) Condition(req *http.Request) {
+	entry, ok := c.Get(req.URL.String())
+	if !ok {
+		return
+	}
+
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// Hits returns total pages restored from the cache.
+func (c *ValidatorCache) Hits() int64 {
+	return atomic.LoadInt64(&c.hits)
+}
+
+// restore returns the page cached for giving url, counting it as a hit.
+func (c *ValidatorCache) restore(target string) (CacheEntry, pageDocument, bool) {
+	entry, ok := c.Get(target)
+	if !ok {
+		return entry, pageDocument{}, false
+	}
+
+	atomic.AddInt64(&c.hits, 1)
+
+	page := pageDocument{
+		Title:     entry.Title,
+		Language:  entry.Language,
+		Direction: entry.Direction,
+		Robots:    entry.Robots,
+		Contacts:  entry.Contacts,
+		Warnings:  entry.ParseWarnings,
+		Links:     restoreLinks(entry.Links),
+		JSONLinks: restoreLinks(entry.JSONLinks),
+	}
+
+	if entry.Canonical != "" {
+		page.Canonical, _ = url.Parse(entry.Canonical)
+	}
+
+	page.Alternates = restoreAlternates(entry.Alternates)
+
+	if entry.Social != nil {
+		page.Social = *entry.Social
+	}
+
+	if entry.Structured != nil {
+		page.Structured = *entry.Structured
+	}
+
+	return entry, page, true
+}
+
+// newCacheEntry returns the CacheEntry of giving response and the page
+// farmed from it's content.
+func newCacheEntry(res *http.Response, page pageDocument, contentLength int64) CacheEntry {
+	entry := CacheEntry{
+		ETag:          res.Header.Get("ETag"),
+		LastModified:  res.Header.Get("Last-Modified"),
+		ContentType:   res.Header.Get("Content-Type"),
+		ContentLength: contentLength,
+		Title:         page.Title,
+		Language:      page.Language,
+		Direction:     page.Direction,
+		Robots:        page.Robots,
+		Contacts:      page.Contacts,
+		ParseWarnings: page.Warnings,
+		Links:         cacheLinks(page.Links),
+	}
+
+	if page.Canonical != nil {
+		entry.Canonical = page.Canonical.String()
+	}
+
+	entry.Alternates = cacheAlternates(page.Alternates)
+
+	if !page.Social.empty() {
+		social := page.Social
+		entry.Social = &social
+	}
+
+	if !page.Structured.empty() {
+		structured := page.Structured
+		entry.Structured = &structured
+	}
+
+	if len(page.JSONLinks) != 0 {
+		entry.JSONLinks = cacheLinks(page.JSONLinks)
+	}
+
+	return entry
+}
+
+// cacheLinks returns the CachedLinks of giving links in the order they were
+// found.
+func cacheLinks(links map[*url.URL]linkContext) []CachedLink {
+	cached := make([]CachedLink, 0, len(links))
+	for _, link := range orderedLinks(links) {
+		linkCtx := links[link]
+		cached = append(cached, CachedLink{URL: link.String(), Position: linkCtx.Position, Rel: linkCtx.Rel, Text: linkCtx.Text, Heading: linkCtx.Heading, Origin: linkCtx.Origin})
+	}
+	return cached
+}
+
+// restoreLinks returns the links of giving CachedLinks, skipping those whose
+// url fails to parse.
+func restoreLinks(cached []CachedLink) map[*url.URL]linkContext {
+	links := make(map[*url.URL]linkContext, len(cached))
+	for _, link := range cached {
+		if parsed, err := url.Parse(link.URL); err == nil {
+			addLink(links, parsed, linkContext{Position: link.Position, Rel: link.Rel, Text: link.Text, Heading: link.Heading, Origin: link.Origin})
+		}
+	}
+	return links
+}
@@ -0,0 +1,186 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrBrowserClosed is returned when the browser closes it's devtools pipe
+// before answering a command.
+var ErrBrowserClosed = errors.New("browser closed devtools pipe")
+
+// cdpCommand embodies a command sent to a browser over the Chrome DevTools
+// Protocol, addressed to the page attached as SessionID, if any.
+type cdpCommand struct {
+	ID        int         `json:"id"`
+	SessionID string      `json:"sessionId,omitempty"`
+	Method    string      `json:"method"`
+	Params    interface{} `json:"params,omitempty"`
+}
+
+// cdpMessage embodies a message received from a browser over the Chrome
+// DevTools Protocol, being either the answer of the command of ID or an event
+// of Method.
+type cdpMessage struct {
+	ID        int             `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// cdpListener embodies the key of the events of Method sent by the page
+// attached as SessionID, or the browser if empty, a cdpPipe delivers to a
+// listener.
+type cdpListener struct {
+	SessionID string
+	Method    string
+}
+
+// cdpPipe implements a client of the Chrome DevTools Protocol spoken over the
+// pipes a browser run with --remote-debugging-pipe reads from and writes into,
+// messages being json delimited by null bytes. Pages attached with flattened
+// sessions share the pipe, their events being told apart by session.
+type cdpPipe struct {
+	wl        sync.Mutex
+	w         io.Writer
+	ml        sync.Mutex
+	lastID    int
+	pending   map[int]chan cdpMessage
+	listeners map[cdpListener]chan cdpMessage
+	closed    chan struct{}
+}
+
+// newCDPPipe returns a new cdpPipe writing commands into w and reading their
+// answers and events from r till it fails.
+func newCDPPipe(w io.Writer, r io.Reader) *cdpPipe {
+	pipe := &cdpPipe{
+		w:         w,
+		pending:   map[int]chan cdpMessage{},
+		listeners: map[cdpListener]chan cdpMessage{},
+		closed:    make(chan struct{}),
+	}
+
+	go pipe.read(r)
+	return pipe
+}
+
+// Listen returns a channel receiving up to giving total events of method sent
+// by the page attached as session, or the browser if empty, later events being
+// dropped. It must be called before the command triggering the events is sent.
+func (p *cdpPipe) Listen(session string, method string, total int) <-chan cdpMessage {
+	events := make(chan cdpMessage, total)
+
+	p.ml.Lock()
+	p.listeners[cdpListener{SessionID: session, Method: method}] = events
+	p.ml.Unlock()
+
+	return events
+}
+
+// Release drops all listeners of the page attached as giving session, once
+// it's events are no longer awaited.
+func (p *cdpPipe) Release(session string) {
+	p.ml.Lock()
+	defer p.ml.Unlock()
+
+	for listener := range p.listeners {
+		if listener.SessionID == session {
+			delete(p.listeners, listener)
+		}
+	}
+}
+
+// Closed returns a channel closed once the browser closes it's pipe.
+func (p *cdpPipe) Closed() <-chan struct{} {
+	return p.closed
+}
+
+// Call sends the command of method with giving params to the page attached as
+// session, or the browser if empty, decoding it's result into result if not
+// nil.
+func (p *cdpPipe) Call(ctx context.Context, session string, method string, params interface{}, result interface{}) error {
+	answer := make(chan cdpMessage, 1)
+
+	p.ml.Lock()
+	p.lastID++
+	id := p.lastID
+	p.pending[id] = answer
+	p.ml.Unlock()
+
+	defer func() {
+		p.ml.Lock()
+		delete(p.pending, id)
+		p.ml.Unlock()
+	}()
+
+	data, err := json.Marshal(cdpCommand{ID: id, SessionID: session, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	p.wl.Lock()
+	_, err = p.w.Write(append(data, 0))
+	p.wl.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.closed:
+		return fmt.Errorf("%+s: %s", ErrBrowserClosed, method)
+	case message := <-answer:
+		if message.Error != nil {
+			return fmt.Errorf("%s: %s", method, message.Error.Message)
+		}
+
+		if result == nil || len(message.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(message.Result, result)
+	}
+}
+
+// read delivers the messages read from r to the commands awaiting them and the
+// listeners of their events, closing the pipe once r fails.
+func (p *cdpPipe) read(r io.Reader) {
+	defer close(p.closed)
+
+	reader := bufio.NewReader(r)
+	for {
+		data, err := reader.ReadBytes(0)
+		if err != nil {
+			return
+		}
+
+		var message cdpMessage
+		if err := json.Unmarshal(data[:len(data)-1], &message); err != nil {
+			continue
+		}
+
+		p.ml.Lock()
+		if message.Method == "" {
+			if answer, ok := p.pending[message.ID]; ok {
+				answer <- message
+			}
+		} else if events, ok := p.listeners[cdpListener{SessionID: message.SessionID, Method: message.Method}]; ok {
+			select {
+			case events <- message:
+			default:
+			}
+		}
+		p.ml.Unlock()
+	}
+}
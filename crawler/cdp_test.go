@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestCDPPipe(t *testing.T) {
+	commandsIn, commands := io.Pipe()
+	answers, answersOut := io.Pipe()
+
+	pipe := newCDPPipe(commands, answers)
+
+	// The browser answers commands once both sessions have sent theirs, in
+	// reverse order, each answer preceded by an event of it's session.
+	received := make(chan []cdpCommand, 1)
+	release := make(chan struct{})
+	go func() {
+		reader := bufio.NewReader(commandsIn)
+
+		var batch []cdpCommand
+		for len(batch) < 3 {
+			data, err := reader.ReadBytes(0)
+			if err != nil {
+				return
+			}
+
+			var command cdpCommand
+			json.Unmarshal(data[:len(data)-1], &command)
+			batch = append(batch, command)
+		}
+		received <- batch
+
+		send := func(message interface{}) {
+			data, _ := json.Marshal(message)
+			answersOut.Write(append(data, 0))
+		}
+
+		for index := len(batch) - 1; index >= 0; index-- {
+			command := batch[index]
+			if command.Method == "Runtime.fail" {
+				send(map[string]interface{}{"id": command.ID, "error": map[string]interface{}{"code": -32000, "message": "failed"}})
+				continue
+			}
+
+			send(map[string]interface{}{"sessionId": command.SessionID, "method": "Page.loadEventFired", "params": map[string]string{"session": command.SessionID}})
+			send(map[string]interface{}{"id": command.ID, "result": map[string]string{"session": command.SessionID}})
+		}
+
+		// Events of released sessions are dropped, and the pipe closed while
+		// commands are still read.
+		<-release
+		send(map[string]interface{}{"sessionId": "a", "method": "Page.loadEventFired", "params": map[string]string{"session": "released"}})
+		answersOut.Close()
+		io.Copy(ioutil.Discard, reader)
+	}()
+
+	events := map[string]<-chan cdpMessage{
+		"a": pipe.Listen("a", "Page.loadEventFired", 2),
+		"b": pipe.Listen("b", "Page.loadEventFired", 2),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	results := make(chan string, 2)
+	failed := make(chan error, 1)
+	for _, session := range []string{"a", "b"} {
+		go func(session string) {
+			var result struct {
+				Session string `json:"session"`
+			}
+			if err := pipe.Call(ctx, session, "Page.navigate", map[string]string{"url": "about:blank"}, &result); err != nil {
+				results <- err.Error()
+				return
+			}
+			results <- session + "=" + result.Session
+		}(session)
+	}
+
+	go func() {
+		// The failing command is sent once both sessions have sent theirs.
+		time.Sleep(50 * time.Millisecond)
+		failed <- pipe.Call(ctx, "", "Runtime.fail", nil, nil)
+	}()
+
+	answered := []string{<-results, <-results}
+	if !(answered[0] == "a=a" && answered[1] == "b=b") && !(answered[0] == "b=b" && answered[1] == "a=a") {
+		tests.Info("Received: %q", answered)
+		tests.Failed("Should have delivered answers to the commands of their id")
+	}
+	tests.Passed("Should have delivered answers to the commands of their id")
+
+	if err := <-failed; err == nil || err.Error() != "Runtime.fail: failed" {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed command answered with an error")
+	}
+	tests.Passed("Should have failed command answered with an error")
+
+	if batch := <-received; batch[0].Method != "Page.navigate" || batch[0].ID == batch[1].ID {
+		tests.Info("Received: %#v", batch)
+		tests.Failed("Should have sent commands with distinct ids")
+	}
+	tests.Passed("Should have sent commands with distinct ids")
+
+	pipe.Release("a")
+	close(release)
+
+	select {
+	case <-pipe.Closed():
+	case <-time.After(time.Second):
+		tests.Failed("Should have closed pipe once the browser closed it's end")
+	}
+	tests.Passed("Should have closed pipe once the browser closed it's end")
+
+	for session, listener := range events {
+		event := <-listener
+		if !strings.Contains(string(event.Params), `"session":"`+session+`"`) || event.SessionID != session {
+			tests.Info("Session: %s, Received: %s", session, event.Params)
+			tests.Failed("Should have delivered events to the listeners of their session")
+		}
+
+		select {
+		case event := <-listener:
+			tests.Info("Session: %s, Received: %s", session, event.Params)
+			tests.Failed("Should have delivered events to the listeners of their session")
+		default:
+		}
+	}
+	tests.Passed("Should have delivered events to the listeners of their session")
+
+	if err := pipe.Call(ctx, "", "Target.createTarget", nil, nil); err == nil || !strings.Contains(err.Error(), ErrBrowserClosed.Error()) {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed command sent once browser closed it's pipe")
+	}
+	tests.Passed("Should have failed command sent once browser closed it's pipe")
+}
@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HostCertificate embodies the TLS details of connections to a giving host and
+// the certificate it serves.
+type HostCertificate struct {
+	Host        string    `json:"host"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"not_after"`
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipher_suite"`
+	WeakVersion bool      `json:"weak_version,omitempty"`
+	WeakCipher  bool      `json:"weak_cipher,omitempty"`
+}
+
+// ExpiresWithin returns true if the host's certificate expires within giving
+// duration from now, including certificates already expired.
+func (h HostCertificate) ExpiresWithin(within time.Duration, now time.Time) bool {
+	return h.NotAfter.Before(now.Add(within))
+}
+
+// CertificateStats implements a concurrent-safe tracker of the TLS details of
+// hosts connected to by a crawl, for flagging certificates about to expire and
+// weak protocol versions or cipher suites.
+type CertificateStats struct {
+	ml    sync.Mutex
+	hosts map[string]HostCertificate
+}
+
+// NewCertificateStats returns a new instance of a CertificateStats.
+func NewCertificateStats() *CertificateStats {
+	return &CertificateStats{
+		hosts: map[string]HostCertificate{},
+	}
+}
+
+// Record adds the TLS details of a connection to giving host. Hosts serving
+// different certificates across connections keep the one expiring first.
+func (c *CertificateStats) Record(host string, state tls.ConnectionState) {
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+
+	leaf := state.PeerCertificates[0]
+	cert := HostCertificate{
+		Host:        host,
+		Subject:     leaf.Subject.CommonName,
+		Issuer:      leaf.Issuer.CommonName,
+		NotAfter:    leaf.NotAfter,
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		WeakVersion: state.Version < tls.VersionTLS12,
+		WeakCipher:  isInsecureCipher(state.CipherSuite),
+	}
+
+	if cert.Issuer == "" && len(leaf.Issuer.Organization) != 0 {
+		cert.Issuer = leaf.Issuer.Organization[0]
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	if existing, ok := c.hosts[host]; ok && !cert.NotAfter.Before(existing.NotAfter) {
+		return
+	}
+	c.hosts[host] = cert
+}
+
+// Hosts returns the TLS details of all hosts ordered by host.
+func (c *CertificateStats) Hosts() []HostCertificate {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	hosts := make([]HostCertificate, 0, len(c.hosts))
+	for _, cert := range c.hosts {
+		hosts = append(hosts, cert)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// Expiring returns the TLS details of hosts whose certificate expires within
+// giving duration from now, ordered by host.
+func (c *CertificateStats) Expiring(within time.Duration, now time.Time) []HostCertificate {
+	var expiring []HostCertificate
+	for _, cert := range c.Hosts() {
+		if cert.ExpiresWithin(within, now) {
+			expiring = append(expiring, cert)
+		}
+	}
+	return expiring
+}
+
+// withTrace returns giving context traced to record the TLS details of every
+// new connection made for requests sent with it.
+func (c *CertificateStats) withTrace(ctx context.Context) context.Context {
+	if c == nil {
+		return ctx
+	}
+
+	var ml sync.Mutex
+	var host string
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			ml.Lock()
+			host = hostPort
+			ml.Unlock()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err != nil {
+				return
+			}
+
+			ml.Lock()
+			hostPort := host
+			ml.Unlock()
+
+			c.Record(hostPort, state)
+		},
+	})
+}
+
+// isInsecureCipher returns true if giving cipher suite is one with known
+// security issues.
+func isInsecureCipher(id uint16) bool {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// errors ...
+var (
+	ErrNoTarget           = errors.New("crawler has no target url")
+	ErrInvalidTarget      = errors.New("invalid target url, expected absolute http or https url")
+	ErrInvalidConfig      = errors.New("invalid crawler config")
+	ErrContradictoryScope = errors.New("contradictory scope rules")
+	ErrInvalidOwnHost     = errors.New("invalid own host, expected host or *. followed by a registered domain")
+)
+
+// NewPageCrawler returns a copy of giving PageCrawler configuration ready to be
+// run, with it's unset fields defaulted, else an error naming the first invalid
+// setting found. Crawlers should be created with it rather than used as zero
+// values, so invalid configurations are caught before any request is sent.
+func NewPageCrawler(cfg PageCrawler) (PageCrawler, error) {
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg.withDefaults(), nil
+}
+
+// Validate returns an error if the crawler's configuration is invalid, such as
+// a missing target, negative limits or scope rules contradicting each other.
+func (pc PageCrawler) Validate() error {
+	if pc.Target == nil {
+		return ErrNoTarget
+	}
+
+	if (pc.Target.Scheme != "http" && pc.Target.Scheme != "https") || pc.Target.Host == "" {
+		return fmt.Errorf("%+s: %+q", ErrInvalidTarget, pc.Target.String())
+	}
+
+	switch {
+	case pc.MaxDepth < -1:
+		return fmt.Errorf("%+s: max depth of %d, expected -1 or more", ErrInvalidConfig, pc.MaxDepth)
+	case pc.MaxRedirects < 0:
+		return fmt.Errorf("%+s: negative max redirects of %d", ErrInvalidConfig, pc.MaxRedirects)
+	case pc.RedirectChainLimit < 0:
+		return fmt.Errorf("%+s: negative redirect chain limit of %d", ErrInvalidConfig, pc.RedirectChainLimit)
+	case pc.MaxBodySize < 0:
+		return fmt.Errorf("%+s: negative max body size of %d", ErrInvalidConfig, pc.MaxBodySize)
+	case pc.RequestTimeout < 0:
+		return fmt.Errorf("%+s: negative request timeout of %s", ErrInvalidConfig, pc.RequestTimeout)
+	case pc.ReadTimeout < 0:
+		return fmt.Errorf("%+s: negative read timeout of %s", ErrInvalidConfig, pc.ReadTimeout)
+	}
+
+	if _, err := ParseRedirectPolicy(string(pc.RedirectPolicy)); err != nil {
+		return err
+	}
+
+	if _, err := ParseExternalRedirectPolicy(string(pc.ExternalRedirectPolicy)); err != nil {
+		return err
+	}
+
+	if err := pc.QueryPolicy.validate(); err != nil {
+		return err
+	}
+
+	scope, err := ParseScope(string(pc.Scope))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case scope == ScopeCustom && len(pc.ScopeHosts) == 0:
+		return fmt.Errorf("%+s: %+q scope without scope hosts", ErrContradictoryScope, scope)
+	case scope != ScopeCustom && len(pc.ScopeHosts) != 0:
+		return fmt.Errorf("%+s: scope hosts %+q ignored by %+q scope", ErrContradictoryScope, pc.ScopeHosts, scope)
+	}
+
+	for _, pattern := range pc.OwnHosts {
+		if !validOwnHost(pattern) {
+			return fmt.Errorf("%+s: %+q", ErrInvalidOwnHost, pattern)
+		}
+	}
+
+	return nil
+}
+
+// validOwnHost returns true if giving own host pattern names a host or the
+// subdomains of a registered domain, so no pattern exempts a public suffix
+// such as *.com from politeness.
+func validOwnHost(pattern string) bool {
+	host := strings.ToLower(strings.TrimSpace(pattern))
+	wildcard := strings.HasPrefix(host, "*.")
+	host = strings.TrimPrefix(host, "*.")
+
+	if host == "" || strings.ContainsAny(host, "*/ ") {
+		return false
+	}
+
+	suffix, _ := publicsuffix.PublicSuffix(host)
+	return !wildcard || suffix != host
+}
+
+// withDefaults returns a copy of the crawler with it's unset fields defaulted.
+func (pc PageCrawler) withDefaults() PageCrawler {
+	// if MaxDepth was left unset, set it to infinity(-1).
+	if pc.MaxDepth == 0 {
+		pc.MaxDepth = -1
+	}
+
+	if pc.Scope == "" {
+		pc.Scope = ScopeHost
+	}
+
+	if pc.RedirectPolicy == "" {
+		pc.RedirectPolicy = RedirectAny
+	}
+
+	if pc.ExternalRedirectPolicy == "" {
+		pc.ExternalRedirectPolicy = ExternalRedirectFollow
+	}
+
+	if pc.QueryPolicy.Mode == "" {
+		pc.QueryPolicy.Mode = QueryKeep
+	}
+
+	if pc.UserAgent == "" {
+		pc.UserAgent = DefaultUserAgent
+	}
+
+	return pc
+}
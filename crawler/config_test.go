@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestNewPageCrawler(t *testing.T) {
+	target, err := url.Parse("https://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	pages, err := NewPageCrawler(PageCrawler{Target: target, OwnHosts: []string{"staging.mombo.com", "*.mombo.co.uk", "10.0.0.1"}})
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created crawler")
+	}
+	tests.Passed("Should have successfully created crawler")
+
+	if pages.MaxDepth != -1 || pages.Scope != ScopeHost || pages.RedirectPolicy != RedirectAny || pages.QueryPolicy.Mode != QueryKeep || pages.UserAgent != DefaultUserAgent {
+		tests.Info("Crawler: %#v", pages)
+		tests.Failed("Should have defaulted unset fields of crawler")
+	}
+	tests.Passed("Should have defaulted unset fields of crawler")
+
+	relative, err := url.Parse("/about")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+
+	invalid := map[string]PageCrawler{
+		"crawler has no target":             {},
+		"invalid target url":                {Target: relative},
+		"max depth of -2":                   {Target: target, MaxDepth: -2},
+		"negative max redirects":            {Target: target, MaxRedirects: -1},
+		"negative request timeout":          {Target: target, RequestTimeout: -1},
+		"negative read timeout":             {Target: target, ReadTimeout: -1},
+		"invalid redirect policy":           {Target: target, RedirectPolicy: "sometimes"},
+		"invalid scope":                     {Target: target, Scope: "planet"},
+		"scope without scope hosts":         {Target: target, Scope: ScopeCustom},
+		"ignored by \"subdomains\" scope":   {Target: target, Scope: ScopeSubdomains, ScopeHosts: []string{"docs.mombo.com"}},
+		"invalid query policy":              {Target: target, QueryPolicy: QueryPolicy{Mode: QueryWhitelist}},
+		"contradictory scope rules: scope ": {Target: target, ScopeHosts: []string{"*.mombo.com"}},
+		"invalid own host":                  {Target: target, OwnHosts: []string{"*.co.uk"}},
+	}
+
+	for message, config := range invalid {
+		if _, err := NewPageCrawler(config); err == nil || !strings.Contains(err.Error(), message) {
+			tests.Info("Expected Error: %q", message)
+			tests.Info("Received Error: %+s", err)
+			tests.Failed("Should have failed to create crawler of invalid config")
+		}
+	}
+	tests.Passed("Should have failed to create crawler of invalid config")
+}
+
+func TestPageCrawlerRunInvalid(t *testing.T) {
+	pool := NewWorkerPool(1, context.Background())
+	defer pool.Stop()
+
+	reports := make(chan LinkReport)
+	if err := (PageCrawler{}).Run(context.Background(), http.DefaultClient, pool, reports); err != ErrNoTarget {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed to run crawler without target")
+	}
+	tests.Passed("Should have failed to run crawler without target")
+
+	if _, ok := <-reports; ok {
+		tests.Failed("Should have closed reports of invalid crawler")
+	}
+	tests.Passed("Should have closed reports of invalid crawler")
+}
@@ -0,0 +1,104 @@
+package crawler
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+)
+
+// HostConnections embodies the connections used for requests to a giving host.
+type HostConnections struct {
+	Host   string `json:"host"`
+	New    int    `json:"new"`
+	Reused int    `json:"reused"`
+}
+
+// Requests returns total requests sent to the host.
+func (h HostConnections) Requests() int {
+	return h.New + h.Reused
+}
+
+// ReuseRatio returns the share of requests to the host sent over a reused
+// connection.
+func (h HostConnections) ReuseRatio() float64 {
+	if h.Requests() == 0 {
+		return 0
+	}
+	return float64(h.Reused) / float64(h.Requests())
+}
+
+// ConnectionStats implements a concurrent-safe tracker of the connections
+// requests of a crawl are sent over per host, for tuning keep-alive and per
+// host limits.
+type ConnectionStats struct {
+	ml    sync.Mutex
+	hosts map[string]*HostConnections
+}
+
+// NewConnectionStats returns a new instance of a ConnectionStats.
+func NewConnectionStats() *ConnectionStats {
+	return &ConnectionStats{
+		hosts: map[string]*HostConnections{},
+	}
+}
+
+// Record adds a request sent to giving host over a new or reused connection.
+func (c *ConnectionStats) Record(host string, reused bool) {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	conns, ok := c.hosts[host]
+	if !ok {
+		conns = &HostConnections{Host: host}
+		c.hosts[host] = conns
+	}
+
+	if reused {
+		conns.Reused++
+		return
+	}
+	conns.New++
+}
+
+// Hosts returns the connections of all hosts ordered by host.
+func (c *ConnectionStats) Hosts() []HostConnections {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	hosts := make([]HostConnections, 0, len(c.hosts))
+	for _, conns := range c.hosts {
+		hosts = append(hosts, *conns)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// withTrace returns giving context traced to record the connection of every
+// request sent with it, including redirect hops to other hosts.
+func (c *ConnectionStats) withTrace(ctx context.Context) context.Context {
+	if c == nil {
+		return ctx
+	}
+
+	var ml sync.Mutex
+	var host string
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			ml.Lock()
+			host = hostPort
+			ml.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			ml.Lock()
+			hostPort := host
+			ml.Unlock()
+
+			c.Record(hostPort, info.Reused)
+		},
+	})
+}
@@ -0,0 +1,212 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultSectionCoverage is the pages crawled per top-level section before
+// sections are crawled deeper, when a crawl's page budget is spread across
+// sections.
+const DefaultSectionCoverage = 10
+
+// SectionStat embodies the pages of a top-level section crawled and those
+// skipped when the crawl's budget ran out.
+type SectionStat struct {
+	Section string `json:"section"`
+	Crawled int    `json:"crawled"`
+	Skipped int    `json:"skipped"`
+}
+
+// queuedKid embodies a kid of a page queued to be crawled, with the page it
+// was linked from and the depth it's crawled at.
+type queuedKid struct {
+	kid     LinkReport
+	parent  *LinkReport
+	depth   int
+	section string
+}
+
+// SectionCoverage implements a concurrent-safe bias of a crawl's frontier
+// spreading it's page budget across the top-level sections of a site, such as
+// /blog or /docs, so every section gets some coverage before any section gets
+// deep coverage. Pages of a section beyond it's share of quota pages are
+// deferred until the crawl runs out of other pages, then released with every
+// section's share raised by the quota. Sections whose pages were dropped once
+// the budget ran out are reported as partially crawled. A nil SectionCoverage
+// leaves the frontier as is.
+type SectionCoverage struct {
+	ml       sync.Mutex
+	quota    int
+	limit    int
+	admitted map[string]int
+	crawled  map[string]int
+	skipped  map[string]map[string]bool
+	done     map[string]bool
+	deferred []queuedKid
+	queued   map[string]bool
+}
+
+// NewSectionCoverage returns a new instance of a SectionCoverage crawling
+// giving quota of pages per section before sections are crawled deeper. A
+// quota below 1 defaults to DefaultSectionCoverage.
+func NewSectionCoverage(quota int) *SectionCoverage {
+	if quota < 1 {
+		quota = DefaultSectionCoverage
+	}
+
+	return &SectionCoverage{
+		quota:    quota,
+		limit:    quota,
+		admitted: map[string]int{},
+		crawled:  map[string]int{},
+		skipped:  map[string]map[string]bool{},
+		done:     map[string]bool{},
+		queued:   map[string]bool{},
+	}
+}
+
+// Partial returns the sections with pages skipped once the budget ran out,
+// ordered by section.
+func (c *SectionCoverage) Partial() []SectionStat {
+	if c == nil {
+		return nil
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	var stats []SectionStat
+	for section, skipped := range c.skipped {
+		if len(skipped) != 0 {
+			stats = append(stats, SectionStat{Section: section, Crawled: c.crawled[section], Skipped: len(skipped)})
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Section < stats[j].Section
+	})
+	return stats
+}
+
+// admit returns true if giving kid may be crawled now, counting it against it's
+// section's share, else defers it until the crawl runs out of other pages.
+func (c *SectionCoverage) admit(kid queuedKid) bool {
+	if c == nil {
+		return true
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	if c.admitted[kid.section] < c.limit {
+		c.admitted[kid.section]++
+		return true
+	}
+
+	key := seenKey(kid.kid.Path)
+	if !c.queued[key] {
+		c.queued[key] = true
+		c.deferred = append(c.deferred, kid)
+	}
+	return false
+}
+
+// release raises the share of every section by the quota, returning the
+// deferred kids fitting within their section's share in the order they were
+// deferred.
+func (c *SectionCoverage) release() []queuedKid {
+	if c == nil {
+		return nil
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	c.limit += c.quota
+
+	var released, deferred []queuedKid
+	for _, kid := range c.deferred {
+		if c.admitted[kid.section] < c.limit {
+			c.admitted[kid.section]++
+			delete(c.queued, seenKey(kid.kid.Path))
+			released = append(released, kid)
+			continue
+		}
+		deferred = append(deferred, kid)
+	}
+
+	c.deferred = deferred
+	return released
+}
+
+// drop records all deferred kids as skipped, as the crawl ended before they
+// were released.
+func (c *SectionCoverage) drop() {
+	if c == nil {
+		return
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	for _, kid := range c.deferred {
+		c.markSkipped(kid.kid.Path)
+	}
+	c.deferred = nil
+	c.queued = map[string]bool{}
+}
+
+// crawl records giving page of a section as crawled.
+func (c *SectionCoverage) crawl(link *url.URL) {
+	if c == nil {
+		return
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	section, key := urlSection(link), seenKey(link)
+	c.crawled[section]++
+	c.done[key] = true
+	delete(c.skipped[section], key)
+}
+
+// skip records giving page as skipped by the crawl's budget.
+func (c *SectionCoverage) skip(link *url.URL) {
+	if c == nil {
+		return
+	}
+
+	c.ml.Lock()
+	defer c.ml.Unlock()
+	c.markSkipped(link)
+}
+
+// markSkipped records giving page as skipped unless it was crawled, the lock
+// being held.
+func (c *SectionCoverage) markSkipped(link *url.URL) {
+	key := seenKey(link)
+	if c.done[key] {
+		return
+	}
+
+	section := urlSection(link)
+	if c.skipped[section] == nil {
+		c.skipped[section] = map[string]bool{}
+	}
+	c.skipped[section][key] = true
+}
+
+// urlSection returns the top-level section of giving url, being the first
+// segment of it's path prefixed with a slash, such as /blog, or / for the
+// site's root.
+func urlSection(link *url.URL) string {
+	segment := strings.TrimPrefix(link.Path, "/")
+	if index := strings.IndexByte(segment, '/'); index != -1 {
+		segment = segment[:index]
+	}
+	return "/" + segment
+}
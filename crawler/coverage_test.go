@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestURLSection(t *testing.T) {
+	fixtures := map[string]string{
+		"http://example.com":             "/",
+		"http://example.com/":            "/",
+		"http://example.com/about":       "/about",
+		"http://example.com/blog/":       "/blog",
+		"http://example.com/blog/2020/1": "/blog",
+	}
+
+	for raw, expected := range fixtures {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url")
+		}
+
+		if section := urlSection(link); section != expected {
+			tests.Info("URL: %q, Expected: %q, Received: %q", raw, expected, section)
+			tests.Failed("Should have returned the top-level section of url")
+		}
+	}
+	tests.Passed("Should have returned the top-level section of url")
+}
+
+func TestSectionCoverage(t *testing.T) {
+	queue := func(path string) queuedKid {
+		link := &url.URL{Scheme: "http", Host: "example.com", Path: path}
+		return queuedKid{kid: LinkReport{Path: link}, section: urlSection(link)}
+	}
+
+	coverage := NewSectionCoverage(2)
+
+	var admitted []string
+	for _, path := range []string{"/blog/1", "/blog/2", "/blog/3", "/blog/4", "/blog/5", "/blog/3", "/docs/1"} {
+		if coverage.admit(queue(path)) {
+			admitted = append(admitted, path)
+		}
+	}
+
+	if len(admitted) != 3 || admitted[2] != "/docs/1" {
+		tests.Info("Received: %+q", admitted)
+		tests.Failed("Should have admitted pages within their section's share")
+	}
+	tests.Passed("Should have admitted pages within their section's share")
+
+	released := coverage.release()
+	if len(released) != 2 || released[0].kid.Path.Path != "/blog/3" || released[1].kid.Path.Path != "/blog/4" {
+		tests.Info("Received: %+v", released)
+		tests.Failed("Should have released deferred pages once within their section's raised share")
+	}
+	tests.Passed("Should have released deferred pages once within their section's raised share")
+
+	coverage.crawl(&url.URL{Scheme: "http", Host: "example.com", Path: "/blog/1"})
+	coverage.crawl(&url.URL{Scheme: "http", Host: "example.com", Path: "/docs/1"})
+	coverage.drop()
+
+	partial := coverage.Partial()
+	if len(partial) != 1 || partial[0] != (SectionStat{Section: "/blog", Crawled: 1, Skipped: 1}) {
+		tests.Info("Received: %+v", partial)
+		tests.Failed("Should have reported sections with pages dropped as partial")
+	}
+	tests.Passed("Should have reported sections with pages dropped as partial")
+}
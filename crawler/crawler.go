@@ -5,21 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
 	"sync"
-
-	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/net/html"
 )
 
+// Version is the current version of the sitecrawler.
+const Version = "0.1.0"
+
+// DefaultUserAgent is the User-Agent identifying the crawler when none is set.
+const DefaultUserAgent = "sitecrawler/" + Version + " (+https://github.com/influx6/sitecrawler)"
+
+// DefaultMaxBodySize is the maximum bytes read of a page's body when none is set.
+const DefaultMaxBodySize = 5 << 20
+
 // errors ...
 var (
 	ErrPageFailed = errors.New("url path failed to respond, possible dead")
 	ErrNonHTMLURL = errors.New("path points to a non html path")
+	ErrHostPaused = errors.New("host requested a pause of requests with Retry-After")
 )
 
 // Status embodies data used to represent a giving links state status.
@@ -28,14 +38,62 @@ type Status struct {
 	IsCrawlable bool      `json:"is_crawlable"`
 	LastStatus  int       `json:"last_status"`
 	At          time.Time `json:"at"`
-	Reason      error     `json:"reason,omitemtpy"`
+	Reason      *Reason   `json:"reason,omitempty"`
 }
 
 // LinkReport embodies a the data reports for a giving path.
 type LinkReport struct {
-	Path     *url.URL     `json:"path"`
-	Status   Status       `json:"status"`
-	PointsTo []LinkReport `json:"points_to"`
+	Path            *url.URL          `json:"path"`
+	Status          Status            `json:"status"`
+	Title           string            `json:"title,omitempty"`
+	Language        string            `json:"language,omitempty"`
+	Direction       string            `json:"direction,omitempty"`
+	ContentType     string            `json:"content_type,omitempty"`
+	ContentLength   int64             `json:"content_length"`
+	Latency         time.Duration     `json:"latency"`
+	Owner           string            `json:"owner,omitempty"`
+	Position        LinkPosition      `json:"position,omitempty"`
+	Rel             []string          `json:"rel,omitempty"`
+	AnchorText      string            `json:"anchor_text,omitempty"`
+	Heading         string            `json:"heading,omitempty"`
+	Origin          string            `json:"origin,omitempty"`
+	RedirectedTo    *url.URL          `json:"redirected_to,omitempty"`
+	Redirects       []RedirectHop     `json:"redirects,omitempty"`
+	LongRedirect    bool              `json:"long_redirect,omitempty"`
+	Stripped        []string          `json:"stripped_params,omitempty"`
+	SniffedType     string            `json:"sniffed_type,omitempty"`
+	Cached          bool              `json:"cached,omitempty"`
+	Truncated       bool              `json:"truncated,omitempty"`
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	EncodedSize     int64             `json:"encoded_size,omitempty"`
+	DecodedSize     int64             `json:"decoded_size,omitempty"`
+	RemoteIP        string            `json:"remote_ip,omitempty"`
+	IPFamily        IPFamily          `json:"ip_family,omitempty"`
+	ASN             int               `json:"asn,omitempty"`
+	ASOrg           string            `json:"as_org,omitempty"`
+	Server          string            `json:"server,omitempty"`
+	Stylesheet      string            `json:"stylesheet,omitempty"`
+	Feed            string            `json:"feed,omitempty"`
+	JSON            string            `json:"json,omitempty"`
+	Robots          *RobotsDirectives `json:"robots,omitempty"`
+	Contacts        []string          `json:"contacts,omitempty"`
+	ParseWarnings   []string          `json:"parse_warnings,omitempty"`
+	RouteError      string            `json:"route_error,omitempty"`
+	Canonical       *url.URL          `json:"canonical,omitempty"`
+	NonCanonical    bool              `json:"canonical_mismatch,omitempty"`
+	Alternates      []Alternate       `json:"alternates,omitempty"`
+	Social          *SocialTags       `json:"social,omitempty"`
+	Structured      *StructuredData   `json:"structured_data,omitempty"`
+	CacheBefore     CacheStatus       `json:"cache_before,omitempty"`
+	CacheAfter      CacheStatus       `json:"cache_after,omitempty"`
+	Template        string            `json:"template,omitempty"`
+	TemplateCapped  bool              `json:"template_capped,omitempty"`
+	Metadata        Metadata          `json:"metadata,omitempty"`
+	PointsTo        []LinkReport      `json:"points_to"`
+
+	// page holds the page farmed when the report's path was checked with a GET,
+	// so it's not requested again when crawled.
+	page *pageDocument
 }
 
 // PageCrawler implements a web crawler which runs through a provided
@@ -52,17 +110,257 @@ type PageCrawler struct {
 	// Verbose dictates that PageCrawler print current scanning target.
 	Verbose bool
 
-	current int
-	seen    *HasSet
-	child   bool
-	report  *LinkReport
-	waiter  *sync.WaitGroup
+	// UserAgent sets the User-Agent identifying the crawler on all requests,
+	// defaults to DefaultUserAgent.
+	UserAgent string
+
+	// Headers sets additional headers sent on all requests, such as auth tokens
+	// or language preferences.
+	Headers http.Header
+
+	// Cookies sets cookies sent on all requests, such as session or A/B
+	// bucket cookies.
+	Cookies []*http.Cookie
+
+	// Discoveries when set records every discovery of a url by the crawler,
+	// including those already seen, for analysis of the site's internal linking.
+	Discoveries *DiscoveryStats
+
+	// Connections when set records the connections requests are sent over per
+	// host, new or reused, for tuning keep-alive and per host limits.
+	Connections *ConnectionStats
+
+	// OnEnqueue when set is called for the target and every page enqueued for
+	// crawling, attaching metadata to the context the page is crawled with,
+	// which is emitted on it's report. Pages inherit the metadata of the page
+	// linking to them.
+	OnEnqueue EnqueueHook
+
+	// Graph when set records the link graph of the crawl, the urls crawled and
+	// linked to with the links between them, for analysis once Run completes.
+	Graph *Graph
+
+	// Sitemap when set seeds the crawl with the urls listed by the target's
+	// /sitemap.xml and the sitemaps nested within it, so pages no page links to
+	// are crawled, recording how the urls listed compare to those linked.
+	Sitemap *SitemapSeeds
+
+	// Certificates when set records the TLS details and certificate of every
+	// https host connected to, for flagging certificates about to expire.
+	Certificates *CertificateStats
+
+	// Sections sets politeness overrides for specific path prefixes of the
+	// target, restricting concurrency or adding delay for just those sections.
+	Sections []SectionRule
+
+	// RampUp when set with a Max warms the crawl up from low concurrency to
+	// it's maximum over a window, holding while requests fail.
+	RampUp RampUp
+
+	// Rate sets the maximum rate of requests allowed per host. A zero rate
+	// leaves requests unlimited except for delays requested by the host.
+	Rate Rate
+
+	// RequestTimeout sets the deadline of each request, spanning all redirects
+	// followed and the read of it's body. A zero value leaves requests bounded
+	// only by the client.
+	RequestTimeout time.Duration
+
+	// ReadTimeout when set bounds the read of crawled pages by how long a
+	// single read of their body may stall, so slow but steady pages outlive
+	// the RequestTimeout, which then spans each page's request up to it's
+	// response only.
+	ReadTimeout time.Duration
+
+	// MaxRedirects sets the maximum redirects followed for a url before it is
+	// reported as failed, defaults to 10.
+	MaxRedirects int
+
+	// RedirectChainLimit sets the redirect chain length beyond which a url is
+	// flagged with LongRedirect, defaults to 3.
+	RedirectChainLimit int
+
+	// RedirectPolicy sets which redirects are followed, redirects not followed
+	// are recorded with their location without fetching it. Defaults to
+	// RedirectAny.
+	RedirectPolicy RedirectPolicy
+
+	// ExternalRedirectPolicy sets how redirects leading out of the crawl's
+	// scope are followed, on top of the redirect policy, so checks of external
+	// urls stay cheap. Defaults to ExternalRedirectFollow.
+	ExternalRedirectPolicy ExternalRedirectPolicy
+
+	// Cache when set holds the validators of pages from previous crawls, pages
+	// are requested conditionally and restored from it when unchanged.
+	Cache *ValidatorCache
+
+	// State when set records the crawl's frontier, seen set and results into
+	// it's Store as the crawl runs.
+	State *CrawlState
+
+	// KeepSessionParams dictates that PageCrawler keep session parameters
+	// of links, which are otherwise stripped before links are checked.
+	KeepSessionParams bool
+
+	// KeepTrackingParams dictates that PageCrawler keep the built-in tracking
+	// parameters of links, such as utm_* or gclid, which are otherwise
+	// stripped so links differing only by campaign tags are crawled once.
+	KeepTrackingParams bool
+
+	// TrackingParams sets additional tracking parameters stripped from links,
+	// names ending in "*" stripping all parameters of their prefix.
+	TrackingParams []string
+
+	// QueryPolicy sets whether the query strings of links distinguish urls,
+	// keeping them all by default. Stripped parameters are recorded on the
+	// links' reports.
+	QueryPolicy QueryPolicy
+
+	// Traps when set checks links against it's rules before they're requested,
+	// skipping those suspected to lie within crawler traps such as endless
+	// calendars. Skipped links are reported with a trap reason.
+	Traps *TrapDetector
+
+	// Templates when set clusters crawled pages by their structure into
+	// templates, leaving the links of pages beyond it's per template limit
+	// unchecked and uncrawled so machine-generated sections are sampled.
+	Templates *TemplateClusters
+
+	// Budget when set bounds the pages crawled and requests sent by the crawl,
+	// which stops crawling new pages once it's exhausted.
+	Budget *Budget
+
+	// Coverage when set spreads the crawl's pages across the top-level
+	// sections of the site, so a budget truncating the crawl still covers
+	// every section before any section is crawled deeply. Sections left
+	// partially crawled by the budget are reported by it.
+	Coverage *SectionCoverage
+
+	// MaxBodySize sets the maximum bytes read of a page's body, so huge files
+	// whose Content-Type lies are not downloaded whole. Links are only farmed
+	// from the bytes read. Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+
+	// RateByIP dictates that PageCrawler apply the rate per resolved ip address
+	// of hosts rather than per host name, so subdomains sharing one origin
+	// server don't multiply the effective rate of requests.
+	RateByIP bool
+
+	// Resolver sets the resolver hosts are resolved with when applying the rate
+	// per ip address, defaults to net.DefaultResolver.
+	Resolver HostResolver
+
+	// Scope sets which hosts links must lie on to be crawled, relative to the
+	// target's host, defaults to ScopeHost.
+	Scope Scope
+
+	// ScopeHosts sets the host patterns crawled with ScopeCustom, such as
+	// docs.monzo.com or *.monzo.com.
+	ScopeHosts []string
+
+	// AllowHosts sets additional hosts crawled as internal whatever the scope,
+	// such as a docs domain served off a CDN, other hosts staying external.
+	AllowHosts []string
+
+	// RespectNofollow dictates that PageCrawler not crawl links whose anchor is
+	// marked rel="nofollow", which are still checked and listed with their rel
+	// values.
+	RespectNofollow bool
+
+	// IgnoreCrawlDelay dictates that PageCrawler ignore the Crawl-delay provided
+	// by the target's robots.txt.
+	IgnoreCrawlDelay bool
+
+	// OwnHosts sets the host patterns of infrastructure owned by the crawl's
+	// operator, such as staging.monzo.com or *.internal.monzo.com, which are
+	// crawled at full speed, ignoring the rate, robots.txt and Retry-After
+	// pauses. All other hosts keep the crawl's politeness.
+	OwnHosts []string
+
+	// ScanStylesheets dictates that PageCrawler fetch the live stylesheets
+	// linked by pages, checking the fonts, images and stylesheets they
+	// reference through url() and @import as links of the page.
+	ScanStylesheets bool
+
+	// FollowFeeds dictates that PageCrawler parse the live RSS and Atom feeds
+	// linked by pages, crawling the urls of their items within the crawl's
+	// scope as links of the page, as posts are often only reachable through
+	// feeds or archives.
+	FollowFeeds bool
+
+	// ScanJSON dictates that PageCrawler scan the json documents linked by
+	// pages within the crawl's scope, such as data routes of single page apps,
+	// and json embedded in their <script> elements, crawling the url-shaped
+	// strings they hold within the crawl's scope as links of the page.
+	ScanJSON bool
+
+	// ScanSocial dictates that PageCrawler audit the Open Graph and Twitter
+	// Card tags of pages, recording their og:title, og:image and twitter:card
+	// and flagging those missing along with images which are broken or not
+	// images.
+	ScanSocial bool
+
+	// Fetcher when set sends all requests of the crawl in place of the client
+	// given to Run, such as a fetcher answering from a cache or recorded
+	// fixtures. Redirects are still followed by the crawler.
+	Fetcher Fetcher
+
+	// Renderer when set renders the document links are farmed from for every
+	// crawled page, such as the DOM rendered by a ChromeRenderer for single
+	// page apps, merging it's links with those of the page served. Pages
+	// failing to render are farmed as served, recording the failure as a
+	// parse warning.
+	Renderer Renderer
+
+	// PrimeCache dictates that PageCrawler warm the CDN and origin caches of
+	// the site, such as after a deploy, requesting every url within the
+	// crawl's scope with a GET read whole, without conditional requests or
+	// cache-busting headers. Each url is requested again with a HEAD once
+	// primed, recording the cache status reported before and after.
+	PrimeCache bool
+
+	// Scheduler when set decides the order the links of pages are crawled in
+	// and how long requests wait, in place of the default PoliteScheduler.
+	// The crawler's Rate, RateByIP, OwnHosts and the Crawl-delay of the
+	// target's robots.txt only apply to the default.
+	Scheduler Scheduler
+
+	current     int
+	root        *url.URL
+	seen        *HasSet
+	sections    *sectionLimiter
+	ramp        *rampLimiter
+	scheduler   Scheduler
+	fetch       Fetcher
+	checks      *statusScheduler
+	sessions    *SessionStripper
+	stylesheets *referenceScanner
+	feeds       *referenceScanner
+	jsonDocs    *referenceScanner
+	child       bool
+	report      *LinkReport
+	waiter      *sync.WaitGroup
 }
 
 // Run initializes the target url crawling all pages url paths retrieved from
 // the target's body content. It crawls deeply into all pages based on giving depth
-// desired.
-func (pc PageCrawler) Run(ctx context.Context, client *http.Client, pool WorkerPool, reports chan<- LinkReport) {
+// desired. Requests are sent through a Fetcher of the client, built once and
+// shared by all pages of the crawl, unless the crawler's Fetcher is set. It
+// fails fast with the crawler's validation error, closing reports without
+// sending a request, if the crawler's configuration is invalid.
+func (pc PageCrawler) Run(ctx context.Context, client *http.Client, pool WorkerPool, reports chan<- LinkReport) error {
+	if !pc.child {
+		if err := pc.Validate(); err != nil {
+			close(reports)
+			return err
+		}
+		pc = pc.withDefaults()
+	}
+
+	if pc.fetch == nil {
+		pc.fetch = pc.newFetcher(client)
+	}
+
 	if pc.waiter == nil {
 		pc.waiter = new(sync.WaitGroup)
 	}
@@ -71,10 +369,56 @@ func (pc PageCrawler) Run(ctx context.Context, client *http.Client, pool WorkerP
 		pc.seen = NewHasSet()
 	}
 
+	if pc.sections == nil && len(pc.Sections) != 0 {
+		pc.sections = newSectionLimiter(pc.Sections)
+	}
+
+	if pc.checks == nil {
+		pc.checks = newStatusScheduler()
+	}
+
+	if pc.ramp == nil && pc.RampUp.Max > 0 {
+		pc.ramp = newRampLimiter(pc.RampUp, time.Now())
+	}
+
+	if pc.sessions == nil && !pc.KeepSessionParams {
+		pc.sessions = NewSessionStripper()
+	}
+
+	if pc.stylesheets == nil && pc.ScanStylesheets {
+		pc.stylesheets = newReferenceScanner()
+	}
+
+	if pc.feeds == nil && pc.FollowFeeds {
+		pc.feeds = newReferenceScanner()
+	}
+
+	if pc.jsonDocs == nil && pc.ScanJSON {
+		pc.jsonDocs = newReferenceScanner()
+	}
+
+	if pc.scheduler == nil {
+		pc.scheduler = pc.newScheduler()
+
+		// The Crawl-delay of the target's robots.txt paces the default
+		// scheduler only.
+		if polite, ok := pc.scheduler.(*PoliteScheduler); ok && pc.Scheduler == nil && !pc.IgnoreCrawlDelay && !pc.ownsHost(pc.Target.Host) {
+			if robots := pc.fetchRobots(ctx); robots.CrawlDelay > 0 {
+				polite.SetDelay(pc.Target.Host, robots.CrawlDelay)
+			}
+		}
+	}
+
 	if !pc.child {
 		pc.waiter.Add(1)
 		go func() {
 			pc.waiter.Wait()
+
+			// Release pages deferred by the coverage once all others are
+			// crawled, until none remain.
+			for pc.releaseDeferred(ctx, pool, reports) {
+				pc.waiter.Wait()
+			}
 			close(reports)
 		}()
 	}
@@ -85,34 +429,40 @@ func (pc PageCrawler) Run(ctx context.Context, client *http.Client, pool WorkerP
 		defer fmt.Printf("Done scanning %+q from %q.\n", pc.Target.Path, pc.Target.Host)
 	}
 
-	// if MaxDepth was left unset, set it to infinity(-1).
-	if pc.MaxDepth == 0 {
-		pc.MaxDepth = -1
+	// Kids are normalized when their links are checked, leaving the seed.
+	if !pc.child {
+		pc.Target, _ = pc.stripQuery(Normalize(pc.Target))
+		pc.State.seed(pc.Target)
 	}
 
-	trimmed := strings.TrimSuffix(pc.Target.Path, "/")
-	if trimmed == "" {
-		trimmed = "/"
-	}
+	trimmed := seenKey(pc.Target)
 
 	// if we have have an attached seen map, then check if requests
 	// has already being added to the seen map and marked as processed or
 	// in-process.
 	if pc.seen.Has(trimmed) {
-		return
+		return nil
 	}
 
 	// Have we max'ed out desired depth, then stop.
 	if pc.MaxDepth > 0 && pc.current >= pc.MaxDepth {
-		return
+		return nil
 	}
 
 	// Add target into seen map immediately.
 	pc.seen.Add(trimmed)
+	pc.State.crawl(pc.Target)
+
+	// Have we spent the crawl's budget, then stop.
+	if !pc.Budget.Page() {
+		pc.Coverage.skip(pc.Target)
+		return nil
+	}
+	pc.Coverage.crawl(pc.Target)
 
 	select {
 	case <-ctx.Done():
-		return
+		return nil
 	default:
 		if pc.Verbose {
 			fmt.Printf("Scanning %+q from %q.\n", pc.Target.Path, pc.Target.Host)
@@ -120,137 +470,958 @@ func (pc PageCrawler) Run(ctx context.Context, client *http.Client, pool WorkerP
 
 		var report LinkReport
 		if pc.report == nil {
-			report.Path = pc.Target
-			report.Status = getURLStatus(client, pc.Target)
+			ctx = pc.enqueue(ctx, pc.Target, nil)
+
+			report = pc.checks.Check(pc.Target, func() LinkReport {
+				return pc.statusReport(ctx, pc.Target, true)
+			})
+
+			// Attribute the seed to the final url it redirects to, so it's links
+			// resolve against and stay within the final host.
+			if report.RedirectedTo != nil {
+				pc.Target, _ = pc.stripQuery(report.RedirectedTo)
+				pc.seen.Add(seenKey(pc.Target))
+				pc.State.crawl(pc.Target)
+				report.Path = pc.Target
+			}
+
+			// Scope all pages of the crawl to the target's final host.
+			pc.root = pc.Target
+
+			// Crawl the urls listed by the target's sitemaps once the target
+			// is crawled.
+			if pc.Sitemap != nil {
+				defer func() { pc.seedSitemaps(ctx, pool, reports) }()
+			}
 		} else {
 			report = *pc.report
+
+			// Position, rel, anchor text, heading and origin describe the link
+			// which led to the page, not the page itself.
+			report.Position = ""
+			report.Rel = nil
+			report.AnchorText = ""
+			report.Heading = ""
+			report.Origin = ""
 		}
 
+		report.Metadata = MetadataFrom(ctx)
+
 		// check url status if the page is live, else skip.
 		if !report.Status.IsLive {
-			reports <- report
-			return
+			pc.deliver(reports, report)
+			return nil
 		}
 
 		// if report indicates it's a live page but not something we can crawl with, maybe due to content-type, then skip.
 		if report.Status.IsLive && !report.Status.IsCrawlable {
-			reports <- report
-			return
+			pc.deliver(reports, report)
+			return nil
 		}
 
-		// Retrieve path's body for scanning, else skip if and update status.
-		pathBody, err := exploreURL(client, pc.Target)
-		if err != nil {
-			report.Status.IsLive = false
-			reports <- report
-			return
+		// Use the page farmed when target was checked, else retrieve path's
+		// body for scanning, skipping if it fails and update status.
+		page := report.page
+		if page == nil {
+			fetched, err := pc.fetchPage(ctx, &report)
+			if err != nil {
+				report.Status.IsLive = false
+				pc.deliver(reports, report)
+				return nil
+			}
+			page = &fetched
+		}
+
+		if pc.Renderer != nil {
+			rendered, ok := pc.render(ctx, *page)
+			if ok {
+				report.RouteError = routeError(rendered)
+			}
+			page = &rendered
+		}
+
+		report.page = nil
+		report.Title = page.Title
+		report.Language = page.Language
+		report.Direction = page.Direction
+		report.Canonical = page.Canonical
+		report.NonCanonical = canonicalMismatch(pc.Target, page.Canonical)
+		report.Alternates = pc.reportAlternates(page.Alternates)
+		report.Contacts = page.Contacts
+		report.ParseWarnings = page.Warnings
+		report.Robots = newRobotsDirectives(page.Robots)
+
+		if !page.Structured.empty() {
+			structured := page.Structured
+			report.Structured = &structured
+		}
+
+		if pc.ScanSocial {
+			report.Social = pc.auditSocial(ctx, page.Social)
+		}
+
+		// Pages beyond their template's limit are reported without exploring
+		// their links.
+		var explore bool
+		report.Template, explore = pc.Templates.Assign(pc.Target.String(), page.Shingles)
+		if !explore {
+			report.TemplateCapped = true
+			pc.deliver(reports, report)
+			return nil
 		}
 
-		defer pathBody.Close()
+		var err error
 
-		// Use BodyCrawler to retrieve page's internal children links.
+		// Check status of page's internal children links.
 		// Skip if we failed to get children.
 		// TODO: Should we update isLive status here? Does failure here warrant change?
-		report.PointsTo, err = CrawlBody(client, pc.Target, pathBody)
+		links := page.Links
+		if pc.ScanJSON && len(page.JSONLinks) != 0 {
+			links = mergeLinks(page.Links, page.JSONLinks)
+		}
+
+		report.PointsTo, err = pc.checkLinks(ctx, pool, pc.Target, links)
 		if err != nil {
-			reports <- report
-			return
+			report.PointsTo = withoutPages(report.PointsTo)
+			pc.deliver(reports, report)
+			return nil
+		}
+
+		if pc.ScanStylesheets {
+			report.PointsTo = append(report.PointsTo, pc.checkStylesheets(ctx, pool, report.PointsTo)...)
 		}
 
-		// Deliver target's report.
-		reports <- report
+		if pc.FollowFeeds {
+			report.PointsTo = append(report.PointsTo, pc.checkFeeds(ctx, pool, report.PointsTo)...)
+		}
 
-		nextDepth := pc.current + 1
+		if pc.ScanJSON {
+			report.PointsTo = append(report.PointsTo, pc.checkJSON(ctx, pool, report.PointsTo)...)
+		}
 
-		// Issue new PageCrawlers for target's kids and update waitgroup worker count.
-		for _, kid := range report.PointsTo {
-			kidPath := strings.TrimSuffix(kid.Path.Path, "/")
-			if kidPath == "" {
-				continue
+		if pc.Discoveries != nil {
+			source := pc.Target.String()
+			for _, kid := range report.PointsTo {
+				pc.Discoveries.Record(kid.Path.String(), source)
 			}
+		}
+
+		// Deliver target's report, it's links leaving out the pages farmed
+		// when they were checked, which are only handed to their crawls.
+		kids := report.PointsTo
+		report.PointsTo = withoutPages(kids)
+		pc.deliver(reports, report)
+
+		// Pages marked nofollow have their links checked but not crawled.
+		if report.Robots != nil && report.Robots.NoFollow {
+			return nil
+		}
+
+		pc.crawlKids(ctx, pool, reports, &report, kids)
+	}
 
-			if !kid.Status.IsCrawlable {
+	return nil
+}
+
+// crawlKids issues new PageCrawlers for giving kids of parent, which is nil for
+// kids not linked from a page, such as those listed by sitemaps, in the order
+// set by the crawl's scheduler.
+func (pc PageCrawler) crawlKids(ctx context.Context, pool WorkerPool, reports chan<- LinkReport, parent *LinkReport, kids []LinkReport) {
+	nextDepth := pc.current + 1
+
+	// Collect the kids to crawl, attributed to their final url.
+	var queue []LinkReport
+	for _, kid := range kids {
+		kidTarget := kid.Path
+
+		// Attribute redirected kids to their final url, skipping those
+		// which leave the host.
+		if kid.RedirectedTo != nil {
+			if !pc.inScope(pc.Target, kid.RedirectedTo) {
 				continue
 			}
+			kidTarget, _ = pc.stripQuery(kid.RedirectedTo)
+		}
 
-			if pc.seen.Has(kidPath) {
-				continue
+		if !kid.Status.IsCrawlable {
+			continue
+		}
+
+		if !pc.follows(kid.Rel) {
+			continue
+		}
+
+		// Fix issue with kid report leaking into future goroutines.
+		kid.Path = kidTarget
+		kid.Redirects = nil
+		kid.RedirectedTo = nil
+		kid.LongRedirect = false
+		kid.Stripped = nil
+		kid.Rel = nil
+		queue = append(queue, kid)
+	}
+
+	var page *url.URL
+	if parent != nil {
+		page = parent.Path
+	}
+
+	// Issue new PageCrawlers for target's kids and update waitgroup worker count.
+	// Kids beyond their section's share of the coverage are deferred.
+	var enqueued []queuedKid
+	for _, kid := range pc.scheduler.Order(page, queue) {
+		if pc.seen.Has(seenKey(kid.Path)) {
+			continue
+		}
+
+		if pc.Budget.Exhausted() {
+			pc.Coverage.skip(kid.Path)
+			continue
+		}
+
+		queued := queuedKid{kid: kid, parent: parent, depth: nextDepth, section: urlSection(kid.Path)}
+		if !pc.Coverage.admit(queued) {
+			continue
+		}
+
+		pc.waiter.Add(1)
+		enqueued = append(enqueued, queued)
+	}
+
+	pc.spawnKids(ctx, pool, reports, enqueued)
+}
+
+// spawnKids issues new PageCrawlers for giving queued kids, whose crawls were
+// added to the waitgroup.
+func (pc PageCrawler) spawnKids(ctx context.Context, pool WorkerPool, reports chan<- LinkReport, queued []queuedKid) {
+	if len(queued) == 0 {
+		return
+	}
+
+	for index := range queued {
+		pc.State.queue(queued[index].kid.Path, queued[index].depth)
+	}
+
+	// Secure worker service for kids in order from one goroutine, as adding
+	// blocks while all workers are busy. If failed, drop request counter.
+	go func() {
+		for index := range queued {
+			kid := queued[index].kid
+
+			kidCrawler := pc
+			kidCrawler.child = true
+			kidCrawler.report = &kid
+			kidCrawler.Target = kid.Path
+			kidCrawler.current = queued[index].depth
+
+			kidCtx := pc.enqueue(ctx, kid.Path, queued[index].parent)
+			// Kids send their requests through the fetcher of the crawl.
+			if err := pool.Add(func() { kidCrawler.Run(kidCtx, nil, pool, reports) }); err != nil {
+				pc.waiter.Done()
 			}
+		}
+	}()
+}
+
+// releaseDeferred issues new PageCrawlers for the kids deferred by the
+// coverage which fit within their section's raised share, returning false once
+// none remain. Kids still deferred when the budget is exhausted or the crawl
+// ends are dropped as skipped.
+func (pc PageCrawler) releaseDeferred(ctx context.Context, pool WorkerPool, reports chan<- LinkReport) bool {
+	if pc.Coverage == nil {
+		return false
+	}
+
+	for {
+		if pc.Budget.Exhausted() || ctx.Err() != nil {
+			pc.Coverage.drop()
+			return false
+		}
 
-			pc.waiter.Add(1)
+		released := pc.Coverage.release()
+		if len(released) == 0 {
+			return false
+		}
 
-			// Attempt to secure worker service, if failed, drop request counter.
-			// Fix issue with kid report leaking into future goroutines.
-			go func(k LinkReport) {
-				kidCrawler := PageCrawler{
-					child:    true,
-					report:   &k,
-					Target:   k.Path,
-					seen:     pc.seen,
-					waiter:   pc.waiter,
-					Verbose:  pc.Verbose,
-					MaxDepth: pc.MaxDepth,
-					current:  nextDepth,
-				}
+		var enqueued []queuedKid
+		for _, queued := range released {
+			if !pc.seen.Has(seenKey(queued.kid.Path)) {
+				pc.waiter.Add(1)
+				enqueued = append(enqueued, queued)
+			}
+		}
 
-				if err := pool.Add(func() { kidCrawler.Run(ctx, client, pool, reports) }); err != nil {
-					pc.waiter.Done()
-				}
-			}(kid)
+		if len(enqueued) != 0 {
+			pc.spawnKids(ctx, pool, reports, enqueued)
+			return true
 		}
 	}
 }
 
+// enqueue returns the context giving target is crawled with, as returned by
+// the crawler's enqueue hook.
+func (pc PageCrawler) enqueue(ctx context.Context, target *url.URL, parent *LinkReport) context.Context {
+	if pc.OnEnqueue == nil {
+		return ctx
+	}
+	return pc.OnEnqueue(ctx, target, parent)
+}
+
+// deliver records giving report into the crawl's graph, sitemap seeds and
+// state before delivering it.
+func (pc PageCrawler) deliver(reports chan<- LinkReport, report LinkReport) {
+	pc.Graph.Record(report, !pc.child)
+	pc.Sitemap.record(report, !pc.child)
+	pc.State.result(report)
+	reports <- report
+}
+
+// withoutPages returns copies of giving reports without the pages farmed when
+// they were checked, so reports kept once crawled don't hold them in memory.
+func withoutPages(reports []LinkReport) []LinkReport {
+	if len(reports) == 0 {
+		return reports
+	}
+
+	copies := make([]LinkReport, len(reports))
+	for index, report := range reports {
+		report.page = nil
+		copies[index] = report
+	}
+	return copies
+}
+
 // CrawlBody starts the internal logic of the body crawler to retrieve all
 // internal routes of the target page. It takes into account all paths
 // that are relative to the target's root.
 // The crawler is strict in that it will only crawl path in the same host
 // as the root. So paths like web.monzo.com is not within root of monzo.com,
 // and will not be crawled.
-func CrawlBody(client *http.Client, target *url.URL, body io.Reader) ([]LinkReport, error) {
+// Status checks of links are run concurrently through the provided pool, if
+// any, and stop once the context is cancelled.
+func CrawlBody(ctx context.Context, client *http.Client, pool WorkerPool, target *url.URL, body io.Reader) ([]LinkReport, error) {
+	var pc PageCrawler
+	pc.scheduler = pc.newScheduler()
+	pc.fetch = pc.newFetcher(client)
+
+	reports, err := pc.checkLinks(ctx, pool, target, farmWithHTML(body, target))
+	return withoutPages(reports), err
+}
+
+// CheckLinks checks the status of all provided links lying within the scope of
+// the crawler's target without crawling them, such as urls listed by a sitemap.
+// Checks respect the crawler's rate, section and request settings and are run
+// concurrently through the provided pool, if any.
+func (pc PageCrawler) CheckLinks(ctx context.Context, client *http.Client, pool WorkerPool, links []*url.URL) ([]LinkReport, error) {
+	if pc.fetch == nil {
+		pc.fetch = pc.newFetcher(client)
+	}
+
+	if pc.sections == nil && len(pc.Sections) != 0 {
+		pc.sections = newSectionLimiter(pc.Sections)
+	}
+
+	if pc.scheduler == nil {
+		pc.scheduler = pc.newScheduler()
+	}
+
+	if pc.checks == nil {
+		pc.checks = newStatusScheduler()
+	}
+
+	if pc.ramp == nil && pc.RampUp.Max > 0 {
+		pc.ramp = newRampLimiter(pc.RampUp, time.Now())
+	}
+
+	set := make(map[*url.URL]linkContext, len(links))
+	for index, link := range links {
+		set[link] = linkContext{Index: index}
+	}
+
+	reports, err := pc.checkLinks(ctx, pool, pc.Target, set)
+	return withoutPages(reports), err
+}
+
+// checkLinks checks the status of all links lying within the crawl's scope of
+// target, respecting the crawler's section and rate restrictions. Checks are handed
+// to idle workers of the pool, else run by the caller, so checking never
+// waits on workers busy crawling pages. Reports are returned in the order the
+// links were found.
+func (pc PageCrawler) checkLinks(ctx context.Context, pool WorkerPool, target *url.URL, links map[*url.URL]linkContext) ([]LinkReport, error) {
+	var waiter sync.WaitGroup
+
+	ordered := orderedLinks(links)
+	for _, link := range ordered {
+		pc.sessions.Observe(link)
+	}
+
+	// Reports are written into the slot of their link, as checks complete
+	// in any order.
+	var slots int
+	results := make([]LinkReport, len(ordered))
+
+	checked := map[string]bool{}
+	for _, link := range ordered {
+		linkCtx := links[link]
+		link = Normalize(link)
+		if !pc.inScope(target, link) {
+			continue
+		}
+
+		// Check links differing only by session parameters once.
+		link, stripped := pc.sessions.Strip(link)
+
+		link, dropped := pc.stripQuery(link)
+		if len(dropped) != 0 {
+			stripped = append(stripped, dropped...)
+			sort.Strings(stripped)
+		}
+		if checked[link.String()] {
+			continue
+		}
+		checked[link.String()] = true
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		slot := slots
+		slots++
+
+		if err := pc.Traps.Check(link); err != nil {
+			results[slot] = LinkReport{
+				Path:       link,
+				Position:   linkCtx.Position,
+				Rel:        linkCtx.Rel,
+				AnchorText: linkCtx.Text,
+				Heading:    linkCtx.Heading,
+				Origin:     linkCtx.Origin,
+				Stripped:   stripped,
+				Status:     Status{Reason: &Reason{Code: ReasonTrap, Message: err.Error()}, At: time.Now()},
+			}
+			continue
+		}
+
+		waiter.Add(1)
+
+		check := func(slot int, link *url.URL, linkCtx linkContext, stripped []string) func() {
+			return func() {
+				defer waiter.Done()
+
+				report := pc.checks.Check(link, func() LinkReport {
+					return pc.statusReport(ctx, link, pc.willCrawl(link) && pc.follows(linkCtx.Rel))
+				})
+				report.Position = linkCtx.Position
+				report.Rel = linkCtx.Rel
+				report.AnchorText = linkCtx.Text
+				report.Heading = linkCtx.Heading
+				report.Origin = linkCtx.Origin
+				report.Stripped = stripped
+
+				results[slot] = report
+			}
+		}(slot, link, linkCtx, stripped)
+
+		if pool == nil || !pool.TryAdd(check) {
+			check()
+		}
+	}
+
+	waiter.Wait()
+
 	var kids []LinkReport
+	if slots != 0 {
+		kids = results[:slots]
+	}
+	return kids, ctx.Err()
+}
+
+// statusReport returns the report of giving target, respecting the restrictions
+// of the target's section, the crawl's ramp up and host rate. Targets to be
+// crawled or primed are requested with a single GET, farming the page into the
+// report, others with a HEAD.
+func (pc PageCrawler) statusReport(ctx context.Context, target *url.URL, crawl bool) (report LinkReport) {
+	release, err := pc.sections.Acquire(ctx, target.Path)
+	if err != nil {
+		return failedReport(target, err)
+	}
+
+	defer release()
+
+	done, err := pc.ramp.Acquire(ctx)
+	if err != nil {
+		return failedReport(target, err)
+	}
+
+	defer func() {
+		done(isOriginError(report.Status.LastStatus))
+	}()
+
+	for attempt := 0; ; attempt++ {
+		if err := pc.scheduler.Wait(ctx, target); err != nil {
+			return failedReport(target, err)
+		}
+
+		method := http.MethodHead
+		if crawl || pc.primes(target) {
+			method = http.MethodGet
+		}
+
+		reqCtx, cancel, responded := pc.pageContext(ctx)
+
+		req, err := pc.newRequest(reqCtx, method, target)
+		if err != nil {
+			cancel()
+			return failedReport(target, err)
+		}
+
+		if crawl && !pc.PrimeCache {
+			pc.Cache.Condition(req)
+		}
+
+		// If host asked for a pause, then retry once after it.
+		report, paused := pc.getURLReport(req, responded)
+		cancel()
 
-	links := farmWithHTML(body, target)
-	for link := range links {
-		if link.Host != target.Host {
+		if paused && attempt == 0 {
 			continue
 		}
 
-		kids = append(kids, LinkReport{
-			Path:   link,
-			Status: getURLStatus(client, link),
-		})
+		if method == http.MethodGet && pc.PrimeCache && report.Status.IsLive {
+			final := target
+			if report.RedirectedTo != nil {
+				final = report.RedirectedTo
+			}
+			report.CacheAfter = pc.probeCache(ctx, final)
+		}
+
+		// Sniff the content of generic or missing content types, as servers
+		// often omit or misreport the type of html pages.
+		if reason := report.Status.Reason; method == http.MethodHead && reason != nil && reason.Code == ReasonNonHTML && needsSniff(report.ContentType) {
+			final := target
+			if report.RedirectedTo != nil {
+				final = report.RedirectedTo
+			}
+
+			report.SniffedType = pc.sniffType(ctx, final)
+			if isHTMLType(report.SniffedType) {
+				report.Status.IsCrawlable = true
+				report.Status.Reason = nil
+			}
+		}
+
+		return report
+	}
+}
+
+// stripQuery returns a copy of giving link without it's tracking parameters and
+// the parameters the query policy strips, and the names of the parameters
+// stripped ordered by name.
+func (pc PageCrawler) stripQuery(link *url.URL) (*url.URL, []string) {
+	link, tracking := stripTracking(link, pc.KeepTrackingParams, pc.TrackingParams)
+	link, stripped := pc.QueryPolicy.Apply(link)
+	if len(tracking) == 0 {
+		return link, stripped
+	}
+
+	stripped = append(stripped, tracking...)
+	sort.Strings(stripped)
+	return link, stripped
+}
+
+// willCrawl returns true if giving link is expected to be crawled after it's
+// status is checked, being unseen and within the maximum depth.
+func (pc PageCrawler) willCrawl(link *url.URL) bool {
+	// Without a seen set, links are only being checked such as by CrawlBody.
+	if pc.seen == nil {
+		return false
+	}
+
+	if pc.MaxDepth > 0 && pc.current+1 >= pc.MaxDepth {
+		return false
+	}
+
+	// Assets are rarely html, so they are only checked.
+	if assetExtensions[strings.ToLower(path.Ext(link.Path))] {
+		return false
+	}
+
+	return !pc.seen.Has(seenKey(link))
+}
+
+// inScope returns true if giving link lies within the crawl's scope, relative
+// to the host of the crawl's target, else of giving target for links checked
+// without a crawl, or on one of the allowed hosts.
+func (pc PageCrawler) inScope(target *url.URL, link *url.URL) bool {
+	if pc.root != nil {
+		target = pc.root
+	}
+
+	if pc.Scope.Contains(target, link, pc.ScopeHosts) {
+		return true
+	}
+
+	host := strings.ToLower(link.Hostname())
+	for _, allowed := range pc.AllowHosts {
+		if matchHost(strings.ToLower(allowed), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// follows returns true if links of giving rel values are crawled.
+func (pc PageCrawler) follows(rel []string) bool {
+	if !pc.RespectNofollow {
+		return true
+	}
+
+	for _, value := range rel {
+		if value == "nofollow" {
+			return false
+		}
+	}
+	return true
+}
+
+// assetExtensions lists extensions of paths not expected to be html pages.
+var assetExtensions = map[string]bool{
+	".css": true, ".js": true, ".json": true, ".xml": true, ".txt": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".ico": true,
+	".pdf": true, ".zip": true, ".gz": true, ".mp3": true, ".mp4": true, ".webm": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".eot": true,
+}
+
+// fetchPage retrieves and farms the page of giving report's path, for pages
+// whose status was checked without retrieving them.
+func (pc PageCrawler) fetchPage(ctx context.Context, report *LinkReport) (pageDocument, error) {
+	fetchStart := time.Now()
+	res, err := pc.explore(ctx, pc.Target)
+	if err != nil {
+		return pageDocument{}, err
+	}
+
+	defer closeBody(res.Body)
+
+	report.Latency = time.Since(fetchStart)
+
+	// Restore unchanged pages from the cache, else farm the page's content.
+	if res.StatusCode == http.StatusNotModified {
+		if entry, page, ok := pc.Cache.restore(pc.Target.String()); ok {
+			report.Cached = true
+			report.ContentType = entry.ContentType
+			report.ContentLength = entry.ContentLength
+			return page, nil
+		}
+	}
+
+	report.ContentType = res.Header.Get("Content-Type")
+	report.ContentLength = res.ContentLength
+
+	page := pc.farmBody(report, res, pc.Target)
+	pc.Cache.Set(pc.Target.String(), newCacheEntry(res, page, report.ContentLength))
+	return page, nil
+}
+
+// explore retrieves the response of giving target for scanning, respecting the
+// restrictions of the target's section, the crawl's ramp up and host rate.
+func (pc PageCrawler) explore(ctx context.Context, target *url.URL) (res *http.Response, err error) {
+	release, err := pc.sections.Acquire(ctx, target.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	done, err := pc.ramp.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only failures to get a response are known here, as error statuses are
+	// not returned.
+	defer func() {
+		done(err != nil && !errors.Is(err, ErrNonHTMLURL) && !errors.Is(err, ErrPageFailed))
+	}()
+
+	for attempt := 0; ; attempt++ {
+		if err := pc.scheduler.Wait(ctx, target); err != nil {
+			return nil, err
+		}
+
+		reqCtx, cancel, responded := pc.pageContext(ctx)
+
+		req, err := pc.newRequest(reqCtx, http.MethodGet, target)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		if !pc.PrimeCache {
+			pc.Cache.Condition(req)
+		}
+
+		// If host asked for a pause, then retry once after it.
+		res, err := pc.exploreURL(req)
+		if err != nil {
+			cancel()
+		} else {
+			res.Body = cancelBody{ReadCloser: responded(res.Body), cancel: cancel}
+		}
+
+		if err != ErrHostPaused || attempt > 0 {
+			return res, err
+		}
+	}
+}
+
+// requestContext returns giving context bounded by the crawler's request
+// timeout, with a function to release it once the request is done.
+func (pc PageCrawler) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if pc.RequestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, pc.RequestTimeout)
+}
+
+// pageContext returns giving context bounded by the crawler's request timeout
+// for a request which may read a page, with a function to release it once the
+// request is done and one guarding the page's body once it's response
+// arrives. With a read timeout set, the latter lifts the request timeout and
+// bounds each read of the body by the read timeout instead.
+func (pc PageCrawler) pageContext(ctx context.Context) (context.Context, context.CancelFunc, func(io.ReadCloser) io.ReadCloser) {
+	if pc.ReadTimeout <= 0 || pc.RequestTimeout <= 0 {
+		reqCtx, cancel := pc.requestContext(ctx)
+		return reqCtx, cancel, func(body io.ReadCloser) io.ReadCloser {
+			return body
+		}
+	}
+
+	reqCtx, cancelCause := context.WithCancelCause(ctx)
+	deadline := time.AfterFunc(pc.RequestTimeout, func() {
+		cancelCause(context.DeadlineExceeded)
+	})
+
+	cancel := func() {
+		deadline.Stop()
+		cancelCause(nil)
+	}
+
+	return reqCtx, cancel, func(body io.ReadCloser) io.ReadCloser {
+		deadline.Stop()
+		return &readTimeoutBody{body: body, timeout: pc.ReadTimeout, cancel: cancel}
+	}
+}
+
+// newRequest returns a new request for giving target, identified with the
+// crawler's User-Agent. It fails with ErrBudgetExhausted once the crawl's
+// request budget is spent.
+func (pc PageCrawler) newRequest(ctx context.Context, method string, target *url.URL) (*http.Request, error) {
+	if !pc.Budget.Request() {
+		return nil, ErrBudgetExhausted
+	}
+
+	req, err := http.NewRequestWithContext(pc.Certificates.withTrace(pc.Connections.withTrace(ctx)), method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range pc.Headers {
+		req.Header[name] = append([]string(nil), values...)
 	}
 
-	return kids, nil
+	for _, cookie := range pc.Cookies {
+		req.AddCookie(cookie)
+	}
+
+	userAgent := pc.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	// Primed requests must be answered from and stored into caches.
+	if pc.PrimeCache {
+		req.Header.Del("Cache-Control")
+		req.Header.Del("Pragma")
+	}
+
+	// Negotiate compression explicitly, decoding bodies ourselves so their
+	// encoded and decoded sizes can be reported.
+	if method != http.MethodHead && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	return req, nil
+}
+
+// failedReport returns the report for giving target which failed to be
+// requested due to provided error.
+func failedReport(target *url.URL, err error) LinkReport {
+	return LinkReport{
+		Path: target,
+		Status: Status{
+			Reason:     NewReason(err),
+			At:         time.Now(),
+			LastStatus: http.StatusInternalServerError,
+		},
+	}
 }
 
-func getURLStatus(client *http.Client, target *url.URL) Status {
+// getURLReport returns the report of giving target's status and metadata
+// retrieved with the provided request, whose body is guarded by responded once
+// it's response arrives. It returns true if the host's requests were paused by
+// a Retry-After of the response.
+func (pc PageCrawler) getURLReport(req *http.Request, responded func(io.ReadCloser) io.ReadCloser) (LinkReport, bool) {
 	now := time.Now()
-	res, err := client.Head(target.String())
+	target := req.URL
+	report := LinkReport{Path: target}
+
+	res, hops, err := followRedirects(pc.fetch, withRemoteTrace(req, &report), pc.allowsRedirect, pc.maxRedirects())
+	report.Latency = time.Since(now)
+	report.Redirects = hops
+	report.LongRedirect = len(hops) > pc.redirectChainLimit()
 	if err != nil {
-		return Status{
-			Reason:     err,
+		report.Status = Status{
+			Reason:     NewReason(err),
 			At:         now,
 			LastStatus: http.StatusInternalServerError,
 		}
+
+		if len(hops) != 0 {
+			report.Status.LastStatus = hops[len(hops)-1].Status
+		}
+		return report, false
+	}
+
+	res.Body = responded(res.Body)
+	defer closeBody(res.Body)
+
+	report.Server = res.Header.Get("Server")
+
+	if len(hops) != 0 {
+		report.RedirectedTo = Normalize(res.Request.URL)
+	}
+
+	// Record redirects not allowed by the policy as live but uncrawlable
+	// links pointing to their location.
+	if location, err := res.Location(); err == nil && isRedirect(res.StatusCode) {
+		report.RedirectedTo = Normalize(location)
+		report.Status = Status{
+			At:         now,
+			IsLive:     true,
+			LastStatus: res.StatusCode,
+		}
+		return report, false
+	}
+
+	report.ContentType = res.Header.Get("Content-Type")
+	report.ContentLength = res.ContentLength
+	report.Status = responseStatus(res, now)
+
+	if req.Method == http.MethodGet {
+		pc.readPage(&report, req, res)
+	}
+
+	// Primed bodies are read whole, as caches only store responses fully
+	// transferred.
+	if pc.PrimeCache && req.Method == http.MethodGet {
+		report.CacheBefore = cacheStatus(res.Header)
+		drainBody(res.Body, pc.maxBodySize())
+	}
+
+	return report, pc.scheduler.Observe(res)
+}
+
+// readPage farms the page of giving GET response into the report if it's
+// crawlable, restoring unchanged pages from the cache and sniffing generic
+// content types. Pages redirecting out of the crawl's scope are not farmed as
+// they won't be crawled.
+func (pc PageCrawler) readPage(report *LinkReport, req *http.Request, res *http.Response) {
+	if res.StatusCode == http.StatusNotModified {
+		entry, page, ok := pc.Cache.restore(req.URL.String())
+		if !ok {
+			return
+		}
+
+		report.Cached = true
+		report.ContentType = entry.ContentType
+		report.ContentLength = entry.ContentLength
+		report.Status = Status{
+			At:          report.Status.At,
+			IsLive:      true,
+			IsCrawlable: true,
+			LastStatus:  res.StatusCode,
+		}
+		report.page = &page
+		return
+	}
+
+	if reason := report.Status.Reason; reason != nil && reason.Code == ReasonNonHTML && needsSniff(report.ContentType) {
+		report.SniffedType = peekType(res)
+		if isHTMLType(report.SniffedType) {
+			report.Status.IsCrawlable = true
+			report.Status.Reason = nil
+		}
+	}
+
+	if !report.Status.IsCrawlable || !pc.inScope(req.URL, res.Request.URL) {
+		return
 	}
 
+	page := pc.farmBody(report, res, res.Request.URL)
+	report.page = &page
+	pc.Cache.Set(req.URL.String(), newCacheEntry(res, page, report.ContentLength))
+}
+
+// farmBody farms the page of giving response's body, reading no more than the
+// crawler's maximum body size and flagging the report as truncated if the body
+// goes beyond it. Reports of unknown content length get the bytes read.
+func (pc PageCrawler) farmBody(report *LinkReport, res *http.Response, target *url.URL) pageDocument {
+	limit := pc.maxBodySize()
+	body := &countingReader{r: io.LimitReader(res.Body, limit)}
+	page := farmDocument(body, target)
+	page.Robots = headerRobots(page.Robots, res.Header)
+
+	// Pages not declaring their language fall back to the language the server
+	// declares them in.
+	if page.Language == "" {
+		page.Language = strings.TrimSpace(strings.Split(res.Header.Get("Content-Language"), ",")[0])
+	}
+
+	if body.n == limit {
+		var probe [1]byte
+		if n, _ := io.ReadFull(res.Body, probe[:]); n != 0 {
+			report.Truncated = true
+			page.Warnings = append(page.Warnings, fmt.Sprintf("body truncated at %d bytes", limit))
+		}
+	}
+
+	if report.ContentLength < 0 {
+		report.ContentLength = body.n
+	}
+
+	recordEncoding(report, res.Body)
+
+	return page
+}
+
+// responseStatus returns the Status for giving response received at provided time.
+func responseStatus(res *http.Response, now time.Time) Status {
 	if res.StatusCode < 200 || res.StatusCode > 299 {
 		return Status{
 			At:         now,
 			LastStatus: res.StatusCode,
-			Reason:     ErrPageFailed,
+			Reason:     NewReason(ErrPageFailed),
 		}
 	}
 
-	if !strings.Contains(res.Header.Get("Content-Type"), "text/html") &&
-		!strings.Contains(res.Header.Get("Content-Type"), "text/xhtml") {
+	if !isHTMLType(res.Header.Get("Content-Type")) {
 		return Status{
 			At:         now,
 			IsLive:     true,
 			LastStatus: res.StatusCode,
-			Reason:     ErrNonHTMLURL,
+			Reason:     NewReason(ErrNonHTMLURL),
 		}
 	}
 
@@ -264,141 +1435,149 @@ func getURLStatus(client *http.Client, target *url.URL) Status {
 
 // exploreURL attempts to retrieve content of path and validate that path is a valid html
 // link which can be crawled.
-func exploreURL(client *http.Client, target *url.URL) (io.ReadCloser, error) {
-	res, err := client.Get(target.String())
+func (pc PageCrawler) exploreURL(req *http.Request) (*http.Response, error) {
+	res, _, err := followRedirects(pc.fetch, req, pc.allowsRedirect, pc.maxRedirects())
 	if err != nil {
 		return nil, err
 	}
 
+	if res.StatusCode == http.StatusNotModified && req.Header.Get("If-None-Match")+req.Header.Get("If-Modified-Since") != "" {
+		return res, nil
+	}
+
 	if res.StatusCode < 200 || res.StatusCode > 299 {
+		closeBody(res.Body)
+
+		if pc.scheduler.Observe(res) {
+			return nil, ErrHostPaused
+		}
 		return nil, ErrPageFailed
 	}
 
-	if !strings.Contains(res.Header.Get("Content-Type"), "text/html") &&
-		!strings.Contains(res.Header.Get("Content-Type"), "text/xhtml") {
+	// Sniff the content of generic or missing content types to decide
+	// crawlability.
+	if contentType := res.Header.Get("Content-Type"); !isHTMLType(contentType) &&
+		(!needsSniff(contentType) || !isHTMLType(peekType(res))) {
+		closeBody(res.Body)
 		return nil, ErrNonHTMLURL
 	}
 
-	return res.Body, nil
+	return res, nil
 }
 
-// farmWithGoquery takes a given url and retrieves the needed links associated with
-// that URL.
-func farmWithGoquery(content io.Reader, rootURL *url.URL) (map[*url.URL]struct{}, error) {
-	doc, err := goquery.NewDocumentFromReader(content)
-	if err != nil {
-		return nil, err
+// allowsRedirect returns true if the redirect from giving url to location is
+// followed, per the redirect policy and, for redirects leading out of the
+// crawl's scope, the external redirect policy.
+func (pc PageCrawler) allowsRedirect(from *url.URL, location *url.URL) bool {
+	if !pc.RedirectPolicy.Allows(from, location) {
+		return false
 	}
 
-	urlMap := make(map[*url.URL]struct{}, 0)
+	target := pc.root
+	if target == nil {
+		target = pc.Target
+	}
 
-	// Collect all href links within the document. This way we can capture
-	// external,internal and stylesheets within the page.
-	hrefs := doc.Find("[href]")
-	for i := 0; i < hrefs.Length(); i++ {
-		if item, ok := getAttr(hrefs.Get(i).Attr, "href"); ok {
-			trimmedPath := strings.TrimSpace(item.Val)
-			if !strings.Contains(trimmedPath, "javascript:void(0)") {
-				if parsedPath, err := parsePath(trimmedPath, rootURL); err == nil {
-					urlMap[parsedPath] = struct{}{}
-				}
-			}
-		}
+	if target == nil || pc.inScope(target, location) {
+		return true
 	}
 
-	// Collect all src links within the document. This way we can capture
-	// external,internal and stylesheets within the page.
-	srcs := doc.Find("[src]")
-	for i := 0; i < srcs.Length(); i++ {
-		if item, ok := getAttr(srcs.Get(i).Attr, "src"); ok {
-			trimmedPath := strings.TrimSpace(item.Val)
-			if !strings.Contains(trimmedPath, "javascript:void(0)") {
-				if parsedPath, err := parsePath(trimmedPath, rootURL); err == nil {
-					urlMap[parsedPath] = struct{}{}
-				}
-			}
-		}
+	switch pc.ExternalRedirectPolicy {
+	case ExternalRedirectNone:
+		return false
+	case ExternalRedirectFirstHop:
+		return pc.inScope(target, from)
 	}
+	return true
+}
 
-	return urlMap, nil
+// maxRedirects returns the maximum redirects followed for a url.
+func (pc PageCrawler) maxRedirects() int {
+	if pc.MaxRedirects <= 0 {
+		return defaultMaxRedirects
+	}
+	return pc.MaxRedirects
 }
 
-func farmWithHTML(content io.Reader, rootURL *url.URL) map[*url.URL]struct{} {
-	tokenizer := html.NewTokenizer(content)
-	urlMap := make(map[*url.URL]struct{}, 0)
+// newHostLimiter returns the HostLimiter applying the crawler's rate.
+func (pc PageCrawler) newHostLimiter() *HostLimiter {
+	limiter := NewHostLimiter(pc.Rate)
+	if pc.RateByIP {
+		limiter.KeyByIP(pc.resolver())
+	}
+	limiter.Exempt(pc.OwnHosts...)
+	return limiter
+}
 
-	for {
-		switch tokenizer.Next() {
-		case html.ErrorToken:
-			return urlMap
-		case html.CommentToken:
-			continue
-		case html.SelfClosingTagToken, html.StartTagToken:
-			token := tokenizer.Token()
+// ownsHost returns true if giving host is owned by the crawl's operator.
+func (pc PageCrawler) ownsHost(host string) bool {
+	patterns := make([]string, len(pc.OwnHosts))
+	for index, pattern := range pc.OwnHosts {
+		patterns[index] = strings.ToLower(strings.TrimSpace(pattern))
+	}
+	return matchesHost(patterns, host)
+}
 
-			// if we dont have any attribute then skip.
-			if len(token.Attr) == 0 {
-				continue
-			}
+// resolver returns the resolver hosts are resolved with.
+func (pc PageCrawler) resolver() HostResolver {
+	if pc.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return pc.Resolver
+}
 
-			for _, attr := range token.Attr {
-				switch strings.ToLower(attr.Key) {
-				case "href":
-					if strings.Contains(attr.Val, "javascript:void(0)") {
-						continue
-					}
-
-					if parsedPath, err := parsePath(attr.Val, rootURL); err == nil {
-						urlMap[parsedPath] = struct{}{}
-					}
-				case "src":
-					if strings.Contains(attr.Val, "javascript:void(0)") {
-						continue
-					}
-
-					if parsedPath, err := parsePath(attr.Val, rootURL); err == nil {
-						urlMap[parsedPath] = struct{}{}
-					}
-				case "srcset":
-					for _, item := range strings.Split(attr.Val, ",") {
-						if strings.Contains(item, "javascript:void(0)") {
-							continue
-						}
-
-						if parsedPath, err := parsePath(item, rootURL); err == nil {
-							urlMap[parsedPath] = struct{}{}
-						}
-					}
-				}
-			}
-		}
+// maxBodySize returns the maximum bytes read of a page's body.
+func (pc PageCrawler) maxBodySize() int64 {
+	if pc.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
 	}
+	return pc.MaxBodySize
+}
 
-	return urlMap
+// redirectChainLimit returns the redirect chain length beyond which a url
+// is flagged.
+func (pc PageCrawler) redirectChainLimit() int {
+	if pc.RedirectChainLimit <= 0 {
+		return defaultRedirectChainLimit
+	}
+	return pc.RedirectChainLimit
 }
 
-// getAttr returns the giving attribute for a specific name type if found.
-func getAttr(attrs []html.Attribute, key string) (attr html.Attribute, found bool) {
-	for _, attr = range attrs {
-		if attr.Key == key {
-			found = true
-			return
-		}
+// canonicalMismatch returns true if giving canonical url of a page points to
+// another url than the page, differing in more than a trailing slash.
+func canonicalMismatch(page *url.URL, canonical *url.URL) bool {
+	if canonical == nil {
+		return false
 	}
-	return
+	return !strings.EqualFold(canonical.Scheme, page.Scheme) || seenKey(canonical) != seenKey(page)
 }
 
-// parsePath re-evaluates a giving path string using a root URL path, else
-// returns an error if it fails to validate path as a valid url.
-func parsePath(path string, index *url.URL) (*url.URL, error) {
-	pathURI, err := url.Parse(path)
-	if err != nil {
-		return nil, err
+// seenKey returns the key used to mark giving url as seen, being it's
+// normalized host, path and query, keyed by host as crawls may span many hosts
+// of their scope. Trailing slashes and schemes are ignored.
+func seenKey(target *url.URL) string {
+	normalized := Normalize(target)
+
+	trimmed := strings.TrimSuffix(normalized.EscapedPath(), "/")
+	if trimmed == "" {
+		trimmed = "/"
 	}
 
-	if !pathURI.IsAbs() {
-		pathURI = index.ResolveReference(pathURI)
+	if normalized.RawQuery != "" {
+		return normalized.Host + trimmed + "?" + normalized.RawQuery
 	}
+	return normalized.Host + trimmed
+}
+
+// countingReader wraps a io.Reader counting total bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
 
-	return pathURI, nil
+// Read implements the io.Reader interface.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
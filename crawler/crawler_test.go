@@ -2,17 +2,30 @@ package crawler_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"context"
 
+	"github.com/andybalholm/brotli"
 	"github.com/influx6/faux/tests"
 	"github.com/influx6/sitecrawler/crawler"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
 )
 
 var (
@@ -109,7 +122,7 @@ func TestBodyCrawler(t *testing.T) {
 
 	tests.Header("When farming links from index page")
 	{
-		links, err := crawler.CrawlBody(baseClient, target, bytes.NewReader(indexPage))
+		links, err := crawler.CrawlBody(context.Background(), baseClient, nil, target, bytes.NewReader(indexPage))
 		if err != nil {
 			tests.FailedWithError(err, "Should have successfully scanned page")
 		}
@@ -125,7 +138,7 @@ func TestBodyCrawler(t *testing.T) {
 
 	tests.Header("When farming links from service page")
 	{
-		links, err := crawler.CrawlBody(baseClient, target, bytes.NewReader(servicePage))
+		links, err := crawler.CrawlBody(context.Background(), baseClient, nil, target, bytes.NewReader(servicePage))
 		if err != nil {
 			tests.FailedWithError(err, "Should have successfully scanned page")
 		}
@@ -141,7 +154,7 @@ func TestBodyCrawler(t *testing.T) {
 
 	tests.Header("When farming links from contacts page")
 	{
-		links, err := crawler.CrawlBody(baseClient, target, bytes.NewReader(contactPage))
+		links, err := crawler.CrawlBody(context.Background(), baseClient, nil, target, bytes.NewReader(contactPage))
 		if err != nil {
 			tests.FailedWithError(err, "Should have successfully scanned page")
 		}
@@ -155,6 +168,37 @@ func TestBodyCrawler(t *testing.T) {
 		tests.Passed("Should have farmed out 3 links from page")
 	}
 
+	tests.Header("When checking links through a worker pool")
+	{
+		pool := crawler.NewWorkerPool(10, context.Background())
+		defer pool.Stop()
+
+		links, err := crawler.CrawlBody(context.Background(), baseClient, pool, target, bytes.NewReader(contactPage))
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully scanned page")
+		}
+		tests.Passed("Should have successfully scanned page")
+
+		if total := len(links); total != 3 {
+			tests.Info("Expected Links: %d", 3)
+			tests.Info("Received Links: %d", total)
+			tests.Failed("Should have farmed out 3 links from page")
+		}
+		tests.Passed("Should have farmed out 3 links from page")
+	}
+
+	tests.Header("When context is cancelled")
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := crawler.CrawlBody(ctx, baseClient, nil, target, bytes.NewReader(contactPage)); err != context.Canceled {
+			tests.Info("Received Error: %+s", err)
+			tests.Failed("Should have stopped scanning with cancelled context")
+		}
+		tests.Passed("Should have stopped scanning with cancelled context")
+	}
+
 }
 
 type testHandler struct{}
@@ -190,3 +234,3418 @@ func (t testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusBadRequest)
 }
+
+func TestPageCrawlerDiscoveries(t *testing.T) {
+	server := httptest.NewServer(testHandler{})
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.MaxDepth = -1
+	pages.Discoveries = crawler.NewDiscoveryStats()
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	for range reports {
+	}
+
+	top := pages.Discoveries.Top(1)
+	if len(top) != 1 || top[0].URL != server.URL+"/services" {
+		tests.Info("Received Top: %#v", top)
+		tests.Failed("Should have found /services as most redundantly linked url")
+	}
+	tests.Passed("Should have found /services as most redundantly linked url")
+
+	if top[0].Count != 3 {
+		tests.Info("Expected Count: %d", 3)
+		tests.Info("Received Count: %d", top[0].Count)
+		tests.Failed("Should have discovered /services 3 times")
+	}
+	tests.Passed("Should have discovered /services 3 times")
+
+	if len(top[0].Sources) != 3 {
+		tests.Info("Received Sources: %q", top[0].Sources)
+		tests.Failed("Should have recorded the pages /services was discovered from")
+	}
+	tests.Passed("Should have recorded the pages /services was discovered from")
+
+	if pages.Discoveries.Ratio() <= 0 {
+		tests.Failed("Should have a positive dedup ratio")
+	}
+	tests.Passed("Should have a positive dedup ratio")
+}
+
+func TestDiscoveryStatsSources(t *testing.T) {
+	discoveries := crawler.NewDiscoveryStats()
+	for index := 0; index < crawler.MaxDiscoverySources+3; index++ {
+		discoveries.Record("https://example.com/", "https://example.com/a")
+		discoveries.Record("https://example.com/", fmt.Sprintf("https://example.com/%d", index))
+	}
+
+	top := discoveries.Top(1)
+	if len(top) != 1 || top[0].Count != 2*(crawler.MaxDiscoverySources+3) {
+		tests.Info("Received Top: %#v", top)
+		tests.Failed("Should have counted every discovery of url")
+	}
+	tests.Passed("Should have counted every discovery of url")
+
+	sources := top[0].Sources
+	if len(sources) != crawler.MaxDiscoverySources || sources[0] != "https://example.com/a" || sources[1] != "https://example.com/0" || sources[2] != "https://example.com/1" {
+		tests.Info("Received Sources: %q", sources)
+		tests.Failed("Should have kept the first distinct sources of url up to the limit")
+	}
+	tests.Passed("Should have kept the first distinct sources of url up to the limit")
+}
+
+func TestStatusReasonSerialization(t *testing.T) {
+	status := crawler.Status{
+		LastStatus: http.StatusNotFound,
+		Reason:     crawler.NewReason(crawler.ErrPageFailed),
+	}
+
+	encoded, err := json.Marshal(status)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully marshalled status")
+	}
+	tests.Passed("Should have successfully marshalled status")
+
+	if !bytes.Contains(encoded, []byte(`"reason":{"code":"http-status","message":"url path failed to respond, possible dead"}`)) {
+		tests.Info("Received JSON: %s", encoded)
+		tests.Failed("Should have serialized reason with code and message")
+	}
+	tests.Passed("Should have serialized reason with code and message")
+
+	encoded, err = json.Marshal(crawler.Status{IsLive: true})
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully marshalled status")
+	}
+	tests.Passed("Should have successfully marshalled status")
+
+	if bytes.Contains(encoded, []byte(`"reason"`)) {
+		tests.Info("Received JSON: %s", encoded)
+		tests.Failed("Should have omitted empty reason")
+	}
+	tests.Passed("Should have omitted empty reason")
+}
+
+func TestReasonClassification(t *testing.T) {
+	_, err := baseClient.Head("http://sitecrawler.invalid/")
+	if reason := crawler.NewReason(err); reason == nil || reason.Code != crawler.ReasonDNS {
+		tests.Info("Received Reason: %#v", reason)
+		tests.Failed("Should have classified lookup failure as dns reason")
+	}
+	tests.Passed("Should have classified lookup failure as dns reason")
+
+	if reason := crawler.NewReason(crawler.ErrNonHTMLURL); reason.Code != crawler.ReasonNonHTML {
+		tests.Info("Received Reason: %#v", reason)
+		tests.Failed("Should have classified non-html error")
+	}
+	tests.Passed("Should have classified non-html error")
+}
+
+func TestPageCrawlerRequestHeaders(t *testing.T) {
+	headers := make(chan http.Header, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		testHandler{}.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.UserAgent = "mombo-bot/1.0"
+	pages.Headers = http.Header{"X-Api-Key": []string{"abc"}}
+	pages.Cookies = []*http.Cookie{{Name: "bucket", Value: "b"}}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	for range reports {
+	}
+
+	close(headers)
+	for header := range headers {
+		if header.Get("User-Agent") != "mombo-bot/1.0" {
+			tests.Info("Received User-Agent: %q", header.Get("User-Agent"))
+			tests.Failed("Should have identified all requests with provided User-Agent")
+		}
+
+		if header.Get("X-Api-Key") != "abc" {
+			tests.Info("Received X-Api-Key: %q", header.Get("X-Api-Key"))
+			tests.Failed("Should have sent provided headers on all requests")
+		}
+
+		if header.Get("Cookie") != "bucket=b" {
+			tests.Info("Received Cookie: %q", header.Get("Cookie"))
+			tests.Failed("Should have sent provided cookies on all requests")
+		}
+	}
+	tests.Passed("Should have sent User-Agent, headers and cookies on all requests")
+}
+
+func TestPageCrawlerDeduplicatesStatusChecks(t *testing.T) {
+	var ml sync.Mutex
+	heads := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			ml.Lock()
+			heads[r.URL.Path]++
+			ml.Unlock()
+		}
+		testHandler{}.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	for range reports {
+	}
+
+	for path, total := range heads {
+		if total != 1 {
+			tests.Info("Path: %q", path)
+			tests.Info("Received Checks: %d", total)
+			tests.Failed("Should have checked status of path only once")
+		}
+	}
+	tests.Passed("Should have checked status of all paths only once")
+}
+
+func TestRedirectAudit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/old-services"></a><a href="/old-contacts"></a><a href="/contacts"></a>`))
+		case "/old-services":
+			http.Redirect(w, r, "/services", http.StatusMovedPermanently)
+		case "/old-contacts":
+			http.Redirect(w, r, "/contacts", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	audit := crawler.NewRedirectAudit()
+	for report := range reports {
+		audit.Observe(report)
+	}
+
+	redirected := audit.RedirectOnly()
+	if len(redirected) != 1 || redirected[0].URL != server.URL+"/services" {
+		tests.Info("Received Pages: %#v", redirected)
+		tests.Failed("Should have found /services as only reached through redirects")
+	}
+	tests.Passed("Should have found /services as only reached through redirects")
+
+	if len(redirected[0].Via) != 1 || redirected[0].Via[0] != server.URL+"/old-services" {
+		tests.Info("Received Via: %#v", redirected[0].Via)
+		tests.Failed("Should have recorded /old-services as redirect source")
+	}
+	tests.Passed("Should have recorded /old-services as redirect source")
+}
+
+func TestRedirectChains(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/a"></a><a href="/loop"></a>`))
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/c":
+			http.Redirect(w, r, "/final", http.StatusTemporaryRedirect)
+		case "/loop":
+			http.Redirect(w, r, "/loop-back", http.StatusFound)
+		case "/loop-back":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.RedirectChainLimit = 2
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var root crawler.LinkReport
+	crawled := map[string]bool{}
+	for report := range reports {
+		crawled[report.Path.Path] = true
+		if report.Path.Path == "/" {
+			root = report
+		}
+	}
+
+	links := map[string]crawler.LinkReport{}
+	for _, kid := range root.PointsTo {
+		links[kid.Path.Path] = kid
+	}
+
+	chain := links["/a"]
+	if len(chain.Redirects) != 3 || chain.Redirects[0].Status != http.StatusMovedPermanently ||
+		chain.Redirects[2].URL != server.URL+"/c" || chain.Redirects[2].Status != http.StatusTemporaryRedirect {
+		tests.Info("Received Hops: %#v", chain.Redirects)
+		tests.Failed("Should have recorded each hop of redirect chain")
+	}
+	tests.Passed("Should have recorded each hop of redirect chain")
+
+	if !chain.LongRedirect {
+		tests.Failed("Should have flagged redirect chain longer than limit")
+	}
+	tests.Passed("Should have flagged redirect chain longer than limit")
+
+	if chain.RedirectedTo == nil || chain.RedirectedTo.Path != "/final" || !crawled["/final"] || crawled["/a"] {
+		tests.Info("Received Pages: %#v", crawled)
+		tests.Failed("Should have attributed redirect chain to final url")
+	}
+	tests.Passed("Should have attributed redirect chain to final url")
+
+	loop := links["/loop"]
+	if loop.Status.Reason == nil || loop.Status.Reason.Code != crawler.ReasonRedirect || loop.Status.IsLive {
+		tests.Info("Received Status: %#v", loop.Status)
+		tests.Failed("Should have flagged redirect loop")
+	}
+	tests.Passed("Should have flagged redirect loop")
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	var external int64
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&external, 1)
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer foreign.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/moved"></a><a href="/partner"></a>`))
+		case "/moved":
+			http.Redirect(w, r, "/services", http.StatusMovedPermanently)
+		case "/partner":
+			http.Redirect(w, r, foreign.URL+"/landing", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(policy crawler.RedirectPolicy) map[string]crawler.LinkReport {
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.RedirectPolicy = policy
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		links := map[string]crawler.LinkReport{}
+		for report := range reports {
+			if report.Path.Path != "/" {
+				continue
+			}
+			for _, kid := range report.PointsTo {
+				links[kid.Path.Path] = kid
+			}
+		}
+		return links
+	}
+
+	links := crawl(crawler.RedirectSameHost)
+	if atomic.LoadInt64(&external) != 0 {
+		tests.Failed("Should not have fetched off-host redirect")
+	}
+	tests.Passed("Should not have fetched off-host redirect")
+
+	partner := links["/partner"]
+	if partner.RedirectedTo == nil || partner.RedirectedTo.String() != foreign.URL+"/landing" ||
+		partner.Status.LastStatus != http.StatusMovedPermanently || partner.Status.IsCrawlable {
+		tests.Info("Received Report: %#v", partner)
+		tests.Failed("Should have recorded off-host redirect as external")
+	}
+	tests.Passed("Should have recorded off-host redirect as external")
+
+	if moved := links["/moved"]; moved.Status.LastStatus != http.StatusOK || len(moved.Redirects) != 1 {
+		tests.Info("Received Report: %#v", moved)
+		tests.Failed("Should have followed same-host redirect")
+	}
+	tests.Passed("Should have followed same-host redirect")
+
+	links = crawl(crawler.RedirectNone)
+	if moved := links["/moved"]; moved.Status.LastStatus != http.StatusMovedPermanently || moved.RedirectedTo.Path != "/services" {
+		tests.Info("Received Report: %#v", moved)
+		tests.Failed("Should not have followed any redirect")
+	}
+	tests.Passed("Should not have followed any redirect")
+
+	if _, err := crawler.ParseRedirectPolicy("elsewhere"); err == nil {
+		tests.Failed("Should have failed to parse unknown redirect policy")
+	}
+	tests.Passed("Should have failed to parse unknown redirect policy")
+}
+
+func TestExternalRedirectPolicy(t *testing.T) {
+	var external int64
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&external, 1)
+		switch r.URL.Path {
+		case "/landing":
+			http.Redirect(w, r, "/welcome", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer foreign.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/moved"></a><a href="/partner"></a>`))
+		case "/moved":
+			http.Redirect(w, r, "/services", http.StatusMovedPermanently)
+		case "/partner":
+			http.Redirect(w, r, foreign.URL+"/landing", http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(policy crawler.ExternalRedirectPolicy) map[string]crawler.LinkReport {
+		atomic.StoreInt64(&external, 0)
+
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.ExternalRedirectPolicy = policy
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		links := map[string]crawler.LinkReport{}
+		for report := range reports {
+			if report.Path.Path != "/" {
+				continue
+			}
+			for _, kid := range report.PointsTo {
+				links[kid.Path.Path] = kid
+			}
+		}
+		return links
+	}
+
+	links := crawl(crawler.ExternalRedirectNone)
+	if atomic.LoadInt64(&external) != 0 {
+		tests.Failed("Should not have fetched redirect out of scope")
+	}
+	tests.Passed("Should not have fetched redirect out of scope")
+
+	if partner := links["/partner"]; partner.RedirectedTo == nil || partner.RedirectedTo.String() != foreign.URL+"/landing" {
+		tests.Info("Received Report: %#v", partner)
+		tests.Failed("Should have recorded redirect out of scope with it's location")
+	}
+	tests.Passed("Should have recorded redirect out of scope with it's location")
+
+	if moved := links["/moved"]; moved.Status.LastStatus != http.StatusOK || len(moved.Redirects) != 1 {
+		tests.Info("Received Report: %#v", moved)
+		tests.Failed("Should have followed internal redirect")
+	}
+	tests.Passed("Should have followed internal redirect")
+
+	links = crawl(crawler.ExternalRedirectFirstHop)
+	if atomic.LoadInt64(&external) != 1 {
+		tests.Info("Received Fetches: %d", atomic.LoadInt64(&external))
+		tests.Failed("Should have only fetched first hop out of scope")
+	}
+	tests.Passed("Should have only fetched first hop out of scope")
+
+	if partner := links["/partner"]; partner.Status.LastStatus != http.StatusMovedPermanently ||
+		partner.RedirectedTo == nil || partner.RedirectedTo.String() != foreign.URL+"/welcome" {
+		tests.Info("Received Report: %#v", partner)
+		tests.Failed("Should have recorded redirect of external url without fetching it")
+	}
+	tests.Passed("Should have recorded redirect of external url without fetching it")
+
+	links = crawl(crawler.ExternalRedirectFollow)
+	if partner := links["/partner"]; partner.Status.LastStatus != http.StatusOK || len(partner.Redirects) != 2 {
+		tests.Info("Received Report: %#v", partner)
+		tests.Failed("Should have followed redirect chain out of scope")
+	}
+	tests.Passed("Should have followed redirect chain out of scope")
+
+	if _, err := crawler.ParseExternalRedirectPolicy("elsewhere"); err == nil {
+		tests.Failed("Should have failed to parse unknown external redirect policy")
+	}
+	tests.Passed("Should have failed to parse unknown external redirect policy")
+}
+
+func TestPageCrawlerValidatorCache(t *testing.T) {
+	var fetched int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/" {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			atomic.AddInt64(&fetched, 1)
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<title>Home</title><nav><a href="/services">Services</a></nav>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(cache *crawler.ValidatorCache) crawler.LinkReport {
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.Cache = cache
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		var root crawler.LinkReport
+		for report := range reports {
+			if report.Path.Path == "/" {
+				root = report
+			}
+		}
+		return root
+	}
+
+	first := crawler.NewValidatorCache()
+	crawl(first)
+
+	var saved bytes.Buffer
+	if err := first.Save(&saved); err != nil {
+		tests.FailedWithError(err, "Should have successfully saved cache")
+	}
+	tests.Passed("Should have successfully saved cache")
+
+	cache, err := crawler.LoadValidatorCache(&saved)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded cache")
+	}
+	tests.Passed("Should have successfully loaded cache")
+
+	root := crawl(cache)
+	if atomic.LoadInt64(&fetched) != 1 || !root.Cached || cache.Hits() != 1 {
+		tests.Info("Fetched: %d", atomic.LoadInt64(&fetched))
+		tests.Failed("Should have revalidated unchanged page with a conditional request")
+	}
+	tests.Passed("Should have revalidated unchanged page with a conditional request")
+
+	if root.Title != "Home" || len(root.PointsTo) != 1 || root.PointsTo[0].Position != crawler.PositionNav || root.PointsTo[0].AnchorText != "Services" {
+		tests.Info("Received Report: %#v", root)
+		tests.Failed("Should have restored unchanged page from cache")
+	}
+	tests.Passed("Should have restored unchanged page from cache")
+}
+
+func TestPageCrawlerSniffsContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/mislabeled"></a><a href="/data"></a>`))
+		case "/mislabeled":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte(`<!DOCTYPE html><html><body><a href="/hidden"></a></body></html>`))
+		case "/data":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(`name,value`))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.Path] = report
+	}
+
+	mislabeled := crawled["/mislabeled"]
+	if mislabeled.ContentType != "application/octet-stream" || !strings.HasPrefix(mislabeled.SniffedType, "text/html") {
+		tests.Info("Received Report: %#v", mislabeled)
+		tests.Failed("Should have recorded declared and sniffed content types")
+	}
+	tests.Passed("Should have recorded declared and sniffed content types")
+
+	if _, ok := crawled["/hidden"]; !ok || !mislabeled.Status.IsCrawlable {
+		tests.Failed("Should have crawled html page with generic content type")
+	}
+	tests.Passed("Should have crawled html page with generic content type")
+
+	var data crawler.LinkReport
+	for _, kid := range crawled["/"].PointsTo {
+		if kid.Path.Path == "/data" {
+			data = kid
+		}
+	}
+
+	if data.Status.IsCrawlable || data.Status.Reason == nil || data.Status.Reason.Code != crawler.ReasonNonHTML {
+		tests.Info("Received Report: %#v", data)
+		tests.Failed("Should not have crawled non-html page with generic content type")
+	}
+	tests.Passed("Should not have crawled non-html page with generic content type")
+}
+
+func TestPageCrawlerSingleRequestPerPage(t *testing.T) {
+	var ml sync.Mutex
+	requests := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ml.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		ml.Unlock()
+
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/services"></a><a href="/brochure.pdf"></a>`))
+		case "/services":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/"></a><a href="/contacts"></a>`))
+		case "/brochure.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var crawled int
+	for range reports {
+		crawled++
+	}
+
+	if crawled != 3 {
+		tests.Info("Received Pages: %d", crawled)
+		tests.Failed("Should have crawled all pages")
+	}
+	tests.Passed("Should have crawled all pages")
+
+	expected := map[string]int{
+		"GET /":              1,
+		"GET /services":      1,
+		"GET /contacts":      1,
+		"HEAD /brochure.pdf": 1,
+		"GET /robots.txt":    1,
+	}
+
+	for request, count := range requests {
+		if expected[request] != count {
+			tests.Info("Received Requests: %#v", requests)
+			tests.Failed("Should have requested each page once with a single GET")
+		}
+	}
+	tests.Passed("Should have requested each page once with a single GET")
+}
+
+func TestPageCrawlerReleasesPages(t *testing.T) {
+	const total, offsite = 200, 500
+
+	// Every page links to it's next pages and many off-site links, so the
+	// documents farmed from pages dwarf the reports kept of them.
+	var offsiteLinks bytes.Buffer
+	for index := 0; index < offsite; index++ {
+		fmt.Fprintf(&offsiteLinks, `<a href="https://elsewhere.example.com/a/long/path/to/page-%d?with=query">Link %d</a>`, index, index)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var index int
+		fmt.Sscanf(r.URL.Path, "/page-%d", &index)
+
+		w.Header().Set("Content-Type", "text/html")
+		for next := index*2 + 1; next <= index*2+2 && next < total; next++ {
+			fmt.Fprintf(w, `<a href="/page-%d"></a>`, next)
+		}
+		w.Write(offsiteLinks.Bytes())
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/page-0")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(50, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	pages, err = crawler.NewPageCrawler(pages)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created crawler")
+	}
+	tests.Passed("Should have successfully created crawler")
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var kept []crawler.LinkReport
+	for report := range reports {
+		kept = append(kept, report)
+	}
+
+	runtime.GC()
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	if len(kept) != total {
+		tests.Info("Received Pages: %d", len(kept))
+		tests.Failed("Should have crawled all pages")
+	}
+	tests.Passed("Should have crawled all pages")
+
+	// The crawler and reports are kept alive as applications keep them, so
+	// only documents they hold on to would remain.
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 16<<20 {
+		tests.Info("Received Heap Growth: %.1f MiB", float64(grown)/(1<<20))
+		tests.Failed("Should have released farmed pages once crawled")
+	}
+	tests.Passed("Should have released farmed pages once crawled")
+
+	runtime.KeepAlive(pages)
+	runtime.KeepAlive(kept)
+}
+
+func TestPageCrawlerCheckLinks(t *testing.T) {
+	var ml sync.Mutex
+	var methods []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ml.Lock()
+		methods = append(methods, r.Method)
+		ml.Unlock()
+
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer server.Close()
+
+	var links []*url.URL
+	for _, link := range []string{server.URL + "/", server.URL + "/gone", "http://example.com/"} {
+		parsed, err := url.Parse(link)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url")
+		}
+		links = append(links, parsed)
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	var pages crawler.PageCrawler
+	pages.Target = links[0]
+
+	reports, err := pages.CheckLinks(context.Background(), baseClient, nil, links)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully checked links")
+	}
+	tests.Passed("Should have successfully checked links")
+
+	if len(reports) != 2 {
+		tests.Info("Received Reports: %d", len(reports))
+		tests.Failed("Should have only checked links within target's host")
+	}
+	tests.Passed("Should have only checked links within target's host")
+
+	for _, report := range reports {
+		if report.Status.IsLive != (report.Path.Path != "/gone") {
+			tests.Info("Received Report: %q live: %t", report.Path.Path, report.Status.IsLive)
+			tests.Failed("Should have reported status of each link")
+		}
+	}
+	tests.Passed("Should have reported status of each link")
+
+	for _, method := range methods {
+		if method != http.MethodHead {
+			tests.Info("Received Methods: %#v", methods)
+			tests.Failed("Should have checked links without fetching them")
+		}
+	}
+	tests.Passed("Should have checked links without fetching them")
+}
+
+func TestPageCrawlerMaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			return
+		}
+
+		body := `<a href="/services"></a>` + strings.Repeat(" ", 1<<20) + `<a href="/contacts"></a>`
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.MaxBodySize = 1024
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var index crawler.LinkReport
+	for report := range reports {
+		if report.Path.Path == "/" {
+			index = report
+		}
+	}
+
+	if !index.Truncated {
+		tests.Failed("Should have flagged page beyond max body size as truncated")
+	}
+	tests.Passed("Should have flagged page beyond max body size as truncated")
+
+	if len(index.ParseWarnings) != 1 || index.ParseWarnings[0] != "body truncated at 1024 bytes" {
+		tests.Info("Received Warnings: %+q", index.ParseWarnings)
+		tests.Failed("Should have recorded truncation of page as parse warning")
+	}
+	tests.Passed("Should have recorded truncation of page as parse warning")
+
+	if len(index.PointsTo) != 1 || index.PointsTo[0].Path.Path != "/services" {
+		tests.Info("Received Links: %d", len(index.PointsTo))
+		tests.Failed("Should have only farmed links within max body size")
+	}
+	tests.Passed("Should have only farmed links within max body size")
+}
+
+func TestPageCrawlerReadTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		switch r.URL.Path {
+		case "/steady":
+			// Drip the body well past the request timeout, with no read
+			// stalling beyond the read timeout.
+			for chunk := 0; chunk < 8; chunk++ {
+				fmt.Fprint(w, `<p>steady</p>`)
+				w.(http.Flusher).Flush()
+				time.Sleep(50 * time.Millisecond)
+			}
+			fmt.Fprint(w, `<a href="/steady/found"></a>`)
+		case "/stalled":
+			fmt.Fprint(w, `<p>stalled</p>`)
+			w.(http.Flusher).Flush()
+			time.Sleep(400 * time.Millisecond)
+			fmt.Fprint(w, `<a href="/stalled/found"></a>`)
+		default:
+			fmt.Fprint(w, `<a href="/steady"></a><a href="/stalled"></a>`)
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+
+	crawl := func(readTimeout time.Duration) map[string]bool {
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.MaxDepth = -1
+		pages.RequestTimeout = 200 * time.Millisecond
+		pages.ReadTimeout = readTimeout
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		found := map[string]bool{}
+		for report := range reports {
+			for _, link := range report.PointsTo {
+				found[link.Path.Path] = true
+			}
+		}
+		return found
+	}
+
+	if found := crawl(0); found["/steady/found"] || found["/stalled/found"] {
+		tests.Info("Found: %#v", found)
+		tests.Failed("Should have cut short bodies outliving the request timeout")
+	}
+	tests.Passed("Should have cut short bodies outliving the request timeout")
+
+	found := crawl(150 * time.Millisecond)
+	if !found["/steady/found"] {
+		tests.Info("Found: %#v", found)
+		tests.Failed("Should have read steady body outliving the request timeout with a read timeout")
+	}
+	tests.Passed("Should have read steady body outliving the request timeout with a read timeout")
+
+	if found["/stalled/found"] {
+		tests.Info("Found: %#v", found)
+		tests.Failed("Should have abandoned body stalling beyond the read timeout")
+	}
+	tests.Passed("Should have abandoned body stalling beyond the read timeout")
+}
+
+func TestPageCrawlerRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+			http.Redirect(w, r, "/slower", http.StatusFound)
+			return
+		case "/slower":
+			time.Sleep(150 * time.Millisecond)
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<a href="/slow"></a><a href="/services"></a>`))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.RequestTimeout = 200 * time.Millisecond
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var index crawler.LinkReport
+	for report := range reports {
+		if report.Path.Path == "/" {
+			index = report
+		}
+	}
+
+	for _, link := range index.PointsTo {
+		switch link.Path.Path {
+		case "/slow":
+			if link.Status.Reason == nil || link.Status.Reason.Code != crawler.ReasonTimeout {
+				tests.Info("Received Status: %#v", link.Status)
+				tests.Failed("Should have timed out request whose redirects exceed the deadline")
+			}
+		case "/services":
+			if !link.Status.IsLive {
+				tests.Info("Received Status: %#v", link.Status)
+				tests.Failed("Should have checked request within the deadline")
+			}
+		}
+	}
+	tests.Passed("Should have bounded each request including it's redirects by the deadline")
+}
+
+func TestPageCrawlerContentEncoding(t *testing.T) {
+	page := `<title>Mumbo Jungle</title>` + strings.Repeat(`<p>mumbo jungle</p>`, 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+
+		accepted := r.Header.Get("Accept-Encoding")
+		switch {
+		case r.URL.Path == "/" && strings.Contains(accepted, "br"):
+			w.Header().Set("Content-Encoding", "br")
+			encoder := brotli.NewWriter(w)
+			encoder.Write([]byte(`<a href="/services"></a><a href="/contacts"></a>` + page))
+			encoder.Close()
+		case r.URL.Path == "/services" && strings.Contains(accepted, "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			encoder := gzip.NewWriter(w)
+			encoder.Write([]byte(page))
+			encoder.Close()
+		default:
+			w.Write([]byte(page))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.Path] = report
+	}
+
+	index := crawled["/"]
+	if index.ContentEncoding != "br" || len(index.PointsTo) != 2 || index.Title != "Mumbo Jungle" {
+		tests.Info("Received Report: %#v", index)
+		tests.Failed("Should have decoded brotli page before farming it")
+	}
+	tests.Passed("Should have decoded brotli page before farming it")
+
+	services := crawled["/services"]
+	if services.ContentEncoding != "gzip" || services.Title != "Mumbo Jungle" {
+		tests.Info("Received Report: %#v", services)
+		tests.Failed("Should have decoded gzip page before farming it")
+	}
+	tests.Passed("Should have decoded gzip page before farming it")
+
+	for _, report := range []crawler.LinkReport{index, services} {
+		if report.EncodedSize == 0 || report.EncodedSize >= report.DecodedSize || report.ContentLength != report.DecodedSize {
+			tests.Info("Received Sizes: %d encoded, %d decoded, %d length", report.EncodedSize, report.DecodedSize, report.ContentLength)
+			tests.Failed("Should have recorded encoded and decoded sizes of %q", report.Path.Path)
+		}
+	}
+	tests.Passed("Should have recorded encoded and decoded sizes")
+
+	if contacts := crawled["/contacts"]; contacts.ContentEncoding != "" || contacts.DecodedSize != int64(len(page)) {
+		tests.Info("Received Report: %#v", contacts)
+		tests.Failed("Should have recorded size of unencoded page")
+	}
+	tests.Passed("Should have recorded size of unencoded page")
+}
+
+func TestPageCrawlerCertificateStats(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<a href="/services"></a><a href="/contacts"></a>`))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Certificates = crawler.NewCertificateStats()
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, server.Client(), pool, reports)
+	})
+
+	for range reports {
+	}
+
+	hosts := pages.Certificates.Hosts()
+	if len(hosts) != 1 || hosts[0].Host != target.Host || hosts[0].Version != "TLS 1.3" || hosts[0].Issuer != "Acme Co" {
+		tests.Info("Received Certificates: %#v", hosts)
+		tests.Failed("Should have recorded TLS details of host")
+	}
+	tests.Passed("Should have recorded TLS details of host")
+
+	if hosts[0].WeakVersion || hosts[0].WeakCipher {
+		tests.Info("Received Certificate: %#v", hosts[0])
+		tests.Failed("Should have not flagged modern TLS as weak")
+	}
+	tests.Passed("Should have not flagged modern TLS as weak")
+
+	now := time.Now()
+	if len(pages.Certificates.Expiring(30*24*time.Hour, now)) != 0 {
+		tests.Failed("Should have not flagged certificate far from expiry")
+	}
+	tests.Passed("Should have not flagged certificate far from expiry")
+
+	if expiring := pages.Certificates.Expiring(hosts[0].NotAfter.Sub(now)+time.Hour, now); len(expiring) != 1 {
+		tests.Failed("Should have flagged certificate expiring within window")
+	}
+	tests.Passed("Should have flagged certificate expiring within window")
+}
+
+func TestPageCrawlerBudget(t *testing.T) {
+	var requests int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<a href="/a` + r.URL.Path + `"></a><a href="/b` + r.URL.Path + `"></a>`))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(budget *crawler.Budget) int {
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.Budget = budget
+		pages.IgnoreCrawlDelay = true
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		var crawled int
+		for range reports {
+			crawled++
+		}
+		return crawled
+	}
+
+	budget := crawler.NewBudget(5, 0)
+	if crawled := crawl(budget); crawled != 5 || budget.Pages() != 5 {
+		tests.Info("Received Pages: %d", crawled)
+		tests.Failed("Should have stopped crawl at max pages")
+	}
+	tests.Passed("Should have stopped crawl at max pages")
+
+	if !budget.Exhausted() {
+		tests.Failed("Should have reported crawl stopped due to page budget")
+	}
+	tests.Passed("Should have reported crawl stopped due to page budget")
+
+	atomic.StoreInt64(&requests, 0)
+
+	budget = crawler.NewBudget(0, 10)
+	crawl(budget)
+
+	if sent := atomic.LoadInt64(&requests); sent != 10 || budget.Requests() != 10 {
+		tests.Info("Received Requests: %d", sent)
+		tests.Failed("Should have stopped crawl at max requests")
+	}
+	tests.Passed("Should have stopped crawl at max requests")
+
+	if !budget.Exhausted() {
+		tests.Failed("Should have reported crawl stopped due to request budget")
+	}
+	tests.Passed("Should have reported crawl stopped due to request budget")
+}
+
+func TestPageCrawlerSectionCoverage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/blog/"></a><a href="/docs/"></a>`))
+		case "/blog/", "/docs/":
+			for index := 1; index <= 6; index++ {
+				fmt.Fprintf(w, `<a href="%s%d"></a>`, r.URL.Path, index)
+			}
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Budget = crawler.NewBudget(8, 0)
+	pages.Coverage = crawler.NewSectionCoverage(2)
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	sections := map[string]int{}
+	for report := range reports {
+		sections[strings.SplitAfterN(report.Path.Path, "/", 3)[1]]++
+	}
+
+	if sections["blog/"] < 3 || sections["docs/"] < 3 || sections["blog/"]+sections["docs/"] != 7 {
+		tests.Info("Received: %v", sections)
+		tests.Failed("Should have spread page budget across sections")
+	}
+	tests.Passed("Should have spread page budget across sections")
+
+	partial := pages.Coverage.Partial()
+	if len(partial) != 2 || partial[0].Section != "/blog" || partial[1].Section != "/docs" || partial[0].Crawled+partial[0].Skipped != 7 || partial[1].Crawled+partial[1].Skipped != 7 {
+		tests.Info("Received: %+v", partial)
+		tests.Failed("Should have reported sections partially crawled")
+	}
+	tests.Passed("Should have reported sections partially crawled")
+}
+
+func TestPageCrawlerRecordsRemoteIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Server", "mombo-edge")
+		w.Write([]byte(`<a href="/services"></a>`))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	family, err := crawler.ParseIPFamily("v4")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed ip family")
+	}
+	tests.Passed("Should have successfully parsed ip family")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	client := &http.Client{Timeout: 5 * time.Second, Transport: crawler.NewTransport(crawler.TransportOptions{IPFamily: family})}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, client, pool, reports)
+	})
+
+	for report := range reports {
+		if report.RemoteIP != "127.0.0.1" || report.IPFamily != crawler.IPFamilyV4 {
+			tests.Info("Received Remote: %q %q", report.RemoteIP, report.IPFamily)
+			tests.Failed("Should have recorded ip address and family used for %q", report.Path.Path)
+		}
+
+		if report.Server != "mombo-edge" {
+			tests.Info("Received Server: %q", report.Server)
+			tests.Failed("Should have recorded server header of %q", report.Path.Path)
+		}
+	}
+	tests.Passed("Should have recorded ip address and family used")
+	tests.Passed("Should have recorded server header")
+
+	if family, err := crawler.ParseIPFamily("6"); err != nil || family != crawler.IPFamilyV6 {
+		tests.Failed("Should have parsed ip version as ip family")
+	}
+	tests.Passed("Should have parsed ip version as ip family")
+
+	if _, err := crawler.ParseIPFamily("v5"); err == nil {
+		tests.Failed("Should have failed to parse unknown ip family")
+	}
+	tests.Passed("Should have failed to parse unknown ip family")
+}
+
+func TestPageCrawlerConnectionStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<a href="/services"></a><a href="/contacts"></a>`))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Connections = crawler.NewConnectionStats()
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	for range reports {
+	}
+
+	hosts := pages.Connections.Hosts()
+	if len(hosts) != 1 || hosts[0].Host != target.Host {
+		tests.Info("Received Hosts: %#v", hosts)
+		tests.Failed("Should have recorded connections of target's host")
+	}
+	tests.Passed("Should have recorded connections of target's host")
+
+	if hosts[0].New == 0 || hosts[0].Reused == 0 || hosts[0].Requests() < 4 {
+		tests.Info("Received Connections: %#v", hosts[0])
+		tests.Failed("Should have recorded new and reused connections")
+	}
+	tests.Passed("Should have recorded new and reused connections")
+}
+
+func TestPageCrawlerSyntheticSite(t *testing.T) {
+	server := sitetest.NewServer(sitetest.Options{
+		Pages:        200,
+		Branching:    4,
+		Errors:       10,
+		Redirects:    15,
+		RedirectHops: 2,
+		Seed:         1,
+	})
+	defer server.Close()
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = server.Target()
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	received := map[string]bool{}
+	broken := map[string]bool{}
+	for report := range reports {
+		received[report.Path.Path] = true
+		for _, kid := range report.PointsTo {
+			if !kid.Status.IsLive {
+				broken[kid.Path.Path] = true
+			}
+		}
+	}
+
+	for _, page := range server.Site.Pages() {
+		if !received[page] {
+			tests.Info("Missing Page: %q", page)
+			tests.Failed("Should have crawled all pages of synthetic site")
+		}
+	}
+	tests.Passed("Should have crawled all pages of synthetic site")
+
+	if len(received) != 200 {
+		tests.Info("Received Pages: %d", len(received))
+		tests.Failed("Should have crawled every page once")
+	}
+	tests.Passed("Should have crawled every page once")
+
+	for _, link := range server.Site.Broken() {
+		if !broken[link] {
+			tests.Info("Missing Broken Link: %q", link)
+			tests.Failed("Should have reported all broken links of synthetic site")
+		}
+	}
+	tests.Passed("Should have reported all broken links of synthetic site")
+}
+
+func TestPageCrawlerBodyStats(t *testing.T) {
+	server := sitetest.NewServer(sitetest.Options{
+		Pages:     100,
+		Branching: 5,
+		Errors:    10,
+		Redirects: 10,
+		Seed:      3,
+	})
+	defer server.Close()
+
+	bodies := crawler.NewBodyStats()
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: crawler.NewRoundTripper(crawler.TransportOptions{Bodies: bodies}),
+	}
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = server.Target()
+	pages.Connections = crawler.NewConnectionStats()
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, client, pool, reports)
+	})
+
+	for range reports {
+	}
+
+	if bodies.Opened() < 100 {
+		tests.Info("Received Opened: %d", bodies.Opened())
+		tests.Failed("Should have tracked response bodies of all requests")
+	}
+	tests.Passed("Should have tracked response bodies of all requests")
+
+	if bodies.Leaked() != 0 {
+		tests.Info("Received Leaked: %d", bodies.Leaked())
+		tests.Failed("Should have closed every response body")
+	}
+	tests.Passed("Should have closed every response body")
+
+	if bodies.Abandoned() != 0 {
+		tests.Info("Received Abandoned: %d", bodies.Abandoned())
+		tests.Failed("Should have drained every response body before closing it")
+	}
+	tests.Passed("Should have drained every response body before closing it")
+
+	if hosts := pages.Connections.Hosts(); len(hosts) != 1 || hosts[0].Reused == 0 {
+		tests.Info("Received Hosts: %#v", hosts)
+		tests.Failed("Should have reused connections of drained bodies")
+	}
+	tests.Passed("Should have reused connections of drained bodies")
+}
+
+func TestPageCrawlerScope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.Host + r.URL.Path {
+		case "monzo.test/":
+			w.Write([]byte(`<a href="http://web.monzo.test/"></a><a href="/about"></a><a href="http://other.test/"></a>`))
+		case "web.monzo.test/":
+			w.Write([]byte(`<a href="/about"></a><a href="http://monzo.test/"></a>`))
+		default:
+			w.Write([]byte(`<p>page</p>`))
+		}
+	}))
+	defer server.Close()
+
+	// Send requests to every host to the test server.
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+			},
+		},
+	}
+
+	target, err := url.Parse("http://monzo.test/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(scope crawler.Scope) map[string]bool {
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.Scope = scope
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, client, pool, reports)
+		})
+
+		received := map[string]bool{}
+		for report := range reports {
+			received[report.Path.String()] = true
+		}
+		return received
+	}
+
+	if received := crawl(crawler.ScopeHost); len(received) != 2 || received["http://web.monzo.test/"] {
+		tests.Info("Received Pages: %#v", received)
+		tests.Failed("Should have only crawled target's host by default")
+	}
+	tests.Passed("Should have only crawled target's host by default")
+
+	received := crawl(crawler.ScopeSubdomains)
+	if len(received) != 4 || !received["http://web.monzo.test/"] || !received["http://web.monzo.test/about"] {
+		tests.Info("Received Pages: %#v", received)
+		tests.Failed("Should have crawled subdomains of target's host, keeping their pages apart")
+	}
+	tests.Passed("Should have crawled subdomains of target's host, keeping their pages apart")
+
+	if received["http://other.test/"] {
+		tests.Failed("Should have not crawled hosts out of scope")
+	}
+	tests.Passed("Should have not crawled hosts out of scope")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.AllowHosts = []string{"Other.test"}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, client, pool, reports)
+	})
+
+	received = map[string]bool{}
+	for report := range reports {
+		received[report.Path.String()] = true
+	}
+
+	if len(received) != 3 || !received["http://other.test/"] || received["http://web.monzo.test/"] {
+		tests.Info("Received Pages: %#v", received)
+		tests.Failed("Should have crawled allowed hosts along with target's host")
+	}
+	tests.Passed("Should have crawled allowed hosts along with target's host")
+}
+
+func TestPageCrawlerGraph(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<nav><a href="/services"></a></nav><a href="/about"></a>`))
+		case "/services":
+			w.Write([]byte(`<a href="/about"></a><a href="/old-contacts"></a>`))
+		case "/old-contacts":
+			http.Redirect(w, r, "/contacts", http.StatusMovedPermanently)
+		case "/contacts":
+			w.Write([]byte(`<a href="/"></a>`))
+		default:
+			w.Write([]byte(`<a href="/services"></a>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Graph = crawler.NewGraph()
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	for range reports {
+	}
+
+	graph := pages.Graph
+	if graph.Seed() != server.URL+"/" {
+		tests.Info("Received Seed: %q", graph.Seed())
+		tests.Failed("Should have recorded target as seed of graph")
+	}
+	tests.Passed("Should have recorded target as seed of graph")
+
+	if nodes := graph.Nodes(); len(nodes) != 5 {
+		tests.Info("Received Nodes: %#v", nodes)
+		tests.Failed("Should have recorded all crawled and linked urls as nodes")
+	}
+	tests.Passed("Should have recorded all crawled and linked urls as nodes")
+
+	contacts, ok := graph.Node(server.URL + "/contacts")
+	if !ok || !contacts.Crawled || !contacts.Status.IsLive {
+		tests.Info("Received Node: %#v", contacts)
+		tests.Failed("Should have recorded crawled page reached through redirect")
+	}
+	tests.Passed("Should have recorded crawled page reached through redirect")
+
+	outlinks := graph.Outlinks(server.URL + "/")
+	if len(outlinks) != 2 || outlinks[1].To != server.URL+"/services" || outlinks[1].Position != crawler.PositionNav {
+		tests.Info("Received Outlinks: %#v", outlinks)
+		tests.Failed("Should have recorded outgoing links of page with their position")
+	}
+	tests.Passed("Should have recorded outgoing links of page with their position")
+
+	inlinks := graph.Inlinks(server.URL + "/about")
+	if len(inlinks) != 2 || inlinks[0].From != server.URL+"/" || inlinks[1].From != server.URL+"/services" {
+		tests.Info("Received Inlinks: %#v", inlinks)
+		tests.Failed("Should have recorded incoming links of page")
+	}
+	tests.Passed("Should have recorded incoming links of page")
+
+	path := graph.ShortestPath(server.URL + "/contacts")
+	expected := []string{server.URL + "/", server.URL + "/services", server.URL + "/old-contacts", server.URL + "/contacts"}
+	if len(path) != len(expected) {
+		tests.Info("Received Path: %+q", path)
+		tests.Failed("Should have found shortest path from seed through redirect")
+	}
+
+	for index := range expected {
+		if path[index] != expected[index] {
+			tests.Info("Received Path: %+q", path)
+			tests.Failed("Should have found shortest path from seed through redirect")
+		}
+	}
+	tests.Passed("Should have found shortest path from seed through redirect")
+
+	if graph.Depth(server.URL+"/about") != 1 || graph.Depth(server.URL+"/missing") != -1 {
+		tests.Failed("Should have measured depth of urls from seed")
+	}
+	tests.Passed("Should have measured depth of urls from seed")
+
+	var walked []int
+	graph.Walk(func(node crawler.GraphNode, depth int) bool {
+		walked = append(walked, depth)
+		return depth < 2
+	})
+
+	if len(walked) != 4 || walked[0] != 0 || walked[3] != 2 {
+		tests.Info("Received Depths: %v", walked)
+		tests.Failed("Should have walked graph breadth first till stopped")
+	}
+	tests.Passed("Should have walked graph breadth first till stopped")
+}
+
+func TestPageCrawlerRobotsDirectives(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/private"></a><a href="/drafts"></a>`))
+		case "/private":
+			w.Write([]byte(`<head><meta name="Robots" content="NoIndex, nofollow"></head><a href="/hidden"></a>`))
+		case "/drafts":
+			w.Header().Add("X-Robots-Tag", "noindex, noarchive")
+			w.Header().Add("X-Robots-Tag", "googlebot: nofollow")
+			w.Write([]byte(`<a href="/draft-1"></a>`))
+		default:
+			w.Write([]byte(`<p>page</p>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	received := map[string]crawler.LinkReport{}
+	for report := range reports {
+		received[report.Path.Path] = report
+	}
+
+	if robots := received["/"].Robots; robots != nil {
+		tests.Info("Received Robots: %#v", robots)
+		tests.Failed("Should have recorded no directives for page without any")
+	}
+	tests.Passed("Should have recorded no directives for page without any")
+
+	private := received["/private"].Robots
+	if private == nil || !private.NoIndex || !private.NoFollow || len(private.Directives) != 2 || private.Directives[0] != "noindex" {
+		tests.Info("Received Robots: %#v", private)
+		tests.Failed("Should have recorded directives of robots meta tag")
+	}
+	tests.Passed("Should have recorded directives of robots meta tag")
+
+	if _, ok := received["/hidden"]; ok || len(received["/private"].PointsTo) != 1 {
+		tests.Failed("Should have listed but not crawled links of nofollow page")
+	}
+	tests.Passed("Should have listed but not crawled links of nofollow page")
+
+	drafts := received["/drafts"].Robots
+	if drafts == nil || !drafts.NoIndex || drafts.NoFollow || len(drafts.Directives) != 2 || drafts.Directives[1] != "noarchive" {
+		tests.Info("Received Robots: %#v", drafts)
+		tests.Failed("Should have recorded X-Robots-Tag directives not scoped to another crawler")
+	}
+	tests.Passed("Should have recorded X-Robots-Tag directives not scoped to another crawler")
+
+	if _, ok := received["/draft-1"]; !ok {
+		tests.Failed("Should have crawled links of noindex page")
+	}
+	tests.Passed("Should have crawled links of noindex page")
+}
+
+func TestPageCrawlerRelNofollow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/comments" rel="UGC nofollow"></a><a href="/ads" rel="sponsored"></a><a href="/about"></a>`))
+		default:
+			w.Write([]byte(`<p>page</p>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(respect bool) map[string]crawler.LinkReport {
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.RespectNofollow = respect
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		received := map[string]crawler.LinkReport{}
+		for report := range reports {
+			received[report.Path.Path] = report
+		}
+		return received
+	}
+
+	received := crawl(false)
+	if _, ok := received["/comments"]; !ok || len(received) != 4 {
+		tests.Info("Received Pages: %d", len(received))
+		tests.Failed("Should have crawled nofollow links by default")
+	}
+	tests.Passed("Should have crawled nofollow links by default")
+
+	received = crawl(true)
+	if _, ok := received["/comments"]; ok || len(received) != 3 {
+		tests.Info("Received Pages: %d", len(received))
+		tests.Failed("Should have skipped crawling nofollow links")
+	}
+	tests.Passed("Should have skipped crawling nofollow links")
+
+	rels := map[string][]string{}
+	for _, kid := range received["/"].PointsTo {
+		if !kid.Status.IsLive {
+			tests.Info("Received Status: %#v", kid.Status)
+			tests.Failed("Should have checked all links of page")
+		}
+		rels[kid.Path.Path] = kid.Rel
+	}
+	tests.Passed("Should have checked all links of page")
+
+	if rel := rels["/comments"]; len(rel) != 2 || rel[0] != "ugc" || rel[1] != "nofollow" {
+		tests.Info("Received Rel: %#v", rel)
+		tests.Failed("Should have listed nofollow link with it's rel values")
+	}
+	tests.Passed("Should have listed nofollow link with it's rel values")
+
+	if rel := rels["/ads"]; len(rel) != 1 || rel[0] != "sponsored" {
+		tests.Info("Received Rel: %#v", rel)
+		tests.Failed("Should have listed sponsored link with it's rel values")
+	}
+	tests.Passed("Should have listed sponsored link with it's rel values")
+
+	if received["/ads"].Rel != nil {
+		tests.Failed("Should have not carried rel of link onto it's page")
+	}
+	tests.Passed("Should have not carried rel of link onto it's page")
+}
+
+func TestPageCrawlerSitemapSeeds(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<sitemapindex><sitemap><loc>` + server.URL + `/sitemap-pages.xml.gz</loc></sitemap><sitemap><loc>https://other.com/sitemap.xml</loc></sitemap></sitemapindex>`))
+		case "/sitemap-pages.xml.gz":
+			var content bytes.Buffer
+			writer := gzip.NewWriter(&content)
+			writer.Write([]byte(`<urlset><url><loc>` + server.URL + `/</loc></url><url><loc>` + server.URL + `/about</loc></url><url><loc>` + server.URL + `/orphan</loc></url><url><loc>` + server.URL + `/missing</loc></url><url><loc>https://other.com/page</loc></url></urlset>`))
+			writer.Close()
+
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(content.Bytes())
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/about"></a>`))
+		case "/about":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<p>about</p>`))
+		case "/orphan":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/deep"></a>`))
+		case "/deep":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<p>deep</p>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Sitemap = crawler.NewSitemapSeeds()
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	received := map[string]bool{}
+	for report := range reports {
+		received[report.Path.Path] = true
+	}
+
+	if !received["/orphan"] || !received["/deep"] || !received["/missing"] || len(received) != 5 {
+		tests.Info("Received Pages: %#v", received)
+		tests.Failed("Should have crawled pages listed by sitemaps with their links")
+	}
+	tests.Passed("Should have crawled pages listed by sitemaps with their links")
+
+	if sitemaps := pages.Sitemap.Sitemaps(); len(sitemaps) != 2 || sitemaps[1] != server.URL+"/sitemap-pages.xml.gz" {
+		tests.Info("Received Sitemaps: %#v", sitemaps)
+		tests.Failed("Should have fetched nested sitemaps of target's host")
+	}
+	tests.Passed("Should have fetched nested sitemaps of target's host")
+
+	if listed := pages.Sitemap.Listed(); len(listed) != 4 {
+		tests.Info("Received Listed: %#v", listed)
+		tests.Failed("Should have listed sitemap urls within target's host")
+	}
+	tests.Passed("Should have listed sitemap urls within target's host")
+
+	if orphans := pages.Sitemap.Orphans(); len(orphans) != 2 || orphans[0] != server.URL+"/missing" || orphans[1] != server.URL+"/orphan" {
+		tests.Info("Received Orphans: %#v", orphans)
+		tests.Failed("Should have reported sitemap urls no page links to as orphans")
+	}
+	tests.Passed("Should have reported sitemap urls no page links to as orphans")
+
+	if unlisted := pages.Sitemap.Unlisted(); len(unlisted) != 1 || unlisted[0] != server.URL+"/deep" {
+		tests.Info("Received Unlisted: %#v", unlisted)
+		tests.Failed("Should have reported crawled pages missing from sitemaps")
+	}
+	tests.Passed("Should have reported crawled pages missing from sitemaps")
+}
+
+// metadataTransport implements a http.RoundTripper recording the metadata
+// carried by requests per path.
+type metadataTransport struct {
+	ml       sync.Mutex
+	metadata map[string]crawler.Metadata
+}
+
+func (m *metadataTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.ml.Lock()
+	m.metadata[req.URL.Path] = crawler.MetadataFrom(req.Context())
+	m.ml.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPageCrawlerMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/blog"></a><a href="/services"></a>`))
+		case "/blog":
+			w.Write([]byte(`<a href="/blog/post"></a>`))
+		default:
+			w.Write([]byte(`<p>page</p>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.OnEnqueue = func(ctx context.Context, target *url.URL, parent *crawler.LinkReport) context.Context {
+		if parent == nil {
+			return crawler.WithMetadata(ctx, "experiment", "b")
+		}
+
+		if target.Path == "/blog" {
+			return crawler.WithMetadata(ctx, "owner", "content-team")
+		}
+		return ctx
+	}
+
+	transport := &metadataTransport{metadata: map[string]crawler.Metadata{}}
+	client := &http.Client{Timeout: 5 * time.Second, Transport: transport}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, client, pool, reports)
+	})
+
+	received := map[string]crawler.Metadata{}
+	for report := range reports {
+		received[report.Path.Path] = report.Metadata
+	}
+
+	if received["/"]["experiment"] != "b" || len(received["/"]) != 1 {
+		tests.Info("Received Metadata: %#v", received["/"])
+		tests.Failed("Should have emitted metadata attached to target on it's report")
+	}
+	tests.Passed("Should have emitted metadata attached to target on it's report")
+
+	if received["/blog"]["owner"] != "content-team" || received["/blog/post"]["owner"] != "content-team" || received["/blog/post"]["experiment"] != "b" {
+		tests.Info("Received Metadata: %#v", received)
+		tests.Failed("Should have emitted metadata attached to page and inherited by pages it links to")
+	}
+	tests.Passed("Should have emitted metadata attached to page and inherited by pages it links to")
+
+	if _, ok := received["/services"]["owner"]; ok || received["/services"]["experiment"] != "b" {
+		tests.Info("Received Metadata: %#v", received["/services"])
+		tests.Failed("Should have kept metadata attached to a page off it's siblings")
+	}
+	tests.Passed("Should have kept metadata attached to a page off it's siblings")
+
+	transport.ml.Lock()
+	defer transport.ml.Unlock()
+
+	if transport.metadata["/"]["experiment"] != "b" {
+		tests.Info("Received Metadata: %#v", transport.metadata)
+		tests.Failed("Should have carried metadata on requests of target")
+	}
+	tests.Passed("Should have carried metadata on requests of target")
+}
+
+func TestPageCrawlerNormalizedLinks(t *testing.T) {
+	var ml sync.Mutex
+	requested := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ml.Lock()
+			requested[r.URL.RequestURI()]++
+			ml.Unlock()
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/page?a=1"></a><a href="/page?b=2"></a><a href="/docs/../page?a=1#top"></a>` +
+				`<a href="/list?sort=asc&page=2"></a><a href="/list?page=2&sort=asc"></a>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]int{}
+	for report := range reports {
+		crawled[report.Path.RequestURI()]++
+	}
+
+	if len(crawled) != 4 || crawled["/page?a=1"] != 1 || crawled["/page?b=2"] != 1 || crawled["/list?page=2&sort=asc"] != 1 {
+		tests.Info("Received Pages: %#v", crawled)
+		tests.Failed("Should have crawled each normalized url once")
+	}
+	tests.Passed("Should have crawled each normalized url once")
+
+	ml.Lock()
+	defer ml.Unlock()
+
+	for uri, count := range requested {
+		if count != 1 {
+			tests.Info("Received Requests: %#v", requested)
+			tests.Failed("Should have requested %q once", uri)
+		}
+	}
+	tests.Passed("Should have requested each normalized url once")
+}
+
+func TestPageCrawlerQueryPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/list?page=1&ref=nav"></a><a href="/list?page=2&ref=nav"></a><a href="/list?page=2&ref=footer"></a>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(policy crawler.QueryPolicy) map[string]crawler.LinkReport {
+		ctx := context.Background()
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.QueryPolicy = policy
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		crawled := map[string]crawler.LinkReport{}
+		for report := range reports {
+			crawled[report.Path.RequestURI()] = report
+		}
+		return crawled
+	}
+
+	if crawled := crawl(crawler.QueryPolicy{Mode: crawler.QueryKeep}); len(crawled) != 4 {
+		tests.Info("Received Pages: %d", len(crawled))
+		tests.Failed("Should have crawled urls of different queries apart")
+	}
+	tests.Passed("Should have crawled urls of different queries apart")
+
+	crawled := crawl(crawler.QueryPolicy{Mode: crawler.QueryStrip})
+	if _, ok := crawled["/list"]; !ok || len(crawled) != 2 {
+		tests.Info("Received Pages: %#v", crawled)
+		tests.Failed("Should have crawled urls differing by query once")
+	}
+	tests.Passed("Should have crawled urls differing by query once")
+
+	crawled = crawl(crawler.QueryPolicy{Mode: crawler.QueryWhitelist, Params: []string{"page"}})
+	if _, ok := crawled["/list?page=2"]; !ok || len(crawled) != 3 {
+		tests.Info("Received Pages: %#v", crawled)
+		tests.Failed("Should have crawled urls apart by whitelisted parameters only")
+	}
+	tests.Passed("Should have crawled urls apart by whitelisted parameters only")
+
+	for _, kid := range crawled["/"].PointsTo {
+		if len(kid.Stripped) != 1 || kid.Stripped[0] != "ref" {
+			tests.Info("Received Report: %#v", kid)
+			tests.Failed("Should have recorded stripped parameters on report")
+		}
+	}
+	tests.Passed("Should have recorded stripped parameters on report")
+}
+
+func TestPageCrawlerTrackingParams(t *testing.T) {
+	var fetched int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/article?utm_source=home"></a><a href="/article?utm_source=nav&fbclid=1"></a><a href="/article?ref=promo"></a>`))
+		case "/article":
+			if r.Method == http.MethodGet {
+				atomic.AddInt64(&fetched, 1)
+			}
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.TrackingParams = []string{"ref"}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.RequestURI()] = report
+	}
+
+	if _, ok := crawled["/article"]; !ok || len(crawled) != 2 || atomic.LoadInt64(&fetched) != 1 {
+		tests.Info("Received Pages: %#v", crawled)
+		tests.Failed("Should have crawled article linked with different tracking parameters once")
+	}
+	tests.Passed("Should have crawled article linked with different tracking parameters once")
+
+	for _, kid := range crawled["/"].PointsTo {
+		if len(kid.Stripped) == 0 {
+			tests.Info("Received Report: %#v", kid)
+			tests.Failed("Should have recorded stripped tracking parameters on report")
+		}
+	}
+	tests.Passed("Should have recorded stripped tracking parameters on report")
+}
+
+func TestPageCrawlerTraps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch {
+		case r.URL.Path == "/":
+			w.Write([]byte(`<a href="/calendar/1"></a><a href="/loop/"></a>`))
+		case strings.HasPrefix(r.URL.Path, "/calendar/"):
+			month, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/calendar/"))
+			fmt.Fprintf(w, `<a href="/calendar/%d"></a>`, month+1)
+		case strings.HasPrefix(r.URL.Path, "/loop/"):
+			w.Write([]byte(`<a href="loop/"></a>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Traps = crawler.NewTrapDetector(crawler.TrapRules{MaxRepeats: 3, MaxPerPattern: 5})
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var crawled int
+	var trapped []string
+	for report := range reports {
+		crawled++
+		for _, kid := range report.PointsTo {
+			if reason := kid.Status.Reason; reason != nil && reason.Code == crawler.ReasonTrap {
+				trapped = append(trapped, kid.Path.Path)
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		tests.Failed("Should have stopped crawling traps before deadline")
+	}
+	tests.Passed("Should have stopped crawling traps before deadline")
+
+	sort.Strings(trapped)
+	if crawled != 9 || len(trapped) != 2 || trapped[0] != "/calendar/6" || trapped[1] != "/loop/loop/loop/loop/" {
+		tests.Info("Received Pages: %d, Trapped: %#v", crawled, trapped)
+		tests.Failed("Should have reported links suspected as traps")
+	}
+	tests.Passed("Should have reported links suspected as traps")
+}
+
+func TestPageCrawlerTemplates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch {
+		case r.URL.Path == "/":
+			w.Write([]byte(`<html><body><nav>`))
+			for product := 1; product <= 5; product++ {
+				fmt.Fprintf(w, `<a href="/products/%d"></a>`, product)
+			}
+			w.Write([]byte(`</nav></body></html>`))
+		case strings.HasPrefix(r.URL.Path, "/products/"):
+			product := strings.TrimPrefix(r.URL.Path, "/products/")
+			fmt.Fprintf(w, `<html><body><div><h1>Product %s</h1><ul><li><a href="/reviews/%s"></a></li></ul></div></body></html>`, product, product)
+		default:
+			w.Write([]byte(`<html><body><p>Review</p></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Templates = crawler.NewTemplateClusters(0, 2)
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var crawled, capped, reviews int
+	for report := range reports {
+		crawled++
+		if report.TemplateCapped {
+			capped++
+			if len(report.PointsTo) != 0 {
+				tests.Failed("Should have left links of capped pages unchecked")
+			}
+		}
+		if strings.HasPrefix(report.Path.Path, "/reviews/") {
+			reviews++
+		}
+	}
+	tests.Passed("Should have left links of capped pages unchecked")
+
+	if crawled != 8 || capped != 3 || reviews != 2 {
+		tests.Info("Received Pages: %d, Capped: %d, Reviews: %d", crawled, capped, reviews)
+		tests.Failed("Should have explored links of at most 2 pages per template")
+	}
+	tests.Passed("Should have explored links of at most 2 pages per template")
+
+	templates := pages.Templates.Templates()
+	if len(templates) != 3 || templates[0].Pages != 5 || templates[0].Capped != 3 {
+		tests.Info("Received Templates: %#v", templates)
+		tests.Failed("Should have clustered pages into their templates")
+	}
+	tests.Passed("Should have clustered pages into their templates")
+}
+
+func TestPageCrawlerStylesheets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="stylesheet" href="/css/site.css"></head><body><img src="/img/logo.png"></body></html>`))
+		case "/css/site.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte(`@import "theme.css"; body { background: url(../img/logo.png); } @font-face { src: url("../fonts/missing.woff2"); }`))
+		case "/css/theme.css":
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			w.Write([]byte(`@import url(site.css); h1 { background-image: url('/img/hero.jpg'); }`))
+		case "/img/logo.png", "/img/hero.jpg":
+			w.Header().Set("Content-Type", "image/png")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.ScanStylesheets = true
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	links := map[string]crawler.LinkReport{}
+	for report := range reports {
+		for _, kid := range report.PointsTo {
+			links[kid.Path.Path] = kid
+		}
+	}
+
+	expected := map[string]string{
+		"/css/site.css":        "",
+		"/img/logo.png":        "",
+		"/css/theme.css":       server.URL + "/css/site.css",
+		"/fonts/missing.woff2": server.URL + "/css/site.css",
+		"/img/hero.jpg":        server.URL + "/css/theme.css",
+	}
+
+	if len(links) != len(expected) {
+		tests.Info("Received Links: %d", len(links))
+		tests.Failed("Should have checked resources referenced by stylesheets once")
+	}
+
+	for path, stylesheet := range expected {
+		if link, ok := links[path]; !ok || link.Stylesheet != stylesheet {
+			tests.Info("Path: %q, Expected: %q, Received: %q", path, stylesheet, link.Stylesheet)
+			tests.Failed("Should have checked resources referenced by stylesheets once")
+		}
+	}
+	tests.Passed("Should have checked resources referenced by stylesheets once")
+
+	if links["/fonts/missing.woff2"].Status.IsLive {
+		tests.Failed("Should have reported missing font of stylesheet as broken")
+	}
+	tests.Passed("Should have reported missing font of stylesheet as broken")
+}
+
+func TestPageCrawlerFeeds(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/feed.xml"></head><body><a href="/blog/linked"></a></body></html>`))
+		case "/feed.xml":
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+			w.Write([]byte(`<rss version="2.0"><channel>
+				<item><link>/blog/linked</link></item>
+				<item><link>` + server.URL + `/blog/archived</link></item>
+				<item><link>https://elsewhere.example/post</link></item>
+			</channel></rss>`))
+		case "/blog/linked", "/blog/archived":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/"></a></body></html>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.FollowFeeds = true
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]bool{}
+	links := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.Path] = true
+		if report.Path.Path == "/" {
+			for _, kid := range report.PointsTo {
+				links[kid.Path.Path] = kid
+			}
+		}
+	}
+
+	expected := map[string]string{
+		"/feed.xml":      "",
+		"/blog/linked":   "",
+		"/blog/archived": server.URL + "/feed.xml",
+	}
+
+	if len(links) != len(expected) {
+		tests.Info("Received Links: %d", len(links))
+		tests.Failed("Should have checked in-scope items of linked feed once")
+	}
+
+	for path, feed := range expected {
+		if link, ok := links[path]; !ok || link.Feed != feed {
+			tests.Info("Path: %q, Expected: %q, Received: %q", path, feed, link.Feed)
+			tests.Failed("Should have checked in-scope items of linked feed once")
+		}
+	}
+	tests.Passed("Should have checked in-scope items of linked feed once")
+
+	if !crawled["/blog/archived"] {
+		tests.Info("Crawled: %+v", crawled)
+		tests.Failed("Should have crawled page only reachable through feed")
+	}
+	tests.Passed("Should have crawled page only reachable through feed")
+}
+
+func TestPageCrawlerJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/api/posts"></a><script type="application/json">{"next": "/blog/inline"}</script></body></html>`))
+		case "/api/posts":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"posts": [{"href": "/blog/first"}], "next": "/api/more.json", "cdn": "https://elsewhere.example/app.js"}`))
+		case "/api/more.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"posts": [{"href": "/blog/second"}, {"href": "/blog/first"}]}`))
+		case "/blog/inline", "/blog/first", "/blog/second":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><a href="/"></a></body></html>`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.ScanJSON = true
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]bool{}
+	links := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.Path] = true
+		if report.Path.Path == "/" {
+			for _, kid := range report.PointsTo {
+				links[kid.Path.Path] = kid
+			}
+		}
+	}
+
+	expected := map[string]string{
+		"/api/posts":     "",
+		"/blog/inline":   "",
+		"/blog/first":    server.URL + "/api/posts",
+		"/api/more.json": server.URL + "/api/posts",
+		"/blog/second":   server.URL + "/api/more.json",
+	}
+
+	if len(links) != len(expected) {
+		tests.Info("Received Links: %d", len(links))
+		tests.Failed("Should have checked in-scope urls of json documents once")
+	}
+
+	for path, doc := range expected {
+		if link, ok := links[path]; !ok || link.JSON != doc {
+			tests.Info("Path: %q, Expected: %q, Received: %q", path, doc, link.JSON)
+			tests.Failed("Should have checked in-scope urls of json documents once")
+		}
+	}
+	tests.Passed("Should have checked in-scope urls of json documents once")
+
+	for _, path := range []string{"/blog/inline", "/blog/first", "/blog/second"} {
+		if !crawled[path] {
+			tests.Info("Path: %q", path)
+			tests.Failed("Should have crawled pages only referenced by json")
+		}
+	}
+	tests.Passed("Should have crawled pages only referenced by json")
+}
+
+// appRenderer implements a crawler.Renderer rendering the links of a single
+// page app served at the root, failing to render all other pages.
+type appRenderer struct{}
+
+func (appRenderer) Render(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != "/" {
+		return nil, errors.New("render failed")
+	}
+	return renderedResponse(req, http.StatusOK, `<html><head><title>App</title></head><body><div id="app"><a href="/client"></a></div></body></html>`), nil
+}
+
+// renderedResponse returns the response of giving request with provided
+// status and the rendered document as it's body.
+func renderedResponse(req *http.Request, status int, document string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Request:    req,
+		Body:       ioutil.NopCloser(strings.NewReader(document)),
+	}
+}
+
+func TestPageCrawlerRenderer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<html><body><div id="app"></div><footer><a href="/served"></a></footer></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Renderer = appRenderer{}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.Path] = report
+	}
+
+	index := crawled["/"]
+	if index.Title != "App" || len(index.PointsTo) != 2 || len(index.ParseWarnings) != 0 {
+		tests.Info("Received Title: %q, Links: %d", index.Title, len(index.PointsTo))
+		tests.Failed("Should have merged links of rendered and served page")
+	}
+	tests.Passed("Should have merged links of rendered and served page")
+
+	if _, ok := crawled["/client"]; !ok {
+		tests.Failed("Should have crawled links rendered client-side")
+	}
+	tests.Passed("Should have crawled links rendered client-side")
+
+	if served := crawled["/served"]; len(served.ParseWarnings) != 1 || served.ParseWarnings[0] != "render failed" {
+		tests.Info("Received Warnings: %+q", served.ParseWarnings)
+		tests.Failed("Should have recorded failure to render page as parse warning")
+	}
+	tests.Passed("Should have recorded failure to render page as parse warning")
+}
+
+// routeRenderer implements a crawler.Renderer rendering the routes of a single
+// page app by path, failing to render unknown routes.
+type routeRenderer map[string]string
+
+func (r routeRenderer) Render(req *http.Request) (*http.Response, error) {
+	rendered, ok := r[req.URL.Path]
+	if !ok {
+		return nil, errors.New("render failed")
+	}
+	return renderedResponse(req, http.StatusOK, rendered), nil
+}
+
+func TestPageCrawlerDeadRoutes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><main id="app"></main></body></html>`))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Renderer = routeRenderer{
+		"/":        `<html><body><nav><a href="/pricing"></a><a href="/careers"></a><a href="/blank"></a><a href="/broken"></a></nav><main><h1>Mombo</h1></main></body></html>`,
+		"/pricing": `<html><body><main><h1>Pricing</h1></main></body></html>`,
+		"/careers": `<html><head><title>Not Found</title></head><body><main><h1>We couldn't find that page</h1></main></body></html>`,
+		"/blank":   `<html><body><main id="app"></main></body></html>`,
+	}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.Path] = report
+	}
+
+	expected := map[string]string{
+		"/":        "",
+		"/pricing": "",
+		"/careers": "renders not found view",
+		"/blank":   "renders empty main element",
+		"/broken":  "",
+	}
+
+	for path, routeError := range expected {
+		if report, ok := crawled[path]; !ok || report.RouteError != routeError || !report.Status.IsLive {
+			tests.Info("Path: %q, Expected: %q, Received: %q", path, routeError, report.RouteError)
+			tests.Failed("Should have reported live routes rendering dead client-side")
+		}
+	}
+	tests.Passed("Should have reported live routes rendering dead client-side")
+}
+
+// pacedRenderer implements a crawler.Renderer recording the requests it's
+// given, answering /about with a pause and rendering all other pages as served.
+type pacedRenderer struct {
+	ml       sync.Mutex
+	requests []*http.Request
+}
+
+func (p *pacedRenderer) Render(req *http.Request) (*http.Response, error) {
+	p.ml.Lock()
+	p.requests = append(p.requests, req)
+	p.ml.Unlock()
+
+	if req.URL.Path == "/about" {
+		res := renderedResponse(req, http.StatusTooManyRequests, "")
+		res.Header.Set("Retry-After", "0")
+		return res, nil
+	}
+	return renderedResponse(req, http.StatusOK, `<html><body><a href="/about"></a></body></html>`), nil
+}
+
+// observingScheduler implements a Scheduler counting the requests waited on
+// and recording the statuses observed, with the ordering and pacing of a
+// PoliteScheduler.
+type observingScheduler struct {
+	*crawler.PoliteScheduler
+	ml       sync.Mutex
+	waits    int
+	statuses []int
+}
+
+func (o *observingScheduler) Wait(ctx context.Context, target *url.URL) error {
+	o.ml.Lock()
+	o.waits++
+	o.ml.Unlock()
+	return o.PoliteScheduler.Wait(ctx, target)
+}
+
+func (o *observingScheduler) Observe(res *http.Response) bool {
+	o.ml.Lock()
+	o.statuses = append(o.statuses, res.StatusCode)
+	o.ml.Unlock()
+	return o.PoliteScheduler.Observe(res)
+}
+
+func TestPageCrawlerRendererRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<html><body><a href="/about"></a></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	crawl := func(renderer crawler.Renderer) (*observingScheduler, *crawler.Budget, map[string]crawler.LinkReport) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		pool := crawler.NewWorkerPool(300, ctx)
+		defer pool.Stop()
+
+		scheduler := &observingScheduler{PoliteScheduler: crawler.NewPoliteScheduler(nil)}
+
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.Scheduler = scheduler
+		pages.Budget = crawler.NewBudget(0, 0)
+		pages.Headers = http.Header{"X-Token": {"abc"}}
+		pages.Cookies = []*http.Cookie{{Name: "session", Value: "s1"}}
+		if renderer != nil {
+			pages.Renderer = renderer
+		}
+
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() {
+			pages.Run(ctx, baseClient, pool, reports)
+		})
+
+		crawled := map[string]crawler.LinkReport{}
+		for report := range reports {
+			crawled[report.Path.Path] = report
+		}
+		return scheduler, pages.Budget, crawled
+	}
+
+	served, servedBudget, _ := crawl(nil)
+
+	renderer := &pacedRenderer{}
+	rendered, renderedBudget, crawled := crawl(renderer)
+
+	if len(renderer.requests) != 2 {
+		tests.Info("Received Renders: %d", len(renderer.requests))
+		tests.Failed("Should have rendered every crawled page")
+	}
+	tests.Passed("Should have rendered every crawled page")
+
+	for _, req := range renderer.requests {
+		cookie, err := req.Cookie("session")
+		if req.UserAgent() != crawler.DefaultUserAgent || req.Header.Get("X-Token") != "abc" || err != nil || cookie.Value != "s1" {
+			tests.Info("Received Headers: %+v", req.Header)
+			tests.Failed("Should have rendered pages with the crawl's user agent, headers and cookies")
+		}
+	}
+	tests.Passed("Should have rendered pages with the crawl's user agent, headers and cookies")
+
+	if rendered.waits != served.waits+2 {
+		tests.Info("Received Waits: %d, Served Waits: %d", rendered.waits, served.waits)
+		tests.Failed("Should have waited on the host's rate before rendering pages")
+	}
+	tests.Passed("Should have waited on the host's rate before rendering pages")
+
+	if renderedBudget.Requests() != servedBudget.Requests()+2 {
+		tests.Info("Received Requests: %d, Served Requests: %d", renderedBudget.Requests(), servedBudget.Requests())
+		tests.Failed("Should have counted renders against the request budget")
+	}
+	tests.Passed("Should have counted renders against the request budget")
+
+	var paused int
+	for _, status := range rendered.statuses {
+		if status == http.StatusTooManyRequests {
+			paused++
+		}
+	}
+
+	if paused != 1 {
+		tests.Info("Received Statuses: %+v", rendered.statuses)
+		tests.Failed("Should have observed host pausing rendered page")
+	}
+	tests.Passed("Should have observed host pausing rendered page")
+
+	if about := crawled["/about"]; len(about.ParseWarnings) != 1 || !strings.Contains(about.ParseWarnings[0], "status 429") {
+		tests.Info("Received Warnings: %+q", about.ParseWarnings)
+		tests.Failed("Should have recorded rendered page paused by host as parse warning")
+	}
+	tests.Passed("Should have recorded rendered page paused by host as parse warning")
+}
+
+// reverseScheduler implements a Scheduler crawling the links of pages in
+// reverse order of their paths, skipping /private, with the pacing of a
+// PoliteScheduler.
+type reverseScheduler struct {
+	*crawler.PoliteScheduler
+	waits int64
+}
+
+func (r *reverseScheduler) Order(page *url.URL, links []crawler.LinkReport) []crawler.LinkReport {
+	var ordered []crawler.LinkReport
+	for _, link := range links {
+		if link.Path.Path != "/private" {
+			ordered = append(ordered, link)
+		}
+	}
+
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Path.Path > ordered[j].Path.Path
+	})
+	return ordered
+}
+
+func (r *reverseScheduler) Wait(ctx context.Context, target *url.URL) error {
+	atomic.AddInt64(&r.waits, 1)
+	return r.PoliteScheduler.Wait(ctx, target)
+}
+
+func TestPageCrawlerScheduler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/a"></a><a href="/b"></a><a href="/private"></a><a href="/c"></a>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	scheduler := &reverseScheduler{PoliteScheduler: crawler.NewPoliteScheduler(nil)}
+
+	var ml sync.Mutex
+	var enqueued []string
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Scheduler = scheduler
+	pages.OnEnqueue = func(ctx context.Context, target *url.URL, parent *crawler.LinkReport) context.Context {
+		ml.Lock()
+		defer ml.Unlock()
+		enqueued = append(enqueued, target.Path)
+		return ctx
+	}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]bool{}
+	for report := range reports {
+		crawled[report.Path.Path] = true
+	}
+
+	if strings.Join(enqueued, ",") != "/,/c,/b,/a" || len(crawled) != 4 || crawled["/private"] {
+		tests.Info("Received Enqueued: %#v", enqueued)
+		tests.Info("Received Crawled: %#v", crawled)
+		tests.Failed("Should have crawled links in the order set by the scheduler")
+	}
+	tests.Passed("Should have crawled links in the order set by the scheduler")
+
+	// The seed and it's four links are requested once each, as crawled links
+	// reuse the page fetched when checked.
+	if waits := atomic.LoadInt64(&scheduler.waits); waits != 5 {
+		tests.Info("Received Waits: %d", waits)
+		tests.Failed("Should have paced every request through the scheduler")
+	}
+	tests.Passed("Should have paced every request through the scheduler")
+}
+
+// recordingScheduler implements a Scheduler recording the links it's given to
+// order for the seed page, with the ordering and pacing of a PoliteScheduler.
+type recordingScheduler struct {
+	*crawler.PoliteScheduler
+	ml    sync.Mutex
+	links []string
+}
+
+func (r *recordingScheduler) Order(page *url.URL, links []crawler.LinkReport) []crawler.LinkReport {
+	if page != nil && page.Path == "/" {
+		r.ml.Lock()
+		for _, link := range links {
+			r.links = append(r.links, link.Path.Path)
+		}
+		r.ml.Unlock()
+	}
+	return r.PoliteScheduler.Order(page, links)
+}
+
+func TestPageCrawlerSchedulerFoundOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="/e"></a><a href="/a"></a><img src="/d"><a href="/b"></a><a href="/a"></a><a href="/g"></a><a href="/c"></a><a href="/f"></a>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	scheduler := &recordingScheduler{PoliteScheduler: crawler.NewPoliteScheduler(nil)}
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Scheduler = scheduler
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var linked []string
+	for report := range reports {
+		if report.Path.Path != "/" {
+			continue
+		}
+
+		for _, kid := range report.PointsTo {
+			linked = append(linked, kid.Path.Path)
+		}
+	}
+
+	expected := "/e,/a,/d,/b,/g,/c,/f"
+	if strings.Join(scheduler.links, ",") != expected || strings.Join(linked, ",") != expected {
+		tests.Info("Received Ordered: %#v", scheduler.links)
+		tests.Info("Received Linked: %#v", linked)
+		tests.Failed("Should have given links to scheduler and report in the order found")
+	}
+	tests.Passed("Should have given links to scheduler and report in the order found")
+}
+
+func TestPageCrawlerCanonical(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch strings.TrimSuffix(r.URL.Path, "/") {
+		case "":
+			w.Write([]byte(`<head><link rel="canonical" href="/"></head><a href="/shoes/"></a><a href="/shoes?sort=price"></a><a href="/about"></a>`))
+		case "/shoes":
+			if r.URL.RawQuery != "" {
+				w.Write([]byte(`<head><link rel="canonical" href="/shoes"><link rel="canonical" href="/boots"></head>`))
+				return
+			}
+			w.Write([]byte(`<head><link rel="canonical" href="/shoes"></head>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	crawled := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.RequestURI()] = report
+	}
+
+	expected := map[string]struct {
+		canonical    string
+		nonCanonical bool
+	}{
+		"/":                 {"/", false},
+		"/shoes/":           {"/shoes", false},
+		"/shoes?sort=price": {"/shoes", true},
+		"/about":            {"", false},
+	}
+
+	for path, want := range expected {
+		report, ok := crawled[path]
+
+		var canonical string
+		if report.Canonical != nil {
+			canonical = report.Canonical.RequestURI()
+		}
+
+		if !ok || canonical != want.canonical || report.NonCanonical != want.nonCanonical {
+			tests.Info("Path: %q, Canonical: %q, NonCanonical: %t", path, canonical, report.NonCanonical)
+			tests.Failed("Should have reported the canonical url declared by page")
+		}
+	}
+	tests.Passed("Should have reported the canonical urls declared by pages")
+}
+
+func TestPageCrawlerPrimeCache(t *testing.T) {
+	var mu sync.Mutex
+	cached := map[string]bool{}
+	methods := map[string][]string{}
+	var busted bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		methods[r.URL.Path] = append(methods[r.URL.Path], r.Method)
+		if r.Header.Get("Cache-Control") != "" || r.Header.Get("Pragma") != "" || r.Header.Get("If-None-Match") != "" {
+			busted = true
+		}
+
+		if cached[r.URL.Path] {
+			w.Header().Set("X-Cache", "HIT")
+		} else {
+			w.Header().Set("X-Cache", "MISS")
+		}
+
+		if r.Method == http.MethodGet {
+			cached[r.URL.Path] = true
+		}
+
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/about"></a><img src="/logo.png">`))
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(bytes.Repeat([]byte("x"), 1<<19))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.PrimeCache = true
+	pages.Headers = http.Header{"Cache-Control": {"no-cache"}, "Pragma": {"no-cache"}}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	primed := map[string]crawler.LinkReport{}
+	for report := range reports {
+		primed[report.Path.Path] = report
+		for _, kid := range report.PointsTo {
+			primed[kid.Path.Path] = kid
+		}
+	}
+
+	for _, path := range []string{"/", "/about", "/logo.png"} {
+		report := primed[path]
+		if report.CacheBefore != crawler.CacheMiss || report.CacheAfter != crawler.CacheHit {
+			tests.Info("Path: %q, Before: %q, After: %q", path, report.CacheBefore, report.CacheAfter)
+			tests.Failed("Should have reported cache status before and after priming")
+		}
+	}
+	tests.Passed("Should have reported cache status before and after priming")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if busted {
+		tests.Failed("Should have primed caches without cache-busting or conditional headers")
+	}
+	tests.Passed("Should have primed caches without cache-busting or conditional headers")
+
+	if logo := methods["/logo.png"]; len(logo) != 2 || logo[0] != http.MethodGet || logo[1] != http.MethodHead {
+		tests.Info("Received: %v", logo)
+		tests.Failed("Should have primed assets with a GET before probing them")
+	}
+	tests.Passed("Should have primed assets with a GET before probing them")
+}
+
+func TestPageCrawlerSocial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<head>
+				<meta property="og:title" content="Home">
+				<meta property="og:image" content="/logo.png">
+				<meta name="twitter:card" content="summary">
+				<meta name="twitter:image" content="/missing.png">
+			</head><a href="/about"></a><a href="/press"></a>`))
+		case "/about":
+			w.Header().Set("Content-Type", "text/html")
+		case "/press":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<meta property="og:title" content="Press"><meta property="og:image" content="/about"><meta name="twitter:card" content="summary">`))
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.ScanSocial = true
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	issues := map[string]string{}
+	for report := range reports {
+		if report.Social == nil {
+			tests.Info("Path: %q", report.Path.Path)
+			tests.Failed("Should have audited social tags of every page")
+		}
+		issues[report.Path.Path] = strings.Join(report.Social.Issues, "; ")
+	}
+	tests.Passed("Should have audited social tags of every page")
+
+	expected := map[string]string{
+		"/":      "twitter:image is broken, status 404",
+		"/about": "missing og:title; missing og:image; missing twitter:card",
+		"/press": "og:image is not an image, served as text/html",
+	}
+
+	for path, want := range expected {
+		if issues[path] != want {
+			tests.Info("Path: %q, Expected: %q, Received: %q", path, want, issues[path])
+			tests.Failed("Should have flagged missing social tags and broken images")
+		}
+	}
+	tests.Passed("Should have flagged missing social tags and broken images")
+}
+
+func TestPageCrawlerFetcher(t *testing.T) {
+	fixtures := map[string]struct {
+		status   int
+		location string
+		body     string
+	}{
+		"https://mombo.com/robots.txt": {status: http.StatusOK, body: "User-agent: *\nCrawl-delay: 0"},
+		"https://mombo.com/":           {status: http.StatusOK, body: `<a href="/old-about"></a><a href="/missing"></a>`},
+		"https://mombo.com/old-about":  {status: http.StatusMovedPermanently, location: "/about"},
+		"https://mombo.com/about":      {status: http.StatusOK, body: `<a href="/"></a>`},
+	}
+
+	var sent, identified int64
+	fetcher := crawler.FetcherFunc(func(ctx context.Context, target *url.URL) (*http.Response, error) {
+		atomic.AddInt64(&sent, 1)
+
+		req, err := crawler.FetchRequest(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.UserAgent() == crawler.DefaultUserAgent && req.Header.Get("X-Token") == "abc" {
+			atomic.AddInt64(&identified, 1)
+		}
+
+		res := &http.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{"Content-Type": []string{"text/html"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}
+
+		if fixture, ok := fixtures[target.String()]; ok {
+			res.StatusCode = fixture.status
+			res.Body = ioutil.NopCloser(strings.NewReader(fixture.body))
+			if fixture.location != "" {
+				res.Header.Set("Location", fixture.location)
+			}
+		}
+		return res, nil
+	})
+
+	target, err := url.Parse("https://mombo.com/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool := crawler.NewWorkerPool(300, ctx)
+	defer pool.Stop()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.Fetcher = fetcher
+	pages.Headers = http.Header{"X-Token": {"abc"}}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, nil, pool, reports)
+	})
+
+	crawled := map[string]crawler.LinkReport{}
+	for report := range reports {
+		crawled[report.Path.Path] = report
+	}
+
+	if len(crawled) != 2 {
+		tests.Info("Received Pages: %d", len(crawled))
+		tests.Failed("Should have crawled site of recorded fixtures without a client")
+	}
+	tests.Passed("Should have crawled site of recorded fixtures without a client")
+
+	var redirected, missing bool
+	for _, kid := range crawled["/"].PointsTo {
+		switch kid.Path.Path {
+		case "/old-about":
+			redirected = kid.RedirectedTo != nil && kid.RedirectedTo.Path == "/about" && len(kid.Redirects) == 1
+		case "/missing":
+			missing = !kid.Status.IsLive && kid.Status.LastStatus == http.StatusNotFound
+		}
+	}
+
+	if !redirected || !missing {
+		tests.Info("Redirected: %t, Missing: %t", redirected, missing)
+		tests.Failed("Should have followed redirects and checked statuses of fetched responses")
+	}
+	tests.Passed("Should have followed redirects and checked statuses of fetched responses")
+
+	if atomic.LoadInt64(&sent) == 0 {
+		tests.Failed("Should have sent requests through fetcher")
+	}
+	tests.Passed("Should have sent requests through fetcher")
+
+	if atomic.LoadInt64(&identified) != atomic.LoadInt64(&sent) {
+		tests.Info("Identified: %d, Sent: %d", atomic.LoadInt64(&identified), atomic.LoadInt64(&sent))
+		tests.Failed("Should have given fetcher the user agent and headers of every request")
+	}
+	tests.Passed("Should have given fetcher the user agent and headers of every request")
+}
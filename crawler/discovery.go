@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"sort"
+	"sync"
+)
+
+// MaxDiscoverySources sets the maximum distinct pages kept as the sources of
+// a discovered url, as urls linked from a site's navigation are discovered
+// from nearly every page.
+const MaxDiscoverySources = 5
+
+// Discovery embodies the record of how often a giving url was discovered
+// during a crawl and the first distinct pages which linked to it, at most
+// MaxDiscoverySources of them.
+type Discovery struct {
+	URL     string   `json:"url"`
+	Count   int      `json:"count"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// DiscoveryStats implements a concurrent-safe tracker of url discoveries made
+// by the crawler, recording every time a url was found even though it gets
+// crawled only once.
+type DiscoveryStats struct {
+	ml    sync.Mutex
+	total int
+	data  map[string]*Discovery
+}
+
+// NewDiscoveryStats returns a new instance of a DiscoveryStats.
+func NewDiscoveryStats() *DiscoveryStats {
+	return &DiscoveryStats{
+		data: map[string]*Discovery{},
+	}
+}
+
+// Record adds a discovery of target url from giving source page.
+func (d *DiscoveryStats) Record(target string, source string) {
+	d.ml.Lock()
+	defer d.ml.Unlock()
+
+	d.total++
+
+	discovery, ok := d.data[target]
+	if !ok {
+		discovery = &Discovery{URL: target}
+		d.data[target] = discovery
+	}
+
+	discovery.Count++
+	if len(discovery.Sources) >= MaxDiscoverySources {
+		return
+	}
+
+	for _, known := range discovery.Sources {
+		if known == source {
+			return
+		}
+	}
+
+	discovery.Sources = append(discovery.Sources, source)
+}
+
+// Total returns total discoveries made, including duplicates.
+func (d *DiscoveryStats) Total() int {
+	d.ml.Lock()
+	defer d.ml.Unlock()
+	return d.total
+}
+
+// Unique returns total unique urls discovered.
+func (d *DiscoveryStats) Unique() int {
+	d.ml.Lock()
+	defer d.ml.Unlock()
+	return len(d.data)
+}
+
+// Ratio returns the fraction of discoveries which were duplicates of
+// already discovered urls.
+func (d *DiscoveryStats) Ratio() float64 {
+	d.ml.Lock()
+	defer d.ml.Unlock()
+
+	if d.total == 0 {
+		return 0
+	}
+
+	return float64(d.total-len(d.data)) / float64(d.total)
+}
+
+// Top returns the n most redundantly linked urls, ordered by discovery count.
+// If n is less than zero, then all discoveries are returned.
+func (d *DiscoveryStats) Top(n int) []Discovery {
+	d.ml.Lock()
+	defer d.ml.Unlock()
+
+	discoveries := make([]Discovery, 0, len(d.data))
+	for _, discovery := range d.data {
+		item := *discovery
+		item.Sources = append([]string(nil), discovery.Sources...)
+		discoveries = append(discoveries, item)
+	}
+
+	sort.Slice(discoveries, func(i, j int) bool {
+		if discoveries[i].Count == discoveries[j].Count {
+			return discoveries[i].URL < discoveries[j].URL
+		}
+		return discoveries[i].Count > discoveries[j].Count
+	})
+
+	if n >= 0 && n < len(discoveries) {
+		discoveries = discoveries[:n]
+	}
+
+	return discoveries
+}
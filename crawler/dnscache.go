@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultDNSTTL sets how long a DNSCache keeps resolved addresses when no ttl
+// is provided.
+const DefaultDNSTTL = time.Minute
+
+// DNSCache implements a concurrent-safe caching HostResolver, so hosts of a
+// crawl are resolved once per ttl rather than for every connection. Concurrent
+// lookups of a host not yet cached share a single lookup. Failed lookups are
+// not cached.
+//
+// The ttl applies to all hosts, as resolvers of the standard library do not
+// expose the ttl of records.
+type DNSCache struct {
+	resolver HostResolver
+	ttl      time.Duration
+	ml       sync.Mutex
+	hits     int
+	misses   int
+	entries  map[string]*dnsEntry
+}
+
+// dnsEntry embodies the addresses of a host, which are available once done
+// is closed.
+type dnsEntry struct {
+	addrs   []string
+	err     error
+	expires time.Time
+	done    chan struct{}
+}
+
+// NewDNSCache returns a new instance of a DNSCache resolving hosts with giving
+// resolver, keeping their addresses for ttl. A zero ttl defaults to DefaultDNSTTL.
+func NewDNSCache(resolver HostResolver, ttl time.Duration) *DNSCache {
+	if ttl <= 0 {
+		ttl = DefaultDNSTTL
+	}
+
+	return &DNSCache{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  map[string]*dnsEntry{},
+	}
+}
+
+// LookupHost returns the addresses of giving host, from the cache if resolved
+// within the ttl.
+func (d *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	d.ml.Lock()
+	entry, ok := d.entries[host]
+	if ok && !d.expired(entry, time.Now()) {
+		d.hits++
+		d.ml.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-entry.done:
+		}
+
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return append([]string(nil), entry.addrs...), nil
+	}
+
+	entry = &dnsEntry{done: make(chan struct{})}
+	d.entries[host] = entry
+	d.misses++
+	d.ml.Unlock()
+
+	addrs, err := d.resolver.LookupHost(ctx, host)
+
+	d.ml.Lock()
+	entry.addrs = addrs
+	entry.err = err
+	entry.expires = time.Now().Add(d.ttl)
+	if err != nil && d.entries[host] == entry {
+		delete(d.entries, host)
+	}
+	d.ml.Unlock()
+
+	close(entry.done)
+
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), addrs...), nil
+}
+
+// Stats returns total lookups answered from the cache and total sent to the
+// underline resolver.
+func (d *DNSCache) Stats() (hits int, misses int) {
+	d.ml.Lock()
+	defer d.ml.Unlock()
+	return d.hits, d.misses
+}
+
+// expired returns true if giving entry is resolved and past it's ttl. It must
+// be called with the lock held.
+func (d *DNSCache) expired(entry *dnsEntry, now time.Time) bool {
+	select {
+	case <-entry.done:
+		return now.After(entry.expires)
+	default:
+		return false
+	}
+}
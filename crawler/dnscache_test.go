@@ -0,0 +1,218 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+// countingResolver implements the HostResolver counting lookups of hosts.
+type countingResolver struct {
+	ml      sync.Mutex
+	lookups int
+	hosts   staticResolver
+}
+
+func (c *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	c.ml.Lock()
+	c.lookups++
+	c.ml.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	return c.hosts.LookupHost(ctx, host)
+}
+
+// Lookups returns total lookups sent to the resolver.
+func (c *countingResolver) Lookups() int {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+	return c.lookups
+}
+
+func TestDNSCache(t *testing.T) {
+	resolver := &countingResolver{hosts: staticResolver{"mombo.com": {"10.0.0.1"}}}
+	cache := NewDNSCache(resolver, 50*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs, err := cache.LookupHost(context.Background(), "mombo.com")
+			if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+				tests.Info("Received Addresses: %+q", addrs)
+				tests.FailedWithError(err, "Should have resolved host")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if resolver.Lookups() != 1 {
+		tests.Info("Received Lookups: %d", resolver.Lookups())
+		tests.Failed("Should have shared a single lookup of host")
+	}
+	tests.Passed("Should have shared a single lookup of host")
+
+	if hits, misses := cache.Stats(); hits != 9 || misses != 1 {
+		tests.Info("Received Stats: %d hits, %d misses", hits, misses)
+		tests.Failed("Should have counted cache hits and misses")
+	}
+	tests.Passed("Should have counted cache hits and misses")
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := cache.LookupHost(context.Background(), "mombo.com"); err != nil || resolver.Lookups() != 2 {
+		tests.Info("Received Lookups: %d", resolver.Lookups())
+		tests.Failed("Should have resolved host again after ttl")
+	}
+	tests.Passed("Should have resolved host again after ttl")
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.LookupHost(context.Background(), "gracehound.com"); err == nil {
+			tests.Failed("Should have failed to resolve unknown host")
+		}
+	}
+
+	if resolver.Lookups() != 4 {
+		tests.Info("Received Lookups: %d", resolver.Lookups())
+		tests.Failed("Should have not cached failed lookups")
+	}
+	tests.Passed("Should have not cached failed lookups")
+}
+
+func TestNewTransportResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed server url")
+	}
+	port := serverURL.Port()
+
+	resolver := &countingResolver{hosts: staticResolver{"mombo.test": {"::1", "127.0.0.1"}}}
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: NewTransport(TransportOptions{
+			IPFamily: IPFamilyV4,
+			Resolver: NewDNSCache(resolver, time.Minute),
+		}),
+	}
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://mombo.test:"+port+"/", nil)
+		req.Close = true
+
+		res, err := client.Do(req)
+		if err != nil {
+			tests.FailedWithError(err, "Should have dialed resolved address of host")
+		}
+		res.Body.Close()
+	}
+	tests.Passed("Should have dialed resolved address of host")
+
+	if resolver.Lookups() != 1 {
+		tests.Info("Received Lookups: %d", resolver.Lookups())
+		tests.Failed("Should have resolved host once across connections")
+	}
+	tests.Passed("Should have resolved host once across connections")
+
+	client.Transport = NewTransport(TransportOptions{
+		IPFamily: IPFamilyV6,
+		Resolver: staticResolver{"mombo.test": {"127.0.0.1"}},
+	})
+
+	if _, err := client.Get("http://mombo.test:" + port + "/"); err == nil {
+		tests.Failed("Should have failed to dial host without addresses of family")
+	}
+	tests.Passed("Should have failed to dial host without addresses of family")
+
+	if _, err := client.Get("http://unknown.test:" + port + "/"); err == nil {
+		tests.Failed("Should have failed to dial unresolved host")
+	}
+	tests.Passed("Should have failed to dial unresolved host")
+}
+
+func TestDialResolvedHappyEyeballs(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully listened on loopback")
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// Dials to the primary address stall till cancelled, as would those of an
+	// unreachable family.
+	dialer := &net.Dialer{
+		FallbackDelay: 50 * time.Millisecond,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if strings.HasPrefix(address, "[::1]") {
+				time.Sleep(2 * time.Second)
+				return errors.New("stalled")
+			}
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resolver := staticResolver{"mombo.test": {"::1", "127.0.0.1"}}
+
+	start := time.Now()
+	conn, err := dialResolved(ctx, dialer, resolver, IPFamilyAuto, "mombo.test:"+port)
+	if err != nil {
+		tests.FailedWithError(err, "Should have dialed fallback address of host")
+	}
+	defer conn.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		tests.Info("Elapsed: %s", elapsed)
+		tests.Failed("Should have raced fallback address against stalled primary")
+	}
+
+	if !strings.HasPrefix(conn.RemoteAddr().String(), "127.0.0.1:") {
+		tests.Info("Remote: %s", conn.RemoteAddr())
+		tests.Failed("Should have connected to fallback address of host")
+	}
+	tests.Passed("Should have raced fallback address against stalled primary")
+
+	resolver = staticResolver{"mombo.test": {"::1", "127.0.0.1"}}
+	dialer = &net.Dialer{FallbackDelay: time.Minute}
+
+	start = time.Now()
+	fallback, err := dialResolved(ctx, dialer, resolver, IPFamilyAuto, "mombo.test:"+port)
+	if err != nil {
+		tests.FailedWithError(err, "Should have dialed fallback address once primaries failed")
+	}
+	defer fallback.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		tests.Info("Elapsed: %s", elapsed)
+		tests.Failed("Should have dialed fallback address once primaries failed")
+	}
+	tests.Passed("Should have dialed fallback address once primaries failed")
+}
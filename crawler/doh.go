@@ -0,0 +1,145 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// errors ...
+var (
+	ErrInvalidDoHEndpoint = errors.New("invalid dns-over-https endpoint, expected http or https url")
+	ErrDoHFailed          = errors.New("dns-over-https query failed")
+)
+
+// maxDoHResponseSize sets the most bytes read of a dns-over-https response, the
+// maximum size of a dns message.
+const maxDoHResponseSize = 65535
+
+// DoHResolver implements a HostResolver resolving hosts through a
+// dns-over-https endpoint per RFC 8484, such as https://1.1.1.1/dns-query, for
+// environments where the system resolver can't resolve the target or where
+// many crawlers must resolve hosts alike. The endpoint's own host is resolved
+// by the system resolver, so endpoints are best given by ip address.
+type DoHResolver struct {
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewDoHResolver returns a new instance of a DoHResolver sending queries to
+// giving endpoint with the provided client, defaulting to http.DefaultClient.
+func NewDoHResolver(endpoint string, client *http.Client) (*DoHResolver, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, fmt.Errorf("%+s: %+q", ErrInvalidDoHEndpoint, endpoint)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &DoHResolver{endpoint: parsed, client: client}, nil
+}
+
+// LookupHost returns the ipv4 and ipv6 addresses of giving host, ipv4 addresses
+// first. Ip addresses are returned as is.
+func (d *DoHResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	var addrs []string
+	var lookupErr error
+	for _, kind := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		found, err := d.query(ctx, host, kind)
+		if err != nil {
+			lookupErr = err
+			continue
+		}
+		addrs = append(addrs, found...)
+	}
+
+	if len(addrs) != 0 {
+		return addrs, nil
+	}
+
+	if lookupErr != nil {
+		return nil, &net.DNSError{Err: lookupErr.Error(), Name: host, Server: d.endpoint.Host}
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, Server: d.endpoint.Host, IsNotFound: true}
+}
+
+// query sends a query of giving record type for host to the endpoint, returning
+// the addresses answered.
+func (d *DoHResolver) query(ctx context.Context, host string, kind dnsmessage.Type) ([]string, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+
+	// Queries use an id of 0, as advised by RFC 8484 for cache friendliness.
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: kind, Class: dnsmessage.ClassINET}},
+	}
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint.String(), bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeBody(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%+s: %+q", ErrDoHFailed, res.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxDoHResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, err
+	}
+
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+	default:
+		return nil, fmt.Errorf("%+s: %+q", ErrDoHFailed, answer.RCode.String())
+	}
+
+	var addrs []string
+	for _, resource := range answer.Answers {
+		switch body := resource.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IP(body.AAAA[:]).String())
+		}
+	}
+	return addrs, nil
+}
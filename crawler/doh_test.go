@@ -0,0 +1,115 @@
+package crawler
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDoHResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var query dnsmessage.Message
+		if err := query.Unpack(body); err != nil || len(query.Questions) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		question := query.Questions[0]
+		answer := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+
+		switch question.Name.String() {
+		case "mombo.test.":
+			resource := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: 60}
+			switch question.Type {
+			case dnsmessage.TypeA:
+				answer.Answers = append(answer.Answers, dnsmessage.Resource{Header: resource, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}})
+			case dnsmessage.TypeAAAA:
+				var ip [16]byte
+				copy(ip[:], net.ParseIP("fd00::1"))
+				answer.Answers = append(answer.Answers, dnsmessage.Resource{Header: resource, Body: &dnsmessage.AAAAResource{AAAA: ip}})
+			}
+		case "broken.test.":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		default:
+			answer.RCode = dnsmessage.RCodeNameError
+		}
+
+		packed, err := answer.Pack()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	defer server.Close()
+
+	for _, invalid := range []string{"", "1.1.1.1", "ftp://1.1.1.1/dns-query", "https:///dns-query"} {
+		if _, err := NewDoHResolver(invalid, nil); err == nil {
+			tests.Info("Endpoint: %q", invalid)
+			tests.Failed("Should have failed to create resolver of invalid endpoint")
+		}
+	}
+	tests.Passed("Should have failed to create resolver of invalid endpoint")
+
+	resolver, err := NewDoHResolver(server.URL+"/dns-query", server.Client())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created resolver")
+	}
+	tests.Passed("Should have successfully created resolver")
+
+	addrs, err := resolver.LookupHost(context.Background(), "mombo.test")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully resolved host")
+	}
+	tests.Passed("Should have successfully resolved host")
+
+	if len(addrs) != 2 || addrs[0] != "10.0.0.1" || addrs[1] != "fd00::1" {
+		tests.Info("Addrs: %+q", addrs)
+		tests.Failed("Should have resolved ipv4 then ipv6 addresses of host")
+	}
+	tests.Passed("Should have resolved ipv4 then ipv6 addresses of host")
+
+	addrs, err = resolver.LookupHost(context.Background(), "::1")
+	if err != nil || len(addrs) != 1 || addrs[0] != "::1" {
+		tests.Info("Addrs: %+q", addrs)
+		tests.Failed("Should have returned ip address as is")
+	}
+	tests.Passed("Should have returned ip address as is")
+
+	_, err = resolver.LookupHost(context.Background(), "missing.test")
+	if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed to resolve missing host as not found")
+	}
+	tests.Passed("Should have failed to resolve missing host as not found")
+
+	_, err = resolver.LookupHost(context.Background(), "broken.test")
+	if dnsErr, ok := err.(*net.DNSError); !ok || dnsErr.IsNotFound {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed to resolve host of failing endpoint")
+	}
+	tests.Passed("Should have failed to resolve host of failing endpoint")
+}
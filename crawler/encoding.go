@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding lists the content encodings negotiated with hosts, which are
+// decoded before pages are parsed.
+const acceptEncoding = "gzip, br"
+
+// encodedBody implements a response body decoding it's content encoding,
+// counting bytes received and decoded so the savings of compression can be
+// reported.
+type encodedBody struct {
+	body     io.ReadCloser
+	encoding string
+	raw      *countingReader
+	decoder  io.Reader
+	decoded  int64
+}
+
+// decodeBody replaces the body of giving response with an encodedBody decoding
+// it's content encoding. Responses of unsupported encodings are left as is.
+func decodeBody(res *http.Response) {
+	if res.Request != nil && res.Request.Method == http.MethodHead {
+		return
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		encoding = ""
+	case "gzip", "x-gzip", "br":
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Uncompressed = true
+	default:
+		return
+	}
+
+	res.Body = &encodedBody{
+		body:     res.Body,
+		encoding: encoding,
+		raw:      &countingReader{r: res.Body},
+	}
+}
+
+// Read implements the io.Reader interface.
+func (e *encodedBody) Read(p []byte) (int, error) {
+	if e.decoder == nil {
+		switch e.encoding {
+		case "gzip", "x-gzip":
+			decoder, err := gzip.NewReader(e.raw)
+			if err != nil {
+				return 0, err
+			}
+			e.decoder = decoder
+		case "br":
+			e.decoder = brotli.NewReader(e.raw)
+		default:
+			e.decoder = e.raw
+		}
+	}
+
+	n, err := e.decoder.Read(p)
+	e.decoded += int64(n)
+	return n, err
+}
+
+// Close implements the io.Closer interface.
+func (e *encodedBody) Close() error {
+	return e.body.Close()
+}
+
+// recordEncoding records the content encoding of giving body into report, with
+// the bytes received and decoded from it so far.
+func recordEncoding(report *LinkReport, body io.ReadCloser) {
+	for body != nil {
+		switch wrapped := body.(type) {
+		case *encodedBody:
+			report.ContentEncoding = wrapped.encoding
+			report.EncodedSize = wrapped.raw.n
+			report.DecodedSize = wrapped.decoded
+			return
+		case interface{ unwrap() io.ReadCloser }:
+			body = wrapped.unwrap()
+		default:
+			return
+		}
+	}
+}
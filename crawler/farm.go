@@ -0,0 +1,868 @@
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// ErrNonNavigational is returned when parsing links of schemes which address no
+// page to crawl, such as mailto:, tel:, javascript: or data:.
+var ErrNonNavigational = errors.New("non-navigational url scheme, expected http or https")
+
+// contactSchemes are the schemes of links addressing contacts rather than
+// pages, recorded apart from the page's links for contact audits.
+var contactSchemes = map[string]bool{
+	"mailto": true,
+	"tel":    true,
+}
+
+// farmWithGoquery takes a given url and retrieves the needed links associated with
+// that URL.
+func farmWithGoquery(content io.Reader, rootURL *url.URL) (map[*url.URL]struct{}, error) {
+	doc, err := goquery.NewDocumentFromReader(content)
+	if err != nil {
+		return nil, err
+	}
+
+	urlMap := make(map[*url.URL]struct{}, 0)
+
+	if bases := doc.Find("base[href]"); bases.Length() != 0 {
+		if base, ok := resolveBase(bases.Get(0).Attr, rootURL); ok {
+			rootURL = base
+		}
+	}
+
+	// Collect all href links within the document. This way we can capture
+	// external,internal and stylesheets within the page.
+	hrefs := doc.Find("[href]")
+	for i := 0; i < hrefs.Length(); i++ {
+		if item, ok := getAttr(hrefs.Get(i).Attr, "href"); ok {
+			trimmedPath := strings.TrimSpace(item.Val)
+			if !strings.Contains(trimmedPath, "javascript:void(0)") {
+				if parsedPath, err := parsePath(trimmedPath, rootURL); err == nil {
+					urlMap[parsedPath] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// Collect all src links within the document. This way we can capture
+	// external,internal and stylesheets within the page.
+	srcs := doc.Find("[src]")
+	for i := 0; i < srcs.Length(); i++ {
+		if item, ok := getAttr(srcs.Get(i).Attr, "src"); ok {
+			trimmedPath := strings.TrimSpace(item.Val)
+			if !strings.Contains(trimmedPath, "javascript:void(0)") {
+				if parsedPath, err := parsePath(trimmedPath, rootURL); err == nil {
+					urlMap[parsedPath] = struct{}{}
+				}
+			}
+		}
+	}
+
+	return urlMap, nil
+}
+
+// LinkPosition defines the region of a page a link was found within.
+type LinkPosition string
+
+// link positions ...
+const (
+	PositionHead    LinkPosition = "head"
+	PositionHeader  LinkPosition = "header"
+	PositionNav     LinkPosition = "nav"
+	PositionAside   LinkPosition = "aside"
+	PositionFooter  LinkPosition = "footer"
+	PositionContent LinkPosition = "content"
+)
+
+// regionTags maps elements to the page region they define.
+var regionTags = map[string]LinkPosition{
+	"head":    PositionHead,
+	"header":  PositionHeader,
+	"nav":     PositionNav,
+	"aside":   PositionAside,
+	"footer":  PositionFooter,
+	"main":    PositionContent,
+	"article": PositionContent,
+}
+
+// regionRoles maps aria landmark roles to the page region they define.
+var regionRoles = map[string]LinkPosition{
+	"banner":        PositionHeader,
+	"navigation":    PositionNav,
+	"complementary": PositionAside,
+	"contentinfo":   PositionFooter,
+	"main":          PositionContent,
+}
+
+// voidTags are elements which never have a closing tag.
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEndTags are elements whose end tag may be omitted, so leaving them
+// unclosed is no parse error.
+var optionalEndTags = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true,
+	"dd": true, "option": true, "optgroup": true, "tr": true, "td": true, "th": true,
+	"thead": true, "tbody": true, "tfoot": true, "colgroup": true, "caption": true,
+	"rb": true, "rt": true, "rtc": true, "rp": true,
+}
+
+// maxParseWarnings sets the total distinct parse warnings recorded per page, so
+// badly broken pages don't bloat their reports.
+const maxParseWarnings = 20
+
+// maxMainText sets the most bytes of the visible text of a page's main element
+// kept, enough to tell empty and not found views apart from content.
+const maxMainText = 512
+
+// maxLinkText sets the most bytes of the anchor text and heading of a link
+// kept.
+const maxLinkText = 200
+
+// hiddenTags lists elements whose text is not rendered.
+var hiddenTags = map[string]bool{
+	"script": true, "style": true, "template": true, "noscript": true,
+}
+
+// headingTags lists the heading elements titling the sections of a page.
+var headingTags = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// visibleText returns true if text within giving open elements is rendered.
+func visibleText(open []openElement) bool {
+	return len(open) == 0 || !hiddenTags[open[len(open)-1].tag]
+}
+
+// appendText returns giving text followed by more with it's whitespace
+// collapsed, cut at limit bytes without splitting a character.
+func appendText(text string, more string, limit int) string {
+	if len(text) >= limit {
+		return text
+	}
+
+	more = strings.Join(strings.Fields(more), " ")
+	if more == "" {
+		return text
+	}
+
+	if text != "" {
+		more = " " + more
+	}
+
+	text += more
+	if len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	return text
+}
+
+// withinMain returns true if text within giving open elements is visible text
+// of a main element.
+func withinMain(open []openElement) bool {
+	if !visibleText(open) {
+		return false
+	}
+
+	for _, element := range open {
+		if element.main {
+			return true
+		}
+	}
+	return false
+}
+
+// linkContext embodies the context within a page a link was farmed from, with
+// the rel values and text of the anchor it was farmed from and the heading of
+// the section it lies within. Origin holds the element and attribute the link
+// was farmed from, such as a[href] or img[srcset], or the element alone for
+// links farmed from it's content, such as style or script. Index holds the
+// order the link was found in, so links are checked and crawled in the order
+// they're found.
+type linkContext struct {
+	Index    int
+	Position LinkPosition
+	Rel      []string
+	Text     string
+	Heading  string
+	Origin   string
+}
+
+// addLink adds giving link into links with it's context, numbered after the
+// links found before it.
+func addLink(links map[*url.URL]linkContext, link *url.URL, linkCtx linkContext) {
+	linkCtx.Index = len(links)
+	links[link] = linkCtx
+}
+
+// orderedLinks returns the links of giving set in the order they were found.
+func orderedLinks(links map[*url.URL]linkContext) []*url.URL {
+	ordered := make([]*url.URL, 0, len(links))
+	for link := range links {
+		ordered = append(ordered, link)
+	}
+
+	sort.Slice(ordered, func(i, j int) bool {
+		first, second := links[ordered[i]].Index, links[ordered[j]].Index
+		if first != second {
+			return first < second
+		}
+		return ordered[i].String() < ordered[j].String()
+	})
+	return ordered
+}
+
+// openAnchor embodies an <a> element yet to be closed, with the links it
+// holds and the text read within it so far.
+type openAnchor struct {
+	links []*url.URL
+	text  string
+	label string
+}
+
+// close sets the anchor's text on the context of it's links within giving
+// links, being the text read within it, else it's aria-label or title.
+func (a *openAnchor) close(links map[*url.URL]linkContext) {
+	text := a.text
+	if text == "" {
+		text = a.label
+	}
+
+	for _, link := range a.links {
+		linkCtx := links[link]
+		linkCtx.Text = text
+		links[link] = linkCtx
+	}
+}
+
+// pageDocument embodies the data farmed from a html page, with the robots
+// directives of it's meta tags and headers, the mailto: and tel: addresses
+// it links to, the shingles of it's structure and the warnings raised while
+// parsing it. HasMain marks pages with a main element, whose visible text is
+// kept in MainText up to maxMainText bytes. Canonical holds the url of the
+// page's first <link rel="canonical"> and Alternates the localized versions
+// declared by it's <link rel="alternate" hreflang> elements, in order. Social
+// holds it's Open Graph and Twitter Card tags and Structured the types of it's
+// JSON-LD blocks and microdata.
+type pageDocument struct {
+	Title      string
+	Language   string
+	Direction  string
+	Canonical  *url.URL
+	Alternates []Alternate
+	Social     SocialTags
+	Structured StructuredData
+	HasMain    bool
+	MainText   string
+	Robots     []string
+	Contacts   []string
+	Warnings   []string
+	Shingles   []uint64
+	Links      map[*url.URL]linkContext
+	JSONLinks  map[*url.URL]linkContext
+}
+
+// openElement embodies an element yet to be closed and the page region
+// it lies within.
+type openElement struct {
+	tag      string
+	position LinkPosition
+	main     bool
+}
+
+// farmWithHTML returns all links farmed from giving html content.
+func farmWithHTML(content io.Reader, rootURL *url.URL) map[*url.URL]linkContext {
+	return farmDocument(content, rootURL).Links
+}
+
+// farmDocument tokenizes giving html content, retrieving the page's title, it's
+// declared language and direction and all links resolved against the rootURL,
+// or the document's <base href> once declared. Links carry the text of their
+// anchor and the heading last read before them. Links include the url() and
+// @import references of inline styles, while the url-shaped strings of json
+// embedded in <script> elements are farmed apart as JSONLinks. Failures to read the content and
+// invalid markup, such as unclosed or stray tags, are recorded as the page's
+// warnings rather than failing the page.
+func farmDocument(content io.Reader, rootURL *url.URL) pageDocument {
+	tokenizer := html.NewTokenizer(content)
+	urlMap := make(map[*url.URL]linkContext, 0)
+
+	var page pageDocument
+	page.Links = urlMap
+	page.JSONLinks = map[*url.URL]linkContext{}
+
+	// Only the first <base> with a href sets the document's base url.
+	var hasBase bool
+	baseURL := rootURL
+
+	contacts := map[string]bool{}
+	tagPaths := map[string]bool{}
+
+	warned := map[string]bool{}
+	warn := func(format string, args ...interface{}) {
+		warning := fmt.Sprintf(format, args...)
+		if !warned[warning] && len(page.Warnings) < maxParseWarnings {
+			warned[warning] = true
+			page.Warnings = append(page.Warnings, warning)
+		}
+	}
+
+	var inTitle, inStyle, inJSON, inLDJSON bool
+	var ldJSON []byte
+	var open []openElement
+
+	// The heading of the section being read is kept once it's element is
+	// closed, so links within a heading are under the previous one.
+	var heading, headingTag, headingText string
+	var anchor *openAnchor
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				warn("read failed: %s", err)
+			} else if len(tokenizer.Raw()) != 0 {
+				warn("document ends within a tag")
+			}
+
+			for _, element := range open {
+				if !optionalEndTags[element.tag] {
+					warn("unclosed <%s>", element.tag)
+				}
+			}
+
+			if anchor != nil {
+				anchor.close(urlMap)
+			}
+
+			page.Shingles = tagPathShingles(tagPaths)
+			return page
+		case html.CommentToken:
+			if raw := tokenizer.Raw(); bytes.HasPrefix(raw, []byte("<!--")) && !bytes.HasSuffix(raw, []byte("-->")) {
+				warn("unterminated comment")
+			}
+		case html.TextToken:
+			// Text is only returned on the first call for a token.
+			text := tokenizer.Text()
+
+			if inTitle {
+				page.Title += string(text)
+			}
+
+			if withinMain(open) {
+				page.MainText = appendText(page.MainText, string(text), maxMainText)
+			}
+
+			if visibleText(open) {
+				if headingTag != "" {
+					headingText = appendText(headingText, string(text), maxLinkText)
+				}
+				if anchor != nil {
+					anchor.text = appendText(anchor.text, string(text), maxLinkText)
+				}
+			}
+
+			if inStyle {
+				position := PositionContent
+				if len(open) != 0 {
+					position = open[len(open)-1].position
+				}
+
+				for _, ref := range scanCSS(string(text)) {
+					if parsedPath, err := parsePath(ref, baseURL); err == nil {
+						addLink(urlMap, parsedPath, linkContext{Position: position, Heading: heading, Origin: "style"})
+					}
+				}
+			}
+
+			if inJSON {
+				position := PositionContent
+				if len(open) != 0 {
+					position = open[len(open)-1].position
+				}
+
+				for _, ref := range scanJSON(bytes.NewReader(text)) {
+					if parsedPath, err := parsePath(ref, baseURL); err == nil {
+						addLink(page.JSONLinks, parsedPath, linkContext{Position: position, Heading: heading, Origin: "script"})
+					}
+				}
+			}
+
+			if inLDJSON {
+				ldJSON = append(ldJSON, text...)
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "title" {
+				inTitle = false
+				page.Title = strings.TrimSpace(page.Title)
+			}
+
+			if string(name) == "style" {
+				inStyle = false
+			}
+
+			if string(name) == "script" {
+				if inLDJSON {
+					farmJSONLD(&page.Structured, ldJSON)
+				}
+				inJSON, inLDJSON, ldJSON = false, false, nil
+			}
+
+			if string(name) == headingTag {
+				if headingText != "" {
+					heading = headingText
+				}
+				headingTag = ""
+			}
+
+			if string(name) == "a" && anchor != nil {
+				anchor.close(urlMap)
+				anchor = nil
+			}
+
+			// Close the nearest open element of the tag, including any
+			// unclosed elements within it.
+			closed := voidTags[string(name)]
+			for index := len(open) - 1; index >= 0; index-- {
+				if open[index].tag == string(name) {
+					for _, element := range open[index+1:] {
+						if !optionalEndTags[element.tag] {
+							warn("unclosed <%s> before </%s>", element.tag, name)
+						}
+					}
+
+					open = open[:index]
+					closed = true
+					break
+				}
+			}
+
+			if !closed {
+				warn("stray </%s>", name)
+			}
+		case html.SelfClosingTagToken, html.StartTagToken:
+			token := tokenizer.Token()
+
+			if token.Data == "title" && token.Type == html.StartTagToken && page.Title == "" {
+				inTitle = true
+			}
+
+			if token.Data == "style" && token.Type == html.StartTagToken {
+				inStyle = true
+			}
+
+			if token.Data == "script" && token.Type == html.StartTagToken {
+				if scriptType, ok := getAttr(token.Attr, "type"); ok {
+					mediaType, _, _ := mime.ParseMediaType(scriptType.Val)
+					inJSON = isJSONType(mediaType)
+					inLDJSON = strings.EqualFold(mediaType, "application/ld+json")
+				}
+			}
+
+			if headingTags[token.Data] && token.Type == html.StartTagToken {
+				headingTag, headingText = token.Data, ""
+			}
+
+			// Anchors can't nest, so an unclosed anchor ends where another
+			// starts.
+			if token.Data == "a" {
+				if anchor != nil {
+					anchor.close(urlMap)
+					anchor = nil
+				}
+
+				if token.Type == html.StartTagToken {
+					anchor = &openAnchor{label: anchorLabel(token.Attr)}
+				}
+			}
+
+			if token.Data == "img" && anchor != nil {
+				if alt, ok := getAttr(token.Attr, "alt"); ok {
+					anchor.text = appendText(anchor.text, alt.Val, maxLinkText)
+				}
+			}
+
+			if token.Data == "html" {
+				if lang, ok := getAttr(token.Attr, "lang"); ok && page.Language == "" {
+					page.Language = strings.TrimSpace(lang.Val)
+				}
+				if dir, ok := getAttr(token.Attr, "dir"); ok && page.Direction == "" {
+					page.Direction = strings.ToLower(strings.TrimSpace(dir.Val))
+				}
+			}
+
+			if token.Data == "base" && !hasBase {
+				if _, ok := getAttr(token.Attr, "href"); ok {
+					hasBase = true
+					if base, ok := resolveBase(token.Attr, rootURL); ok {
+						baseURL = base
+					}
+				}
+			}
+
+			if token.Data == "meta" {
+				if name, ok := getAttr(token.Attr, "name"); ok && strings.EqualFold(strings.TrimSpace(name.Val), "robots") {
+					if content, ok := getAttr(token.Attr, "content"); ok {
+						page.Robots = parseRobotsContent(page.Robots, content.Val)
+					}
+				}
+
+				farmSocial(&page.Social, token.Attr, baseURL)
+			}
+
+			farmMicrodata(&page.Structured, token.Attr)
+
+			position := PositionContent
+			if len(open) != 0 {
+				position = open[len(open)-1].position
+			}
+
+			tagPaths[tagPath(open, token.Data)] = true
+
+			if token.Type == html.StartTagToken && !voidTags[token.Data] {
+				element := openElement{tag: token.Data, position: position}
+				if region, ok := regionTags[token.Data]; ok {
+					element.position = region
+				}
+				if role, ok := getAttr(token.Attr, "role"); ok {
+					if region, ok := regionRoles[strings.ToLower(role.Val)]; ok {
+						element.position = region
+					}
+					element.main = strings.EqualFold(strings.TrimSpace(role.Val), "main")
+				}
+
+				if token.Data == "main" {
+					element.main = true
+				}
+				page.HasMain = page.HasMain || element.main
+
+				open = append(open, element)
+				position = element.position
+			}
+
+			// Rel values of <link> elements tell canonical, alternate and
+			// paginated pages apart from stylesheets and preloads.
+			link := linkContext{Position: position, Heading: heading}
+			if token.Data == "a" || token.Data == "area" || token.Data == "link" {
+				if rel, ok := getAttr(token.Attr, "rel"); ok {
+					link.Rel = strings.Fields(strings.ToLower(rel.Val))
+				}
+			}
+
+			if token.Data == "link" && page.Canonical == nil && hasString(link.Rel, "canonical") {
+				if href, ok := getAttr(token.Attr, "href"); ok {
+					if canonical, err := parsePath(strings.TrimSpace(href.Val), baseURL); err == nil {
+						page.Canonical = canonical
+					}
+				}
+			}
+
+			if token.Data == "link" && hasString(link.Rel, "alternate") {
+				if alternate, ok := alternateLink(token.Attr, baseURL); ok {
+					page.Alternates = append(page.Alternates, alternate)
+				}
+			}
+
+			// Self-closed anchors and <area> elements hold no text.
+			if token.Data == "area" || (token.Data == "a" && anchor == nil) {
+				if alt, ok := getAttr(token.Attr, "alt"); ok {
+					link.Text = appendText("", alt.Val, maxLinkText)
+				}
+				if link.Text == "" {
+					link.Text = anchorLabel(token.Attr)
+				}
+			}
+
+			// if we dont have any attribute then skip.
+			if len(token.Attr) == 0 {
+				continue
+			}
+
+			if target, attr, ok := elementTarget(token); ok {
+				if parsedPath, err := parsePath(target, baseURL); err == nil {
+					addLink(urlMap, parsedPath, withOrigin(link, token.Data, attr))
+				}
+			}
+
+			for _, attr := range token.Attr {
+				key := strings.ToLower(attr.Key)
+				switch key {
+				case "href":
+					if strings.Contains(attr.Val, "javascript:void(0)") {
+						continue
+					}
+
+					if contact, ok := parseContact(attr.Val); ok {
+						if !contacts[contact] {
+							contacts[contact] = true
+							page.Contacts = append(page.Contacts, contact)
+						}
+						continue
+					}
+
+					if parsedPath, err := parsePath(attr.Val, baseURL); err == nil {
+						addLink(urlMap, parsedPath, withOrigin(link, token.Data, key))
+						if token.Data == "a" && anchor != nil {
+							anchor.links = append(anchor.links, parsedPath)
+						}
+					}
+				case "src":
+					if strings.Contains(attr.Val, "javascript:void(0)") {
+						continue
+					}
+
+					if parsedPath, err := parsePath(attr.Val, baseURL); err == nil {
+						addLink(urlMap, parsedPath, withOrigin(link, token.Data, key))
+					}
+				case "style":
+					for _, ref := range scanCSS(attr.Val) {
+						if parsedPath, err := parsePath(ref, baseURL); err == nil {
+							addLink(urlMap, parsedPath, withOrigin(link, token.Data, key))
+						}
+					}
+				case "srcset":
+					for _, item := range parseSrcset(attr.Val) {
+						if strings.Contains(item, "javascript:void(0)") {
+							continue
+						}
+
+						if parsedPath, err := parsePath(item, baseURL); err == nil {
+							addLink(urlMap, parsedPath, withOrigin(link, token.Data, key))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// anchorLabel returns the aria-label of an anchor given it's attributes, else
+// it's title.
+func anchorLabel(attrs []html.Attribute) string {
+	for _, key := range []string{"aria-label", "title"} {
+		if attr, ok := getAttr(attrs, key); ok {
+			if label := appendText("", attr.Val, maxLinkText); label != "" {
+				return label
+			}
+		}
+	}
+	return ""
+}
+
+// withOrigin returns giving link context with it's origin set to giving
+// element and attribute.
+func withOrigin(link linkContext, element string, attr string) linkContext {
+	link.Origin = element + "[" + attr + "]"
+	return link
+}
+
+// elementTarget returns the url giving element links to through attributes
+// other than href, src and srcset, being the target of a <meta> refresh, the
+// action of a <form> submitted with GET and the data of an <object>, along
+// with the attribute holding it.
+func elementTarget(token html.Token) (string, string, bool) {
+	switch token.Data {
+	case "meta":
+		equiv, ok := getAttr(token.Attr, "http-equiv")
+		if !ok || !strings.EqualFold(strings.TrimSpace(equiv.Val), "refresh") {
+			return "", "", false
+		}
+
+		if content, ok := getAttr(token.Attr, "content"); ok {
+			target, ok := parseRefresh(content.Val)
+			return target, "content", ok
+		}
+	case "form":
+		if method, ok := getAttr(token.Attr, "method"); ok && !strings.EqualFold(strings.TrimSpace(method.Val), "get") {
+			return "", "", false
+		}
+
+		if action, ok := getAttr(token.Attr, "action"); ok && strings.TrimSpace(action.Val) != "" {
+			return strings.TrimSpace(action.Val), "action", true
+		}
+	case "object":
+		if data, ok := getAttr(token.Attr, "data"); ok && strings.TrimSpace(data.Val) != "" {
+			return strings.TrimSpace(data.Val), "data", true
+		}
+	}
+	return "", "", false
+}
+
+// parseRefresh returns the url of giving <meta> refresh content, such as
+// "5; url=/next", per the html spec. Refreshes of the page itself have none.
+func parseRefresh(content string) (string, bool) {
+	index := strings.IndexAny(content, ";,")
+	if index == -1 {
+		return "", false
+	}
+
+	target := strings.TrimLeft(content[index+1:], " \t\n\f\r;,")
+	if len(target) >= 3 && strings.EqualFold(target[:3], "url") {
+		if rest := strings.TrimLeft(target[3:], " \t\n\f\r"); strings.HasPrefix(rest, "=") {
+			target = strings.TrimLeft(rest[1:], " \t\n\f\r")
+		}
+	}
+
+	if len(target) != 0 && (target[0] == '"' || target[0] == '\'') {
+		if end := strings.IndexByte(target[1:], target[0]); end != -1 {
+			target = target[1 : end+1]
+		} else {
+			target = target[1:]
+		}
+	}
+
+	target = strings.TrimSpace(target)
+	return target, target != ""
+}
+
+// parseSrcset returns the urls of the image candidates of giving srcset, per
+// the html spec, without their width or density descriptors such as "2x" or
+// "640w". Urls may contain commas, which only separate candidates when
+// following whitespace or ending a url.
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for position := 0; position < len(srcset); {
+		for position < len(srcset) && (isSpace(srcset[position]) || srcset[position] == ',') {
+			position++
+		}
+
+		start := position
+		for position < len(srcset) && !isSpace(srcset[position]) {
+			position++
+		}
+
+		candidate := srcset[start:position]
+		if strings.HasSuffix(candidate, ",") {
+			candidate = strings.TrimRight(candidate, ",")
+		} else {
+			position = skipDescriptors(srcset, position)
+		}
+
+		if candidate != "" {
+			urls = append(urls, candidate)
+		}
+	}
+	return urls
+}
+
+// skipDescriptors returns the position following the descriptors of a srcset
+// candidate starting at giving position, being past the next comma outside of
+// parentheses.
+func skipDescriptors(srcset string, position int) int {
+	var inParens bool
+	for ; position < len(srcset); position++ {
+		switch c := srcset[position]; {
+		case c == '(':
+			inParens = true
+		case c == ')':
+			inParens = false
+		case c == ',' && !inParens:
+			return position + 1
+		}
+	}
+	return position
+}
+
+// isSpace returns true if giving byte is ascii whitespace per the html spec.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// tagPath returns the path of giving tag within the open elements, such as
+// html>body>div>ul>li.
+func tagPath(open []openElement, tag string) string {
+	var path strings.Builder
+	for _, element := range open {
+		path.WriteString(element.tag)
+		path.WriteByte('>')
+	}
+	path.WriteString(tag)
+	return path.String()
+}
+
+// getAttr returns the giving attribute for a specific name type if found.
+func getAttr(attrs []html.Attribute, key string) (attr html.Attribute, found bool) {
+	for _, attr = range attrs {
+		if attr.Key == key {
+			found = true
+			return
+		}
+	}
+	return
+}
+
+// resolveBase returns the base url declared by the href of giving <base>
+// attributes, resolved against the rootURL. Bases not of http or https urls
+// are ignored.
+func resolveBase(attrs []html.Attribute, rootURL *url.URL) (*url.URL, bool) {
+	href, ok := getAttr(attrs, "href")
+	if !ok {
+		return nil, false
+	}
+
+	base, err := parsePath(strings.TrimSpace(href.Val), rootURL)
+	if err != nil || base.Scheme == "" {
+		return nil, false
+	}
+	return base, true
+}
+
+// parseContact returns the address of giving link, such as
+// mailto:hello@monzo.com, if it's a mailto: or tel: link. Parameters such as
+// the subject of mailto: links are dropped.
+func parseContact(link string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || !contactSchemes[parsed.Scheme] {
+		return "", false
+	}
+
+	address := parsed.Opaque
+	if unescaped, err := url.PathUnescape(address); err == nil {
+		address = unescaped
+	}
+
+	if address = strings.TrimSpace(address); address == "" {
+		return "", false
+	}
+	return parsed.Scheme + ":" + address, true
+}
+
+// parsePath re-evaluates a giving path string using a root URL path, else
+// returns an error if it fails to validate path as a valid url. Paths of
+// schemes other than http and https, such as mailto: or javascript:, fail
+// with ErrNonNavigational.
+func parsePath(path string, index *url.URL) (*url.URL, error) {
+	pathURI, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !pathURI.IsAbs() {
+		pathURI = index.ResolveReference(pathURI)
+	}
+
+	if pathURI.Scheme != "" && pathURI.Scheme != "http" && pathURI.Scheme != "https" {
+		return nil, fmt.Errorf("%+s: %+q", ErrNonNavigational, pathURI.Scheme)
+	}
+
+	return pathURI, nil
+}
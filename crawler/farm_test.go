@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/influx6/faux/tests"
@@ -68,7 +69,7 @@ func TestLinkFarm(t *testing.T) {
 	if len(farmedLinks) != len(expectedPaths) {
 		tests.Info("Expected Length: %d", len(expectedPaths))
 		tests.Info("Received Length: %d", len(farmedLinks))
-		fmt.Printf("%+q", farmedLinks)
+		fmt.Printf("%+v", farmedLinks)
 		tests.Failed("Should have received same length of expected path from farmed links")
 	}
 	tests.Passed("Should have received same length of expected path from farmed links")
@@ -90,3 +91,582 @@ func TestLinkFarm(t *testing.T) {
 
 	tests.Passed("Should have found all expected links in farmed page.")
 }
+
+func TestFarmDocumentTitle(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader(samplePage), target)
+	if page.Title != "Ardan Studios" {
+		tests.Info("Expected Title: %q", "Ardan Studios")
+		tests.Info("Received Title: %q", page.Title)
+		tests.Failed("Should have farmed page title")
+	}
+	tests.Passed("Should have farmed page title")
+
+	if len(page.Links) != len(expectedPaths) {
+		tests.Info("Expected Length: %d", len(expectedPaths))
+		tests.Info("Received Length: %d", len(page.Links))
+		tests.Failed("Should have farmed page links alongside title")
+	}
+	tests.Passed("Should have farmed page links alongside title")
+}
+
+func TestFarmLinkPositions(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head><link rel="stylesheet" href="/main.css"></head>
+		<body>
+			<header><a href="/logo"></a><nav><ul><li><a href="/services"></a></ul></nav></header>
+			<main><div><div></div><a href="/article"></a></div></main>
+			<div role="contentinfo"><div><a href="/legal"></a></div><a href="/privacy"></a></div>
+			<a href="/orphan"></a>
+		</body>
+		</html>
+	`)), target)
+
+	expected := map[string]LinkPosition{
+		"/main.css": PositionHead,
+		"/logo":     PositionHeader,
+		"/services": PositionNav,
+		"/article":  PositionContent,
+		"/legal":    PositionFooter,
+		"/privacy":  PositionFooter,
+		"/orphan":   PositionContent,
+	}
+
+	if len(page.Links) != len(expected) {
+		tests.Info("Expected Length: %d", len(expected))
+		tests.Info("Received Length: %d", len(page.Links))
+		tests.Failed("Should have farmed all links from page")
+	}
+	tests.Passed("Should have farmed all links from page")
+
+	for link, linkCtx := range page.Links {
+		if expected[link.Path] != linkCtx.Position {
+			tests.Info("Link: %q", link.Path)
+			tests.Info("Expected Position: %q", expected[link.Path])
+			tests.Info("Received Position: %q", linkCtx.Position)
+			tests.Failed("Should have classified link by it's page region")
+		}
+	}
+	tests.Passed("Should have classified all links by their page region")
+}
+
+func TestFarmLinkOrigins(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head>
+			<meta http-equiv="refresh" content="5; url=/next">
+			<link rel="stylesheet" href="/main.css">
+			<script src="/app.js"></script>
+			<style>body { background: url(/bg.png) }</style>
+			<script type="application/json">{"route": "/data"}</script>
+		</head>
+		<body>
+			<a href="/about">About</a>
+			<img src="/logo.png" srcset="/logo-2x.png 2x">
+			<div style="background: url(/hero.png)"></div>
+			<form action="/search"></form>
+			<object data="/doc.pdf"></object>
+		</body>
+		</html>
+	`)), target)
+
+	expected := map[string]string{
+		"/next":        "meta[content]",
+		"/main.css":    "link[href]",
+		"/app.js":      "script[src]",
+		"/bg.png":      "style",
+		"/about":       "a[href]",
+		"/logo.png":    "img[src]",
+		"/logo-2x.png": "img[srcset]",
+		"/hero.png":    "div[style]",
+		"/search":      "form[action]",
+		"/doc.pdf":     "object[data]",
+	}
+
+	if len(page.Links) != len(expected) {
+		tests.Info("Expected Length: %d", len(expected))
+		tests.Info("Received Length: %d", len(page.Links))
+		tests.Failed("Should have farmed all links from page")
+	}
+	tests.Passed("Should have farmed all links from page")
+
+	for link, linkCtx := range page.Links {
+		if expected[link.Path] != linkCtx.Origin {
+			tests.Info("Link: %q", link.Path)
+			tests.Info("Expected Origin: %q", expected[link.Path])
+			tests.Info("Received Origin: %q", linkCtx.Origin)
+			tests.Failed("Should have recorded the origin of link")
+		}
+	}
+	tests.Passed("Should have recorded the origin of all links")
+
+	for link, linkCtx := range page.JSONLinks {
+		if link.Path != "/data" || linkCtx.Origin != "script" {
+			tests.Info("Link: %q, Origin: %q", link.Path, linkCtx.Origin)
+			tests.Failed("Should have recorded the origin of embedded json links")
+		}
+	}
+	tests.Passed("Should have recorded the origin of embedded json links")
+}
+
+func TestFarmLinkText(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<body>
+			<nav><a href="/home">  Home
+				page </a><a href="/search" aria-label="Search"><svg></svg></a></nav>
+			<h1>Current <em>accounts</em></h1>
+			<p>Read about <a href="/fees"><span>our</span> fees<script>var x = 1;</script></a>.</p>
+			<h2></h2>
+			<a href="/apply"><img src="/apply.png" alt="Apply now"></a>
+			<h2><a href="/savings">Savings</a></h2>
+			<map><area href="/map" alt="Branches"></map>
+			<a href="/unclosed">Unclosed <a href="/next">Next</a>
+		</body>
+		</html>
+	`)), target)
+
+	expected := map[string][2]string{
+		"/home":      {"Home page", ""},
+		"/search":    {"Search", ""},
+		"/fees":      {"our fees", "Current accounts"},
+		"/apply":     {"Apply now", "Current accounts"},
+		"/apply.png": {"", "Current accounts"},
+		"/savings":   {"Savings", "Current accounts"},
+		"/map":       {"Branches", "Savings"},
+		"/unclosed":  {"Unclosed", "Savings"},
+		"/next":      {"Next", "Savings"},
+	}
+
+	if len(page.Links) != len(expected) {
+		tests.Info("Expected Length: %d", len(expected))
+		tests.Info("Received Length: %d", len(page.Links))
+		tests.Failed("Should have farmed all links from page")
+	}
+	tests.Passed("Should have farmed all links from page")
+
+	for link, linkCtx := range page.Links {
+		if expected[link.Path] != [2]string{linkCtx.Text, linkCtx.Heading} {
+			tests.Info("Link: %q", link.Path)
+			tests.Info("Expected Text and Heading: %q", expected[link.Path])
+			tests.Info("Received Text and Heading: %q", [2]string{linkCtx.Text, linkCtx.Heading})
+			tests.Failed("Should have farmed anchor text and heading of link")
+		}
+	}
+	tests.Passed("Should have farmed anchor text and heading of all links")
+
+	if text := appendText("", "Ünïcödé", 4); text != "Ün" {
+		tests.Info("Received Text: %q", text)
+		tests.Failed("Should have cut text without splitting a character")
+	}
+	tests.Passed("Should have cut text without splitting a character")
+}
+
+func TestFarmPageLanguage(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`<html lang=" ar-EG " dir="RTL"><body><a href="/services"></a></body></html>`)), target)
+	if page.Language != "ar-EG" || page.Direction != "rtl" {
+		tests.Info("Received Language: %q, Direction: %q", page.Language, page.Direction)
+		tests.Failed("Should have farmed page language and direction")
+	}
+	tests.Passed("Should have farmed page language and direction")
+}
+
+func TestFarmBaseHref(t *testing.T) {
+	target, err := url.Parse("http://mombo.com/blog/post")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head><base href="/docs/v2/"><base href="http://other.com/"><link rel="stylesheet" href="main.css"></head>
+		<body><a href="guide"></a><a href="../v1/guide"></a><a href="/about"></a><a href="http://cdn.mombo.com/app.js"></a></body>
+		</html>
+	`)), target)
+
+	expected := map[string]bool{
+		"http://mombo.com/docs/v2/":         true,
+		"http://mombo.com/docs/v2/main.css": true,
+		"http://mombo.com/docs/v2/guide":    true,
+		"http://mombo.com/docs/v1/guide":    true,
+		"http://mombo.com/about":            true,
+		"http://other.com/":                 true,
+		"http://cdn.mombo.com/app.js":       true,
+	}
+
+	if len(page.Links) != len(expected) {
+		tests.Info("Expected Length: %d", len(expected))
+		tests.Info("Received Length: %d", len(page.Links))
+		tests.Failed("Should have farmed all links from page")
+	}
+	tests.Passed("Should have farmed all links from page")
+
+	for link := range page.Links {
+		if !expected[link.String()] {
+			tests.Info("Link: %q", link.String())
+			tests.Failed("Should have resolved link against first base href")
+		}
+	}
+	tests.Passed("Should have resolved links against first base href")
+
+	page = farmDocument(bytes.NewReader([]byte(`<base href="javascript:alert(1)"><a href="guide"></a>`)), target)
+	for link := range page.Links {
+		if link.Scheme == "http" && link.String() != "http://mombo.com/blog/guide" {
+			tests.Info("Link: %q", link.String())
+			tests.Failed("Should have ignored base href of non-http url")
+		}
+	}
+	tests.Passed("Should have ignored base href of non-http url")
+}
+
+func TestFarmNonNavigationalLinks(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<body>
+			<a href="/about"></a>
+			<a href="mailto:hello@mombo.com?subject=Hi"></a>
+			<a href="MAILTO:hello@mombo.com"></a>
+			<a href="tel:+44%2020%207946%200000"></a>
+			<a href="javascript:alert(1)"></a>
+			<img src="data:image/png;base64,iVBORw0KGgo=">
+			<a href="ftp://files.mombo.com/report.pdf"></a>
+		</body>
+	`)), target)
+
+	if len(page.Links) != 1 {
+		tests.Info("Received Links: %+v", page.Links)
+		tests.Failed("Should have only farmed http and https links")
+	}
+
+	for link := range page.Links {
+		if link.String() != "http://mombo.com/about" {
+			tests.Info("Received Link: %q", link.String())
+			tests.Failed("Should have only farmed http and https links")
+		}
+	}
+	tests.Passed("Should have only farmed http and https links")
+
+	expected := []string{"mailto:hello@mombo.com", "tel:+44 20 7946 0000"}
+	if len(page.Contacts) != len(expected) {
+		tests.Info("Received Contacts: %+q", page.Contacts)
+		tests.Failed("Should have farmed unique contact addresses of page")
+	}
+
+	for index, contact := range page.Contacts {
+		if contact != expected[index] {
+			tests.Info("Received Contacts: %+q", page.Contacts)
+			tests.Failed("Should have farmed unique contact addresses of page")
+		}
+	}
+	tests.Passed("Should have farmed unique contact addresses of page")
+}
+
+func TestParseSrcset(t *testing.T) {
+	for srcset, expected := range map[string][]string{
+		"image.png":                       {"image.png"},
+		"image.png 2x":                    {"image.png"},
+		"small.jpg 480w, large.jpg 1080w": {"small.jpg", "large.jpg"},
+		" a.png 1x,b.png 2x , c.png":      {"a.png", "b.png", "c.png"},
+		"a.png,b.png":                     {"a.png,b.png"},
+		"a.png, b.png,":                   {"a.png", "b.png"},
+		"/img/w_400,h_300/cat.jpg 1x, /img/x.jpg 2x": {"/img/w_400,h_300/cat.jpg", "/img/x.jpg"},
+		"a.png (max-width: 1px, 2px) 1x, b.png 2x":   {"a.png", "b.png"},
+		"\ta.png\n100w,\r\nb.png\f200w":              {"a.png", "b.png"},
+		"":                                           nil,
+	} {
+		urls := parseSrcset(srcset)
+		if len(urls) != len(expected) {
+			tests.Info("Srcset: %q, Received: %+q", srcset, urls)
+			tests.Failed("Should have parsed urls of srcset candidates")
+		}
+
+		for index, link := range urls {
+			if link != expected[index] {
+				tests.Info("Srcset: %q, Received: %+q", srcset, urls)
+				tests.Failed("Should have parsed urls of srcset candidates")
+			}
+		}
+	}
+	tests.Passed("Should have parsed urls of srcset candidates")
+}
+
+func TestFarmAdditionalSources(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head>
+			<meta http-equiv="Refresh" content="5; URL='/moved'">
+			<link rel="canonical" href="/article">
+			<link rel="alternate" hreflang="de" href="/de/article">
+			<link rel="next" href="/article?page=2">
+		</head>
+		<body>
+			<iframe src="/embed"></iframe>
+			<frameset><frame src="/frame"></frameset>
+			<map><area href="/region" rel="nofollow"></map>
+			<form action="/search"><input name="q"></form>
+			<form action="/subscribe" method="post"></form>
+			<object data="/movie.swf"></object>
+		</body>
+		</html>
+	`)), target)
+
+	expected := map[string][]string{
+		"/moved":          nil,
+		"/article":        {"canonical"},
+		"/de/article":     {"alternate"},
+		"/article?page=2": {"next"},
+		"/embed":          nil,
+		"/frame":          nil,
+		"/region":         {"nofollow"},
+		"/search":         nil,
+		"/movie.swf":      nil,
+	}
+
+	if len(page.Links) != len(expected) {
+		tests.Info("Received Links: %+v", page.Links)
+		tests.Failed("Should have farmed links of all navigable sources")
+	}
+
+	for link, linkCtx := range page.Links {
+		rel, ok := expected[link.RequestURI()]
+		if !ok || strings.Join(rel, " ") != strings.Join(linkCtx.Rel, " ") {
+			tests.Info("Link: %q, Rel: %+q", link.RequestURI(), linkCtx.Rel)
+			tests.Failed("Should have farmed links of all navigable sources")
+		}
+	}
+	tests.Passed("Should have farmed links of all navigable sources")
+}
+
+func TestParseRefresh(t *testing.T) {
+	for content, expected := range map[string]string{
+		"5; url=/next":           "/next",
+		"0;URL='http://a.com/b'": "http://a.com/b",
+		`3, url = "/quoted"`:     "/quoted",
+		"1; /bare":               "/bare",
+		"5":                      "",
+		"0; url=":                "",
+	} {
+		if target, _ := parseRefresh(content); target != expected {
+			tests.Info("Content: %q, Received: %q", content, target)
+			tests.Failed("Should have parsed url of refresh")
+		}
+	}
+	tests.Passed("Should have parsed url of refresh")
+}
+
+func TestFarmParseWarnings(t *testing.T) {
+	target, err := url.Parse("http://mombo.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head><title>Mombo</title></head>
+		<body>
+			<ul><li>One<li>Two</ul>
+			<p>Text<br></br>
+			<div><span><a href="/about">About</a></div>
+			</section>
+			<div><a href="/careers">Careers</a>
+	`)), target)
+
+	if len(page.Links) != 2 {
+		tests.Info("Received Links: %+v", page.Links)
+		tests.Failed("Should have farmed links of invalid html")
+	}
+	tests.Passed("Should have farmed links of invalid html")
+
+	expected := []string{"unclosed <span> before </div>", "stray </section>", "unclosed <div>"}
+	if strings.Join(page.Warnings, ", ") != strings.Join(expected, ", ") {
+		tests.Info("Expected: %+q", expected)
+		tests.Info("Received: %+q", page.Warnings)
+		tests.Failed("Should have recorded parse warnings of invalid html")
+	}
+	tests.Passed("Should have recorded parse warnings of invalid html")
+
+	page = farmDocument(bytes.NewReader([]byte(`<html><body><!-- unfinished <a href="/hidden">`)), target)
+	if len(page.Warnings) != 1 || page.Warnings[0] != "unterminated comment" {
+		tests.Info("Received: %+q", page.Warnings)
+		tests.Failed("Should have recorded unterminated comment")
+	}
+	tests.Passed("Should have recorded unterminated comment")
+
+	page = farmDocument(bytes.NewReader([]byte(`<html><body><a href="/cut`)), target)
+	if len(page.Warnings) != 1 || page.Warnings[0] != "document ends within a tag" {
+		tests.Info("Received: %+q", page.Warnings)
+		tests.Failed("Should have recorded document ending within a tag")
+	}
+	tests.Passed("Should have recorded document ending within a tag")
+
+	page = farmDocument(bytes.NewReader([]byte(`<!DOCTYPE html><html><head></head><body><p>Valid</p></body></html>`)), target)
+	if len(page.Warnings) != 0 {
+		tests.Info("Received: %+q", page.Warnings)
+		tests.Failed("Should have recorded no parse warnings of valid html")
+	}
+	tests.Passed("Should have recorded no parse warnings of valid html")
+}
+
+func TestFarmAlternates(t *testing.T) {
+	target, err := url.Parse("http://mombo.com/en/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head>
+			<link rel="alternate" hreflang="en" href="/en/">
+			<link rel="alternate" hreflang=" de-DE " href="../de/">
+			<link rel="alternate" hreflang="x-default" href="/en/">
+			<link rel="alternate" href="/feed.xml" type="application/rss+xml">
+			<link rel="stylesheet" hreflang="fr" href="/fr.css">
+			<link rel="alternate" hreflang="es">
+		</head>
+		</html>
+	`)), target)
+
+	expected := []string{"en http://mombo.com/en/", "de-DE http://mombo.com/de/", "x-default http://mombo.com/en/"}
+
+	var received []string
+	for _, alternate := range page.Alternates {
+		received = append(received, alternate.Hreflang+" "+alternate.URL.String())
+	}
+
+	if strings.Join(received, ", ") != strings.Join(expected, ", ") {
+		tests.Info("Expected: %+q", expected)
+		tests.Info("Received: %+q", received)
+		tests.Failed("Should have farmed hreflang alternates in order")
+	}
+	tests.Passed("Should have farmed hreflang alternates in order")
+}
+
+func TestFarmSocial(t *testing.T) {
+	target, err := url.Parse("http://mombo.com/articles/first")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head>
+			<meta property="og:title" content=" First article ">
+			<meta property="og:title" content="Ignored">
+			<meta property="og:image" content="../images/first.png">
+			<meta name="twitter:card" content="summary_large_image">
+			<meta name="twitter:image:src" content="https://cdn.mombo.com/first.png">
+			<meta property="og:description" content="Not audited">
+			<meta name="twitter:title" content="">
+		</head>
+		</html>
+	`)), target)
+
+	expected := SocialTags{
+		OGTitle:      "First article",
+		OGImage:      "http://mombo.com/images/first.png",
+		TwitterCard:  "summary_large_image",
+		TwitterImage: "https://cdn.mombo.com/first.png",
+	}
+
+	if page.Social.OGTitle != expected.OGTitle || page.Social.OGImage != expected.OGImage || page.Social.TwitterCard != expected.TwitterCard || page.Social.TwitterImage != expected.TwitterImage {
+		tests.Info("Expected: %+v", expected)
+		tests.Info("Received: %+v", page.Social)
+		tests.Failed("Should have farmed the first of each social tag")
+	}
+	tests.Passed("Should have farmed the first of each social tag")
+}
+
+func TestFarmStructuredData(t *testing.T) {
+	target, err := url.Parse("http://mombo.com/articles/first")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head>
+			<script type="application/ld+json">
+				{"@context": "https://schema.org", "@graph": [
+					{"@type": "https://schema.org/Article", "author": {"@type": "Person", "name": "Alex"}},
+					{"@type": ["WebPage", "schema:ItemPage"]}
+				]}
+			</script>
+			<script type="application/ld+json">{"@type": "Organization",}</script>
+			<script type="application/json">{"@type": "Ignored"}</script>
+		</head>
+		<body>
+			<div itemscope itemtype="https://schema.org/Product">
+				<div itemprop="review" itemscope itemtype="http://schema.org/Review"></div>
+			</div>
+		</body>
+		</html>
+	`)), target)
+
+	expectedJSONLD := []string{"Article", "ItemPage", "Person", "WebPage"}
+	if strings.Join(page.Structured.JSONLD, ",") != strings.Join(expectedJSONLD, ",") || page.Structured.Blocks != 2 {
+		tests.Info("Expected: %+q", expectedJSONLD)
+		tests.Info("Received: %+q in %d blocks", page.Structured.JSONLD, page.Structured.Blocks)
+		tests.Failed("Should have farmed the types of all nodes of JSON-LD blocks")
+	}
+	tests.Passed("Should have farmed the types of all nodes of JSON-LD blocks")
+
+	if len(page.Structured.Errors) != 1 || !strings.HasPrefix(page.Structured.Errors[0], "block 2: ") {
+		tests.Info("Received: %+q", page.Structured.Errors)
+		tests.Failed("Should have recorded the parse error of the invalid JSON-LD block")
+	}
+	tests.Passed("Should have recorded the parse error of the invalid JSON-LD block")
+
+	expectedMicrodata := []string{"Product", "Review"}
+	if strings.Join(page.Structured.Microdata, ",") != strings.Join(expectedMicrodata, ",") {
+		tests.Info("Expected: %+q", expectedMicrodata)
+		tests.Info("Received: %+q", page.Structured.Microdata)
+		tests.Failed("Should have farmed the microdata item types")
+	}
+	tests.Passed("Should have farmed the microdata item types")
+}
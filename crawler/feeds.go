@@ -0,0 +1,200 @@
+package crawler
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// feedTypes lists the media types feeds are served or linked as, generic xml
+// types only counting for links marked as alternate representations.
+var feedTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+	"application/rdf+xml":  true,
+}
+
+// feedDocument embodies the items of an RSS 2.0, RSS 1.0 or Atom feed.
+type feedDocument struct {
+	Items    []feedItem  `xml:"channel>item"`
+	RDFItems []feedItem  `xml:"item"`
+	Entries  []feedEntry `xml:"entry"`
+}
+
+// feedItem embodies an item of an RSS feed, with it's link or permalink guid.
+type feedItem struct {
+	Links []string `xml:"link"`
+	GUID  struct {
+		Value       string `xml:",chardata"`
+		IsPermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+}
+
+// feedEntry embodies an entry of an Atom feed.
+type feedEntry struct {
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+}
+
+// checkFeeds parses the live RSS and Atom feeds among giving links of a page,
+// returning the reports of the urls of their items within the crawl's scope
+// which the page doesn't link to itself, so they're crawled as links of the
+// page. Each report records the feed listing it.
+func (pc PageCrawler) checkFeeds(ctx context.Context, pool WorkerPool, kids []LinkReport) []LinkReport {
+	linked := map[string]bool{}
+	for _, kid := range kids {
+		linked[kid.Path.String()] = true
+	}
+
+	var found []LinkReport
+	for _, kid := range kids {
+		if !isFeed(kid) {
+			continue
+		}
+
+		feed := kid.Path
+		if kid.RedirectedTo != nil {
+			feed = kid.RedirectedTo
+		}
+
+		refs := pc.feeds.Scan(feed, func() []*url.URL {
+			return pc.fetchFeed(ctx, feed)
+		})
+
+		// Items off the crawl's scope are dropped when checked.
+		links := make(map[*url.URL]linkContext, len(refs))
+		for index, ref := range refs {
+			links[ref] = linkContext{Index: index}
+		}
+
+		checked, _ := pc.checkLinks(ctx, pool, feed, links)
+		for _, ref := range checked {
+			if linked[ref.Path.String()] {
+				continue
+			}
+
+			linked[ref.Path.String()] = true
+			ref.Feed = feed.String()
+			found = append(found, ref)
+		}
+	}
+
+	return found
+}
+
+// isFeed returns true if giving report is of a live feed, served as one or
+// linked as an alternate representation served as xml.
+func isFeed(report LinkReport) bool {
+	if !report.Status.IsLive {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(report.ContentType)
+	if err != nil {
+		return false
+	}
+
+	if feedTypes[mediaType] {
+		return true
+	}
+
+	if mediaType != "application/xml" && mediaType != "text/xml" {
+		return false
+	}
+
+	for _, rel := range report.Rel {
+		if rel == "alternate" {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchFeed retrieves giving feed, returning the urls of it's items resolved
+// against it. Feeds are read up to the crawler's maximum body size.
+func (pc PageCrawler) fetchFeed(ctx context.Context, feed *url.URL) []*url.URL {
+	if err := pc.scheduler.Wait(ctx, feed); err != nil {
+		return nil
+	}
+
+	ctx, cancel := pc.requestContext(ctx)
+	defer cancel()
+
+	req, err := pc.newRequest(ctx, http.MethodGet, feed)
+	if err != nil {
+		return nil
+	}
+
+	res, _, err := followRedirects(pc.fetch, req, pc.allowsRedirect, pc.maxRedirects())
+	if err != nil {
+		return nil
+	}
+
+	defer closeBody(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var refs []*url.URL
+	for _, ref := range parseFeed(io.LimitReader(res.Body, pc.maxBodySize())) {
+		if link, err := parsePath(ref, res.Request.URL); err == nil {
+			refs = append(refs, link)
+		}
+	}
+	return refs
+}
+
+// parseFeed returns the links of the items of giving RSS or Atom feed in order
+// of appearance, being the link of RSS items, else their permalink guid, and
+// the alternate link of Atom entries. Malformed feeds yield no links.
+func parseFeed(content io.Reader) []string {
+	var doc feedDocument
+
+	decoder := xml.NewDecoder(content)
+	decoder.Strict = false
+	if err := decoder.Decode(&doc); err != nil {
+		return nil
+	}
+
+	var refs []string
+	for _, item := range append(doc.Items, doc.RDFItems...) {
+		if link := itemLink(item); link != "" {
+			refs = append(refs, link)
+		}
+	}
+
+	for _, entry := range doc.Entries {
+		for _, link := range entry.Links {
+			if href := strings.TrimSpace(link.Href); href != "" && (link.Rel == "" || link.Rel == "alternate") {
+				refs = append(refs, href)
+				break
+			}
+		}
+	}
+
+	return refs
+}
+
+// itemLink returns the link of giving RSS item, else it's guid if it's a
+// permalink, as guids are permalinks unless marked otherwise.
+func itemLink(item feedItem) string {
+	for _, link := range item.Links {
+		if link = strings.TrimSpace(link); link != "" {
+			return link
+		}
+	}
+
+	if guid := strings.TrimSpace(item.GUID.Value); guid != "" && !strings.EqualFold(item.GUID.IsPermaLink, "false") {
+		if strings.HasPrefix(guid, "http://") || strings.HasPrefix(guid, "https://") {
+			return guid
+		}
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestParseFeed(t *testing.T) {
+	rss := parseFeed(strings.NewReader(`<?xml version="1.0"?>
+		<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+			<channel>
+				<link>https://mombo.com/blog</link>
+				<atom:link href="https://mombo.com/feed.xml" rel="self"/>
+				<item><title>First</title><link> /blog/first </link></item>
+				<item><guid>https://mombo.com/blog/second</guid></item>
+				<item><guid isPermaLink="false">tag:mombo.com,2020:3</guid></item>
+			</channel>
+		</rss>`))
+
+	expected := []string{"/blog/first", "https://mombo.com/blog/second"}
+	if strings.Join(rss, " ") != strings.Join(expected, " ") {
+		tests.Info("Expected: %+q", expected)
+		tests.Info("Received: %+q", rss)
+		tests.Failed("Should have parsed item links of rss feed")
+	}
+	tests.Passed("Should have parsed item links of rss feed")
+
+	atom := parseFeed(strings.NewReader(`<?xml version="1.0" encoding="utf-8"?>
+		<feed xmlns="http://www.w3.org/2005/Atom">
+			<link href="https://mombo.com/" rel="alternate"/>
+			<entry>
+				<link href="https://mombo.com/blog/first/comments" rel="replies"/>
+				<link href="https://mombo.com/blog/first"/>
+			</entry>
+			<entry><link rel="alternate" href="/blog/second"/></entry>
+		</feed>`))
+
+	expected = []string{"https://mombo.com/blog/first", "/blog/second"}
+	if strings.Join(atom, " ") != strings.Join(expected, " ") {
+		tests.Info("Expected: %+q", expected)
+		tests.Info("Received: %+q", atom)
+		tests.Failed("Should have parsed entry links of atom feed")
+	}
+	tests.Passed("Should have parsed entry links of atom feed")
+
+	if links := parseFeed(strings.NewReader(`<html><body>not a feed`)); len(links) != 0 {
+		tests.Info("Received: %+q", links)
+		tests.Failed("Should have parsed no links of malformed feed")
+	}
+	tests.Passed("Should have parsed no links of malformed feed")
+}
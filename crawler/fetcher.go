@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// fetchRequestKey is the context key of the request the crawler sends for a
+// fetched url.
+type fetchRequestKey struct{}
+
+// Fetcher defines the contract for retrieving the urls of a crawl, returning
+// the response of a single request without following it's redirects, which
+// the crawler follows itself so every hop is recorded. Fetchers answering
+// from a cache, a headless browser or recorded fixtures can be injected into
+// a PageCrawler in place of it's http.Client. The method and headers the
+// crawler sends for a url, such as it's User-Agent, cookies and conditional
+// headers, are given by FetchRequest.
+type Fetcher interface {
+	Fetch(ctx context.Context, target *url.URL) (*http.Response, error)
+}
+
+// FetcherFunc implements a Fetcher through a function, such as one answering
+// requests from recorded fixtures.
+type FetcherFunc func(ctx context.Context, target *url.URL) (*http.Response, error)
+
+// Fetch implements the Fetcher interface.
+func (f FetcherFunc) Fetch(ctx context.Context, target *url.URL) (*http.Response, error) {
+	return f(ctx, target)
+}
+
+// FetchRequest returns the request the crawler sends for giving target within
+// the context a Fetcher is given, with it's method and headers. A GET without
+// headers is returned for contexts not given by the crawler.
+func FetchRequest(ctx context.Context, target *url.URL) (*http.Request, error) {
+	template, ok := ctx.Value(fetchRequestKey{}).(*http.Request)
+	if !ok {
+		return http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	}
+
+	req := template.Clone(ctx)
+	req.URL = target
+	req.Host = ""
+	return req, nil
+}
+
+// fetch sends giving request through provided fetcher, setting the request of
+// the response if the fetcher left it unset.
+func fetch(fetcher Fetcher, req *http.Request) (*http.Response, error) {
+	res, err := fetcher.Fetch(context.WithValue(req.Context(), fetchRequestKey{}, req), req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Request == nil {
+		res.Request = req
+	}
+	return res, nil
+}
+
+// clientFetcher implements a Fetcher sending requests through an http.Client
+// which never follows redirects.
+type clientFetcher struct {
+	client http.Client
+}
+
+// NewClientFetcher returns a Fetcher sending requests through giving client,
+// without following redirects. A nil client defaults to http.DefaultClient.
+func NewClientFetcher(client *http.Client) Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	fetcher := &clientFetcher{client: *client}
+	fetcher.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return fetcher
+}
+
+// Fetch implements the Fetcher interface, sending the request the crawler
+// gave for target.
+func (c *clientFetcher) Fetch(ctx context.Context, target *url.URL) (*http.Response, error) {
+	req, err := FetchRequest(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
+// newFetcher returns the Fetcher requests of the crawl are sent through, being
+// the crawler's Fetcher when set, else a Fetcher of giving client. It's built
+// once when a crawl or check starts and shared by all requests it sends.
+func (pc PageCrawler) newFetcher(client *http.Client) Fetcher {
+	if pc.Fetcher != nil {
+		return pc.Fetcher
+	}
+	return NewClientFetcher(client)
+}
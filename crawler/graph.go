@@ -0,0 +1,278 @@
+package crawler
+
+import (
+	"sort"
+	"sync"
+)
+
+// GraphNode embodies a url of a crawl's link graph. Crawled marks urls whose
+// page was crawled, as opposed to urls only linked to, such as external links
+// or pages beyond the crawl's depth.
+type GraphNode struct {
+	URL     string `json:"url"`
+	Status  Status `json:"status"`
+	Crawled bool   `json:"crawled"`
+}
+
+// GraphEdge embodies a link between two urls of a crawl's link graph. Redirect
+// marks edges from a linked url to the url it redirects to. AnchorText holds
+// the text of the anchor linking from the page.
+type GraphEdge struct {
+	From       string       `json:"from"`
+	To         string       `json:"to"`
+	Position   LinkPosition `json:"position,omitempty"`
+	AnchorText string       `json:"anchor_text,omitempty"`
+	Redirect   bool         `json:"redirect,omitempty"`
+}
+
+// Graph implements a concurrent-safe link graph of the urls of a crawl and the
+// links between them, built while the crawl runs, so applications embedding
+// the crawler can run their own analyses once Run completes without parsing
+// it's outputs. Urls are keyed as linked, redirected links being connected to
+// the url they redirect to.
+type Graph struct {
+	ml    sync.RWMutex
+	seed  string
+	nodes map[string]*GraphNode
+	out   map[string][]GraphEdge
+	in    map[string][]GraphEdge
+}
+
+// NewGraph returns a new instance of a Graph.
+func NewGraph() *Graph {
+	return &Graph{
+		nodes: map[string]*GraphNode{},
+		out:   map[string][]GraphEdge{},
+		in:    map[string][]GraphEdge{},
+	}
+}
+
+// Record adds the page of giving report with it's outgoing links into the
+// graph, marking it the seed of the crawl if seed is true. Crawls restored
+// from their stored reports can be rebuilt into a graph through it.
+func (g *Graph) Record(report LinkReport, seed bool) {
+	if g == nil || report.Path == nil {
+		return
+	}
+
+	g.ml.Lock()
+	defer g.ml.Unlock()
+
+	from := report.Path.String()
+	if seed {
+		g.seed = from
+	}
+
+	node := g.node(from)
+	node.Status = report.Status
+	node.Crawled = true
+
+	for _, kid := range report.PointsTo {
+		to := kid.Path.String()
+		if _, ok := g.nodes[to]; !ok {
+			g.node(to).Status = kid.Status
+		}
+		g.link(GraphEdge{From: from, To: to, Position: kid.Position, AnchorText: kid.AnchorText})
+
+		if kid.RedirectedTo != nil {
+			redirected := kid.RedirectedTo.String()
+			g.node(redirected)
+			g.link(GraphEdge{From: to, To: redirected, Redirect: true})
+		}
+	}
+}
+
+// node returns the node of giving url, adding it if missing. It must be called
+// with the lock held.
+func (g *Graph) node(link string) *GraphNode {
+	node, ok := g.nodes[link]
+	if !ok {
+		node = &GraphNode{URL: link}
+		g.nodes[link] = node
+	}
+	return node
+}
+
+// link adds giving edge unless it's urls are already linked. It must be called
+// with the lock held.
+func (g *Graph) link(edge GraphEdge) {
+	for _, existing := range g.out[edge.From] {
+		if existing.To == edge.To {
+			return
+		}
+	}
+
+	g.out[edge.From] = append(g.out[edge.From], edge)
+	g.in[edge.To] = append(g.in[edge.To], edge)
+}
+
+// Seed returns the url the crawl started from, the final url if the target
+// redirected.
+func (g *Graph) Seed() string {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+	return g.seed
+}
+
+// Node returns the node of giving url, if it's within the graph.
+func (g *Graph) Node(link string) (GraphNode, bool) {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+
+	node, ok := g.nodes[link]
+	if !ok {
+		return GraphNode{}, false
+	}
+	return *node, true
+}
+
+// Nodes returns all nodes of the graph ordered by url.
+func (g *Graph) Nodes() []GraphNode {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+
+	nodes := make([]GraphNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, *node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].URL < nodes[j].URL
+	})
+	return nodes
+}
+
+// Edges returns all edges of the graph ordered by their urls.
+func (g *Graph) Edges() []GraphEdge {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+
+	var edges []GraphEdge
+	for _, out := range g.out {
+		edges = append(edges, out...)
+	}
+
+	sortEdges(edges)
+	return edges
+}
+
+// Outlinks returns the edges of links from giving url, ordered by the url
+// linked to.
+func (g *Graph) Outlinks(link string) []GraphEdge {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+
+	edges := append([]GraphEdge(nil), g.out[link]...)
+	sortEdges(edges)
+	return edges
+}
+
+// Inlinks returns the edges of links to giving url, ordered by the url
+// linking to it.
+func (g *Graph) Inlinks(link string) []GraphEdge {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+
+	edges := append([]GraphEdge(nil), g.in[link]...)
+	sortEdges(edges)
+	return edges
+}
+
+// Walk visits all urls reachable from the seed breadth first with their depth,
+// the seed being at depth 0, till fn returns false.
+func (g *Graph) Walk(fn func(node GraphNode, depth int) bool) {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+
+	g.walkWith(nil, func(link string, depth int) bool {
+		return fn(*g.nodes[link], depth)
+	})
+}
+
+// Depth returns the fewest links followed from the seed to reach giving url,
+// else -1 if it's not reachable.
+func (g *Graph) Depth(link string) int {
+	path := g.ShortestPath(link)
+	return len(path) - 1
+}
+
+// ShortestPath returns the urls of the shortest path of links from the seed to
+// giving url, starting with the seed. It returns nil if the url is not
+// reachable from the seed.
+func (g *Graph) ShortestPath(link string) []string {
+	g.ml.RLock()
+	defer g.ml.RUnlock()
+
+	if _, ok := g.nodes[link]; !ok {
+		return nil
+	}
+
+	previous := map[string]string{}
+	found := false
+
+	g.walkWith(func(edge GraphEdge) {
+		previous[edge.To] = edge.From
+	}, func(current string, _ int) bool {
+		found = current == link
+		return !found
+	})
+
+	if !found {
+		return nil
+	}
+
+	path := []string{link}
+	for current := link; current != g.seed; {
+		current = previous[current]
+		path = append(path, current)
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// walkWith visits all urls reachable from the seed breadth first, calling
+// follow with every edge leading to a url visited for the first time. It must
+// be called with the lock held.
+func (g *Graph) walkWith(follow func(GraphEdge), visit func(link string, depth int) bool) {
+	if _, ok := g.nodes[g.seed]; !ok {
+		return
+	}
+
+	depths := map[string]int{g.seed: 0}
+	queue := []string{g.seed}
+
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if !visit(current, depths[current]) {
+			return
+		}
+
+		for _, edge := range g.out[current] {
+			if _, ok := depths[edge.To]; ok {
+				continue
+			}
+
+			depths[edge.To] = depths[current] + 1
+			queue = append(queue, edge.To)
+
+			if follow != nil {
+				follow(edge)
+			}
+		}
+	}
+}
+
+// sortEdges orders giving edges by their urls.
+func sortEdges(edges []GraphEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
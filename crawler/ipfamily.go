@@ -0,0 +1,86 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+)
+
+// errors ...
+var (
+	ErrInvalidIPFamily = errors.New("invalid ip family, expected v4, v6 or auto")
+)
+
+// IPFamily defines the ip address family used to connect to hosts.
+type IPFamily string
+
+// ip families ...
+const (
+	IPFamilyAuto IPFamily = "auto"
+	IPFamilyV4   IPFamily = "v4"
+	IPFamilyV6   IPFamily = "v6"
+)
+
+// ParseIPFamily parses giving ip family, an empty family defaults to
+// IPFamilyAuto. The ip versions 4 and 6 are accepted for v4 and v6.
+func ParseIPFamily(family string) (IPFamily, error) {
+	switch IPFamily(family) {
+	case "":
+		return IPFamilyAuto, nil
+	case "4":
+		return IPFamilyV4, nil
+	case "6":
+		return IPFamilyV6, nil
+	case IPFamilyAuto, IPFamilyV4, IPFamilyV6:
+		return IPFamily(family), nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrInvalidIPFamily, family)
+}
+
+// Network returns the network dialed for the family.
+func (f IPFamily) Network() string {
+	switch f {
+	case IPFamilyV4:
+		return "tcp4"
+	case IPFamilyV6:
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// allows returns true if giving ip address is of the family.
+func (f IPFamily) allows(ip net.IP) bool {
+	switch f {
+	case IPFamilyV4:
+		return ip.To4() != nil
+	case IPFamilyV6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// withRemoteTrace returns giving request traced to record the ip address and
+// family of the connection it's sent over into report. Requests following
+// redirects record the connection of the last hop.
+func withRemoteTrace(req *http.Request, report *LinkReport) *http.Request {
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			report.RemoteIP, report.IPFamily = remoteAddress(info.Conn.RemoteAddr())
+		},
+	}))
+}
+
+// remoteAddress returns the ip and family of giving remote address.
+func remoteAddress(addr net.Addr) (string, IPFamily) {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || tcp.IP == nil {
+		return "", ""
+	}
+
+	if tcp.IP.To4() != nil {
+		return tcp.IP.String(), IPFamilyV4
+	}
+	return tcp.IP.String(), IPFamilyV6
+}
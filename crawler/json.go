@@ -0,0 +1,178 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxJSONURLLength sets the longest string of a json document taken as a url.
+const maxJSONURLLength = 2048
+
+// checkJSON fetches the live json documents among giving links of a page, such
+// as data routes of single page apps, along with the json documents they
+// reference, returning the reports of the url-shaped strings they hold within
+// the crawl's scope which the page doesn't link to itself, so they're crawled
+// as links of the page. Each report records the json document referencing it.
+func (pc PageCrawler) checkJSON(ctx context.Context, pool WorkerPool, kids []LinkReport) []LinkReport {
+	linked := map[string]bool{}
+	for _, kid := range kids {
+		linked[kid.Path.String()] = true
+	}
+
+	var found []LinkReport
+	for queue := append([]LinkReport(nil), kids...); len(queue) != 0; queue = queue[1:] {
+		kid := queue[0]
+		if !isJSON(kid) {
+			continue
+		}
+
+		doc := kid.Path
+		if kid.RedirectedTo != nil {
+			doc = kid.RedirectedTo
+		}
+
+		// Documents off the crawl's scope are not scanned, as their urls are
+		// of another site.
+		if !pc.inScope(pc.Target, doc) {
+			continue
+		}
+
+		refs := pc.jsonDocs.Scan(doc, func() []*url.URL {
+			return pc.fetchJSON(ctx, doc)
+		})
+
+		links := make(map[*url.URL]linkContext, len(refs))
+		for index, ref := range refs {
+			links[ref] = linkContext{Index: index, Position: kid.Position}
+		}
+
+		checked, _ := pc.checkLinks(ctx, pool, doc, links)
+		for _, ref := range checked {
+			if linked[ref.Path.String()] {
+				continue
+			}
+
+			linked[ref.Path.String()] = true
+			ref.JSON = doc.String()
+			found = append(found, ref)
+			queue = append(queue, ref)
+		}
+	}
+
+	return found
+}
+
+// mergeLinks returns the links of giving sets in one set, links of later sets
+// taking precedence and ordered after those of earlier sets.
+func mergeLinks(sets ...map[*url.URL]linkContext) map[*url.URL]linkContext {
+	var total int
+	for _, set := range sets {
+		total += len(set)
+	}
+
+	links := make(map[*url.URL]linkContext, total)
+	for _, set := range sets {
+		for _, link := range orderedLinks(set) {
+			addLink(links, link, set[link])
+		}
+	}
+	return links
+}
+
+// isJSON returns true if giving report is of a live json document, served as
+// application/json or a +json media type.
+func isJSON(report LinkReport) bool {
+	if !report.Status.IsLive {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(report.ContentType)
+	return err == nil && isJSONType(mediaType)
+}
+
+// isJSONType returns true if giving media type is of json.
+func isJSONType(mediaType string) bool {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// fetchJSON retrieves giving json document, returning the url-shaped strings
+// it holds resolved against it. Documents are read up to the crawler's maximum
+// body size.
+func (pc PageCrawler) fetchJSON(ctx context.Context, doc *url.URL) []*url.URL {
+	if err := pc.scheduler.Wait(ctx, doc); err != nil {
+		return nil
+	}
+
+	ctx, cancel := pc.requestContext(ctx)
+	defer cancel()
+
+	req, err := pc.newRequest(ctx, http.MethodGet, doc)
+	if err != nil {
+		return nil
+	}
+
+	res, _, err := followRedirects(pc.fetch, req, pc.allowsRedirect, pc.maxRedirects())
+	if err != nil {
+		return nil
+	}
+
+	defer closeBody(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var refs []*url.URL
+	for _, ref := range scanJSON(io.LimitReader(res.Body, pc.maxBodySize())) {
+		if link, err := parsePath(ref, res.Request.URL); err == nil {
+			refs = append(refs, link)
+		}
+	}
+	return refs
+}
+
+// scanJSON returns the url-shaped strings of giving json document in order of
+// appearance, keys included as route manifests key pages by their path. A
+// malformed or truncated document yields the strings read before the fault.
+func scanJSON(content io.Reader) []string {
+	var refs []string
+
+	decoder := json.NewDecoder(content)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return refs
+		}
+
+		if value, ok := token.(string); ok && isURLShaped(value) {
+			refs = append(refs, value)
+		}
+	}
+}
+
+// isURLShaped returns true if giving json string looks like a url, being an
+// absolute http(s) url, a protocol-relative url or a root-relative path,
+// without spaces. Route patterns such as /blog/[slug] or /api/* are skipped.
+func isURLShaped(value string) bool {
+	if len(value) < 2 || len(value) > maxJSONURLLength || strings.ContainsAny(value, " \t\r\n<>\"{}|\\^`[]*") {
+		return false
+	}
+
+	lower := strings.ToLower(value)
+	switch {
+	case strings.HasPrefix(lower, "http://"), strings.HasPrefix(lower, "https://"):
+		link, err := url.Parse(value)
+		return err == nil && link.Host != ""
+	case strings.HasPrefix(value, "//"):
+		return len(value) > 2 && value[2] != '/'
+	case value[0] == '/':
+		return true
+	}
+	return false
+}
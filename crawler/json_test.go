@@ -0,0 +1,76 @@
+package crawler
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestScanJSON(t *testing.T) {
+	refs := scanJSON(strings.NewReader(`{
+		"pageProps": {
+			"posts": [{"slug": "first", "href": "/blog/first"}, {"href": "https://mombo.com/blog/second"}],
+			"cdn": "//cdn.mombo.com/app.js",
+			"title": "Not / a url",
+			"html": "<a href=\"/markup\"></a>",
+			"root": "/",
+			"comment": "///"
+		},
+		"/about": {"page": "/about"},
+		"buildId": "abc123"
+	`))
+
+	expected := []string{"/blog/first", "https://mombo.com/blog/second", "//cdn.mombo.com/app.js", "/about", "/about"}
+	if strings.Join(refs, " ") != strings.Join(expected, " ") {
+		tests.Info("Expected: %+q", expected)
+		tests.Info("Received: %+q", refs)
+		tests.Failed("Should have scanned url-shaped strings of truncated json")
+	}
+	tests.Passed("Should have scanned url-shaped strings of truncated json")
+}
+
+func TestFarmInlineJSON(t *testing.T) {
+	target, err := url.Parse("http://mombo.com/blog/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<body>
+			<a href="/linked"></a>
+			<script id="__NEXT_DATA__" type="application/json">{"page": "/blog/[slug]", "props": {"next": "/blog/second"}}</script>
+			<script type="application/ld+json">{"@type": "BlogPosting", "url": "http://mombo.com/blog/first"}</script>
+			<script>var route = "/not-json";</script>
+		</body>
+		</html>
+	`)), target)
+
+	if len(page.Links) != 1 {
+		tests.Info("Received Links: %+v", page.Links)
+		tests.Failed("Should have kept links of inline json apart from page links")
+	}
+	tests.Passed("Should have kept links of inline json apart from page links")
+
+	expected := map[string]bool{
+		"/blog/second": true,
+		"/blog/first":  true,
+	}
+
+	if len(page.JSONLinks) != len(expected) {
+		tests.Info("Received Links: %+v", page.JSONLinks)
+		tests.Failed("Should have farmed url-shaped strings of inline json")
+	}
+
+	for link := range page.JSONLinks {
+		if !expected[link.Path] {
+			tests.Info("Received Link: %q", link.Path)
+			tests.Failed("Should have farmed url-shaped strings of inline json")
+		}
+	}
+	tests.Passed("Should have farmed url-shaped strings of inline json")
+}
@@ -0,0 +1,41 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+)
+
+// metadataKey is the context key metadata of a crawled url is stored under.
+type metadataKey struct{}
+
+// Metadata embodies the key-value pairs attached to a url when it's enqueued,
+// such as an experiment tag or owning team, emitted on the url's report. It
+// must be treated as read-only, as it's shared by contexts derived from the
+// one it's attached to.
+type Metadata map[string]string
+
+// EnqueueHook defines a function called when a url is enqueued for crawling,
+// returning the context it's crawled with. Metadata attached to the returned
+// context with WithMetadata is carried on requests sent while crawling the url
+// and emitted on it's report. The parent report is nil for the target.
+type EnqueueHook func(ctx context.Context, target *url.URL, parent *LinkReport) context.Context
+
+// WithMetadata returns a copy of giving context with the key-value pair added
+// to the metadata it carries, replacing any existing value of key.
+func WithMetadata(ctx context.Context, key string, value string) context.Context {
+	existing := MetadataFrom(ctx)
+
+	metadata := make(Metadata, len(existing)+1)
+	for k, v := range existing {
+		metadata[k] = v
+	}
+	metadata[key] = value
+
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// MetadataFrom returns the metadata carried by giving context, else nil.
+func MetadataFrom(ctx context.Context) Metadata {
+	metadata, _ := ctx.Value(metadataKey{}).(Metadata)
+	return metadata
+}
@@ -0,0 +1,86 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// robotsDirectiveNames lists the directives of robots meta tags and the
+// X-Robots-Tag header, used to tell directives apart from the user agents
+// X-Robots-Tag values can be scoped to.
+var robotsDirectiveNames = map[string]bool{
+	"all": true, "noindex": true, "nofollow": true, "none": true, "noarchive": true,
+	"nosnippet": true, "notranslate": true, "noimageindex": true, "indexifembedded": true,
+	"unavailable_after": true, "max-snippet": true, "max-image-preview": true, "max-video-preview": true,
+}
+
+// RobotsDirectives embodies the indexing directives of a page, given by it's
+// robots meta tags and X-Robots-Tag headers. NoFollow pages do not have their
+// links crawled.
+type RobotsDirectives struct {
+	NoIndex    bool     `json:"noindex,omitempty"`
+	NoFollow   bool     `json:"nofollow,omitempty"`
+	Directives []string `json:"directives"`
+}
+
+// newRobotsDirectives returns the RobotsDirectives of giving directives, else
+// nil if there are none.
+func newRobotsDirectives(directives []string) *RobotsDirectives {
+	if len(directives) == 0 {
+		return nil
+	}
+
+	robots := &RobotsDirectives{Directives: directives}
+	for _, directive := range directives {
+		switch directive {
+		case "noindex":
+			robots.NoIndex = true
+		case "nofollow":
+			robots.NoFollow = true
+		case "none":
+			robots.NoIndex = true
+			robots.NoFollow = true
+		}
+	}
+	return robots
+}
+
+// parseRobotsContent appends the directives of giving robots meta tag content
+// to directives, skipping those already listed.
+func parseRobotsContent(directives []string, content string) []string {
+	for _, directive := range strings.Split(content, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "" {
+			continue
+		}
+
+		var listed bool
+		for _, existing := range directives {
+			if existing == directive {
+				listed = true
+				break
+			}
+		}
+
+		if !listed {
+			directives = append(directives, directive)
+		}
+	}
+	return directives
+}
+
+// headerRobots appends the directives of the X-Robots-Tag headers of giving
+// response to directives. Values scoped to a user agent, such as
+// "googlebot: noindex", are skipped as they don't apply to all crawlers.
+func headerRobots(directives []string, header http.Header) []string {
+	for _, value := range header.Values("X-Robots-Tag") {
+		if index := strings.IndexByte(value, ':'); index >= 0 {
+			name := strings.ToLower(strings.TrimSpace(value[:index]))
+			if !robotsDirectiveNames[name] && !strings.Contains(name, ",") {
+				continue
+			}
+		}
+		directives = parseRobotsContent(directives, value)
+	}
+	return directives
+}
@@ -0,0 +1,190 @@
+package crawler
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultPorts maps schemes to the port they imply, which are dropped from
+// normalized urls.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns a copy of giving url normalized per RFC 3986, so urls
+// differing only in their spelling are crawled and reported once. Scheme and
+// host are lowercased, default ports dropped, dot-segments removed, percent
+// encodings of unreserved characters decoded and others uppercased, query
+// parameters sorted by name and fragments stripped. An empty path of a url
+// with a host becomes "/".
+func Normalize(link *url.URL) *url.URL {
+	if link == nil {
+		return nil
+	}
+
+	normalized := *link
+	normalized.Scheme = strings.ToLower(link.Scheme)
+	normalized.Fragment = ""
+	normalized.RawFragment = ""
+
+	if link.Opaque != "" {
+		return &normalized
+	}
+
+	normalized.Host = normalizeHost(normalized.Scheme, link.Host)
+
+	normalized.Path = removeDotSegments(link.Path)
+	if normalized.Path == "" && normalized.Host != "" {
+		normalized.Path = "/"
+	}
+
+	normalized.RawPath = ""
+	if link.RawPath != "" {
+		escaped := normalizeEscapes(removeDotSegments(link.RawPath))
+		if unescaped, err := url.PathUnescape(escaped); err == nil && unescaped == normalized.Path {
+			normalized.RawPath = escaped
+		}
+	}
+
+	normalized.RawQuery = normalizeQuery(link.RawQuery)
+	normalized.ForceQuery = false
+	return &normalized
+}
+
+// normalizeHost returns giving host lowercased, without the default port of
+// giving scheme.
+func normalizeHost(scheme string, host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ":")
+
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil || port != defaultPorts[scheme] {
+		return host
+	}
+
+	if strings.Contains(hostname, ":") {
+		return "[" + hostname + "]"
+	}
+	return hostname
+}
+
+// normalizeQuery returns giving raw query with it's escapes normalized and
+// parameters sorted by name, keeping the order of values of the same name.
+// Empty parameters are dropped.
+func normalizeQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+
+	var params []string
+	for _, param := range strings.Split(query, "&") {
+		if param != "" {
+			params = append(params, normalizeEscapes(param))
+		}
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		return queryName(params[i]) < queryName(params[j])
+	})
+	return strings.Join(params, "&")
+}
+
+// queryName returns the name of giving query parameter.
+func queryName(param string) string {
+	if index := strings.IndexByte(param, '='); index != -1 {
+		return param[:index]
+	}
+	return param
+}
+
+// removeDotSegments removes the "." and ".." segments of giving path, per
+// RFC 3986 section 5.2.4, keeping the trailing slash they imply.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	last := len(segments) - 1
+
+	kept := make([]string, 0, len(segments))
+	for index, segment := range segments {
+		switch segment {
+		case ".":
+		case "..":
+			if len(kept) > 1 || (len(kept) == 1 && kept[0] != "") {
+				kept = kept[:len(kept)-1]
+			}
+		default:
+			kept = append(kept, segment)
+			continue
+		}
+
+		if index == last {
+			kept = append(kept, "")
+		}
+	}
+
+	cleaned := strings.Join(kept, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(cleaned, "/") {
+		cleaned = "/" + cleaned
+	}
+	return cleaned
+}
+
+// normalizeEscapes decodes the percent encodings of unreserved characters of
+// giving text and uppercases the hex digits of all others.
+func normalizeEscapes(text string) string {
+	if !strings.Contains(text, "%") {
+		return text
+	}
+
+	var normalized strings.Builder
+	normalized.Grow(len(text))
+
+	for index := 0; index < len(text); index++ {
+		if text[index] != '%' || index+2 >= len(text) || !isHex(text[index+1]) || !isHex(text[index+2]) {
+			normalized.WriteByte(text[index])
+			continue
+		}
+
+		decoded := unhex(text[index+1])<<4 | unhex(text[index+2])
+		if isUnreserved(decoded) {
+			normalized.WriteByte(decoded)
+		} else {
+			normalized.WriteByte('%')
+			normalized.WriteString(strings.ToUpper(text[index+1 : index+3]))
+		}
+		index += 2
+	}
+
+	return normalized.String()
+}
+
+// isUnreserved returns true if giving byte is an unreserved character per
+// RFC 3986, which never needs percent encoding.
+func isUnreserved(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isHex returns true if giving byte is a hex digit.
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// unhex returns the value of giving hex digit.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		link       string
+		normalized string
+	}{
+		{"HTTP://Site.com:80/a/../b?x=1", "http://site.com/b?x=1"},
+		{"https://site.com:443", "https://site.com/"},
+		{"https://site.com:8443/", "https://site.com:8443/"},
+		{"http://[::1]:80/", "http://[::1]/"},
+		{"http://site.com/a/./b/../c/", "http://site.com/a/c/"},
+		{"http://site.com/a/b/..", "http://site.com/a/"},
+		{"http://site.com/../a", "http://site.com/a"},
+		{"http://site.com/page?b=2&a=1&b=1", "http://site.com/page?a=1&b=2&b=1"},
+		{"http://site.com/page?", "http://site.com/page"},
+		{"http://site.com/page?&a=1&", "http://site.com/page?a=1"},
+		{"http://site.com/page#section", "http://site.com/page"},
+		{"http://site.com/%7euser/%2fpath?q=%3a%7E", "http://site.com/~user/%2Fpath?q=%3A~"},
+		{"mailto:Team@Monzo.com#top", "mailto:Team@Monzo.com"},
+	}
+
+	for _, c := range cases {
+		link, err := url.Parse(c.link)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", c.link)
+		}
+
+		if normalized := Normalize(link).String(); normalized != c.normalized {
+			tests.Info("Received URL: %q", normalized)
+			tests.Failed("Should have normalized %q to %q", c.link, c.normalized)
+		}
+		tests.Passed("Should have normalized %q to %q", c.link, c.normalized)
+	}
+}
+
+func TestSeenKey(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+		return link
+	}
+
+	if seenKey(parse("HTTP://Site.com:80/a/../b/")) != seenKey(parse("https://site.com/b")) {
+		tests.Failed("Should have keyed differently spelled urls the same")
+	}
+	tests.Passed("Should have keyed differently spelled urls the same")
+
+	if seenKey(parse("http://site.com/page?b=2&a=1")) != seenKey(parse("http://site.com/page?a=1&b=2#top")) {
+		tests.Failed("Should have keyed urls differing in query order the same")
+	}
+	tests.Passed("Should have keyed urls differing in query order the same")
+
+	if seenKey(parse("http://site.com/page?a=1")) == seenKey(parse("http://site.com/page?b=2")) {
+		tests.Failed("Should have keyed urls of different queries apart")
+	}
+	tests.Passed("Should have keyed urls of different queries apart")
+}
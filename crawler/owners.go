@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// OwnerRule defines the team owning all paths lying under a giving prefix.
+type OwnerRule struct {
+	Prefix string
+	Team   string
+}
+
+// Owners implements a CODEOWNERS-style mapping of path prefixes to owning teams,
+// where the last matching rule wins.
+type Owners struct {
+	rules []OwnerRule
+}
+
+// NewOwners returns a new instance of Owners for giving rules.
+func NewOwners(rules ...OwnerRule) *Owners {
+	return &Owners{rules: rules}
+}
+
+// ParseOwners parses giving CODEOWNERS-style content, where each line holds
+// a path prefix followed by the owning team e.g `/blog @content-team`. A `*`
+// prefix matches all paths.
+func ParseOwners(content io.Reader) (*Owners, error) {
+	var owners Owners
+
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		owners.rules = append(owners.rules, OwnerRule{
+			Prefix: fields[0],
+			Team:   strings.TrimPrefix(fields[1], "@"),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &owners, nil
+}
+
+// Owner returns the team owning giving path, else an empty string if no
+// rule matches.
+func (o *Owners) Owner(path string) string {
+	if o == nil {
+		return ""
+	}
+
+	for index := len(o.rules) - 1; index >= 0; index-- {
+		rule := o.rules[index]
+		if rule.Prefix == "*" || strings.HasPrefix(path, rule.Prefix) {
+			return rule.Team
+		}
+	}
+
+	return ""
+}
+
+// Annotate sets the owner of giving report and all it's outgoing links.
+func (o *Owners) Annotate(report *LinkReport) {
+	report.Owner = o.Owner(report.Path.Path)
+	for index := range report.PointsTo {
+		report.PointsTo[index].Owner = o.Owner(report.PointsTo[index].Path.Path)
+	}
+}
@@ -0,0 +1,37 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestOwners(t *testing.T) {
+	owners, err := ParseOwners(strings.NewReader(`
+# default owners
+*            @web-platform
+/blog        @content-team
+/blog/legal  @legal
+`))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed owners")
+	}
+	tests.Passed("Should have successfully parsed owners")
+
+	expected := map[string]string{
+		"/":                 "web-platform",
+		"/blog/hello":       "content-team",
+		"/blog/legal/terms": "legal",
+	}
+
+	for path, team := range expected {
+		if owner := owners.Owner(path); owner != team {
+			tests.Info("Path: %q", path)
+			tests.Info("Expected Owner: %q", team)
+			tests.Info("Received Owner: %q", owner)
+			tests.Failed("Should have matched owner of path")
+		}
+	}
+	tests.Passed("Should have matched owners of all paths")
+}
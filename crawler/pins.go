@@ -0,0 +1,62 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrInvalidPin is returned for address pins not of the form host:ip.
+var ErrInvalidPin = errors.New("invalid address pin, expected host:ip")
+
+// PinnedResolver implements a HostResolver which resolves pinned hosts to fixed
+// addresses, like curl's --resolve, so a site can be crawled against a specific
+// origin server without editing /etc/hosts. Hosts not pinned are resolved with
+// the underline resolver.
+type PinnedResolver struct {
+	resolver HostResolver
+	pins     map[string][]string
+}
+
+// NewPinnedResolver returns a new instance of a PinnedResolver resolving hosts
+// not pinned with giving resolver, defaulting to the system resolver.
+func NewPinnedResolver(resolver HostResolver) *PinnedResolver {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return &PinnedResolver{
+		resolver: resolver,
+		pins:     map[string][]string{},
+	}
+}
+
+// Pin parses giving host:ip pin, resolving the host to the ip address. Pinning
+// a host multiple times resolves it to all addresses in order. Ipv6 addresses
+// may be enclosed in brackets. Hosts must be pinned before the resolver is used.
+func (p *PinnedResolver) Pin(pin string) error {
+	parts := strings.SplitN(strings.TrimSpace(pin), ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("%+s: %+q", ErrInvalidPin, pin)
+	}
+
+	ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(parts[1], "["), "]"))
+	if ip == nil {
+		return fmt.Errorf("%+s: %+q", ErrInvalidPin, pin)
+	}
+
+	host := strings.ToLower(parts[0])
+	p.pins[host] = append(p.pins[host], ip.String())
+	return nil
+}
+
+// LookupHost returns the pinned addresses of giving host, else those resolved
+// by the underline resolver.
+func (p *PinnedResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := p.pins[strings.ToLower(host)]; ok {
+		return append([]string(nil), addrs...), nil
+	}
+	return p.resolver.LookupHost(ctx, host)
+}
@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestPinnedResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Host", r.Host)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed server url")
+	}
+
+	resolver := NewPinnedResolver(staticResolver{"other.test": {"10.0.0.1"}})
+	for _, pin := range []string{"Mombo.test:127.0.0.1", "mombo.test:[::1]"} {
+		if err := resolver.Pin(pin); err != nil {
+			tests.FailedWithError(err, "Should have successfully pinned host")
+		}
+	}
+	tests.Passed("Should have successfully pinned host")
+
+	for _, invalid := range []string{"mombo.test", ":127.0.0.1", "mombo.test:mombo.com", "mombo.test:443:"} {
+		if err := resolver.Pin(invalid); err == nil {
+			tests.Info("Pin: %q", invalid)
+			tests.Failed("Should have failed to parse invalid pin")
+		}
+	}
+	tests.Passed("Should have failed to parse invalid pin")
+
+	addrs, err := resolver.LookupHost(context.Background(), "mombo.test")
+	if err != nil || len(addrs) != 2 || addrs[0] != "127.0.0.1" || addrs[1] != "::1" {
+		tests.Info("Received Addresses: %+q", addrs)
+		tests.Failed("Should have resolved host to pinned addresses in order")
+	}
+	tests.Passed("Should have resolved host to pinned addresses in order")
+
+	addrs, err = resolver.LookupHost(context.Background(), "other.test")
+	if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+		tests.Info("Received Addresses: %+q", addrs)
+		tests.Failed("Should have resolved host not pinned with underline resolver")
+	}
+	tests.Passed("Should have resolved host not pinned with underline resolver")
+
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: NewTransport(TransportOptions{IPFamily: IPFamilyV4, Resolver: resolver}),
+	}
+
+	res, err := client.Get("http://mombo.test:" + serverURL.Port() + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have dialed pinned address of host")
+	}
+	res.Body.Close()
+
+	if res.Header.Get("X-Host") != "mombo.test:"+serverURL.Port() {
+		tests.Info("Received Host: %q", res.Header.Get("X-Host"))
+		tests.Failed("Should have kept host of url on requests to pinned address")
+	}
+	tests.Passed("Should have dialed pinned address of host keeping it's host")
+}
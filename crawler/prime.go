@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// CacheStatus defines the status a CDN or origin cache reports for a response.
+type CacheStatus string
+
+// cache statuses ...
+const (
+	CacheHit         CacheStatus = "hit"
+	CacheMiss        CacheStatus = "miss"
+	CacheStale       CacheStatus = "stale"
+	CacheExpired     CacheStatus = "expired"
+	CacheRevalidated CacheStatus = "revalidated"
+	CacheBypass      CacheStatus = "bypass"
+)
+
+// cacheHeaders lists the headers caches report their status in, in order of
+// precedence, from the standard Cache-Status to those of common CDNs and
+// proxies.
+var cacheHeaders = []string{
+	"Cache-Status", "CF-Cache-Status", "X-Cache", "X-Cache-Status",
+	"X-Proxy-Cache", "X-Vercel-Cache", "X-Nextjs-Cache",
+}
+
+// cacheMarkers maps the markers of cache status values to their status, in
+// order of precedence, so TCP_REFRESH_HIT reads as revalidated and not as a
+// hit.
+var cacheMarkers = []struct {
+	marker string
+	status CacheStatus
+}{
+	{"revalidated", CacheRevalidated},
+	{"refresh_hit", CacheRevalidated},
+	{"stale", CacheStale},
+	{"updating", CacheStale},
+	{"expired", CacheExpired},
+	{"hit", CacheHit},
+	{"miss", CacheMiss},
+	{"fwd=", CacheMiss},
+	{"pass", CacheBypass},
+	{"dynamic", CacheBypass},
+	{"uncacheable", CacheBypass},
+}
+
+// cacheStatus returns the cache status reported by giving response headers,
+// being the status of the cache closest to the client when many caches are
+// listed, such as the edge of Fastly's "MISS, HIT". Responses reporting no
+// status but served with an Age are taken as hits. Statuses of unknown values
+// are returned as is.
+func cacheStatus(header http.Header) CacheStatus {
+	for _, name := range cacheHeaders {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+
+		members := strings.Split(value, ",")
+		member := strings.ToLower(strings.TrimSpace(members[len(members)-1]))
+
+		for _, marker := range cacheMarkers {
+			if strings.Contains(member, marker.marker) {
+				return marker.status
+			}
+		}
+		return CacheStatus(member)
+	}
+
+	if age, err := strconv.Atoi(strings.TrimSpace(header.Get("Age"))); err == nil && age > 0 {
+		return CacheHit
+	}
+	return ""
+}
+
+// primes returns true if giving link is requested with a GET to warm caches,
+// being within the crawl's scope when priming.
+func (pc PageCrawler) primes(link *url.URL) bool {
+	return pc.PrimeCache && pc.Target != nil && pc.inScope(pc.Target, link)
+}
+
+// probeCache returns the cache status of giving primed url, requested again
+// with a HEAD negotiating the same encodings as it's GET, so it hits the same
+// cached variant.
+func (pc PageCrawler) probeCache(ctx context.Context, target *url.URL) CacheStatus {
+	if err := pc.scheduler.Wait(ctx, target); err != nil {
+		return ""
+	}
+
+	ctx, cancel := pc.requestContext(ctx)
+	defer cancel()
+
+	req, err := pc.newRequest(ctx, http.MethodHead, target)
+	if err != nil {
+		return ""
+	}
+
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	res, _, err := followRedirects(pc.fetch, req, pc.allowsRedirect, pc.maxRedirects())
+	if err != nil {
+		return ""
+	}
+
+	defer closeBody(res.Body)
+	return cacheStatus(res.Header)
+}
@@ -0,0 +1,38 @@
+package crawler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestCacheStatus(t *testing.T) {
+	fixtures := []struct {
+		header   http.Header
+		expected CacheStatus
+	}{
+		{http.Header{"Cf-Cache-Status": {"HIT"}}, CacheHit},
+		{http.Header{"Cf-Cache-Status": {"DYNAMIC"}}, CacheBypass},
+		{http.Header{"X-Cache": {"Miss from cloudfront"}}, CacheMiss},
+		{http.Header{"X-Cache": {"MISS, HIT"}}, CacheHit},
+		{http.Header{"X-Cache": {"TCP_REFRESH_HIT"}}, CacheRevalidated},
+		{http.Header{"X-Cache-Status": {"STALE"}}, CacheStale},
+		{http.Header{"Cache-Status": {"origin; fwd=uri-miss, cdn; hit"}}, CacheHit},
+		{http.Header{"Cache-Status": {"cdn; fwd=stale; stored"}}, CacheStale},
+		{http.Header{"Cache-Status": {"cdn; fwd=request"}}, CacheMiss},
+		{http.Header{"Cache-Status": {"cdn; hit"}, "X-Cache": {"MISS"}}, CacheHit},
+		{http.Header{"X-Vercel-Cache": {"PRERENDER"}}, CacheStatus("prerender")},
+		{http.Header{"Age": {"120"}}, CacheHit},
+		{http.Header{"Age": {"0"}}, ""},
+		{http.Header{}, ""},
+	}
+
+	for _, fixture := range fixtures {
+		if status := cacheStatus(fixture.header); status != fixture.expected {
+			tests.Info("Header: %v, Expected: %q, Received: %q", fixture.header, fixture.expected, status)
+			tests.Failed("Should have read the cache status reported by headers")
+		}
+	}
+	tests.Passed("Should have read the cache status reported by headers")
+}
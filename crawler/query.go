@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidQueryPolicy is returned when parsing an unknown query policy.
+var ErrInvalidQueryPolicy = errors.New("invalid query policy, expected keep, strip or whitelist=name,...")
+
+// QueryMode defines whether the query strings of links distinguish urls.
+type QueryMode string
+
+// query modes ...
+const (
+	// QueryKeep keeps query strings, so urls of different queries such as the
+	// pages of a paginated listing are crawled apart.
+	QueryKeep QueryMode = "keep"
+
+	// QueryStrip strips query strings, so urls differing only by their query
+	// are crawled once.
+	QueryStrip QueryMode = "strip"
+
+	// QueryWhitelist keeps only the query parameters of the policy's names,
+	// stripping all others.
+	QueryWhitelist QueryMode = "whitelist"
+)
+
+// QueryPolicy defines how the query strings of links are handled before
+// they're checked and crawled. The zero value keeps all query strings.
+type QueryPolicy struct {
+	Mode   QueryMode
+	Params []string
+}
+
+// ParseQueryPolicy parses giving query policy of the form keep, strip or
+// whitelist=page,sort, an empty policy defaults to QueryKeep.
+func ParseQueryPolicy(policy string) (QueryPolicy, error) {
+	mode, params := policy, ""
+	if index := strings.IndexByte(policy, '='); index != -1 {
+		mode, params = policy[:index], policy[index+1:]
+	}
+
+	switch QueryMode(strings.ToLower(mode)) {
+	case "", QueryKeep:
+		if params == "" {
+			return QueryPolicy{Mode: QueryKeep}, nil
+		}
+	case QueryStrip:
+		if params == "" {
+			return QueryPolicy{Mode: QueryStrip}, nil
+		}
+	case QueryWhitelist:
+		var names []string
+		for _, name := range strings.Split(params, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+
+		if len(names) != 0 {
+			return QueryPolicy{Mode: QueryWhitelist, Params: names}, nil
+		}
+	}
+
+	return QueryPolicy{}, fmt.Errorf("%+s: %+q", ErrInvalidQueryPolicy, policy)
+}
+
+// validate returns an error if the policy's mode is unknown or it's params
+// contradict it's mode.
+func (q QueryPolicy) validate() error {
+	switch q.Mode {
+	case "", QueryKeep, QueryStrip:
+		if len(q.Params) == 0 {
+			return nil
+		}
+	case QueryWhitelist:
+		if len(q.Params) != 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%+s: %+q with params %+q", ErrInvalidQueryPolicy, q.Mode, q.Params)
+}
+
+// Apply returns a copy of giving link with it's query handled per the policy
+// and the names of the query parameters stripped. The link is returned as is
+// if none are stripped.
+func (q QueryPolicy) Apply(link *url.URL) (*url.URL, []string) {
+	if link.RawQuery == "" {
+		return link, nil
+	}
+
+	switch q.Mode {
+	case QueryStrip, QueryWhitelist:
+	default:
+		return link, nil
+	}
+
+	var kept []string
+	stripped := map[string]bool{}
+	for _, param := range strings.Split(link.RawQuery, "&") {
+		if param == "" {
+			continue
+		}
+
+		name := queryName(param)
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if q.allows(name) {
+			kept = append(kept, param)
+			continue
+		}
+		stripped[name] = true
+	}
+
+	if len(stripped) == 0 {
+		return link, nil
+	}
+
+	names := make([]string, 0, len(stripped))
+	for name := range stripped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clean := *link
+	clean.RawQuery = strings.Join(kept, "&")
+	clean.ForceQuery = false
+	return &clean, names
+}
+
+// allows returns true if the query parameter of giving name is kept.
+func (q QueryPolicy) allows(name string) bool {
+	if q.Mode != QueryWhitelist {
+		return false
+	}
+
+	for _, param := range q.Params {
+		if param == name {
+			return true
+		}
+	}
+	return false
+}
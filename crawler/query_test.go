@@ -0,0 +1,59 @@
+package crawler
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestParseQueryPolicy(t *testing.T) {
+	if policy, err := ParseQueryPolicy(""); err != nil || policy.Mode != QueryKeep {
+		tests.Info("Received Policy: %#v", policy)
+		tests.Failed("Should have defaulted empty query policy to keep")
+	}
+	tests.Passed("Should have defaulted empty query policy to keep")
+
+	if policy, err := ParseQueryPolicy("whitelist=page, sort"); err != nil || policy.Mode != QueryWhitelist || !reflect.DeepEqual(policy.Params, []string{"page", "sort"}) {
+		tests.Info("Received Policy: %#v", policy)
+		tests.Failed("Should have successfully parsed whitelist policy")
+	}
+	tests.Passed("Should have successfully parsed whitelist policy")
+
+	for _, policy := range []string{"whitelist", "whitelist=", "strip=page", "drop"} {
+		if _, err := ParseQueryPolicy(policy); err == nil {
+			tests.Failed("Should have failed to parse query policy %q", policy)
+		}
+		tests.Passed("Should have failed to parse query policy %q", policy)
+	}
+}
+
+func TestQueryPolicyApply(t *testing.T) {
+	link, _ := url.Parse("http://site.com/list?page=2&ref=home&sort=asc&utm_source=mail")
+
+	if kept, stripped := (QueryPolicy{Mode: QueryKeep}).Apply(link); kept != link || stripped != nil {
+		tests.Info("Received URL: %q, Stripped: %#v", kept, stripped)
+		tests.Failed("Should have kept query string as is")
+	}
+	tests.Passed("Should have kept query string as is")
+
+	stripped, names := (QueryPolicy{Mode: QueryStrip}).Apply(link)
+	if stripped.String() != "http://site.com/list" || !reflect.DeepEqual(names, []string{"page", "ref", "sort", "utm_source"}) {
+		tests.Info("Received URL: %q, Stripped: %#v", stripped, names)
+		tests.Failed("Should have stripped query string")
+	}
+	tests.Passed("Should have stripped query string")
+
+	whitelisted, names := (QueryPolicy{Mode: QueryWhitelist, Params: []string{"page", "sort"}}).Apply(link)
+	if whitelisted.String() != "http://site.com/list?page=2&sort=asc" || !reflect.DeepEqual(names, []string{"ref", "utm_source"}) {
+		tests.Info("Received URL: %q, Stripped: %#v", whitelisted, names)
+		tests.Failed("Should have kept only whitelisted parameters")
+	}
+	tests.Passed("Should have kept only whitelisted parameters")
+
+	if link.RawQuery != "page=2&ref=home&sort=asc&utm_source=mail" {
+		tests.Failed("Should have left giving link unchanged")
+	}
+	tests.Passed("Should have left giving link unchanged")
+}
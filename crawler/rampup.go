@@ -0,0 +1,181 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errors ...
+var (
+	ErrInvalidRampUp = errors.New("invalid ramp up, expected start:max:window e.g 2:50:1m")
+)
+
+// rampTick sets how often requests waiting on the ramp check if the allowed
+// concurrency grew.
+const rampTick = 50 * time.Millisecond
+
+// rampSamples sets total recent requests the error rate is measured over.
+const rampSamples = 20
+
+// defaultRampErrorRate is the error rate above which ramping holds when none is set.
+const defaultRampErrorRate = 0.1
+
+// RampUp defines a warm-up schedule of a crawl, which starts at Start concurrent
+// requests and ramps up linearly to Max over Window, rather than hitting an
+// origin with full concurrency instantly. Ramping holds while the error rate
+// of recent requests is above MaxErrorRate, which defaults to 0.1.
+type RampUp struct {
+	Start        int
+	Max          int
+	Window       time.Duration
+	MaxErrorRate float64
+}
+
+// ParseRampUp parses a giving ramp up in the format of `start:max:window`
+// e.g `2:50:1m`.
+func ParseRampUp(ramp string) (RampUp, error) {
+	var parsed RampUp
+
+	parts := strings.Split(strings.TrimSpace(ramp), ":")
+	if len(parts) != 3 {
+		return parsed, fmt.Errorf("%+s: %+q", ErrInvalidRampUp, ramp)
+	}
+
+	start, err := strconv.Atoi(parts[0])
+	if err != nil || start <= 0 {
+		return parsed, fmt.Errorf("%+s: %+q", ErrInvalidRampUp, ramp)
+	}
+
+	max, err := strconv.Atoi(parts[1])
+	if err != nil || max < start {
+		return parsed, fmt.Errorf("%+s: %+q", ErrInvalidRampUp, ramp)
+	}
+
+	window, err := time.ParseDuration(parts[2])
+	if err != nil || window <= 0 {
+		return parsed, fmt.Errorf("%+s: %+q", ErrInvalidRampUp, ramp)
+	}
+
+	parsed.Start = start
+	parsed.Max = max
+	parsed.Window = window
+	return parsed, nil
+}
+
+// rampLimiter implements the concurrency restriction of a RampUp schedule,
+// advancing the schedule only while the error rate of recent requests is
+// within the allowed rate.
+type rampLimiter struct {
+	ramp    RampUp
+	ml      sync.Mutex
+	active  int
+	elapsed time.Duration
+	last    time.Time
+	samples [rampSamples]bool
+	sampled int
+	errors  int
+	freed   chan struct{}
+}
+
+func newRampLimiter(ramp RampUp, now time.Time) *rampLimiter {
+	return &rampLimiter{
+		ramp:  ramp,
+		last:  now,
+		freed: make(chan struct{}),
+	}
+}
+
+// Acquire blocks till the schedule has capacity for another request, returning
+// a function to release the secured slot with whether the request failed.
+func (r *rampLimiter) Acquire(ctx context.Context) (func(failed bool), error) {
+	if r == nil {
+		return func(bool) {}, nil
+	}
+
+	for {
+		r.ml.Lock()
+		if r.active < r.limit(time.Now()) {
+			r.active++
+			r.ml.Unlock()
+			return r.release, nil
+		}
+		freed := r.freed
+		r.ml.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return func(bool) {}, ctx.Err()
+		case <-freed:
+		case <-time.After(rampTick):
+		}
+	}
+}
+
+// release frees a secured slot, recording the outcome of it's request.
+func (r *rampLimiter) release(failed bool) {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	r.active--
+
+	slot := r.sampled % rampSamples
+	if r.sampled >= rampSamples && r.samples[slot] {
+		r.errors--
+	}
+
+	r.samples[slot] = failed
+	if failed {
+		r.errors++
+	}
+	r.sampled++
+
+	close(r.freed)
+	r.freed = make(chan struct{})
+}
+
+// limit returns the concurrent requests allowed at giving time, advancing the
+// schedule by the time passed if the error rate allows it. It must be called
+// with the lock held.
+func (r *rampLimiter) limit(now time.Time) int {
+	maxErrorRate := r.ramp.MaxErrorRate
+	if maxErrorRate <= 0 {
+		maxErrorRate = defaultRampErrorRate
+	}
+
+	if passed := now.Sub(r.last); passed > 0 {
+		if r.errorRate() <= maxErrorRate {
+			r.elapsed += passed
+		}
+		r.last = now
+	}
+
+	if r.elapsed >= r.ramp.Window {
+		return r.ramp.Max
+	}
+
+	return r.ramp.Start + int(float64(r.ramp.Max-r.ramp.Start)*float64(r.elapsed)/float64(r.ramp.Window))
+}
+
+// errorRate returns the share of recent requests which failed.
+func (r *rampLimiter) errorRate() float64 {
+	total := r.sampled
+	if total > rampSamples {
+		total = rampSamples
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(r.errors) / float64(total)
+}
+
+// isOriginError returns true if giving status signals a struggling origin.
+func isOriginError(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
@@ -0,0 +1,92 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestParseRampUp(t *testing.T) {
+	ramp, err := ParseRampUp("2:50:1m")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed ramp up")
+	}
+	tests.Passed("Should have successfully parsed ramp up")
+
+	if ramp.Start != 2 || ramp.Max != 50 || ramp.Window != time.Minute {
+		tests.Info("Received Ramp: %#v", ramp)
+		tests.Failed("Should have parsed start, max and window of ramp up")
+	}
+	tests.Passed("Should have parsed start, max and window of ramp up")
+
+	if _, err := ParseRampUp("50:2:1m"); err == nil {
+		tests.Failed("Should have failed to parse ramp up with max below start")
+	}
+	tests.Passed("Should have failed to parse ramp up with max below start")
+}
+
+func TestRampLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newRampLimiter(RampUp{Start: 2, Max: 10, Window: 10 * time.Second}, now)
+
+	if limit := limiter.limit(now); limit != 2 {
+		tests.Info("Received Limit: %d", limit)
+		tests.Failed("Should have started at start concurrency")
+	}
+	tests.Passed("Should have started at start concurrency")
+
+	if limit := limiter.limit(now.Add(5 * time.Second)); limit != 6 {
+		tests.Info("Received Limit: %d", limit)
+		tests.Failed("Should have ramped halfway through window")
+	}
+	tests.Passed("Should have ramped halfway through window")
+
+	for index := 0; index < rampSamples; index++ {
+		limiter.active++
+		limiter.release(true)
+	}
+
+	if limit := limiter.limit(now.Add(8 * time.Second)); limit != 6 {
+		tests.Info("Received Limit: %d", limit)
+		tests.Failed("Should have held ramp while requests fail")
+	}
+	tests.Passed("Should have held ramp while requests fail")
+
+	for index := 0; index < rampSamples; index++ {
+		limiter.active++
+		limiter.release(false)
+	}
+
+	if limit := limiter.limit(now.Add(20 * time.Second)); limit != 10 {
+		tests.Info("Received Limit: %d", limit)
+		tests.Failed("Should have ramped to max once requests recover")
+	}
+	tests.Passed("Should have ramped to max once requests recover")
+}
+
+func TestRampLimiterAcquire(t *testing.T) {
+	limiter := newRampLimiter(RampUp{Start: 1, Max: 2, Window: time.Hour}, time.Now())
+
+	done, err := limiter.Acquire(context.Background())
+	if err != nil {
+		tests.FailedWithError(err, "Should have acquired slot within start concurrency")
+	}
+	tests.Passed("Should have acquired slot within start concurrency")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := limiter.Acquire(ctx); err == nil {
+		tests.Failed("Should have blocked request beyond start concurrency")
+	}
+	tests.Passed("Should have blocked request beyond start concurrency")
+
+	go done(false)
+
+	if _, err := limiter.Acquire(context.Background()); err != nil {
+		tests.FailedWithError(err, "Should have acquired slot once released")
+	}
+	tests.Passed("Should have acquired slot once released")
+}
@@ -0,0 +1,340 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errors ...
+var (
+	ErrInvalidRate = errors.New("invalid rate, expected requests/period e.g 5/s")
+)
+
+// Rate defines the total requests allowed within a giving period.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseRate parses a giving rate in the format of `requests/period`, where
+// period is either a unit (ms, s, m, h) or a duration e.g `5/s`, `100/m`, `1/500ms`.
+func ParseRate(rate string) (Rate, error) {
+	var parsed Rate
+
+	parts := strings.SplitN(strings.TrimSpace(rate), "/", 2)
+	if len(parts) != 2 {
+		return parsed, ErrInvalidRate
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return parsed, ErrInvalidRate
+	}
+
+	period := parts[1]
+	switch period {
+	case "ms", "s", "m", "h":
+		period = "1" + period
+	}
+
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return parsed, fmt.Errorf("%+s: %+q", ErrInvalidRate, rate)
+	}
+
+	parsed.Requests = requests
+	parsed.Per = per
+	return parsed, nil
+}
+
+// Interval returns the duration between each request allowed by the rate.
+func (r Rate) Interval() time.Duration {
+	if r.Requests <= 0 {
+		return 0
+	}
+	return r.Per / time.Duration(r.Requests)
+}
+
+// maxRetryAfter caps the pause a host can request through Retry-After.
+const maxRetryAfter = 5 * time.Minute
+
+// HostResolver defines the contract for resolving the ip addresses of a host.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// HostLimiter implements a concurrent-safe token bucket rate limiter keyed
+// by host, ensuring no single origin receives more than the giving rate
+// of requests. Hosts can be keyed by their ip address instead, so hosts
+// sharing one origin server share it's rate.
+type HostLimiter struct {
+	rate     Rate
+	ml       sync.Mutex
+	resolver HostResolver
+	ips      map[string]string
+	delays   map[string]time.Duration
+	paused   map[string]time.Time
+	buckets  map[string]*tokenBucket
+	exempt   []string
+}
+
+// NewHostLimiter returns a new instance of a HostLimiter for giving rate. A zero
+// rate applies no limit except for hosts with a delay set.
+func NewHostLimiter(rate Rate) *HostLimiter {
+	return &HostLimiter{
+		rate:    rate,
+		ips:     map[string]string{},
+		delays:  map[string]time.Duration{},
+		paused:  map[string]time.Time{},
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// KeyByIP sets the limiter to apply limits per ip address of hosts resolved
+// with giving resolver, rather than per host name. Hosts which fail to resolve
+// are limited by their name.
+func (h *HostLimiter) KeyByIP(resolver HostResolver) {
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	h.resolver = resolver
+}
+
+// Exempt exempts the hosts matching giving patterns, such as staging.monzo.com
+// or *.internal.monzo.com, from all limits, delays and pauses, so hosts owned
+// by the crawl's operator are requested at full speed.
+func (h *HostLimiter) Exempt(patterns ...string) {
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	for _, pattern := range patterns {
+		h.exempt = append(h.exempt, strings.ToLower(strings.TrimSpace(pattern)))
+	}
+}
+
+// exempts returns true if giving host is exempt from all limits.
+func (h *HostLimiter) exempts(host string) bool {
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	return matchesHost(h.exempt, host)
+}
+
+// key returns the key limits of giving host are kept under, being the lowest
+// of it's resolved ip addresses when keyed by ip, so the key is stable across
+// differently ordered lookups, else the host itself. Resolved addresses are
+// cached for the life of the limiter.
+func (h *HostLimiter) key(ctx context.Context, host string) string {
+	h.ml.Lock()
+	resolver := h.resolver
+	ip, ok := h.ips[host]
+	h.ml.Unlock()
+
+	if resolver == nil {
+		return host
+	}
+
+	if ok {
+		return ip
+	}
+
+	name := host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		name = hostname
+	}
+
+	ip = host
+	if addrs, err := resolver.LookupHost(ctx, name); err == nil && len(addrs) != 0 {
+		ip = addrs[0]
+		for _, addr := range addrs[1:] {
+			if addr < ip {
+				ip = addr
+			}
+		}
+	}
+
+	h.ml.Lock()
+	h.ips[host] = ip
+	h.ml.Unlock()
+	return ip
+}
+
+// SetDelay sets the minimum delay between requests to giving host, such as
+// a Crawl-delay provided by the host's robots.txt. The delay only applies if
+// it is slower than the limiter's rate.
+func (h *HostLimiter) SetDelay(host string, delay time.Duration) {
+	if h.exempts(host) {
+		return
+	}
+
+	host = h.key(context.Background(), host)
+
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	h.delays[host] = delay
+	delete(h.buckets, host)
+}
+
+// Pause pauses all requests to giving host till provided time.
+func (h *HostLimiter) Pause(host string, until time.Time) {
+	host = h.key(context.Background(), host)
+
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	if until.After(h.paused[host]) {
+		h.paused[host] = until
+	}
+}
+
+// Observe pauses requests to giving host if the response is a 429 or 503
+// carrying a Retry-After header, returning true if a pause was set.
+func (h *HostLimiter) Observe(host string, res *http.Response) bool {
+	if h == nil {
+		return false
+	}
+
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return false
+	}
+
+	if h.exempts(host) {
+		return false
+	}
+
+	wait, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return false
+	}
+
+	h.Pause(host, time.Now().Add(wait))
+	return true
+}
+
+// parseRetryAfter parses giving Retry-After value which is either in delay
+// seconds or a http date, capped at maxRetryAfter.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = at.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+
+	return wait, true
+}
+
+// Wait blocks till a request to giving host is allowed, returning an error
+// if the context gets cancelled before.
+func (h *HostLimiter) Wait(ctx context.Context, host string) error {
+	if h == nil || h.exempts(host) {
+		return nil
+	}
+
+	host = h.key(ctx, host)
+
+	now := time.Now()
+	wait := h.reserve(host, now)
+
+	h.ml.Lock()
+	if paused := h.paused[host].Sub(now); paused > wait {
+		wait = paused
+	}
+	h.ml.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve reserves a token from giving host's bucket, returning duration
+// to wait before the token becomes available.
+func (h *HostLimiter) reserve(host string, now time.Time) time.Duration {
+	h.ml.Lock()
+	defer h.ml.Unlock()
+
+	bucket, ok := h.buckets[host]
+	if !ok {
+		burst, interval := h.rate.Requests, h.rate.Interval()
+		if delay := h.delays[host]; delay > interval {
+			burst, interval = 1, delay
+		}
+
+		if interval <= 0 {
+			return 0
+		}
+
+		bucket = &tokenBucket{
+			burst:    float64(burst),
+			tokens:   float64(burst),
+			interval: interval,
+			last:     now,
+		}
+		h.buckets[host] = bucket
+	}
+
+	return bucket.reserve(now)
+}
+
+// tokenBucket implements a token bucket which refills a token every interval
+// up to it's burst size. Tokens can be reserved ahead, causing the balance
+// to go negative for waiting requests.
+type tokenBucket struct {
+	burst    float64
+	tokens   float64
+	interval time.Duration
+	last     time.Time
+}
+
+func (t *tokenBucket) reserve(now time.Time) time.Duration {
+	if elapsed := now.Sub(t.last); elapsed > 0 {
+		t.tokens += float64(elapsed) / float64(t.interval)
+		if t.tokens > t.burst {
+			t.tokens = t.burst
+		}
+		t.last = now
+	}
+
+	t.tokens--
+	if t.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-t.tokens * float64(t.interval))
+}
@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestParseRate(t *testing.T) {
+	rate, err := ParseRate("5/s")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed rate")
+	}
+	tests.Passed("Should have successfully parsed rate")
+
+	if rate.Interval() != 200*time.Millisecond {
+		tests.Info("Expected Interval: %s", 200*time.Millisecond)
+		tests.Info("Received Interval: %s", rate.Interval())
+		tests.Failed("Should have 200ms interval for 5/s rate")
+	}
+	tests.Passed("Should have 200ms interval for 5/s rate")
+
+	if _, err := ParseRate("5"); err == nil {
+		tests.Failed("Should have failed to parse rate without period")
+	}
+	tests.Passed("Should have failed to parse rate without period")
+}
+
+func TestHostLimiter(t *testing.T) {
+	limiter := NewHostLimiter(Rate{Requests: 2, Per: time.Second})
+
+	now := time.Now()
+	if wait := limiter.reserve("mombo.com", now); wait != 0 {
+		tests.Failed("Should have allowed first request within burst")
+	}
+	tests.Passed("Should have allowed first request within burst")
+
+	if wait := limiter.reserve("mombo.com", now); wait != 0 {
+		tests.Failed("Should have allowed second request within burst")
+	}
+	tests.Passed("Should have allowed second request within burst")
+
+	if wait := limiter.reserve("mombo.com", now); wait != 500*time.Millisecond {
+		tests.Info("Received Wait: %s", wait)
+		tests.Failed("Should have delayed third request by 500ms")
+	}
+	tests.Passed("Should have delayed third request by 500ms")
+
+	if wait := limiter.reserve("gracehound.com", now); wait != 0 {
+		tests.Failed("Should have allowed request to separate host")
+	}
+	tests.Passed("Should have allowed request to separate host")
+
+	limiter.SetDelay("gracehound.com", 2*time.Second)
+	limiter.reserve("gracehound.com", now)
+	if wait := limiter.reserve("gracehound.com", now); wait != 2*time.Second {
+		tests.Info("Received Wait: %s", wait)
+		tests.Failed("Should have delayed request by host's crawl delay")
+	}
+	tests.Passed("Should have delayed request by host's crawl delay")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := limiter.Wait(ctx, "gracehound.com"); err == nil {
+		tests.Failed("Should have failed to wait with cancelled context")
+	}
+	tests.Passed("Should have failed to wait with cancelled context")
+}
+
+func TestParseRobots(t *testing.T) {
+	rules := ParseRobots(strings.NewReader(`
+User-agent: *
+Crawl-delay: 2
+Disallow: /admin
+
+User-agent: sitecrawler
+Crawl-delay: 0.5
+`), "sitecrawler")
+
+	if rules.CrawlDelay != 500*time.Millisecond {
+		tests.Info("Received Delay: %s", rules.CrawlDelay)
+		tests.Failed("Should have used crawl delay of crawler's group")
+	}
+	tests.Passed("Should have used crawl delay of crawler's group")
+
+	rules = ParseRobots(strings.NewReader(`
+User-agent: googlebot
+Crawl-delay: 10
+
+User-agent: *
+Crawl-delay: 2
+`), "sitecrawler")
+
+	if rules.CrawlDelay != 2*time.Second {
+		tests.Info("Received Delay: %s", rules.CrawlDelay)
+		tests.Failed("Should have used crawl delay of default group")
+	}
+	tests.Passed("Should have used crawl delay of default group")
+}
+
+func TestHostLimiterRetryAfter(t *testing.T) {
+	limiter := NewHostLimiter(Rate{})
+
+	res := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"1"}},
+	}
+
+	if !limiter.Observe("mombo.com", res) {
+		tests.Failed("Should have paused host with Retry-After")
+	}
+	tests.Passed("Should have paused host with Retry-After")
+
+	start := time.Now()
+	if err := limiter.Wait(context.Background(), "mombo.com"); err != nil {
+		tests.FailedWithError(err, "Should have successfully waited on paused host")
+	}
+	tests.Passed("Should have successfully waited on paused host")
+
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		tests.Info("Received Wait: %s", elapsed)
+		tests.Failed("Should have waited for the Retry-After duration")
+	}
+	tests.Passed("Should have waited for the Retry-After duration")
+
+	res.StatusCode = http.StatusNotFound
+	if limiter.Observe("mombo.com", res) {
+		tests.Failed("Should have ignored Retry-After of non 429/503 response")
+	}
+	tests.Passed("Should have ignored Retry-After of non 429/503 response")
+
+	if wait, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), time.Now()); !ok || wait != maxRetryAfter {
+		tests.Info("Received Wait: %s", wait)
+		tests.Failed("Should have capped http date Retry-After")
+	}
+	tests.Passed("Should have capped http date Retry-After")
+}
+
+// staticResolver implements the HostResolver with fixed addresses per host.
+type staticResolver map[string][]string
+
+func (s staticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := s[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestHostLimiterKeyByIP(t *testing.T) {
+	limiter := NewHostLimiter(Rate{Requests: 1, Per: time.Second})
+	limiter.KeyByIP(staticResolver{
+		"mombo.com":      {"10.0.0.2", "10.0.0.1"},
+		"blog.mombo.com": {"10.0.0.1"},
+	})
+
+	if err := limiter.Wait(context.Background(), "mombo.com:8080"); err != nil {
+		tests.FailedWithError(err, "Should have allowed first request within burst")
+	}
+	tests.Passed("Should have allowed first request within burst")
+
+	if wait := limiter.reserve(limiter.key(context.Background(), "blog.mombo.com"), time.Now()); wait <= 0 {
+		tests.Failed("Should have delayed request to host sharing ip address")
+	}
+	tests.Passed("Should have delayed request to host sharing ip address")
+
+	if key := limiter.key(context.Background(), "gracehound.com"); key != "gracehound.com" {
+		tests.Info("Received Key: %q", key)
+		tests.Failed("Should have limited unresolved host by it's name")
+	}
+	tests.Passed("Should have limited unresolved host by it's name")
+}
+
+func TestHostLimiterExempt(t *testing.T) {
+	limiter := NewHostLimiter(Rate{Requests: 1, Per: time.Hour})
+	limiter.Exempt("Staging.mombo.com", "*.internal.mombo.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	limiter.SetDelay("staging.mombo.com", time.Hour)
+	limiter.Observe("api.internal.mombo.com:8443", &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"60"}},
+	})
+
+	for _, host := range []string{"staging.mombo.com", "staging.mombo.com", "api.internal.mombo.com:8443", "api.internal.mombo.com:8443"} {
+		if err := limiter.Wait(ctx, host); err != nil {
+			tests.Info("Host: %q", host)
+			tests.FailedWithError(err, "Should have allowed requests to exempt host at full speed")
+		}
+	}
+	tests.Passed("Should have allowed requests to exempt host at full speed")
+
+	if err := limiter.Wait(ctx, "mombo.com"); err != nil {
+		tests.FailedWithError(err, "Should have allowed first request within burst")
+	}
+
+	if err := limiter.Wait(ctx, "mombo.com"); err == nil {
+		tests.Failed("Should have limited requests to other hosts")
+	}
+	tests.Passed("Should have limited requests to other hosts")
+}
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+)
+
+// ReasonCode defines the class of a failure recorded for a giving url.
+type ReasonCode string
+
+// reason codes ...
+const (
+	ReasonDNS        ReasonCode = "dns"
+	ReasonTimeout    ReasonCode = "timeout"
+	ReasonTLS        ReasonCode = "tls"
+	ReasonConnection ReasonCode = "connection"
+	ReasonHTTPStatus ReasonCode = "http-status"
+	ReasonNonHTML    ReasonCode = "non-html"
+	ReasonRedirect   ReasonCode = "redirect"
+	ReasonBudget     ReasonCode = "budget"
+	ReasonTrap       ReasonCode = "trap"
+	ReasonUnknown    ReasonCode = "unknown"
+)
+
+// Reason embodies a structured failure reason for a url's status, it
+// carries the class of the failure alongside it's message so it serializes
+// consistently across output formats.
+type Reason struct {
+	Code    ReasonCode `json:"code"`
+	Message string     `json:"message"`
+}
+
+// NewReason returns a new Reason for giving error, classifying the error
+// into it's appropriate code. It returns nil if error is nil.
+func NewReason(err error) *Reason {
+	if err == nil {
+		return nil
+	}
+
+	return &Reason{
+		Code:    classifyError(err),
+		Message: err.Error(),
+	}
+}
+
+// Error implements the error interface.
+func (r *Reason) Error() string {
+	return r.Message
+}
+
+// classifyError returns the ReasonCode matching giving error.
+func classifyError(err error) ReasonCode {
+	switch {
+	case errors.Is(err, ErrPageFailed), errors.Is(err, ErrHostPaused):
+		return ReasonHTTPStatus
+	case errors.Is(err, ErrNonHTMLURL):
+		return ReasonNonHTML
+	case errors.Is(err, ErrRedirectLoop), errors.Is(err, ErrTooManyRedirects):
+		return ReasonRedirect
+	case errors.Is(err, ErrBudgetExhausted):
+		return ReasonBudget
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrReadTimeout):
+		return ReasonTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ReasonDNS
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ReasonTimeout
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) {
+		return ReasonTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ReasonConnection
+	}
+
+	return ReasonUnknown
+}
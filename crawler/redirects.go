@@ -0,0 +1,231 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// errors ...
+var (
+	ErrRedirectLoop     = errors.New("redirect chain loops back to a visited url")
+	ErrTooManyRedirects = errors.New("redirect chain exceeds maximum redirects")
+	ErrInvalidPolicy    = errors.New("invalid redirect policy, expected same-host, any or none")
+
+	ErrInvalidExternalPolicy = errors.New("invalid external redirect policy, expected follow, first-hop or none")
+)
+
+// RedirectPolicy defines which redirects are followed by the crawler.
+type RedirectPolicy string
+
+// redirect policies ...
+const (
+	RedirectAny      RedirectPolicy = "any"
+	RedirectSameHost RedirectPolicy = "same-host"
+	RedirectNone     RedirectPolicy = "none"
+)
+
+// ParseRedirectPolicy parses giving redirect policy, an empty policy
+// defaults to RedirectAny.
+func ParseRedirectPolicy(policy string) (RedirectPolicy, error) {
+	switch RedirectPolicy(policy) {
+	case "":
+		return RedirectAny, nil
+	case RedirectAny, RedirectSameHost, RedirectNone:
+		return RedirectPolicy(policy), nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrInvalidPolicy, policy)
+}
+
+// Allows returns true if the policy allows following a redirect from
+// giving url to provided location.
+func (p RedirectPolicy) Allows(from *url.URL, location *url.URL) bool {
+	switch p {
+	case RedirectNone:
+		return false
+	case RedirectSameHost:
+		return from.Host == location.Host
+	}
+	return true
+}
+
+// ExternalRedirectPolicy defines how redirects leading out of the crawl's scope
+// are followed, keeping checks of external urls cheap.
+type ExternalRedirectPolicy string
+
+// external redirect policies ...
+const (
+	// ExternalRedirectFollow follows external redirects as the redirect policy
+	// allows.
+	ExternalRedirectFollow ExternalRedirectPolicy = "follow"
+
+	// ExternalRedirectFirstHop follows the first redirect out of the scope,
+	// recording redirects of the external url without fetching them.
+	ExternalRedirectFirstHop ExternalRedirectPolicy = "first-hop"
+
+	// ExternalRedirectNone records redirects out of the scope with their
+	// location without fetching it.
+	ExternalRedirectNone ExternalRedirectPolicy = "none"
+)
+
+// ParseExternalRedirectPolicy parses giving external redirect policy, an empty
+// policy defaults to ExternalRedirectFollow.
+func ParseExternalRedirectPolicy(policy string) (ExternalRedirectPolicy, error) {
+	switch ExternalRedirectPolicy(policy) {
+	case "":
+		return ExternalRedirectFollow, nil
+	case ExternalRedirectFollow, ExternalRedirectFirstHop, ExternalRedirectNone:
+		return ExternalRedirectPolicy(policy), nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrInvalidExternalPolicy, policy)
+}
+
+// defaults for redirect following ...
+const (
+	defaultMaxRedirects       = 10
+	defaultRedirectChainLimit = 3
+)
+
+// RedirectHop embodies a single hop of a redirect chain, the url requested
+// and the redirect status it responded with.
+type RedirectHop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// followRedirects sends giving request through the fetcher, following all
+// redirects allowed by allows itself so every hop of the chain is recorded. It fails with ErrRedirectLoop
+// if a hop returns to a visited url or ErrTooManyRedirects if the chain exceeds
+// max hops. The final response's Request holds the final url, a redirect not
+// allowed is returned as the final response.
+func followRedirects(fetcher Fetcher, req *http.Request, allows func(from *url.URL, location *url.URL) bool, max int) (*http.Response, []RedirectHop, error) {
+	var hops []RedirectHop
+	visited := map[string]bool{req.URL.String(): true}
+
+	for {
+		res, err := fetch(fetcher, req)
+		if err != nil {
+			return nil, hops, err
+		}
+
+		location, err := res.Location()
+		if !isRedirect(res.StatusCode) || err != nil || !allows(req.URL, location) {
+			decodeBody(res)
+			return res, hops, nil
+		}
+
+		closeBody(res.Body)
+
+		hops = append(hops, RedirectHop{URL: req.URL.String(), Status: res.StatusCode})
+
+		if visited[location.String()] {
+			return nil, hops, ErrRedirectLoop
+		}
+
+		if len(hops) >= max {
+			return nil, hops, ErrTooManyRedirects
+		}
+
+		visited[location.String()] = true
+
+		next := req.Clone(req.Context())
+		next.URL = location
+		next.Host = ""
+
+		// Never leak credentials to other hosts.
+		if location.Host != req.URL.Host {
+			next.Header.Del("Authorization")
+			next.Header.Del("Cookie")
+		}
+
+		req = next
+	}
+}
+
+// isRedirect returns true if giving status code is a redirect carrying a location.
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// RedirectedPage embodies a page which was only reached through redirects
+// from the linked urls which point to it.
+type RedirectedPage struct {
+	URL string   `json:"url"`
+	Via []string `json:"via"`
+}
+
+// RedirectAudit implements a concurrent-safe tracker of links and the
+// redirects they lead to, for finding pages which are never linked directly
+// but only reached through redirects, which often indicates outdated
+// internal links.
+type RedirectAudit struct {
+	ml        sync.Mutex
+	linked    map[string]struct{}
+	redirects map[string]map[string]struct{}
+}
+
+// NewRedirectAudit returns a new instance of a RedirectAudit.
+func NewRedirectAudit() *RedirectAudit {
+	return &RedirectAudit{
+		linked:    map[string]struct{}{},
+		redirects: map[string]map[string]struct{}{},
+	}
+}
+
+// Observe records all outgoing links of giving report and the redirects
+// they lead to.
+func (r *RedirectAudit) Observe(report LinkReport) {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	for _, kid := range report.PointsTo {
+		r.linked[kid.Path.String()] = struct{}{}
+
+		if kid.RedirectedTo == nil {
+			continue
+		}
+
+		target := kid.RedirectedTo.String()
+		if _, ok := r.redirects[target]; !ok {
+			r.redirects[target] = map[string]struct{}{}
+		}
+
+		r.redirects[target][kid.Path.String()] = struct{}{}
+	}
+}
+
+// RedirectOnly returns all pages reached only through redirects, ordered
+// by url.
+func (r *RedirectAudit) RedirectOnly() []RedirectedPage {
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	var pages []RedirectedPage
+	for target, sources := range r.redirects {
+		if _, ok := r.linked[target]; ok {
+			continue
+		}
+
+		page := RedirectedPage{URL: target}
+		for source := range sources {
+			page.Via = append(page.Via, source)
+		}
+
+		sort.Strings(page.Via)
+		pages = append(pages, page)
+	}
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].URL < pages[j].URL
+	})
+
+	return pages
+}
@@ -0,0 +1,597 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errors ...
+var (
+	ErrInvalidRenderMode = errors.New("invalid render mode, expected none or js")
+	ErrNoBrowser         = errors.New("no headless browser found, expected chromium, chromium-browser, google-chrome or chrome")
+	ErrRenderFailed      = errors.New("browser failed to render page")
+)
+
+// defaults of rendering ...
+const (
+	DefaultRenderTimeout = 10 * time.Second
+	DefaultRenderTabs    = 4
+)
+
+// shortMainText sets the length of main element text below which a not found
+// phrase marks the page as a not found view, rather than content mentioning one.
+const shortMainText = 200
+
+// notFoundView matches the titles and texts of client-side not found views.
+var notFoundView = regexp.MustCompile(`(?i)\b404\b|\bnot found\b|\bpage (doesn't|does not|no longer) exists?\b`)
+
+// browsers lists the executables looked up for rendering pages when no browser
+// is set, in order of preference.
+var browsers = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome"}
+
+// RenderMode defines how pages are rendered before their links are farmed.
+type RenderMode string
+
+// render modes ...
+const (
+	RenderNone RenderMode = "none"
+	RenderJS   RenderMode = "js"
+)
+
+// ParseRenderMode parses giving render mode, an empty mode defaults to
+// RenderNone.
+func ParseRenderMode(mode string) (RenderMode, error) {
+	switch RenderMode(mode) {
+	case "":
+		return RenderNone, nil
+	case RenderNone, RenderJS:
+		return RenderMode(mode), nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrInvalidRenderMode, mode)
+}
+
+// Renderer defines the contract for rendering the document of a page links are
+// farmed from, such as the DOM rendered by a browser for single page apps
+// which render their links client-side. The page is requested with the
+// headers of giving request, and the response of it's document returned with
+// the rendered DOM as it's body.
+type Renderer interface {
+	Render(req *http.Request) (*http.Response, error)
+}
+
+// ChromeRenderer implements a Renderer rendering pages in a headless Chrome or
+// Chromium driven over the DevTools protocol, returning the DOM serialized once
+// the page's scripts have run. A single browser process is started on the
+// first render and kept running till the renderer is closed, restarted if it
+// exits. Each page is rendered in a tab of it's own browser context, so pages
+// share no cookies or storage, bounded by the render timeout, with at most tabs
+// pages rendered at once.
+type ChromeRenderer struct {
+	browser string
+	args    []string
+	timeout time.Duration
+	tabs    chan struct{}
+
+	ml      sync.Mutex
+	running *chromeProcess
+}
+
+// chromeProcess embodies a browser process run by a ChromeRenderer, driven
+// over it's devtools pipe.
+type chromeProcess struct {
+	cmd      *exec.Cmd
+	pipe     *cdpPipe
+	commands *os.File
+	answers  *os.File
+	stopped  sync.Once
+	done     chan struct{}
+}
+
+// NewChromeRenderer returns a new instance of a ChromeRenderer running giving
+// browser executable with the additional args, such as --no-sandbox. The
+// browser is looked up on the PATH when unset. The timeout and tabs default
+// to DefaultRenderTimeout and DefaultRenderTabs.
+func NewChromeRenderer(browser string, args []string, timeout time.Duration, tabs int) (*ChromeRenderer, error) {
+	if browser == "" {
+		for _, candidate := range browsers {
+			if path, err := exec.LookPath(candidate); err == nil {
+				browser = path
+				break
+			}
+		}
+
+		if browser == "" {
+			return nil, ErrNoBrowser
+		}
+	} else if _, err := exec.LookPath(browser); err != nil {
+		return nil, fmt.Errorf("%+s: %+q", ErrNoBrowser, browser)
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultRenderTimeout
+	}
+
+	if tabs <= 0 {
+		tabs = DefaultRenderTabs
+	}
+
+	return &ChromeRenderer{
+		browser: browser,
+		args:    args,
+		timeout: timeout,
+		tabs:    make(chan struct{}, tabs),
+	}, nil
+}
+
+// Render implements the Renderer interface, waiting for a free tab before
+// rendering the target of giving request with it's User-Agent, cookies and
+// headers. Scripts are given the render timeout to settle, through the
+// browser's virtual time budget, before the DOM is read.
+func (c *ChromeRenderer) Render(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	select {
+	case c.tabs <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	defer func() { <-c.tabs }()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err := c.render(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%+s: %+q: timed out after %s", ErrRenderFailed, req.URL.String(), c.timeout)
+		}
+		return nil, fmt.Errorf("%+s: %+q: %s", ErrRenderFailed, req.URL.String(), err)
+	}
+	return res, nil
+}
+
+// Close implements the io.Closer interface, stopping the browser pages are
+// rendered in if running.
+func (c *ChromeRenderer) Close() error {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	if c.running != nil {
+		c.running.stop()
+		c.running = nil
+	}
+	return nil
+}
+
+// process returns the running browser process, starting it if it's not
+// running or has exited.
+func (c *ChromeRenderer) process() (*chromeProcess, error) {
+	c.ml.Lock()
+	defer c.ml.Unlock()
+
+	if c.running != nil {
+		if c.running.alive() {
+			return c.running, nil
+		}
+
+		c.running.stop()
+		c.running = nil
+	}
+
+	// The browser reads commands from it's fd 3 and writes answers into it's
+	// fd 4.
+	browserIn, commands, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	answers, browserOut, err := os.Pipe()
+	if err != nil {
+		browserIn.Close()
+		commands.Close()
+		return nil, err
+	}
+
+	args := []string{"--headless", "--disable-gpu", "--remote-debugging-pipe"}
+	args = append(args, c.args...)
+	args = append(args, "about:blank")
+
+	cmd := exec.Command(c.browser, args...)
+	cmd.ExtraFiles = []*os.File{browserIn, browserOut}
+
+	// Processes spawned by the browser may hold it's pipes open once it's
+	// killed, so they're not waited on for long.
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Start()
+	browserIn.Close()
+	browserOut.Close()
+
+	if err != nil {
+		commands.Close()
+		answers.Close()
+		return nil, err
+	}
+
+	c.running = &chromeProcess{
+		cmd:      cmd,
+		pipe:     newCDPPipe(commands, answers),
+		commands: commands,
+		answers:  answers,
+		done:     make(chan struct{}),
+	}
+	return c.running, nil
+}
+
+// alive returns true if the browser process is neither stopped nor has closed
+// it's pipe.
+func (p *chromeProcess) alive() bool {
+	select {
+	case <-p.done:
+		return false
+	case <-p.pipe.Closed():
+		return false
+	default:
+		return true
+	}
+}
+
+// stop kills the browser process, waiting for it to exit.
+func (p *chromeProcess) stop() {
+	p.stopped.Do(func() {
+		close(p.done)
+		p.commands.Close()
+		p.cmd.Process.Kill()
+		p.cmd.Wait()
+		p.answers.Close()
+	})
+}
+
+// closePageTimeout sets the deadline for the browser to close the tab of a
+// rendered page, past which it's deemed hung and restarted.
+const closePageTimeout = time.Second
+
+// render renders the target of giving request in a new tab of the running
+// browser, returning the response of it's document with the rendered DOM. The
+// tab's browser context is disposed once done, stopping the browser if it
+// doesn't answer so the next page restarts it.
+func (c *ChromeRenderer) render(ctx context.Context, req *http.Request) (*http.Response, error) {
+	process, err := c.process()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := process.pipe
+
+	var browserContext struct {
+		BrowserContextID string `json:"browserContextId"`
+	}
+	if err := pipe.Call(ctx, "", "Target.createBrowserContext", nil, &browserContext); err != nil {
+		if ctx.Err() != nil {
+			process.stop()
+		}
+		return nil, err
+	}
+
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), closePageTimeout)
+		defer cancel()
+
+		if err := pipe.Call(closeCtx, "", "Target.disposeBrowserContext", map[string]interface{}{"browserContextId": browserContext.BrowserContextID}, nil); err != nil {
+			process.stop()
+		}
+	}()
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := pipe.Call(ctx, "", "Target.createTarget", map[string]interface{}{"url": "about:blank", "browserContextId": browserContext.BrowserContextID}, &target); err != nil {
+		return nil, err
+	}
+
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := pipe.Call(ctx, "", "Target.attachToTarget", map[string]interface{}{"targetId": target.TargetID, "flatten": true}, &attached); err != nil {
+		return nil, err
+	}
+
+	session := attached.SessionID
+	responses := pipe.Listen(session, "Network.responseReceived", maxRenderResponses)
+	expired := pipe.Listen(session, "Emulation.virtualTimeBudgetExpired", 1)
+	defer pipe.Release(session)
+
+	if err := pipe.Call(ctx, session, "Network.enable", nil, nil); err != nil {
+		return nil, err
+	}
+
+	if userAgent := req.UserAgent(); userAgent != "" {
+		if err := pipe.Call(ctx, session, "Network.setUserAgentOverride", map[string]interface{}{"userAgent": userAgent}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if cookies := req.Cookies(); len(cookies) != 0 {
+		var params []map[string]interface{}
+		for _, cookie := range cookies {
+			params = append(params, map[string]interface{}{"name": cookie.Name, "value": cookie.Value, "url": req.URL.String()})
+		}
+
+		if err := pipe.Call(ctx, session, "Network.setCookies", map[string]interface{}{"cookies": params}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// The browser sets it's own User-Agent, cookies and encodings, which
+	// are left out of the extra headers sent.
+	headers := map[string]string{}
+	for name, values := range req.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "User-Agent", "Cookie", "Accept-Encoding":
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	if len(headers) != 0 {
+		if err := pipe.Call(ctx, session, "Network.setExtraHTTPHeaders", map[string]interface{}{"headers": headers}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// The budget of scripts is kept within the timeout, leaving the browser
+	// time to read the DOM.
+	budget := c.timeout * 3 / 4 / time.Millisecond
+
+	policy := map[string]interface{}{"policy": "pauseIfNetworkFetchesPending", "budget": int64(budget), "waitForNavigation": true}
+	if err := pipe.Call(ctx, session, "Emulation.setVirtualTimePolicy", policy, nil); err != nil {
+		return nil, err
+	}
+
+	var navigated struct {
+		FrameID   string `json:"frameId"`
+		ErrorText string `json:"errorText"`
+	}
+	if err := pipe.Call(ctx, session, "Page.navigate", map[string]interface{}{"url": req.URL.String()}, &navigated); err != nil {
+		return nil, err
+	}
+
+	if navigated.ErrorText != "" {
+		return nil, errors.New(navigated.ErrorText)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-pipe.Closed():
+		return nil, ErrBrowserClosed
+	case <-expired:
+	}
+
+	var evaluated struct {
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+	}
+	if err := pipe.Call(ctx, session, "Runtime.evaluate", map[string]interface{}{"expression": "document.documentElement.outerHTML", "returnByValue": true}, &evaluated); err != nil {
+		return nil, err
+	}
+
+	if evaluated.Result.Value == "" {
+		return nil, errors.New("empty document")
+	}
+
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Request:    req,
+		Body:       ioutil.NopCloser(strings.NewReader(evaluated.Result.Value)),
+	}
+
+	documentResponse(res, responses, navigated.FrameID)
+	res.Status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	return res, nil
+}
+
+// maxRenderResponses sets the total responses received by a rendered page
+// searched for the response of it's document.
+const maxRenderResponses = 64
+
+// documentResponse sets the status and headers of giving response to those of
+// the document of giving frame found among the received responses, if any.
+func documentResponse(res *http.Response, responses <-chan cdpMessage, frameID string) {
+	for {
+		var event cdpMessage
+		select {
+		case event = <-responses:
+		default:
+			return
+		}
+
+		var received struct {
+			Type     string `json:"type"`
+			FrameID  string `json:"frameId"`
+			Response struct {
+				Status  int               `json:"status"`
+				Headers map[string]string `json:"headers"`
+			} `json:"response"`
+		}
+
+		if err := json.Unmarshal(event.Params, &received); err != nil || received.Type != "Document" || received.FrameID != frameID {
+			continue
+		}
+
+		if received.Response.Status != 0 {
+			res.StatusCode = received.Response.Status
+		}
+
+		// Headers repeated are joined by newlines.
+		for name, value := range received.Response.Headers {
+			for _, line := range strings.Split(value, "\n") {
+				res.Header.Add(name, line)
+			}
+		}
+		return
+	}
+}
+
+// render returns a copy of giving page of the crawler's target merged with the
+// document rendered by the crawler's Renderer, so links rendered client-side are
+// farmed along with those served. The rendered title, language, structure and
+// main element take precedence over those served. It returns false with the
+// page as served if rendering failed.
+func (pc PageCrawler) render(ctx context.Context, page pageDocument) (pageDocument, bool) {
+	content, err := pc.renderTarget(ctx)
+	if err != nil {
+		page.Warnings = append(append([]string(nil), page.Warnings...), err.Error())
+		return page, false
+	}
+
+	rendered := farmDocument(content, pc.Target)
+
+	page.Links = mergeLinks(page.Links, rendered.Links)
+	page.JSONLinks = mergeLinks(page.JSONLinks, rendered.JSONLinks)
+
+	if rendered.Title != "" {
+		page.Title = rendered.Title
+	}
+
+	if rendered.Language != "" {
+		page.Language = rendered.Language
+	}
+
+	if rendered.Direction != "" {
+		page.Direction = rendered.Direction
+	}
+
+	// Canonicals injected client-side apply only to pages serving none.
+	if page.Canonical == nil {
+		page.Canonical = rendered.Canonical
+	}
+
+	// Likewise alternates injected client-side apply only to pages declaring
+	// none.
+	if len(page.Alternates) == 0 {
+		page.Alternates = rendered.Alternates
+	}
+
+	if page.Social.empty() {
+		page.Social = rendered.Social
+	}
+
+	// Structured data is often injected client-side, as search engines read
+	// it from the rendered page.
+	if page.Structured.empty() {
+		page.Structured = rendered.Structured
+	}
+
+	if len(rendered.Shingles) != 0 {
+		page.Shingles = rendered.Shingles
+	}
+
+	page.HasMain = rendered.HasMain
+	page.MainText = rendered.MainText
+
+	page.Robots = append(append([]string(nil), page.Robots...), rendered.Robots...)
+
+	contacts := append([]string(nil), page.Contacts...)
+	for _, contact := range rendered.Contacts {
+		if !hasString(contacts, contact) {
+			contacts = append(contacts, contact)
+		}
+	}
+	page.Contacts = contacts
+
+	return page, true
+}
+
+// renderTarget renders the crawler's target with it's Renderer, respecting the
+// restrictions of the target's section and host rate like explore, and sending
+// the headers, cookies and User-Agent of the crawler's requests. Renders count
+// against the crawl's request budget, while a host answering the document with
+// a pause, such as a 429 with Retry-After, pauses the host's later requests.
+func (pc PageCrawler) renderTarget(ctx context.Context) (io.Reader, error) {
+	release, err := pc.sections.Acquire(ctx, pc.Target.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	if err := pc.scheduler.Wait(ctx, pc.Target); err != nil {
+		return nil, err
+	}
+
+	req, err := pc.newRequest(ctx, http.MethodGet, pc.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := pc.Renderer.Render(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer closeBody(res.Body)
+
+	if res.Request == nil {
+		res.Request = req
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		pc.scheduler.Observe(res)
+		return nil, fmt.Errorf("%+s: %+q: status %d", ErrRenderFailed, pc.Target.String(), res.StatusCode)
+	}
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+// routeError returns why giving rendered page is a client-side dead route, a
+// route served live which renders a not found view or an empty main element,
+// else an empty string. Pages without a main element are only judged by their
+// title.
+func routeError(page pageDocument) string {
+	if notFoundView.MatchString(page.Title) {
+		return "renders not found view"
+	}
+
+	if !page.HasMain {
+		return ""
+	}
+
+	if page.MainText == "" {
+		return "renders empty main element"
+	}
+
+	if len(page.MainText) < shortMainText && notFoundView.MatchString(page.MainText) {
+		return "renders not found view"
+	}
+	return ""
+}
+
+// hasString returns true if giving values hold value.
+func hasString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,326 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestParseRenderMode(t *testing.T) {
+	for mode, expected := range map[string]RenderMode{"": RenderNone, "none": RenderNone, "js": RenderJS} {
+		if parsed, err := ParseRenderMode(mode); err != nil || parsed != expected {
+			tests.Info("Mode: %q, Received: %q", mode, parsed)
+			tests.Failed("Should have parsed render mode")
+		}
+	}
+	tests.Passed("Should have parsed render mode")
+
+	if _, err := ParseRenderMode("webkit"); err == nil {
+		tests.Failed("Should have failed to parse unknown render mode")
+	}
+	tests.Passed("Should have failed to parse unknown render mode")
+}
+
+// fakeBrowserEnv sets TestFakeBrowser to act as the browser rendering pages.
+const fakeBrowserEnv = "SITECRAWLER_FAKE_BROWSER"
+
+// TestFakeBrowser acts as a headless browser driven over the DevTools pipe when
+// run by the fake browser of TestChromeRenderer, rendering a document listing
+// it's pid, args, open browser contexts and the user agent, cookies, headers
+// and closed contexts it was sent. Tabs are attached as flattened sessions,
+// whose commands are refused once their browser context is disposed. Pages
+// under /slow hang, while those under /missing fail to navigate.
+func TestFakeBrowser(t *testing.T) {
+	if os.Getenv(fakeBrowserEnv) == "" {
+		return
+	}
+
+	commands := bufio.NewReader(os.NewFile(3, "commands"))
+	answers := os.NewFile(4, "answers")
+
+	send := func(message interface{}) {
+		data, _ := json.Marshal(message)
+		answers.Write(append(data, 0))
+	}
+
+	var seen []string
+	var created int
+	contexts := map[string]bool{}
+	targets := map[string]string{}
+	sessions := map[string]string{}
+	for {
+		data, err := commands.ReadBytes(0)
+		if err != nil {
+			os.Exit(0)
+		}
+
+		var command struct {
+			ID        int                    `json:"id"`
+			SessionID string                 `json:"sessionId"`
+			Method    string                 `json:"method"`
+			Params    map[string]interface{} `json:"params"`
+		}
+		json.Unmarshal(data[:len(data)-1], &command)
+
+		if command.SessionID != "" && !contexts[targets[sessions[command.SessionID]]] {
+			send(map[string]interface{}{"id": command.ID, "error": map[string]interface{}{"code": -32001, "message": "Session with given id not found."}})
+			continue
+		}
+
+		result := map[string]interface{}{}
+		switch command.Method {
+		case "Target.createBrowserContext":
+			created++
+			id := fmt.Sprintf("context-%d", created)
+			contexts[id] = true
+			result["browserContextId"] = id
+		case "Target.disposeBrowserContext":
+			id, _ := command.Params["browserContextId"].(string)
+			delete(contexts, id)
+			seen = append(seen, command.Method+" "+id)
+		case "Target.createTarget":
+			id, _ := command.Params["browserContextId"].(string)
+			targets["target-"+id] = id
+			result["targetId"] = "target-" + id
+		case "Target.attachToTarget":
+			id, _ := command.Params["targetId"].(string)
+			sessions["session-"+id] = id
+			result["sessionId"] = "session-" + id
+		case "Network.setUserAgentOverride", "Network.setCookies", "Network.setExtraHTTPHeaders", "Emulation.setVirtualTimePolicy":
+			params, _ := json.Marshal(command.Params)
+			seen = append(seen, command.Method+" "+string(params))
+		case "Page.navigate":
+			target := command.Params["url"].(string)
+			if strings.Contains(target, "/slow") {
+				time.Sleep(5 * time.Second)
+			}
+
+			result["frameId"] = "page"
+			if strings.Contains(target, "/missing") {
+				result["errorText"] = "net::ERR_NAME_NOT_RESOLVED"
+				break
+			}
+
+			send(map[string]interface{}{"sessionId": command.SessionID, "method": "Network.responseReceived", "params": map[string]interface{}{
+				"type": "Script", "frameId": "page", "response": map[string]interface{}{"status": 404},
+			}})
+			send(map[string]interface{}{"sessionId": command.SessionID, "method": "Network.responseReceived", "params": map[string]interface{}{
+				"type": "Document", "frameId": "page", "response": map[string]interface{}{"status": 203, "headers": map[string]string{"Link": "</a>\n</b>", "X-Url": target}},
+			}})
+		case "Runtime.evaluate":
+			document := fmt.Sprintf("pid %d, open %d\n%s\n%s", os.Getpid(), len(contexts), strings.Join(os.Args, " "), strings.Join(seen, "\n"))
+			result["result"] = map[string]interface{}{"type": "string", "value": `<html><body><a href="/rendered">` + html.EscapeString(document) + `</a></body></html>`}
+		}
+
+		send(map[string]interface{}{"id": command.ID, "result": result})
+		if command.Method == "Page.navigate" && result["errorText"] == nil {
+			send(map[string]interface{}{"sessionId": command.SessionID, "method": "Emulation.virtualTimeBudgetExpired"})
+		}
+	}
+}
+
+// renderedPID returns the pid of the fake browser listed in giving rendered
+// document.
+func renderedPID(rendered string) string {
+	return strings.Fields(strings.TrimPrefix(rendered[strings.Index(rendered, "pid "):], "pid "))[0]
+}
+
+func TestChromeRenderer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitecrawler-render")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	// The fake browser runs TestFakeBrowser of the test binary.
+	browser := filepath.Join(dir, "chromium")
+	script := "#!/bin/sh\n" + fakeBrowserEnv + "=1 exec " + os.Args[0] + " -test.run='^TestFakeBrowser$' -- \"$@\"\n"
+	if err := ioutil.WriteFile(browser, []byte(script), 0755); err != nil {
+		tests.FailedWithError(err, "Should have successfully written fake browser")
+	}
+	tests.Passed("Should have successfully written fake browser")
+
+	if _, err := NewChromeRenderer(filepath.Join(dir, "missing"), nil, 0, 0); err == nil {
+		tests.Failed("Should have failed to create renderer of missing browser")
+	}
+	tests.Passed("Should have failed to create renderer of missing browser")
+
+	renderer, err := NewChromeRenderer(browser, []string{"--no-sandbox"}, 400*time.Millisecond, 2)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created renderer")
+	}
+	tests.Passed("Should have successfully created renderer")
+
+	defer renderer.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://mombo.com/app", nil)
+	req.Header.Set("User-Agent", "mombo-bot")
+	req.Header.Set("X-Token", "abc")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+
+	res, err := renderer.Render(req)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully rendered page")
+	}
+	tests.Passed("Should have successfully rendered page")
+
+	output, err := ioutil.ReadAll(res.Body)
+	if err != nil || !strings.Contains(string(output), `<a href="/rendered">`) {
+		tests.Info("Received: %s", output)
+		tests.Failed("Should have returned rendered document")
+	}
+	tests.Passed("Should have returned rendered document")
+
+	rendered := html.UnescapeString(string(output))
+	if !strings.Contains(rendered, "-- --headless --disable-gpu --remote-debugging-pipe --no-sandbox about:blank") {
+		tests.Info("Received: %s", rendered)
+		tests.Failed("Should have run browser headless over devtools pipe with additional args")
+	}
+	tests.Passed("Should have run browser headless over devtools pipe with additional args")
+
+	expected := []string{
+		`Network.setUserAgentOverride {"userAgent":"mombo-bot"}`,
+		`Network.setCookies {"cookies":[{"name":"session","url":"http://mombo.com/app","value":"s1"}]}`,
+		`Network.setExtraHTTPHeaders {"headers":{"X-Token":"abc"}}`,
+		`Emulation.setVirtualTimePolicy {"budget":300,"policy":"pauseIfNetworkFetchesPending","waitForNavigation":true}`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(rendered, line) {
+			tests.Info("Expected: %s", line)
+			tests.Info("Received: %s", rendered)
+			tests.Failed("Should have rendered page with user agent, cookies and headers of request")
+		}
+	}
+	tests.Passed("Should have rendered page with user agent, cookies and headers of request")
+
+	if res.StatusCode != 203 || len(res.Header["Link"]) != 2 || res.Request != req {
+		tests.Info("Status: %d, Headers: %+v", res.StatusCode, res.Header)
+		tests.Failed("Should have returned status and headers of rendered document")
+	}
+	tests.Passed("Should have returned status and headers of rendered document")
+
+	pid := renderedPID(rendered)
+
+	var wg sync.WaitGroup
+	pages := []string{"http://mombo.com/app/1", "http://mombo.com/app/2", "http://mombo.com/app/3"}
+	documents := make([]string, len(pages))
+	statuses := make([]string, len(pages))
+	for index, page := range pages {
+		wg.Add(1)
+		go func(index int, page string) {
+			defer wg.Done()
+
+			req, _ := http.NewRequest(http.MethodGet, page, nil)
+			res, err := renderer.Render(req)
+			if err != nil {
+				statuses[index] = err.Error()
+				return
+			}
+
+			output, _ := ioutil.ReadAll(res.Body)
+			documents[index] = html.UnescapeString(string(output))
+			statuses[index] = res.Header.Get("X-Url")
+		}(index, page)
+	}
+	wg.Wait()
+
+	for index, page := range pages {
+		if statuses[index] != page {
+			tests.Info("Page: %s, Received: %q", page, statuses[index])
+			tests.Failed("Should have rendered pages at once in their own tabs of a browser")
+		}
+
+		if renderedPID(documents[index]) != pid || strings.Contains(documents[index], "open 3") {
+			tests.Info("Page: %s, Received: %s", page, documents[index])
+			tests.Failed("Should have rendered pages at once in their own tabs of a browser")
+		}
+	}
+	tests.Passed("Should have rendered pages at once in their own tabs of a browser")
+
+	last, _ := http.NewRequest(http.MethodGet, "http://mombo.com/app/4", nil)
+	res, err = renderer.Render(last)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully rendered page")
+	}
+
+	output, _ = ioutil.ReadAll(res.Body)
+	rendered = html.UnescapeString(string(output))
+	if !strings.Contains(rendered, "open 1\n") || strings.Count(rendered, "Target.disposeBrowserContext") != 4 {
+		tests.Info("Received: %s", rendered)
+		tests.Failed("Should have disposed browser context of each rendered page")
+	}
+	tests.Passed("Should have disposed browser context of each rendered page")
+
+	missing, _ := http.NewRequest(http.MethodGet, "http://mombo.com/missing", nil)
+	if _, err := renderer.Render(missing); err == nil || !strings.Contains(err.Error(), "ERR_NAME_NOT_RESOLVED") {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed to render page failing to navigate")
+	}
+	tests.Passed("Should have failed to render page failing to navigate")
+
+	slow, _ := http.NewRequest(http.MethodGet, "http://mombo.com/slow", nil)
+	start := time.Now()
+	if _, err := renderer.Render(slow); err == nil || !strings.Contains(err.Error(), "timed out") {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed to render page beyond render timeout")
+	}
+	tests.Passed("Should have failed to render page beyond render timeout")
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		tests.Info("Elapsed: %s", elapsed)
+		tests.Failed("Should have stopped hung browser shortly after render timeout")
+	}
+	tests.Passed("Should have stopped hung browser shortly after render timeout")
+
+	res, err = renderer.Render(last)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully rendered page in restarted browser")
+	}
+
+	output, _ = ioutil.ReadAll(res.Body)
+	if restarted := renderedPID(html.UnescapeString(string(output))); restarted == pid {
+		tests.Info("Received: %s", output)
+		tests.Failed("Should have restarted browser once stopped")
+	}
+	tests.Passed("Should have restarted browser once stopped")
+}
+
+func TestRouteError(t *testing.T) {
+	target, err := url.Parse("http://mombo.com/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	routes := map[string]string{
+		`<html><head><title>Pricing</title></head><body><main><h1>Pricing</h1><p>Plans for teams</p></main></body></html>`:                                                                                                                                             "",
+		`<html><body><div role="main"><article><p>Why a 404 page matters for every site, and how to design one which keeps visitors around long after they've landed on a broken link. ` + strings.Repeat("More content. ", 20) + `</p></article></div></body></html>`: "",
+		`<html><body><div id="app"><p>No main element</p></div></body></html>`:                                     "",
+		`<html><body><main><script>window.__state = {}</script>  </main></body></html>`:                            "renders empty main element",
+		`<html><body><main><h1>Oops!</h1><p>This page doesn't exist.</p><a href="/">Home</a></main></body></html>`: "renders not found view",
+		`<html><head><title>Page Not Found | Mombo</title></head><body><div id="app"></div></body></html>`:         "renders not found view",
+	}
+
+	for markup, expected := range routes {
+		page := farmDocument(strings.NewReader(markup), target)
+		if received := routeError(page); received != expected {
+			tests.Info("Markup: %s", markup)
+			tests.Info("Expected: %q, Received: %q", expected, received)
+			tests.Failed("Should have told client-side dead routes apart from content")
+		}
+	}
+	tests.Passed("Should have told client-side dead routes apart from content")
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RobotsRules embodies the directives of a host's robots.txt which applies
+// to the crawler.
+type RobotsRules struct {
+	CrawlDelay time.Duration
+}
+
+// ParseRobots parses giving robots.txt content, returning the rules of the
+// group matching the provided user-agent token, else those of the `*` group.
+func ParseRobots(content io.Reader, token string) RobotsRules {
+	token = strings.ToLower(token)
+
+	var agentRules, defaultRules RobotsRules
+	var foundAgent bool
+
+	var agents []string
+	var inRules bool
+
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index != -1 {
+			line = line[:index]
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "user-agent":
+			// A user-agent line following rules starts a new group.
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "crawl-delay":
+			inRules = true
+
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+
+			delay := time.Duration(seconds * float64(time.Second))
+			for _, agent := range agents {
+				switch {
+				case agent == "*":
+					defaultRules.CrawlDelay = delay
+				case token != "" && strings.Contains(agent, token):
+					foundAgent = true
+					agentRules.CrawlDelay = delay
+				}
+			}
+		default:
+			inRules = true
+		}
+	}
+
+	if foundAgent {
+		return agentRules
+	}
+	return defaultRules
+}
+
+// agentToken returns the product token of giving User-Agent used for
+// matching robots.txt groups e.g `sitecrawler` for `sitecrawler/0.1.0`.
+func agentToken(userAgent string) string {
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	return strings.SplitN(strings.TrimSpace(userAgent), "/", 2)[0]
+}
+
+// fetchRobots retrieves and parses the robots.txt of the crawler's target host. A
+// missing or failing robots.txt returns empty rules.
+func (pc PageCrawler) fetchRobots(ctx context.Context) RobotsRules {
+	robotsURL := &url.URL{Scheme: pc.Target.Scheme, Host: pc.Target.Host, Path: "/robots.txt"}
+
+	ctx, cancel := pc.requestContext(ctx)
+	defer cancel()
+
+	req, err := pc.newRequest(ctx, http.MethodGet, robotsURL)
+	if err != nil {
+		return RobotsRules{}
+	}
+
+	res, _, err := followRedirects(pc.fetch, req, RedirectAny.Allows, pc.maxRedirects())
+	if err != nil {
+		return RobotsRules{}
+	}
+
+	defer closeBody(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return RobotsRules{}
+	}
+
+	return ParseRobots(io.LimitReader(res.Body, 512*1024), agentToken(pc.UserAgent))
+}
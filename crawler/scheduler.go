@@ -0,0 +1,73 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Scheduler defines the contract for the scheduling decisions of a crawl,
+// being the order the links of a page are enqueued for crawling and how long
+// requests wait before being sent, so applications embedding the crawler can
+// implement their own crawl ordering and pacing strategies. Schedulers are
+// called concurrently.
+type Scheduler interface {
+	// Order returns the links of giving page to crawl in the order they're
+	// enqueued, links left out not being crawled from the page. Links are
+	// given in the order they were found within the page. The page is nil
+	// for links not linked from a page, such as those listed by sitemaps.
+	Order(page *url.URL, links []LinkReport) []LinkReport
+
+	// Wait blocks until a request to giving url may be sent, failing if the
+	// context ends first.
+	Wait(ctx context.Context, target *url.URL) error
+
+	// Observe records the response to a request, returning true if it's host
+	// requested a pause of requests, such as with a 429 or 503 Retry-After,
+	// so the request is retried once after it.
+	Observe(res *http.Response) bool
+}
+
+// PoliteScheduler implements the default Scheduler of a PageCrawler, crawling
+// the links of pages in the order they're found while pacing requests per
+// host through it's HostLimiter. Custom schedulers can embed it to override
+// only the ordering or pacing of a crawl.
+type PoliteScheduler struct {
+	*HostLimiter
+}
+
+// NewPoliteScheduler returns a new PoliteScheduler pacing requests through
+// giving limiter. A nil limiter leaves requests unlimited except for pauses
+// requested by hosts.
+func NewPoliteScheduler(limiter *HostLimiter) *PoliteScheduler {
+	if limiter == nil {
+		limiter = NewHostLimiter(Rate{})
+	}
+	return &PoliteScheduler{HostLimiter: limiter}
+}
+
+// Order implements the Scheduler interface, returning links as found.
+func (p *PoliteScheduler) Order(page *url.URL, links []LinkReport) []LinkReport {
+	return links
+}
+
+// Wait implements the Scheduler interface, waiting on the rate of the url's
+// host.
+func (p *PoliteScheduler) Wait(ctx context.Context, target *url.URL) error {
+	return p.HostLimiter.Wait(ctx, target.Host)
+}
+
+// Observe implements the Scheduler interface, pausing the response's host for
+// the Retry-After of a 429 or 503.
+func (p *PoliteScheduler) Observe(res *http.Response) bool {
+	return p.HostLimiter.Observe(res.Request.URL.Host, res)
+}
+
+// newScheduler returns the Scheduler of the crawl, being the crawler's
+// Scheduler when set, else a PoliteScheduler applying the crawler's rate.
+func (pc PageCrawler) newScheduler() Scheduler {
+	if pc.Scheduler != nil {
+		return pc.Scheduler
+	}
+	return NewPoliteScheduler(pc.newHostLimiter())
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ErrInvalidScope is returned when parsing an unknown crawl scope.
+var ErrInvalidScope = errors.New("invalid scope, expected host, subdomains, domain or custom")
+
+// Scope defines which hosts links must lie on to be crawled as internal links
+// of a crawl, links to other hosts being external.
+type Scope string
+
+// scopes ...
+const (
+	// ScopeHost only holds the target's host, so web.monzo.com lies outside of
+	// a crawl of monzo.com.
+	ScopeHost Scope = "host"
+
+	// ScopeSubdomains holds the target's host and all it's subdomains.
+	ScopeSubdomains Scope = "subdomains"
+
+	// ScopeDomain holds all hosts of the target's registered domain, it's
+	// eTLD+1 per the public suffix list, so a crawl of blog.monzo.com holds
+	// monzo.com and web.monzo.com.
+	ScopeDomain Scope = "domain"
+
+	// ScopeCustom holds the target's host and hosts matching the patterns of
+	// the crawl's scope hosts, such as docs.monzo.com or *.monzo.com.
+	ScopeCustom Scope = "custom"
+)
+
+// ParseScope parses giving crawl scope, an empty scope defaults to ScopeHost.
+func ParseScope(scope string) (Scope, error) {
+	switch Scope(strings.ToLower(scope)) {
+	case "":
+		return ScopeHost, nil
+	case ScopeHost, ScopeSubdomains, ScopeDomain, ScopeCustom:
+		return Scope(strings.ToLower(scope)), nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrInvalidScope, scope)
+}
+
+// Contains returns true if giving link lies within the scope of a crawl of
+// target. The host patterns of ScopeCustom are provided by hosts.
+func (s Scope) Contains(target *url.URL, link *url.URL, hosts []string) bool {
+	if link.Host == target.Host {
+		return true
+	}
+
+	host := strings.ToLower(link.Hostname())
+	root := strings.ToLower(target.Hostname())
+
+	switch s {
+	case ScopeSubdomains:
+		return strings.HasSuffix(host, "."+root)
+	case ScopeDomain:
+		domain := registeredDomain(root)
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	case ScopeCustom:
+		for _, pattern := range hosts {
+			if matchHost(strings.ToLower(pattern), host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// registeredDomain returns the eTLD+1 of giving host, else the host itself for
+// ip addresses and hosts which are public suffixes.
+func registeredDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// matchesHost returns true if giving host, with or without a port, matches any
+// of the lowercased patterns.
+func matchesHost(patterns []string, host string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		if matchHost(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost returns true if giving host matches pattern, either the host
+// itself or "*." followed by a domain whose subdomains it matches.
+func matchHost(pattern string, host string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:])
+	}
+	return host == pattern
+}
@@ -0,0 +1,69 @@
+package crawler
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestParseScope(t *testing.T) {
+	if scope, err := ParseScope(""); err != nil || scope != ScopeHost {
+		tests.Info("Received Scope: %q", scope)
+		tests.Failed("Should have defaulted empty scope to host")
+	}
+	tests.Passed("Should have defaulted empty scope to host")
+
+	if scope, err := ParseScope("Subdomains"); err != nil || scope != ScopeSubdomains {
+		tests.Info("Received Scope: %q", scope)
+		tests.Failed("Should have successfully parsed scope")
+	}
+	tests.Passed("Should have successfully parsed scope")
+
+	if _, err := ParseScope("world"); err == nil {
+		tests.Failed("Should have failed to parse unknown scope")
+	}
+	tests.Passed("Should have failed to parse unknown scope")
+}
+
+func TestScopeContains(t *testing.T) {
+	target, _ := url.Parse("https://monzo.com/")
+	blog, _ := url.Parse("https://blog.monzo.com/")
+
+	cases := []struct {
+		scope  Scope
+		target *url.URL
+		link   string
+		hosts  []string
+		within bool
+	}{
+		{ScopeHost, target, "https://monzo.com/about", nil, true},
+		{ScopeHost, target, "https://web.monzo.com/", nil, false},
+		{ScopeSubdomains, target, "https://web.monzo.com/", nil, true},
+		{ScopeSubdomains, target, "https://api.web.Monzo.com/", nil, true},
+		{ScopeSubdomains, target, "https://notmonzo.com/", nil, false},
+		{ScopeSubdomains, blog, "https://monzo.com/", nil, false},
+		{ScopeDomain, blog, "https://monzo.com/", nil, true},
+		{ScopeDomain, blog, "https://web.monzo.com/", nil, true},
+		{ScopeDomain, target, "https://monzo.co.uk/", nil, false},
+		{ScopeCustom, target, "https://docs.cdn.net/", []string{"docs.cdn.net"}, true},
+		{ScopeCustom, target, "https://web.monzo.com/", []string{"*.monzo.com"}, true},
+		{ScopeCustom, target, "https://evil.com/", []string{"*.monzo.com"}, false},
+	}
+
+	for _, c := range cases {
+		link, _ := url.Parse(c.link)
+		if c.scope.Contains(c.target, link, c.hosts) != c.within {
+			tests.Info("Scope: %q, Target: %q, Link: %q", c.scope, c.target, c.link)
+			tests.Failed("Should have matched link against scope")
+		}
+	}
+	tests.Passed("Should have matched link against scope")
+
+	suffix, _ := url.Parse("https://shop.co.uk/")
+	other, _ := url.Parse("https://other.co.uk/")
+	if ScopeDomain.Contains(suffix, other, nil) {
+		tests.Failed("Should have kept domains sharing a public suffix apart")
+	}
+	tests.Passed("Should have kept domains sharing a public suffix apart")
+}
@@ -0,0 +1,142 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errors ...
+var (
+	ErrInvalidSectionRule = errors.New("invalid section rule, expected prefix=concurrency[:delay]")
+)
+
+// SectionRule defines politeness overrides for all paths lying under a giving
+// path prefix, such as expensive search or api sections of a site.
+type SectionRule struct {
+	// Prefix is the path prefix which the rule applies to.
+	Prefix string
+
+	// MaxConcurrency sets the maximum total requests allowed in-flight for
+	// the section. A value of zero or less leaves concurrency unrestricted.
+	MaxConcurrency int
+
+	// Delay sets an extra wait applied before every request to the section.
+	Delay time.Duration
+}
+
+// ParseSectionRule parses a giving rule in the format of `prefix=concurrency[:delay]`,
+// e.g `/search=2:500ms`.
+func ParseSectionRule(rule string) (SectionRule, error) {
+	var section SectionRule
+
+	parts := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return section, ErrInvalidSectionRule
+	}
+
+	section.Prefix = parts[0]
+
+	settings := strings.SplitN(parts[1], ":", 2)
+	concurrency, err := strconv.Atoi(settings[0])
+	if err != nil {
+		return section, fmt.Errorf("%+s: %+s", ErrInvalidSectionRule, err)
+	}
+
+	section.MaxConcurrency = concurrency
+
+	if len(settings) == 2 {
+		delay, err := time.ParseDuration(settings[1])
+		if err != nil {
+			return section, fmt.Errorf("%+s: %+s", ErrInvalidSectionRule, err)
+		}
+
+		section.Delay = delay
+	}
+
+	return section, nil
+}
+
+// ParseSectionRules parses a comma separated list of section rules.
+func ParseSectionRules(rules string) ([]SectionRule, error) {
+	var sections []SectionRule
+	for _, rule := range strings.Split(rules, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+
+		section, err := ParseSectionRule(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// sectionLimiter implements the concurrency and delay restrictions of
+// provided section rules, where the longest matching prefix wins.
+type sectionLimiter struct {
+	rules []SectionRule
+	slots []chan struct{}
+}
+
+func newSectionLimiter(rules []SectionRule) *sectionLimiter {
+	var limiter sectionLimiter
+	limiter.rules = append(limiter.rules, rules...)
+
+	sort.SliceStable(limiter.rules, func(i, j int) bool {
+		return len(limiter.rules[i].Prefix) > len(limiter.rules[j].Prefix)
+	})
+
+	limiter.slots = make([]chan struct{}, len(limiter.rules))
+	for index, rule := range limiter.rules {
+		if rule.MaxConcurrency > 0 {
+			limiter.slots[index] = make(chan struct{}, rule.MaxConcurrency)
+		}
+	}
+
+	return &limiter
+}
+
+// Acquire blocks till the section of giving path has capacity for another
+// request, returning a function to release the secured slot.
+func (s *sectionLimiter) Acquire(ctx context.Context, path string) (func(), error) {
+	release := func() {}
+	if s == nil {
+		return release, nil
+	}
+
+	for index, rule := range s.rules {
+		if !strings.HasPrefix(path, rule.Prefix) {
+			continue
+		}
+
+		if slot := s.slots[index]; slot != nil {
+			select {
+			case <-ctx.Done():
+				return release, ctx.Err()
+			case slot <- struct{}{}:
+				release = func() { <-slot }
+			}
+		}
+
+		if rule.Delay > 0 {
+			select {
+			case <-ctx.Done():
+				release()
+				return func() {}, ctx.Err()
+			case <-time.After(rule.Delay):
+			}
+		}
+
+		break
+	}
+
+	return release, nil
+}
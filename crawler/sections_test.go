@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestParseSectionRules(t *testing.T) {
+	sections, err := ParseSectionRules("/search=2:500ms, /api=1")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed section rules")
+	}
+	tests.Passed("Should have successfully parsed section rules")
+
+	if len(sections) != 2 {
+		tests.Info("Expected Rules: %d", 2)
+		tests.Info("Received Rules: %d", len(sections))
+		tests.Failed("Should have parsed 2 section rules")
+	}
+	tests.Passed("Should have parsed 2 section rules")
+
+	if sections[0].Prefix != "/search" || sections[0].MaxConcurrency != 2 || sections[0].Delay != 500*time.Millisecond {
+		tests.Info("Received Rule: %#v", sections[0])
+		tests.Failed("Should have parsed /search rule with concurrency and delay")
+	}
+	tests.Passed("Should have parsed /search rule with concurrency and delay")
+
+	if _, err := ParseSectionRules("/search"); err == nil {
+		tests.Failed("Should have failed to parse rule without concurrency")
+	}
+	tests.Passed("Should have failed to parse rule without concurrency")
+}
+
+func TestSectionLimiter(t *testing.T) {
+	limiter := newSectionLimiter([]SectionRule{
+		{Prefix: "/api", MaxConcurrency: 1},
+	})
+
+	release, err := limiter.Acquire(context.Background(), "/api/users")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully secured slot for section")
+	}
+	tests.Passed("Should have successfully secured slot for section")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := limiter.Acquire(ctx, "/api/teams"); err == nil {
+		tests.Failed("Should have failed to secure slot from full section")
+	}
+	tests.Passed("Should have failed to secure slot from full section")
+
+	if _, err := limiter.Acquire(ctx, "/contacts"); err != nil {
+		tests.FailedWithError(err, "Should have secured slot for path outside of section")
+	}
+	tests.Passed("Should have secured slot for path outside of section")
+
+	release()
+
+	if _, err := limiter.Acquire(context.Background(), "/api/teams"); err != nil {
+		tests.FailedWithError(err, "Should have secured slot after release")
+	}
+	tests.Passed("Should have secured slot after release")
+}
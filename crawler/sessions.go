@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minSessionValueLength sets the minimum length of a query value considered
+// a random session-style value.
+const minSessionValueLength = 16
+
+// sessionValueThreshold sets the distinct random values a parameter must take
+// on the same path before it is considered a session parameter.
+const sessionValueThreshold = 5
+
+// sessionParams lists the query and path parameter names known to carry
+// session ids.
+var sessionParams = map[string]bool{
+	"phpsessid":  true,
+	"jsessionid": true,
+	"sid":        true,
+	"sessionid":  true,
+	"session_id": true,
+	"cfid":       true,
+	"cftoken":    true,
+}
+
+// SessionStripper implements a concurrent-safe detector of session-style query
+// parameters, which are stripped from links so the same page isn't checked
+// once per session. Besides known session parameters, a parameter which takes
+// many distinct long random values on the same path is detected as one.
+type SessionStripper struct {
+	ml       sync.Mutex
+	detected map[string]bool
+	values   map[string]map[string]struct{}
+}
+
+// NewSessionStripper returns a new instance of a SessionStripper.
+func NewSessionStripper() *SessionStripper {
+	return &SessionStripper{
+		detected: map[string]bool{},
+		values:   map[string]map[string]struct{}{},
+	}
+}
+
+// Observe records the random query values of giving link, detecting
+// parameters taking too many distinct values on the link's path.
+func (s *SessionStripper) Observe(link *url.URL) {
+	if s == nil || link.RawQuery == "" {
+		return
+	}
+
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	for name, values := range link.Query() {
+		lowered := strings.ToLower(name)
+		if s.detected[lowered] {
+			continue
+		}
+
+		for _, value := range values {
+			if !isRandomValue(value) {
+				continue
+			}
+
+			key := link.Host + link.Path + "?" + lowered
+			seen, ok := s.values[key]
+			if !ok {
+				seen = map[string]struct{}{}
+				s.values[key] = seen
+			}
+
+			seen[value] = struct{}{}
+			if len(seen) >= sessionValueThreshold {
+				s.detected[lowered] = true
+				delete(s.values, key)
+				break
+			}
+		}
+	}
+}
+
+// Strip returns a copy of giving link without it's session parameters and the
+// names of the parameters stripped. The link is returned as is if it has none.
+func (s *SessionStripper) Strip(link *url.URL) (*url.URL, []string) {
+	if s == nil {
+		return link, nil
+	}
+
+	var stripped []string
+
+	path := link.Path
+	if index := strings.Index(strings.ToLower(path), ";jsessionid="); index != -1 {
+		path = path[:index]
+		stripped = append(stripped, "jsessionid")
+	}
+
+	var query url.Values
+	if link.RawQuery != "" {
+		query = link.Query()
+
+		s.ml.Lock()
+		for name := range query {
+			lowered := strings.ToLower(name)
+			if sessionParams[lowered] || s.detected[lowered] || strings.HasPrefix(lowered, "aspsessionid") {
+				stripped = append(stripped, name)
+				query.Del(name)
+			}
+		}
+		s.ml.Unlock()
+	}
+
+	if len(stripped) == 0 {
+		return link, nil
+	}
+
+	sort.Strings(stripped)
+
+	clean := *link
+	clean.Path = path
+	clean.RawPath = ""
+	clean.RawQuery = query.Encode()
+	return &clean, stripped
+}
+
+// isRandomValue returns true if giving value looks like a random id, being
+// long and mixing letters with digits.
+func isRandomValue(value string) bool {
+	if len(value) < minSessionValueLength {
+		return false
+	}
+
+	var letters, digits bool
+	for _, char := range value {
+		switch {
+		case char >= '0' && char <= '9':
+			digits = true
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z':
+			letters = true
+		case char == '-' || char == '_':
+		default:
+			return false
+		}
+	}
+
+	return letters && digits
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestSessionStripperKnownParams(t *testing.T) {
+	stripper := NewSessionStripper()
+
+	link, err := url.Parse("http://example.com/cart;jsessionid=A1B2C3?PHPSESSID=abc&item=4")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	clean, stripped := stripper.Strip(link)
+	if clean.String() != "http://example.com/cart?item=4" {
+		tests.Info("Received URL: %q", clean.String())
+		tests.Failed("Should have stripped known session parameters")
+	}
+	tests.Passed("Should have stripped known session parameters")
+
+	if len(stripped) != 2 || stripped[0] != "PHPSESSID" || stripped[1] != "jsessionid" {
+		tests.Info("Received Stripped: %#v", stripped)
+		tests.Failed("Should have recorded stripped parameters")
+	}
+	tests.Passed("Should have recorded stripped parameters")
+}
+
+func TestSessionStripperDetection(t *testing.T) {
+	stripper := NewSessionStripper()
+
+	for index := 0; index < sessionValueThreshold; index++ {
+		link, _ := url.Parse(fmt.Sprintf("http://example.com/page?token=f81d4fae7dec11d0a765%04d&page=%d", index, index))
+		stripper.Observe(link)
+	}
+
+	link, _ := url.Parse("http://example.com/other?token=a81d4fae7dec11d0a7659999&page=2")
+	clean, stripped := stripper.Strip(link)
+	if clean.String() != "http://example.com/other?page=2" || len(stripped) != 1 || stripped[0] != "token" {
+		tests.Info("Received URL: %q", clean.String())
+		tests.Info("Received Stripped: %#v", stripped)
+		tests.Failed("Should have detected and stripped random parameter")
+	}
+	tests.Passed("Should have detected and stripped random parameter")
+
+	link, _ = url.Parse("http://example.com/page?page=2")
+	if clean, stripped := stripper.Strip(link); clean != link || stripped != nil {
+		tests.Failed("Should have left link without session parameters as is")
+	}
+	tests.Passed("Should have left link without session parameters as is")
+}
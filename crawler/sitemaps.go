@@ -0,0 +1,277 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// limits of the sitemaps fetched to seed a crawl.
+const (
+	maxSitemapFetches = 50
+	maxSitemapSize    = 50 * 1024 * 1024
+)
+
+// sitemapDocument embodies the entries of a sitemap or sitemap index.
+type sitemapDocument struct {
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemapEntry embodies a url or nested sitemap listed by a sitemapDocument.
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+// SitemapSeeds implements a concurrent-safe record of the urls listed by the
+// sitemaps of a crawl's target, which are crawled along with the pages found
+// through links, so orphan pages no page links to are reached. Once the crawl
+// completes, it compares the urls listed against the pages discoverable
+// through links.
+type SitemapSeeds struct {
+	ml       sync.Mutex
+	sitemaps []string
+	listed   map[string]string
+	linked   map[string]bool
+	crawled  map[string]string
+}
+
+// NewSitemapSeeds returns a new instance of a SitemapSeeds.
+func NewSitemapSeeds() *SitemapSeeds {
+	return &SitemapSeeds{
+		listed:  map[string]string{},
+		linked:  map[string]bool{},
+		crawled: map[string]string{},
+	}
+}
+
+// Sitemaps returns the urls of all sitemaps fetched, in the order fetched.
+func (s *SitemapSeeds) Sitemaps() []string {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+	return append([]string(nil), s.sitemaps...)
+}
+
+// Listed returns all urls listed by the sitemaps within the crawl's scope,
+// ordered by url.
+func (s *SitemapSeeds) Listed() []string {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	listed := make([]string, 0, len(s.listed))
+	for _, link := range s.listed {
+		listed = append(listed, link)
+	}
+
+	sort.Strings(listed)
+	return listed
+}
+
+// Orphans returns the urls listed by the sitemaps which no crawled page links
+// to, ordered by url. These are only reachable through the sitemaps, or are
+// stale entries of pages which no longer exist.
+func (s *SitemapSeeds) Orphans() []string {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	var orphans []string
+	for key, link := range s.listed {
+		if !s.linked[key] {
+			orphans = append(orphans, link)
+		}
+	}
+
+	sort.Strings(orphans)
+	return orphans
+}
+
+// Unlisted returns the urls of live pages crawled which the sitemaps don't
+// list, ordered by url.
+func (s *SitemapSeeds) Unlisted() []string {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	var unlisted []string
+	for key, link := range s.crawled {
+		if _, ok := s.listed[key]; !ok {
+			unlisted = append(unlisted, link)
+		}
+	}
+
+	sort.Strings(unlisted)
+	return unlisted
+}
+
+// list adds the urls of giving sitemap into the listed urls.
+func (s *SitemapSeeds) list(sitemap string, links []*url.URL) {
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	s.sitemaps = append(s.sitemaps, sitemap)
+	for _, link := range links {
+		s.listed[seenKey(link)] = link.String()
+	}
+}
+
+// record adds the page of giving report with it's outgoing links into the
+// record, the seed counting as linked as crawls start from it.
+func (s *SitemapSeeds) record(report LinkReport, seed bool) {
+	if s == nil || report.Path == nil {
+		return
+	}
+
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	key := seenKey(report.Path)
+	if seed {
+		s.linked[key] = true
+	}
+
+	if report.Status.IsLive {
+		s.crawled[key] = report.Path.String()
+	}
+
+	for _, kid := range report.PointsTo {
+		s.linked[seenKey(kid.Path)] = true
+		if kid.RedirectedTo != nil {
+			s.linked[seenKey(kid.RedirectedTo)] = true
+		}
+	}
+}
+
+// seedSitemaps fetches the target's /sitemap.xml and the sitemaps nested
+// within it, crawling the urls they list within the crawl's scope.
+func (pc PageCrawler) seedSitemaps(ctx context.Context, pool WorkerPool, reports chan<- LinkReport) {
+	links := pc.fetchSitemaps(ctx)
+	if len(links) == 0 {
+		return
+	}
+
+	set := make(map[*url.URL]linkContext, len(links))
+	for index, link := range links {
+		set[link] = linkContext{Index: index}
+	}
+
+	kids, err := pc.checkLinks(ctx, pool, pc.Target, set)
+	if err != nil {
+		return
+	}
+
+	// Listed urls which can't be crawled, such as broken ones, are reported as
+	// they are, as they may not be linked from any page.
+	listed := pc
+	listed.child = true
+	for _, kid := range kids {
+		if kid.Status.IsCrawlable || kid.RedirectedTo != nil || pc.seen.Has(seenKey(kid.Path)) {
+			continue
+		}
+
+		pc.seen.Add(seenKey(kid.Path))
+		listed.deliver(reports, kid)
+	}
+
+	pc.crawlKids(ctx, pool, reports, nil, kids)
+}
+
+// fetchSitemaps returns the urls within the crawl's scope listed by the
+// target's /sitemap.xml and the sitemaps of the scope nested within it, at
+// most maxSitemapFetches sitemaps being fetched. Failing sitemaps are skipped.
+func (pc PageCrawler) fetchSitemaps(ctx context.Context) []*url.URL {
+	root := &url.URL{Scheme: pc.Target.Scheme, Host: pc.Target.Host, Path: "/sitemap.xml"}
+
+	queue := []*url.URL{root}
+	fetched := map[string]bool{root.String(): true}
+	listed := map[string]bool{}
+
+	var links []*url.URL
+	for fetches := 0; len(queue) != 0 && fetches < maxSitemapFetches; fetches++ {
+		sitemap := queue[0]
+		queue = queue[1:]
+
+		doc, err := pc.fetchSitemap(ctx, sitemap)
+		if err != nil {
+			continue
+		}
+
+		var found []*url.URL
+		for _, entry := range doc.URLs {
+			link, err := url.Parse(strings.TrimSpace(entry.Loc))
+			if err != nil || !pc.inScope(pc.Target, link) {
+				continue
+			}
+
+			link = Normalize(link)
+			found = append(found, link)
+
+			if !listed[link.String()] {
+				listed[link.String()] = true
+				links = append(links, link)
+			}
+		}
+
+		pc.Sitemap.list(sitemap.String(), found)
+
+		for _, entry := range doc.Sitemaps {
+			nested, err := url.Parse(strings.TrimSpace(entry.Loc))
+			if err != nil || !pc.inScope(pc.Target, nested) || fetched[nested.String()] {
+				continue
+			}
+
+			fetched[nested.String()] = true
+			queue = append(queue, nested)
+		}
+	}
+
+	return links
+}
+
+// fetchSitemap retrieves and parses the sitemap or sitemap index at giving
+// url, decompressing gzipped sitemaps.
+func (pc PageCrawler) fetchSitemap(ctx context.Context, sitemap *url.URL) (sitemapDocument, error) {
+	var doc sitemapDocument
+
+	ctx, cancel := pc.requestContext(ctx)
+	defer cancel()
+
+	req, err := pc.newRequest(ctx, http.MethodGet, sitemap)
+	if err != nil {
+		return doc, err
+	}
+
+	res, _, err := followRedirects(pc.fetch, req, RedirectAny.Allows, pc.maxRedirects())
+	if err != nil {
+		return doc, err
+	}
+
+	defer closeBody(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return doc, fmt.Errorf("%+s: %+q", ErrPageFailed, sitemap.String())
+	}
+
+	var body io.Reader = bufio.NewReader(io.LimitReader(res.Body, maxSitemapSize))
+
+	// Sitemaps stored gzipped are served as is, without a Content-Encoding.
+	if magic, err := body.(*bufio.Reader).Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		decoder, err := gzip.NewReader(body)
+		if err != nil {
+			return doc, err
+		}
+		defer decoder.Close()
+
+		body = io.LimitReader(decoder, maxSitemapSize)
+	}
+
+	err = xml.NewDecoder(body).Decode(&doc)
+	return doc, err
+}
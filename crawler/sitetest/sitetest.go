@@ -0,0 +1,278 @@
+// Package sitetest generates deterministic synthetic sites served over
+// httptest, for testing and benchmarking crawlers against sites of known shape.
+package sitetest
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaults of a synthetic site.
+const (
+	defaultPages        = 10
+	defaultBranching    = 3
+	defaultRedirectHops = 1
+	defaultErrorStatus  = http.StatusInternalServerError
+)
+
+// Options embodies the shape of a synthetic site. Pages form a tree rooted at
+// "/", each page linking to Branching child pages and back to it's parent, so
+// all pages are reachable from the root. Errors adds links to missing pages
+// responding with ErrorStatus, while Redirects pages are linked to through a
+// chain of RedirectHops redirects. Where broken links and redirected pages lie
+// is picked at random from Seed, so sites of equal options are the same.
+type Options struct {
+	Pages        int
+	Branching    int
+	Errors       int
+	ErrorStatus  int
+	Redirects    int
+	RedirectHops int
+	Latency      time.Duration
+	Seed         int64
+}
+
+// Site implements a http.Handler serving a synthetic site of giving options.
+// Pages are rendered on request, so sites of many pages are cheap to serve.
+type Site struct {
+	options    Options
+	broken     map[int][]int
+	redirected map[int]bool
+}
+
+// NewSite returns a new instance of a Site of giving options, setting
+// defaults of unset options.
+func NewSite(options Options) *Site {
+	if options.Pages <= 0 {
+		options.Pages = defaultPages
+	}
+
+	if options.Branching <= 0 {
+		options.Branching = defaultBranching
+	}
+
+	if options.ErrorStatus == 0 {
+		options.ErrorStatus = defaultErrorStatus
+	}
+
+	if options.RedirectHops <= 0 {
+		options.RedirectHops = defaultRedirectHops
+	}
+
+	// The root is never redirected, as it's the target of a crawl.
+	if options.Redirects > options.Pages-1 {
+		options.Redirects = options.Pages - 1
+	}
+
+	site := &Site{
+		options:    options,
+		broken:     map[int][]int{},
+		redirected: map[int]bool{},
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	for index := 0; index < options.Errors; index++ {
+		page := random.Intn(options.Pages)
+		site.broken[page] = append(site.broken[page], index)
+	}
+
+	for _, page := range random.Perm(options.Pages - 1)[:options.Redirects] {
+		site.redirected[page+1] = true
+	}
+
+	return site
+}
+
+// Options returns the options of the site, with defaults set.
+func (s *Site) Options() Options {
+	return s.options
+}
+
+// Pages returns the paths of all pages of the site, starting with the root.
+func (s *Site) Pages() []string {
+	pages := make([]string, s.options.Pages)
+	for page := range pages {
+		pages[page] = pagePath(page)
+	}
+	return pages
+}
+
+// Broken returns the paths of all broken links of the site, ordered by path.
+func (s *Site) Broken() []string {
+	var broken []string
+	for _, indexes := range s.broken {
+		for _, index := range indexes {
+			broken = append(broken, brokenPath(index))
+		}
+	}
+
+	sort.Strings(broken)
+	return broken
+}
+
+// Redirected returns the paths of all pages of the site linked to through
+// redirects, ordered by path.
+func (s *Site) Redirected() []string {
+	var redirected []string
+	for page := range s.redirected {
+		redirected = append(redirected, pagePath(page))
+	}
+
+	sort.Strings(redirected)
+	return redirected
+}
+
+// Depth returns the fewest links followed from the root to reach the deepest
+// page of the site.
+func (s *Site) Depth() int {
+	var depth int
+	for page := s.options.Pages - 1; page > 0; page = s.parent(page) {
+		depth++
+	}
+	return depth
+}
+
+// ServeHTTP implements the http.Handler interface, serving pages, redirects and
+// broken links of the site.
+func (s *Site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.options.Latency > 0 {
+		select {
+		case <-time.After(s.options.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/":
+		s.servePage(w, r, 0)
+		return
+	case len(parts) == 2 && parts[0] == "page":
+		if page, ok := s.index(parts[1]); ok && page != 0 {
+			s.servePage(w, r, page)
+			return
+		}
+	case len(parts) == 3 && parts[0] == "redirect":
+		page, ok := s.index(parts[1])
+		hop, err := strconv.Atoi(parts[2])
+		if ok && err == nil && s.redirected[page] && hop > 0 && hop <= s.options.RedirectHops {
+			next := pagePath(page)
+			if hop < s.options.RedirectHops {
+				next = redirectPath(page, hop+1)
+			}
+			http.Redirect(w, r, next, http.StatusMovedPermanently)
+			return
+		}
+	case len(parts) == 2 && parts[0] == "broken":
+		http.Error(w, http.StatusText(s.options.ErrorStatus), s.options.ErrorStatus)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// servePage renders the page of giving index with it's links.
+func (s *Site) servePage(w http.ResponseWriter, r *http.Request, page int) {
+	var body strings.Builder
+	fmt.Fprintf(&body, "<html><head><title>Page %d</title></head><body>\n", page)
+
+	if page != 0 {
+		fmt.Fprintf(&body, "<a href=%q>Parent</a>\n", pagePath(s.parent(page)))
+	}
+
+	first := page*s.options.Branching + 1
+	for kid := first; kid < first+s.options.Branching && kid < s.options.Pages; kid++ {
+		link := pagePath(kid)
+		if s.redirected[kid] {
+			link = redirectPath(kid, 1)
+		}
+		fmt.Fprintf(&body, "<a href=%q>Page %d</a>\n", link, kid)
+	}
+
+	for _, index := range s.broken[page] {
+		fmt.Fprintf(&body, "<a href=%q>Broken %d</a>\n", brokenPath(index), index)
+	}
+
+	body.WriteString("</body></html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		w.Write([]byte(body.String()))
+	}
+}
+
+// index returns the page index of giving path segment, if it's a page of the
+// site.
+func (s *Site) index(segment string) (int, bool) {
+	page, err := strconv.Atoi(segment)
+	if err != nil || page < 0 || page >= s.options.Pages || strconv.Itoa(page) != segment {
+		return 0, false
+	}
+	return page, true
+}
+
+// parent returns the index of the page linking to giving page.
+func (s *Site) parent(page int) int {
+	return (page - 1) / s.options.Branching
+}
+
+// Server embodies a Site served over a httptest.Server, which must be closed
+// once done.
+type Server struct {
+	*httptest.Server
+	Site *Site
+}
+
+// NewServer returns a new instance of a Server serving a Site of giving
+// options.
+func NewServer(options Options) *Server {
+	site := NewSite(options)
+	return &Server{
+		Server: httptest.NewServer(site),
+		Site:   site,
+	}
+}
+
+// Target returns the url of the root of the site, where crawls start from.
+func (s *Server) Target() *url.URL {
+	return s.Link("/")
+}
+
+// Link returns the url of giving path of the site.
+func (s *Server) Link(path string) *url.URL {
+	link, err := url.Parse(s.URL + path)
+	if err != nil {
+		panic(err)
+	}
+	return link
+}
+
+// pagePath returns the path of the page of giving index.
+func pagePath(page int) string {
+	if page == 0 {
+		return "/"
+	}
+	return "/page/" + strconv.Itoa(page)
+}
+
+// redirectPath returns the path of giving hop of the redirect chain leading to
+// the page of giving index.
+func redirectPath(page int, hop int) string {
+	return "/redirect/" + strconv.Itoa(page) + "/" + strconv.Itoa(hop)
+}
+
+// brokenPath returns the path of the broken link of giving index.
+func brokenPath(index int) string {
+	return "/broken/" + strconv.Itoa(index)
+}
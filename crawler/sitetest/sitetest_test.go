@@ -0,0 +1,112 @@
+package sitetest_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
+)
+
+func TestSite(t *testing.T) {
+	options := sitetest.Options{
+		Pages:        13,
+		Branching:    3,
+		Errors:       4,
+		ErrorStatus:  http.StatusNotFound,
+		Redirects:    2,
+		RedirectHops: 3,
+		Seed:         7,
+	}
+
+	site := sitetest.NewSite(options)
+	if pages := site.Pages(); len(pages) != 13 || pages[0] != "/" || pages[12] != "/page/12" {
+		tests.Info("Received Pages: %#v", pages)
+		tests.Failed("Should have listed all pages of site")
+	}
+	tests.Passed("Should have listed all pages of site")
+
+	if depth := site.Depth(); depth != 2 {
+		tests.Info("Received Depth: %d", depth)
+		tests.Failed("Should have returned depth of deepest page")
+	}
+	tests.Passed("Should have returned depth of deepest page")
+
+	if broken := site.Broken(); len(broken) != 4 {
+		tests.Info("Received Broken: %#v", broken)
+		tests.Failed("Should have listed all broken links of site")
+	}
+	tests.Passed("Should have listed all broken links of site")
+
+	if !reflect.DeepEqual(site.Redirected(), sitetest.NewSite(options).Redirected()) {
+		tests.Failed("Should have generated the same site for equal options")
+	}
+	tests.Passed("Should have generated the same site for equal options")
+
+	server := sitetest.NewServer(options)
+	defer server.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(server.Target().String())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully fetched root of site")
+	}
+	content, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	tests.Passed("Should have successfully fetched root of site")
+
+	if !strings.Contains(string(content), `href="/page/1"`) && !strings.Contains(string(content), `href="/redirect/1/1"`) {
+		tests.Info("Received Content: %s", content)
+		tests.Failed("Should have linked root to it's child pages")
+	}
+	tests.Passed("Should have linked root to it's child pages")
+
+	redirected := site.Redirected()[0]
+	hop := strings.Replace(redirected, "/page/", "/redirect/", 1)
+	for index, next := range []string{hop + "/2", hop + "/3", redirected} {
+		res, err := client.Get(server.Link(hop + "/" + strconv.Itoa(index+1)).String())
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully followed redirect chain")
+		}
+		res.Body.Close()
+
+		if location := res.Header.Get("Location"); res.StatusCode != http.StatusMovedPermanently || location != next {
+			tests.Info("Received Status: %d, Location: %q", res.StatusCode, location)
+			tests.Failed("Should have redirected through every hop of chain")
+		}
+	}
+	tests.Passed("Should have redirected through every hop of chain")
+
+	res, err = client.Get(server.Link(site.Broken()[0]).String())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully fetched broken link")
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		tests.Info("Received Status: %d", res.StatusCode)
+		tests.Failed("Should have responded to broken link with error status")
+	}
+	tests.Passed("Should have responded to broken link with error status")
+
+	res, err = client.Get(server.Link("/page/13").String())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully fetched missing page")
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		tests.Info("Received Status: %d", res.StatusCode)
+		tests.Failed("Should have responded to page beyond site with not found")
+	}
+	tests.Passed("Should have responded to page beyond site with not found")
+}
@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sniffLength sets the total bytes of content used to sniff it's type.
+const sniffLength = 512
+
+// sniffableTypes lists declared content types which are too generic to be
+// trusted, such content is sniffed to decide it's crawlability.
+var sniffableTypes = map[string]bool{
+	"":                         true,
+	"text/plain":               true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"application/unknown":      true,
+	"application/x-unknown":    true,
+}
+
+// isHTMLType returns true if giving content type is a html type.
+func isHTMLType(contentType string) bool {
+	return strings.Contains(contentType, "text/html") || strings.Contains(contentType, "text/xhtml")
+}
+
+// needsSniff returns true if giving declared content type is missing or too
+// generic to decide crawlability by.
+func needsSniff(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(contentType)
+	}
+	return sniffableTypes[strings.ToLower(mediaType)]
+}
+
+// sniffType returns the content type sniffed from the first bytes of giving
+// target's content, requesting only those bytes.
+func (pc PageCrawler) sniffType(ctx context.Context, target *url.URL) string {
+	if err := pc.scheduler.Wait(ctx, target); err != nil {
+		return ""
+	}
+
+	ctx, cancel := pc.requestContext(ctx)
+	defer cancel()
+
+	req, err := pc.newRequest(ctx, http.MethodGet, target)
+	if err != nil {
+		return ""
+	}
+
+	req.Header.Set("Range", "bytes=0-511")
+
+	res, _, err := followRedirects(pc.fetch, req, pc.allowsRedirect, pc.maxRedirects())
+	if err != nil {
+		return ""
+	}
+
+	defer closeBody(res.Body)
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		return ""
+	}
+
+	content := make([]byte, sniffLength)
+	n, _ := io.ReadFull(res.Body, content)
+	if n == 0 {
+		return ""
+	}
+
+	return http.DetectContentType(content[:n])
+}
+
+// peekedBody implements a response body read through a buffer it's first bytes
+// were peeked from.
+type peekedBody struct {
+	*bufio.Reader
+	body io.ReadCloser
+}
+
+// Close implements the io.Closer interface.
+func (p peekedBody) Close() error {
+	return p.body.Close()
+}
+
+// unwrap returns the body peeked from.
+func (p peekedBody) unwrap() io.ReadCloser {
+	return p.body
+}
+
+// peekType returns the content type sniffed from the first bytes of giving
+// response's body, replacing the body so the peeked bytes are still read.
+func peekType(res *http.Response) string {
+	reader := bufio.NewReaderSize(res.Body, sniffLength)
+	res.Body = peekedBody{Reader: reader, body: res.Body}
+
+	content, _ := reader.Peek(sniffLength)
+	if len(content) == 0 {
+		return ""
+	}
+
+	return http.DetectContentType(content)
+}
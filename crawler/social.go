@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// SocialTags embodies the Open Graph and Twitter Card tags of a page, which
+// social networks read to preview links to it, with the issues found auditing
+// them, such as missing tags or images which are broken or not images.
+type SocialTags struct {
+	OGTitle      string   `json:"og_title,omitempty"`
+	OGImage      string   `json:"og_image,omitempty"`
+	TwitterCard  string   `json:"twitter_card,omitempty"`
+	TwitterImage string   `json:"twitter_image,omitempty"`
+	Issues       []string `json:"issues,omitempty"`
+}
+
+// empty returns true if none of the tags are set.
+func (s SocialTags) empty() bool {
+	return s.OGTitle == "" && s.OGImage == "" && s.TwitterCard == "" && s.TwitterImage == ""
+}
+
+// farmSocial records the tag of giving <meta> attributes into tags if it's
+// one of the Open Graph or Twitter Card tags audited and not yet set, as the
+// first of repeated tags is the one previewed. Images are resolved against the
+// baseURL.
+func farmSocial(tags *SocialTags, attrs []html.Attribute, baseURL *url.URL) {
+	name, ok := getAttr(attrs, "property")
+	if !ok {
+		if name, ok = getAttr(attrs, "name"); !ok {
+			return
+		}
+	}
+
+	content, ok := getAttr(attrs, "content")
+	if !ok {
+		return
+	}
+
+	value := strings.TrimSpace(content.Val)
+	if value == "" {
+		return
+	}
+
+	var field *string
+	var image bool
+	switch strings.ToLower(strings.TrimSpace(name.Val)) {
+	case "og:title":
+		field = &tags.OGTitle
+	case "og:image", "og:image:url":
+		field, image = &tags.OGImage, true
+	case "twitter:card":
+		field = &tags.TwitterCard
+	case "twitter:image", "twitter:image:src":
+		field, image = &tags.TwitterImage, true
+	default:
+		return
+	}
+
+	if *field != "" {
+		return
+	}
+
+	if image {
+		if resolved, err := baseURL.Parse(value); err == nil {
+			value = resolved.String()
+		}
+	}
+	*field = value
+}
+
+// auditSocial returns the social tags of giving page with the issues found
+// auditing them, checking the status of the images they reference whatever
+// their host, as they're often served by CDNs. The twitter:image is only
+// checked if it differs from the og:image.
+func (pc PageCrawler) auditSocial(ctx context.Context, tags SocialTags) *SocialTags {
+	audited := tags
+	audited.Issues = nil
+
+	if audited.OGTitle == "" {
+		audited.Issues = append(audited.Issues, "missing og:title")
+	}
+
+	if audited.OGImage == "" {
+		audited.Issues = append(audited.Issues, "missing og:image")
+	}
+
+	if audited.TwitterCard == "" {
+		audited.Issues = append(audited.Issues, "missing twitter:card")
+	}
+
+	images := []struct{ tag, link string }{{"og:image", audited.OGImage}, {"twitter:image", audited.TwitterImage}}
+	for index, image := range images {
+		if image.link == "" || (index == 1 && image.link == audited.OGImage) {
+			continue
+		}
+
+		if issue := pc.checkSocialImage(ctx, image.link); issue != "" {
+			audited.Issues = append(audited.Issues, image.tag+" "+issue)
+		}
+	}
+
+	return &audited
+}
+
+// checkSocialImage returns the issue found checking giving image url, being
+// invalid, broken or not an image, else an empty string.
+func (pc PageCrawler) checkSocialImage(ctx context.Context, raw string) string {
+	image, err := url.Parse(raw)
+	if err != nil || (image.Scheme != "http" && image.Scheme != "https") || image.Host == "" {
+		return "is not an absolute http url"
+	}
+
+	image = Normalize(image)
+	report := pc.checks.Check(image, func() LinkReport {
+		return pc.statusReport(ctx, image, false)
+	})
+
+	if !report.Status.IsLive || report.Status.LastStatus >= 400 {
+		return fmt.Sprintf("is broken, status %d", report.Status.LastStatus)
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(report.ContentType); err == nil && !strings.HasPrefix(mediaType, "image/") {
+		return "is not an image, served as " + mediaType
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// statusScheduler implements a concurrent-safe scheduler of status checks
+// shared by all pages of a crawl. Pending checks of the same url are
+// deduplicated into a single request whose result is shared by all waiting
+// pages and reused by later ones, so links repeated across pages like
+// navigation and footers are only checked once.
+type statusScheduler struct {
+	ml      sync.Mutex
+	checks  map[string]*statusCheck
+	skipped int64
+}
+
+// statusCheck embodies a pending or completed status check of a url.
+type statusCheck struct {
+	done   chan struct{}
+	report LinkReport
+}
+
+func newStatusScheduler() *statusScheduler {
+	return &statusScheduler{
+		checks: map[string]*statusCheck{},
+	}
+}
+
+// Check returns the report of giving link, running the provided check only if
+// no other check of the link is pending or completed. The page farmed by the
+// check is only returned to the caller running it, reports kept for others
+// leaving it out, so pages aren't held for the whole crawl.
+func (s *statusScheduler) Check(link *url.URL, check func() LinkReport) LinkReport {
+	if s == nil {
+		return check()
+	}
+
+	key := link.String()
+
+	s.ml.Lock()
+	pending, ok := s.checks[key]
+	if !ok {
+		pending = &statusCheck{done: make(chan struct{})}
+		s.checks[key] = pending
+	}
+	s.ml.Unlock()
+
+	if ok {
+		atomic.AddInt64(&s.skipped, 1)
+		<-pending.done
+
+		report := pending.report
+		report.Path = link
+		return report
+	}
+
+	report := check()
+	pending.report = report
+	pending.report.page = nil
+	close(pending.done)
+
+	report.Path = link
+	return report
+}
+
+// Skipped returns total checks which were answered without a request.
+func (s *statusScheduler) Skipped() int64 {
+	return atomic.LoadInt64(&s.skipped)
+}
@@ -0,0 +1,306 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// errors ...
+var (
+	ErrInvalidStore     = errors.New("invalid store, expected memory, bolt, sqlite or json")
+	ErrNoStorePath      = errors.New("store requires a path to it's database file")
+	ErrInvalidJSONValue = errors.New("json store values must be json or empty")
+)
+
+// buckets of a Store ...
+const (
+	// StoreFrontier holds the urls queued for crawling and not yet crawled,
+	// with the depth they're crawled at.
+	StoreFrontier = "frontier"
+
+	// StoreSeen holds the urls crawled, keyed as the crawl dedupes them.
+	StoreSeen = "seen"
+
+	// StoreResults holds the json report of each url crawled.
+	StoreResults = "results"
+
+	// StoreCache holds the json validator cache entry of each url.
+	StoreCache = "cache"
+
+	// StoreURLs holds the urls of the live pages of the last complete crawl,
+	// which the next crawl's delta is listed against.
+	StoreURLs = "urls"
+
+	// StoreCrawl holds the json seed url of the crawl whose state is held,
+	// keyed as "seed".
+	StoreCrawl = "crawl"
+)
+
+// Store defines the contract for the persistence of a crawl's state, being the
+// frontier, seen set, results and caches, as values keyed within buckets, so
+// all persistence shares one backend. Values are returned as copies safe to
+// retain, and Each visits a snapshot of a bucket in key order, so it's
+// function may write into the store. Clear deletes all values of a bucket.
+type Store interface {
+	Get(bucket string, key string) ([]byte, bool, error)
+	Put(bucket string, key string, value []byte) error
+	Delete(bucket string, key string) error
+	Each(bucket string, fn func(key string, value []byte) error) error
+	Clear(bucket string) error
+	Close() error
+}
+
+// StoreKind defines the backend a Store keeps a crawl's state in.
+type StoreKind string
+
+// store kinds ...
+const (
+	StoreMemory StoreKind = "memory"
+	StoreBolt   StoreKind = "bolt"
+	StoreSQLite StoreKind = "sqlite"
+	StoreJSON   StoreKind = "json"
+)
+
+// ParseStoreKind parses giving store kind, an empty kind defaults to
+// StoreMemory.
+func ParseStoreKind(kind string) (StoreKind, error) {
+	switch StoreKind(kind) {
+	case "":
+		return StoreMemory, nil
+	case StoreMemory, StoreBolt, StoreSQLite, StoreJSON:
+		return StoreKind(kind), nil
+	}
+	return "", fmt.Errorf("%+s: %+q", ErrInvalidStore, kind)
+}
+
+// OpenStore returns the Store of giving kind, keeping it's state in the
+// database file at path for the bolt, sqlite and json kinds.
+func OpenStore(kind StoreKind, path string) (Store, error) {
+	switch kind {
+	case "", StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreBolt, StoreSQLite, StoreJSON:
+		if path == "" {
+			return nil, fmt.Errorf("%+s: %s", ErrNoStorePath, kind)
+		}
+
+		switch kind {
+		case StoreBolt:
+			return OpenBoltStore(path)
+		case StoreSQLite:
+			return OpenSQLiteStore(path)
+		}
+		return OpenJSONStore(path)
+	}
+	return nil, fmt.Errorf("%+s: %+q", ErrInvalidStore, kind)
+}
+
+// MemoryStore implements a concurrent-safe Store keeping a crawl's state in
+// memory, for crawls whose state needn't outlive them.
+type MemoryStore struct {
+	ml      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemoryStore returns a new instance of an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]map[string][]byte{},
+	}
+}
+
+// Get implements the Store interface.
+func (m *MemoryStore) Get(bucket string, key string) ([]byte, bool, error) {
+	m.ml.RLock()
+	defer m.ml.RUnlock()
+
+	value, ok := m.buckets[bucket][key]
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte(nil), value...), true, nil
+}
+
+// Put implements the Store interface.
+func (m *MemoryStore) Put(bucket string, key string, value []byte) error {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+
+	values, ok := m.buckets[bucket]
+	if !ok {
+		values = map[string][]byte{}
+		m.buckets[bucket] = values
+	}
+
+	values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete implements the Store interface.
+func (m *MemoryStore) Delete(bucket string, key string) error {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+// Each implements the Store interface.
+func (m *MemoryStore) Each(bucket string, fn func(key string, value []byte) error) error {
+	m.ml.RLock()
+	keys := make([]string, 0, len(m.buckets[bucket]))
+	values := make(map[string][]byte, len(m.buckets[bucket]))
+	for key, value := range m.buckets[bucket] {
+		keys = append(keys, key)
+		values[key] = append([]byte(nil), value...)
+	}
+	m.ml.RUnlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear implements the Store interface.
+func (m *MemoryStore) Clear(bucket string) error {
+	m.ml.Lock()
+	defer m.ml.Unlock()
+
+	delete(m.buckets, bucket)
+	return nil
+}
+
+// Close implements the Store interface.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// CrawlState records the state of a crawl into a Store as it runs, being the
+// urls queued for crawling into it's frontier with their depth, the urls
+// crawled into it's seen set and the report of each url crawled into it's
+// results. It keeps the first error returned by the store, which doesn't stop
+// the crawl. The state of a previous crawl is cleared by Reset, so the store
+// holds that of a single crawl.
+type CrawlState struct {
+	store Store
+	ml    sync.Mutex
+	err   error
+}
+
+// NewCrawlState returns a new instance of a CrawlState recording into giving
+// store.
+func NewCrawlState(store Store) *CrawlState {
+	return &CrawlState{store: store}
+}
+
+// Err returns the first error returned by the store while recording the
+// crawl, if any.
+func (s *CrawlState) Err() error {
+	if s == nil {
+		return nil
+	}
+
+	s.ml.Lock()
+	defer s.ml.Unlock()
+	return s.err
+}
+
+// Reset clears the frontier, seen set and results of the store, left by a
+// previous crawl recorded into it, to be called before the crawl starts.
+func (s *CrawlState) Reset() error {
+	if s == nil {
+		return nil
+	}
+
+	for _, bucket := range []string{StoreFrontier, StoreSeen, StoreResults, StoreCrawl} {
+		if err := s.store.Clear(bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CrawlSeed returns the seed url of the crawl whose state is held by giving
+// store, as recorded by a CrawlState. It returns nil if no crawl was recorded.
+func CrawlSeed(store Store) (*url.URL, error) {
+	data, found, err := store.Get(StoreCrawl, "seed")
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var seed string
+	if err := json.Unmarshal(data, &seed); err != nil {
+		return nil, err
+	}
+	return url.Parse(seed)
+}
+
+// seed records giving url as the seed of the crawl.
+func (s *CrawlState) seed(target *url.URL) {
+	if s == nil {
+		return
+	}
+
+	data, err := json.Marshal(target.String())
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.fail(s.store.Put(StoreCrawl, "seed", data))
+}
+
+// queue records giving url into the frontier as queued for crawling at
+// provided depth.
+func (s *CrawlState) queue(target *url.URL, depth int) {
+	if s == nil {
+		return
+	}
+	s.fail(s.store.Put(StoreFrontier, target.String(), []byte(strconv.Itoa(depth))))
+}
+
+// crawl records giving url into the seen set, removing it from the frontier.
+func (s *CrawlState) crawl(target *url.URL) {
+	if s == nil {
+		return
+	}
+
+	s.fail(s.store.Put(StoreSeen, seenKey(target), nil))
+	s.fail(s.store.Delete(StoreFrontier, target.String()))
+}
+
+// result records giving report of a crawled url into the results.
+func (s *CrawlState) result(report LinkReport) {
+	if s == nil {
+		return
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.fail(s.store.Put(StoreResults, report.Path.String(), data))
+}
+
+// fail keeps giving error if it's the first returned by the store.
+func (s *CrawlState) fail(err error) {
+	if err == nil {
+		return
+	}
+
+	s.ml.Lock()
+	defer s.ml.Unlock()
+
+	if s.err == nil {
+		s.err = err
+	}
+}
@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore implements a Store keeping a crawl's state in a BoltDB file, with
+// a bolt bucket per bucket of the store. Writes of concurrent crawlers are
+// batched into shared transactions.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore returns a BoltStore of the BoltDB file at giving path, which
+// is created if missing.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Get implements the Store interface.
+func (b *BoltStore) Get(bucket string, key string) ([]byte, bool, error) {
+	var value []byte
+	var found bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		values := tx.Bucket([]byte(bucket))
+		if values == nil {
+			return nil
+		}
+
+		if stored := values.Get([]byte(key)); stored != nil {
+			value, found = append([]byte(nil), stored...), true
+		}
+		return nil
+	})
+	return value, found, err
+}
+
+// Put implements the Store interface.
+func (b *BoltStore) Put(bucket string, key string, value []byte) error {
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		values, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return values.Put([]byte(key), append([]byte{}, value...))
+	})
+}
+
+// Delete implements the Store interface.
+func (b *BoltStore) Delete(bucket string, key string) error {
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		values := tx.Bucket([]byte(bucket))
+		if values == nil {
+			return nil
+		}
+		return values.Delete([]byte(key))
+	})
+}
+
+// Each implements the Store interface, visiting a snapshot of the bucket read
+// within a single transaction, so fn may write into the store.
+func (b *BoltStore) Each(bucket string, fn func(key string, value []byte) error) error {
+	var keys []string
+	var values [][]byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		stored := tx.Bucket([]byte(bucket))
+		if stored == nil {
+			return nil
+		}
+
+		return stored.ForEach(func(key []byte, value []byte) error {
+			keys = append(keys, string(key))
+			values = append(values, append([]byte(nil), value...))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for index, key := range keys {
+		if err := fn(key, values[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear implements the Store interface.
+func (b *BoltStore) Clear(bucket string) error {
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(bucket)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		return nil
+	})
+}
+
+// Close implements the Store interface.
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,117 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// jsonStoreFile embodies the content of the file of a JSONStore, with empty
+// values written as null.
+type jsonStoreFile struct {
+	Buckets map[string]map[string]json.RawMessage `json:"buckets"`
+}
+
+// JSONStore implements a Store keeping a crawl's state in memory, loaded from
+// a json file and written back into it once closed, for small states such as
+// page validators which are best kept as a readable file. Values put into it
+// must be json or empty.
+type JSONStore struct {
+	*MemoryStore
+
+	path   string
+	ml     sync.Mutex
+	closed bool
+}
+
+// OpenJSONStore returns a JSONStore of the json file at giving path, which is
+// created once the store is closed if missing. Files written by
+// ValidatorCache.Save are loaded as the cache bucket.
+func OpenJSONStore(path string) (*JSONStore, error) {
+	store := &JSONStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var content map[string]json.RawMessage
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+
+	buckets := map[string]map[string]json.RawMessage{}
+	if raw, ok := content["buckets"]; ok {
+		if err := json.Unmarshal(raw, &buckets); err != nil {
+			return nil, err
+		}
+	} else {
+		buckets[StoreCache] = content
+	}
+
+	for bucket, values := range buckets {
+		for key, value := range values {
+			if bytes.Equal(value, []byte("null")) {
+				value = nil
+			}
+
+			if err := store.MemoryStore.Put(bucket, key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return store, nil
+}
+
+// Put implements the Store interface, returning an error for values which
+// aren't json.
+func (j *JSONStore) Put(bucket string, key string, value []byte) error {
+	if len(value) != 0 && !json.Valid(value) {
+		return ErrInvalidJSONValue
+	}
+	return j.MemoryStore.Put(bucket, key, value)
+}
+
+// Close implements the Store interface, writing the store into it's file.
+// Only the first call writes the file.
+func (j *JSONStore) Close() error {
+	j.ml.Lock()
+	defer j.ml.Unlock()
+
+	if j.closed {
+		return nil
+	}
+
+	j.closed = true
+
+	content := jsonStoreFile{Buckets: map[string]map[string]json.RawMessage{}}
+
+	j.MemoryStore.ml.RLock()
+	for bucket, values := range j.MemoryStore.buckets {
+		if len(values) == 0 {
+			continue
+		}
+
+		written := make(map[string]json.RawMessage, len(values))
+		for key, value := range values {
+			if len(value) == 0 {
+				value = []byte("null")
+			}
+			written[key] = value
+		}
+		content.Buckets[bucket] = written
+	}
+	j.MemoryStore.ml.RUnlock()
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(j.path, data, 0644)
+}
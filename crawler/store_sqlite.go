@@ -0,0 +1,123 @@
+package crawler
+
+import (
+	"database/sql"
+
+	// registers the sqlite3 driver.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteSchema holds the table the values of all buckets of a SQLiteStore are
+// kept in.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS store (
+	bucket TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+) WITHOUT ROWID`
+
+// SQLiteStore implements a Store keeping a crawl's state in a SQLite database
+// file, within a single table keyed by bucket and key, so it can be queried
+// with sqlite3 once the crawl ends. It requires a build with cgo.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore returns a SQLiteStore of the SQLite database file at giving
+// path, which is created if missing. The database is written through it's
+// write-ahead log.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, so writes of concurrent crawlers are
+	// serialized over one connection rather than failing as busy.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Get implements the Store interface.
+func (s *SQLiteStore) Get(bucket string, key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM store WHERE bucket = ? AND key = ?`, bucket, key).Scan(&value)
+	switch err {
+	case nil:
+		return value, true, nil
+	case sql.ErrNoRows:
+		return nil, false, nil
+	}
+	return nil, false, err
+}
+
+// Put implements the Store interface.
+func (s *SQLiteStore) Put(bucket string, key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	_, err := s.db.Exec(`INSERT INTO store (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, bucket, key, value)
+	return err
+}
+
+// Delete implements the Store interface.
+func (s *SQLiteStore) Delete(bucket string, key string) error {
+	_, err := s.db.Exec(`DELETE FROM store WHERE bucket = ? AND key = ?`, bucket, key)
+	return err
+}
+
+// Each implements the Store interface, visiting a snapshot of the bucket read
+// by a single query, so fn may write into the store.
+func (s *SQLiteStore) Each(bucket string, fn func(key string, value []byte) error) error {
+	rows, err := s.db.Query(`SELECT key, value FROM store WHERE bucket = ? ORDER BY key`, bucket)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	var values [][]byte
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return err
+		}
+
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for index, key := range keys {
+		if err := fn(key, values[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear implements the Store interface.
+func (s *SQLiteStore) Clear(bucket string) error {
+	_, err := s.db.Exec(`DELETE FROM store WHERE bucket = ?`, bucket)
+	return err
+}
+
+// Close implements the Store interface.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
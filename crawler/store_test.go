@@ -0,0 +1,429 @@
+package crawler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestParseStoreKind(t *testing.T) {
+	for value, expected := range map[string]crawler.StoreKind{
+		"":       crawler.StoreMemory,
+		"memory": crawler.StoreMemory,
+		"bolt":   crawler.StoreBolt,
+		"sqlite": crawler.StoreSQLite,
+		"json":   crawler.StoreJSON,
+	} {
+		kind, err := crawler.ParseStoreKind(value)
+		if err != nil || kind != expected {
+			tests.Info("Kind: %q, Error: %+s", kind, err)
+			tests.Failed("Should have parsed store kind %q", value)
+		}
+	}
+	tests.Passed("Should have parsed store kinds")
+
+	if _, err := crawler.ParseStoreKind("redis"); err == nil || !strings.Contains(err.Error(), crawler.ErrInvalidStore.Error()) {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed to parse unknown store kind")
+	}
+	tests.Passed("Should have failed to parse unknown store kind")
+
+	if _, err := crawler.OpenStore(crawler.StoreBolt, ""); err == nil || !strings.Contains(err.Error(), crawler.ErrNoStorePath.Error()) {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have failed to open a bolt store without a path")
+	}
+	tests.Passed("Should have failed to open a bolt store without a path")
+}
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawl-store")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	for _, kind := range []crawler.StoreKind{crawler.StoreMemory, crawler.StoreBolt, crawler.StoreSQLite, crawler.StoreJSON} {
+		path := filepath.Join(dir, string(kind)+".db")
+		store, err := crawler.OpenStore(kind, path)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully opened %s store", kind)
+		}
+
+		if _, found, err := store.Get(crawler.StoreSeen, "example.com/"); err != nil || found {
+			tests.Info("Found: %t, Error: %+s", found, err)
+			tests.Failed("Should have found no value in empty %s store", kind)
+		}
+
+		value := []byte("1")
+		for _, key := range []string{"https://example.com/b", "https://example.com/a", "https://example.com/c"} {
+			if err := store.Put(crawler.StoreFrontier, key, value); err != nil {
+				tests.FailedWithError(err, "Should have successfully put value into %s store", kind)
+			}
+		}
+		value[0] = '9'
+
+		if err := store.Put(crawler.StoreFrontier, "https://example.com/c", []byte("2")); err != nil {
+			tests.FailedWithError(err, "Should have successfully replaced value of %s store", kind)
+		}
+
+		if err := store.Put(crawler.StoreSeen, "example.com/", nil); err != nil {
+			tests.FailedWithError(err, "Should have successfully put empty value into %s store", kind)
+		}
+
+		if err := store.Delete(crawler.StoreFrontier, "https://example.com/a"); err != nil {
+			tests.FailedWithError(err, "Should have successfully deleted value of %s store", kind)
+		}
+
+		if err := store.Delete(crawler.StoreResults, "https://example.com/a"); err != nil {
+			tests.FailedWithError(err, "Should have ignored deleting value of empty bucket of %s store", kind)
+		}
+
+		if found, err := storeValue(store, crawler.StoreFrontier, "https://example.com/b"); err != nil || found != "1" {
+			tests.Info("Value: %q, Error: %+s", found, err)
+			tests.Failed("Should have kept a copy of values put into %s store", kind)
+		}
+		tests.Passed("Should have kept a copy of values put into %s store", kind)
+
+		if _, found, err := store.Get(crawler.StoreSeen, "example.com/"); err != nil || !found {
+			tests.Info("Found: %t, Error: %+s", found, err)
+			tests.Failed("Should have found empty value in %s store", kind)
+		}
+		tests.Passed("Should have found empty value in %s store", kind)
+
+		var visited []string
+		err = store.Each(crawler.StoreFrontier, func(key string, value []byte) error {
+			visited = append(visited, key+"="+string(value))
+
+			// Writes within Each must not deadlock the store.
+			return store.Put(crawler.StoreSeen, key, nil)
+		})
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully visited values of %s store", kind)
+		}
+
+		if strings.Join(visited, ",") != "https://example.com/b=1,https://example.com/c=2" {
+			tests.Info("Visited: %q", visited)
+			tests.Failed("Should have visited values of bucket of %s store in key order", kind)
+		}
+		tests.Passed("Should have visited values of bucket of %s store in key order", kind)
+
+		if err := store.Put(crawler.StoreResults, "https://example.com/", []byte("{}")); err != nil {
+			tests.FailedWithError(err, "Should have successfully put value into %s store", kind)
+		}
+
+		if err := store.Clear(crawler.StoreResults); err != nil {
+			tests.FailedWithError(err, "Should have successfully cleared bucket of %s store", kind)
+		}
+
+		if err := store.Clear(crawler.StoreURLs); err != nil {
+			tests.FailedWithError(err, "Should have ignored clearing empty bucket of %s store", kind)
+		}
+
+		if keys := storeKeys(store, crawler.StoreResults); len(keys) != 0 {
+			tests.Info("Keys: %q", keys)
+			tests.Failed("Should have cleared bucket of %s store", kind)
+		}
+
+		if keys := storeKeys(store, crawler.StoreFrontier); len(keys) != 2 {
+			tests.Info("Keys: %q", keys)
+			tests.Failed("Should have only cleared given bucket of %s store", kind)
+		}
+		tests.Passed("Should have cleared bucket of %s store", kind)
+
+		if err := store.Close(); err != nil {
+			tests.FailedWithError(err, "Should have successfully closed %s store", kind)
+		}
+
+		if kind == crawler.StoreMemory {
+			continue
+		}
+
+		reopened, err := crawler.OpenStore(kind, path)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully reopened %s store", kind)
+		}
+
+		if found, err := storeValue(reopened, crawler.StoreFrontier, "https://example.com/c"); err != nil || found != "2" {
+			tests.Info("Value: %q, Error: %+s", found, err)
+			tests.Failed("Should have persisted values of %s store", kind)
+		}
+
+		if value, found, err := reopened.Get(crawler.StoreSeen, "example.com/"); err != nil || !found || len(value) != 0 {
+			tests.Info("Value: %q, Found: %t, Error: %+s", value, found, err)
+			tests.Failed("Should have persisted empty values of %s store", kind)
+		}
+		tests.Passed("Should have persisted values of %s store", kind)
+
+		if err := reopened.Close(); err != nil {
+			tests.FailedWithError(err, "Should have successfully closed %s store", kind)
+		}
+	}
+}
+
+func TestJSONStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawl-store")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cache.json")
+
+	cache := crawler.NewValidatorCache()
+	cache.Set("https://example.com/", crawler.CacheEntry{ETag: `"1"`, Title: "Home"})
+
+	var saved strings.Builder
+	if err := cache.Save(&saved); err != nil {
+		tests.FailedWithError(err, "Should have successfully saved cache")
+	}
+
+	if err := ioutil.WriteFile(path, []byte(saved.String()), 0644); err != nil {
+		tests.FailedWithError(err, "Should have successfully written cache file")
+	}
+
+	store, err := crawler.OpenJSONStore(path)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully opened json store of cache file")
+	}
+
+	restored, err := crawler.LoadValidatorCacheStore(store)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded cache from json store")
+	}
+
+	if entry, ok := restored.Get("https://example.com/"); !ok || entry.Title != "Home" {
+		tests.Info("Entry: %#v", entry)
+		tests.Failed("Should have loaded entries of cache file saved by cache into cache bucket")
+	}
+	tests.Passed("Should have loaded entries of cache file saved by cache into cache bucket")
+
+	if err := store.Put(crawler.StoreResults, "https://example.com/", []byte("<html>")); err == nil || err != crawler.ErrInvalidJSONValue {
+		tests.Info("Error: %+s", err)
+		tests.Failed("Should have refused value which isn't json")
+	}
+	tests.Passed("Should have refused value which isn't json")
+
+	if err := store.Close(); err != nil {
+		tests.FailedWithError(err, "Should have successfully closed json store")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read json store file")
+	}
+
+	var content struct {
+		Buckets map[string]map[string]crawler.CacheEntry `json:"buckets"`
+	}
+	if err := json.Unmarshal(data, &content); err != nil || content.Buckets[crawler.StoreCache]["https://example.com/"].ETag != `"1"` {
+		tests.Info("Content: %s", data)
+		tests.Failed("Should have written buckets of json store into it's file")
+	}
+	tests.Passed("Should have written buckets of json store into it's file")
+}
+
+func TestCrawlState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<a href="/about"></a><a href="/blog"></a>`))
+		case "/blog":
+			w.Write([]byte(`<a href="/blog/first"></a>`))
+		default:
+			w.Write([]byte(`<a href="/"></a>`))
+		}
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(10, ctx)
+	defer pool.Stop()
+
+	store := crawler.NewMemoryStore()
+	for _, bucket := range []string{crawler.StoreFrontier, crawler.StoreSeen, crawler.StoreResults, crawler.StoreCache} {
+		if err := store.Put(bucket, server.URL+"/removed", []byte("{}")); err != nil {
+			tests.FailedWithError(err, "Should have successfully put state of previous crawl into store")
+		}
+	}
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.State = crawler.NewCrawlState(store)
+
+	if err := pages.State.Reset(); err != nil {
+		tests.FailedWithError(err, "Should have successfully reset state of store")
+	}
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	var crawled int
+	for range reports {
+		crawled++
+	}
+
+	if err := pages.State.Err(); err != nil {
+		tests.FailedWithError(err, "Should have successfully recorded crawl into store")
+	}
+	tests.Passed("Should have successfully recorded crawl into store")
+
+	seen := storeKeys(store, crawler.StoreSeen)
+	if crawled != 4 || len(seen) != 4 {
+		tests.Info("Crawled: %d, Seen: %q", crawled, seen)
+		tests.Failed("Should have recorded every crawled url into seen set")
+	}
+	tests.Passed("Should have recorded every crawled url into seen set")
+
+	if frontier := storeKeys(store, crawler.StoreFrontier); len(frontier) != 0 {
+		tests.Info("Frontier: %q", frontier)
+		tests.Failed("Should have removed crawled urls from frontier")
+	}
+	tests.Passed("Should have removed crawled urls from frontier")
+
+	if seed, err := crawler.CrawlSeed(store); err != nil || seed == nil || seed.String() != server.URL+"/" {
+		tests.Info("Seed: %s, Error: %+s", seed, err)
+		tests.Failed("Should have recorded seed of crawl into store")
+	}
+	tests.Passed("Should have recorded seed of crawl into store")
+
+	if _, found, _ := store.Get(crawler.StoreResults, server.URL+"/removed"); found {
+		tests.Failed("Should have cleared results of previous crawl")
+	}
+
+	if _, found, _ := store.Get(crawler.StoreCache, server.URL+"/removed"); !found {
+		tests.Failed("Should have kept caches of previous crawl")
+	}
+	tests.Passed("Should have cleared state of previous crawl keeping caches")
+
+	data, found, err := store.Get(crawler.StoreResults, server.URL+"/blog")
+	if err != nil || !found {
+		tests.Info("Results: %q", storeKeys(store, crawler.StoreResults))
+		tests.Failed("Should have recorded report of crawled url into results")
+	}
+
+	var report crawler.LinkReport
+	if err := json.Unmarshal(data, &report); err != nil || len(report.PointsTo) != 1 || report.PointsTo[0].Path.Path != "/blog/first" {
+		tests.Info("Report: %s", data)
+		tests.Failed("Should have recorded report of crawled url into results")
+	}
+	tests.Passed("Should have recorded report of crawled url into results")
+}
+
+func TestCrawlStateFrontier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<a href="/about"></a><a href="/blog"></a>`))
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+
+	ctx := context.Background()
+	pool := crawler.NewWorkerPool(10, ctx)
+	defer pool.Stop()
+
+	store := crawler.NewMemoryStore()
+
+	var pages crawler.PageCrawler
+	pages.Target = target
+	pages.MaxDepth = 1
+	pages.State = crawler.NewCrawlState(store)
+
+	reports := make(chan crawler.LinkReport)
+	pool.Add(func() {
+		pages.Run(ctx, baseClient, pool, reports)
+	})
+
+	for range reports {
+	}
+
+	frontier := storeKeys(store, crawler.StoreFrontier)
+	if len(frontier) != 2 || frontier[0] != server.URL+"/about" || frontier[1] != server.URL+"/blog" {
+		tests.Info("Frontier: %q", frontier)
+		tests.Failed("Should have kept queued urls beyond max depth in frontier")
+	}
+
+	if depth, _ := storeValue(store, crawler.StoreFrontier, server.URL+"/blog"); depth != "1" {
+		tests.Info("Depth: %q", depth)
+		tests.Failed("Should have recorded depth of queued urls in frontier")
+	}
+	tests.Passed("Should have kept queued urls with their depth in frontier")
+}
+
+func TestValidatorCacheStore(t *testing.T) {
+	store := crawler.NewMemoryStore()
+	if err := store.Put(crawler.StoreCache, "https://example.com/stale", []byte(`{"etag":"\"0\""}`)); err != nil {
+		tests.FailedWithError(err, "Should have successfully put stale entry into store")
+	}
+
+	cache, err := crawler.LoadValidatorCacheStore(store)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded cache from store")
+	}
+
+	if entry, ok := cache.Get("https://example.com/stale"); !ok || entry.ETag != `"0"` {
+		tests.Info("Entry: %#v", entry)
+		tests.Failed("Should have loaded entries of cache from store")
+	}
+	tests.Passed("Should have loaded entries of cache from store")
+
+	cache.Set("https://example.com/stale", crawler.CacheEntry{})
+	cache.Set("https://example.com/", crawler.CacheEntry{ETag: `"1"`, Title: "Home"})
+	if err := cache.SaveStore(store); err != nil {
+		tests.FailedWithError(err, "Should have successfully saved cache into store")
+	}
+
+	if keys := storeKeys(store, crawler.StoreCache); len(keys) != 1 || keys[0] != "https://example.com/" {
+		tests.Info("Keys: %q", keys)
+		tests.Failed("Should have replaced entries of cache in store")
+	}
+	tests.Passed("Should have replaced entries of cache in store")
+
+	restored, err := crawler.LoadValidatorCacheStore(store)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded cache from store")
+	}
+
+	if entry, ok := restored.Get("https://example.com/"); !ok || entry.ETag != `"1"` || entry.Title != "Home" {
+		tests.Info("Entry: %#v", entry)
+		tests.Failed("Should have restored saved entries of cache")
+	}
+	tests.Passed("Should have restored saved entries of cache")
+}
+
+// storeValue returns the value of giving key of provided store as a string.
+func storeValue(store crawler.Store, bucket string, key string) (string, error) {
+	value, _, err := store.Get(bucket, key)
+	return string(value), err
+}
+
+// storeKeys returns the keys of giving bucket of provided store.
+func storeKeys(store crawler.Store, bucket string) []string {
+	var keys []string
+	store.Each(bucket, func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys
+}
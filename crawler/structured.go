@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// schemaPrefixes lists the prefixes of schema.org types stripped from the
+// types reported, so https://schema.org/Product is reported as Product.
+var schemaPrefixes = []string{"https://schema.org/", "http://schema.org/", "schema:"}
+
+// StructuredData embodies the schema.org types declared by a page through it's
+// <script type="application/ld+json"> blocks and microdata itemtype attributes,
+// with the total JSON-LD blocks and the errors of those failing to parse, so
+// structured data can be confirmed to survive deployments.
+type StructuredData struct {
+	JSONLD    []string `json:"jsonld,omitempty"`
+	Microdata []string `json:"microdata,omitempty"`
+	Blocks    int      `json:"jsonld_blocks,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// empty returns true if the page declares no structured data.
+func (s StructuredData) empty() bool {
+	return s.Blocks == 0 && len(s.Microdata) == 0
+}
+
+// Types returns the types declared through JSON-LD and microdata, sorted.
+func (s StructuredData) Types() []string {
+	var types []string
+	for _, kind := range s.JSONLD {
+		types = addType(types, kind)
+	}
+	for _, kind := range s.Microdata {
+		types = addType(types, kind)
+	}
+	return types
+}
+
+// farmJSONLD records the types declared by giving content of a JSON-LD block
+// into data, or the error failing to parse it, blocks being numbered in the
+// order they're read.
+func farmJSONLD(data *StructuredData, content []byte) {
+	data.Blocks++
+
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		data.Errors = append(data.Errors, fmt.Sprintf("block %d: %s", data.Blocks, err))
+		return
+	}
+
+	data.JSONLD = jsonLDTypes(data.JSONLD, document)
+}
+
+// farmMicrodata records the types declared by the itemtype of giving element
+// attributes into data.
+func farmMicrodata(data *StructuredData, attrs []html.Attribute) {
+	itemType, ok := getAttr(attrs, "itemtype")
+	if !ok {
+		return
+	}
+
+	for _, kind := range strings.Fields(itemType.Val) {
+		data.Microdata = addType(data.Microdata, kind)
+	}
+}
+
+// jsonLDTypes returns giving types with the @type values of all nodes within
+// giving JSON-LD document added, including those nested within properties or
+// an @graph.
+func jsonLDTypes(types []string, document interface{}) []string {
+	switch node := document.(type) {
+	case []interface{}:
+		for _, item := range node {
+			types = jsonLDTypes(types, item)
+		}
+	case map[string]interface{}:
+		for key, value := range node {
+			if key != "@type" {
+				types = jsonLDTypes(types, value)
+				continue
+			}
+
+			switch kind := value.(type) {
+			case string:
+				types = addType(types, kind)
+			case []interface{}:
+				for _, item := range kind {
+					if name, ok := item.(string); ok {
+						types = addType(types, name)
+					}
+				}
+			}
+		}
+	}
+	return types
+}
+
+// addType returns giving sorted types with provided type added, stripped of
+// it's schema.org prefix, unless already present.
+func addType(types []string, kind string) []string {
+	kind = strings.TrimSpace(kind)
+	for _, prefix := range schemaPrefixes {
+		kind = strings.TrimPrefix(kind, prefix)
+	}
+
+	if kind == "" {
+		return types
+	}
+
+	index := sort.SearchStrings(types, kind)
+	if index < len(types) && types[index] == kind {
+		return types
+	}
+
+	types = append(types, "")
+	copy(types[index+1:], types[index:])
+	types[index] = kind
+	return types
+}
@@ -0,0 +1,281 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// referenceScanner implements a concurrent-safe cache of the references
+// scanned from documents such as stylesheets and feeds, shared by all pages of
+// a crawl, so documents linked by every page are fetched and scanned once.
+type referenceScanner struct {
+	ml   sync.Mutex
+	docs map[string]*referenceScan
+}
+
+// referenceScan embodies a pending or completed scan of a document.
+type referenceScan struct {
+	done chan struct{}
+	refs []*url.URL
+}
+
+func newReferenceScanner() *referenceScanner {
+	return &referenceScanner{
+		docs: map[string]*referenceScan{},
+	}
+}
+
+// Scan returns the references of giving document, running the provided scan
+// only if no other scan of the document is pending or completed.
+func (s *referenceScanner) Scan(doc *url.URL, scan func() []*url.URL) []*url.URL {
+	if s == nil {
+		return scan()
+	}
+
+	key := doc.String()
+
+	s.ml.Lock()
+	pending, ok := s.docs[key]
+	if !ok {
+		pending = &referenceScan{done: make(chan struct{})}
+		s.docs[key] = pending
+	}
+	s.ml.Unlock()
+
+	if ok {
+		<-pending.done
+	} else {
+		pending.refs = scan()
+		close(pending.done)
+	}
+
+	return pending.refs
+}
+
+// checkStylesheets fetches the live stylesheets among giving links of a page,
+// along with the stylesheets they import, returning the reports of the fonts,
+// images and stylesheets they reference which the page doesn't link to itself.
+// Each report records the stylesheet referencing it.
+func (pc PageCrawler) checkStylesheets(ctx context.Context, pool WorkerPool, kids []LinkReport) []LinkReport {
+	linked := map[string]bool{}
+	for _, kid := range kids {
+		linked[kid.Path.String()] = true
+	}
+
+	var found []LinkReport
+	for queue := append([]LinkReport(nil), kids...); len(queue) != 0; queue = queue[1:] {
+		kid := queue[0]
+		if !isStylesheet(kid) {
+			continue
+		}
+
+		sheet := kid.Path
+		if kid.RedirectedTo != nil {
+			sheet = kid.RedirectedTo
+		}
+
+		refs := pc.stylesheets.Scan(sheet, func() []*url.URL {
+			return pc.fetchStylesheet(ctx, sheet)
+		})
+
+		links := make(map[*url.URL]linkContext, len(refs))
+		for index, ref := range refs {
+			links[ref] = linkContext{Index: index, Position: kid.Position}
+		}
+
+		// References the page already links to are skipped, so stylesheets
+		// importing each other are scanned once.
+		checked, _ := pc.checkLinks(ctx, pool, sheet, links)
+		for _, ref := range checked {
+			if linked[ref.Path.String()] {
+				continue
+			}
+
+			linked[ref.Path.String()] = true
+			ref.Stylesheet = sheet.String()
+			found = append(found, ref)
+			queue = append(queue, ref)
+		}
+	}
+
+	return found
+}
+
+// isStylesheet returns true if giving report is of a live stylesheet, linked
+// as one or served as text/css.
+func isStylesheet(report LinkReport) bool {
+	if !report.Status.IsLive {
+		return false
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(report.ContentType); err == nil && mediaType == "text/css" {
+		return true
+	}
+
+	for _, rel := range report.Rel {
+		if rel == "stylesheet" {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchStylesheet retrieves giving stylesheet, returning the urls referenced by
+// it's url() functions and @import rules resolved against it. Stylesheets are
+// read up to the crawler's maximum body size.
+func (pc PageCrawler) fetchStylesheet(ctx context.Context, sheet *url.URL) []*url.URL {
+	if err := pc.scheduler.Wait(ctx, sheet); err != nil {
+		return nil
+	}
+
+	ctx, cancel := pc.requestContext(ctx)
+	defer cancel()
+
+	req, err := pc.newRequest(ctx, http.MethodGet, sheet)
+	if err != nil {
+		return nil
+	}
+
+	res, _, err := followRedirects(pc.fetch, req, pc.allowsRedirect, pc.maxRedirects())
+	if err != nil {
+		return nil
+	}
+
+	defer closeBody(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(res.Body, pc.maxBodySize()))
+	if err != nil {
+		return nil
+	}
+
+	var refs []*url.URL
+	for _, ref := range scanCSS(string(content)) {
+		if link, err := parsePath(ref, res.Request.URL); err == nil {
+			refs = append(refs, link)
+		}
+	}
+	return refs
+}
+
+// scanCSS returns the urls giving css references through url() functions and
+// @import rules in order of appearance, skipping those within comments and
+// strings, and fragment-only references such as those of svg filters.
+func scanCSS(css string) []string {
+	var refs []string
+	add := func(ref string) {
+		if ref = strings.TrimSpace(ref); ref != "" && !strings.HasPrefix(ref, "#") {
+			refs = append(refs, ref)
+		}
+	}
+
+	for index := 0; index < len(css); {
+		rest := css[index:]
+		switch {
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end == -1 {
+				return refs
+			}
+			index += end + 4
+		case hasPrefixFold(rest, "url(") && (index == 0 || !isIdentByte(css[index-1])):
+			ref, next := readCSSURL(css, index+4)
+			add(ref)
+			index = next
+		case hasPrefixFold(rest, "@import"):
+			// Imports given as url() are scanned as any other url().
+			index = skipCSSSpaces(css, index+len("@import"))
+			if index < len(css) && (css[index] == '"' || css[index] == '\'') {
+				ref, next := readCSSString(css, index)
+				add(ref)
+				index = next
+			}
+		case rest[0] == '"' || rest[0] == '\'':
+			_, index = readCSSString(css, index)
+		default:
+			index++
+		}
+	}
+
+	return refs
+}
+
+// readCSSURL returns the value of the url() function whose arguments start at
+// giving index of css, quoted or not, and the index following the function.
+func readCSSURL(css string, index int) (string, int) {
+	index = skipCSSSpaces(css, index)
+
+	var ref string
+	if index < len(css) && (css[index] == '"' || css[index] == '\'') {
+		ref, index = readCSSString(css, index)
+	} else {
+		var value strings.Builder
+		for ; index < len(css) && css[index] != ')'; index++ {
+			if css[index] == '\\' && index+1 < len(css) {
+				index++
+			}
+			value.WriteByte(css[index])
+		}
+		ref = value.String()
+	}
+
+	if end := strings.IndexByte(css[index:], ')'); end != -1 {
+		return ref, index + end + 1
+	}
+	return ref, len(css)
+}
+
+// readCSSString returns the unescaped value of the css string quoted at giving
+// index, and the index following it's closing quote.
+func readCSSString(css string, index int) (string, int) {
+	quote := css[index]
+
+	var value strings.Builder
+	for index++; index < len(css); index++ {
+		switch css[index] {
+		case quote:
+			return value.String(), index + 1
+		case '\\':
+			if index+1 < len(css) {
+				index++
+				value.WriteByte(css[index])
+			}
+		case '\n':
+			// Unterminated strings end at the line's end.
+			return value.String(), index
+		default:
+			value.WriteByte(css[index])
+		}
+	}
+
+	return value.String(), index
+}
+
+// skipCSSSpaces returns the index of the first byte from giving index of css
+// which isn't a space.
+func skipCSSSpaces(css string, index int) int {
+	for index < len(css) && isSpace(css[index]) {
+		index++
+	}
+	return index
+}
+
+// hasPrefixFold returns true if giving value starts with prefix, ignoring case.
+func hasPrefixFold(value string, prefix string) bool {
+	return len(value) >= len(prefix) && strings.EqualFold(value[:len(prefix)], prefix)
+}
+
+// isIdentByte returns true if giving byte may be part of a css identifier, so
+// functions such as my-url() aren't mistaken for url().
+func isIdentByte(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
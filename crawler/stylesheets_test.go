@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestScanCSS(t *testing.T) {
+	refs := scanCSS(`
+		@import "reset.css";
+		@IMPORT url('print.css') print;
+		/* background: url(commented.png); */
+		body { background: URL( "bg.png" ) no-repeat, url(data:image/gif;base64,R0lGOD); }
+		.icon { mask: my-url(ignored.svg); filter: url(#blur); }
+		.quote::before { content: "url(quoted.png)"; }
+		@font-face { src: url(fonts/a\(1\).woff2) format("woff2"), url(fonts/a.woff); }
+	`)
+
+	expected := []string{"reset.css", "print.css", "bg.png", "data:image/gif;base64,R0lGOD", "fonts/a(1).woff2", "fonts/a.woff"}
+	if strings.Join(refs, " ") != strings.Join(expected, " ") {
+		tests.Info("Expected: %+q", expected)
+		tests.Info("Received: %+q", refs)
+		tests.Failed("Should have scanned url() and @import references of css")
+	}
+	tests.Passed("Should have scanned url() and @import references of css")
+}
+
+func TestFarmInlineStyles(t *testing.T) {
+	target, err := url.Parse("http://mombo.com/blog/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	page := farmDocument(bytes.NewReader([]byte(`
+		<html>
+		<head>
+			<style>
+				@import "/css/print.css";
+				@font-face { src: url(fonts/brand.woff2); }
+			</style>
+		</head>
+		<body>
+			<div style="background-image: url('hero.jpg')"></div>
+			<p>url(not-a-style.png)</p>
+		</body>
+		</html>
+	`)), target)
+
+	expected := map[string]bool{
+		"/css/print.css":          true,
+		"/blog/fonts/brand.woff2": true,
+		"/blog/hero.jpg":          true,
+	}
+
+	if len(page.Links) != len(expected) {
+		tests.Info("Received Links: %+v", page.Links)
+		tests.Failed("Should have farmed references of inline styles")
+	}
+
+	for link := range page.Links {
+		if !expected[link.Path] {
+			tests.Info("Received Link: %q", link.Path)
+			tests.Failed("Should have farmed references of inline styles")
+		}
+	}
+	tests.Passed("Should have farmed references of inline styles")
+}
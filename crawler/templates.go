@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultTemplateSimilarity is the share of tag-path shingles pages must have
+// in common to be clustered into the same template when none is set.
+const DefaultTemplateSimilarity = 0.8
+
+// Template embodies a cluster of pages of a crawl sharing the same structure,
+// such as the product pages of a shop, with the first page clustered into it
+// as it's sample and the pages whose links were left unexplored once it's
+// page limit was reached.
+type Template struct {
+	ID     string `json:"id"`
+	Sample string `json:"sample"`
+	Pages  int    `json:"pages"`
+	Capped int    `json:"capped"`
+}
+
+// TemplateClusters implements a concurrent-safe clustering of crawled pages by
+// their structural fingerprint, the set of tag paths of their elements, so
+// pages rendered from the same template are counted together whatever their
+// content. It optionally limits the pages of each template whose links are
+// explored, bounding crawls of machine-generated sections.
+type TemplateClusters struct {
+	similarity float64
+	limit      int
+
+	ml        sync.Mutex
+	templates []*Template
+	shingles  [][]uint64
+}
+
+// NewTemplateClusters returns a new instance of a TemplateClusters clustering
+// pages sharing at least giving share of their shingles, defaulting to
+// DefaultTemplateSimilarity, and exploring the links of at most limit pages of
+// each template. A limit of zero leaves templates unlimited.
+func NewTemplateClusters(similarity float64, limit int) *TemplateClusters {
+	if similarity <= 0 || similarity > 1 {
+		similarity = DefaultTemplateSimilarity
+	}
+
+	return &TemplateClusters{similarity: similarity, limit: limit}
+}
+
+// Assign clusters the page of giving url and shingles into the template it
+// resembles most, else into a new template, returning the template's id and
+// true if the page's links are within the template's limit to be explored.
+// Pages without shingles, such as those restored from a cache, are left
+// unclustered.
+func (t *TemplateClusters) Assign(link string, shingles []uint64) (string, bool) {
+	if t == nil || len(shingles) == 0 {
+		return "", true
+	}
+
+	t.ml.Lock()
+	defer t.ml.Unlock()
+
+	best, bestScore := -1, t.similarity
+	for index, sample := range t.shingles {
+		if score := jaccard(sample, shingles); score >= bestScore {
+			best, bestScore = index, score
+		}
+	}
+
+	if best == -1 {
+		best = len(t.templates)
+		t.templates = append(t.templates, &Template{ID: "t" + strconv.Itoa(best+1), Sample: link})
+		t.shingles = append(t.shingles, shingles)
+	}
+
+	template := t.templates[best]
+	template.Pages++
+
+	if t.limit > 0 && template.Pages > t.limit {
+		template.Capped++
+		return template.ID, false
+	}
+	return template.ID, true
+}
+
+// Templates returns all templates found ordered by their total pages.
+func (t *TemplateClusters) Templates() []Template {
+	if t == nil {
+		return nil
+	}
+
+	t.ml.Lock()
+	defer t.ml.Unlock()
+
+	templates := make([]Template, 0, len(t.templates))
+	for _, template := range t.templates {
+		templates = append(templates, *template)
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Pages > templates[j].Pages
+	})
+	return templates
+}
+
+// tagPathShingles returns the sorted hashes of giving distinct tag paths, such
+// as html>body>div>ul>li, being the shingles of a page's structure.
+func tagPathShingles(paths map[string]bool) []uint64 {
+	shingles := make([]uint64, 0, len(paths))
+	for path := range paths {
+		hash := fnv.New64a()
+		hash.Write([]byte(path))
+		shingles = append(shingles, hash.Sum64())
+	}
+
+	sort.Slice(shingles, func(i, j int) bool { return shingles[i] < shingles[j] })
+	return shingles
+}
+
+// jaccard returns the share of shingles giving sorted sets have in common out
+// of all their shingles.
+func jaccard(a []uint64, b []uint64) float64 {
+	var shared int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			shared++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 1
+	}
+	return float64(shared) / float64(union)
+}
@@ -0,0 +1,51 @@
+package crawler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestTemplateClusters(t *testing.T) {
+	farm := func(content string) []uint64 {
+		return farmDocument(bytes.NewReader([]byte(content)), nil).Shingles
+	}
+
+	article := farm(`<html><body><header><nav><a></a></nav></header><main><article><h1></h1><p></p><p></p><img></article></main></body></html>`)
+	longArticle := farm(`<html><body><header><nav><a></a><a></a></nav></header><main><article><h1></h1><p></p><p></p><p></p><p></p><img></article></main></body></html>`)
+	listing := farm(`<html><body><table><tr><td><span></span></td></tr></table></body></html>`)
+
+	if score := jaccard(article, longArticle); score != 1 {
+		tests.Info("Received Similarity: %f", score)
+		tests.Failed("Should have found pages differing only in repeated elements alike")
+	}
+	tests.Passed("Should have found pages differing only in repeated elements alike")
+
+	clusters := NewTemplateClusters(0, 1)
+	for index, page := range []struct {
+		link     string
+		shingles []uint64
+		id       string
+		explore  bool
+	}{
+		{link: "/blog/a", shingles: article, id: "t1", explore: true},
+		{link: "/products", shingles: listing, id: "t2", explore: true},
+		{link: "/blog/b", shingles: longArticle, id: "t1", explore: false},
+		{link: "/cached", id: "", explore: true},
+	} {
+		id, explore := clusters.Assign(page.link, page.shingles)
+		if id != page.id || explore != page.explore {
+			tests.Info("Page %d: %q, explore: %t", index, id, explore)
+			tests.Failed("Should have clustered page into it's template")
+		}
+	}
+	tests.Passed("Should have clustered pages into their templates")
+
+	templates := clusters.Templates()
+	if len(templates) != 2 || templates[0].ID != "t1" || templates[0].Pages != 2 || templates[0].Capped != 1 || templates[0].Sample != "/blog/a" {
+		tests.Info("Received Templates: %#v", templates)
+		tests.Failed("Should have counted pages per template")
+	}
+	tests.Passed("Should have counted pages per template")
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams lists the query parameter names known to carry campaign and
+// click tracking ids, names ending in "*" matching all parameters of their
+// prefix.
+var trackingParams = []string{
+	"utm_*",
+	"gclid",
+	"gclsrc",
+	"dclid",
+	"gbraid",
+	"wbraid",
+	"fbclid",
+	"msclkid",
+	"twclid",
+	"ttclid",
+	"yclid",
+	"igshid",
+	"li_fat_id",
+	"mc_cid",
+	"mc_eid",
+	"mkt_tok",
+	"_ga",
+	"_gl",
+	"_hsenc",
+	"_hsmi",
+}
+
+// stripTracking returns a copy of giving link without it's tracking parameters
+// and the names of the parameters stripped, matching the built-in tracking
+// parameters unless keepBuiltin is set and all extra patterns. The link is
+// returned as is if it has none.
+func stripTracking(link *url.URL, keepBuiltin bool, extra []string) (*url.URL, []string) {
+	if link.RawQuery == "" || (keepBuiltin && len(extra) == 0) {
+		return link, nil
+	}
+
+	var kept []string
+	stripped := map[string]bool{}
+	for _, param := range strings.Split(link.RawQuery, "&") {
+		if param == "" {
+			continue
+		}
+
+		name := queryName(param)
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if (!keepBuiltin && matchParam(trackingParams, name)) || matchParam(extra, name) {
+			stripped[name] = true
+			continue
+		}
+		kept = append(kept, param)
+	}
+
+	if len(stripped) == 0 {
+		return link, nil
+	}
+
+	names := make([]string, 0, len(stripped))
+	for name := range stripped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clean := *link
+	clean.RawQuery = strings.Join(kept, "&")
+	clean.ForceQuery = false
+	return &clean, names
+}
+
+// matchParam returns true if giving parameter name matches any of the patterns,
+// ignoring case. Patterns ending in "*" match names of their prefix.
+func matchParam(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			continue
+		}
+
+		if name == pattern {
+			return true
+		}
+	}
+	return false
+}
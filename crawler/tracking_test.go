@@ -0,0 +1,33 @@
+package crawler
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestStripTracking(t *testing.T) {
+	link, _ := url.Parse("http://site.com/article?id=4&utm_source=mail&UTM_Medium=email&gclid=abc&campaign_id=x&utm_source=feed")
+
+	stripped, names := stripTracking(link, false, nil)
+	if stripped.String() != "http://site.com/article?id=4&campaign_id=x" || !reflect.DeepEqual(names, []string{"UTM_Medium", "gclid", "utm_source"}) {
+		tests.Info("Received URL: %q, Stripped: %#v", stripped, names)
+		tests.Failed("Should have stripped built-in tracking parameters")
+	}
+	tests.Passed("Should have stripped built-in tracking parameters")
+
+	stripped, names = stripTracking(link, true, []string{"campaign_*"})
+	if stripped.String() != "http://site.com/article?id=4&utm_source=mail&UTM_Medium=email&gclid=abc&utm_source=feed" || !reflect.DeepEqual(names, []string{"campaign_id"}) {
+		tests.Info("Received URL: %q, Stripped: %#v", stripped, names)
+		tests.Failed("Should have only stripped extra tracking parameters")
+	}
+	tests.Passed("Should have only stripped extra tracking parameters")
+
+	plain, _ := url.Parse("http://site.com/article?id=4")
+	if stripped, names := stripTracking(plain, false, nil); stripped != plain || names != nil {
+		tests.Failed("Should have returned link without tracking parameters as is")
+	}
+	tests.Passed("Should have returned link without tracking parameters as is")
+}
@@ -0,0 +1,408 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrReadTimeout is returned by reads of response bodies which stall for longer
+// than the transport's read timeout.
+var ErrReadTimeout = errors.New("response body read timed out")
+
+// ErrNoCertificates is returned for ca bundles holding no pem encoded certificates.
+var ErrNoCertificates = errors.New("no pem encoded certificates found in ca bundle")
+
+// defaultConnectTimeout sets the maximum time allowed to connect to a host when
+// none is provided.
+const defaultConnectTimeout = 30 * time.Second
+
+// defaultFallbackDelay sets how long the addresses of the family of a host
+// first resolved are dialed before those of the other family are raced
+// against them, as used by net.Dialer.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// TransportOptions defines the options of the http.Transport requests of a
+// crawl are sent over.
+type TransportOptions struct {
+	// IPFamily sets the ip address family dialed, IPFamilyAuto races both
+	// families with Happy Eyeballs, preferring the first to connect.
+	IPFamily IPFamily
+
+	// Resolver when set resolves hosts dialed by the transport, such as a
+	// DNSCache, in place of the system resolver. Resolved addresses are dialed
+	// in order till one connects, those of both families being raced with
+	// Happy Eyeballs under IPFamilyAuto.
+	Resolver HostResolver
+
+	// ConnectTimeout sets the maximum time allowed to connect to a host,
+	// defaults to 30 seconds.
+	ConnectTimeout time.Duration
+
+	// ResponseHeaderTimeout sets the maximum time allowed for a host to respond
+	// with headers once a request is sent. A zero value leaves it unlimited.
+	ResponseHeaderTimeout time.Duration
+
+	// ReadTimeout sets the maximum time a single read of a response body may
+	// stall, so slow bodies are abandoned without bounding their total
+	// download time. A zero value leaves it unlimited. It's only applied by the
+	// http.RoundTripper of NewRoundTripper.
+	ReadTimeout time.Duration
+
+	// MaxConnsPerHost sets the maximum connections open to a host, with as many
+	// kept idle for reuse. A zero value leaves connections unlimited.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout sets how long idle connections are kept for reuse,
+	// defaults to that of http.DefaultTransport.
+	IdleConnTimeout time.Duration
+
+	// TLSHandshakeTimeout sets the maximum time allowed for a TLS handshake,
+	// defaults to that of http.DefaultTransport.
+	TLSHandshakeTimeout time.Duration
+
+	// RootCAs sets the certificate authorities host certificates are verified
+	// against, defaults to those of the system.
+	RootCAs *x509.CertPool
+
+	// InsecureSkipVerify dictates that the transport skip verification of host
+	// certificates, for staging environments with self-signed certificates.
+	InsecureSkipVerify bool
+
+	// DisableHTTP2 dictates that the transport only speak HTTP/1.1, even to
+	// hosts supporting HTTP/2.
+	DisableHTTP2 bool
+
+	// Bodies when set records the response bodies received and closed, for
+	// spotting leaked bodies. It's only applied by the http.RoundTripper of
+	// NewRoundTripper.
+	Bodies *BodyStats
+}
+
+// NewTransport returns a new http.Transport configured with giving options.
+func NewTransport(opts TransportOptions) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   defaultConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if opts.ConnectTimeout > 0 {
+		dialer.Timeout = opts.ConnectTimeout
+	}
+
+	family := opts.IPFamily
+	network := family.Network()
+	resolver := opts.Resolver
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _ string, addr string) (net.Conn, error) {
+		if resolver == nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return dialResolved(ctx, dialer, resolver, family, addr)
+	}
+
+	if opts.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = opts.MaxConnsPerHost
+		transport.MaxIdleConnsPerHost = opts.MaxConnsPerHost
+	}
+
+	if opts.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	}
+
+	if opts.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = opts.IdleConnTimeout
+	}
+
+	if opts.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+
+	if opts.RootCAs != nil || opts.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:            opts.RootCAs,
+			InsecureSkipVerify: opts.InsecureSkipVerify,
+		}
+	}
+
+	if opts.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// LoadCertPool returns the certificate authorities of the system with those of
+// the pem encoded bundle at giving path added.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("%+s: %+q", ErrNoCertificates, path)
+	}
+	return pool, nil
+}
+
+// NewRoundTripper returns a new http.RoundTripper over the http.Transport of
+// NewTransport, abandoning response bodies which stall for longer than the
+// read timeout of giving options and recording response bodies into it's body
+// stats.
+func NewRoundTripper(opts TransportOptions) http.RoundTripper {
+	var transport http.RoundTripper = NewTransport(opts)
+	if opts.ReadTimeout > 0 {
+		transport = &readTimeoutTransport{transport: transport, timeout: opts.ReadTimeout}
+	}
+
+	if opts.Bodies != nil {
+		transport = &bodyStatsTransport{transport: transport, stats: opts.Bodies}
+	}
+	return transport
+}
+
+// readTimeoutTransport implements a http.RoundTripper which cancels requests
+// whose response body stalls for longer than timeout on a single read.
+type readTimeoutTransport struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+// CloseIdleConnections closes the idle connections of the underlying transport,
+// if it supports it.
+func (r *readTimeoutTransport) CloseIdleConnections() {
+	closeIdleConnections(r.transport)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *readTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	res, err := r.transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = &readTimeoutBody{body: res.Body, timeout: r.timeout, cancel: cancel}
+	return res, nil
+}
+
+// readTimeoutBody implements a response body which cancels it's request when
+// a read stalls for longer than timeout.
+type readTimeoutBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	cancel  context.CancelFunc
+	ml      sync.Mutex
+	expired bool
+}
+
+// Read implements the io.Reader interface.
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	timer := time.AfterFunc(b.timeout, func() {
+		b.ml.Lock()
+		b.expired = true
+		b.ml.Unlock()
+
+		b.cancel()
+	})
+
+	n, err := b.body.Read(p)
+	timer.Stop()
+
+	b.ml.Lock()
+	defer b.ml.Unlock()
+
+	if err != nil && b.expired {
+		return n, ErrReadTimeout
+	}
+	return n, err
+}
+
+// Close implements the io.Closer interface.
+func (b *readTimeoutBody) Close() error {
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
+
+// unwrap returns the body read.
+func (b *readTimeoutBody) unwrap() io.ReadCloser {
+	return b.body
+}
+
+// cancelBody implements a response body which cancels the context of it's
+// request once closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements the io.Closer interface.
+func (c cancelBody) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// unwrap returns the body of the request.
+func (c cancelBody) unwrap() io.ReadCloser {
+	return c.ReadCloser
+}
+
+// dialResolved dials giving address, resolving it's host with giving resolver.
+// The resolved addresses of the family first listed are dialed in order, raced
+// against those of the other family once the dialer's fallback delay passes,
+// as Happy Eyeballs does.
+func dialResolved(ctx context.Context, dialer *net.Dialer, resolver HostResolver, family IPFamily, addr string) (net.Conn, error) {
+	network := family.Network()
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var primaryV4 bool
+	var primaries, fallbacks []string
+	for _, resolved := range addrs {
+		ip := net.ParseIP(resolved)
+		if ip == nil || !family.allows(ip) {
+			continue
+		}
+
+		address := net.JoinHostPort(resolved, port)
+		if len(primaries) == 0 {
+			primaryV4 = ip.To4() != nil
+		}
+
+		if (ip.To4() != nil) == primaryV4 {
+			primaries = append(primaries, address)
+			continue
+		}
+		fallbacks = append(fallbacks, address)
+	}
+
+	if len(primaries) == 0 {
+		return nil, fmt.Errorf("no addresses of ip family %s found for host %+q", family, host)
+	}
+
+	if len(fallbacks) == 0 {
+		return dialSerial(ctx, dialer, network, primaries)
+	}
+	return dialParallel(ctx, dialer, network, primaries, fallbacks)
+}
+
+// dialSerial dials giving addresses in order till one connects, returning the
+// error of the first failing if none do.
+func dialSerial(ctx context.Context, dialer *net.Dialer, network string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// dialResult embodies the outcome of dialing the primary or fallback
+// addresses of a host.
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// dialParallel dials giving primary addresses, racing them against the
+// fallback addresses once the dialer's fallback delay passes or the primaries
+// fail, returning the first connection made. Connections of the losing race
+// are closed.
+func dialParallel(ctx context.Context, dialer *net.Dialer, network string, primaries []string, fallbacks []string) (net.Conn, error) {
+	returned := make(chan struct{})
+	defer close(returned)
+
+	results := make(chan dialResult)
+	race := func(ctx context.Context, addrs []string, primary bool) {
+		conn, err := dialSerial(ctx, dialer, network, addrs)
+		select {
+		case results <- dialResult{conn: conn, err: err, primary: primary}:
+		case <-returned:
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+
+	primaryCtx, primaryCancel := context.WithCancel(ctx)
+	defer primaryCancel()
+	go race(primaryCtx, primaries, true)
+
+	delay := dialer.FallbackDelay
+	if delay <= 0 {
+		delay = defaultFallbackDelay
+	}
+
+	fallbackTimer := time.NewTimer(delay)
+	defer fallbackTimer.Stop()
+
+	fallbackCtx, fallbackCancel := context.WithCancel(ctx)
+	defer fallbackCancel()
+
+	var primaryErr error
+	var primaryDone, fallbackDone bool
+	for {
+		select {
+		case <-fallbackTimer.C:
+			go race(fallbackCtx, fallbacks, false)
+		case result := <-results:
+			if result.err == nil {
+				return result.conn, nil
+			}
+
+			if result.primary {
+				primaryDone = true
+				primaryErr = result.err
+			} else {
+				fallbackDone = true
+			}
+
+			if primaryDone && fallbackDone {
+				return nil, primaryErr
+			}
+
+			// Fallbacks are dialed at once if the primaries fail first.
+			if result.primary && fallbackTimer.Stop() {
+				fallbackTimer.Reset(0)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestNewTransportOptions(t *testing.T) {
+	transport := NewTransport(TransportOptions{
+		MaxConnsPerHost:       8,
+		IdleConnTimeout:       time.Minute,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Second,
+	})
+
+	if transport.MaxConnsPerHost != 8 || transport.MaxIdleConnsPerHost != 8 {
+		tests.Info("Received Conns: %d max, %d idle", transport.MaxConnsPerHost, transport.MaxIdleConnsPerHost)
+		tests.Failed("Should have limited and kept idle connections per host")
+	}
+	tests.Passed("Should have limited and kept idle connections per host")
+
+	if transport.IdleConnTimeout != time.Minute || transport.TLSHandshakeTimeout != 5*time.Second {
+		tests.Info("Received Timeouts: %s idle, %s handshake", transport.IdleConnTimeout, transport.TLSHandshakeTimeout)
+		tests.Failed("Should have set idle and handshake timeouts")
+	}
+	tests.Passed("Should have set idle and handshake timeouts")
+
+	if transport.ResponseHeaderTimeout != 2*time.Second {
+		tests.Info("Received Timeout: %s", transport.ResponseHeaderTimeout)
+		tests.Failed("Should have set response header timeout")
+	}
+	tests.Passed("Should have set response header timeout")
+
+	defaults := NewTransport(TransportOptions{})
+	if defaults.IdleConnTimeout != http.DefaultTransport.(*http.Transport).IdleConnTimeout {
+		tests.Failed("Should have defaulted to timeouts of http.DefaultTransport")
+	}
+	tests.Passed("Should have defaulted to timeouts of http.DefaultTransport")
+}
+
+func TestNewTransportDisableHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	roots := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	for _, disabled := range []bool{false, true} {
+		transport := NewTransport(TransportOptions{DisableHTTP2: disabled})
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+
+		res, err := (&http.Client{Timeout: 5 * time.Second, Transport: transport}).Get(server.URL)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully requested server")
+		}
+		res.Body.Close()
+
+		if (res.ProtoMajor == 2) == disabled {
+			tests.Info("Received Protocol: %s", res.Proto)
+			tests.Failed("Should have spoken HTTP/2 only when enabled")
+		}
+	}
+	tests.Passed("Should have spoken HTTP/2 only when enabled")
+}
+
+func TestNewRoundTripperReadTimeout(t *testing.T) {
+	stall := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>"))
+		w.(http.Flusher).Flush()
+
+		if r.URL.Path == "/stalled" {
+			<-stall
+			return
+		}
+
+		for i := 0; i < 3; i++ {
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("<p>slow but steady</p>"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	client := &http.Client{Transport: NewRoundTripper(TransportOptions{ReadTimeout: 100 * time.Millisecond})}
+
+	res, err := client.Get(server.URL + "/steady")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully requested server")
+	}
+
+	if _, err := ioutil.ReadAll(res.Body); err != nil {
+		tests.FailedWithError(err, "Should have read body whose reads stay within timeout")
+	}
+	res.Body.Close()
+	tests.Passed("Should have read body whose reads stay within timeout")
+
+	res, err = client.Get(server.URL + "/stalled")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully requested server")
+	}
+	defer res.Body.Close()
+
+	if _, err := ioutil.ReadAll(res.Body); !errors.Is(err, ErrReadTimeout) {
+		tests.FailedWithError(err, "Should have abandoned stalled body")
+	}
+	tests.Passed("Should have abandoned stalled body")
+
+	if classifyError(ErrReadTimeout) != ReasonTimeout {
+		tests.Failed("Should have classified read timeout as a timeout")
+	}
+	tests.Passed("Should have classified read timeout as a timeout")
+}
+
+func TestNewTransportCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "sitecrawler-ca")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	bundle := filepath.Join(dir, "ca.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(bundle, content, 0644); err != nil {
+		tests.FailedWithError(err, "Should have successfully written ca bundle")
+	}
+
+	get := func(opts TransportOptions) error {
+		client := &http.Client{Timeout: 5 * time.Second, Transport: NewTransport(opts)}
+		res, err := client.Get(server.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(TransportOptions{}); err == nil {
+		tests.Failed("Should have failed to verify self-signed certificate")
+	}
+	tests.Passed("Should have failed to verify self-signed certificate")
+
+	if err := get(TransportOptions{InsecureSkipVerify: true}); err != nil {
+		tests.FailedWithError(err, "Should have skipped verification of certificate")
+	}
+	tests.Passed("Should have skipped verification of certificate")
+
+	pool, err := LoadCertPool(bundle)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded ca bundle")
+	}
+
+	if err := get(TransportOptions{RootCAs: pool}); err != nil {
+		tests.FailedWithError(err, "Should have verified certificate against ca bundle")
+	}
+	tests.Passed("Should have verified certificate against ca bundle")
+
+	empty := filepath.Join(dir, "empty.pem")
+	ioutil.WriteFile(empty, []byte("not a certificate"), 0644)
+
+	if _, err := LoadCertPool(empty); err == nil {
+		tests.Failed("Should have failed to load bundle without certificates")
+	}
+	tests.Passed("Should have failed to load bundle without certificates")
+}
@@ -0,0 +1,168 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrTrapSuspected is returned for links skipped as they look to lie within an
+// infinite url space, such as endless calendars or repeating relative links.
+var ErrTrapSuspected = errors.New("skipped: trap suspected")
+
+// TrapRules defines the limits beyond which links are suspected to lie within
+// a crawler trap. A zero limit leaves it's rule unchecked.
+type TrapRules struct {
+	// MaxURLLength sets the maximum characters of a link's url.
+	MaxURLLength int
+
+	// MaxSegments sets the maximum segments of a link's path.
+	MaxSegments int
+
+	// MaxRepeats sets the maximum times the same segment may occur within a
+	// link's path, such as /a/b/a/b/a/b produced by relative links.
+	MaxRepeats int
+
+	// MaxPerPattern sets the maximum distinct links of the same pattern, being
+	// the link's path with digits masked and it's query parameter names, so
+	// /calendar/2031/01 and /calendar/2032/07 share a cap.
+	MaxPerPattern int
+}
+
+// TrapDetector implements a concurrent-safe detector of crawler traps, which
+// checks links against it's rules before they're requested. Verdicts are kept
+// per link, so links seen again on other pages get the same verdict without
+// counting against their pattern again.
+type TrapDetector struct {
+	rules    TrapRules
+	ml       sync.Mutex
+	verdicts map[string]error
+	patterns map[string]int
+	trapped  int
+}
+
+// NewTrapDetector returns a new instance of a TrapDetector checking links
+// against giving rules.
+func NewTrapDetector(rules TrapRules) *TrapDetector {
+	return &TrapDetector{
+		rules:    rules,
+		verdicts: map[string]error{},
+		patterns: map[string]int{},
+	}
+}
+
+// Check returns an error of ErrTrapSuspected naming the rule broken if giving
+// link breaks any of the detector's rules, else nil.
+func (t *TrapDetector) Check(link *url.URL) error {
+	if t == nil {
+		return nil
+	}
+
+	key := seenKey(link)
+
+	t.ml.Lock()
+	defer t.ml.Unlock()
+
+	if verdict, ok := t.verdicts[key]; ok {
+		return verdict
+	}
+
+	verdict := t.rules.check(link)
+	if verdict == nil && t.rules.MaxPerPattern > 0 {
+		pattern := trapPattern(link)
+		if t.patterns[pattern] >= t.rules.MaxPerPattern {
+			verdict = fmt.Errorf("%+s: more than %d urls of pattern %+q", ErrTrapSuspected, t.rules.MaxPerPattern, pattern)
+		} else {
+			t.patterns[pattern]++
+		}
+	}
+
+	if verdict != nil {
+		t.trapped++
+	}
+
+	t.verdicts[key] = verdict
+	return verdict
+}
+
+// Trapped returns total links suspected to lie within traps.
+func (t *TrapDetector) Trapped() int {
+	if t == nil {
+		return 0
+	}
+
+	t.ml.Lock()
+	defer t.ml.Unlock()
+	return t.trapped
+}
+
+// check returns an error of ErrTrapSuspected naming the rule broken if giving
+// link breaks any of the static rules, else nil.
+func (r TrapRules) check(link *url.URL) error {
+	if r.MaxURLLength > 0 && len(link.String()) > r.MaxURLLength {
+		return fmt.Errorf("%+s: url longer than %d characters", ErrTrapSuspected, r.MaxURLLength)
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(link.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	if r.MaxSegments > 0 && len(segments) > r.MaxSegments {
+		return fmt.Errorf("%+s: path deeper than %d segments", ErrTrapSuspected, r.MaxSegments)
+	}
+
+	if r.MaxRepeats > 0 {
+		repeats := map[string]int{}
+		for _, segment := range segments {
+			repeats[segment]++
+			if repeats[segment] > r.MaxRepeats {
+				return fmt.Errorf("%+s: segment %+q repeated more than %d times", ErrTrapSuspected, segment, r.MaxRepeats)
+			}
+		}
+	}
+
+	return nil
+}
+
+// trapPattern returns the pattern of giving link, being it's host and path with
+// runs of digits masked as "N", followed by the names of it's query parameters.
+func trapPattern(link *url.URL) string {
+	var pattern strings.Builder
+	pattern.WriteString(link.Host)
+
+	var digits bool
+	for _, r := range link.Path {
+		if '0' <= r && r <= '9' {
+			if !digits {
+				pattern.WriteByte('N')
+			}
+			digits = true
+			continue
+		}
+
+		digits = false
+		pattern.WriteRune(r)
+	}
+
+	if link.RawQuery == "" {
+		return pattern.String()
+	}
+
+	var names []string
+	for _, param := range strings.Split(link.RawQuery, "&") {
+		if param != "" {
+			names = append(names, queryName(param))
+		}
+	}
+
+	sort.Strings(names)
+	pattern.WriteByte('?')
+	pattern.WriteString(strings.Join(names, "&"))
+	return pattern.String()
+}
@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+)
+
+func TestTrapDetector(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+		return link
+	}
+
+	traps := NewTrapDetector(TrapRules{MaxURLLength: 64, MaxSegments: 4, MaxRepeats: 2, MaxPerPattern: 2})
+
+	cases := []struct {
+		link    string
+		trapped bool
+	}{
+		{"http://site.com/about", false},
+		{"http://site.com/" + strings.Repeat("a", 64), true},
+		{"http://site.com/a/b/c/d/e", true},
+		{"http://site.com/a/a/a", true},
+		{"http://site.com/a/b/a", false},
+		{"http://site.com/calendar/2031/01", false},
+		{"http://site.com/calendar/2031/02", false},
+		{"http://site.com/calendar/2031/01?", false},
+		{"http://site.com/calendar/2032/07", true},
+		{"http://site.com/list?page=1", false},
+		{"http://site.com/list?page=2", false},
+		{"http://site.com/list?page=3", true},
+		{"http://site.com/list?page=3&sort=asc", false},
+	}
+
+	for _, c := range cases {
+		err := traps.Check(parse(c.link))
+		if (err != nil) != c.trapped || (err != nil && !strings.HasPrefix(err.Error(), ErrTrapSuspected.Error())) {
+			tests.Info("Received Error: %+v", err)
+			tests.Failed("Should have checked %q as trapped: %t", c.link, c.trapped)
+		}
+		tests.Passed("Should have checked %q as trapped: %t", c.link, c.trapped)
+	}
+
+	if traps.Trapped() != 5 {
+		tests.Info("Received Trapped: %d", traps.Trapped())
+		tests.Failed("Should have counted links suspected as traps")
+	}
+	tests.Passed("Should have counted links suspected as traps")
+
+	if traps.Check(parse("http://site.com/a/a/a")) == nil || traps.Trapped() != 5 {
+		tests.Failed("Should have kept verdict of link checked again")
+	}
+	tests.Passed("Should have kept verdict of link checked again")
+}
@@ -9,7 +9,8 @@ import (
 
 // errors ...
 var (
-	ErrNoMoreService = errors.New("no more service")
+	ErrNoMoreService  = errors.New("no more service")
+	ErrInvalidWorkers = errors.New("invalid total workers, expected 1 or more")
 )
 
 // WorkerPool exposes a interface which provides the definition for a pool of
@@ -18,6 +19,7 @@ type WorkerPool interface {
 	Stop()
 	WaitOnStop()
 	Add(func()) error
+	TryAdd(func()) bool
 }
 
 func NewWorkerPool(max int, ctx context.Context) WorkerPool {
@@ -79,7 +81,7 @@ func (w *workerPool) Add(fn func()) error {
 		}
 
 		w.wg.Add(1)
-		go w.lunch()
+		go w.lunch(nil)
 	}
 
 	select {
@@ -92,13 +94,48 @@ func (w *workerPool) Add(fn func()) error {
 	}
 }
 
-// lunch sets up a worker for handling worker requests.
-func (w *workerPool) lunch() {
+// TryAdd attempts to hand fn to an idle worker or a new worker if the pool
+// is not at it's maximum, without blocking. It returns false if no worker
+// was available, leaving the caller to run fn itself.
+func (w *workerPool) TryAdd(fn func()) bool {
+	var done <-chan struct{}
+	if w.ctx != nil {
+		done = w.ctx.Done()
+	}
+
+	select {
+	case <-done:
+		return false
+	case <-w.close:
+		return false
+	case w.work <- fn:
+		return true
+	default:
+	}
+
+	if int(atomic.LoadInt64(&w.totalWorkers)) >= w.max {
+		return false
+	}
+
+	w.wg.Add(1)
+	go w.lunch(fn)
+	return true
+}
+
+// lunch sets up a worker for handling worker requests, running the
+// provided first work if any before receiving more.
+func (w *workerPool) lunch(first func()) {
 	defer w.wg.Done()
 
 	atomic.AddInt64(&w.totalWorkers, 1)
 	defer atomic.AddInt64(&w.totalWorkers, -1)
 
+	if first != nil {
+		atomic.AddInt64(&w.activeWorkers, 1)
+		first()
+		atomic.AddInt64(&w.activeWorkers, -1)
+	}
+
 	var done <-chan struct{}
 
 	if w.ctx != nil {
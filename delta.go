@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// delta file names ...
+const (
+	deltaStateFile   = "urls.txt"
+	deltaAddedFile   = "added.txt"
+	deltaRemovedFile = "removed.txt"
+)
+
+// crawlDelta implements a collector of the urls of the live pages of a crawl,
+// compared against those of the previous crawl stored in the same directory,
+// listing the pages newly discovered and those which disappeared for deploy
+// notes.
+type crawlDelta struct {
+	urls map[string]bool
+}
+
+// newCrawlDelta returns a new empty crawlDelta.
+func newCrawlDelta() *crawlDelta {
+	return &crawlDelta{urls: map[string]bool{}}
+}
+
+// Observe records the url of giving page report if it's live and not
+// redirected, as listed by sitemaps.
+func (d *crawlDelta) Observe(report crawler.LinkReport) {
+	if report.Path == nil || !report.Status.IsLive || report.RedirectedTo != nil {
+		return
+	}
+	d.urls[report.Path.String()] = true
+}
+
+// URLs returns the urls recorded sorted.
+func (d *crawlDelta) URLs() []string {
+	urls := make([]string, 0, len(d.urls))
+	for link := range d.urls {
+		urls = append(urls, link)
+	}
+
+	sort.Strings(urls)
+	return urls
+}
+
+// Diff returns the urls recorded missing from giving previous urls, and the
+// previous urls no longer recorded, both sorted.
+func (d *crawlDelta) Diff(previous []string) (added []string, removed []string) {
+	known := make(map[string]bool, len(previous))
+	for _, link := range previous {
+		known[link] = true
+		if !d.urls[link] {
+			removed = append(removed, link)
+		}
+	}
+
+	for _, link := range d.URLs() {
+		if !known[link] {
+			added = append(added, link)
+		}
+	}
+
+	sort.Strings(removed)
+	return added, removed
+}
+
+// WriteDir writes the delta against the previous crawl into dir, creating it
+// if needed, as text files listing a url per line: added.txt for the pages
+// newly discovered and removed.txt for those which disappeared. The urls of
+// the crawl are then kept for the next crawl within the urls bucket of giving
+// store, else as urls.txt within dir when no store is provided. Without a
+// previous crawl only the urls are kept, it returns false and no delta is
+// written. It returns the urls added and removed.
+func (d *crawlDelta) WriteDir(dir string, store crawler.Store) (added []string, removed []string, ok bool, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, false, err
+	}
+
+	previous, ok, err := readDeltaState(dir, store)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if ok {
+		added, removed = d.Diff(previous)
+		if err := writeURLList(filepath.Join(dir, deltaAddedFile), added); err != nil {
+			return nil, nil, false, err
+		}
+
+		if err := writeURLList(filepath.Join(dir, deltaRemovedFile), removed); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	if err := writeDeltaState(dir, store, d.URLs()); err != nil {
+		return nil, nil, false, err
+	}
+	return added, removed, ok, nil
+}
+
+// readDeltaState returns the urls of the previous crawl kept within the urls
+// bucket of giving store, else within urls.txt of dir when no store is
+// provided. It returns false if no previous crawl was kept.
+func readDeltaState(dir string, store crawler.Store) ([]string, bool, error) {
+	if store == nil {
+		return readURLList(filepath.Join(dir, deltaStateFile))
+	}
+
+	var urls []string
+	err := store.Each(crawler.StoreURLs, func(link string, _ []byte) error {
+		urls = append(urls, link)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return urls, len(urls) != 0, nil
+}
+
+// writeDeltaState keeps giving urls for the next crawl within the urls bucket
+// of provided store, replacing those of the previous crawl, else within
+// urls.txt of dir when no store is provided.
+func writeDeltaState(dir string, store crawler.Store, urls []string) error {
+	if store == nil {
+		return writeURLList(filepath.Join(dir, deltaStateFile), urls)
+	}
+
+	if err := store.Clear(crawler.StoreURLs); err != nil {
+		return err
+	}
+
+	for _, link := range urls {
+		if err := store.Put(crawler.StoreURLs, link, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readURLList returns the urls listed a url per line by giving file, skipping
+// blank lines. It returns false if the file does not exist.
+func readURLList(path string) ([]string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			urls = append(urls, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+	return urls, true, nil
+}
+
+// writeURLList writes giving urls a url per line into the file at path.
+func writeURLList(path string, urls []string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	for _, link := range urls {
+		if _, err := fmt.Fprintln(buf, link); err != nil {
+			return err
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
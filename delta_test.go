@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// deltaCrawl returns the crawlDelta of a crawl of example.com finding giving
+// live paths, along with a broken and a redirected page.
+func deltaCrawl(paths ...string) *crawlDelta {
+	delta := newCrawlDelta()
+	for _, path := range paths {
+		delta.Observe(crawler.LinkReport{
+			Path:   &url.URL{Scheme: "https", Host: "example.com", Path: path},
+			Status: crawler.Status{IsLive: true, LastStatus: 200},
+		})
+	}
+
+	delta.Observe(crawler.LinkReport{
+		Path:   &url.URL{Scheme: "https", Host: "example.com", Path: "/gone"},
+		Status: crawler.Status{LastStatus: 404},
+	})
+	delta.Observe(crawler.LinkReport{
+		Path:         &url.URL{Scheme: "https", Host: "example.com", Path: "/old"},
+		Status:       crawler.Status{IsLive: true, LastStatus: 200},
+		RedirectedTo: &url.URL{Scheme: "https", Host: "example.com", Path: "/new"},
+	})
+	return delta
+}
+
+func TestCrawlDelta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawl-delta")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	deltaDir := filepath.Join(dir, "delta")
+
+	_, _, ok, err := deltaCrawl("/", "/about", "/jobs").WriteDir(deltaDir, nil)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully written delta")
+	}
+	tests.Passed("Should have successfully written delta")
+
+	if ok {
+		tests.Failed("Should have written no delta without a previous crawl")
+	}
+	tests.Passed("Should have written no delta without a previous crawl")
+
+	if _, err := os.Stat(filepath.Join(deltaDir, deltaAddedFile)); !os.IsNotExist(err) {
+		tests.Failed("Should have written no added urls without a previous crawl")
+	}
+	tests.Passed("Should have written no added urls without a previous crawl")
+
+	added, removed, ok, err := deltaCrawl("/", "/about", "/blog", "/help").WriteDir(deltaDir, nil)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully written delta")
+	}
+	tests.Passed("Should have successfully written delta")
+
+	if !ok || len(added) != 2 || added[0] != "https://example.com/blog" || added[1] != "https://example.com/help" {
+		tests.Info("Received: %#v", added)
+		tests.Failed("Should have listed urls newly discovered")
+	}
+	tests.Passed("Should have listed urls newly discovered")
+
+	if len(removed) != 1 || removed[0] != "https://example.com/jobs" {
+		tests.Info("Received: %#v", removed)
+		tests.Failed("Should have listed urls which disappeared")
+	}
+	tests.Passed("Should have listed urls which disappeared")
+
+	for name, expected := range map[string]string{
+		deltaAddedFile:   "https://example.com/blog\nhttps://example.com/help\n",
+		deltaRemovedFile: "https://example.com/jobs\n",
+		deltaStateFile:   "https://example.com/\nhttps://example.com/about\nhttps://example.com/blog\nhttps://example.com/help\n",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(deltaDir, name))
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully read %s", name)
+		}
+
+		if string(content) != expected {
+			tests.Info("File: %s, Received: %q", name, content)
+			tests.Failed("Should have written a url per line")
+		}
+	}
+	tests.Passed("Should have written a url per line")
+
+	if previous, _, _ := readURLList(filepath.Join(deltaDir, deltaStateFile)); strings.Join(previous, ",") != "https://example.com/,https://example.com/about,https://example.com/blog,https://example.com/help" {
+		tests.Info("Received: %#v", previous)
+		tests.Failed("Should have stored urls of the crawl for the next delta")
+	}
+	tests.Passed("Should have stored urls of the crawl for the next delta")
+}
+
+func TestCrawlDeltaStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawl-delta")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := crawler.NewMemoryStore()
+
+	if _, _, ok, err := deltaCrawl("/", "/about", "/jobs").WriteDir(dir, store); err != nil || ok {
+		tests.Info("Delta: %t, Error: %+s", ok, err)
+		tests.Failed("Should have written no delta without a previous crawl in store")
+	}
+	tests.Passed("Should have written no delta without a previous crawl in store")
+
+	added, removed, ok, err := deltaCrawl("/", "/blog").WriteDir(dir, store)
+	if err != nil || !ok || strings.Join(added, ",") != "https://example.com/blog" || strings.Join(removed, ",") != "https://example.com/about,https://example.com/jobs" {
+		tests.Info("Added: %q, Removed: %q, Error: %+s", added, removed, err)
+		tests.Failed("Should have listed delta against the previous crawl in store")
+	}
+	tests.Passed("Should have listed delta against the previous crawl in store")
+
+	if previous, _, _ := readDeltaState(dir, store); strings.Join(previous, ",") != "https://example.com/,https://example.com/blog" {
+		tests.Info("Received: %#v", previous)
+		tests.Failed("Should have replaced urls of the previous crawl in store")
+	}
+	tests.Passed("Should have replaced urls of the previous crawl in store")
+
+	if _, err := os.Stat(filepath.Join(dir, deltaStateFile)); !os.IsNotExist(err) {
+		tests.Failed("Should have kept urls of the crawl in store in place of the state file")
+	}
+	tests.Passed("Should have kept urls of the crawl in store in place of the state file")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// canonicalClusters embodies the live pages of a crawl grouped by the canonical
+// url they declare through <link rel="canonical">, so duplicate content served
+// under many urls stands out as one cluster per canonical url.
+type canonicalClusters struct {
+	urls map[string][]string
+	seen map[string]bool
+}
+
+// canonicalCluster embodies a canonical url and the urls of the other pages
+// declaring it, which duplicate it's content.
+type canonicalCluster struct {
+	Canonical string   `json:"canonical"`
+	URLs      []string `json:"urls"`
+}
+
+// newCanonicalClusters returns a new empty canonicalClusters.
+func newCanonicalClusters() *canonicalClusters {
+	return &canonicalClusters{urls: map[string][]string{}, seen: map[string]bool{}}
+}
+
+// Observe records the canonical url declared by giving page report if it
+// points elsewhere, once per page.
+func (c *canonicalClusters) Observe(report crawler.LinkReport) {
+	if !report.NonCanonical || report.Canonical == nil || !report.Status.IsLive {
+		return
+	}
+
+	page := report.Path.String()
+	if c.seen[page] {
+		return
+	}
+	c.seen[page] = true
+
+	canonical := report.Canonical.String()
+	c.urls[canonical] = append(c.urls[canonical], page)
+}
+
+// Clusters returns the canonical urls declared by other pages with the urls of
+// those pages sorted, largest clusters first.
+func (c *canonicalClusters) Clusters() []canonicalCluster {
+	clusters := make([]canonicalCluster, 0, len(c.urls))
+	for canonical, urls := range c.urls {
+		sorted := append([]string(nil), urls...)
+		sort.Strings(sorted)
+		clusters = append(clusters, canonicalCluster{Canonical: canonical, URLs: sorted})
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].URLs) != len(clusters[j].URLs) {
+			return len(clusters[i].URLs) > len(clusters[j].URLs)
+		}
+		return clusters[i].Canonical < clusters[j].Canonical
+	})
+	return clusters
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestCanonicalClusters(t *testing.T) {
+	page := func(path string, canonical string) crawler.LinkReport {
+		report := crawler.LinkReport{
+			Path:   &url.URL{Scheme: "https", Host: "example.com", Path: path},
+			Status: crawler.Status{IsLive: true},
+		}
+
+		if canonical != "" {
+			report.Canonical = &url.URL{Scheme: "https", Host: "example.com", Path: canonical}
+			report.NonCanonical = path != canonical
+		}
+		return report
+	}
+
+	clusters := newCanonicalClusters()
+	clusters.Observe(page("/shoes", "/shoes"))
+	clusters.Observe(page("/shoes/red", "/shoes"))
+	clusters.Observe(page("/shoes/blue", "/shoes"))
+	clusters.Observe(page("/shoes/blue", "/shoes"))
+	clusters.Observe(page("/print/about", "/about"))
+	clusters.Observe(page("/contact", ""))
+
+	found := clusters.Clusters()
+	if len(found) != 2 {
+		tests.Info("Received: %#v", found)
+		tests.Failed("Should have grouped pages by the canonical url declared")
+	}
+	tests.Passed("Should have grouped pages by the canonical url declared")
+
+	if found[0].Canonical != "https://example.com/shoes" || len(found[0].URLs) != 2 || found[0].URLs[0] != "https://example.com/shoes/blue" {
+		tests.Info("Received: %#v", found[0])
+		tests.Failed("Should have listed largest cluster first with it's urls sorted")
+	}
+	tests.Passed("Should have listed largest cluster first with it's urls sorted")
+
+	if found[1].Canonical != "https://example.com/about" || len(found[1].URLs) != 1 {
+		tests.Info("Received: %#v", found[1])
+		tests.Failed("Should have listed pages canonicalizing elsewhere")
+	}
+	tests.Passed("Should have listed pages canonicalizing elsewhere")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// statusLink embodies a url reported by a crawl with the status it answered
+// with. Redirects hold the url the redirect leads to and the final status
+// reached there.
+type statusLink struct {
+	URL         string
+	Target      string
+	FinalStatus int
+}
+
+// statusExport implements a collector of all urls reported by a crawl grouped
+// by the status code they first answered with, for fix-it workflows starting
+// from a list of a status's urls rather than the full reports.
+type statusExport struct {
+	codes map[int]map[string]statusLink
+}
+
+// newStatusExport returns a new empty statusExport.
+func newStatusExport() *statusExport {
+	return &statusExport{codes: map[int]map[string]statusLink{}}
+}
+
+// Observe records giving page report and the links it points to under their
+// status codes. Links which never answered are skipped.
+func (s *statusExport) Observe(report crawler.LinkReport) {
+	s.add(report)
+	for _, kid := range report.PointsTo {
+		s.add(kid)
+	}
+}
+
+// add records the url of giving report under the status it first answered
+// with, which for followed redirects is the status of it's first hop.
+func (s *statusExport) add(report crawler.LinkReport) {
+	if report.Path == nil {
+		return
+	}
+
+	link := statusLink{URL: report.Path.String(), FinalStatus: report.Status.LastStatus}
+	code := report.Status.LastStatus
+	if len(report.Redirects) != 0 {
+		code = report.Redirects[0].Status
+	}
+
+	if code == 0 {
+		return
+	}
+
+	if report.RedirectedTo != nil {
+		link.Target = report.RedirectedTo.String()
+	}
+
+	links, ok := s.codes[code]
+	if !ok {
+		links = map[string]statusLink{}
+		s.codes[code] = links
+	}
+	links[link.URL] = link
+}
+
+// Codes returns all status codes recorded, in ascending order.
+func (s *statusExport) Codes() []int {
+	codes := make([]int, 0, len(s.codes))
+	for code := range s.codes {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+	return codes
+}
+
+// Links returns the links recorded under giving status code, ordered by url.
+func (s *statusExport) Links(code int) []statusLink {
+	links := make([]statusLink, 0, len(s.codes[code]))
+	for _, link := range s.codes[code] {
+		links = append(links, link)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].URL < links[j].URL
+	})
+	return links
+}
+
+// WriteDir writes a file per status code recorded into dir, creating it if
+// needed. Redirects are written as csv files of their urls, targets and final
+// statuses, such as 301.csv, all other codes as text files listing a url per
+// line, such as 404.txt. It returns the paths of the files written.
+func (s *statusExport) WriteDir(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, code := range s.Codes() {
+		path := filepath.Join(dir, statusFileName(code))
+		if err := s.writeFile(path, code); err != nil {
+			return files, err
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// writeFile writes the links of giving status code into the file at path.
+func (s *statusExport) writeFile(path string, code int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buf := bufio.NewWriter(file)
+	if isRedirectStatus(code) {
+		records := csv.NewWriter(buf)
+		if err := records.Write([]string{"url", "target", "final_status"}); err != nil {
+			return err
+		}
+
+		for _, link := range s.Links(code) {
+			if err := records.Write([]string{link.URL, link.Target, strconv.Itoa(link.FinalStatus)}); err != nil {
+				return err
+			}
+		}
+
+		records.Flush()
+		if err := records.Error(); err != nil {
+			return err
+		}
+	} else {
+		for _, link := range s.Links(code) {
+			if _, err := fmt.Fprintln(buf, link.URL); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// statusFileName returns the name of the file listing urls of giving status
+// code.
+func statusFileName(code int) string {
+	if isRedirectStatus(code) {
+		return strconv.Itoa(code) + ".csv"
+	}
+	return strconv.Itoa(code) + ".txt"
+}
+
+// isRedirectStatus returns true if giving status code is a redirect.
+func isRedirectStatus(code int) bool {
+	return code >= 300 && code <= 399
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestStatusExport(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+		return link
+	}
+
+	statuses := newStatusExport()
+	statuses.Observe(crawler.LinkReport{
+		Path:   parse("http://example.com/"),
+		Status: crawler.Status{IsLive: true, LastStatus: 200},
+		PointsTo: []crawler.LinkReport{
+			{Path: parse("http://example.com/gone"), Status: crawler.Status{LastStatus: 404}},
+			{Path: parse("http://example.com/missing"), Status: crawler.Status{LastStatus: 404}},
+			{Path: parse("http://example.com/error"), Status: crawler.Status{LastStatus: 500}},
+			{
+				Path:         parse("http://example.com/old"),
+				Status:       crawler.Status{IsLive: true, LastStatus: 200},
+				RedirectedTo: parse("http://example.com/new"),
+				Redirects:    []crawler.RedirectHop{{URL: "http://example.com/old", Status: 301}},
+			},
+			{Path: parse("http://example.com/unreachable")},
+		},
+	})
+	statuses.Observe(crawler.LinkReport{
+		Path:     parse("http://example.com/gone"),
+		Status:   crawler.Status{LastStatus: 404},
+		PointsTo: []crawler.LinkReport{},
+	})
+
+	if codes := statuses.Codes(); len(codes) != 4 || codes[0] != 200 || codes[1] != 301 || codes[2] != 404 || codes[3] != 500 {
+		tests.Info("Received Codes: %#v", codes)
+		tests.Failed("Should have grouped urls by first status answered")
+	}
+	tests.Passed("Should have grouped urls by first status answered")
+
+	dir, err := ioutil.TempDir("", "status-export")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	files, err := statuses.WriteDir(filepath.Join(dir, "status"))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully written status files")
+	}
+	tests.Passed("Should have successfully written status files")
+
+	if len(files) != 4 {
+		tests.Info("Received Files: %#v", files)
+		tests.Failed("Should have written a file per status code")
+	}
+	tests.Passed("Should have written a file per status code")
+
+	notFound, err := ioutil.ReadFile(filepath.Join(dir, "status", "404.txt"))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read 404.txt")
+	}
+
+	if string(notFound) != "http://example.com/gone\nhttp://example.com/missing\n" {
+		tests.Info("Received 404.txt: %q", notFound)
+		tests.Failed("Should have listed each url answering 404 once")
+	}
+	tests.Passed("Should have listed each url answering 404 once")
+
+	moved, err := ioutil.ReadFile(filepath.Join(dir, "status", "301.csv"))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read 301.csv")
+	}
+
+	if string(moved) != "url,target,final_status\nhttp://example.com/old,http://example.com/new,200\n" {
+		tests.Info("Received 301.csv: %q", moved)
+		tests.Failed("Should have listed redirects with their targets")
+	}
+	tests.Passed("Should have listed redirects with their targets")
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/influx6/sitecrawler/crawler"
+	"github.com/klauspost/compress/zstd"
+)
+
+// errors ...
+var (
+	ErrUnknownFormat      = errors.New("unknown output format")
+	ErrUnknownCompression = errors.New("unknown output compression")
+	ErrUnknownSplit       = errors.New("unknown output split, expected host, prefix, owner or language")
+	ErrSplitLanguage      = errors.New("split by language can't be combined with another output split")
+)
+
+// formatExtension returns the file extension for giving format and compression.
+func formatExtension(format string, compression string) string {
+	ext := ".xml"
+	switch strings.ToLower(format) {
+	case "ndjson":
+		ext = ".ndjson"
+	case "parquet":
+		ext = ".parquet"
+	}
+
+	switch strings.ToLower(compression) {
+	case "gzip", "gz":
+		ext += ".gz"
+	case "zstd", "zst":
+		ext += ".zst"
+	}
+
+	return ext
+}
+
+// splitKey returns a function which returns the key used to split reports into
+// separate outputs for giving split mode.
+func splitKey(split string) (func(crawler.LinkReport) string, error) {
+	switch strings.ToLower(split) {
+	case "host":
+		return func(report crawler.LinkReport) string {
+			return report.Path.Host
+		}, nil
+	case "prefix":
+		return func(report crawler.LinkReport) string {
+			section := strings.SplitN(strings.TrimPrefix(report.Path.Path, "/"), "/", 2)[0]
+			if section == "" {
+				return "root"
+			}
+			return section
+		}, nil
+	case "owner":
+		return func(report crawler.LinkReport) string {
+			if report.Owner == "" {
+				return "unowned"
+			}
+			return report.Owner
+		}, nil
+	case "language":
+		return func(report crawler.LinkReport) string {
+			return "sitemap-" + languageTag(report.Language)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%+s: %+q", ErrUnknownSplit, split)
+}
+
+// languageTag returns giving language lowercased as a BCP 47 tag, else "und"
+// for pages of undetermined language.
+func languageTag(language string) string {
+	language = strings.ToLower(strings.Replace(strings.TrimSpace(language), "_", "-", -1))
+	if language == "" {
+		return "und"
+	}
+	return language
+}
+
+// splitWriter implements the ReportWriter which splits reports into separate
+// files within a directory, based on a key retrieved from each report.
+type splitWriter struct {
+	dir         string
+	format      string
+	compression string
+	key         func(crawler.LinkReport) string
+	files       map[string]*splitFile
+	written     []string
+}
+
+// splitFile embodies the open file and writers for a giving split key.
+type splitFile struct {
+	file       *os.File
+	compressor io.WriteCloser
+	writer     ReportWriter
+}
+
+// newSplitWriter returns a new splitWriter writing reports files into dir.
+func newSplitWriter(split string, dir string, format string, compression string) (*splitWriter, error) {
+	key, err := splitKey(split)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &splitWriter{
+		dir:         dir,
+		key:         key,
+		format:      format,
+		compression: compression,
+		files:       map[string]*splitFile{},
+	}, nil
+}
+
+// Write delivers giving report to the file of it's split key.
+func (s *splitWriter) Write(report crawler.LinkReport) error {
+	key := sanitizeFileName(s.key(report))
+
+	split, ok := s.files[key]
+	if !ok {
+		path := filepath.Join(s.dir, key+formatExtension(s.format, s.compression))
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+
+		compressor, err := newCompressor(s.compression, file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		writer, err := newReportWriter(s.format, compressor)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		split = &splitFile{file: file, compressor: compressor, writer: writer}
+		s.files[key] = split
+		s.written = append(s.written, path)
+	}
+
+	return split.writer.Write(report)
+}
+
+// Flush flushes and closes all split files.
+func (s *splitWriter) Flush() error {
+	var firstErr error
+	for key, split := range s.files {
+		if err := split.writer.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		if err := split.compressor.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		if err := split.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		delete(s.files, key)
+	}
+	return firstErr
+}
+
+// Files returns the paths of all split files written, ordered by path.
+func (s *splitWriter) Files() []string {
+	files := append([]string(nil), s.written...)
+	sort.Strings(files)
+	return files
+}
+
+// sanitizeFileName replaces characters of giving name which are unsafe for
+// use as a file name.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// newCompressor returns a io.WriteCloser which compresses all written data with
+// the giving compression before writing into w. Closing the returned writer
+// flushes the compressor but does not close w.
+func newCompressor(compression string, w io.Writer) (io.WriteCloser, error) {
+	switch strings.ToLower(compression) {
+	case "", "none":
+		return nopWriteCloser{Writer: w}, nil
+	case "gzip", "gz":
+		return gzip.NewWriter(w), nil
+	case "zstd", "zst":
+		return zstd.NewWriter(w)
+	}
+
+	return nil, fmt.Errorf("%+s: %+q", ErrUnknownCompression, compression)
+}
+
+// nopWriteCloser implements a io.WriteCloser whose Close does nothing.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements the io.Closer interface.
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// ReportWriter defines the contract for writing received crawl reports
+// into a giving output format.
+type ReportWriter interface {
+	Write(crawler.LinkReport) error
+	Flush() error
+}
+
+// newReportWriter returns a ReportWriter for the giving format name which
+// writes into the provided writer.
+func newReportWriter(format string, w io.Writer) (ReportWriter, error) {
+	switch strings.ToLower(format) {
+	case "", "xml":
+		return &sitemapWriter{w: w}, nil
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "parquet":
+		return newParquetWriter(w)
+	}
+
+	return nil, fmt.Errorf("%+s: %+q", ErrUnknownFormat, format)
+}
+
+// sitemapWriter implements the ReportWriter for the xml sitemap format, it
+// collects all records till Flush is called.
+type sitemapWriter struct {
+	w       io.Writer
+	buf     bytes.Buffer
+	records []string
+}
+
+// Write renders giving report into a sitemap url record.
+func (s *sitemapWriter) Write(report crawler.LinkReport) error {
+	s.buf.Reset()
+
+	if err := urlTemplate.Execute(&s.buf, report); err != nil {
+		return fmt.Errorf("parseError:  %+s", err)
+	}
+
+	s.records = append(s.records, s.buf.String())
+	return nil
+}
+
+// Flush writes the sitemap document with all collected records.
+func (s *sitemapWriter) Flush() error {
+	_, err := fmt.Fprintf(s.w, sitemapTemplate, strings.Join(s.records, ""))
+	return err
+}
+
+// sitemapFuncs holds the functions used by the sitemap templates to encode
+// urls and text per the sitemaps spec.
+var sitemapFuncs = template.FuncMap{
+	"loc":       sitemapLoc,
+	"locString": sitemapLocString,
+	"xml":       xmlEscape,
+}
+
+// sitemapLoc returns giving url percent-encoded and xml escaped for use as a
+// sitemap location.
+func sitemapLoc(u *url.URL) string {
+	return xmlEscape(encodeURL(u))
+}
+
+// sitemapLocString returns giving raw url percent-encoded and xml escaped for
+// use as a sitemap location.
+func sitemapLocString(raw string) string {
+	return xmlEscape(escapeURL(raw))
+}
+
+// encodeURL returns giving url percent-encoded per RFC 3986, as the query of
+// parsed urls is kept as found, with it's spaces and unicode characters.
+func encodeURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return escapeURL(u.String())
+}
+
+// escapeURL percent-encodes all bytes of giving url not allowed by RFC 3986,
+// leaving existing escapes as they are, so encoding is idempotent.
+func escapeURL(raw string) string {
+	var encoded strings.Builder
+	for index := 0; index < len(raw); index++ {
+		c := raw[index]
+		if c == '%' && index+2 < len(raw) && isHex(raw[index+1]) && isHex(raw[index+2]) {
+			encoded.WriteByte(c)
+			continue
+		}
+
+		if c != '%' && isURLByte(c) {
+			encoded.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&encoded, "%%%02X", c)
+	}
+	return encoded.String()
+}
+
+// isURLByte returns true if giving byte is an unreserved or reserved character
+// of RFC 3986.
+func isURLByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~:/?#[]@!$&'()*+,;=", c) != -1
+}
+
+// isHex returns true if giving byte is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// xmlEscape returns giving text escaped for use within xml elements and
+// attributes, replacing characters xml does not allow.
+func xmlEscape(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
+
+// reportRow embodies the flat row written per url for line based formats.
+type reportRow struct {
+	URL             string                    `json:"url"`
+	Status          crawler.Status            `json:"status"`
+	Title           string                    `json:"title,omitempty"`
+	Language        string                    `json:"language,omitempty"`
+	Direction       string                    `json:"direction,omitempty"`
+	ContentType     string                    `json:"content_type,omitempty"`
+	SniffedType     string                    `json:"sniffed_type,omitempty"`
+	ContentLength   int64                     `json:"content_length"`
+	LatencyMS       float64                   `json:"latency_ms"`
+	Owner           string                    `json:"owner,omitempty"`
+	Redirects       []crawler.RedirectHop     `json:"redirects,omitempty"`
+	LongRedirect    bool                      `json:"long_redirect,omitempty"`
+	Cached          bool                      `json:"cached,omitempty"`
+	Truncated       bool                      `json:"truncated,omitempty"`
+	ContentEncoding string                    `json:"content_encoding,omitempty"`
+	EncodedSize     int64                     `json:"encoded_size,omitempty"`
+	DecodedSize     int64                     `json:"decoded_size,omitempty"`
+	RemoteIP        string                    `json:"remote_ip,omitempty"`
+	IPFamily        crawler.IPFamily          `json:"ip_family,omitempty"`
+	ASN             int                       `json:"asn,omitempty"`
+	ASOrg           string                    `json:"as_org,omitempty"`
+	Server          string                    `json:"server,omitempty"`
+	Robots          *crawler.RobotsDirectives `json:"robots,omitempty"`
+	ParseWarnings   []string                  `json:"parse_warnings,omitempty"`
+	RouteError      string                    `json:"route_error,omitempty"`
+	Canonical       string                    `json:"canonical,omitempty"`
+	NonCanonical    bool                      `json:"canonical_mismatch,omitempty"`
+	Alternates      []alternateRow            `json:"alternates,omitempty"`
+	Social          *crawler.SocialTags       `json:"social,omitempty"`
+	Structured      *crawler.StructuredData   `json:"structured_data,omitempty"`
+	CacheBefore     crawler.CacheStatus       `json:"cache_before,omitempty"`
+	CacheAfter      crawler.CacheStatus       `json:"cache_after,omitempty"`
+	Metadata        crawler.Metadata          `json:"metadata,omitempty"`
+	Outlinks        []outlinkRow              `json:"outlinks"`
+}
+
+// alternateRow embodies a localized version of the page of a reportRow.
+type alternateRow struct {
+	Hreflang string `json:"hreflang"`
+	URL      string `json:"url"`
+}
+
+// outlinkRow embodies a nested outgoing link of a reportRow.
+type outlinkRow struct {
+	URL          string                `json:"url"`
+	Status       crawler.Status        `json:"status"`
+	Owner        string                `json:"owner,omitempty"`
+	Position     crawler.LinkPosition  `json:"position,omitempty"`
+	Rel          []string              `json:"rel,omitempty"`
+	AnchorText   string                `json:"anchor_text,omitempty"`
+	Heading      string                `json:"heading,omitempty"`
+	Origin       string                `json:"origin,omitempty"`
+	RedirectedTo string                `json:"redirected_to,omitempty"`
+	Redirects    []crawler.RedirectHop `json:"redirects,omitempty"`
+	LongRedirect bool                  `json:"long_redirect,omitempty"`
+	Stripped     []string              `json:"stripped_params,omitempty"`
+	Stylesheet   string                `json:"stylesheet,omitempty"`
+	Feed         string                `json:"feed,omitempty"`
+	JSON         string                `json:"json,omitempty"`
+	CacheBefore  crawler.CacheStatus   `json:"cache_before,omitempty"`
+	CacheAfter   crawler.CacheStatus   `json:"cache_after,omitempty"`
+}
+
+// newReportRow returns a reportRow for giving report.
+func newReportRow(report crawler.LinkReport) reportRow {
+	row := reportRow{
+		URL:             encodeURL(report.Path),
+		Status:          report.Status,
+		Title:           report.Title,
+		Language:        report.Language,
+		Direction:       report.Direction,
+		ContentType:     report.ContentType,
+		SniffedType:     report.SniffedType,
+		ContentLength:   report.ContentLength,
+		LatencyMS:       report.Latency.Seconds() * 1000,
+		Owner:           report.Owner,
+		Redirects:       encodeHops(report.Redirects),
+		LongRedirect:    report.LongRedirect,
+		Cached:          report.Cached,
+		Truncated:       report.Truncated,
+		ContentEncoding: report.ContentEncoding,
+		EncodedSize:     report.EncodedSize,
+		DecodedSize:     report.DecodedSize,
+		RemoteIP:        report.RemoteIP,
+		IPFamily:        report.IPFamily,
+		ASN:             report.ASN,
+		ASOrg:           report.ASOrg,
+		Server:          report.Server,
+		Robots:          report.Robots,
+		ParseWarnings:   report.ParseWarnings,
+		RouteError:      report.RouteError,
+		NonCanonical:    report.NonCanonical,
+		CacheBefore:     report.CacheBefore,
+		CacheAfter:      report.CacheAfter,
+		Social:          report.Social,
+		Structured:      report.Structured,
+		Metadata:        report.Metadata,
+		Outlinks:        make([]outlinkRow, 0, len(report.PointsTo)),
+	}
+
+	if report.Canonical != nil {
+		row.Canonical = encodeURL(report.Canonical)
+	}
+
+	for _, alternate := range report.Alternates {
+		row.Alternates = append(row.Alternates, alternateRow{Hreflang: alternate.Hreflang, URL: encodeURL(alternate.URL)})
+	}
+
+	for _, kid := range report.PointsTo {
+		outlink := outlinkRow{
+			URL:          encodeURL(kid.Path),
+			Status:       kid.Status,
+			Owner:        kid.Owner,
+			Position:     kid.Position,
+			Rel:          kid.Rel,
+			AnchorText:   kid.AnchorText,
+			Heading:      kid.Heading,
+			Origin:       kid.Origin,
+			Redirects:    encodeHops(kid.Redirects),
+			LongRedirect: kid.LongRedirect,
+			Stripped:     kid.Stripped,
+			Stylesheet:   kid.Stylesheet,
+			Feed:         kid.Feed,
+			JSON:         kid.JSON,
+			CacheBefore:  kid.CacheBefore,
+			CacheAfter:   kid.CacheAfter,
+		}
+
+		if kid.RedirectedTo != nil {
+			outlink.RedirectedTo = encodeURL(kid.RedirectedTo)
+		}
+
+		row.Outlinks = append(row.Outlinks, outlink)
+	}
+
+	return row
+}
+
+// encodeHops returns a copy of giving redirect hops with their urls percent-encoded.
+func encodeHops(hops []crawler.RedirectHop) []crawler.RedirectHop {
+	if hops == nil {
+		return nil
+	}
+
+	encoded := make([]crawler.RedirectHop, len(hops))
+	for index, hop := range hops {
+		encoded[index] = crawler.RedirectHop{URL: escapeURL(hop.URL), Status: hop.Status}
+	}
+	return encoded
+}
+
+// ndjsonWriter implements the ReportWriter for newline delimited json, writing
+// one row per url with it's outgoing links nested within.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+// Write encodes giving report as a single json line.
+func (n *ndjsonWriter) Write(report crawler.LinkReport) error {
+	return n.enc.Encode(newReportRow(report))
+}
+
+// Flush does nothing as rows are written immediately.
+func (n *ndjsonWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// nastyURLs holds urls with characters which must be encoded in sitemaps,
+// mapped to their expected encoded location.
+var nastyURLs = map[string]string{
+	"http://example.com/":                          "http://example.com/",
+	"http://example.com/a b/c":                     "http://example.com/a%20b/c",
+	"http://example.com/café":                      "http://example.com/caf%C3%A9",
+	"http://exämple.com/":                          "http://ex%C3%A4mple.com/",
+	"http://example.com/日本?q=語":                    "http://example.com/%E6%97%A5%E6%9C%AC?q=%E8%AA%9E",
+	"http://example.com/search?q=a b&lang=en":      "http://example.com/search?q=a%20b&lang=en",
+	"http://example.com/?q=<script>\"x\"</script>": "http://example.com/?q=%3Cscript%3E%22x%22%3C/script%3E",
+	"http://example.com/it's":                      "http://example.com/it's",
+	"http://example.com/a|b^c{d}":                  "http://example.com/a%7Cb%5Ec%7Bd%7D",
+	"http://example.com/100%25?p=50%":              "http://example.com/100%25?p=50%25",
+	"http://example.com/page#frag ment":            "http://example.com/page#frag%20ment",
+}
+
+func TestEscapeURLIsIdempotent(t *testing.T) {
+	for raw, expected := range nastyURLs {
+		if escaped := escapeURL(expected); escaped != expected {
+			tests.Info("Received URL: %q", escaped)
+			tests.Failed("Should have left encoded url %q as is", raw)
+		}
+	}
+	tests.Passed("Should have left encoded urls as is")
+}
+
+func TestSitemapWriterEncodesURLs(t *testing.T) {
+	for raw, expected := range nastyURLs {
+		target, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+
+		var out bytes.Buffer
+		writer, _ := newReportWriter("xml", &out)
+		if err := writer.Write(crawler.LinkReport{Path: target, PointsTo: []crawler.LinkReport{{Path: target}}}); err != nil {
+			tests.FailedWithError(err, "Should have successfully written report of %q", raw)
+		}
+
+		if err := writer.Flush(); err != nil {
+			tests.FailedWithError(err, "Should have successfully flushed sitemap of %q", raw)
+		}
+
+		var sitemap struct {
+			URLs []struct {
+				Loc   string   `xml:"loc"`
+				Links []string `xml:"connects>link"`
+			} `xml:"url"`
+		}
+
+		if err := xml.Unmarshal(out.Bytes(), &sitemap); err != nil {
+			tests.Info("Received Sitemap: %s", out.String())
+			tests.FailedWithError(err, "Should have written well formed xml for %q", raw)
+		}
+
+		if len(sitemap.URLs) != 1 || sitemap.URLs[0].Loc != expected {
+			tests.Info("Received Sitemap: %s", out.String())
+			tests.Failed("Should have percent-encoded location of %q", raw)
+		}
+
+		if len(sitemap.URLs[0].Links) != 1 || sitemap.URLs[0].Links[0] != expected {
+			tests.Info("Received Sitemap: %s", out.String())
+			tests.Failed("Should have percent-encoded link of %q", raw)
+		}
+	}
+	tests.Passed("Should have percent-encoded and xml escaped all urls")
+}
+
+func TestNDJSONWriterEncodesURLs(t *testing.T) {
+	for raw, expected := range nastyURLs {
+		target, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+
+		var out bytes.Buffer
+		writer, _ := newReportWriter("ndjson", &out)
+		if err := writer.Write(crawler.LinkReport{Path: target, PointsTo: []crawler.LinkReport{{Path: target}}}); err != nil {
+			tests.FailedWithError(err, "Should have successfully written report of %q", raw)
+		}
+
+		var row reportRow
+		if err := json.Unmarshal(out.Bytes(), &row); err != nil {
+			tests.FailedWithError(err, "Should have written valid json for %q", raw)
+		}
+
+		if row.URL != expected || len(row.Outlinks) != 1 || row.Outlinks[0].URL != expected {
+			tests.Info("Received Row: %s", out.String())
+			tests.Failed("Should have percent-encoded urls of %q", raw)
+		}
+	}
+	tests.Passed("Should have percent-encoded all urls")
+}
+
+func TestZstdCompressor(t *testing.T) {
+	if ext := formatExtension("ndjson", "zstd"); ext != ".ndjson.zst" {
+		tests.Info("Received Extension: %q", ext)
+		tests.Failed("Should have suffixed zstd outputs with .zst")
+	}
+	tests.Passed("Should have suffixed zstd outputs with .zst")
+
+	var out bytes.Buffer
+	compressor, err := newCompressor("zstd", &out)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created zstd compressor")
+	}
+	tests.Passed("Should have successfully created zstd compressor")
+
+	writer, _ := newReportWriter("ndjson", compressor)
+	for _, path := range []string{"/", "/about", "/gone"} {
+		report := crawler.LinkReport{
+			Path:   &url.URL{Scheme: "https", Host: "example.com", Path: path},
+			Status: crawler.Status{IsLive: path != "/gone", LastStatus: 200},
+		}
+		if err := writer.Write(report); err != nil {
+			tests.FailedWithError(err, "Should have successfully written report")
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		tests.FailedWithError(err, "Should have successfully flushed reports")
+	}
+
+	if err := compressor.Close(); err != nil {
+		tests.FailedWithError(err, "Should have successfully closed zstd compressor")
+	}
+	tests.Passed("Should have successfully written reports through zstd")
+
+	if !bytes.HasPrefix(out.Bytes(), zstdMagic) {
+		tests.Failed("Should have written a zstd frame")
+	}
+	tests.Passed("Should have written a zstd frame")
+
+	crawl, err := loadCrawl(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully loaded zstd compressed reports")
+	}
+
+	if len(crawl.Reports) != 3 || crawl.Reports[1].Path.Path != "/about" || crawl.Reports[2].Status.IsLive {
+		tests.Info("Received Reports: %#v", crawl.Reports)
+		tests.Failed("Should have restored reports from zstd compressed ndjson")
+	}
+	tests.Passed("Should have restored reports from zstd compressed ndjson")
+}
+
+func TestSplitWriterByLanguage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "split-language")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	writer, err := newSplitWriter("language", dir, "xml", "none")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created split writer")
+	}
+	tests.Passed("Should have successfully created split writer")
+
+	for raw, language := range map[string]string{
+		"http://example.com/":      "en",
+		"http://example.com/about": "EN",
+		"http://example.com/de/":   "de",
+		"http://example.com/blob":  "",
+	} {
+		target, _ := url.Parse(raw)
+		if err := writer.Write(crawler.LinkReport{Path: target, Language: language}); err != nil {
+			tests.FailedWithError(err, "Should have successfully written report of %q", raw)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		tests.FailedWithError(err, "Should have successfully flushed split files")
+	}
+
+	files := writer.Files()
+	expected := []string{"sitemap-de.xml", "sitemap-en.xml", "sitemap-und.xml"}
+	if len(files) != len(expected) {
+		tests.Info("Received Files: %#v", files)
+		tests.Failed("Should have written a sitemap per language")
+	}
+
+	for index, file := range files {
+		if filepath.Base(file) != expected[index] {
+			tests.Info("Received Files: %#v", files)
+			tests.Failed("Should have written a sitemap per language")
+		}
+	}
+	tests.Passed("Should have written a sitemap per language")
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "sitemap-en.xml"))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read sitemap-en.xml")
+	}
+
+	var sitemap struct {
+		URLs []struct {
+			Loc      string `xml:"loc"`
+			Language string `xml:"language"`
+		} `xml:"url"`
+	}
+
+	if err := xml.Unmarshal(content, &sitemap); err != nil || len(sitemap.URLs) != 2 {
+		tests.Info("Received Sitemap: %s", content)
+		tests.Failed("Should have grouped pages of a language into it's sitemap")
+	}
+
+	for _, entry := range sitemap.URLs {
+		if !strings.EqualFold(entry.Language, "en") {
+			tests.Info("Received Sitemap: %s", content)
+			tests.Failed("Should have grouped pages of a language into it's sitemap")
+		}
+	}
+	tests.Passed("Should have grouped pages of a language into it's sitemap")
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// iso639Languages lists the two letter ISO 639-1 language codes hreflang
+// values start with.
+var iso639Languages = codeSet(`
+	aa ab ae af ak am an ar as av ay az ba be bg bh bi bm bn bo br bs ca ce ch co cr cs cu cv cy
+	da de dv dz ee el en eo es et eu fa ff fi fj fo fr fy ga gd gl gn gu gv ha he hi ho hr ht hu
+	hy hz ia id ie ig ii ik io is it iu ja jv ka kg ki kj kk kl km kn ko kr ks ku kv kw ky la lb
+	lg li ln lo lt lu lv mg mh mi mk ml mn mr ms mt my na nb nd ne ng nl nn no nr nv ny oc oj om
+	or os pa pi pl ps pt qu rm rn ro ru rw sa sc sd se sg si sk sl sm sn so sq sr ss st su sv sw
+	ta te tg th ti tk tl tn to tr ts tt tw ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu
+`)
+
+// iso3166Regions lists the two letter ISO 3166-1 region codes hreflang values
+// may end with.
+var iso3166Regions = codeSet(`
+	ad ae af ag ai al am ao aq ar as at au aw ax az ba bb bd be bf bg bh bi bj bl bm bn bo bq br
+	bs bt bv bw by bz ca cc cd cf cg ch ci ck cl cm cn co cr cu cv cw cx cy cz de dj dk dm do dz
+	ec ee eg eh er es et fi fj fk fm fo fr ga gb gd ge gf gg gh gi gl gm gn gp gq gr gs gt gu gw
+	gy hk hm hn hr ht hu id ie il im in io iq ir is it je jm jo jp ke kg kh ki km kn kp kr kw ky
+	kz la lb lc li lk lr ls lt lu lv ly ma mc md me mf mg mh mk ml mm mn mo mp mq mr ms mt mu mv
+	mw mx my mz na nc ne nf ng ni nl no np nr nu nz om pa pe pf pg ph pk pl pm pn pr ps pt pw py
+	qa re ro rs ru rw sa sb sc sd se sg sh si sj sk sl sm sn so sr ss st sv sx sy sz tc td tf tg
+	th tj tk tl tm tn to tr tt tv tw tz ua ug um us uy uz va vc ve vg vi vn vu wf ws ye yt za zm
+	zw
+`)
+
+// codeSet returns the set of the codes listed by giving text.
+func codeSet(codes string) map[string]bool {
+	set := map[string]bool{}
+	for _, code := range strings.Fields(codes) {
+		set[code] = true
+	}
+	return set
+}
+
+// validHreflang returns true if giving hreflang value is x-default or an ISO
+// 639-1 language code, optionally followed by a four letter script and an ISO
+// 3166-1 region or three digit UN M.49 area, such as en, en-GB, zh-Hant-TW or
+// es-419. Codes separated by underscores, such as en_GB, are invalid.
+func validHreflang(value string) bool {
+	value = strings.ToLower(value)
+	if value == "x-default" {
+		return true
+	}
+
+	subtags := strings.Split(value, "-")
+	if !iso639Languages[subtags[0]] {
+		return false
+	}
+	subtags = subtags[1:]
+
+	if len(subtags) != 0 && len(subtags[0]) == 4 && isLetters(subtags[0]) {
+		subtags = subtags[1:]
+	}
+
+	switch len(subtags) {
+	case 0:
+		return true
+	case 1:
+		return iso3166Regions[subtags[0]] || len(subtags[0]) == 3 && isDigits(subtags[0])
+	default:
+		return false
+	}
+}
+
+// isLetters returns true if giving lowercase text holds only letters.
+func isLetters(text string) bool {
+	for _, c := range text {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits returns true if giving text holds only digits.
+func isDigits(text string) bool {
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hreflangStatus embodies the status a url answered with during a crawl.
+type hreflangStatus struct {
+	status     crawler.Status
+	redirected bool
+}
+
+// hreflangAudit embodies the hreflang alternates declared by the live pages of
+// a crawl with the statuses of the urls checked, for auditing alternates are
+// reachable, declared back by the pages they point to and use valid language
+// codes.
+type hreflangAudit struct {
+	pages    map[string][]crawler.Alternate
+	statuses map[string]hreflangStatus
+}
+
+// hreflangIssue embodies an alternate declared by a page which failed the
+// audit, with the problem found.
+type hreflangIssue struct {
+	Page     string
+	Hreflang string
+	URL      string
+	Problem  string
+}
+
+// newHreflangAudit returns a new empty hreflangAudit.
+func newHreflangAudit() *hreflangAudit {
+	return &hreflangAudit{
+		pages:    map[string][]crawler.Alternate{},
+		statuses: map[string]hreflangStatus{},
+	}
+}
+
+// Observe records the alternates declared by giving page report if it's live
+// and not redirected, and the statuses of the page and the links it points to,
+// once per url.
+func (h *hreflangAudit) Observe(report crawler.LinkReport) {
+	if report.Path == nil {
+		return
+	}
+
+	if report.Status.IsLive && report.RedirectedTo == nil {
+		h.pages[report.Path.String()] = report.Alternates
+	}
+
+	h.add(report)
+	for _, kid := range report.PointsTo {
+		h.add(kid)
+	}
+}
+
+// add records the status of giving report if it's url is unseen.
+func (h *hreflangAudit) add(report crawler.LinkReport) {
+	if report.Path == nil {
+		return
+	}
+
+	link := report.Path.String()
+	if _, ok := h.statuses[link]; !ok {
+		h.statuses[link] = hreflangStatus{status: report.Status, redirected: report.RedirectedTo != nil}
+	}
+}
+
+// Issues returns the alternates failing the audit ordered by page. Alternates
+// whose urls were not checked, such as those out of the crawl's scope, are only
+// checked for their language code, and those pointing to pages not crawled are
+// not checked for reciprocity.
+func (h *hreflangAudit) Issues() []hreflangIssue {
+	pages := make([]string, 0, len(h.pages))
+	for page := range h.pages {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+
+	var issues []hreflangIssue
+	for _, page := range pages {
+		for _, alternate := range h.pages[page] {
+			if alternate.URL == nil {
+				continue
+			}
+
+			link := alternate.URL.String()
+			add := func(problem string) {
+				issues = append(issues, hreflangIssue{Page: page, Hreflang: alternate.Hreflang, URL: link, Problem: problem})
+			}
+
+			if !validHreflang(alternate.Hreflang) {
+				add("invalid language code")
+			}
+
+			if checked, ok := h.statuses[link]; ok {
+				switch {
+				case checked.redirected:
+					add("redirected")
+				case !checked.status.IsLive || checked.status.LastStatus >= 400:
+					if checked.status.LastStatus != 0 {
+						add(fmt.Sprintf("unreachable, status %d", checked.status.LastStatus))
+					} else {
+						add("unreachable")
+					}
+				}
+			}
+
+			if alternates, ok := h.pages[link]; ok && link != page && !declares(alternates, page) {
+				add("not reciprocal")
+			}
+		}
+	}
+	return issues
+}
+
+// declares returns true if giving alternates point to provided url.
+func declares(alternates []crawler.Alternate, link string) bool {
+	for _, alternate := range alternates {
+		if alternate.URL != nil && alternate.URL.String() == link {
+			return true
+		}
+	}
+	return false
+}
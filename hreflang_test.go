@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestValidHreflang(t *testing.T) {
+	fixtures := map[string]bool{
+		"en":         true,
+		"en-GB":      true,
+		"EN-gb":      true,
+		"zh-Hant":    true,
+		"zh-Hant-TW": true,
+		"es-419":     true,
+		"x-default":  true,
+		"en-UK":      false,
+		"en_GB":      false,
+		"eng":        false,
+		"gb":         false,
+		"en-GB-x":    false,
+		"":           false,
+	}
+
+	for value, valid := range fixtures {
+		if validHreflang(value) != valid {
+			tests.Info("Value: %q, Expected: %t", value, valid)
+			tests.Failed("Should have validated hreflang language codes")
+		}
+	}
+	tests.Passed("Should have validated hreflang language codes")
+}
+
+func TestHreflangAudit(t *testing.T) {
+	link := func(path string) *url.URL {
+		return &url.URL{Scheme: "https", Host: "example.com", Path: path}
+	}
+
+	page := func(path string, alternates ...crawler.Alternate) crawler.LinkReport {
+		report := crawler.LinkReport{
+			Path:       link(path),
+			Status:     crawler.Status{IsLive: true, LastStatus: 200},
+			Alternates: alternates,
+		}
+
+		for _, alternate := range alternates {
+			report.PointsTo = append(report.PointsTo, crawler.LinkReport{
+				Path:   alternate.URL,
+				Status: crawler.Status{IsLive: true, LastStatus: 200},
+			})
+		}
+		return report
+	}
+
+	audit := newHreflangAudit()
+	home := page("/",
+		crawler.Alternate{Hreflang: "en", URL: link("/")},
+		crawler.Alternate{Hreflang: "de", URL: link("/de/")},
+		crawler.Alternate{Hreflang: "fr", URL: link("/fr/")},
+		crawler.Alternate{Hreflang: "en_GB", URL: link("/")},
+		crawler.Alternate{Hreflang: "es", URL: link("/es/")},
+		crawler.Alternate{Hreflang: "it", URL: link("/it/")},
+		crawler.Alternate{Hreflang: "ja", URL: &url.URL{Scheme: "https", Host: "example.jp", Path: "/"}},
+	)
+	home.PointsTo[4].Status = crawler.Status{LastStatus: 404}
+	home.PointsTo[5].RedirectedTo = link("/")
+
+	audit.Observe(home)
+	audit.Observe(page("/de/", crawler.Alternate{Hreflang: "en", URL: link("/")}, crawler.Alternate{Hreflang: "de", URL: link("/de/")}))
+	audit.Observe(page("/fr/", crawler.Alternate{Hreflang: "fr", URL: link("/fr/")}))
+
+	expected := []hreflangIssue{
+		{Page: "https://example.com/", Hreflang: "fr", URL: "https://example.com/fr/", Problem: "not reciprocal"},
+		{Page: "https://example.com/", Hreflang: "en_GB", URL: "https://example.com/", Problem: "invalid language code"},
+		{Page: "https://example.com/", Hreflang: "es", URL: "https://example.com/es/", Problem: "unreachable, status 404"},
+		{Page: "https://example.com/", Hreflang: "it", URL: "https://example.com/it/", Problem: "redirected"},
+	}
+
+	issues := audit.Issues()
+	if len(issues) != len(expected) {
+		tests.Info("Received: %#v", issues)
+		tests.Failed("Should have reported alternates failing the audit")
+	}
+	tests.Passed("Should have reported alternates failing the audit")
+
+	for index, issue := range issues {
+		if issue != expected[index] {
+			tests.Info("Expected: %#v, Received: %#v", expected[index], issue)
+			tests.Failed("Should have reported alternates failing the audit ordered by page")
+		}
+	}
+	tests.Passed("Should have reported alternates failing the audit ordered by page")
+}
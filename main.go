@@ -1,47 +1,94 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/url"
 
+	"net"
 	"net/http"
 	"time"
 
 	"os"
-
-	"bytes"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 
 	"github.com/influx6/faux/flags"
-	"github.com/influx6/faux/tmplutil"
 	"github.com/influx6/sitecrawler/crawler"
 )
 
 var (
-	urlTemplate = tmplutil.MustFrom("url-template", `
+	urlTemplate = template.Must(template.New("url-template").Funcs(sitemapFuncs).Parse(`
 	<url>
-		<loc>{{.Path.String }}</loc>
+		<loc>{{ loc .Path }}</loc>
 		<laststatus>{{.Status.LastStatus}}</laststatus>
 		<lastchecked>{{.Status.At.UTC}}</lastchecked>
 		<reachable>{{.Status.IsLive}}</reachable>
-		<crawlable>{{.Status.IsCrawlable}}</crawlable>
-		{{ if notequal .Status.Reason nil }}<reachable_error>{{.Status.Reason.Error }}</reachable_error>
+		<crawlable>{{.Status.IsCrawlable}}</crawlable>{{ if .Owner }}
+		<owner>{{ xml .Owner }}</owner>{{end}}{{ if .Title }}
+		<title>{{ xml .Title }}</title>{{end}}{{ if .Language }}
+		<language{{ if .Direction }} dir="{{ xml .Direction }}"{{end}}>{{ xml .Language }}</language>{{end}}{{ if .ContentType }}
+		<contenttype>{{ xml .ContentType }}</contenttype>{{end}}{{ if .SniffedType }}
+		<sniffedtype>{{ xml .SniffedType }}</sniffedtype>{{end}}
+		<contentlength>{{.ContentLength}}</contentlength>
+		<latency>{{.Latency}}</latency>{{ if .Cached }}
+		<cached>true</cached>{{end}}{{ if .Truncated }}
+		<truncated>true</truncated>{{end}}{{ if .ContentEncoding }}
+		<contentencoding encoded="{{.EncodedSize}}" decoded="{{.DecodedSize}}">{{ xml .ContentEncoding }}</contentencoding>{{end}}{{ if .RemoteIP }}
+		<remoteip family="{{.IPFamily}}"{{ if .ASN }} asn="{{.ASN}}" as_org="{{ xml .ASOrg }}"{{end}}>{{ xml .RemoteIP }}</remoteip>{{end}}{{ if .Server }}
+		<server>{{ xml .Server }}</server>{{end}}{{ if .Robots }}
+		<robots index="{{ not .Robots.NoIndex }}" follow="{{ not .Robots.NoFollow }}">{{ range $i, $d := .Robots.Directives }}{{ if $i }}, {{end}}{{ xml $d }}{{end}}</robots>{{end}}{{ range .ParseWarnings }}
+		<parsewarning>{{ xml . }}</parsewarning>{{end}}{{ if .RouteError }}
+		<routeerror>{{ xml .RouteError }}</routeerror>{{end}}{{ if .Canonical }}
+		<canonical{{ if .NonCanonical }} mismatch="true"{{end}}>{{ loc .Canonical }}</canonical>{{end}}{{ range .Alternates }}
+		<alternate hreflang="{{ xml .Hreflang }}">{{ loc .URL }}</alternate>{{end}}{{ with .Social }}
+		<social{{ if .OGTitle }} og_title="{{ xml .OGTitle }}"{{end}}{{ if .OGImage }} og_image="{{ xml .OGImage }}"{{end}}{{ if .TwitterCard }} twitter_card="{{ xml .TwitterCard }}"{{end}}{{ if .TwitterImage }} twitter_image="{{ xml .TwitterImage }}"{{end}}>{{ range .Issues }}
+			<issue>{{ xml . }}</issue>{{end}}
+		</social>{{end}}{{ with .Structured }}
+		<structured_data jsonld_blocks="{{.Blocks}}">{{ range .JSONLD }}
+			<type source="jsonld">{{ xml . }}</type>{{end}}{{ range .Microdata }}
+			<type source="microdata">{{ xml . }}</type>{{end}}{{ range .Errors }}
+			<error>{{ xml . }}</error>{{end}}
+		</structured_data>{{end}}{{ if or .CacheBefore .CacheAfter }}
+		<cache{{ if .CacheBefore }} before="{{ xml (print .CacheBefore) }}"{{end}}{{ if .CacheAfter }} after="{{ xml (print .CacheAfter) }}"{{end}}/>{{end}}{{ if .Metadata }}
+		<metadata>{{ range $key, $value := .Metadata }}
+			<meta key="{{ xml $key }}">{{ xml $value }}</meta>{{end}}
+		</metadata>{{end}}{{ if .Redirects }}
+		<redirects{{ if .LongRedirect }} long="true"{{end}}>{{ range .Redirects }}
+			<hop status="{{.Status}}">{{ locString .URL }}</hop>{{end}}
+		</redirects>{{end}}
+		{{ if .Status.Reason }}<reachable_error code="{{.Status.Reason.Code}}">{{ xml .Status.Reason.Message }}</reachable_error>
 		<connects>{{ range .PointsTo }}
-			<link>{{.Path.String }}</link>
+			<link{{ if .Position }} position="{{.Position}}"{{end}}{{ if .Rel }} rel="{{ range $i, $r := .Rel }}{{ if $i }} {{end}}{{ xml $r }}{{end}}"{{end}}{{ if .AnchorText }} anchor_text="{{ xml .AnchorText }}"{{end}}{{ if .Heading }} heading="{{ xml .Heading }}"{{end}}{{ if .Origin }} origin="{{ xml .Origin }}"{{end}}{{ if .RedirectedTo }} redirected_to="{{ loc .RedirectedTo }}" hops="{{ len .Redirects }}"{{end}}{{ if .LongRedirect }} long_redirect="true"{{end}}{{ if .Stripped }} stripped_params="{{ range $i, $p := .Stripped }}{{ if $i }},{{end}}{{ xml $p }}{{end}}"{{end}}{{ if .Stylesheet }} stylesheet="{{ xml .Stylesheet }}"{{end}}{{ if .Feed }} feed="{{ xml .Feed }}"{{end}}{{ if .JSON }} json="{{ xml .JSON }}"{{end}}{{ if .CacheBefore }} cache_before="{{ xml (print .CacheBefore) }}"{{end}}{{ if .CacheAfter }} cache_after="{{ xml (print .CacheAfter) }}"{{end}}>{{ loc .Path }}</link>
 		{{end}}</connects>{{else}}<connects>
 		{{ range .PointsTo }}
-			<link>{{.Path.String }}</link>
+			<link{{ if .Position }} position="{{.Position}}"{{end}}{{ if .Rel }} rel="{{ range $i, $r := .Rel }}{{ if $i }} {{end}}{{ xml $r }}{{end}}"{{end}}{{ if .AnchorText }} anchor_text="{{ xml .AnchorText }}"{{end}}{{ if .Heading }} heading="{{ xml .Heading }}"{{end}}{{ if .Origin }} origin="{{ xml .Origin }}"{{end}}{{ if .RedirectedTo }} redirected_to="{{ loc .RedirectedTo }}" hops="{{ len .Redirects }}"{{end}}{{ if .LongRedirect }} long_redirect="true"{{end}}{{ if .Stripped }} stripped_params="{{ range $i, $p := .Stripped }}{{ if $i }},{{end}}{{ xml $p }}{{end}}"{{end}}{{ if .Stylesheet }} stylesheet="{{ xml .Stylesheet }}"{{end}}{{ if .Feed }} feed="{{ xml .Feed }}"{{end}}{{ if .JSON }} json="{{ xml .JSON }}"{{end}}{{ if .CacheBefore }} cache_before="{{ xml (print .CacheBefore) }}"{{end}}{{ if .CacheAfter }} cache_after="{{ xml (print .CacheAfter) }}"{{end}}>{{ loc .Path }}</link>
 		{{end}}</connects>{{end}}
 	</url>
-`)
+`))
 
 	sitemapTemplate = `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">%+s</urlset>`
 )
 
+// commands tracks running commands which must finish before the process
+// exits, as flags.Run returns on interrupt without waiting on them.
+var commands sync.WaitGroup
+
+// failures counts the commands whose action returned an error, as flags.Run
+// prints those errors without failing the process.
+var failures int32
+
 func main() {
-	flags.Run("sitecrawler", flags.Command{
+	run("sitecrawler", flags.Command{
 		Name:         "crawl",
 		AllowDefault: true,
 		ShortDesc:    "Crawls provided website URL returning json sitemap.",
@@ -53,11 +100,41 @@ func main() {
 				Default: -1,
 				Desc:    "Sets the depth to crawl through giving site",
 			},
+			&flags.DurationFlag{
+				Name: "max-duration",
+				Desc: "Sets the maximum duration of the crawl, after which it stops with partial results",
+			},
+			&flags.IntFlag{
+				Name: "max-pages",
+				Desc: "Sets the maximum pages crawled before the crawl stops",
+			},
+			&flags.IntFlag{
+				Name: "max-requests",
+				Desc: "Sets the maximum requests sent before the crawl stops",
+			},
+			&flags.IntFlag{
+				Name:    "section-coverage",
+				Default: crawler.DefaultSectionCoverage,
+				Desc:    "Sets the pages crawled per top-level section before any section is crawled deeper when max-pages bounds the crawl, 0 disables",
+			},
 			&flags.BoolFlag{
 				Name:    "verbose",
 				Default: false,
 				Desc:    "Sets the flag to ensure crawler prints current target.",
 			},
+			&flags.StringFlag{
+				Name:    "user-agent",
+				Default: crawler.DefaultUserAgent,
+				Desc:    "Sets the User-Agent identifying the crawler on all requests",
+			},
+			&stringsFlag{
+				Name: "header",
+				Desc: "Sets a header sent on all requests e.g \"X-Api-Key: abc\", can be repeated",
+			},
+			&stringsFlag{
+				Name: "cookie",
+				Desc: "Sets a cookie sent on all requests e.g \"bucket=b\", can be repeated",
+			},
 			&flags.BoolFlag{
 				Name: "timed",
 				Desc: "Sets the flag to time operation.",
@@ -65,25 +142,524 @@ func main() {
 			&flags.DurationFlag{
 				Name:    "timeout",
 				Default: time.Second * 3,
-				Desc:    "Sets timeout for http.Client to be used",
+				Desc:    "Sets the deadline of each request, spanning all redirects followed and the read of it's body, or up to the response of crawled pages when read-timeout is set",
 			},
 			&flags.IntFlag{
 				Default: 300,
 				Name:    "workers",
 				Desc:    "Sets the total workers allowed by goroutine worker pool",
 			},
+			&flags.StringFlag{
+				Name:    "ip-family",
+				Default: "auto",
+				Desc:    "Sets the ip address family used to connect to hosts, auto races both (v4, v6, auto)",
+			},
+			&flags.StringFlag{
+				Name: "ip-version",
+				Desc: "Sets the ip version used to connect to hosts (4, 6), overriding ip-family",
+			},
+			&flags.StringFlag{
+				Name:    "scope",
+				Default: "host",
+				Desc:    "Sets which hosts are crawled as internal, subdomains and domain going by the target's registered domain (host, subdomains, domain, custom)",
+			},
+			&stringsFlag{
+				Name: "scope-host",
+				Desc: "Sets a host crawled with custom scope e.g \"docs.monzo.com\" or \"*.monzo.com\", can be repeated",
+			},
+			&stringsFlag{
+				Name: "allow-host",
+				Desc: "Sets an additional host crawled as internal whatever the scope e.g \"docs.monzo.com\", can be repeated",
+			},
+			&stringsFlag{
+				Name: "own-host",
+				Desc: "Sets a host pattern you own crawled at full speed, ignoring rate, robots.txt and Retry-After e.g \"*.staging.monzo.com\", can be repeated",
+			},
+			&stringsFlag{
+				Name: "resolve",
+				Desc: "Sets the ip address a host is resolved to e.g \"monzo.com:203.0.113.10\", can be repeated",
+			},
+			&flags.DurationFlag{
+				Name:    "dns-ttl",
+				Default: crawler.DefaultDNSTTL,
+				Desc:    "Sets how long resolved addresses of hosts are cached, 0 resolves hosts on every connection",
+			},
+			&flags.StringFlag{
+				Name: "doh",
+				Desc: "Sets a dns-over-https endpoint all hosts are resolved through in place of the system resolver e.g https://1.1.1.1/dns-query",
+			},
+			&flags.IntFlag{
+				Name: "max-conns-per-host",
+				Desc: "Sets the maximum connections open to a host, with as many kept idle for reuse, 0 leaves it unlimited",
+			},
+			&flags.DurationFlag{
+				Name:    "idle-conn-timeout",
+				Default: 90 * time.Second,
+				Desc:    "Sets how long idle connections are kept for reuse",
+			},
+			&flags.DurationFlag{
+				Name:    "tls-handshake-timeout",
+				Default: 10 * time.Second,
+				Desc:    "Sets the maximum time allowed for a TLS handshake",
+			},
+			&flags.DurationFlag{
+				Name:    "connect-timeout",
+				Default: 30 * time.Second,
+				Desc:    "Sets the maximum time allowed to connect to a host",
+			},
+			&flags.DurationFlag{
+				Name: "response-header-timeout",
+				Desc: "Sets the maximum time allowed for a host to respond with headers once a request is sent, 0 leaves it unlimited",
+			},
+			&flags.DurationFlag{
+				Name: "read-timeout",
+				Desc: "Sets the maximum time a single read of a response body may stall, bounding the read of crawled pages in place of timeout, 0 leaves it unlimited",
+			},
+			&flags.StringFlag{
+				Name: "ca-cert",
+				Desc: "Sets path of a pem bundle of certificate authorities trusted in addition to those of the system",
+			},
+			&flags.BoolFlag{
+				Name: "insecure-skip-verify",
+				Desc: "Sets the flag to skip verification of host certificates, for staging environments with self-signed certificates",
+			},
+			&flags.BoolFlag{
+				Name:    "http2",
+				Default: true,
+				Desc:    "Sets the flag to use HTTP/2 with hosts supporting it, false only speaks HTTP/1.1",
+			},
+			&flags.StringFlag{
+				Name:    "format",
+				Default: "xml",
+				Desc:    "Sets the output format for crawl reports (xml, ndjson, parquet)",
+			},
+			&flags.StringFlag{
+				Name: "template",
+				Desc: "Sets a text/template file crawl reports are rendered with in place of the output format",
+			},
+			&flags.StringFlag{
+				Name:    "compress",
+				Default: "none",
+				Desc:    "Sets the compression applied to written reports (none, gzip, zstd)",
+			},
+			&flags.StringFlag{
+				Name: "split-output-by",
+				Desc: "Sets reports to be split into separate files per host, top-level path prefix, owning team or page language (host, prefix, owner, language)",
+			},
+			&flags.BoolFlag{
+				Name: "split-by-language",
+				Desc: "Sets reports to be split into a sitemap file per declared page language e.g sitemap-en.xml, sitemap-de.xml",
+			},
+			&flags.StringFlag{
+				Name:    "output-dir",
+				Default: ".",
+				Desc:    "Sets the directory split report files are written into",
+			},
+			&flags.StringFlag{
+				Name: "canonical-host",
+				Desc: "Sets the canonical host, or scheme and host e.g https://www.monzo.com, urls of it's www and non-www variants are rewritten to in all outputs",
+			},
+			&stringsFlag{
+				Name: "outlink-origin",
+				Desc: "Sets an origin of the outlinks written to reports, such as a[href] or img for all img attributes, dropping outlinks of other origins, can be repeated",
+			},
+			&flags.IntFlag{
+				Name: "report-buffer",
+				Desc: "Sets the reports buffered for writing apart from the crawl, so slow outputs don't stall it, 0 writes reports as they're received",
+			},
+			&flags.StringFlag{
+				Name:    "report-buffer-policy",
+				Default: "block",
+				Desc:    "Sets how reports are handled while the report buffer is full (block, drop-oldest, spill)",
+			},
+			&flags.StringFlag{
+				Name: "sections",
+				Desc: "Sets comma separated politeness overrides for path prefixes e.g /search=2:500ms,/api=1",
+			},
+			&flags.StringFlag{
+				Name: "owners",
+				Desc: "Sets path to a CODEOWNERS-style file mapping path prefixes to owning teams",
+			},
+			&flags.BoolFlag{
+				Name: "redirect-only",
+				Desc: "Sets the flag to print pages only reached through redirects after crawl",
+			},
+			&flags.IntFlag{
+				Name:    "max-redirects",
+				Default: 10,
+				Desc:    "Sets the maximum redirects followed for a url before it is reported as failed",
+			},
+			&flags.IntFlag{
+				Name:    "redirect-chain-limit",
+				Default: 3,
+				Desc:    "Sets the redirect chain length beyond which a url is flagged as a long redirect",
+			},
+			&flags.StringFlag{
+				Name:    "redirect-policy",
+				Default: "any",
+				Desc:    "Sets which redirects are followed, others are recorded without fetching (same-host, any, none)",
+			},
+			&flags.StringFlag{
+				Name:    "external-redirect-policy",
+				Default: "follow",
+				Desc:    "Sets how redirects leading out of the crawl's scope are followed (follow, first-hop, none)",
+			},
+			&flags.BoolFlag{
+				Name: "keep-session-params",
+				Desc: "Sets the flag to keep session parameters of links which are otherwise stripped",
+			},
+			&flags.BoolFlag{
+				Name: "keep-tracking-params",
+				Desc: "Sets the flag to keep tracking parameters of links such as utm_* or gclid which are otherwise stripped",
+			},
+			&stringsFlag{
+				Name: "tracking-param",
+				Desc: "Sets an additional tracking parameter stripped from links, ending in * to strip all of a prefix e.g mc_*, can be repeated",
+			},
+			&flags.StringFlag{
+				Name:    "query",
+				Default: "keep",
+				Desc:    "Sets whether query strings distinguish urls, stripping them or keeping only listed parameters (keep, strip, whitelist=page,sort)",
+			},
+			&flags.StringFlag{
+				Name: "cache",
+				Desc: "Sets path to a json store caching page validators across crawls, so unchanged pages are not fetched again",
+			},
+			&flags.StringFlag{
+				Name: "store",
+				Desc: "Sets the backend the crawl's frontier, seen set, results, page validators and delta urls are persisted into, in place of the cache file, each crawl replacing the previous crawl's frontier, seen set and results (memory, bolt, sqlite, json)",
+			},
+			&flags.StringFlag{
+				Name: "store-path",
+				Desc: "Sets path to the database file of the bolt, sqlite or json store",
+			},
+			&flags.IntFlag{
+				Name:    "max-body-size",
+				Default: crawler.DefaultMaxBodySize,
+				Desc:    "Sets the maximum bytes read of a page's body, links beyond it are not farmed",
+			},
+			&flags.IntFlag{
+				Name:    "max-url-length",
+				Default: 2048,
+				Desc:    "Sets the maximum characters of a url beyond which it's skipped as a suspected crawler trap, 0 for no limit",
+			},
+			&flags.IntFlag{
+				Name:    "max-path-segments",
+				Default: 32,
+				Desc:    "Sets the maximum path segments of a url beyond which it's skipped as a suspected crawler trap, 0 for no limit",
+			},
+			&flags.IntFlag{
+				Name:    "max-segment-repeats",
+				Default: 3,
+				Desc:    "Sets the maximum times a path segment may repeat in a url before it's skipped as a suspected crawler trap, 0 for no limit",
+			},
+			&flags.IntFlag{
+				Name: "max-per-pattern",
+				Desc: "Sets the maximum urls of a pattern, their path with digits masked, beyond which they're skipped as suspected crawler traps",
+			},
+			&flags.StringFlag{
+				Name: "rate",
+				Desc: "Sets the maximum rate of requests per host e.g 5/s, 100/m",
+			},
+			&flags.StringFlag{
+				Name: "ramp-up",
+				Desc: "Sets a warm-up schedule ramping concurrent requests from start to max over a window e.g 2:50:1m",
+			},
+			&flags.Float64Flag{
+				Name:    "ramp-error-rate",
+				Default: 0.1,
+				Desc:    "Sets the error rate of recent requests above which the ramp up holds",
+			},
+			&flags.BoolFlag{
+				Name: "rate-by-ip",
+				Desc: "Sets the flag to apply the rate per ip address of hosts, so hosts sharing a server share it's rate",
+			},
+			&flags.BoolFlag{
+				Name: "ignore-crawl-delay",
+				Desc: "Sets the flag to ignore Crawl-delay of target's robots.txt",
+			},
+			&flags.BoolFlag{
+				Name: "respect-nofollow",
+				Desc: "Sets the flag to not crawl links marked rel=nofollow, still checking and listing them",
+			},
+			&flags.BoolFlag{
+				Name: "stylesheets",
+				Desc: "Sets the flag to fetch linked stylesheets, checking the fonts, images and imports they reference",
+			},
+			&flags.BoolFlag{
+				Name: "feeds",
+				Desc: "Sets the flag to parse linked RSS and Atom feeds, crawling the urls of their items within scope",
+			},
+			&flags.BoolFlag{
+				Name: "json",
+				Desc: "Sets the flag to scan linked and embedded json documents, crawling the url-shaped strings they hold within scope",
+			},
+			&flags.BoolFlag{
+				Name: "social",
+				Desc: "Sets the flag to audit the Open Graph and Twitter Card tags of pages, flagging missing tags and broken image urls",
+			},
+			&flags.BoolFlag{
+				Name: "prime-cache",
+				Desc: "Sets the flag to warm CDN and origin caches, requesting every url within scope with a GET without cache-busting and reporting it's cache status before and after",
+			},
+			&flags.StringFlag{
+				Name:    "render",
+				Default: "none",
+				Desc:    "Sets how pages are rendered before their links are farmed, js renders them in a headless browser (none, js)",
+			},
+			&flags.DurationFlag{
+				Name:    "render-timeout",
+				Default: crawler.DefaultRenderTimeout,
+				Desc:    "Sets the deadline for rendering each page in the headless browser",
+			},
+			&flags.IntFlag{
+				Name:    "render-tabs",
+				Default: crawler.DefaultRenderTabs,
+				Desc:    "Sets the maximum pages rendered at once, each in it's own tab of the headless browser",
+			},
+			&flags.StringFlag{
+				Name: "render-browser",
+				Desc: "Sets path of the chrome or chromium executable pages are rendered with, defaults to the first found on PATH",
+			},
+			&stringsFlag{
+				Name: "render-arg",
+				Desc: "Sets an additional argument passed to the headless browser such as --no-sandbox, can be repeated",
+			},
+			&flags.StringFlag{
+				Name: "submit",
+				Desc: "Sets path of json config of indexing apis (indexnow, google) urls are submitted to after crawl, being only urls added since the previous crawl of the store, cache or delta, or whose validators changed, sent with default TLS verification apart from the crawl's client",
+			},
+			&flags.BoolFlag{
+				Name: "certificates",
+				Desc: "Sets the flag to print TLS version, cipher suite and certificate of https hosts after crawl",
+			},
+			&flags.IntFlag{
+				Name:    "cert-expiry-days",
+				Default: 30,
+				Desc:    "Sets the days within which expiring certificates are flagged when printing certificates",
+			},
+			&flags.BoolFlag{
+				Name: "contacts",
+				Desc: "Sets the flag to print the mailto: and tel: addresses linked to by crawled pages after crawl",
+			},
+			&flags.StringFlag{
+				Name: "asn-db",
+				Desc: "Sets path of tab separated ip2asn database (iptoasn.com) used to record the autonomous system serving each url",
+			},
+			&flags.StringFlag{
+				Name: "redact",
+				Desc: "Sets path of json config of redactions (strip_params, secrets, headers) applied to all outputs and logs",
+			},
+			&flags.StringFlag{
+				Name: "bundle",
+				Desc: "Sets path of a tar archive (.tar, .tar.gz, .tar.zst) packaging reports, manifest, badge and logs of the crawl with a content index",
+			},
+			&flags.StringFlag{
+				Name: "badge",
+				Desc: "Sets path prefix of json and svg badge artifacts summarizing coverage and broken links written after crawl",
+			},
+			&flags.StringFlag{
+				Name: "badge-sitemap",
+				Desc: "Sets a sitemap file or url whose urls reached by the crawl are reported as coverage in the badge",
+			},
+			&flags.StringFlag{
+				Name: "export-by-status",
+				Desc: "Sets directory a file listing urls per status code is written into after crawl e.g 404.txt, 301.csv with targets",
+			},
+			&flags.StringFlag{
+				Name: "emit-delta",
+				Desc: "Sets directory storing the live urls of each crawl, or the crawl's store when set, writing the urls added and removed since the previous crawl into added.txt and removed.txt",
+			},
+			&flags.StringFlag{
+				Name: "progress-file",
+				Desc: "Sets path of a json file rewritten atomically during crawl with the pages received, their rates and the crawl's state",
+			},
+			&flags.DurationFlag{
+				Name:    "progress-interval",
+				Default: defaultProgressInterval,
+				Desc:    "Sets how often the progress file is rewritten during crawl",
+			},
+			&flags.BoolFlag{
+				Name: "body-stats",
+				Desc: "Sets the flag to print response bodies received, closed unread and leaked after crawl",
+			},
+			&flags.BoolFlag{
+				Name: "connections",
+				Desc: "Sets the flag to print new and reused connection counts per host after crawl",
+			},
+			&flags.BoolFlag{
+				Name: "seed-from-sitemap",
+				Desc: "Sets the flag to also crawl urls listed by target's /sitemap.xml, printing orphan and unlisted pages after crawl",
+			},
+			&flags.BoolFlag{
+				Name: "templates",
+				Desc: "Sets the flag to cluster pages by their structure into templates, printing the pages per template after crawl",
+			},
+			&flags.IntFlag{
+				Name: "max-per-template",
+				Desc: "Sets the maximum pages per template whose links are explored, clustering pages into templates, 0 is unlimited",
+			},
+			&flags.Float64Flag{
+				Name:    "template-similarity",
+				Default: crawler.DefaultTemplateSimilarity,
+				Desc:    "Sets the share of tag paths pages must have in common to be clustered into the same template",
+			},
+			&flags.IntFlag{
+				Name: "discoveries",
+				Desc: "Sets total most redundantly linked urls to print with the first pages linking to them and the dedup ratio after crawl",
+			},
+			&flags.IntFlag{
+				Name:    "error-digest",
+				Default: 10,
+				Desc:    "Sets total most frequent distinct errors to print with their url counts after crawl, 0 disables it",
+			},
+			&flags.IntFlag{
+				Name:    "max-parse-failures",
+				Default: -1,
+				Desc:    "Sets the maximum pages with html parse warnings before the crawl fails, -1 disables it",
+			},
 		},
 		Action: func(ctx flags.Context) error {
 			if len(ctx.Args()) == 0 {
 				return errors.New("must provide website url for crawling. Run `crawl help`")
 			}
 
+			commands.Add(1)
+			defer commands.Done()
+
 			start := time.Now()
 			depth, _ := ctx.GetInt("depth")
 			timeout, _ := ctx.GetDuration("timeout")
 			verbose, _ := ctx.GetBool("verbose")
+			format, _ := ctx.GetString("format")
+			compression, _ := ctx.GetString("compress")
+			split, _ := ctx.GetString("split-output-by")
+			if byLanguage, _ := ctx.GetBool("split-by-language"); byLanguage {
+				if split != "" && split != "language" {
+					return ErrSplitLanguage
+				}
+				split = "language"
+			}
+			outputDir, _ := ctx.GetString("output-dir")
+			sectionRules, _ := ctx.GetString("sections")
 
-			client := &http.Client{Timeout: timeout}
+			sections, err := crawler.ParseSectionRules(sectionRules)
+			if err != nil {
+				return err
+			}
+
+			var redaction *redactor
+			if redactFile, _ := ctx.GetString("redact"); redactFile != "" {
+				if redaction, err = loadRedactor(redactFile); err != nil {
+					return err
+				}
+			}
+
+			// Logs and reports are also captured when bundling, to be packaged
+			// with the crawl's other artifacts.
+			var logs io.Writer = os.Stderr
+			var logBuffer bytes.Buffer
+			var reportsOutput io.Writer = os.Stdout
+			var reportsFile *os.File
+
+			bundle, _ := ctx.GetString("bundle")
+			if bundle != "" {
+				if _, err := bundleCompression(bundle); err != nil {
+					return err
+				}
+
+				logs = io.MultiWriter(os.Stderr, &logBuffer)
+
+				if split == "" {
+					if reportsFile, err = ioutil.TempFile("", "sitecrawler-reports"); err != nil {
+						return err
+					}
+
+					defer os.Remove(reportsFile.Name())
+					defer reportsFile.Close()
+
+					reportsOutput = io.MultiWriter(os.Stdout, reportsFile)
+				}
+			}
+
+			logs = redaction.Writer(logs)
+
+			if err := checkParquetCompression(format, compression); err != nil {
+				return err
+			}
+
+			output, err := newCompressor(compression, reportsOutput)
+			if err != nil {
+				return err
+			}
+
+			templateFile, _ := ctx.GetString("template")
+			if templateFile != "" && split != "" {
+				return ErrSplitTemplate
+			}
+
+			ipFamily, _ := ctx.GetString("ip-family")
+			if ipVersion, _ := ctx.GetString("ip-version"); ipVersion != "" {
+				if ipVersion != "4" && ipVersion != "6" {
+					return fmt.Errorf("invalid ip version %+q, expected 4 or 6", ipVersion)
+				}
+				ipFamily = ipVersion
+			}
+
+			family, err := crawler.ParseIPFamily(ipFamily)
+			if err != nil {
+				return err
+			}
+
+			var resolver crawler.HostResolver
+			var lookups crawler.HostResolver = net.DefaultResolver
+			if doh, _ := ctx.GetString("doh"); doh != "" {
+				if lookups, err = crawler.NewDoHResolver(doh, &http.Client{Timeout: timeout}); err != nil {
+					return err
+				}
+				resolver = lookups
+			}
+
+			if dnsTTL, _ := ctx.GetDuration("dns-ttl"); dnsTTL > 0 {
+				resolver = crawler.NewDNSCache(lookups, dnsTTL)
+			}
+
+			if pins, _ := ctx.Get("resolve"); len(pins.([]string)) != 0 {
+				pinned := crawler.NewPinnedResolver(resolver)
+				for _, pin := range pins.([]string) {
+					if err := pinned.Pin(pin); err != nil {
+						return err
+					}
+				}
+				resolver = pinned
+			}
+
+			transport := crawler.TransportOptions{
+				IPFamily: family,
+				Resolver: resolver,
+			}
+
+			transport.MaxConnsPerHost, _ = ctx.GetInt("max-conns-per-host")
+			transport.IdleConnTimeout, _ = ctx.GetDuration("idle-conn-timeout")
+			transport.TLSHandshakeTimeout, _ = ctx.GetDuration("tls-handshake-timeout")
+			transport.ConnectTimeout, _ = ctx.GetDuration("connect-timeout")
+			transport.ResponseHeaderTimeout, _ = ctx.GetDuration("response-header-timeout")
+			transport.ReadTimeout, _ = ctx.GetDuration("read-timeout")
+			transport.InsecureSkipVerify, _ = ctx.GetBool("insecure-skip-verify")
+			if bodyStats, _ := ctx.GetBool("body-stats"); bodyStats {
+				transport.Bodies = crawler.NewBodyStats()
+			}
+			if caCert, _ := ctx.GetString("ca-cert"); caCert != "" {
+				if transport.RootCAs, err = crawler.LoadCertPool(caCert); err != nil {
+					return err
+				}
+			}
+
+			if http2, _ := ctx.GetBool("http2"); !http2 {
+				transport.DisableHTTP2 = true
+			}
+
+			client := &http.Client{Transport: crawler.NewRoundTripper(transport)}
 
 			targetURL := ctx.Args()[0]
 			target, err := url.Parse(targetURL)
@@ -95,40 +671,1313 @@ func main() {
 				return fmt.Errorf("provided url has no host path")
 			}
 
-			pool := crawler.NewWorkerPool(300, ctx)
+			canonicalValue, _ := ctx.GetString("canonical-host")
+			canonical, err := parseCanonicalHost(canonicalValue)
+			if err != nil {
+				return err
+			}
+
+			originValues, _ := ctx.Get("outlink-origin")
+			origins := parseOutlinkOrigins(originValues.([]string))
+
+			var writer ReportWriter
+			switch {
+			case split != "":
+				writer, err = newSplitWriter(split, outputDir, format, compression)
+			case templateFile != "":
+				writer, err = newTemplateWriter(templateFile, redaction.URL(canonical.URL(target)), output)
+			default:
+				writer, err = newReportWriter(format, output)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if redaction != nil {
+				writer = redactReportWriter{ReportWriter: writer, redactor: redaction}
+			}
+
+			var buffered *bufferedWriter
+			if bufferSize, _ := ctx.GetInt("report-buffer"); bufferSize > 0 {
+				bufferPolicy, _ := ctx.GetString("report-buffer-policy")
+				policy, err := parseBufferPolicy(bufferPolicy)
+				if err != nil {
+					return err
+				}
+
+				buffered = newBufferedWriter(writer, bufferSize, policy)
+				writer = buffered
+			}
+
+			var asns *crawler.ASNs
+			if asnFile, _ := ctx.GetString("asn-db"); asnFile != "" {
+				file, err := os.Open(asnFile)
+				if err != nil {
+					return err
+				}
+
+				asns, err = crawler.ParseASNs(file)
+				file.Close()
+				if err != nil {
+					return err
+				}
+			}
+
+			var owners *crawler.Owners
+			if ownersFile, _ := ctx.GetString("owners"); ownersFile != "" {
+				file, err := os.Open(ownersFile)
+				if err != nil {
+					return err
+				}
+
+				owners, err = crawler.ParseOwners(file)
+				file.Close()
+				if err != nil {
+					return err
+				}
+			}
+
+			// The crawl's state is kept in it's store, while a cache file is a
+			// json store keeping page validators alone.
+			var store, validators crawler.Store
+			cacheFile, _ := ctx.GetString("cache")
+			storeKind, _ := ctx.GetString("store")
+			switch {
+			case storeKind != "" && cacheFile != "":
+				return ErrStoreCache
+			case storeKind != "":
+				kind, err := crawler.ParseStoreKind(storeKind)
+				if err != nil {
+					return err
+				}
+
+				storePath, _ := ctx.GetString("store-path")
+				if store, err = crawler.OpenStore(kind, storePath); err != nil {
+					return err
+				}
+				validators = store
+			case cacheFile != "":
+				if validators, err = crawler.OpenStore(crawler.StoreJSON, cacheFile); err != nil {
+					return err
+				}
+			}
+
+			var cache *crawler.ValidatorCache
+			if validators != nil {
+				defer validators.Close()
+
+				if cache, err = crawler.LoadValidatorCacheStore(validators); err != nil {
+					return err
+				}
+			}
+
+			// Submitted urls are compared against the previous crawl, read
+			// before the crawl's state is reset.
+			var baseline *crawlBaseline
+			var previousCrawl bool
+			submitFile, _ := ctx.GetString("submit")
+			deltaDir, _ := ctx.GetString("emit-delta")
+			if submitFile != "" {
+				if _, memory := validators.(*crawler.MemoryStore); deltaDir == "" && (validators == nil || memory) {
+					return ErrSubmitBaseline
+				}
+
+				if baseline, previousCrawl, err = loadCrawlBaseline(store, validators, deltaDir); err != nil {
+					return err
+				}
+			}
+
+			workers, _ := ctx.GetInt("workers")
+			if workers < 1 {
+				return fmt.Errorf("%+s: %d", crawler.ErrInvalidWorkers, workers)
+			}
+
+			pool := crawler.NewWorkerPool(workers, ctx)
 			defer pool.Stop()
 
 			var pages crawler.PageCrawler
 			pages.Target = target
 			pages.MaxDepth = depth
 			pages.Verbose = verbose
+			pages.UserAgent, _ = ctx.GetString("user-agent")
 
-			reports := make(chan crawler.LinkReport)
-			pool.Add(func() { pages.Run(context.Background(), client, pool, reports) })
-
-			var buf bytes.Buffer
+			headers, _ := ctx.Get("header")
+			if pages.Headers, err = parseHeaders(headers.([]string)); err != nil {
+				return err
+			}
 
-			var records []string
-			for report := range reports {
-				buf.Reset()
+			cookies, _ := ctx.Get("cookie")
+			if pages.Cookies, err = parseCookies(cookies.([]string)); err != nil {
+				return err
+			}
+			redaction.MaskHeaders(pages.Headers, pages.Cookies)
 
-				if pages.Verbose {
-					fmt.Printf("Received new page report: %q from %q\n", report.Path.Path, report.Path.Host)
+			pages.Sections = sections
+			pages.Cache = cache
+			if store != nil {
+				pages.State = crawler.NewCrawlState(store)
+				if err := pages.State.Reset(); err != nil {
+					return err
 				}
+			}
+			pages.IgnoreCrawlDelay, _ = ctx.GetBool("ignore-crawl-delay")
+			pages.RateByIP, _ = ctx.GetBool("rate-by-ip")
+			pages.RespectNofollow, _ = ctx.GetBool("respect-nofollow")
+			pages.ScanStylesheets, _ = ctx.GetBool("stylesheets")
+			pages.FollowFeeds, _ = ctx.GetBool("feeds")
+			pages.ScanJSON, _ = ctx.GetBool("json")
+			pages.ScanSocial, _ = ctx.GetBool("social")
+			pages.PrimeCache, _ = ctx.GetBool("prime-cache")
+
+			render, _ := ctx.GetString("render")
+			renderMode, err := crawler.ParseRenderMode(render)
+			if err != nil {
+				return err
+			}
+
+			if renderMode == crawler.RenderJS {
+				browser, _ := ctx.GetString("render-browser")
+				renderTimeout, _ := ctx.GetDuration("render-timeout")
+				renderTabs, _ := ctx.GetInt("render-tabs")
+				renderArgs, _ := ctx.Get("render-arg")
 
-				if err := urlTemplate.Execute(&buf, report); err != nil {
-					return fmt.Errorf("parseError:  %+s", err)
+				renderer, err := crawler.NewChromeRenderer(browser, renderArgs.([]string), renderTimeout, renderTabs)
+				if err != nil {
+					return err
 				}
 
-				records = append(records, buf.String())
+				defer renderer.Close()
+				pages.Renderer = renderer
 			}
+			pages.RequestTimeout = timeout
+			pages.ReadTimeout = transport.ReadTimeout
+			pages.Resolver = resolver
+			pages.MaxRedirects, _ = ctx.GetInt("max-redirects")
+			pages.RedirectChainLimit, _ = ctx.GetInt("redirect-chain-limit")
+			pages.KeepSessionParams, _ = ctx.GetBool("keep-session-params")
 
-			fmt.Fprintf(os.Stdout, sitemapTemplate, strings.Join(records, ""))
+			pages.KeepTrackingParams, _ = ctx.GetBool("keep-tracking-params")
+			trackingParams, _ := ctx.Get("tracking-param")
+			pages.TrackingParams = trackingParams.([]string)
 
-			if timed, _ := ctx.GetBool("timed"); timed {
-				fmt.Fprintf(os.Stderr, "\nFinished: %+s.\n", time.Now().Sub(start))
+			query, _ := ctx.GetString("query")
+			if pages.QueryPolicy, err = crawler.ParseQueryPolicy(query); err != nil {
+				return err
 			}
-			return nil
-		},
-	})
+
+			maxPages, _ := ctx.GetInt("max-pages")
+			maxRequests, _ := ctx.GetInt("max-requests")
+			if maxPages > 0 || maxRequests > 0 {
+				pages.Budget = crawler.NewBudget(maxPages, maxRequests)
+			}
+
+			if sectionCoverage, _ := ctx.GetInt("section-coverage"); maxPages > 0 && sectionCoverage > 0 {
+				pages.Coverage = crawler.NewSectionCoverage(sectionCoverage)
+			}
+
+			maxBodySize, _ := ctx.GetInt("max-body-size")
+			pages.MaxBodySize = int64(maxBodySize)
+
+			var traps crawler.TrapRules
+			traps.MaxURLLength, _ = ctx.GetInt("max-url-length")
+			traps.MaxSegments, _ = ctx.GetInt("max-path-segments")
+			traps.MaxRepeats, _ = ctx.GetInt("max-segment-repeats")
+			traps.MaxPerPattern, _ = ctx.GetInt("max-per-pattern")
+			pages.Traps = crawler.NewTrapDetector(traps)
+
+			scope, _ := ctx.GetString("scope")
+			if pages.Scope, err = crawler.ParseScope(scope); err != nil {
+				return err
+			}
+
+			scopeHosts, _ := ctx.Get("scope-host")
+			pages.ScopeHosts = scopeHosts.([]string)
+
+			allowHosts, _ := ctx.Get("allow-host")
+			pages.AllowHosts = allowHosts.([]string)
+
+			ownHosts, _ := ctx.Get("own-host")
+			pages.OwnHosts = ownHosts.([]string)
+
+			policy, _ := ctx.GetString("redirect-policy")
+			if pages.RedirectPolicy, err = crawler.ParseRedirectPolicy(policy); err != nil {
+				return err
+			}
+
+			externalPolicy, _ := ctx.GetString("external-redirect-policy")
+			if pages.ExternalRedirectPolicy, err = crawler.ParseExternalRedirectPolicy(externalPolicy); err != nil {
+				return err
+			}
+
+			if rate, _ := ctx.GetString("rate"); rate != "" {
+				if pages.Rate, err = crawler.ParseRate(rate); err != nil {
+					return err
+				}
+			}
+
+			if rampUp, _ := ctx.GetString("ramp-up"); rampUp != "" {
+				if pages.RampUp, err = crawler.ParseRampUp(rampUp); err != nil {
+					return err
+				}
+				pages.RampUp.MaxErrorRate, _ = ctx.GetFloat64("ramp-error-rate")
+			}
+
+			var redirects *crawler.RedirectAudit
+			if redirectOnly, _ := ctx.GetBool("redirect-only"); redirectOnly {
+				redirects = crawler.NewRedirectAudit()
+			}
+
+			templates, _ := ctx.GetBool("templates")
+			maxPerTemplate, _ := ctx.GetInt("max-per-template")
+			if templates || maxPerTemplate > 0 {
+				similarity, _ := ctx.GetFloat64("template-similarity")
+				pages.Templates = crawler.NewTemplateClusters(similarity, maxPerTemplate)
+			}
+
+			discoveries, _ := ctx.GetInt("discoveries")
+			if discoveries > 0 {
+				pages.Discoveries = crawler.NewDiscoveryStats()
+			}
+
+			if seed, _ := ctx.GetBool("seed-from-sitemap"); seed {
+				pages.Sitemap = crawler.NewSitemapSeeds()
+			}
+
+			if connections, _ := ctx.GetBool("connections"); connections {
+				pages.Connections = crawler.NewConnectionStats()
+			}
+
+			if certificates, _ := ctx.GetBool("certificates"); certificates {
+				pages.Certificates = crawler.NewCertificateStats()
+			}
+
+			duplicates := newCanonicalClusters()
+			hreflang := newHreflangAudit()
+
+			var contacts *contactAudit
+			if audit, _ := ctx.GetBool("contacts"); audit {
+				contacts = newContactAudit()
+			}
+
+			var priming *primingAudit
+			if pages.PrimeCache {
+				priming = newPrimingAudit()
+			}
+
+			var encodedBytes, decodedBytes int64
+
+			var submit *submitConfig
+			var submitter *http.Client
+			var submissions []string
+			var indexNowKey *keyVerification
+			if submitFile != "" {
+				config, err := loadSubmitConfig(submitFile)
+				if err != nil {
+					return err
+				}
+				submit = &config
+				submitter = newSubmitClient()
+
+				if submit.IndexNow != nil {
+					indexNowKey = &keyVerification{Location: redaction.RawURL(submit.IndexNow.keyLocation(target))}
+
+					if err := verifyIndexNowKey(submitter, pages.UserAgent, *submit.IndexNow, target); err != nil {
+						fmt.Fprintf(logs, "Preflight: indexnow key not verified, skipping indexnow submission: %+s\n", err)
+						indexNowKey.Error = redaction.String(err.Error())
+						submit.IndexNow = nil
+					} else {
+						fmt.Fprintf(logs, "Preflight: indexnow key verified at %s\n", indexNowKey.Location)
+						indexNowKey.Verified = true
+					}
+				}
+			}
+
+			summary := newCrawlSummary()
+			badge, _ := ctx.GetString("badge")
+
+			var statuses *statusExport
+			statusDir, _ := ctx.GetString("export-by-status")
+			if statusDir != "" {
+				statuses = newStatusExport()
+			}
+
+			var delta *crawlDelta
+			if deltaDir != "" {
+				delta = newCrawlDelta()
+			}
+
+			crawlCtx, cancelCrawl := context.WithCancel(context.Background())
+			defer cancelCrawl()
+
+			if maxDuration, _ := ctx.GetDuration("max-duration"); maxDuration > 0 {
+				var cancel context.CancelFunc
+				crawlCtx, cancel = context.WithTimeout(crawlCtx, maxDuration)
+				defer cancel()
+			}
+
+			signals := trapSignals(cancelCrawl)
+			defer signals.Stop()
+
+			if pages, err = crawler.NewPageCrawler(pages); err != nil {
+				return err
+			}
+
+			var progress *progressFile
+			if progressPath, _ := ctx.GetString("progress-file"); progressPath != "" {
+				progress = newProgressFile(progressPath, redaction.String(target.String()))
+
+				interval, _ := ctx.GetDuration("progress-interval")
+				if err := progress.Start(interval); err != nil {
+					return err
+				}
+
+				// Marks the crawl failed if it returns before finishing.
+				defer progress.Finish(progressFailed)
+			}
+
+			reports := make(chan crawler.LinkReport)
+			pool.Add(func() { pages.Run(crawlCtx, client, pool, reports) })
+
+			var crawled int
+			for report := range reports {
+				crawled++
+
+				if pages.Verbose {
+					redacted := redaction.URL(report.Path)
+					fmt.Printf("Received new page report: %q from %q\n", redacted.Path, redacted.Host)
+				}
+
+				owners.Annotate(&report)
+				asns.Annotate(&report)
+				report = canonical.Report(report)
+
+				if redirects != nil {
+					redirects.Observe(report)
+				}
+
+				summary.Observe(report)
+				duplicates.Observe(report)
+				hreflang.Observe(report)
+
+				if statuses != nil {
+					statuses.Observe(redaction.Report(report))
+				}
+
+				if delta != nil {
+					delta.Observe(redaction.Report(report))
+				}
+
+				if contacts != nil {
+					contacts.Observe(report)
+				}
+
+				if priming != nil {
+					priming.Observe(report)
+				}
+
+				if progress != nil {
+					progress.Observe(report)
+				}
+
+				if report.ContentEncoding != "" {
+					encodedBytes += report.EncodedSize
+					decodedBytes += report.DecodedSize
+				}
+
+				if submit != nil && report.Status.IsLive && report.RedirectedTo == nil && !report.Cached {
+					submissions = append(submissions, report.Path.String())
+				}
+
+				if err := writer.Write(origins.Report(report)); err != nil {
+					return err
+				}
+			}
+
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+
+			if err := output.Close(); err != nil {
+				return err
+			}
+
+			if buffered != nil {
+				if dropped := buffered.Dropped(); dropped != 0 {
+					fmt.Fprintf(logs, "\nDropped: %d reports evicted from the full report buffer\n", dropped)
+				}
+
+				if spilled := buffered.Spilled(); spilled != 0 {
+					fmt.Fprintf(logs, "\nSpilled: %d reports written through disk while the report buffer was full\n", spilled)
+				}
+			}
+
+			if trapped := pages.Traps.Trapped(); trapped != 0 {
+				fmt.Fprintf(logs, "\nSkipped: %d urls suspected to lie within crawler traps\n", trapped)
+			}
+
+			if digest, _ := ctx.GetInt("error-digest"); digest > 0 && len(summary.Errors) != 0 {
+				fmt.Fprintf(logs, "\nErrors: %d distinct across %d urls\n", len(summary.Errors), summary.Failed())
+				for index, class := range summary.Errors {
+					if index == digest {
+						fmt.Fprintf(logs, "\t... %d more\n", len(summary.Errors)-digest)
+						break
+					}
+					fmt.Fprintf(logs, "\t%d\t%s\t%s\n", class.URLs, class.Code, redaction.String(class.Message))
+				}
+			}
+
+			if summary.ParseFailures != 0 {
+				fmt.Fprintf(logs, "\nParse warnings: %d pages\n", summary.ParseFailures)
+			}
+
+			if len(summary.DeadRoutes) != 0 {
+				fmt.Fprintf(logs, "\nClient-side dead routes: %d pages served live\n", len(summary.DeadRoutes))
+				for _, route := range summary.DeadRoutes {
+					fmt.Fprintf(logs, "\t%s\t%s\n", redaction.RawURL(route.URL), route.Reason)
+				}
+			}
+
+			if clusters := duplicates.Clusters(); len(clusters) != 0 {
+				fmt.Fprintf(logs, "\nDuplicate clusters: %d canonical urls declared by %d other pages\n", len(clusters), summary.NonCanonical)
+				for _, cluster := range clusters {
+					fmt.Fprintf(logs, "\t%s\t%d pages\n", redaction.RawURL(cluster.Canonical), len(cluster.URLs))
+					for _, page := range cluster.URLs {
+						fmt.Fprintf(logs, "\t\t%s\n", redaction.RawURL(page))
+					}
+				}
+			}
+
+			if len(summary.SocialIssues) != 0 {
+				fmt.Fprintf(logs, "\nSocial tag issues: %d pages\n", len(summary.SocialIssues))
+				for _, page := range summary.SocialIssues {
+					fmt.Fprintf(logs, "\t%s\t%s\n", redaction.RawURL(page.URL), redaction.String(strings.Join(page.Issues, "; ")))
+				}
+			}
+
+			if len(summary.StructuredTypes) != 0 {
+				types := make([]string, 0, len(summary.StructuredTypes))
+				for kind := range summary.StructuredTypes {
+					types = append(types, kind)
+				}
+				sort.Strings(types)
+
+				fmt.Fprintf(logs, "\nStructured data types: %d\n", len(types))
+				for _, kind := range types {
+					fmt.Fprintf(logs, "\t%s\t%d pages\n", kind, summary.StructuredTypes[kind])
+				}
+			}
+
+			if len(summary.StructuredErrors) != 0 {
+				fmt.Fprintf(logs, "\nStructured data errors: %d pages\n", len(summary.StructuredErrors))
+				for _, page := range summary.StructuredErrors {
+					fmt.Fprintf(logs, "\t%s\t%s\n", redaction.RawURL(page.URL), redaction.String(strings.Join(page.Errors, "; ")))
+				}
+			}
+
+			if issues := hreflang.Issues(); len(issues) != 0 {
+				fmt.Fprintf(logs, "\nHreflang issues: %d\n", len(issues))
+				for _, issue := range issues {
+					fmt.Fprintf(logs, "\t%s\t%s\t%s\t%s\n", redaction.RawURL(issue.Page), issue.Hreflang, redaction.RawURL(issue.URL), issue.Problem)
+				}
+			}
+
+			if pages.Budget.Exhausted() {
+				fmt.Fprintf(logs, "\nStopped: crawl budget exhausted after %d pages and %d requests\n", pages.Budget.Pages(), pages.Budget.Requests())
+			}
+
+			summary.PartialSections(pages.Coverage)
+			if partial := pages.Coverage.Partial(); len(partial) != 0 {
+				fmt.Fprintf(logs, "\nPartially crawled sections: %d\n", len(partial))
+				for _, section := range partial {
+					fmt.Fprintf(logs, "\t%s\t%d crawled, %d skipped\n", redaction.String(section.Section), section.Crawled, section.Skipped)
+				}
+			}
+
+			deadlined := crawlCtx.Err() == context.DeadlineExceeded
+			if deadlined {
+				fmt.Fprintf(logs, "\nStopped: crawl truncated by max duration, results are partial\n")
+			}
+
+			interrupted := signals.Interrupted()
+			if interrupted {
+				fmt.Fprintf(logs, "\nStopped: crawl interrupted, results are partial\n")
+			}
+
+			progressState := progressCompleted
+			switch {
+			case interrupted:
+				progressState = progressInterrupted
+			case deadlined || pages.Budget.Exhausted():
+				progressState = progressTruncated
+			}
+
+			if decodedBytes > 0 {
+				fmt.Fprintf(logs, "\nCompressed pages: %d bytes received for %d bytes of content, saving %.1f%%\n", encodedBytes, decodedBytes, 100*float64(decodedBytes-encodedBytes)/float64(decodedBytes))
+			}
+
+			if err := pages.State.Err(); err != nil {
+				return err
+			}
+
+			if validators != nil {
+				if err := cache.SaveStore(validators); err != nil {
+					return err
+				}
+				fmt.Fprintf(logs, "\nPages unchanged since last crawl: %d\n", cache.Hits())
+			}
+
+			if badge != "" {
+				summary.Truncated = deadlined || interrupted || pages.Budget.Exhausted()
+
+				if location, _ := ctx.GetString("badge-sitemap"); location != "" {
+					sitemap, host, err := openSitemap(&http.Client{Timeout: timeout, Transport: client.Transport}, pages.UserAgent, location)
+					if err != nil {
+						return err
+					}
+
+					validation, err := validateSitemap(sitemap, host)
+					sitemap.Close()
+					if err != nil {
+						return err
+					}
+
+					summary.Cover(validation.URLs)
+				}
+
+				if err := writeBadge(badge, summary); err != nil {
+					return err
+				}
+			}
+
+			var statusFiles []string
+			if statuses != nil {
+				if statusFiles, err = statuses.WriteDir(statusDir); err != nil {
+					return err
+				}
+			}
+
+			var deltaFiles []string
+			if delta != nil {
+				// Partial crawls would list the urls not reached as removed,
+				// so the previous crawl is kept for the next delta.
+				if deadlined || interrupted || pages.Budget.Exhausted() {
+					fmt.Fprintf(logs, "\nDelta: skipped as the crawl is partial, keeping the previous crawl's urls\n")
+				} else {
+					added, removed, ok, err := delta.WriteDir(deltaDir, store)
+					if err != nil {
+						return err
+					}
+
+					if ok {
+						fmt.Fprintf(logs, "\nDelta: %d urls added, %d removed since previous crawl\n", len(added), len(removed))
+						deltaFiles = append(deltaFiles, filepath.Join(deltaDir, deltaAddedFile), filepath.Join(deltaDir, deltaRemovedFile))
+					} else {
+						fmt.Fprintf(logs, "\nDelta: no previous crawl, recorded %d urls\n", len(delta.URLs()))
+					}
+				}
+			}
+
+			// Json stores only write their file once closed.
+			if validators != nil {
+				if err := validators.Close(); err != nil {
+					return err
+				}
+			}
+
+			if submit != nil && !interrupted {
+				if previousCrawl {
+					submissions = baseline.Submissions(submissions, cache)
+
+					fmt.Fprintf(logs, "\nSubmitting %d new or changed urls:\n", len(submissions))
+					for _, result := range submitURLs(submitter, *submit, target, submissions) {
+						if result.Err != nil {
+							fmt.Fprintf(logs, "\t%s\t%d submitted, failed: %+s\n", result.API, result.Submitted, result.Err)
+							continue
+						}
+						fmt.Fprintf(logs, "\t%s\t%d submitted\n", result.API, result.Submitted)
+					}
+				} else {
+					fmt.Fprintf(logs, "\nSubmitting: skipped as there is no previous crawl to compare %d urls against\n", len(submissions))
+				}
+			}
+
+			if transport.Bodies != nil {
+				fmt.Fprintf(logs, "\nResponse bodies: %d received, %d closed unread, %d leaked\n", transport.Bodies.Opened(), transport.Bodies.Abandoned(), transport.Bodies.Leaked())
+			}
+
+			if pages.Connections != nil {
+				fmt.Fprintf(logs, "\nConnections per host:\n")
+				for _, host := range pages.Connections.Hosts() {
+					fmt.Fprintf(logs, "\t%s\t%d requests, %d new, %d reused, reuse ratio: %.2f\n", host.Host, host.Requests(), host.New, host.Reused, host.ReuseRatio())
+				}
+			}
+
+			if pages.Certificates != nil {
+				expiryDays, _ := ctx.GetInt("cert-expiry-days")
+				within := time.Duration(expiryDays) * 24 * time.Hour
+				now := time.Now()
+
+				fmt.Fprintf(logs, "\nCertificates per host:\n")
+				for _, cert := range pages.Certificates.Hosts() {
+					var flagged []string
+					if cert.ExpiresWithin(within, now) {
+						flagged = append(flagged, "EXPIRING")
+					}
+					if cert.WeakVersion {
+						flagged = append(flagged, "WEAK VERSION")
+					}
+					if cert.WeakCipher {
+						flagged = append(flagged, "WEAK CIPHER")
+					}
+
+					fmt.Fprintf(logs, "\t%s\t%s, %s, issuer: %s, expires: %s (%d days)\t%s\n", cert.Host, cert.Version, cert.CipherSuite, cert.Issuer,
+						cert.NotAfter.UTC().Format("2006-01-02"), int(cert.NotAfter.Sub(now).Hours()/24), strings.Join(flagged, ", "))
+				}
+
+				if expiring := pages.Certificates.Expiring(within, now); len(expiring) != 0 {
+					fmt.Fprintf(logs, "\nCertificates expiring within %d days: %d\n", expiryDays, len(expiring))
+				}
+			}
+
+			if pages.Templates != nil {
+				clusters := pages.Templates.Templates()
+				fmt.Fprintf(logs, "\nTemplates: %d found\n", len(clusters))
+				for _, template := range clusters {
+					fmt.Fprintf(logs, "\t%s\t%d pages, %d capped\t%s\n", template.ID, template.Pages, template.Capped, redaction.RawURL(template.Sample))
+				}
+			}
+
+			if contacts != nil {
+				addresses := contacts.Addresses()
+				fmt.Fprintf(logs, "\nContacts: %d addresses\n", len(addresses))
+				for _, contact := range addresses {
+					fmt.Fprintf(logs, "\t%d\t%s\n", contact.Pages, redaction.String(contact.Address))
+				}
+			}
+
+			if priming != nil {
+				total, before, after := priming.Hits()
+				fmt.Fprintf(logs, "\nCache priming: %d urls reporting a cache status, %d hits before, %d hits after\n", total, before, after)
+				for _, primed := range priming.Cold() {
+					fmt.Fprintf(logs, "\t%s\t%s -> %s\n", redaction.RawURL(primed.URL), cacheLabel(primed.Before), cacheLabel(primed.After))
+				}
+			}
+
+			if pages.Discoveries != nil {
+				fmt.Fprintf(logs, "\nDiscovered: %d links, %d unique, dedup ratio: %.2f\n", pages.Discoveries.Total(), pages.Discoveries.Unique(), pages.Discoveries.Ratio())
+				for _, discovery := range pages.Discoveries.Top(discoveries) {
+					fmt.Fprintf(logs, "\t%d\t%s\n", discovery.Count, redaction.RawURL(discovery.URL))
+					for _, source := range discovery.Sources {
+						fmt.Fprintf(logs, "\t\tfrom %s\n", redaction.RawURL(source))
+					}
+				}
+			}
+
+			if pages.Sitemap != nil {
+				orphans, unlisted := pages.Sitemap.Orphans(), pages.Sitemap.Unlisted()
+				fmt.Fprintf(logs, "\nSitemaps: %d fetched, %d urls listed, %d orphans, %d unlisted pages\n", len(pages.Sitemap.Sitemaps()), len(pages.Sitemap.Listed()), len(orphans), len(unlisted))
+				for _, orphan := range orphans {
+					fmt.Fprintf(logs, "\torphan\t%s\n", redaction.RawURL(orphan))
+				}
+				for _, page := range unlisted {
+					fmt.Fprintf(logs, "\tunlisted\t%s\n", redaction.RawURL(page))
+				}
+			}
+
+			if redirects != nil {
+				pages := redirects.RedirectOnly()
+				fmt.Fprintf(logs, "\nPages only reached through redirects: %d\n", len(pages))
+				for _, page := range pages {
+					via := make([]string, len(page.Via))
+					for index, link := range page.Via {
+						via[index] = redaction.RawURL(link)
+					}
+					fmt.Fprintf(logs, "\t%s\tvia %s\n", redaction.RawURL(page.URL), strings.Join(via, ", "))
+				}
+			}
+
+			if timed, _ := ctx.GetBool("timed"); timed {
+				fmt.Fprintf(logs, "\nFinished: %+s.\n", time.Now().Sub(start))
+			}
+
+			if bundle != "" {
+				manifest, err := jsonEntry("manifest.json", crawlManifest{
+					Target:      redaction.URL(target).String(),
+					StartedAt:   start,
+					FinishedAt:  time.Now(),
+					Pages:       crawled,
+					Format:      format,
+					Compression: compression,
+					Truncated:   deadlined || interrupted || pages.Budget.Exhausted(),
+					IndexNowKey: indexNowKey,
+				})
+				if err != nil {
+					return err
+				}
+
+				entries := []bundleEntry{manifest}
+				if reportsFile != nil {
+					name := "reports" + formatExtension(format, compression)
+					if templateFile != "" {
+						name = "reports" + templateExtension(templateFile, compression)
+					}
+					entries = append(entries, bundleEntry{Name: name, Path: reportsFile.Name()})
+				}
+
+				if splitter, ok := splitOutput(writer); ok {
+					for _, file := range splitter.Files() {
+						entries = append(entries, bundleEntry{Name: "reports/" + filepath.Base(file), Path: file})
+					}
+				}
+
+				if badge != "" {
+					entries = append(entries,
+						bundleEntry{Name: "badge.json", Path: badge + ".json"},
+						bundleEntry{Name: "badge.svg", Path: badge + ".svg"},
+					)
+				}
+
+				for _, file := range statusFiles {
+					entries = append(entries, bundleEntry{Name: "status/" + filepath.Base(file), Path: file})
+				}
+
+				for _, file := range deltaFiles {
+					entries = append(entries, bundleEntry{Name: "delta/" + filepath.Base(file), Path: file})
+				}
+
+				entries = append(entries, bundleEntry{Name: "crawl.log", Content: logBuffer.Bytes()})
+
+				if err := writeBundle(bundle, entries); err != nil {
+					return err
+				}
+			}
+
+			if maxParseFailures, _ := ctx.GetInt("max-parse-failures"); maxParseFailures >= 0 && summary.ParseFailures > maxParseFailures {
+				return fmt.Errorf("%d pages failed to parse, exceeding max of %d", summary.ParseFailures, maxParseFailures)
+			}
+
+			// The progress file is only finished once nothing else can fail,
+			// leaving the deferred call to mark the crawl failed otherwise.
+			if progress != nil {
+				if err := progress.Finish(progressState); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}, flags.Command{
+		Name:      "validate",
+		ShortDesc: "Validates a local or remote sitemap against the sitemaps spec.",
+		Desc:      "Validate checks a sitemap file or url for size limits, url count, escaping and that all urls lie on the same host, optionally checking each listed url is live.",
+		Usages:    []string{"sitecrawler validate sitemap.xml", "sitecrawler -validate.check validate https://monzo.com/sitemap.xml"},
+		Flags: []flags.Flag{
+			&flags.BoolFlag{
+				Name: "check",
+				Desc: "Sets the flag to check the liveness of every listed url",
+			},
+			&flags.StringFlag{
+				Name: "host",
+				Desc: "Sets the host all urls must lie on, defaults to the host of remote sitemaps else of the first url",
+			},
+			&flags.StringFlag{
+				Name:    "user-agent",
+				Default: crawler.DefaultUserAgent,
+				Desc:    "Sets the User-Agent identifying the crawler on all requests",
+			},
+			&flags.DurationFlag{
+				Name:    "timeout",
+				Default: time.Second * 3,
+				Desc:    "Sets timeout for http.Client to be used",
+			},
+			&flags.IntFlag{
+				Default: 300,
+				Name:    "workers",
+				Desc:    "Sets the total workers allowed by goroutine worker pool",
+			},
+		},
+		Action: func(ctx flags.Context) error {
+			if len(ctx.Args()) == 0 {
+				return errors.New("must provide sitemap file or url for validation. Run `validate help`")
+			}
+
+			timeout, _ := ctx.GetDuration("timeout")
+			client := &http.Client{Timeout: timeout}
+
+			var pages crawler.PageCrawler
+			pages.UserAgent, _ = ctx.GetString("user-agent")
+
+			location := ctx.Args()[0]
+			sitemap, host, err := openSitemap(client, pages.UserAgent, location)
+			if err != nil {
+				return err
+			}
+
+			if override, _ := ctx.GetString("host"); override != "" {
+				host = override
+			}
+
+			validation, err := validateSitemap(sitemap, host)
+			sitemap.Close()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Validated %s: %d bytes, %d urls, %d problems\n", location, validation.Size, validation.Total, len(validation.Problems))
+			for _, problem := range validation.Problems {
+				fmt.Printf("\t%s\n", problem)
+			}
+
+			var dead int
+			if check, _ := ctx.GetBool("check"); check && len(validation.URLs) != 0 {
+				workers, _ := ctx.GetInt("workers")
+				pool := crawler.NewWorkerPool(workers, ctx)
+				defer pool.Stop()
+
+				pages.Target = validation.URLs[0]
+				reports, err := pages.CheckLinks(ctx, client, pool, validation.URLs)
+				if err != nil {
+					return err
+				}
+
+				for _, report := range reports {
+					if report.Status.IsLive {
+						continue
+					}
+
+					dead++
+					if report.Status.Reason != nil {
+						fmt.Printf("\t%s: not live: %s\n", encodeURL(report.Path), report.Status.Reason.Message)
+						continue
+					}
+					fmt.Printf("\t%s: not live: status %d\n", encodeURL(report.Path), report.Status.LastStatus)
+				}
+
+				fmt.Printf("Checked %d urls, %d not live\n", len(reports), dead)
+			}
+
+			if len(validation.Problems) != 0 || dead != 0 {
+				return fmt.Errorf("sitemap %+q is invalid", location)
+			}
+			return nil
+		},
+	}, flags.Command{
+		Name:      "batch",
+		ShortDesc: "Crawls many sites listed in a yaml file, each with it's own options.",
+		Desc:      "Batch runs independent crawls of all sites listed in a yaml file with a global concurrency cap, writing each site's reports into it's own file and a combined summary.json into the output directory.",
+		Usages:    []string{"sitecrawler batch sites.yaml", "sitecrawler -batch.concurrency 8 batch sites.yaml"},
+		Flags: []flags.Flag{
+			&flags.IntFlag{
+				Name: "concurrency",
+				Desc: "Sets the maximum sites crawled at once, overriding the concurrency of the batch file",
+			},
+			&flags.StringFlag{
+				Name: "output-dir",
+				Desc: "Sets the directory site reports and the summary are written into, overriding the output_dir of the batch file",
+			},
+		},
+		Action: func(ctx flags.Context) error {
+			if len(ctx.Args()) == 0 {
+				return errors.New("must provide yaml file listing sites for crawling. Run `batch help`")
+			}
+
+			commands.Add(1)
+			defer commands.Done()
+
+			config, err := loadBatchConfig(ctx.Args()[0])
+			if err != nil {
+				return err
+			}
+
+			if concurrency, _ := ctx.GetInt("concurrency"); concurrency > 0 {
+				config.Concurrency = concurrency
+			}
+
+			if outputDir, _ := ctx.GetString("output-dir"); outputDir != "" {
+				config.OutputDir = outputDir
+			}
+
+			if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+				return err
+			}
+
+			batchCtx, cancelBatch := context.WithCancel(context.Background())
+			defer cancelBatch()
+
+			signals := trapSignals(cancelBatch)
+			defer signals.Stop()
+
+			start := time.Now()
+			results := runBatch(batchCtx, config, os.Stderr)
+
+			summaryFile := filepath.Join(config.OutputDir, batchSummaryName)
+			if err := writeBatchSummary(summaryFile, results); err != nil {
+				return err
+			}
+
+			var failed int
+			fmt.Fprintf(os.Stderr, "\nCrawled %d sites in %s, summary written to %s:\n", len(results), time.Since(start), summaryFile)
+			for _, result := range results {
+				if result.Error != "" {
+					failed++
+					fmt.Fprintf(os.Stderr, "\t%s\tfailed: %s\n", result.Site, result.Error)
+					continue
+				}
+
+				var truncated string
+				if result.Summary.Truncated {
+					truncated = ", truncated"
+				}
+				fmt.Fprintf(os.Stderr, "\t%s\t%d pages, %d live, %d broken%s\t%s\n", result.Site, result.Summary.Pages, result.Summary.Live, result.Summary.Broken, truncated, result.Output)
+			}
+
+			if failed != 0 {
+				return fmt.Errorf("%d of %d sites failed to crawl", failed, len(results))
+			}
+			return nil
+		},
+	}, flags.Command{
+		Name:      "bench",
+		ShortDesc: "Benchmarks the crawler against a synthetic site served locally.",
+		Desc:      "Bench serves a synthetic site of giving size and shape locally and crawls it, measuring throughput, memory and allocations of the crawl for the workers, rate and connection settings provided.",
+		Usages:    []string{"sitecrawler bench", "sitecrawler -bench.pages 100000 -bench.branching 20 bench"},
+		Flags: []flags.Flag{
+			&flags.IntFlag{
+				Default: 1000,
+				Name:    "pages",
+				Desc:    "Sets the total pages of the synthetic site",
+			},
+			&flags.IntFlag{
+				Default: 10,
+				Name:    "branching",
+				Desc:    "Sets the links to child pages of every page of the synthetic site",
+			},
+			&flags.IntFlag{
+				Name: "errors",
+				Desc: "Sets the total broken links of the synthetic site",
+			},
+			&flags.IntFlag{
+				Name: "redirects",
+				Desc: "Sets the total pages of the synthetic site linked to through redirects",
+			},
+			&flags.DurationFlag{
+				Name: "latency",
+				Desc: "Sets the delay of every response of the synthetic site",
+			},
+			&flags.IntFlag{
+				Name: "depth",
+				Desc: "Sets the depth to crawl through the synthetic site",
+			},
+			&flags.IntFlag{
+				Default: 300,
+				Name:    "workers",
+				Desc:    "Sets the total workers allowed by goroutine worker pool",
+			},
+			&flags.StringFlag{
+				Name: "rate",
+				Desc: "Sets the maximum rate of requests per host e.g 5/s, 100/m",
+			},
+			&flags.DurationFlag{
+				Name:    "timeout",
+				Default: time.Second * 5,
+				Desc:    "Sets timeout for http.Client to be used",
+			},
+			&flags.IntFlag{
+				Name: "max-conns-per-host",
+				Desc: "Sets the maximum connections open to the synthetic site",
+			},
+			&flags.StringFlag{
+				Name: "cpuprofile",
+				Desc: "Sets the file the cpu profile of the crawl is written into",
+			},
+			&flags.StringFlag{
+				Name: "memprofile",
+				Desc: "Sets the file the allocation profile of the crawl is written into",
+			},
+		},
+		Action: func(ctx flags.Context) error {
+			commands.Add(1)
+			defer commands.Done()
+
+			var config benchConfig
+			config.Site.Pages, _ = ctx.GetInt("pages")
+			config.Site.Branching, _ = ctx.GetInt("branching")
+			config.Site.Errors, _ = ctx.GetInt("errors")
+			config.Site.Redirects, _ = ctx.GetInt("redirects")
+			config.Site.Latency, _ = ctx.GetDuration("latency")
+			config.Depth, _ = ctx.GetInt("depth")
+			config.Workers, _ = ctx.GetInt("workers")
+			config.Timeout, _ = ctx.GetDuration("timeout")
+			config.MaxConnsPerHost, _ = ctx.GetInt("max-conns-per-host")
+			config.CPUProfile, _ = ctx.GetString("cpuprofile")
+			config.MemProfile, _ = ctx.GetString("memprofile")
+
+			if rate, _ := ctx.GetString("rate"); rate != "" {
+				var err error
+				if config.Rate, err = crawler.ParseRate(rate); err != nil {
+					return err
+				}
+			}
+
+			benchCtx, cancelBench := context.WithCancel(context.Background())
+			defer cancelBench()
+
+			signals := trapSignals(cancelBench)
+			defer signals.Stop()
+
+			fmt.Fprintf(os.Stderr, "Crawling synthetic site of %d pages, %d links per page with %d workers.\n", config.Site.Pages, config.Site.Branching, config.Workers)
+
+			result, err := runBench(benchCtx, config)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Pages:\t\t%d\n", result.Pages)
+			fmt.Printf("Broken links:\t%d\n", result.Broken)
+			fmt.Printf("Duration:\t%s\n", formatDuration(result.Duration))
+			fmt.Printf("Throughput:\t%.1f pages/s\n", result.PagesPerSecond())
+			fmt.Printf("Peak heap:\t%.1f MiB\n", float64(result.PeakHeap)/(1<<20))
+			fmt.Printf("Allocated:\t%.1f MiB\n", float64(result.TotalAlloc)/(1<<20))
+			fmt.Printf("Allocations:\t%d, %d per page\n", result.Mallocs, result.AllocsPerPage())
+			fmt.Printf("GC cycles:\t%d\n", result.GCs)
+			fmt.Printf("Goroutines:\t%d peak\n", result.PeakGoroutines)
+			return nil
+		},
+	}, flags.Command{
+		Name:      "soak",
+		ShortDesc: "Repeatedly crawls a website or synthetic site, failing if resources leak.",
+		Desc:      "Soak crawls provided website URL, else a synthetic site served locally, for many iterations, tracking goroutines, heap size and open file descriptors after each and failing if any grows over every iteration, as daemon deployments would leak.",
+		Usages:    []string{"sitecrawler soak", "sitecrawler -soak.iterations=50 soak https://monzo.com"},
+		Flags: []flags.Flag{
+			&flags.IntFlag{
+				Default: 10,
+				Name:    "iterations",
+				Desc:    "Sets the total crawls run",
+			},
+			&flags.IntFlag{
+				Default: 1000,
+				Name:    "pages",
+				Desc:    "Sets the total pages of the synthetic site crawled without a website url",
+			},
+			&flags.IntFlag{
+				Default: 10,
+				Name:    "branching",
+				Desc:    "Sets the links to child pages of every page of the synthetic site",
+			},
+			&flags.IntFlag{
+				Name: "depth",
+				Desc: "Sets the depth to crawl through giving site",
+			},
+			&flags.IntFlag{
+				Default: 300,
+				Name:    "workers",
+				Desc:    "Sets the total workers allowed by goroutine worker pool",
+			},
+			&flags.StringFlag{
+				Name: "rate",
+				Desc: "Sets the maximum rate of requests per host e.g 5/s, 100/m",
+			},
+			&flags.DurationFlag{
+				Name:    "timeout",
+				Default: time.Second * 5,
+				Desc:    "Sets timeout for http.Client to be used",
+			},
+			&flags.DurationFlag{
+				Name:    "settle",
+				Default: time.Millisecond * 100,
+				Desc:    "Sets the pause after every crawl before resources are sampled, letting closed connections wind down",
+			},
+		},
+		Action: func(ctx flags.Context) error {
+			commands.Add(1)
+			defer commands.Done()
+
+			var config soakConfig
+			config.Iterations, _ = ctx.GetInt("iterations")
+			config.Site.Pages, _ = ctx.GetInt("pages")
+			config.Site.Branching, _ = ctx.GetInt("branching")
+			config.Depth, _ = ctx.GetInt("depth")
+			config.Workers, _ = ctx.GetInt("workers")
+			config.Timeout, _ = ctx.GetDuration("timeout")
+			config.Settle, _ = ctx.GetDuration("settle")
+
+			if len(ctx.Args()) != 0 {
+				target, err := url.Parse(ctx.Args()[0])
+				if err != nil {
+					return fmt.Errorf("url error: %+s for %+q", err, ctx.Args()[0])
+				}
+
+				if target.Host == "" {
+					return fmt.Errorf("provided url has no host path")
+				}
+				config.Target = target
+			}
+
+			if rate, _ := ctx.GetString("rate"); rate != "" {
+				var err error
+				if config.Rate, err = crawler.ParseRate(rate); err != nil {
+					return err
+				}
+			}
+
+			soakCtx, cancelSoak := context.WithCancel(context.Background())
+			defer cancelSoak()
+
+			signals := trapSignals(cancelSoak)
+			defer signals.Stop()
+
+			_, err := runSoak(soakCtx, config, func(sample soakSample) {
+				fmt.Fprintf(os.Stderr, "Iteration %d: %d pages in %s, %d goroutines, %.1f MiB heap, %d fds\n", sample.Iteration, sample.Pages,
+					formatDuration(sample.Duration), sample.Goroutines, float64(sample.HeapAlloc)/(1<<20), sample.FDs)
+			})
+			return err
+		},
+	}, flags.Command{
+		Name:      "analyze",
+		ShortDesc: "Analyses a stored crawl without network access.",
+		Desc:      "Analyze restores a crawl from the database file of it's store, as written by `crawl -crawl.store`, or from it's ndjson reports, gzipped, zstd compressed or not, rebuilding it's link graph to list the most linked broken urls (top-broken), the slowest pages (slowest), the deepest pages (deepest), pages no other page links to (orphans) or the shape of the graph (graph-stats).",
+		Usages:    []string{"sitecrawler analyze graph-stats crawl.db", "sitecrawler -analyze.top=50 analyze top-broken reports.ndjson.gz", "sitecrawler -analyze.store=json analyze orphans crawl.json"},
+		Flags: []flags.Flag{
+			&flags.IntFlag{
+				Default: 20,
+				Name:    "top",
+				Desc:    "Sets the most urls listed by top-broken, slowest and deepest, 0 lists all",
+			},
+			&flags.StringFlag{
+				Name: "store",
+				Desc: "Sets the kind of store whose database file holds the crawl, detected for bolt and sqlite stores, else the file is read as ndjson reports (bolt, sqlite, json)",
+			},
+		},
+		Action: func(ctx flags.Context) error {
+			if len(ctx.Args()) < 2 {
+				return errors.New("must provide analysis and store or ndjson reports of crawl. Run `analyze help`")
+			}
+
+			storeKind, _ := ctx.GetString("store")
+			crawl, err := openCrawl(ctx.Args()[1], storeKind)
+			if err != nil {
+				return err
+			}
+
+			top, _ := ctx.GetInt("top")
+			return runAnalysis(os.Stdout, crawl, ctx.Args()[0], top)
+		},
+	})
+}
+
+// run runs the command selected by the process arguments out of giving
+// commands, waiting on it to finish. The process exits with status 1 if the
+// command's action returns an error, so scripts and CI jobs can tell failed
+// crawls apart from successful ones.
+func run(title string, cmds ...flags.Command) {
+	for index, cmd := range cmds {
+		action := cmd.Action
+		cmds[index].Action = func(ctx flags.Context) error {
+			if err := action(ctx); err != nil {
+				atomic.AddInt32(&failures, 1)
+				fmt.Fprintln(os.Stderr, err)
+			}
+			return nil
+		}
+	}
+
+	flags.Run(title, cmds...)
+	commands.Wait()
+
+	if atomic.LoadInt32(&failures) != 0 {
+		os.Exit(1)
+	}
+}
+
+// ErrStoreCache is returned when a cache file is set along with a store, which
+// keeps the page validators of the crawl in it's place.
+var ErrStoreCache = errors.New("page validators are kept in the crawl's store, a cache file can't be combined with a store")
+
+// ErrSubmitBaseline is returned when urls are submitted without the state of a
+// previous crawl to compare them against, as every url would be submitted.
+var ErrSubmitBaseline = errors.New("submitted urls are compared against the previous crawl, which requires a persistent store, a cache file or a delta directory")
+
+// stringsFlag implements the flags.Flag interface for a string flag which can
+// be repeated, collecting all provided values.
+type stringsFlag struct {
+	Name    string
+	Desc    string
+	Default []string
+	values  stringValues
+}
+
+// FlagName returns name of flag.
+func (s *stringsFlag) FlagName() string {
+	return s.Name
+}
+
+// DefaultValue returns default value of flag.
+func (s *stringsFlag) DefaultValue() interface{} {
+	return s.Default
+}
+
+// Value returns all collected values of flag, else it's default.
+func (s *stringsFlag) Value() interface{} {
+	if len(s.values) == 0 {
+		return s.Default
+	}
+	return []string(s.values)
+}
+
+// Parse sets the underline flag ready for value receiving.
+func (s *stringsFlag) Parse(cmd string) error {
+	flag.Var(&s.values, fmt.Sprintf("%s.%s", strings.ToLower(cmd), s.Name), s.Desc)
+	return nil
+}
+
+// stringValues implements the flag.Value interface, appending every set value.
+type stringValues []string
+
+// String implements the flag.Value interface.
+func (s *stringValues) String() string {
+	return strings.Join(*s, ", ")
+}
+
+// Set implements the flag.Value interface.
+func (s *stringValues) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseHeaders parses giving list of `Name: value` headers.
+func parseHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, value := range values {
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header %+q, expected \"Name: value\"", value)
+		}
+
+		headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return headers, nil
+}
+
+// parseCookies parses giving list of `name=value` cookies, where each value can
+// hold multiple cookies separated by `;`.
+func parseCookies(values []string) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ";") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				return nil, fmt.Errorf("invalid cookie %+q, expected \"name=value\"", pair)
+			}
+
+			cookies = append(cookies, &http.Cookie{
+				Name:  strings.TrimSpace(parts[0]),
+				Value: strings.TrimSpace(parts[1]),
+			})
+		}
+	}
+	return cookies, nil
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
+)
+
+// mainArgsEnv sets the arguments sitecrawler is run with by TestMainProcess,
+// separated by newlines.
+const mainArgsEnv = "SITECRAWLER_TEST_ARGS"
+
+// mainLeakEnv sets TestMainProcess to leak goroutines while sitecrawler runs,
+// so soak runs can be seen to fail.
+const mainLeakEnv = "SITECRAWLER_TEST_LEAK"
+
+// TestMainProcess runs sitecrawler with the arguments set by runMain, doing
+// nothing when run as part of the tests.
+func TestMainProcess(t *testing.T) {
+	args, ok := os.LookupEnv(mainArgsEnv)
+	if !ok {
+		return
+	}
+
+	if os.Getenv(mainLeakEnv) != "" {
+		go func() {
+			for range time.Tick(2 * time.Millisecond) {
+				go func() { select {} }()
+			}
+		}()
+	}
+
+	os.Args = append([]string{"sitecrawler"}, strings.Split(args, "\n")...)
+	main()
+	os.Exit(0)
+}
+
+// runMain runs sitecrawler with giving args in a child process, returning it's
+// exit status and what it wrote into stderr.
+func runMain(args ...string) (int, string, error) {
+	return runMainEnv(nil, args...)
+}
+
+// runMainEnv runs sitecrawler like runMain with giving environment variables
+// added.
+func runMainEnv(env []string, args ...string) (int, string, error) {
+	return runMainInto(nil, env, args...)
+}
+
+// runMainStdout runs sitecrawler like runMain, returning what it wrote to
+// stdout along with it's exit status and stderr.
+func runMainStdout(args ...string) (string, int, string, error) {
+	var stdout bytes.Buffer
+	status, stderr, err := runMainInto(&stdout, nil, args...)
+	return stdout.String(), status, stderr, err
+}
+
+// runMainInto runs sitecrawler like runMainEnv, writing it's stdout into
+// giving writer.
+func runMainInto(stdout io.Writer, env []string, args ...string) (int, string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainProcess$")
+	cmd.Env = append(os.Environ(), mainArgsEnv+"="+strings.Join(args, "\n"))
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return exit.ExitCode(), stderr.String(), nil
+	}
+	return 0, stderr.String(), err
+}
+
+func TestMainExitStatus(t *testing.T) {
+	server := sitetest.NewServer(sitetest.Options{Pages: 10, Branching: 3})
+	defer server.Close()
+
+	status, stderr, err := runMain("-crawl.format=bogus", "crawl", server.Target().String())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	if status != 1 || !strings.Contains(stderr, "unknown output format") {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have exited with status 1 when crawl fails")
+	}
+	tests.Passed("Should have exited with status 1 when crawl fails")
+
+	status, stderr, err = runMain("crawl", server.Target().String())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	if status != 0 {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have exited with status 0 when crawl succeeds")
+	}
+	tests.Passed("Should have exited with status 0 when crawl succeeds")
+}
+
+func TestMainExitStatusParseFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Stray</span></p></body></html>`)
+	}))
+	defer server.Close()
+
+	status, stderr, err := runMain("-crawl.max-parse-failures=0", "crawl", server.URL)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	if status != 1 || !strings.Contains(stderr, "1 pages failed to parse, exceeding max of 0") {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have exited with status 1 when pages fail to parse beyond max")
+	}
+	tests.Passed("Should have exited with status 1 when pages fail to parse beyond max")
+}
+
+func TestCrawlReadTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.Method == http.MethodHead || r.URL.Path != "/" {
+			return
+		}
+
+		// Drip the page past the crawl's timeout, with no read stalling
+		// beyond the read timeout.
+		for chunk := 0; chunk < 8; chunk++ {
+			fmt.Fprint(w, `<p>steady</p>`)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+		fmt.Fprint(w, `<a href="/found"></a>`)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "crawl-read-timeout")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+
+	crawl := func(args ...string) string {
+		args = append([]string{"-crawl.timeout=200ms", "-crawl.format=ndjson", "-crawl.split-output-by=host", "-crawl.output-dir=" + dir}, args...)
+		if status, stderr, err := runMain(append(args, "crawl", server.URL)...); err != nil || status != 0 {
+			tests.Info("Status: %d, Stderr: %q", status, stderr)
+			tests.FailedWithError(err, "Should have successfully run crawl")
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, sanitizeFileName(target.Host)+".ndjson"))
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully read crawl reports")
+		}
+		return string(data)
+	}
+
+	if reports := crawl(); strings.Contains(reports, "/found") {
+		tests.Info("Reports: %s", reports)
+		tests.Failed("Should have cut short page outliving the timeout")
+	}
+	tests.Passed("Should have cut short page outliving the timeout")
+
+	if reports := crawl("-crawl.read-timeout=150ms"); !strings.Contains(reports, server.URL+"/found") {
+		tests.Info("Reports: %s", reports)
+		tests.Failed("Should have read page outliving the timeout with a read timeout")
+	}
+	tests.Passed("Should have read page outliving the timeout with a read timeout")
+}
+
+func TestCrawlStore(t *testing.T) {
+	// Once trimmed, the index stops linking to /blog.
+	var trimmed int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, etag := `<a href="/"></a>`, fmt.Sprintf("%q", r.URL.Path)
+		switch {
+		case r.URL.Path != "/":
+		case atomic.LoadInt32(&trimmed) == 1:
+			body, etag = `<a href="/about"></a>`, `"/trimmed"`
+		default:
+			body = `<a href="/about"></a><a href="/blog"></a>`
+		}
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "crawl-store")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	status, stderr, err := runMain("-crawl.store=bolt", "-crawl.cache="+filepath.Join(dir, "cache.json"), "crawl", server.URL)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	if status != 1 || !strings.Contains(stderr, ErrStoreCache.Error()) {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have refused a cache file along with a store")
+	}
+	tests.Passed("Should have refused a cache file along with a store")
+
+	crawl := func(args ...string) string {
+		status, stderr, err := runMain(append(args, "crawl", server.URL)...)
+		if err != nil || status != 0 {
+			tests.Info("Status: %d, Stderr: %q", status, stderr)
+			tests.FailedWithError(err, "Should have successfully run crawl")
+		}
+		return stderr
+	}
+
+	for _, kind := range []crawler.StoreKind{crawler.StoreBolt, crawler.StoreSQLite, crawler.StoreJSON} {
+		atomic.StoreInt32(&trimmed, 0)
+
+		path := filepath.Join(dir, string(kind)+".db")
+		deltaDir := filepath.Join(dir, string(kind)+"-delta")
+		args := []string{"-crawl.store=" + string(kind), "-crawl.store-path=" + path, "-crawl.emit-delta=" + deltaDir}
+
+		crawl(args...)
+		if stderr := crawl(args...); !strings.Contains(stderr, "Pages unchanged since last crawl: 3") {
+			tests.Info("Stderr: %q", stderr)
+			tests.Failed("Should have revalidated pages against validators of %s store", kind)
+		}
+		tests.Passed("Should have revalidated pages against validators of %s store", kind)
+
+		atomic.StoreInt32(&trimmed, 1)
+		if stderr := crawl(args...); !strings.Contains(stderr, "Delta: 0 urls added, 1 removed since previous crawl") {
+			tests.Info("Stderr: %q", stderr)
+			tests.Failed("Should have listed delta against urls of previous crawl in %s store", kind)
+		}
+		tests.Passed("Should have listed delta against urls of previous crawl in %s store", kind)
+
+		if _, err := os.Stat(filepath.Join(deltaDir, deltaStateFile)); !os.IsNotExist(err) {
+			tests.Failed("Should have kept urls of crawl in %s store in place of the state file", kind)
+		}
+		tests.Passed("Should have kept urls of crawl in %s store in place of the state file", kind)
+
+		store, err := crawler.OpenStore(kind, path)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully opened %s store", kind)
+		}
+
+		var seen, results, cached, urls int
+		store.Each(crawler.StoreSeen, func(string, []byte) error { seen++; return nil })
+		store.Each(crawler.StoreResults, func(string, []byte) error { results++; return nil })
+		store.Each(crawler.StoreCache, func(string, []byte) error { cached++; return nil })
+		store.Each(crawler.StoreURLs, func(string, []byte) error { urls++; return nil })
+		store.Close()
+
+		if seen != 2 || results != 2 || urls != 2 || cached != 3 {
+			tests.Info("Seen: %d, Results: %d, Urls: %d, Cached: %d", seen, results, urls, cached)
+			tests.Failed("Should have replaced seen set and results of previous crawls in %s store, keeping validators", kind)
+		}
+		tests.Passed("Should have replaced seen set and results of previous crawls in %s store, keeping validators", kind)
+	}
+
+	atomic.StoreInt32(&trimmed, 0)
+
+	cacheFile := filepath.Join(dir, "cache.json")
+	crawl("-crawl.cache=" + cacheFile)
+	if stderr := crawl("-crawl.cache=" + cacheFile); !strings.Contains(stderr, "Pages unchanged since last crawl: 3") {
+		tests.Info("Stderr: %q", stderr)
+		tests.Failed("Should have revalidated pages against validators of cache file")
+	}
+	tests.Passed("Should have revalidated pages against validators of cache file")
+
+	store, err := crawler.OpenStore(crawler.StoreJSON, cacheFile)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully opened cache file as json store")
+	}
+	defer store.Close()
+
+	var cached, results int
+	store.Each(crawler.StoreCache, func(string, []byte) error { cached++; return nil })
+	store.Each(crawler.StoreResults, func(string, []byte) error { results++; return nil })
+
+	if cached != 3 || results != 0 {
+		tests.Info("Cached: %d, Results: %d", cached, results)
+		tests.Failed("Should have kept page validators alone in cache file")
+	}
+	tests.Passed("Should have kept page validators alone in cache file")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// outlinkOrigins implements the filtering of the outlinks written to reports
+// by the element and attribute they were farmed from, such as a[href] for
+// navigational links or img[src] for asset references. An origin given as an
+// element alone, such as img, matches all of it's attributes. Outlinks of no
+// origin, such as those referenced by stylesheets or feeds, are dropped. A nil
+// outlinkOrigins leaves reports as is.
+type outlinkOrigins map[string]bool
+
+// parseOutlinkOrigins returns the outlinkOrigins of giving origins. It returns
+// nil if no origin is provided.
+func parseOutlinkOrigins(values []string) outlinkOrigins {
+	origins := outlinkOrigins{}
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			origins[value] = true
+		}
+	}
+
+	if len(origins) == 0 {
+		return nil
+	}
+	return origins
+}
+
+// Report returns a copy of giving report pointing only to the outlinks of the
+// origins kept.
+func (o outlinkOrigins) Report(report crawler.LinkReport) crawler.LinkReport {
+	if o == nil || report.PointsTo == nil {
+		return report
+	}
+
+	kids := make([]crawler.LinkReport, 0, len(report.PointsTo))
+	for _, kid := range report.PointsTo {
+		if o.Has(kid.Origin) {
+			kids = append(kids, kid)
+		}
+	}
+
+	report.PointsTo = kids
+	return report
+}
+
+// Has returns true if outlinks of giving origin are kept.
+func (o outlinkOrigins) Has(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	element := origin
+	if index := strings.IndexByte(origin, '['); index != -1 {
+		element = origin[:index]
+	}
+	return o[origin] || o[element]
+}
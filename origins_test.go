@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestOutlinkOrigins(t *testing.T) {
+	page, err := url.Parse("https://example.com/")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	report := crawler.LinkReport{
+		Path: page,
+		PointsTo: []crawler.LinkReport{
+			{Path: page, Origin: "a[href]"},
+			{Path: page, Origin: "img[src]"},
+			{Path: page, Origin: "img[srcset]"},
+			{Path: page, Origin: "script[src]"},
+			{Path: page, Stylesheet: "https://example.com/main.css"},
+		},
+	}
+
+	if parseOutlinkOrigins([]string{" ", ""}) != nil || len(parseOutlinkOrigins(nil).Report(report).PointsTo) != 5 {
+		tests.Failed("Should have kept all outlinks without origins")
+	}
+	tests.Passed("Should have kept all outlinks without origins")
+
+	filtered := parseOutlinkOrigins([]string{"A[href]", "img"}).Report(report)
+
+	var origins []string
+	for _, kid := range filtered.PointsTo {
+		origins = append(origins, kid.Origin)
+	}
+
+	if len(origins) != 3 || origins[0] != "a[href]" || origins[1] != "img[src]" || origins[2] != "img[srcset]" {
+		tests.Info("Received: %#v", origins)
+		tests.Failed("Should have kept only outlinks of given origins")
+	}
+	tests.Passed("Should have kept only outlinks of given origins")
+
+	if len(report.PointsTo) != 5 {
+		tests.Failed("Should have left original report untouched")
+	}
+	tests.Passed("Should have left original report untouched")
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// ErrParquetCompression is returned when parquet reports are written with an
+// output compression, as their pages are already compressed by the encoder
+// and query engines can't read compressed parquet files.
+var ErrParquetCompression = errors.New("parquet reports are compressed with snappy by their encoder, expected no output compression")
+
+// checkParquetCompression returns ErrParquetCompression if giving format is
+// parquet and provided compression isn't none.
+func checkParquetCompression(format string, compression string) error {
+	if strings.ToLower(format) != "parquet" {
+		return nil
+	}
+
+	switch strings.ToLower(compression) {
+	case "", "none":
+		return nil
+	}
+	return ErrParquetCompression
+}
+
+// parquetSchema holds the schema of parquet reports, with one row per url and
+// it's outgoing links nested within as a list. Nested report fields without a
+// fixed shape, such as social tags and metadata, are written as json.
+const parquetSchema = `message report {
+	required binary url (STRING);
+	required group status {
+		required boolean is_live;
+		required boolean is_crawlable;
+		required int32 last_status;
+		optional int64 at (TIMESTAMP(MILLIS, true));
+		optional binary reason_code (STRING);
+		optional binary reason_message (STRING);
+	}
+	optional binary title (STRING);
+	optional binary language (STRING);
+	optional binary direction (STRING);
+	optional binary content_type (STRING);
+	optional binary sniffed_type (STRING);
+	required int64 content_length;
+	required double latency_ms;
+	optional binary owner (STRING);
+	optional group redirects (LIST) {
+		repeated group list {
+			required group element {
+				required binary url (STRING);
+				required int32 status;
+			}
+		}
+	}
+	required boolean long_redirect;
+	required boolean cached;
+	required boolean truncated;
+	optional binary content_encoding (STRING);
+	required int64 encoded_size;
+	required int64 decoded_size;
+	optional binary remote_ip (STRING);
+	optional binary ip_family (STRING);
+	required int32 asn;
+	optional binary as_org (STRING);
+	optional binary server (STRING);
+	optional binary robots (JSON);
+	optional group parse_warnings (LIST) {
+		repeated group list {
+			required binary element (STRING);
+		}
+	}
+	optional binary route_error (STRING);
+	optional binary canonical (STRING);
+	required boolean canonical_mismatch;
+	optional group alternates (LIST) {
+		repeated group list {
+			required group element {
+				required binary hreflang (STRING);
+				required binary url (STRING);
+			}
+		}
+	}
+	optional binary social (JSON);
+	optional binary structured_data (JSON);
+	optional binary cache_before (STRING);
+	optional binary cache_after (STRING);
+	optional binary metadata (JSON);
+	required group outlinks (LIST) {
+		repeated group list {
+			required group element {
+				required binary url (STRING);
+				required group status {
+					required boolean is_live;
+					required boolean is_crawlable;
+					required int32 last_status;
+					optional int64 at (TIMESTAMP(MILLIS, true));
+					optional binary reason_code (STRING);
+					optional binary reason_message (STRING);
+				}
+				optional binary owner (STRING);
+				optional binary position (STRING);
+				optional group rel (LIST) {
+					repeated group list {
+						required binary element (STRING);
+					}
+				}
+				optional binary anchor_text (STRING);
+				optional binary heading (STRING);
+				optional binary origin (STRING);
+				optional binary redirected_to (STRING);
+				optional group redirects (LIST) {
+					repeated group list {
+						required group element {
+							required binary url (STRING);
+							required int32 status;
+						}
+					}
+				}
+				required boolean long_redirect;
+				optional group stripped_params (LIST) {
+					repeated group list {
+						required binary element (STRING);
+					}
+				}
+				optional binary stylesheet (STRING);
+				optional binary feed (STRING);
+				optional binary json (STRING);
+				optional binary cache_before (STRING);
+				optional binary cache_after (STRING);
+			}
+		}
+	}
+}`
+
+// parquetWriter implements the ReportWriter for the parquet format, writing
+// one row per url with it's outgoing links nested within, so reports can be
+// queried directly by engines such as Athena and DuckDB. Rows are kept in row
+// groups by the encoder till Flush writes the file's footer.
+type parquetWriter struct {
+	file *goparquet.FileWriter
+}
+
+// newParquetWriter returns a new parquetWriter writing into w.
+func newParquetWriter(w io.Writer) (*parquetWriter, error) {
+	schema, err := parquetschema.ParseSchemaDefinition(parquetSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parquetWriter{
+		file: goparquet.NewFileWriter(w,
+			goparquet.WithSchemaDefinition(schema),
+			goparquet.WithCompressionCodec(parquet.CompressionCodec_SNAPPY),
+			goparquet.WithCreator("sitecrawler"),
+		),
+	}, nil
+}
+
+// Write adds giving report as a row of the parquet file.
+func (p *parquetWriter) Write(report crawler.LinkReport) error {
+	row := newReportRow(report)
+
+	data := map[string]interface{}{
+		"url":                []byte(row.URL),
+		"status":             parquetStatus(row.Status),
+		"content_length":     row.ContentLength,
+		"latency_ms":         row.LatencyMS,
+		"long_redirect":      row.LongRedirect,
+		"cached":             row.Cached,
+		"truncated":          row.Truncated,
+		"encoded_size":       row.EncodedSize,
+		"decoded_size":       row.DecodedSize,
+		"asn":                int32(row.ASN),
+		"canonical_mismatch": row.NonCanonical,
+	}
+
+	parquetString(data, "title", row.Title)
+	parquetString(data, "language", row.Language)
+	parquetString(data, "direction", row.Direction)
+	parquetString(data, "content_type", row.ContentType)
+	parquetString(data, "sniffed_type", row.SniffedType)
+	parquetString(data, "owner", row.Owner)
+	parquetString(data, "content_encoding", row.ContentEncoding)
+	parquetString(data, "remote_ip", row.RemoteIP)
+	parquetString(data, "ip_family", string(row.IPFamily))
+	parquetString(data, "as_org", row.ASOrg)
+	parquetString(data, "server", row.Server)
+	parquetString(data, "route_error", row.RouteError)
+	parquetString(data, "canonical", row.Canonical)
+	parquetString(data, "cache_before", string(row.CacheBefore))
+	parquetString(data, "cache_after", string(row.CacheAfter))
+	parquetHops(data, "redirects", row.Redirects)
+	parquetStrings(data, "parse_warnings", row.ParseWarnings)
+
+	if len(row.Alternates) != 0 {
+		alternates := make([]map[string]interface{}, 0, len(row.Alternates))
+		for _, alternate := range row.Alternates {
+			alternates = append(alternates, parquetElement(map[string]interface{}{
+				"hreflang": []byte(alternate.Hreflang),
+				"url":      []byte(alternate.URL),
+			}))
+		}
+		data["alternates"] = parquetList(alternates)
+	}
+
+	if row.Robots != nil {
+		if err := parquetJSON(data, "robots", row.Robots); err != nil {
+			return err
+		}
+	}
+
+	if row.Social != nil {
+		if err := parquetJSON(data, "social", row.Social); err != nil {
+			return err
+		}
+	}
+
+	if row.Structured != nil {
+		if err := parquetJSON(data, "structured_data", row.Structured); err != nil {
+			return err
+		}
+	}
+
+	if len(row.Metadata) != 0 {
+		if err := parquetJSON(data, "metadata", row.Metadata); err != nil {
+			return err
+		}
+	}
+
+	outlinks := make([]map[string]interface{}, 0, len(row.Outlinks))
+	for _, outlink := range row.Outlinks {
+		kid := map[string]interface{}{
+			"url":           []byte(outlink.URL),
+			"status":        parquetStatus(outlink.Status),
+			"long_redirect": outlink.LongRedirect,
+		}
+
+		parquetString(kid, "owner", outlink.Owner)
+		parquetString(kid, "position", string(outlink.Position))
+		parquetString(kid, "anchor_text", outlink.AnchorText)
+		parquetString(kid, "heading", outlink.Heading)
+		parquetString(kid, "origin", outlink.Origin)
+		parquetString(kid, "redirected_to", outlink.RedirectedTo)
+		parquetString(kid, "stylesheet", outlink.Stylesheet)
+		parquetString(kid, "feed", outlink.Feed)
+		parquetString(kid, "json", outlink.JSON)
+		parquetString(kid, "cache_before", string(outlink.CacheBefore))
+		parquetString(kid, "cache_after", string(outlink.CacheAfter))
+		parquetStrings(kid, "rel", outlink.Rel)
+		parquetStrings(kid, "stripped_params", outlink.Stripped)
+		parquetHops(kid, "redirects", outlink.Redirects)
+
+		outlinks = append(outlinks, parquetElement(kid))
+	}
+	data["outlinks"] = parquetList(outlinks)
+
+	return p.file.AddData(data)
+}
+
+// Flush writes the remaining rows and footer of the parquet file.
+func (p *parquetWriter) Flush() error {
+	return p.file.Close()
+}
+
+// parquetStatus returns the status group of giving status.
+func parquetStatus(status crawler.Status) map[string]interface{} {
+	group := map[string]interface{}{
+		"is_live":      status.IsLive,
+		"is_crawlable": status.IsCrawlable,
+		"last_status":  int32(status.LastStatus),
+	}
+
+	if !status.At.IsZero() {
+		group["at"] = status.At.UnixNano() / 1e6
+	}
+
+	if status.Reason != nil {
+		parquetString(group, "reason_code", string(status.Reason.Code))
+		parquetString(group, "reason_message", status.Reason.Message)
+	}
+
+	return group
+}
+
+// parquetString sets the optional string field of giving name if value is
+// not empty.
+func parquetString(data map[string]interface{}, name string, value string) {
+	if value != "" {
+		data[name] = []byte(value)
+	}
+}
+
+// parquetStrings sets the optional list of strings of giving name if values
+// is not empty.
+func parquetStrings(data map[string]interface{}, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	elements := make([]map[string]interface{}, 0, len(values))
+	for _, value := range values {
+		elements = append(elements, parquetElement([]byte(value)))
+	}
+	data[name] = parquetList(elements)
+}
+
+// parquetHops sets the optional list of redirect hops of giving name if hops
+// is not empty.
+func parquetHops(data map[string]interface{}, name string, hops []crawler.RedirectHop) {
+	if len(hops) == 0 {
+		return
+	}
+
+	elements := make([]map[string]interface{}, 0, len(hops))
+	for _, hop := range hops {
+		elements = append(elements, parquetElement(map[string]interface{}{
+			"url":    []byte(hop.URL),
+			"status": int32(hop.Status),
+		}))
+	}
+	data[name] = parquetList(elements)
+}
+
+// parquetJSON sets the json field of giving name to the encoding of value.
+func parquetJSON(data map[string]interface{}, name string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	data[name] = encoded
+	return nil
+}
+
+// parquetElement returns the entry of a list holding giving element.
+func parquetElement(element interface{}) map[string]interface{} {
+	return map[string]interface{}{"element": element}
+}
+
+// parquetList returns the group of a list holding giving entries. Empty lists
+// are written without their repeated group, as the encoder rejects an empty
+// one.
+func parquetList(entries []map[string]interface{}) map[string]interface{} {
+	if len(entries) == 0 {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"list": entries}
+}
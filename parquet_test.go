@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
+)
+
+// readParquet returns the rows of giving parquet file.
+func readParquet(data []byte) ([]map[string]interface{}, error) {
+	reader, err := goparquet.NewFileReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]interface{}
+	for index := int64(0); index < reader.NumRows(); index++ {
+		row, err := reader.NextRow()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parquetElements returns the elements of the list field of giving name.
+func parquetElements(data map[string]interface{}, name string) []interface{} {
+	list, _ := data[name].(map[string]interface{})
+	entries, _ := list["list"].([]map[string]interface{})
+
+	elements := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		elements = append(elements, entry["element"])
+	}
+	return elements
+}
+
+func TestParquetWriter(t *testing.T) {
+	if ext := formatExtension("parquet", "none"); ext != ".parquet" {
+		tests.Info("Received Extension: %q", ext)
+		tests.Failed("Should have suffixed parquet outputs with .parquet")
+	}
+	tests.Passed("Should have suffixed parquet outputs with .parquet")
+
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	report := crawler.LinkReport{
+		Path:          &url.URL{Scheme: "https", Host: "example.com", Path: "/a b"},
+		Status:        crawler.Status{IsLive: true, IsCrawlable: true, LastStatus: 200, At: at},
+		Title:         "Home",
+		ContentLength: 512,
+		Latency:       1500 * time.Millisecond,
+		Redirects:     []crawler.RedirectHop{{URL: "http://example.com/a b", Status: 301}},
+		ParseWarnings: []string{"stray </span>"},
+		Metadata:      crawler.Metadata{"team": "web"},
+		PointsTo: []crawler.LinkReport{
+			{
+				Path:       &url.URL{Scheme: "https", Host: "example.com", Path: "/about"},
+				Status:     crawler.Status{IsLive: true, LastStatus: 200},
+				Rel:        []string{"nofollow", "ugc"},
+				AnchorText: "About",
+			},
+			{
+				Path:   &url.URL{Scheme: "https", Host: "example.com", Path: "/gone"},
+				Status: crawler.Status{LastStatus: 404, Reason: &crawler.Reason{Code: crawler.ReasonHTTPStatus, Message: "not found"}},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	writer, err := newReportWriter("parquet", &out)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created parquet writer")
+	}
+	tests.Passed("Should have successfully created parquet writer")
+
+	if err := writer.Write(report); err != nil {
+		tests.FailedWithError(err, "Should have successfully written report")
+	}
+
+	if err := writer.Write(crawler.LinkReport{Path: &url.URL{Scheme: "https", Host: "example.com", Path: "/empty"}}); err != nil {
+		tests.FailedWithError(err, "Should have successfully written report without outlinks")
+	}
+
+	if err := writer.Flush(); err != nil {
+		tests.FailedWithError(err, "Should have successfully flushed parquet file")
+	}
+	tests.Passed("Should have successfully written parquet file")
+
+	if !bytes.HasPrefix(out.Bytes(), []byte("PAR1")) || !bytes.HasSuffix(out.Bytes(), []byte("PAR1")) {
+		tests.Failed("Should have written parquet magic around the file")
+	}
+	tests.Passed("Should have written parquet magic around the file")
+
+	rows, err := readParquet(out.Bytes())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read parquet file")
+	}
+
+	if len(rows) != 2 {
+		tests.Info("Received Rows: %#v", rows)
+		tests.Failed("Should have written one row per url")
+	}
+	tests.Passed("Should have written one row per url")
+
+	row := rows[0]
+	status, _ := row["status"].(map[string]interface{})
+	if string(row["url"].([]byte)) != "https://example.com/a%20b" || string(row["title"].([]byte)) != "Home" ||
+		row["content_length"] != int64(512) || row["latency_ms"] != float64(1500) ||
+		status["last_status"] != int32(200) || status["is_live"] != true || status["at"] != at.UnixNano()/1e6 {
+		tests.Info("Received Row: %#v", row)
+		tests.Failed("Should have written encoded url, status and fields of report")
+	}
+	tests.Passed("Should have written encoded url, status and fields of report")
+
+	if _, ok := row["language"]; ok {
+		tests.Info("Received Row: %#v", row)
+		tests.Failed("Should have left empty fields of report null")
+	}
+	tests.Passed("Should have left empty fields of report null")
+
+	hops := parquetElements(row, "redirects")
+	warnings := parquetElements(row, "parse_warnings")
+	if len(hops) != 1 || string(hops[0].(map[string]interface{})["url"].([]byte)) != "http://example.com/a%20b" ||
+		len(warnings) != 1 || string(warnings[0].([]byte)) != "stray </span>" {
+		tests.Info("Received Row: %#v", row)
+		tests.Failed("Should have written redirects and parse warnings as lists")
+	}
+	tests.Passed("Should have written redirects and parse warnings as lists")
+
+	var metadata crawler.Metadata
+	if err := json.Unmarshal(row["metadata"].([]byte), &metadata); err != nil || metadata["team"] != "web" {
+		tests.Info("Received Row: %#v", row)
+		tests.Failed("Should have written metadata as json")
+	}
+	tests.Passed("Should have written metadata as json")
+
+	outlinks := parquetElements(row, "outlinks")
+	if len(outlinks) != 2 {
+		tests.Info("Received Row: %#v", row)
+		tests.Failed("Should have nested outlinks of report within it's row")
+	}
+
+	about := outlinks[0].(map[string]interface{})
+	rel := parquetElements(about, "rel")
+	if string(about["url"].([]byte)) != "https://example.com/about" || string(about["anchor_text"].([]byte)) != "About" ||
+		len(rel) != 2 || string(rel[1].([]byte)) != "ugc" {
+		tests.Info("Received Outlink: %#v", about)
+		tests.Failed("Should have written fields of nested outlinks")
+	}
+
+	gone := outlinks[1].(map[string]interface{})["status"].(map[string]interface{})
+	if gone["last_status"] != int32(404) || gone["is_live"] != false || string(gone["reason_code"].([]byte)) != "http-status" {
+		tests.Info("Received Outlink Status: %#v", gone)
+		tests.Failed("Should have written status and reason of nested outlinks")
+	}
+	tests.Passed("Should have nested outlinks of report within it's row")
+
+	if empty := parquetElements(rows[1], "outlinks"); len(empty) != 0 {
+		tests.Info("Received Row: %#v", rows[1])
+		tests.Failed("Should have written an empty list for reports without outlinks")
+	}
+	tests.Passed("Should have written an empty list for reports without outlinks")
+}
+
+func TestParquetCrawl(t *testing.T) {
+	server := sitetest.NewServer(sitetest.Options{Pages: 10, Branching: 3})
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "crawl-parquet")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	status, stderr, err := runMain("-crawl.format=parquet", "-crawl.compress=gzip", "crawl", server.Target().String())
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	if status != 1 || !strings.Contains(stderr, ErrParquetCompression.Error()) {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have refused to compress parquet reports")
+	}
+	tests.Passed("Should have refused to compress parquet reports")
+
+	args := []string{"-crawl.format=parquet", "-crawl.split-output-by=host", "-crawl.output-dir=" + dir, "crawl", server.Target().String()}
+	if status, stderr, err := runMain(args...); err != nil || status != 0 {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, sanitizeFileName(server.Target().Host)+".parquet"))
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read parquet reports")
+	}
+
+	rows, err := readParquet(data)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully read parquet reports")
+	}
+
+	var outlinks int
+	for _, row := range rows {
+		outlinks += len(parquetElements(row, "outlinks"))
+	}
+
+	if len(rows) != 10 || outlinks == 0 {
+		tests.Info("Rows: %d, Outlinks: %d", len(rows), outlinks)
+		tests.Failed("Should have written a row with nested outlinks per crawled url")
+	}
+	tests.Passed("Should have written a row with nested outlinks per crawled url")
+}
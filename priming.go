@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// primingAudit embodies the cache statuses reported for the urls primed by a
+// crawl, before and after each was requested, for checking caches were warmed
+// after a deploy.
+type primingAudit struct {
+	urls map[string]primedURL
+}
+
+// primedURL embodies a primed url and it's cache status before and after it
+// was requested.
+type primedURL struct {
+	URL    string
+	Before crawler.CacheStatus
+	After  crawler.CacheStatus
+}
+
+// newPrimingAudit returns a new empty primingAudit.
+func newPrimingAudit() *primingAudit {
+	return &primingAudit{urls: map[string]primedURL{}}
+}
+
+// Observe records the cache statuses of giving page report and it's outlinks,
+// once per url. Urls reporting no cache status are not recorded.
+func (p *primingAudit) Observe(report crawler.LinkReport) {
+	p.add(report)
+	for _, kid := range report.PointsTo {
+		p.add(kid)
+	}
+}
+
+// add records the cache statuses of giving report if it's url is unseen.
+func (p *primingAudit) add(report crawler.LinkReport) {
+	if report.Path == nil || report.CacheBefore == "" && report.CacheAfter == "" {
+		return
+	}
+
+	link := report.Path.String()
+	if _, ok := p.urls[link]; ok {
+		return
+	}
+	p.urls[link] = primedURL{URL: link, Before: report.CacheBefore, After: report.CacheAfter}
+}
+
+// Hits returns the total urls recorded and those hit before and after they
+// were primed.
+func (p *primingAudit) Hits() (total int, before int, after int) {
+	for _, primed := range p.urls {
+		if primed.Before == crawler.CacheHit {
+			before++
+		}
+		if primed.After == crawler.CacheHit {
+			after++
+		}
+	}
+	return len(p.urls), before, after
+}
+
+// Cold returns the urls recorded which were not hits once primed, sorted by
+// url.
+func (p *primingAudit) Cold() []primedURL {
+	var cold []primedURL
+	for _, primed := range p.urls {
+		if primed.After != crawler.CacheHit {
+			cold = append(cold, primed)
+		}
+	}
+
+	sort.Slice(cold, func(i, j int) bool {
+		return cold[i].URL < cold[j].URL
+	})
+	return cold
+}
+
+// cacheLabel returns giving cache status, or none if no status was reported.
+func cacheLabel(status crawler.CacheStatus) string {
+	if status == "" {
+		return "none"
+	}
+	return string(status)
+}
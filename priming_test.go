@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestPrimingAudit(t *testing.T) {
+	link := func(path string, before, after crawler.CacheStatus) crawler.LinkReport {
+		return crawler.LinkReport{
+			Path:        &url.URL{Scheme: "https", Host: "example.com", Path: path},
+			CacheBefore: before,
+			CacheAfter:  after,
+		}
+	}
+
+	page := link("/", crawler.CacheMiss, crawler.CacheHit)
+	page.PointsTo = []crawler.LinkReport{
+		link("/logo.png", crawler.CacheHit, crawler.CacheHit),
+		link("/search", crawler.CacheBypass, crawler.CacheBypass),
+		link("/about", crawler.CacheMiss, crawler.CacheMiss),
+		link("/external", "", ""),
+	}
+
+	audit := newPrimingAudit()
+	audit.Observe(page)
+	audit.Observe(link("/about", crawler.CacheMiss, crawler.CacheHit))
+
+	total, before, after := audit.Hits()
+	if total != 4 || before != 1 || after != 2 {
+		tests.Info("Total: %d, Before: %d, After: %d", total, before, after)
+		tests.Failed("Should have counted hits before and after once per url")
+	}
+	tests.Passed("Should have counted hits before and after once per url")
+
+	cold := audit.Cold()
+	if len(cold) != 2 || cold[0].URL != "https://example.com/about" || cold[1].URL != "https://example.com/search" {
+		tests.Info("Received: %#v", cold)
+		tests.Failed("Should have listed urls not hit after priming sorted by url")
+	}
+	tests.Passed("Should have listed urls not hit after priming sorted by url")
+
+	if cacheLabel("") != "none" || cacheLabel(crawler.CacheStale) != "stale" {
+		tests.Failed("Should have labelled missing cache statuses as none")
+	}
+	tests.Passed("Should have labelled missing cache statuses as none")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// defaultProgressInterval is the interval the progress file is rewritten at
+// during a crawl.
+const defaultProgressInterval = 5 * time.Second
+
+// progress states ...
+const (
+	progressRunning     = "running"
+	progressCompleted   = "completed"
+	progressTruncated   = "truncated"
+	progressInterrupted = "interrupted"
+	progressFailed      = "failed"
+)
+
+// crawlProgress embodies the progress of a crawl as written into the progress
+// file.
+type crawlProgress struct {
+	State          string    `json:"state"`
+	Target         string    `json:"target"`
+	StartedAt      time.Time `json:"started_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	Pages          int       `json:"pages"`
+	Live           int       `json:"live"`
+	Redirected     int       `json:"redirected"`
+	Failed         int       `json:"failed"`
+	PagesPerSecond float64   `json:"pages_per_second"`
+	RecentRate     float64   `json:"recent_pages_per_second"`
+}
+
+// progressFile implements the continuous writing of a crawl's counts, rates and
+// state into a small json file, so orchestration systems such as sidecars and
+// cron wrappers can monitor long crawls without parsing logs. The file is
+// written into a temporary file beside it then renamed over it, so readers
+// never see it partially written.
+type progressFile struct {
+	ml         sync.Mutex
+	path       string
+	progress   crawlProgress
+	lastPages  int
+	lastUpdate time.Time
+	finished   bool
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+// newProgressFile returns a new progressFile written at giving path for the
+// crawl of target.
+func newProgressFile(path string, target string) *progressFile {
+	now := time.Now()
+	return &progressFile{
+		path:       path,
+		lastUpdate: now,
+		progress: crawlProgress{
+			State:     progressRunning,
+			Target:    target,
+			StartedAt: now,
+		},
+	}
+}
+
+// Start writes the progress file, returning an error if it can't be written,
+// then rewrites it every giving interval until Finish is called. Errors of
+// later writes are ignored, as the next write may succeed.
+func (p *progressFile) Start(interval time.Duration) error {
+	if err := p.write(time.Now()); err != nil {
+		return err
+	}
+
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+
+	go func() {
+		defer close(p.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case now := <-ticker.C:
+				p.write(now)
+			}
+		}
+	}()
+	return nil
+}
+
+// Observe counts giving page report.
+func (p *progressFile) Observe(report crawler.LinkReport) {
+	p.ml.Lock()
+	defer p.ml.Unlock()
+
+	p.progress.Pages++
+	switch {
+	case report.RedirectedTo != nil:
+		p.progress.Redirected++
+	case report.Status.IsLive && report.Status.LastStatus < 400:
+		p.progress.Live++
+	default:
+		p.progress.Failed++
+	}
+}
+
+// Finish stops the rewriting of the progress file and writes it a last time
+// with giving state. Calls after the first are ignored, so a deferred call
+// marking the crawl failed leaves the state of a finished crawl as is.
+func (p *progressFile) Finish(state string) error {
+	p.ml.Lock()
+	if p.finished {
+		p.ml.Unlock()
+		return nil
+	}
+	p.finished = true
+	p.progress.State = state
+	p.ml.Unlock()
+
+	if p.stop != nil {
+		close(p.stop)
+		<-p.stopped
+	}
+	return p.write(time.Now())
+}
+
+// Snapshot returns the progress of the crawl at giving time, with the rate of
+// pages received since the last snapshot.
+func (p *progressFile) Snapshot(now time.Time) crawlProgress {
+	p.ml.Lock()
+	defer p.ml.Unlock()
+
+	progress := p.progress
+	progress.UpdatedAt = now
+	progress.ElapsedSeconds = now.Sub(progress.StartedAt).Seconds()
+	if progress.ElapsedSeconds > 0 {
+		progress.PagesPerSecond = float64(progress.Pages) / progress.ElapsedSeconds
+	}
+
+	if since := now.Sub(p.lastUpdate).Seconds(); since > 0 {
+		progress.RecentRate = float64(progress.Pages-p.lastPages) / since
+	}
+
+	p.lastPages, p.lastUpdate = progress.Pages, now
+	return progress
+}
+
+// write replaces the progress file with the progress of the crawl at giving
+// time.
+func (p *progressFile) write(now time.Time) error {
+	data, err := json.MarshalIndent(p.Snapshot(now), "", "\t")
+	if err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(p.path), "."+filepath.Base(p.path)+".")
+	if err != nil {
+		return err
+	}
+
+	// Temporary files are only readable by their owner, while monitors may
+	// run as other users.
+	if err := temp.Chmod(0644); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), p.path); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestProgressFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawl-progress")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "progress.json")
+	read := func() crawlProgress {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully read progress file")
+		}
+
+		var progress crawlProgress
+		if err := json.Unmarshal(data, &progress); err != nil {
+			tests.FailedWithError(err, "Should have successfully decoded progress file")
+		}
+		return progress
+	}
+
+	progress := newProgressFile(path, "https://example.com")
+	if err := progress.Start(time.Hour); err != nil {
+		tests.FailedWithError(err, "Should have successfully started progress file")
+	}
+	tests.Passed("Should have successfully started progress file")
+
+	if started := read(); started.State != progressRunning || started.Pages != 0 || started.Target != "https://example.com" {
+		tests.Info("Progress: %#v", started)
+		tests.Failed("Should have written running progress on start")
+	}
+	tests.Passed("Should have written running progress on start")
+
+	link := func(path string) *url.URL {
+		return &url.URL{Scheme: "https", Host: "example.com", Path: path}
+	}
+
+	progress.Observe(crawler.LinkReport{Path: link("/"), Status: crawler.Status{IsLive: true, LastStatus: 200}})
+	progress.Observe(crawler.LinkReport{Path: link("/about"), Status: crawler.Status{IsLive: true, LastStatus: 200}})
+	progress.Observe(crawler.LinkReport{Path: link("/gone"), Status: crawler.Status{LastStatus: 404}})
+	progress.Observe(crawler.LinkReport{Path: link("/old"), Status: crawler.Status{IsLive: true, LastStatus: 200}, RedirectedTo: link("/new")})
+
+	if err := progress.Finish(progressInterrupted); err != nil {
+		tests.FailedWithError(err, "Should have successfully finished progress file")
+	}
+	tests.Passed("Should have successfully finished progress file")
+
+	if err := progress.Finish(progressFailed); err != nil {
+		tests.FailedWithError(err, "Should have ignored finishing progress file again")
+	}
+
+	finished := read()
+	if finished.State != progressInterrupted || finished.Pages != 4 || finished.Live != 2 || finished.Failed != 1 || finished.Redirected != 1 {
+		tests.Info("Progress: %#v", finished)
+		tests.Failed("Should have written counts with the state of the first finish")
+	}
+	tests.Passed("Should have written counts with the state of the first finish")
+
+	if finished.PagesPerSecond <= 0 || finished.UpdatedAt.Before(finished.StartedAt) {
+		tests.Info("Progress: %#v", finished)
+		tests.Failed("Should have written rate and update time of progress")
+	}
+	tests.Passed("Should have written rate and update time of progress")
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		tests.Info("Files: %d", len(files))
+		tests.Failed("Should have left no temporary files beside progress file")
+	}
+	tests.Passed("Should have left no temporary files beside progress file")
+}
+
+func TestProgressFileCrawl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crawl-progress")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Stray</span></p></body></html>`)
+	}))
+	defer server.Close()
+
+	read := func(path string) crawlProgress {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully read progress file")
+		}
+
+		var progress crawlProgress
+		if err := json.Unmarshal(data, &progress); err != nil {
+			tests.FailedWithError(err, "Should have successfully decoded progress file")
+		}
+		return progress
+	}
+
+	completed := filepath.Join(dir, "completed.json")
+	if _, _, err := runMain("-crawl.progress-file="+completed, "crawl", server.URL); err != nil {
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	if progress := read(completed); progress.State != progressCompleted || progress.Pages != 1 {
+		tests.Info("Progress: %#v", progress)
+		tests.Failed("Should have marked progress of crawl completed")
+	}
+	tests.Passed("Should have marked progress of crawl completed")
+
+	failed := filepath.Join(dir, "failed.json")
+	if _, _, err := runMain("-crawl.progress-file="+failed, "-crawl.max-parse-failures=0", "crawl", server.URL); err != nil {
+		tests.FailedWithError(err, "Should have successfully run crawl")
+	}
+
+	if progress := read(failed); progress.State != progressFailed || progress.Pages != 1 {
+		tests.Info("Progress: %#v", progress)
+		tests.Failed("Should have marked progress of crawl failed after crawl")
+	}
+	tests.Passed("Should have marked progress of crawl failed after crawl")
+}
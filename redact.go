@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// redactedMask replaces secrets redacted from outputs. It's kept free of
+// characters escaped in urls, so redacted urls remain readable.
+const redactedMask = "REDACTED"
+
+// redactConfig embodies the redaction applied to all outputs and logs of a
+// crawl, so artifacts of authenticated sites can be shared. StripParams and
+// Secrets hold regular expressions, matched against query parameter names and
+// any text respectively. Values of the Headers named, as sent with
+// -crawl.header or -crawl.cookie, are masked wherever they appear.
+type redactConfig struct {
+	StripParams []string `json:"strip_params,omitempty"`
+	Secrets     []string `json:"secrets,omitempty"`
+	Headers     []string `json:"headers,omitempty"`
+}
+
+// redactor implements the redaction of a redactConfig. A nil redactor leaves
+// everything as is.
+type redactor struct {
+	params   []*regexp.Regexp
+	secrets  []*regexp.Regexp
+	headers  map[string]bool
+	ml       sync.RWMutex
+	literals []string
+}
+
+// loadRedactor returns the redactor of the redactConfig stored as json in
+// giving file.
+func loadRedactor(path string) (*redactor, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var config redactConfig
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return nil, fmt.Errorf("redact config %+q: %+s", path, err)
+	}
+
+	return newRedactor(config)
+}
+
+// newRedactor returns a new redactor for giving config.
+func newRedactor(config redactConfig) (*redactor, error) {
+	r := &redactor{headers: map[string]bool{}}
+
+	for _, pattern := range config.StripParams {
+		param, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact param pattern %+q: %+s", pattern, err)
+		}
+		r.params = append(r.params, param)
+	}
+
+	for _, pattern := range config.Secrets {
+		secret, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact secret pattern %+q: %+s", pattern, err)
+		}
+		r.secrets = append(r.secrets, secret)
+	}
+
+	for _, header := range config.Headers {
+		r.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return r, nil
+}
+
+// MaskHeaders adds the values of giving headers and cookies, whose headers are
+// configured to be dropped, to the literals masked.
+func (r *redactor) MaskHeaders(headers http.Header, cookies []*http.Cookie) {
+	if r == nil {
+		return
+	}
+
+	r.ml.Lock()
+	defer r.ml.Unlock()
+
+	for name, values := range headers {
+		if r.headers[http.CanonicalHeaderKey(name)] {
+			r.literals = append(r.literals, values...)
+		}
+	}
+
+	if r.headers["Cookie"] {
+		for _, cookie := range cookies {
+			r.literals = append(r.literals, cookie.Value)
+		}
+	}
+}
+
+// String returns giving text with all secrets and masked literals replaced.
+func (r *redactor) String(text string) string {
+	if r == nil {
+		return text
+	}
+
+	for _, secret := range r.secrets {
+		text = secret.ReplaceAllString(text, redactedMask)
+	}
+
+	r.ml.RLock()
+	defer r.ml.RUnlock()
+
+	for _, literal := range r.literals {
+		if literal != "" {
+			text = strings.Replace(text, literal, redactedMask, -1)
+		}
+	}
+	return text
+}
+
+// URL returns a copy of giving url without it's user credentials and the query
+// parameters configured to be stripped, with secrets of the remaining url masked.
+func (r *redactor) URL(target *url.URL) *url.URL {
+	if r == nil || target == nil {
+		return target
+	}
+
+	redacted := *target
+	redacted.User = nil
+
+	if len(r.params) != 0 && redacted.RawQuery != "" {
+		var kept []string
+		for _, pair := range strings.Split(redacted.RawQuery, "&") {
+			name := pair
+			if index := strings.IndexByte(pair, '='); index >= 0 {
+				name = pair[:index]
+			}
+
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+
+			if !r.stripsParam(name) {
+				kept = append(kept, pair)
+			}
+		}
+		redacted.RawQuery = strings.Join(kept, "&")
+	}
+
+	if masked := r.String(redacted.String()); masked != redacted.String() {
+		if parsed, err := url.Parse(masked); err == nil {
+			return parsed
+		}
+	}
+
+	return &redacted
+}
+
+// stripsParam returns true if giving query parameter name is stripped.
+func (r *redactor) stripsParam(name string) bool {
+	for _, param := range r.params {
+		if param.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Report returns a copy of giving report with all it's urls and texts
+// redacted, including those of the links it points to.
+func (r *redactor) Report(report crawler.LinkReport) crawler.LinkReport {
+	if r == nil {
+		return report
+	}
+
+	report.Path = r.URL(report.Path)
+	report.RedirectedTo = r.URL(report.RedirectedTo)
+	report.Canonical = r.URL(report.Canonical)
+	report.Title = r.String(report.Title)
+	report.AnchorText = r.String(report.AnchorText)
+	report.Heading = r.String(report.Heading)
+	report.RouteError = r.String(report.RouteError)
+	report.Stylesheet = r.RawURL(report.Stylesheet)
+	report.Feed = r.RawURL(report.Feed)
+	report.JSON = r.RawURL(report.JSON)
+	report.Contacts = r.strings(report.Contacts)
+	report.ParseWarnings = r.strings(report.ParseWarnings)
+
+	if report.Metadata != nil {
+		metadata := make(crawler.Metadata, len(report.Metadata))
+		for key, value := range report.Metadata {
+			metadata[key] = r.String(value)
+		}
+		report.Metadata = metadata
+	}
+
+	if report.Social != nil {
+		social := *report.Social
+		social.OGTitle = r.String(social.OGTitle)
+		social.Issues = r.strings(social.Issues)
+		social.OGImage = r.RawURL(social.OGImage)
+		social.TwitterImage = r.RawURL(social.TwitterImage)
+		report.Social = &social
+	}
+
+	if report.Structured != nil {
+		structured := *report.Structured
+		structured.Errors = r.strings(structured.Errors)
+		report.Structured = &structured
+	}
+
+	if report.Status.Reason != nil {
+		reason := *report.Status.Reason
+		reason.Message = r.String(reason.Message)
+		report.Status.Reason = &reason
+	}
+
+	if report.Redirects != nil {
+		hops := make([]crawler.RedirectHop, len(report.Redirects))
+		for index, hop := range report.Redirects {
+			hop.URL = r.RawURL(hop.URL)
+			hops[index] = hop
+		}
+		report.Redirects = hops
+	}
+
+	if report.Alternates != nil {
+		alternates := make([]crawler.Alternate, len(report.Alternates))
+		for index, alternate := range report.Alternates {
+			alternate.URL = r.URL(alternate.URL)
+			alternates[index] = alternate
+		}
+		report.Alternates = alternates
+	}
+
+	if report.PointsTo != nil {
+		kids := make([]crawler.LinkReport, len(report.PointsTo))
+		for index, kid := range report.PointsTo {
+			kids[index] = r.Report(kid)
+		}
+		report.PointsTo = kids
+	}
+
+	return report
+}
+
+// strings returns a copy of giving texts with each redacted.
+func (r *redactor) strings(texts []string) []string {
+	if texts == nil {
+		return nil
+	}
+
+	redacted := make([]string, len(texts))
+	for index, text := range texts {
+		redacted[index] = r.String(text)
+	}
+	return redacted
+}
+
+// RawURL returns giving raw url redacted, masking it whole if it can't be
+// parsed.
+func (r *redactor) RawURL(raw string) string {
+	if r == nil || raw == "" {
+		return raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return r.String(raw)
+	}
+	return r.URL(parsed).String()
+}
+
+// Writer returns a writer masking secrets of all text written through it into
+// w, for logs written with a single write per message.
+func (r *redactor) Writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+	return redactWriter{w: w, redactor: r}
+}
+
+// redactWriter implements the io.Writer which masks secrets of text written
+// through it.
+type redactWriter struct {
+	w        io.Writer
+	redactor *redactor
+}
+
+// Write implements the io.Writer interface, reporting the length of giving
+// text as written even though masking changes it.
+func (w redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redactReportWriter implements the ReportWriter which redacts reports before
+// delivering them to another ReportWriter.
+type redactReportWriter struct {
+	ReportWriter
+	redactor *redactor
+}
+
+// Write delivers giving report redacted.
+func (w redactReportWriter) Write(report crawler.LinkReport) error {
+	return w.ReportWriter.Write(w.redactor.Report(report))
+}
+
+// splitOutput returns the splitWriter giving writer delivers reports to, if any.
+func splitOutput(writer ReportWriter) (*splitWriter, bool) {
+	if buffered, ok := writer.(*bufferedWriter); ok {
+		writer = buffered.ReportWriter
+	}
+
+	if redacted, ok := writer.(redactReportWriter); ok {
+		writer = redacted.ReportWriter
+	}
+
+	splitter, ok := writer.(*splitWriter)
+	return splitter, ok
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// signalTrap implements the trapping of SIGINT and SIGTERM during a crawl, so
+// the crawl is cancelled and reports collected so far are still written.
+type signalTrap struct {
+	signals  chan os.Signal
+	received int32
+}
+
+// trapSignals returns a new signalTrap calling cancel on the first signal
+// received, exiting immediately on the second.
+func trapSignals(cancel func()) *signalTrap {
+	trap := &signalTrap{signals: make(chan os.Signal, 2)}
+	signal.Notify(trap.signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for range trap.signals {
+			if atomic.AddInt32(&trap.received, 1) > 1 {
+				os.Exit(1)
+			}
+
+			fmt.Fprintf(os.Stderr, "\nInterrupted: stopping crawl and writing collected reports, interrupt again to exit immediately\n")
+			cancel()
+		}
+	}()
+
+	return trap
+}
+
+// Interrupted returns true if a signal was received.
+func (t *signalTrap) Interrupted() bool {
+	return atomic.LoadInt32(&t.received) != 0
+}
+
+// Stop stops trapping signals.
+func (t *signalTrap) Stop() {
+	signal.Stop(t.signals)
+	close(t.signals)
+}
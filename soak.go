@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/influx6/sitecrawler/crawler"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
+)
+
+// soakWarmup sets the iterations of a soak run before resources are tracked,
+// giving pools and caches filled by the first crawls time to settle.
+const soakWarmup = 1
+
+// ErrSoakLeak is returned when resources grow over every iteration of a soak
+// run, hinting at a leak.
+var ErrSoakLeak = errors.New("resources grew over every soak iteration")
+
+// soakConfig embodies the settings of a soak run, repeatedly crawling Target,
+// else a synthetic site of giving options.
+type soakConfig struct {
+	Target     *url.URL
+	Site       sitetest.Options
+	Iterations int
+	Depth      int
+	Workers    int
+	Rate       crawler.Rate
+	Timeout    time.Duration
+	Settle     time.Duration
+}
+
+// soakSample embodies the resources held by the process after an iteration of
+// a soak run. FDs is -1 where open file descriptors can't be counted.
+type soakSample struct {
+	Iteration  int
+	Pages      int
+	Duration   time.Duration
+	Goroutines int
+	HeapAlloc  uint64
+	FDs        int
+}
+
+// runSoak crawls the target of giving config for all iterations, sampling the
+// resources held once each crawl completes and calling observe with the
+// sample. It returns an error wrapping ErrSoakLeak naming the resources which
+// grew over every iteration after warmup.
+func runSoak(ctx context.Context, config soakConfig, observe func(soakSample)) ([]soakSample, error) {
+	target := config.Target
+	if target == nil {
+		server := sitetest.NewServer(config.Site)
+		defer server.Close()
+
+		target = server.Target()
+	}
+
+	client := &http.Client{
+		Transport: crawler.NewRoundTripper(crawler.TransportOptions{}),
+	}
+
+	var samples []soakSample
+	for iteration := 1; iteration <= config.Iterations; iteration++ {
+		var pages crawler.PageCrawler
+		pages.Target = target
+		pages.MaxDepth = config.Depth
+		pages.Rate = config.Rate
+		pages.RequestTimeout = config.Timeout
+
+		pages, err := crawler.NewPageCrawler(pages)
+		if err != nil {
+			return samples, err
+		}
+
+		start := time.Now()
+		sample := soakSample{Iteration: iteration}
+
+		pool := crawler.NewWorkerPool(config.Workers, ctx)
+		reports := make(chan crawler.LinkReport)
+		pool.Add(func() { pages.Run(ctx, client, pool, reports) })
+
+		for range reports {
+			sample.Pages++
+		}
+		pool.Stop()
+
+		sample.Duration = time.Since(start)
+		if err := ctx.Err(); err != nil {
+			return samples, err
+		}
+
+		// Idle connections are closed so only resources leaked by the crawl
+		// remain, rather than those pooled for reuse.
+		client.CloseIdleConnections()
+		time.Sleep(config.Settle)
+		runtime.GC()
+
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+
+		sample.Goroutines = runtime.NumGoroutine()
+		sample.HeapAlloc = stats.HeapAlloc
+		sample.FDs = openFDs()
+
+		samples = append(samples, sample)
+		if observe != nil {
+			observe(sample)
+		}
+	}
+
+	if grown := soakGrowth(samples); len(grown) != 0 {
+		return samples, fmt.Errorf("%+s: %+q", ErrSoakLeak, strings.Join(grown, ", "))
+	}
+	return samples, nil
+}
+
+// soakGrowth returns the names of the resources which grew over every sample
+// after warmup. At least four samples after warmup are needed for growth to
+// be told apart from noise.
+func soakGrowth(samples []soakSample) []string {
+	if len(samples) < soakWarmup+4 {
+		return nil
+	}
+	samples = samples[soakWarmup:]
+
+	goroutines, heap, fds := true, true, true
+	for index := 1; index < len(samples); index++ {
+		previous, current := samples[index-1], samples[index]
+		goroutines = goroutines && current.Goroutines > previous.Goroutines
+		heap = heap && current.HeapAlloc > previous.HeapAlloc
+		fds = fds && current.FDs >= 0 && current.FDs > previous.FDs
+	}
+
+	var grown []string
+	if goroutines {
+		grown = append(grown, "goroutines")
+	}
+	if heap {
+		grown = append(grown, "heap")
+	}
+	if fds {
+		grown = append(grown, "file descriptors")
+	}
+	return grown
+}
+
+// openFDs returns the file descriptors open by the process, else -1 where
+// they can't be listed.
+func openFDs() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler/sitetest"
+)
+
+func TestRunSoak(t *testing.T) {
+	config := soakConfig{
+		Site:       sitetest.Options{Pages: 100, Branching: 5},
+		Iterations: 3,
+		Workers:    50,
+		Timeout:    5 * time.Second,
+		Settle:     10 * time.Millisecond,
+	}
+
+	var observed int
+	samples, err := runSoak(context.Background(), config, func(sample soakSample) {
+		observed++
+	})
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run soak")
+	}
+	tests.Passed("Should have successfully run soak")
+
+	if len(samples) != 3 || observed != 3 {
+		tests.Info("Received Samples: %d, Observed: %d", len(samples), observed)
+		tests.Failed("Should have sampled resources after every iteration")
+	}
+	tests.Passed("Should have sampled resources after every iteration")
+
+	for _, sample := range samples {
+		if sample.Pages != 100 || sample.Goroutines == 0 || sample.HeapAlloc == 0 {
+			tests.Info("Received Sample: %#v", sample)
+			tests.Failed("Should have crawled all pages of synthetic site every iteration")
+		}
+	}
+	tests.Passed("Should have crawled all pages of synthetic site every iteration")
+}
+
+func TestSoakGrowth(t *testing.T) {
+	var samples []soakSample
+	for index := 0; index < 6; index++ {
+		samples = append(samples, soakSample{Goroutines: 10 + index, HeapAlloc: 1000, FDs: 8 + index})
+	}
+
+	if grown := soakGrowth(samples); len(grown) != 2 || grown[0] != "goroutines" || grown[1] != "file descriptors" {
+		tests.Info("Received Grown: %#v", grown)
+		tests.Failed("Should have flagged only resources growing over every iteration")
+	}
+	tests.Passed("Should have flagged only resources growing over every iteration")
+
+	if grown := soakGrowth(samples[:4]); len(grown) != 0 {
+		tests.Info("Received Grown: %#v", grown)
+		tests.Failed("Should have not flagged growth over too few iterations")
+	}
+	tests.Passed("Should have not flagged growth over too few iterations")
+
+	for index := range samples {
+		samples[index].FDs = -1
+	}
+
+	if grown := soakGrowth(samples); len(grown) != 1 || grown[0] != "goroutines" {
+		tests.Info("Received Grown: %#v", grown)
+		tests.Failed("Should have not flagged growth of uncounted file descriptors")
+	}
+	tests.Passed("Should have not flagged growth of uncounted file descriptors")
+}
+
+func TestSoakExitStatus(t *testing.T) {
+	args := []string{"-soak.iterations=12", "-soak.settle=20ms", "-soak.pages=10", "-soak.branching=3", "soak"}
+
+	status, stderr, err := runMain(args...)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run soak")
+	}
+
+	if status != 0 {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have exited with status 0 when no resources leak")
+	}
+	tests.Passed("Should have exited with status 0 when no resources leak")
+
+	status, stderr, err = runMainEnv([]string{mainLeakEnv + "=1"}, args...)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run soak")
+	}
+
+	if status != 1 || !strings.Contains(stderr, ErrSoakLeak.Error()) {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have exited with status 1 when goroutines leak")
+	}
+	tests.Passed("Should have exited with status 1 when goroutines leak")
+}
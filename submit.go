@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// defaults of the indexing apis urls are submitted to.
+const (
+	defaultIndexNowEndpoint = "https://api.indexnow.org/indexnow"
+	defaultGoogleEndpoint   = "https://indexing.googleapis.com/v3/urlNotifications:publish"
+	defaultGoogleTokenURI   = "https://oauth2.googleapis.com/token"
+	googleIndexingScope     = "https://www.googleapis.com/auth/indexing"
+	maxIndexNowURLs         = 10000
+	submitTimeout           = 30 * time.Second
+)
+
+// submitConfig embodies the indexing apis new or changed urls of a crawl are
+// submitted to after it finishes.
+type submitConfig struct {
+	IndexNow *indexNowConfig `json:"indexnow,omitempty"`
+	Google   *googleConfig   `json:"google,omitempty"`
+}
+
+// indexNowConfig embodies the key and endpoint urls are submitted to IndexNow
+// with. The key must be hosted at KeyLocation, which defaults to /{key}.txt on
+// the target's host.
+type indexNowConfig struct {
+	Endpoint    string `json:"endpoint,omitempty"`
+	Key         string `json:"key"`
+	KeyLocation string `json:"key_location,omitempty"`
+}
+
+// googleConfig embodies the service account urls are submitted to the Google
+// Indexing API with, Credentials being the path of the account's json key.
+type googleConfig struct {
+	Endpoint    string `json:"endpoint,omitempty"`
+	Credentials string `json:"credentials"`
+}
+
+// googleServiceAccount embodies the fields of a Google service account json key
+// needed to request access tokens.
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// submission embodies the result of submitting urls to an indexing api.
+type submission struct {
+	API       string
+	Submitted int
+	Err       error
+}
+
+// crawlBaseline embodies the live urls and page validators of the previous
+// crawl, which the urls of a crawl are compared against so only those added
+// or changed since are submitted.
+type crawlBaseline struct {
+	urls       map[string]bool
+	validators map[string]crawler.CacheEntry
+}
+
+// newSubmitClient returns the client urls are submitted to indexing apis
+// with. It's kept apart from the crawl's client, so credentials are only sent
+// with default TLS verification, name resolution and proxying.
+func newSubmitClient() *http.Client {
+	return &http.Client{Timeout: submitTimeout}
+}
+
+// loadCrawlBaseline returns the crawlBaseline of the previous crawl, reading
+// it's urls from the results of giving store, else from the urls kept by the
+// delta of deltaDir, along with the pages cached in validators. It returns
+// false if there is no previous crawl.
+func loadCrawlBaseline(store crawler.Store, validators crawler.Store, deltaDir string) (*crawlBaseline, bool, error) {
+	baseline := &crawlBaseline{urls: map[string]bool{}, validators: map[string]crawler.CacheEntry{}}
+
+	switch {
+	case store != nil:
+		err := store.Each(crawler.StoreResults, func(link string, value []byte) error {
+			var report crawler.LinkReport
+			if err := json.Unmarshal(value, &report); err != nil {
+				return err
+			}
+
+			if report.Status.IsLive && report.RedirectedTo == nil {
+				baseline.urls[link] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	case deltaDir != "":
+		urls, _, err := readDeltaState(deltaDir, nil)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, link := range urls {
+			baseline.urls[link] = true
+		}
+	}
+
+	if validators != nil {
+		err := validators.Each(crawler.StoreCache, func(link string, value []byte) error {
+			var entry crawler.CacheEntry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return err
+			}
+
+			baseline.urls[link] = true
+			baseline.validators[link] = entry
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return baseline, len(baseline.urls) != 0, nil
+}
+
+// Submissions returns giving urls which are missing from the previous crawl,
+// or whose validators cached by the crawl in provided cache differ from those
+// of the previous crawl.
+func (b *crawlBaseline) Submissions(urls []string, cache *crawler.ValidatorCache) []string {
+	var submissions []string
+	for _, link := range urls {
+		if !b.urls[link] {
+			submissions = append(submissions, link)
+			continue
+		}
+
+		previous, ok := b.validators[link]
+		if !ok {
+			continue
+		}
+
+		current, cached := cache.Get(link)
+		if !cached || current.ETag != previous.ETag || current.LastModified != previous.LastModified {
+			submissions = append(submissions, link)
+		}
+	}
+	return submissions
+}
+
+// loadSubmitConfig returns the submitConfig stored as json in giving file.
+func loadSubmitConfig(path string) (submitConfig, error) {
+	var config submitConfig
+
+	file, err := os.Open(path)
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&config); err != nil {
+		return config, fmt.Errorf("submit config %+q: %+s", path, err)
+	}
+
+	if config.IndexNow == nil && config.Google == nil {
+		return config, fmt.Errorf("submit config %+q: no indexing api configured, expected indexnow or google", path)
+	}
+
+	if config.IndexNow != nil && config.IndexNow.Key == "" {
+		return config, fmt.Errorf("submit config %+q: indexnow requires a key", path)
+	}
+
+	if config.Google != nil && config.Google.Credentials == "" {
+		return config, fmt.Errorf("submit config %+q: google requires credentials", path)
+	}
+
+	return config, nil
+}
+
+// submitURLs submits giving urls of target to all indexing apis of config,
+// returning the result of each.
+func submitURLs(client *http.Client, config submitConfig, target *url.URL, urls []string) []submission {
+	var submissions []submission
+
+	if config.IndexNow != nil {
+		submitted, err := submitIndexNow(client, *config.IndexNow, target, urls)
+		submissions = append(submissions, submission{API: "indexnow", Submitted: submitted, Err: err})
+	}
+
+	if config.Google != nil {
+		submitted, err := submitGoogle(client, *config.Google, urls)
+		submissions = append(submissions, submission{API: "google", Submitted: submitted, Err: err})
+	}
+
+	return submissions
+}
+
+// keyLocation returns the url the IndexNow key is hosted at on target's host.
+func (c indexNowConfig) keyLocation(target *url.URL) string {
+	if c.KeyLocation != "" {
+		return c.KeyLocation
+	}
+	return (&url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/" + c.Key + ".txt"}).String()
+}
+
+// verifyIndexNowKey verifies the IndexNow key of config is hosted at it's
+// location on target's host, as IndexNow rejects submissions it can't verify.
+func verifyIndexNowKey(client *http.Client, userAgent string, config indexNowConfig, target *url.URL) error {
+	location := config.keyLocation(target)
+
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Host != target.Host {
+		return fmt.Errorf("key location %+q does not lie on host %+q", location, target.Host)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("key location %+q responded with %s", location, res.Status)
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(content)) != config.Key {
+		return fmt.Errorf("key location %+q does not hold the configured key", location)
+	}
+
+	return nil
+}
+
+// submitIndexNow submits urls lying on target's host to IndexNow in batches,
+// returning total urls accepted.
+func submitIndexNow(client *http.Client, config indexNowConfig, target *url.URL, urls []string) (int, error) {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultIndexNowEndpoint
+	}
+
+	var hosted []string
+	for _, link := range urls {
+		if parsed, err := url.Parse(link); err == nil && parsed.Host == target.Host {
+			hosted = append(hosted, link)
+		}
+	}
+
+	var submitted int
+	for len(hosted) > 0 {
+		batch := hosted
+		if len(batch) > maxIndexNowURLs {
+			batch = batch[:maxIndexNowURLs]
+		}
+		hosted = hosted[len(batch):]
+
+		body, err := json.Marshal(map[string]interface{}{
+			"host":        target.Hostname(),
+			"key":         config.Key,
+			"keyLocation": config.keyLocation(target),
+			"urlList":     batch,
+		})
+		if err != nil {
+			return submitted, err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return submitted, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		if err := doSubmit(client, req); err != nil {
+			return submitted, err
+		}
+		submitted += len(batch)
+	}
+
+	return submitted, nil
+}
+
+// submitGoogle publishes each url as updated to the Google Indexing API,
+// returning total urls accepted. Submission stops at the first failure, such
+// as the daily quota being exhausted.
+func submitGoogle(client *http.Client, config googleConfig, urls []string) (int, error) {
+	if len(urls) == 0 {
+		return 0, nil
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultGoogleEndpoint
+	}
+
+	token, err := googleAccessToken(client, config.Credentials)
+	if err != nil {
+		return 0, err
+	}
+
+	var submitted int
+	for _, link := range urls {
+		body, err := json.Marshal(map[string]string{"url": link, "type": "URL_UPDATED"})
+		if err != nil {
+			return submitted, err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return submitted, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		if err := doSubmit(client, req); err != nil {
+			return submitted, fmt.Errorf("%+s: %+s", link, err)
+		}
+		submitted++
+	}
+
+	return submitted, nil
+}
+
+// googleAccessToken returns an access token for the Indexing API, exchanged
+// for a JWT signed with the service account stored in giving file.
+func googleAccessToken(client *http.Client, credentials string) (string, error) {
+	content, err := ioutil.ReadFile(credentials)
+	if err != nil {
+		return "", err
+	}
+
+	var account googleServiceAccount
+	if err := json.Unmarshal(content, &account); err != nil {
+		return "", fmt.Errorf("google credentials %+q: %+s", credentials, err)
+	}
+
+	if account.TokenURI == "" {
+		account.TokenURI = defaultGoogleTokenURI
+	}
+
+	assertion, err := signGoogleJWT(account, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("google credentials %+q: %+s", credentials, err)
+	}
+
+	res, err := client.PostForm(account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google token request failed: %+s", submitError(res))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	if token.AccessToken == "" {
+		return "", errors.New("google token request returned no access token")
+	}
+	return token.AccessToken, nil
+}
+
+// signGoogleJWT returns a JWT asserting giving account, valid for an hour from
+// now, signed with the account's private key.
+func signGoogleJWT(account googleServiceAccount, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", errors.New("private key is not pem encoded")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", err
+		}
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("private key is not a rsa key")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": googleIndexingScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// doSubmit sends giving submission request, returning an error if it's not
+// accepted.
+func doSubmit(client *http.Client, req *http.Request) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return submitError(res)
+	}
+
+	io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
+
+// submitError returns an error describing giving rejected response.
+func submitError(res *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	if message := strings.TrimSpace(string(body)); message != "" {
+		return fmt.Errorf("%s: %s", res.Status, message)
+	}
+	return errors.New(res.Status)
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestSubmitIndexNow(t *testing.T) {
+	var payload struct {
+		Host        string   `json:"host"`
+		Key         string   `json:"key"`
+		KeyLocation string   `json:"keyLocation"`
+		URLList     []string `json:"urlList"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse("https://example.com/")
+	config := indexNowConfig{Endpoint: server.URL, Key: "a1b2c3"}
+
+	submitted, err := submitIndexNow(server.Client(), config, target, []string{
+		"https://example.com/",
+		"https://example.com/services",
+		"https://cdn.example.com/app.js",
+	})
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully submitted urls")
+	}
+	tests.Passed("Should have successfully submitted urls")
+
+	if submitted != 2 || len(payload.URLList) != 2 || payload.Host != "example.com" {
+		tests.Info("Received Payload: %#v", payload)
+		tests.Failed("Should have only submitted urls of target's host")
+	}
+	tests.Passed("Should have only submitted urls of target's host")
+
+	if payload.Key != "a1b2c3" || payload.KeyLocation != "https://example.com/a1b2c3.txt" {
+		tests.Info("Received Payload: %#v", payload)
+		tests.Failed("Should have submitted key and it's default location")
+	}
+	tests.Passed("Should have submitted key and it's default location")
+}
+
+func TestVerifyIndexNowKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a1b2c3.txt":
+			w.Write([]byte("a1b2c3\n"))
+		case "/keys/stale.txt":
+			w.Write([]byte("d4e5f6"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL + "/")
+
+	if err := verifyIndexNowKey(server.Client(), "sitecrawler", indexNowConfig{Key: "a1b2c3"}, target); err != nil {
+		tests.FailedWithError(err, "Should have verified key hosted at default location")
+	}
+	tests.Passed("Should have verified key hosted at default location")
+
+	configs := []indexNowConfig{
+		{Key: "d4e5f6"},
+		{Key: "a1b2c3", KeyLocation: server.URL + "/keys/stale.txt"},
+		{Key: "a1b2c3", KeyLocation: "https://example.com/a1b2c3.txt"},
+	}
+
+	for _, config := range configs {
+		if err := verifyIndexNowKey(server.Client(), "sitecrawler", config, target); err == nil {
+			tests.Failed("Should have failed to verify key %q at %q", config.Key, config.keyLocation(target))
+		}
+	}
+	tests.Passed("Should have failed to verify missing, stale and off host keys")
+}
+
+func TestSubmitGoogle(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully generated key")
+	}
+
+	var ml sync.Mutex
+	var published []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[len(parts)-1])
+		digest := sha256.Sum256([]byte(strings.Join(parts[:len(parts)-1], ".")))
+
+		if len(parts) != 3 || rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token":"token-1","expires_in":3600}`))
+	})
+	mux.HandleFunc("/publish", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var notification map[string]string
+		json.NewDecoder(r.Body).Decode(&notification)
+
+		ml.Lock()
+		defer ml.Unlock()
+
+		if len(published) == 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		published = append(published, notification["url"])
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "sitecrawler-submit")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	credentials, _ := json.Marshal(googleServiceAccount{
+		ClientEmail: "crawler@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		TokenURI:    server.URL + "/token",
+	})
+
+	credentialsFile := filepath.Join(dir, "account.json")
+	if err := ioutil.WriteFile(credentialsFile, credentials, 0600); err != nil {
+		tests.FailedWithError(err, "Should have successfully written credentials")
+	}
+
+	config := googleConfig{Endpoint: server.URL + "/publish", Credentials: credentialsFile}
+
+	submitted, err := submitGoogle(server.Client(), config, []string{
+		"https://example.com/",
+		"https://example.com/services",
+		"https://example.com/contacts",
+	})
+
+	if submitted != 2 || len(published) != 2 || published[1] != "https://example.com/services" {
+		tests.Info("Received Published: %+q", published)
+		tests.Failed("Should have published urls with signed access token")
+	}
+	tests.Passed("Should have published urls with signed access token")
+
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		tests.FailedWithError(err, "Should have stopped submission once quota was exhausted")
+	}
+	tests.Passed("Should have stopped submission once quota was exhausted")
+}
+
+func TestLoadSubmitConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitecrawler-submit")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	configs := map[string]bool{
+		`{"indexnow": {"key": "a1b2c3"}}`:             true,
+		`{"google": {"credentials": "account.json"}}`: true,
+		`{}`: false,
+		`{"indexnow": {"key_location": "/key.txt"}}`:          false,
+		`{"google": {"endpoint": "https://example.com"}}`:     false,
+		`{"indexnow": {"key": "a1b2c3"}, "google": {"cr": 1}`: false,
+	}
+
+	for content, valid := range configs {
+		file := filepath.Join(dir, "submit.json")
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			tests.FailedWithError(err, "Should have successfully written config")
+		}
+
+		if _, err := loadSubmitConfig(file); (err == nil) != valid {
+			tests.Info("Received Error: %+s", err)
+			tests.Failed("Should have validated submit config %s", content)
+		}
+	}
+	tests.Passed("Should have validated submit configs")
+}
+
+func TestCrawlBaseline(t *testing.T) {
+	store := crawler.NewMemoryStore()
+
+	reports := []crawler.LinkReport{
+		{Path: &url.URL{Scheme: "https", Host: "example.com", Path: "/"}, Status: crawler.Status{IsLive: true}},
+		{Path: &url.URL{Scheme: "https", Host: "example.com", Path: "/about"}, Status: crawler.Status{IsLive: true}},
+		{Path: &url.URL{Scheme: "https", Host: "example.com", Path: "/gone"}, Status: crawler.Status{LastStatus: 404}},
+		{Path: &url.URL{Scheme: "https", Host: "example.com", Path: "/old"}, Status: crawler.Status{IsLive: true}, RedirectedTo: &url.URL{Scheme: "https", Host: "example.com", Path: "/"}},
+	}
+	for _, report := range reports {
+		data, _ := json.Marshal(report)
+		store.Put(crawler.StoreResults, report.Path.String(), data)
+	}
+
+	for link, etag := range map[string]string{"https://example.com/": `"a"`, "https://example.com/about": `"b"`, "https://example.com/blog": `"c"`} {
+		data, _ := json.Marshal(crawler.CacheEntry{ETag: etag})
+		store.Put(crawler.StoreCache, link, data)
+	}
+
+	baseline, ok, err := loadCrawlBaseline(store, store, "")
+	if err != nil || !ok {
+		tests.FailedWithError(err, "Should have successfully loaded previous crawl from store")
+	}
+	tests.Passed("Should have successfully loaded previous crawl from store")
+
+	cache := crawler.NewValidatorCache()
+	cache.Set("https://example.com/", crawler.CacheEntry{ETag: `"a"`})
+	cache.Set("https://example.com/about", crawler.CacheEntry{ETag: `"b2"`})
+
+	urls := []string{"https://example.com/", "https://example.com/about", "https://example.com/blog", "https://example.com/gone", "https://example.com/old", "https://example.com/new"}
+	if submissions := baseline.Submissions(urls, cache); strings.Join(submissions, ",") != "https://example.com/about,https://example.com/blog,https://example.com/gone,https://example.com/old,https://example.com/new" {
+		tests.Info("Received: %q", submissions)
+		tests.Failed("Should have listed urls added or whose validators changed since previous crawl")
+	}
+	tests.Passed("Should have listed urls added or whose validators changed since previous crawl")
+
+	if _, ok, err := loadCrawlBaseline(crawler.NewMemoryStore(), nil, ""); err != nil || ok {
+		tests.Info("Previous: %t, Error: %+s", ok, err)
+		tests.Failed("Should have found no previous crawl in empty store")
+	}
+	tests.Passed("Should have found no previous crawl in empty store")
+
+	dir, err := ioutil.TempDir("", "sitecrawler-submit")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeURLList(filepath.Join(dir, deltaStateFile), []string{"https://example.com/"}); err != nil {
+		tests.FailedWithError(err, "Should have successfully written delta urls")
+	}
+
+	baseline, ok, err = loadCrawlBaseline(nil, nil, dir)
+	if err != nil || !ok {
+		tests.FailedWithError(err, "Should have successfully loaded previous crawl from delta urls")
+	}
+
+	if submissions := baseline.Submissions(urls[:2], nil); strings.Join(submissions, ",") != "https://example.com/about" {
+		tests.Info("Received: %q", submissions)
+		tests.Failed("Should have listed urls added since the previous crawl of delta")
+	}
+	tests.Passed("Should have listed urls added since the previous crawl of delta")
+}
+
+func TestCrawlSubmit(t *testing.T) {
+	var version int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.LoadInt32(&version)
+
+		var body string
+		switch r.URL.Path {
+		case "/a1b2c3.txt":
+			w.Write([]byte("a1b2c3"))
+			return
+		case "/":
+			body = `<a href="/about"></a><a href="/blog"></a>`
+		case "/about":
+			body = fmt.Sprintf("about %d", current)
+			if current > 0 {
+				body += `<a href="/new"></a>`
+			}
+		case "/blog":
+			body = "blog"
+		case "/new":
+			body = "new"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		etag := fmt.Sprintf("%q", body)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+	defer site.Close()
+
+	var ml sync.Mutex
+	var submitted []string
+	indexNow := func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			URLList []string `json:"urlList"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		ml.Lock()
+		defer ml.Unlock()
+		submitted = append(submitted, payload.URLList...)
+	}
+
+	endpoint := httptest.NewServer(http.HandlerFunc(indexNow))
+	defer endpoint.Close()
+
+	secureEndpoint := httptest.NewUnstartedServer(http.HandlerFunc(indexNow))
+	secureEndpoint.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	secureEndpoint.StartTLS()
+	defer secureEndpoint.Close()
+
+	dir, err := ioutil.TempDir("", "sitecrawler-submit")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, "indexing.json")
+	secureConfig := filepath.Join(dir, "secure-indexing.json")
+	for file, location := range map[string]string{config: endpoint.URL, secureConfig: secureEndpoint.URL} {
+		content := fmt.Sprintf(`{"indexnow": {"key": "a1b2c3", "endpoint": %q}}`, location)
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			tests.FailedWithError(err, "Should have successfully written submit config")
+		}
+	}
+
+	if status, stderr, _ := runMain("-crawl.submit="+config, "crawl", site.URL); status != 1 || !strings.Contains(stderr, ErrSubmitBaseline.Error()) {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have failed to submit urls without a previous crawl to compare against")
+	}
+	tests.Passed("Should have failed to submit urls without a previous crawl to compare against")
+
+	store := filepath.Join(dir, "crawl.db")
+	status, stderr, err := runMain("-crawl.store=bolt", "-crawl.store-path="+store, "-crawl.submit="+config, "crawl", site.URL)
+	if err != nil || status != 0 || !strings.Contains(stderr, "Submitting: skipped as there is no previous crawl to compare 3 urls against") || len(submitted) != 0 {
+		tests.Info("Status: %d, Stderr: %q, Submitted: %q", status, stderr, submitted)
+		tests.Failed("Should have submitted no urls without a previous crawl")
+	}
+	tests.Passed("Should have submitted no urls without a previous crawl")
+
+	atomic.StoreInt32(&version, 1)
+
+	status, stderr, err = runMain("-crawl.store=bolt", "-crawl.store-path="+store, "-crawl.submit="+config, "crawl", site.URL)
+	if err != nil || status != 0 || strings.Join(submitted, ",") != site.URL+"/about,"+site.URL+"/new" {
+		tests.Info("Status: %d, Stderr: %q, Submitted: %q", status, stderr, submitted)
+		tests.Failed("Should have submitted urls added or changed since previous crawl")
+	}
+	tests.Passed("Should have submitted urls added or changed since previous crawl")
+
+	atomic.StoreInt32(&version, 2)
+
+	status, stderr, err = runMain("-crawl.insecure-skip-verify", "-crawl.store=bolt", "-crawl.store-path="+store, "-crawl.submit="+secureConfig, "crawl", site.URL)
+	if err != nil || status != 0 || !strings.Contains(stderr, "indexnow\t0 submitted, failed") || !strings.Contains(stderr, "certificate") || len(submitted) != 2 {
+		tests.Info("Status: %d, Stderr: %q, Submitted: %q", status, stderr, submitted)
+		tests.Failed("Should have submitted urls with default TLS verification apart from the crawl's client")
+	}
+	tests.Passed("Should have submitted urls with default TLS verification apart from the crawl's client")
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// crawlSummary embodies the totals of a crawl used to produce the coverage
+// badge artifacts, optionally measuring the coverage of a sitemap's urls.
+// Truncated marks summaries of crawls stopped short by a deadline or budget.
+// Errors digests the distinct failures of the crawl, most frequent first.
+// ParseFailures counts the pages whose html raised parse warnings. DeadRoutes
+// lists the pages served live which rendered dead client-side. NonCanonical
+// counts the pages declaring another url canonical. Partial lists the top-level
+// sections left partially crawled by the budget. SocialIssues lists the pages
+// whose social tags failed their audit. StructuredTypes counts the pages
+// declaring each structured data type and StructuredErrors lists the pages
+// whose JSON-LD blocks failed to parse.
+type crawlSummary struct {
+	Pages            int               `json:"pages"`
+	Live             int               `json:"live"`
+	Broken           int               `json:"broken_links"`
+	SitemapURLs      int               `json:"sitemap_urls,omitempty"`
+	Covered          int               `json:"covered,omitempty"`
+	Coverage         float64           `json:"coverage,omitempty"`
+	Truncated        bool              `json:"truncated,omitempty"`
+	ParseFailures    int               `json:"parse_failures,omitempty"`
+	DeadRoutes       []deadRoute       `json:"dead_routes,omitempty"`
+	NonCanonical     int               `json:"non_canonical,omitempty"`
+	Partial          []string          `json:"partial_sections,omitempty"`
+	SocialIssues     []socialIssue     `json:"social_issues,omitempty"`
+	StructuredTypes  map[string]int    `json:"structured_data_types,omitempty"`
+	StructuredErrors []structuredError `json:"structured_data_errors,omitempty"`
+	Errors           []*errorClass     `json:"errors,omitempty"`
+
+	crawled map[string]bool
+	broken  map[string]bool
+	failed  map[string]bool
+	classes map[string]*errorClass
+}
+
+// deadRoute embodies a page served live which rendered a client-side not found
+// view or an empty main element.
+type deadRoute struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// errorClass embodies a distinct failure of a crawl, the class and message of
+// it's reason and the total urls failing with it, so systemic problems such
+// as an untrusted certificate stand out from thousands of per url reasons.
+type errorClass struct {
+	Code    crawler.ReasonCode `json:"code"`
+	Message string             `json:"message"`
+	URLs    int                `json:"urls"`
+}
+
+// socialIssue embodies a page whose Open Graph or Twitter Card tags are missing
+// or reference broken images.
+type socialIssue struct {
+	URL    string   `json:"url"`
+	Issues []string `json:"issues"`
+}
+
+// structuredError embodies a page whose JSON-LD blocks failed to parse.
+type structuredError struct {
+	URL    string   `json:"url"`
+	Errors []string `json:"errors"`
+}
+
+// newCrawlSummary returns a new empty crawlSummary.
+func newCrawlSummary() *crawlSummary {
+	return &crawlSummary{
+		crawled: map[string]bool{},
+		broken:  map[string]bool{},
+		failed:  map[string]bool{},
+		classes: map[string]*errorClass{},
+	}
+}
+
+// Observe records giving page report and the status of it's outgoing links.
+func (s *crawlSummary) Observe(report crawler.LinkReport) {
+	key := summaryKey(report.Path)
+	if !s.crawled[key] {
+		s.crawled[key] = true
+		s.Pages++
+		if report.Status.IsLive {
+			s.Live++
+		}
+		if len(report.ParseWarnings) != 0 {
+			s.ParseFailures++
+		}
+		if report.RouteError != "" {
+			s.DeadRoutes = append(s.DeadRoutes, deadRoute{URL: report.Path.String(), Reason: report.RouteError})
+		}
+		if report.NonCanonical {
+			s.NonCanonical++
+		}
+		if report.Social != nil && len(report.Social.Issues) != 0 {
+			s.SocialIssues = append(s.SocialIssues, socialIssue{URL: report.Path.String(), Issues: report.Social.Issues})
+		}
+		if report.Structured != nil {
+			s.observeStructured(report)
+		}
+	}
+
+	if !report.Status.IsLive {
+		s.markBroken(key)
+		s.markFailed(key, report.Path, report.Status)
+	}
+
+	for _, kid := range report.PointsTo {
+		// Links skipped as suspected traps were never requested.
+		if reason := kid.Status.Reason; reason != nil && reason.Code == crawler.ReasonTrap {
+			continue
+		}
+
+		if !kid.Status.IsLive {
+			kidKey := summaryKey(kid.Path)
+			s.markBroken(kidKey)
+			s.markFailed(kidKey, kid.Path, kid.Status)
+		}
+	}
+}
+
+// observeStructured records the structured data types declared by giving
+// page report and the errors of it's JSON-LD blocks.
+func (s *crawlSummary) observeStructured(report crawler.LinkReport) {
+	for _, kind := range report.Structured.Types() {
+		if s.StructuredTypes == nil {
+			s.StructuredTypes = map[string]int{}
+		}
+		s.StructuredTypes[kind]++
+	}
+
+	if len(report.Structured.Errors) != 0 {
+		s.StructuredErrors = append(s.StructuredErrors, structuredError{URL: report.Path.String(), Errors: report.Structured.Errors})
+	}
+}
+
+// markFailed records the reason of the url of giving key into the digest of
+// errors once, keeping the digest ordered by total urls.
+func (s *crawlSummary) markFailed(key string, link *url.URL, status crawler.Status) {
+	if status.Reason == nil || s.failed[key] {
+		return
+	}
+	s.failed[key] = true
+
+	message := digestMessage(status.Reason.Message, link)
+	if status.Reason.Code == crawler.ReasonHTTPStatus && status.LastStatus != 0 {
+		message = fmt.Sprintf("%s (%d)", message, status.LastStatus)
+	}
+
+	id := string(status.Reason.Code) + " " + message
+	class, ok := s.classes[id]
+	if !ok {
+		class = &errorClass{Code: status.Reason.Code, Message: message}
+		s.classes[id] = class
+		s.Errors = append(s.Errors, class)
+	}
+	class.URLs++
+
+	// Move the class ahead of those failing fewer urls.
+	for index := len(s.Errors) - 1; index > 0; index-- {
+		if s.Errors[index] != class {
+			continue
+		}
+
+		for ; index > 0 && s.Errors[index-1].URLs < class.URLs; index-- {
+			s.Errors[index-1], s.Errors[index] = s.Errors[index], s.Errors[index-1]
+		}
+		break
+	}
+}
+
+// Failed returns total urls recorded into the digest of errors.
+func (s *crawlSummary) Failed() int {
+	return len(s.failed)
+}
+
+// markBroken records the url of giving key as broken once.
+func (s *crawlSummary) markBroken(key string) {
+	if !s.broken[key] {
+		s.broken[key] = true
+		s.Broken++
+	}
+}
+
+// Cover measures the share of giving sitemap urls reached by the crawl.
+func (s *crawlSummary) Cover(urls []*url.URL) {
+	s.SitemapURLs = len(urls)
+	s.Covered = 0
+	for _, link := range urls {
+		if s.crawled[summaryKey(link)] {
+			s.Covered++
+		}
+	}
+
+	if s.SitemapURLs != 0 {
+		s.Coverage = float64(s.Covered) / float64(s.SitemapURLs) * 100
+	}
+}
+
+// Message returns the badge message summarizing the crawl.
+func (s *crawlSummary) Message() string {
+	message := fmt.Sprintf("%d pages, %d broken", s.Pages, s.Broken)
+	if s.SitemapURLs != 0 {
+		message = fmt.Sprintf("%.1f%%, %d broken", s.Coverage, s.Broken)
+	}
+
+	if s.Truncated {
+		message += ", truncated"
+	}
+	return message
+}
+
+// Color returns the badge color reflecting the health of the crawl.
+func (s *crawlSummary) Color() string {
+	switch {
+	case s.Broken != 0 || (s.SitemapURLs != 0 && s.Coverage < 80):
+		return "#e05d44"
+	case s.SitemapURLs != 0 && s.Coverage < 95:
+		return "#dfb317"
+	}
+	return "#4c1"
+}
+
+// WriteJSON writes the summary as json into w.
+func (s *crawlSummary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteSVG writes a flat badge of the summary as svg into w.
+func (s *crawlSummary) WriteSVG(w io.Writer) error {
+	label := "crawl"
+	if s.SitemapURLs != 0 {
+		label = "sitemap coverage"
+	}
+
+	message := s.Message()
+
+	// Widths are estimated from the character count of the 11px font.
+	labelWidth := len(label)*7 + 10
+	messageWidth := len(message)*7 + 10
+	width := labelWidth + messageWidth
+
+	_, err := fmt.Fprintf(w, badgeTemplate,
+		width, xmlEscape(label), xmlEscape(message),
+		labelWidth, labelWidth, messageWidth, s.Color(),
+		labelWidth/2, xmlEscape(label),
+		labelWidth+messageWidth/2, xmlEscape(message),
+	)
+	return err
+}
+
+// PartialSections records the sections giving coverage reports as partially
+// crawled.
+func (s *crawlSummary) PartialSections(coverage *crawler.SectionCoverage) {
+	for _, section := range coverage.Partial() {
+		s.Partial = append(s.Partial, section.Section)
+	}
+}
+
+// writeBadge writes the json and svg badge artifacts of giving summary into
+// files of the provided path prefix.
+func writeBadge(prefix string, summary *crawlSummary) error {
+	for _, artifact := range []struct {
+		ext   string
+		write func(io.Writer) error
+	}{
+		{ext: ".json", write: summary.WriteJSON},
+		{ext: ".svg", write: summary.WriteSVG},
+	} {
+		file, err := os.Create(prefix + artifact.ext)
+		if err != nil {
+			return err
+		}
+
+		if err := artifact.write(file); err != nil {
+			file.Close()
+			return err
+		}
+
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestErrorPrefix matches the method and url prefixing the errors of failed
+// requests, such as `Get "https://monzo.com/about": `.
+var requestErrorPrefix = regexp.MustCompile(`^[A-Z][a-zA-Z]* "[^"]*": `)
+
+// digestMessage returns giving reason message of link without the url it
+// names, so urls failing alike share a message.
+func digestMessage(message string, link *url.URL) string {
+	message = requestErrorPrefix.ReplaceAllString(message, "")
+	if link != nil {
+		message = strings.Replace(message, link.String(), "<url>", -1)
+	}
+	return message
+}
+
+// summaryKey returns the key used to match crawled and sitemap urls, ignoring
+// scheme, query and trailing slashes.
+func summaryKey(link *url.URL) string {
+	if link == nil {
+		return ""
+	}
+	return link.Host + strings.TrimSuffix(link.EscapedPath(), "/")
+}
+
+// badgeTemplate is the svg of a flat badge with a label and message.
+const badgeTemplate = `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">
+	<rect width="%d" height="20" fill="#555"/>
+	<rect x="%d" width="%d" height="20" fill="%s"/>
+	<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+		<text x="%d" y="14">%s</text>
+		<text x="%d" y="14">%s</text>
+	</g>
+</svg>
+`
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestCrawlSummary(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+		return link
+	}
+
+	summary := newCrawlSummary()
+	summary.Observe(crawler.LinkReport{
+		Path:   parse("http://example.com/"),
+		Status: crawler.Status{IsLive: true},
+		PointsTo: []crawler.LinkReport{
+			{Path: parse("http://example.com/services/"), Status: crawler.Status{IsLive: true}},
+			{Path: parse("http://example.com/gone")},
+		},
+	})
+	summary.Observe(crawler.LinkReport{
+		Path:     parse("http://example.com/services"),
+		Status:   crawler.Status{IsLive: true},
+		PointsTo: []crawler.LinkReport{{Path: parse("http://example.com/gone")}},
+	})
+
+	if summary.Pages != 2 || summary.Live != 2 || summary.Broken != 1 {
+		tests.Info("Received Summary: %#v", summary)
+		tests.Failed("Should have counted pages and unique broken links")
+	}
+	tests.Passed("Should have counted pages and unique broken links")
+
+	summary.Cover([]*url.URL{
+		parse("https://example.com/"),
+		parse("http://example.com/services/"),
+		parse("http://example.com/about"),
+		parse("http://example.com/blog"),
+	})
+
+	if summary.SitemapURLs != 4 || summary.Covered != 2 || summary.Coverage != 50 {
+		tests.Info("Received Summary: %#v", summary)
+		tests.Failed("Should have measured coverage of sitemap urls")
+	}
+	tests.Passed("Should have measured coverage of sitemap urls")
+
+	if summary.Message() != "50.0%, 1 broken" {
+		tests.Info("Received Message: %q", summary.Message())
+		tests.Failed("Should have summarized coverage and broken links")
+	}
+	tests.Passed("Should have summarized coverage and broken links")
+
+	var json bytes.Buffer
+	if err := summary.WriteJSON(&json); err != nil || !strings.Contains(json.String(), `"broken_links": 1`) {
+		tests.Info("Received JSON: %s", json.String())
+		tests.Failed("Should have written summary as json")
+	}
+	tests.Passed("Should have written summary as json")
+
+	var svg bytes.Buffer
+	if err := summary.WriteSVG(&svg); err != nil {
+		tests.FailedWithError(err, "Should have successfully written badge")
+	}
+
+	var badge struct {
+		Texts []string `xml:"g>text"`
+	}
+
+	if err := xml.Unmarshal(svg.Bytes(), &badge); err != nil {
+		tests.FailedWithError(err, "Should have written well formed svg")
+	}
+
+	if len(badge.Texts) != 2 || badge.Texts[0] != "sitemap coverage" || badge.Texts[1] != "50.0%, 1 broken" {
+		tests.Info("Received Badge: %s", svg.String())
+		tests.Failed("Should have labelled badge with coverage")
+	}
+	tests.Passed("Should have labelled badge with coverage")
+}
+
+func TestCrawlSummaryTruncated(t *testing.T) {
+	summary := newCrawlSummary()
+	summary.Truncated = true
+
+	if summary.Message() != "0 pages, 0 broken, truncated" {
+		tests.Info("Received Message: %q", summary.Message())
+		tests.Failed("Should have marked summary of truncated crawl")
+	}
+	tests.Passed("Should have marked summary of truncated crawl")
+
+	var json bytes.Buffer
+	if err := summary.WriteJSON(&json); err != nil || !strings.Contains(json.String(), `"truncated": true`) {
+		tests.Info("Received JSON: %s", json.String())
+		tests.Failed("Should have written truncated marker as json")
+	}
+	tests.Passed("Should have written truncated marker as json")
+}
+
+func TestCrawlSummaryErrors(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		link, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+		return link
+	}
+
+	failed := func(raw string, code crawler.ReasonCode, message string, status int) crawler.LinkReport {
+		return crawler.LinkReport{
+			Path:   parse(raw),
+			Status: crawler.Status{LastStatus: status, Reason: &crawler.Reason{Code: code, Message: message}},
+		}
+	}
+
+	const x509 = "x509: certificate signed by unknown authority"
+
+	summary := newCrawlSummary()
+	summary.Observe(crawler.LinkReport{
+		Path:   parse("http://example.com/"),
+		Status: crawler.Status{IsLive: true},
+		PointsTo: []crawler.LinkReport{
+			failed("http://example.com/gone", crawler.ReasonHTTPStatus, "url path failed to respond, possible dead", 404),
+			failed("https://cdn.example.com/a.js", crawler.ReasonTLS, `Get "https://cdn.example.com/a.js": `+x509, 0),
+			failed("https://cdn.example.com/b.js", crawler.ReasonTLS, `Get "https://cdn.example.com/b.js": `+x509, 0),
+			failed("http://example.com/loop", crawler.ReasonTrap, "skipped: trap suspected", 0),
+		},
+	})
+	summary.Observe(crawler.LinkReport{
+		Path:   parse("http://example.com/about"),
+		Status: crawler.Status{IsLive: true},
+		PointsTo: []crawler.LinkReport{
+			failed("https://cdn.example.com/a.js", crawler.ReasonTLS, `Get "https://cdn.example.com/a.js": `+x509, 0),
+			failed("https://cdn.example.com/c.js", crawler.ReasonTLS, `Get "https://cdn.example.com/c.js": `+x509, 0),
+		},
+	})
+
+	if len(summary.Errors) != 2 || summary.Failed() != 4 {
+		tests.Info("Received Errors: %d, Failed: %d", len(summary.Errors), summary.Failed())
+		tests.Failed("Should have digested distinct errors of failed urls once")
+	}
+	tests.Passed("Should have digested distinct errors of failed urls once")
+
+	if first := summary.Errors[0]; first.Code != crawler.ReasonTLS || first.Message != x509 || first.URLs != 3 {
+		tests.Info("Received Error: %#v", first)
+		tests.Failed("Should have listed most frequent error first without it's urls")
+	}
+	tests.Passed("Should have listed most frequent error first without it's urls")
+
+	if second := summary.Errors[1]; second.Code != crawler.ReasonHTTPStatus || !strings.HasSuffix(second.Message, "(404)") || second.URLs != 1 {
+		tests.Info("Received Error: %#v", second)
+		tests.Failed("Should have digested http status errors by their status")
+	}
+	tests.Passed("Should have digested http status errors by their status")
+}
+
+func TestCrawlSummaryParseFailures(t *testing.T) {
+	page, err := url.Parse("https://mombo.com/broken")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	summary := newCrawlSummary()
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, ParseWarnings: []string{"stray </div>"}})
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, ParseWarnings: []string{"stray </div>"}})
+
+	if summary.ParseFailures != 1 {
+		tests.Info("Received: %d", summary.ParseFailures)
+		tests.Failed("Should have counted pages with parse warnings once")
+	}
+	tests.Passed("Should have counted pages with parse warnings once")
+}
+
+func TestCrawlSummaryDeadRoutes(t *testing.T) {
+	page, err := url.Parse("https://mombo.com/careers")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	summary := newCrawlSummary()
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, RouteError: "renders not found view"})
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, RouteError: "renders not found view"})
+
+	if len(summary.DeadRoutes) != 1 || summary.DeadRoutes[0].URL != page.String() || summary.DeadRoutes[0].Reason != "renders not found view" {
+		tests.Info("Received: %#v", summary.DeadRoutes)
+		tests.Failed("Should have listed client-side dead routes once")
+	}
+	tests.Passed("Should have listed client-side dead routes once")
+}
+
+func TestCrawlSummarySocialIssues(t *testing.T) {
+	page, err := url.Parse("https://mombo.com/careers")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	about, err := url.Parse("https://mombo.com/about")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	issues := []string{"missing twitter:card", "og:image is broken, status 404"}
+
+	summary := newCrawlSummary()
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, Social: &crawler.SocialTags{Issues: issues}})
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, Social: &crawler.SocialTags{Issues: issues}})
+	summary.Observe(crawler.LinkReport{Path: about, Status: crawler.Status{IsLive: true}, Social: &crawler.SocialTags{OGTitle: "About"}})
+
+	if len(summary.SocialIssues) != 1 || summary.SocialIssues[0].URL != page.String() || len(summary.SocialIssues[0].Issues) != 2 {
+		tests.Info("Received: %#v", summary.SocialIssues)
+		tests.Failed("Should have listed pages with social tag issues once")
+	}
+	tests.Passed("Should have listed pages with social tag issues once")
+}
+
+func TestCrawlSummaryStructuredData(t *testing.T) {
+	page, err := url.Parse("https://mombo.com/careers")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	about, err := url.Parse("https://mombo.com/about")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed url")
+	}
+	tests.Passed("Should have successfully parsed url")
+
+	careers := &crawler.StructuredData{JSONLD: []string{"JobPosting", "Organization"}, Blocks: 2, Errors: []string{"block 2: unexpected end of JSON input"}}
+
+	summary := newCrawlSummary()
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, Structured: careers})
+	summary.Observe(crawler.LinkReport{Path: page, Status: crawler.Status{IsLive: true}, Structured: careers})
+	summary.Observe(crawler.LinkReport{Path: about, Status: crawler.Status{IsLive: true}, Structured: &crawler.StructuredData{JSONLD: []string{"Organization"}, Microdata: []string{"Organization"}, Blocks: 1}})
+
+	if len(summary.StructuredTypes) != 2 || summary.StructuredTypes["Organization"] != 2 || summary.StructuredTypes["JobPosting"] != 1 {
+		tests.Info("Received: %#v", summary.StructuredTypes)
+		tests.Failed("Should have counted the pages declaring each type once")
+	}
+	tests.Passed("Should have counted the pages declaring each type once")
+
+	if len(summary.StructuredErrors) != 1 || summary.StructuredErrors[0].URL != page.String() || len(summary.StructuredErrors[0].Errors) != 1 {
+		tests.Info("Received: %#v", summary.StructuredErrors)
+		tests.Failed("Should have listed pages with JSON-LD parse errors once")
+	}
+	tests.Passed("Should have listed pages with JSON-LD parse errors once")
+}
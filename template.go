@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+// ErrSplitTemplate is returned when a custom template is used with split outputs,
+// as a template renders a single document of all reports.
+var ErrSplitTemplate = errors.New("custom templates render a single output and can't be split")
+
+// templateData embodies the context custom templates are rendered with.
+type templateData struct {
+	Target      *url.URL
+	GeneratedAt time.Time
+	Reports     []crawler.LinkReport
+	Summary     *crawlSummary
+	Graph       *templateGraph
+}
+
+// templateGraph embodies the link graph metrics of a crawl exposed to custom
+// templates.
+type templateGraph struct {
+	Pages    int
+	Links    int
+	DeadEnds int
+	Orphans  []crawler.LinkReport
+	inlinks  map[string]int
+}
+
+// Inlinks returns total links pointing to giving url from crawled pages.
+func (g *templateGraph) Inlinks(link *url.URL) int {
+	return g.inlinks[summaryKey(link)]
+}
+
+// templateWriter implements the ReportWriter which renders all reports of a
+// crawl with a custom template once flushed.
+type templateWriter struct {
+	w       io.Writer
+	tmpl    *template.Template
+	target  *url.URL
+	reports []crawler.LinkReport
+	summary *crawlSummary
+}
+
+// newTemplateWriter returns a new templateWriter rendering the template stored
+// in giving file into w.
+func newTemplateWriter(file string, target *url.URL, w io.Writer) (*templateWriter, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(filepath.Base(file)).Funcs(templateFuncs).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("template %+q: %+s", file, err)
+	}
+
+	return &templateWriter{
+		w:       w,
+		tmpl:    tmpl,
+		target:  target,
+		summary: newCrawlSummary(),
+	}, nil
+}
+
+// Write collects giving report till Flush is called.
+func (t *templateWriter) Write(report crawler.LinkReport) error {
+	t.reports = append(t.reports, report)
+	t.summary.Observe(report)
+	return nil
+}
+
+// Flush renders the template with all collected reports.
+func (t *templateWriter) Flush() error {
+	graph := &templateGraph{inlinks: map[string]int{}}
+	for _, report := range t.reports {
+		graph.Pages++
+		if len(report.PointsTo) == 0 {
+			graph.DeadEnds++
+		}
+
+		for _, kid := range report.PointsTo {
+			graph.Links++
+			graph.inlinks[summaryKey(kid.Path)]++
+		}
+	}
+
+	// Pages no other crawled page links to, such as those only reached
+	// through redirects, are orphans. The target is reached directly.
+	for _, report := range t.reports {
+		key := summaryKey(report.Path)
+		if graph.inlinks[key] == 0 && key != summaryKey(t.target) {
+			graph.Orphans = append(graph.Orphans, report)
+		}
+	}
+
+	data := templateData{
+		Target:      t.target,
+		GeneratedAt: time.Now(),
+		Reports:     t.reports,
+		Summary:     t.summary,
+		Graph:       graph,
+	}
+
+	if err := t.tmpl.Execute(t.w, data); err != nil {
+		return fmt.Errorf("template %+q: %+s", t.tmpl.Name(), err)
+	}
+	return nil
+}
+
+// templateExtension returns the file extension of outputs rendered with giving
+// template file and compression.
+func templateExtension(file string, compression string) string {
+	ext := filepath.Ext(file)
+	switch strings.ToLower(compression) {
+	case "gzip", "gz":
+		ext += ".gz"
+	case "zstd", "zst":
+		ext += ".zst"
+	}
+	return ext
+}
+
+// templateFuncs holds the helpers available to custom templates, in addition
+// to those of the sitemap templates.
+var templateFuncs = template.FuncMap{
+	"loc":         sitemapLoc,
+	"locString":   sitemapLocString,
+	"xml":         xmlEscape,
+	"url":         encodeURL,
+	"statusClass": statusClass,
+	"duration":    formatDuration,
+	"relative":    relativeURL,
+}
+
+// statusClass returns the class of giving http status code e.g 2xx, else
+// "error" for urls which failed without a response.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// formatDuration returns giving duration rounded for display, to the
+// millisecond below a minute else to the second.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return d.String()
+	case d < time.Minute:
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// relativeURL returns giving url relative to base, as a path if both lie on
+// the same host else as the full url.
+func relativeURL(base *url.URL, target *url.URL) string {
+	if target == nil {
+		return ""
+	}
+
+	if base == nil || base.Scheme != target.Scheme || base.Host != target.Host {
+		return encodeURL(target)
+	}
+
+	dir := base.Path
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+
+	relative := relativePath(dir, target.Path)
+	if strings.HasSuffix(target.Path, "/") && !strings.HasSuffix(relative, "/") {
+		relative += "/"
+	}
+
+	rel := url.URL{Path: relative, RawQuery: target.RawQuery, Fragment: target.Fragment}
+	return escapeURL(rel.String())
+}
+
+// relativePath returns the path of target relative to the directory dir, both
+// being absolute slash separated paths.
+func relativePath(dir string, target string) string {
+	from := strings.Split(strings.Trim(dir, "/"), "/")
+	to := strings.Split(strings.Trim(target, "/"), "/")
+	if from[0] == "" {
+		from = nil
+	}
+	if to[0] == "" {
+		to = nil
+	}
+
+	var common int
+	for common < len(from) && common < len(to) && from[common] == to[common] {
+		common++
+	}
+
+	var parts []string
+	for range from[common:] {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, to[common:]...)
+
+	if len(parts) == 0 {
+		return "."
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestTemplateWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sitecrawler-template")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created temp dir")
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "report.md")
+	if err := ioutil.WriteFile(file, []byte(`# {{ .Target.Host }}: {{ .Summary.Pages }} pages, {{ .Summary.Broken }} broken, {{ .Graph.Links }} links
+{{ range .Reports }}- {{ relative $.Target .Path }} {{ statusClass .Status.LastStatus }} {{ duration .Latency }} {{ $.Graph.Inlinks .Path }} inlinks
+{{ end }}{{ range .Graph.Orphans }}orphan: {{ url .Path }}
+{{ end }}`), 0644); err != nil {
+		tests.FailedWithError(err, "Should have successfully written template")
+	}
+
+	target, _ := url.Parse("https://example.com/")
+	services, _ := url.Parse("https://example.com/services/")
+	careers, _ := url.Parse("https://example.com/about/careers")
+
+	var out bytes.Buffer
+	writer, err := newTemplateWriter(file, target, &out)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully parsed template")
+	}
+	tests.Passed("Should have successfully parsed template")
+
+	reports := []crawler.LinkReport{
+		{
+			Path:     target,
+			Status:   crawler.Status{LastStatus: 200, IsLive: true},
+			Latency:  1234567 * time.Nanosecond,
+			PointsTo: []crawler.LinkReport{{Path: services, Status: crawler.Status{LastStatus: 200, IsLive: true}}},
+		},
+		{Path: services, Status: crawler.Status{LastStatus: 200, IsLive: true}, Latency: 2 * time.Minute},
+		{Path: careers, Status: crawler.Status{}},
+	}
+
+	for _, report := range reports {
+		if err := writer.Write(report); err != nil {
+			tests.FailedWithError(err, "Should have successfully written report")
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		tests.FailedWithError(err, "Should have successfully rendered template")
+	}
+	tests.Passed("Should have successfully rendered template")
+
+	expected := `# example.com: 3 pages, 1 broken, 1 links
+- ./ 2xx 1ms 0 inlinks
+- services/ 2xx 2m0s 1 inlinks
+- about/careers error 0s 0 inlinks
+orphan: https://example.com/about/careers
+`
+	if out.String() != expected {
+		tests.Info("Received Output: %q", out.String())
+		tests.Failed("Should have rendered reports with helpers, summary and graph metrics")
+	}
+	tests.Passed("Should have rendered reports with helpers, summary and graph metrics")
+}
+
+func TestRelativeURL(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+	for raw, expected := range map[string]string{
+		"https://example.com/blog/other":      "other",
+		"https://example.com/about?tab=a b":   "../about?tab=a%20b",
+		"https://example.com/blog/":           "./",
+		"https://cdn.example.com/app.js":      "https://cdn.example.com/app.js",
+		"http://example.com/blog/other":       "http://example.com/blog/other",
+		"https://example.com/blog/2020/intro": "2020/intro",
+	} {
+		target, _ := url.Parse(raw)
+		if relative := relativeURL(base, target); relative != expected {
+			tests.Info("Received URL: %q", relative)
+			tests.Failed("Should have made %q relative as %q", raw, expected)
+		}
+	}
+	tests.Passed("Should have made urls relative to base")
+}
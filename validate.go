@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// limits of a sitemap per the sitemaps spec.
+const (
+	sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	maxSitemapSize   = 50 * 1024 * 1024
+	maxSitemapURLs   = 50000
+	maxLocLength     = 2048
+)
+
+// rawLocs matches the raw, unescaped content of loc elements of a sitemap.
+var rawLocs = regexp.MustCompile(`<loc>([^<]*)</loc>`)
+
+// sitemapProblem embodies a violation of the sitemaps spec found in a sitemap.
+type sitemapProblem struct {
+	Loc     string
+	Message string
+}
+
+// String returns the problem as a printable line.
+func (p sitemapProblem) String() string {
+	if p.Loc == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", p.Loc, p.Message)
+}
+
+// sitemapValidation embodies the result of validating a sitemap.
+type sitemapValidation struct {
+	Size     int64
+	Total    int
+	URLs     []*url.URL
+	Problems []sitemapProblem
+}
+
+// sitemapDocument embodies the elements of a sitemap or sitemap index needed
+// for validation.
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemapEntry embodies a url or sitemap entry of a sitemapDocument.
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+// openSitemap returns a reader for the sitemap at giving location, fetching
+// it with client if location is a http url, else opening it as a local file.
+// It returns the host of the sitemap for remote sitemaps.
+func openSitemap(client *http.Client, userAgent string, location string) (io.ReadCloser, string, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		file, err := os.Open(location)
+		return file, "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, "", fmt.Errorf("sitemap request failed with status %d", res.StatusCode)
+	}
+
+	return res.Body, req.URL.Host, nil
+}
+
+// validateSitemap validates the sitemap read from r against the sitemaps spec,
+// checking it's size, total urls, escaping and that all urls lie within giving
+// host. If host is empty, the host of the first url is used. Gzipped sitemaps
+// are decompressed before validation. It returns an error if the sitemap is
+// not well formed xml.
+func validateSitemap(r io.Reader, host string) (sitemapValidation, error) {
+	var validation sitemapValidation
+
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return validation, err
+		}
+
+		defer gz.Close()
+		r = gz
+	} else {
+		r = buffered
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxSitemapSize+1))
+	if err != nil {
+		return validation, err
+	}
+
+	validation.Size = int64(len(data))
+	if validation.Size > maxSitemapSize {
+		validation.Problems = append(validation.Problems, sitemapProblem{
+			Message: fmt.Sprintf("sitemap exceeds %d bytes uncompressed", maxSitemapSize),
+		})
+		return validation, nil
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return validation, fmt.Errorf("malformed sitemap xml: %+s", err)
+	}
+
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		validation.Problems = append(validation.Problems, sitemapProblem{
+			Message: fmt.Sprintf("root element %q is not urlset or sitemapindex", doc.XMLName.Local),
+		})
+	}
+
+	if doc.XMLName.Space != sitemapNamespace {
+		validation.Problems = append(validation.Problems, sitemapProblem{
+			Message: fmt.Sprintf("root element namespace %q is not %q", doc.XMLName.Space, sitemapNamespace),
+		})
+	}
+
+	entries := append(doc.URLs, doc.Sitemaps...)
+	validation.Total = len(entries)
+	if validation.Total > maxSitemapURLs {
+		validation.Problems = append(validation.Problems, sitemapProblem{
+			Message: fmt.Sprintf("sitemap lists %d urls, more than %d", validation.Total, maxSitemapURLs),
+		})
+	}
+
+	// Entities must be escaped, though xml allows quotes and > within text.
+	for _, match := range rawLocs.FindAllSubmatch(data, -1) {
+		if bytes.ContainsAny(match[1], `'">`) {
+			validation.Problems = append(validation.Problems, sitemapProblem{
+				Loc:     strings.TrimSpace(string(match[1])),
+				Message: "url is not entity escaped",
+			})
+		}
+	}
+
+	for _, entry := range entries {
+		loc := strings.TrimSpace(entry.Loc)
+
+		link, problem := validateLoc(loc, host)
+		if problem != "" {
+			validation.Problems = append(validation.Problems, sitemapProblem{Loc: loc, Message: problem})
+			continue
+		}
+
+		if host == "" {
+			host = link.Host
+		}
+
+		validation.URLs = append(validation.URLs, link)
+	}
+
+	return validation, nil
+}
+
+// validateLoc returns the parsed url of giving sitemap location, else the
+// problem found with it.
+func validateLoc(loc string, host string) (*url.URL, string) {
+	if loc == "" {
+		return nil, "url has no loc"
+	}
+
+	if len(loc) > maxLocLength {
+		return nil, fmt.Sprintf("url is longer than %d characters", maxLocLength)
+	}
+
+	if escapeURL(loc) != loc {
+		return nil, "url is not percent-encoded"
+	}
+
+	link, err := url.Parse(loc)
+	if err != nil {
+		return nil, fmt.Sprintf("url is invalid: %+s", err)
+	}
+
+	if (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+		return nil, "url is not an absolute http(s) url"
+	}
+
+	if host != "" && link.Host != host {
+		return nil, fmt.Sprintf("url is not on sitemap host %q", host)
+	}
+
+	return link, ""
+}
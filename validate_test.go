@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influx6/faux/tests"
+	"github.com/influx6/sitecrawler/crawler"
+)
+
+func TestValidateSitemap(t *testing.T) {
+	sitemap := `<?xml version="1.0" encoding="UTF-8"?>
+	<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+		<url><loc>http://example.com/</loc></url>
+		<url><loc>http://example.com/search?q=a%20b&amp;lang=en</loc></url>
+		<url><loc> http://example.com/padded </loc></url>
+	</urlset>`
+
+	validation, err := validateSitemap(strings.NewReader(sitemap), "example.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully validated sitemap")
+	}
+	tests.Passed("Should have successfully validated sitemap")
+
+	if validation.Total != 3 || len(validation.URLs) != 3 || len(validation.Problems) != 0 {
+		tests.Info("Received Problems: %#v", validation.Problems)
+		tests.Failed("Should have found sitemap valid")
+	}
+	tests.Passed("Should have found sitemap valid")
+}
+
+func TestValidateSitemapProblems(t *testing.T) {
+	sitemap := `<?xml version="1.0" encoding="UTF-8"?>
+	<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+		<url><loc>http://example.com/</loc></url>
+		<url><loc>http://example.com/a b</loc></url>
+		<url><loc>http://example.com/café</loc></url>
+		<url><loc>http://example.com/it's</loc></url>
+		<url><loc>http://other.com/</loc></url>
+		<url><loc>/relative</loc></url>
+		<url></url>
+	</urlset>`
+
+	validation, err := validateSitemap(strings.NewReader(sitemap), "")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully validated sitemap")
+	}
+	tests.Passed("Should have successfully validated sitemap")
+
+	expected := []string{
+		"http://example.com/it's: url is not entity escaped",
+		"http://example.com/a b: url is not percent-encoded",
+		"http://example.com/café: url is not percent-encoded",
+		"http://other.com/: url is not on sitemap host \"example.com\"",
+		"/relative: url is not an absolute http(s) url",
+		"url has no loc",
+	}
+
+	if len(validation.Problems) != len(expected) {
+		tests.Info("Received Problems: %#v", validation.Problems)
+		tests.Failed("Should have found all problems of sitemap")
+	}
+
+	for index, problem := range validation.Problems {
+		if problem.String() != expected[index] {
+			tests.Info("Received Problem: %q", problem.String())
+			tests.Failed("Should have found problem %q", expected[index])
+		}
+	}
+	tests.Passed("Should have found all problems of sitemap")
+
+	if len(validation.URLs) != 2 {
+		tests.Info("Received URLs: %d", len(validation.URLs))
+		tests.Failed("Should have only kept valid urls")
+	}
+	tests.Passed("Should have only kept valid urls")
+}
+
+func TestValidateSitemapLimits(t *testing.T) {
+	var sitemap bytes.Buffer
+	sitemap.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for index := 0; index <= maxSitemapURLs; index++ {
+		fmt.Fprintf(&sitemap, "<url><loc>http://example.com/%d</loc></url>", index)
+	}
+	sitemap.WriteString(`</urlset>`)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(sitemap.Bytes())
+	gz.Close()
+
+	validation, err := validateSitemap(&compressed, "example.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully validated gzipped sitemap")
+	}
+	tests.Passed("Should have successfully validated gzipped sitemap")
+
+	if validation.Total != maxSitemapURLs+1 || len(validation.Problems) != 1 || !strings.Contains(validation.Problems[0].Message, "more than") {
+		tests.Info("Received Problems: %#v", validation.Problems)
+		tests.Failed("Should have found sitemap lists too many urls")
+	}
+	tests.Passed("Should have found sitemap lists too many urls")
+}
+
+func TestValidateSitemapMalformed(t *testing.T) {
+	sitemap := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+		<url><loc>http://example.com/?a=1&b=2</loc></url>
+	</urlset>`
+
+	if _, err := validateSitemap(strings.NewReader(sitemap), ""); err == nil {
+		tests.Failed("Should have failed to validate sitemap with unescaped entities")
+	}
+	tests.Passed("Should have failed to validate sitemap with unescaped entities")
+}
+
+func TestValidateWrittenSitemap(t *testing.T) {
+	var out bytes.Buffer
+	writer, _ := newReportWriter("xml", &out)
+
+	for raw := range nastyURLs {
+		target, err := url.Parse(raw)
+		if err != nil {
+			tests.FailedWithError(err, "Should have successfully parsed url %q", raw)
+		}
+
+		if target.Host != "example.com" {
+			continue
+		}
+
+		writer.Write(crawler.LinkReport{Path: target})
+	}
+	writer.Flush()
+
+	validation, err := validateSitemap(&out, "example.com")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully validated written sitemap")
+	}
+
+	if len(validation.Problems) != 0 {
+		tests.Info("Received Problems: %#v", validation.Problems)
+		tests.Failed("Should have written a valid sitemap")
+	}
+	tests.Passed("Should have written a valid sitemap")
+}
+
+func TestValidateExitStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate-status")
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully created directory")
+	}
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "valid.xml")
+	if err := ioutil.WriteFile(valid, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+	<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+		<url><loc>http://example.com/</loc></url>
+	</urlset>`), 0644); err != nil {
+		tests.FailedWithError(err, "Should have successfully written sitemap")
+	}
+
+	invalid := filepath.Join(dir, "invalid.xml")
+	if err := ioutil.WriteFile(invalid, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+	<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+		<url><loc>http://example.com/a b</loc></url>
+	</urlset>`), 0644); err != nil {
+		tests.FailedWithError(err, "Should have successfully written sitemap")
+	}
+
+	status, stderr, err := runMain("validate", valid)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run validate")
+	}
+
+	if status != 0 {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have exited with status 0 for valid sitemap")
+	}
+	tests.Passed("Should have exited with status 0 for valid sitemap")
+
+	status, stderr, err = runMain("validate", invalid)
+	if err != nil {
+		tests.FailedWithError(err, "Should have successfully run validate")
+	}
+
+	if status != 1 || !strings.Contains(stderr, "is invalid") {
+		tests.Info("Status: %d, Stderr: %q", status, stderr)
+		tests.Failed("Should have exited with status 1 for invalid sitemap")
+	}
+	tests.Passed("Should have exited with status 1 for invalid sitemap")
+}